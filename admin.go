@@ -0,0 +1,177 @@
+package mklog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// AdminHandler returns an http.Handler exposing operational controls of the Debugger instance.
+// It is meant to be mounted on an internal address, e.g. mux.Handle("/debug/mklog/", http.StripPrefix("/debug/mklog", d.AdminHandler())).
+//
+//	POST /rotate                       rotates the log files of all rules
+//	POST /rotate?module=api&index=0    rotates the log file of a single rule
+//	GET  /stats                        returns the Stats snapshot as JSON
+//	GET  /health                       answers 200 when Healthy, 503 otherwise
+//	GET  /rules                        returns the Rules snapshot as JSON
+//	POST /rules?module=api&index=0&enabled=false
+//	                                   enables or disables a single rule
+//	GET  /logs/recent?level=error&module=api&limit=100
+//	                                   returns the entries of the recent buffer as JSON, see SetRecentBuffer
+//	GET  /verbosity                    returns the verbosity shift as JSON
+//	POST /verbosity?delta=1            shifts the verbosity, see BumpVerbosity
+func (d *Debugger) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rules", d.handleRules)
+	mux.HandleFunc("/health", d.handleHealth)
+	mux.HandleFunc("/rotate", d.handleRotate)
+	mux.HandleFunc("/stats", d.handleStats)
+	mux.HandleFunc("/logs/recent", d.handleRecent)
+	mux.HandleFunc("/verbosity", d.handleVerbosity)
+	return mux
+}
+
+// handleStats writes the Stats snapshot.
+func (d *Debugger) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeAdminJSON(w, d.Stats())
+}
+
+// handleRecent writes the entries of the recent buffer selected by the level, module and limit parameters.
+func (d *Debugger) handleRecent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := RecentFilter{Module: query.Get("module")}
+	if level := query.Get("level"); level != "" {
+		var err error
+		if filter.MinLevel, err = StringToLogLevel(level); err != nil {
+			http.Error(w, "invalid level: "+level, http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			http.Error(w, "invalid limit: "+limit, http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries := d.Recent(filter)
+	if entries == nil {
+		entries = []RecentEntry{}
+	}
+	writeAdminJSON(w, entries)
+}
+
+// handleRotate forces a rotation of all log files or of the rule selected by module and index.
+func (d *Debugger) handleRotate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	module := r.URL.Query().Get("module")
+	if module == "" {
+		if err := d.RotateAll(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeAdminJSON(w, map[string]string{"status": "rotated"})
+		return
+	}
+
+	rule, err := d.adminRule(module, r.URL.Query().Get("index"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := rule.RotateNow(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(w, map[string]string{"status": "rotated", "file": rule.FileLog.CurrentFileName})
+}
+
+// handleRules lists the rules or enables and disables the rule selected by module and index.
+func (d *Debugger) handleRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeAdminJSON(w, d.Rules())
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "invalid enabled value: "+r.URL.Query().Get("enabled"), http.StatusBadRequest)
+			return
+		}
+		rule, err := d.adminRule(r.URL.Query().Get("module"), r.URL.Query().Get("index"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if enabled {
+			rule.Enable()
+		} else {
+			rule.Disable()
+		}
+		writeAdminJSON(w, map[string]bool{"enabled": rule.Enabled()})
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleVerbosity returns the verbosity shift or bumps it by the delta parameter.
+func (d *Debugger) handleVerbosity(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeAdminJSON(w, map[string]int{"verbosity": d.VerbosityShift()})
+	case http.MethodPost:
+		delta, err := strconv.Atoi(r.URL.Query().Get("delta"))
+		if err != nil {
+			http.Error(w, "invalid delta: "+r.URL.Query().Get("delta"), http.StatusBadRequest)
+			return
+		}
+		writeAdminJSON(w, map[string]int{"verbosity": d.BumpVerbosity(delta)})
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// adminRule looks up the rule of the module at the given index, the first rule when the index is empty.
+func (d *Debugger) adminRule(module, index string) (*LogRule, error) {
+	rules, ok := d.rules()[module]
+	if !ok {
+		return nil, fmt.Errorf("unknown module: %s", module)
+	}
+
+	i := 0
+	if index != "" {
+		var err error
+		if i, err = strconv.Atoi(index); err != nil {
+			return nil, fmt.Errorf("invalid rule index: %s", index)
+		}
+	}
+	if i < 0 || i >= len(rules) {
+		return nil, fmt.Errorf("module %s has no rule %d", module, i)
+	}
+	return rules[i], nil
+}
+
+// writeAdminJSON writes the value as a JSON response.
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
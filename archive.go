@@ -0,0 +1,246 @@
+package mklog
+
+import (
+	"archive/zip"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// Default interval of the archiving task of file logging
+	MKLOG_ArchiveCheckIntervalDefault = time.Hour
+)
+
+// Grouping of archived log files
+const (
+	ArchiveByMonth = "month" // One archive per month, e.g. app-2024-06.zip
+	ArchiveByWeek  = "week"  // One archive per ISO week, e.g. app-2024-W23.zip
+)
+
+// WithFileArchiving bundles rotated and dated log files older than olderThan into zip archives
+// grouped by "month" or "week", removing the files once the archive is verified.
+// The active file is never archived. Archiving runs on the maintenance scheduler.
+func WithFileArchiving(olderThan time.Duration, groupBy string) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.ArchiveAfter = olderThan
+		lr.FileLog.ArchiveGroupBy = groupBy
+	}
+}
+
+// scheduleArchiving registers the maintenance task archiving old log files of the rule.
+func (d *LogRule) scheduleArchiving(name string) {
+	if d.FileLog.writer == nil || d.FileLog.ArchiveAfter <= 0 || d.debugger == nil {
+		return
+	}
+
+	interval := MKLOG_ArchiveCheckIntervalDefault
+	if d.FileLog.ArchiveAfter < interval {
+		interval = d.FileLog.ArchiveAfter
+	}
+	d.debugger.AddMaintenanceTask(name, interval, func(now time.Time) error {
+		if writer := d.fileWriter(); writer != nil {
+			return writer.Archive()
+		}
+		return nil
+	})
+}
+
+// Archive bundles the rotated and dated files older than ArchiveAfter into zip archives grouped by
+// ArchiveGroupBy and removes them once the archive has been verified. Writes wait while archiving,
+// so that no file is rotated into a name that is being archived.
+func (w *RotatingWriter) Archive() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.options.ArchiveAfter <= 0 || w.name == "" {
+		return nil
+	}
+
+	dir := filepath.Dir(w.name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read log directory: %w", err)
+	}
+
+	cutoff := w.now().Add(-w.options.ArchiveAfter)
+	groups := make(map[string][]archiveFile)
+	for _, e := range entries {
+		if e.IsDir() || !w.isManagedFile(e.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if path == w.name {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		archive := filepath.Join(dir, w.archiveName(info.ModTime()))
+		groups[archive] = append(groups[archive], archiveFile{path: path, info: info})
+	}
+
+	archives := make([]string, 0, len(groups))
+	for archive := range groups {
+		archives = append(archives, archive)
+	}
+	sort.Strings(archives)
+
+	for _, archive := range archives {
+		if err := addToArchive(archive, groups[archive]); err != nil {
+			return fmt.Errorf("failed to archive log files into %s: %w", archive, err)
+		}
+	}
+	return nil
+}
+
+// archiveName returns the name of the archive holding files modified at t.
+func (w *RotatingWriter) archiveName(t time.Time) string {
+	if w.options.ArchiveGroupBy == ArchiveByWeek {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%s-%d-W%02d.zip", w.options.FileName, year, week)
+	}
+	return fmt.Sprintf("%s-%s.zip", w.options.FileName, t.Format("2006-01"))
+}
+
+// archiveFile is a log file waiting to be archived.
+type archiveFile struct {
+	path string
+	info os.FileInfo
+}
+
+// addToArchive writes the files into the archive, keeping its existing entries, verifies the
+// written entries and removes the files. The archive is replaced atomically.
+func addToArchive(archive string, files []archiveFile) error {
+	tmp := archive + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // No-op after the rename.
+
+	zw := zip.NewWriter(out)
+	names := make(map[string]bool)
+
+	// Copy the entries of the existing archive.
+	if existing, err := zip.OpenReader(archive); err == nil {
+		for _, f := range existing.File {
+			if err := zw.Copy(f); err != nil {
+				existing.Close()
+				out.Close()
+				return err
+			}
+			names[f.Name] = true
+		}
+		existing.Close()
+	} else if !os.IsNotExist(err) {
+		out.Close()
+		return fmt.Errorf("failed to open existing archive: %w", err)
+	}
+
+	// Add the files, renaming backups whose name is already taken, e.g. app.log.1 of an earlier rotation.
+	added := make(map[string]uint32, len(files))
+	for _, f := range files {
+		name := filepath.Base(f.path)
+		if names[name] {
+			name = fmt.Sprintf("%s-%s", name, f.info.ModTime().Format("20060102T150405"))
+		}
+		crc, err := writeArchiveEntry(zw, name, f)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		names[name] = true
+		added[name] = crc
+	}
+
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := verifyArchive(tmp, added); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, archive); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		os.Remove(f.path)
+	}
+	return nil
+}
+
+// writeArchiveEntry compresses the file into the archive and returns the checksum of its content.
+func writeArchiveEntry(zw *zip.Writer, name string, f archiveFile) (uint32, error) {
+	in, err := os.Open(f.path)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	header, err := zip.FileInfoHeader(f.info)
+	if err != nil {
+		return 0, err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return 0, err
+	}
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(io.MultiWriter(entry, hash), in); err != nil {
+		return 0, err
+	}
+	return hash.Sum32(), nil
+}
+
+// verifyArchive reads the added entries back and compares their checksums.
+func verifyArchive(path string, added map[string]uint32) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("archive verification failed: %w", err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		want, ok := added[f.Name]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("archive verification failed for %s: %w", f.Name, err)
+		}
+		hash := crc32.NewIEEE()
+		_, err = io.Copy(hash, rc) // The zip reader also checks the stored checksum at EOF.
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("archive verification failed for %s: %w", f.Name, err)
+		}
+		if hash.Sum32() != want {
+			return fmt.Errorf("archive verification failed for %s: checksum mismatch", f.Name)
+		}
+		delete(added, f.Name)
+	}
+	if len(added) > 0 {
+		missing := make([]string, 0, len(added))
+		for name := range added {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("archive verification failed: missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
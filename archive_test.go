@@ -0,0 +1,159 @@
+package mklog
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAgedFile creates the file with the content and sets its modification time.
+func writeAgedFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// zipEntries returns the content of the archive by entry name.
+func zipEntries(t *testing.T, path string) map[string]string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	entries := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s in %s: %v", f.Name, path, err)
+		}
+		entries[f.Name] = string(data)
+	}
+	return entries
+}
+
+// TestArchiveByMonth bundles old backups and dated files of two months into one archive per month and
+// leaves the active file, recent files and foreign files alone.
+func TestArchiveByMonth(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local)
+	writeAgedFile(t, filepath.Join(dir, "app.log.1"), "may backup\n", time.Date(2024, 5, 20, 8, 0, 0, 0, time.Local))
+	writeAgedFile(t, filepath.Join(dir, "2024-05-28_app.log"), "may dated\n", time.Date(2024, 5, 28, 23, 0, 0, 0, time.Local))
+	writeAgedFile(t, filepath.Join(dir, "app.log.2"), "june backup\n", time.Date(2024, 6, 3, 8, 0, 0, 0, time.Local))
+	writeAgedFile(t, filepath.Join(dir, "2024-06-05_app.log"), "june dated\n", time.Date(2024, 6, 5, 23, 0, 0, 0, time.Local))
+	writeAgedFile(t, filepath.Join(dir, "app.log.3"), "recent\n", now.Add(-24*time.Hour))
+	writeAgedFile(t, filepath.Join(dir, "other.log"), "foreign\n", time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local))
+	writeAgedFile(t, filepath.Join(dir, "app.log"), "active\n", time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local))
+
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, ArchiveAfter: 7 * 24 * time.Hour, ArchiveGroupBy: ArchiveByMonth, Now: newFakeClock(now).Now})
+	if err := w.Archive(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app-2024-05.zip", "app-2024-06.zip", "app.log", "app.log.3", "other.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	if got := zipEntries(t, filepath.Join(dir, "app-2024-05.zip")); len(got) != 2 || got["app.log.1"] != "may backup\n" || got["2024-05-28_app.log"] != "may dated\n" {
+		t.Errorf("May archive = %q", got)
+	}
+	if got := zipEntries(t, filepath.Join(dir, "app-2024-06.zip")); len(got) != 2 || got["app.log.2"] != "june backup\n" || got["2024-06-05_app.log"] != "june dated\n" {
+		t.Errorf("June archive = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != "active\n" {
+		t.Errorf("active file = %q", got)
+	}
+}
+
+// TestArchiveByWeek names the archives after the ISO week of the archived files.
+func TestArchiveByWeek(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local)
+	writeAgedFile(t, filepath.Join(dir, "app.log.1"), "monday\n", time.Date(2024, 6, 3, 8, 0, 0, 0, time.Local))
+	writeAgedFile(t, filepath.Join(dir, "app.log.2"), "sunday\n", time.Date(2024, 6, 9, 8, 0, 0, 0, time.Local))
+	writeAgedFile(t, filepath.Join(dir, "app.log.3"), "next monday\n", time.Date(2024, 6, 10, 8, 0, 0, 0, time.Local))
+
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, ArchiveAfter: 24 * time.Hour, ArchiveGroupBy: ArchiveByWeek, Now: newFakeClock(now).Now})
+	if err := w.Archive(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app-2024-W23.zip", "app-2024-W24.zip", "app.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	if got := zipEntries(t, filepath.Join(dir, "app-2024-W23.zip")); len(got) != 2 {
+		t.Errorf("week 23 archive = %q", got)
+	}
+}
+
+// TestArchiveKeepsExistingEntries adds to an existing archive of the month, renaming a backup whose name an
+// earlier run archived already.
+func TestArchiveKeepsExistingEntries(t *testing.T) {
+	dir := t.TempDir()
+	clock := newFakeClock(time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local))
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, ArchiveAfter: 24 * time.Hour, Now: clock.Now})
+
+	writeAgedFile(t, filepath.Join(dir, "app.log.1"), "first\n", time.Date(2024, 6, 2, 8, 0, 0, 0, time.Local))
+	if err := w.Archive(); err != nil {
+		t.Fatal(err)
+	}
+	writeAgedFile(t, filepath.Join(dir, "app.log.1"), "second\n", time.Date(2024, 6, 9, 8, 0, 0, 0, time.Local))
+	if err := w.Archive(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"app.log.1": "first\n", "app.log.1-20240609T080000": "second\n"}
+	got := zipEntries(t, filepath.Join(dir, "app-2024-06.zip"))
+	if len(got) != len(want) {
+		t.Fatalf("archive = %q, want %q", got, want)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("entry %s = %q, want %q", name, got[name], content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.log.1")); !os.IsNotExist(err) {
+		t.Errorf("archived backup still exists: %v", err)
+	}
+}
+
+// TestFileArchivingMaintenance archives the old backups of a rule when its maintenance task is due.
+func TestFileArchivingMaintenance(t *testing.T) {
+	dir := t.TempDir()
+	clock := newFakeClock(time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local))
+	writeAgedFile(t, filepath.Join(dir, "app.log.1"), "old\n", time.Date(2024, 5, 2, 8, 0, 0, 0, time.Local))
+
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.NewLogRule("app", WithFileLogging(dir, "app", ".log"), WithFileArchiving(48*time.Hour, ArchiveByMonth))
+	defer d.Close(context.Background())
+
+	d.RunMaintenance()
+	if _, err := os.Stat(filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatalf("archived before the task was due: %v", err)
+	}
+
+	clock.Set(clock.Now().Add(2 * time.Hour))
+	d.RunMaintenance()
+	if got := zipEntries(t, filepath.Join(dir, "app-2024-05.zip")); got["app.log.1"] != "old\n" {
+		t.Errorf("archive = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.log.1")); !os.IsNotExist(err) {
+		t.Errorf("archived backup still exists: %v", err)
+	}
+}
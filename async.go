@@ -0,0 +1,169 @@
+package mklog
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Overflow policies of the async queue
+const (
+	OverflowBlock      = "block"       // Wait for room in the buffer, at most the send timeout if set
+	OverflowDropNewest = "drop_newest" // Drop the message being logged
+	OverflowDropOldest = "drop_oldest" // Drop the oldest queued message to make room
+)
+
+var (
+	// Default settings of the async overflow handling
+	MKLOG_AsyncDropWarningIntervalDefault = time.Minute // Minimum interval between two dropped message warnings of a rule
+)
+
+// WithAsyncOverflowPolicy sets how the rule handles messages logged while its async buffer is full.
+// With OverflowBlock, a positive sendTimeout bounds the wait before the message is dropped.
+// The drop policies require a buffered queue; unbuffered queues always block.
+func WithAsyncOverflowPolicy(policy string, sendTimeout time.Duration) Option {
+	return func(lr *LogRule) {
+		lr.AsyncLog.OverflowPolicy = policy
+		lr.AsyncLog.SendTimeout = sendTimeout
+	}
+}
+
+// WithAsyncBatchSize lets the async consumer write up to size queued messages to the log file at once.
+// A partial batch is written at the flush interval, see WithAsyncFlushInterval, or as soon as the queue
+// is idle when no flush interval is set.
+func WithAsyncBatchSize(size int) Option {
+	return func(lr *LogRule) {
+		lr.AsyncLog.BatchSize = size
+	}
+}
+
+// validOverflowPolicy checks whether the policy names an overflow policy; empty selects OverflowBlock.
+func validOverflowPolicy(policy string) bool {
+	switch policy {
+	case "", OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+		return true
+	}
+	return false
+}
+
+// enqueue sends the message to the async queue, applying the overflow policy when the buffer is full.
+func (lr *LogRule) enqueue(queued QueuedEntry) {
+	policy := lr.AsyncLog.OverflowPolicy
+	if cap(lr.logChannel) == 0 {
+		policy = OverflowBlock
+	}
+
+	switch policy {
+	case OverflowDropNewest:
+		select {
+		case lr.logChannel <- queued:
+		default:
+			lr.dropQueued()
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case lr.logChannel <- queued:
+				return
+			default:
+			}
+			select {
+			case <-lr.logChannel:
+				lr.dropQueued()
+			default:
+			}
+		}
+	default:
+		if lr.AsyncLog.SendTimeout <= 0 {
+			lr.logChannel <- queued
+			return
+		}
+		select {
+		case lr.logChannel <- queued:
+			return
+		default:
+		}
+		timer := time.NewTimer(lr.AsyncLog.SendTimeout)
+		defer timer.Stop()
+		select {
+		case lr.logChannel <- queued:
+		case <-timer.C:
+			lr.dropQueued()
+		}
+	}
+}
+
+// dropQueued counts a message dropped by the overflow policy and warns about it at most once per
+// MKLOG_AsyncDropWarningIntervalDefault.
+func (lr *LogRule) dropQueued() {
+	w := lr.watchdog
+	if w == nil {
+		return
+	}
+	dropped := atomic.AddUint64(&w.dropped, 1)
+
+	now := lr.now().UnixNano()
+	last := atomic.LoadInt64(&w.lastDrop)
+	if last != 0 && time.Duration(now-last) < MKLOG_AsyncDropWarningIntervalDefault {
+		return
+	}
+	if atomic.CompareAndSwapInt64(&w.lastDrop, last, now) {
+		lr.deferError(fmt.Errorf("[mklog] async queue of %s is full, %d messages dropped", lr.ModuleName, dropped))
+	}
+}
+
+// consumeBatch writes the queued messages, joining consecutive messages bound for the same log file into one write.
+func (lr *LogRule) consumeBatch(batch []QueuedEntry) {
+	defer lr.reportDeferred()
+	if len(batch) == 1 {
+		lr.consume(batch[0])
+		return
+	}
+
+	// Queued messages of a disabled rule are discarded unless it drains them.
+	if !lr.Enabled() && !lr.AsyncLog.DrainWhenDisabled {
+		for range batch {
+			lr.markWritten()
+		}
+		return
+	}
+
+	for _, queued := range batch {
+		if lr.consoleOutput(queued.Entry) {
+			lr.writeConsole(queued.Entry, queued.Text)
+		}
+	}
+	if !lr.FileLog.Enable {
+		for range batch {
+			lr.markWritten()
+		}
+		return
+	}
+
+	var sb strings.Builder
+	for start := 0; start < len(batch); {
+		end := start + 1
+		for end < len(batch) && lr.sameLogFile(batch[start].Entry.Time, batch[end].Entry.Time) {
+			end++
+		}
+		sb.Reset()
+		for _, queued := range batch[start:end] {
+			sb.WriteString(lr.fileLine(queued.Text))
+		}
+		if err := lr.writeLog(batch[start].Entry.Time, sb.String()); err != nil {
+			lr.reportError(fmt.Errorf("[mklog] writing %d entries to the log file of %s failed: %w", end-start, lr.ModuleName, err))
+		} else {
+			for range batch[start:end] {
+				lr.markWritten()
+			}
+		}
+		start = end
+	}
+}
+
+// sameLogFile reports whether messages logged at both times are written to the same log file.
+func (lr *LogRule) sameLogFile(a, b time.Time) bool {
+	writer := lr.FileLog.writer
+	return writer == nil || writer.currentFileName(a) == writer.currentFileName(b)
+}
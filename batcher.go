@@ -0,0 +1,184 @@
+package mklog
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatcherOptions configures a Batcher. Sinks apply their own defaults before creating it.
+type BatcherOptions struct {
+	Name          string           // Name of the sink prefixed to reported errors, e.g. "sql sink".
+	FlushInterval time.Duration    // Interval between flushes.
+	BatchSize     int              // Maximum number of items passed to one write, a full batch triggers an early flush.
+	QueueSize     int              // Maximum number of buffered items, further items are dropped.
+	MaxRetries    int              // Number of retries of a failed batch, none when zero.
+	RetryBackoff  time.Duration    // Delay before the first retry, doubled on each retry.
+	Retryable     func(error) bool // Classifies errors worth retrying, every error when nil.
+	ErrorHandler  ErrorHandler     // Handler for failed background flushes, see ErrorHandler.Report.
+}
+
+// Batcher is the bounded buffer of sinks writing entries in batches from a background goroutine, such as the
+// SQL, SQLite and OTLP sinks. Items are flushed at the flush interval or as soon as a batch is full. While the
+// destination is unavailable the buffer is bounded: items beyond its capacity are dropped and counted, so
+// logging never blocks the application.
+type Batcher[T any] struct {
+	options BatcherOptions
+	write   func(batch []T) error // Writes one batch to the destination
+	dropped uint64                // Number of items dropped because the buffer was full
+
+	mu      sync.Mutex    // Guards buffer and the error handler
+	buffer  []T           // Items waiting for the next flush
+	wake    chan struct{} // Signals a full batch
+	done    chan struct{} // Closed to stop the flushing goroutine
+	stopped sync.WaitGroup
+	once    sync.Once
+}
+
+// NewBatcher creates a batcher passing batches of the buffered items to write and starts its flushing goroutine.
+func NewBatcher[T any](options BatcherOptions, write func(batch []T) error) *Batcher[T] {
+	b := &Batcher[T]{
+		options: options,
+		write:   write,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	b.stopped.Add(1)
+	go b.run()
+	return b
+}
+
+// Add buffers the item until the next flush, dropping it when the buffer is full.
+func (b *Batcher[T]) Add(item T) {
+	b.mu.Lock()
+	if len(b.buffer) >= b.options.QueueSize {
+		b.mu.Unlock()
+		atomic.AddUint64(&b.dropped, 1)
+		return
+	}
+	b.buffer = append(b.buffer, item)
+	full := len(b.buffer) >= b.options.BatchSize
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dropped returns the number of items dropped because the buffer was full.
+func (b *Batcher[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Len returns the number of buffered items.
+func (b *Batcher[T]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.buffer)
+}
+
+// Flush writes the buffered items in batches of at most BatchSize items.
+// Items of a batch that could not be written stay buffered for the next flush as far as the buffer has room.
+func (b *Batcher[T]) Flush() error {
+	b.mu.Lock()
+	items := b.buffer
+	b.buffer = nil
+	b.mu.Unlock()
+
+	for len(items) > 0 {
+		n := len(items)
+		if n > b.options.BatchSize {
+			n = b.options.BatchSize
+		}
+
+		if err := b.writeWithRetry(items[:n]); err != nil {
+			b.requeue(items)
+			return err
+		}
+		items = items[n:]
+	}
+	return nil
+}
+
+// Close stops the flushing goroutine and flushes the remaining items.
+func (b *Batcher[T]) Close() error {
+	b.once.Do(func() {
+		close(b.done)
+	})
+	b.stopped.Wait()
+	return b.Flush()
+}
+
+// SetErrorHandler sets the handler receiving failed background flushes.
+func (b *Batcher[T]) SetErrorHandler(handler ErrorHandler) {
+	b.mu.Lock()
+	b.options.ErrorHandler = handler
+	b.mu.Unlock()
+}
+
+// ReportError passes the error, prefixed with the name of the sink, to the error handler.
+func (b *Batcher[T]) ReportError(err error) {
+	b.mu.Lock()
+	handler := b.options.ErrorHandler
+	b.mu.Unlock()
+
+	handler.Report(fmt.Errorf("[mklog] %s: %w", b.options.Name, err))
+}
+
+// run flushes the buffer on every interval or full batch until the batcher is closed.
+func (b *Batcher[T]) run() {
+	defer b.stopped.Done()
+
+	ticker := time.NewTicker(b.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.wake:
+		case <-b.done:
+			return
+		}
+		if err := b.Flush(); err != nil {
+			b.ReportError(err)
+		}
+	}
+}
+
+// requeue puts items back in front of the buffer, dropping the oldest ones beyond its capacity.
+func (b *Batcher[T]) requeue(items []T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buffer = append(items, b.buffer...)
+	if over := len(b.buffer) - b.options.QueueSize; over > 0 {
+		b.buffer = b.buffer[over:]
+		atomic.AddUint64(&b.dropped, uint64(over))
+	}
+}
+
+// writeWithRetry writes the batch, retrying retryable failures with an exponential backoff until the batcher
+// is closed.
+func (b *Batcher[T]) writeWithRetry(batch []T) error {
+	backoff := b.options.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= b.options.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-b.done:
+				return err
+			}
+			backoff *= 2
+		}
+
+		if err = b.write(batch); err == nil || (b.options.Retryable != nil && !b.options.Retryable(err)) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package mklog
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecorder collects the written batches and fails while err is set.
+type batchRecorder struct {
+	mu      sync.Mutex
+	err     error
+	calls   int
+	batches [][]int
+}
+
+func (r *batchRecorder) write(batch []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, append([]int(nil), batch...))
+	return nil
+}
+
+func (r *batchRecorder) setErr(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+// TestBatcherRequeue drops items beyond the queue size and keeps the items of a failed flush for the next one,
+// in order.
+func TestBatcherRequeue(t *testing.T) {
+	rec := &batchRecorder{err: errors.New("down")}
+	b := NewBatcher(BatcherOptions{FlushInterval: time.Hour, BatchSize: 10, QueueSize: 3}, rec.write)
+	defer b.Close()
+
+	for i := 1; i <= 5; i++ {
+		b.Add(i)
+	}
+	if b.Dropped() != 2 || b.Len() != 3 {
+		t.Fatalf("dropped %d, buffered %d, want 2 and 3", b.Dropped(), b.Len())
+	}
+	if err := b.Flush(); err == nil {
+		t.Fatal("Flush succeeded while the writer fails")
+	}
+	b.Add(6)
+	if err := b.Flush(); err == nil {
+		t.Fatal("Flush succeeded while the writer fails")
+	}
+	if b.Dropped() != 3 || b.Len() != 3 {
+		t.Errorf("dropped %d, buffered %d after the requeue, want 3 and 3", b.Dropped(), b.Len())
+	}
+
+	rec.setErr(nil)
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]int{{1, 2, 3}}; !reflect.DeepEqual(rec.batches, want) {
+		t.Errorf("batches = %v, want %v", rec.batches, want)
+	}
+}
+
+// TestBatcherRetry retries failed batches up to MaxRetries, stops at errors that are not retryable and reports
+// failed background flushes with the name of the sink.
+func TestBatcherRetry(t *testing.T) {
+	permanent := errors.New("permanent")
+	for _, tt := range []struct {
+		err   error
+		calls int
+	}{
+		{errors.New("transient"), 3},
+		{permanent, 1},
+	} {
+		rec := &batchRecorder{err: tt.err}
+		b := NewBatcher(BatcherOptions{FlushInterval: time.Hour, BatchSize: 10, QueueSize: 10, MaxRetries: 2,
+			RetryBackoff: time.Millisecond, Retryable: func(err error) bool { return err != permanent }}, rec.write)
+		b.Add(1)
+		if err := b.Flush(); err != tt.err {
+			t.Errorf("Flush = %v, want %v", err, tt.err)
+		}
+		if rec.calls != tt.calls {
+			t.Errorf("%v: %d writes, want %d", tt.err, rec.calls, tt.calls)
+		}
+		b.Close()
+	}
+
+	reported := make(chan error, 1)
+	rec := &batchRecorder{err: permanent}
+	b := NewBatcher(BatcherOptions{Name: "test sink", FlushInterval: time.Millisecond, BatchSize: 10, QueueSize: 10,
+		ErrorHandler: func(err error) {
+			select {
+			case reported <- err:
+			default:
+			}
+		}}, rec.write)
+	defer b.Close()
+	b.Add(1)
+	select {
+	case err := <-reported:
+		if !strings.HasPrefix(err.Error(), "[mklog] test sink: permanent") || !errors.Is(err, permanent) {
+			t.Errorf("reported %q, want the error prefixed with the sink name", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed flush was not reported")
+	}
+}
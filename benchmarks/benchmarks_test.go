@@ -0,0 +1,271 @@
+package benchmarks
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+// benchmark is a logging path measured by the suite.
+type benchmark struct {
+	name           string           // Name of the path
+	fn             func(*testing.B) // Benchmark of the path
+	maxAllocsPerOp int64            // Allocations per logged message allowed by the budget
+}
+
+const (
+	parallelGoroutines = 8       // Goroutines logging concurrently in BenchmarkParallel
+	budgetIterations   = "2000x" // Iterations of each benchmark checked by TestAllocationBudget, amortizing the setup
+)
+
+// Message and arguments of the benchmarks, sized like a typical request log line.
+const benchMessage = "request %s %s completed with status %d in %v for user %s"
+
+var benchArgs = []interface{}{"GET", "/api/v1/orders/8f14e45f-ceea-467a-9af0-2f6b1f8b3c1d", 200, 1530 * time.Microsecond, "user-4711"}
+
+// suite lists the benchmarks with their allocation budget.
+var suite = []benchmark{
+	{name: "DisabledLevel", fn: BenchmarkDisabledLevel, maxAllocsPerOp: 2}, // The message is formatted before the rules are filtered.
+	{name: "ConsolePlain", fn: BenchmarkConsolePlain, maxAllocsPerOp: 9},
+	{name: "FileJSONSync", fn: BenchmarkFileJSONSync, maxAllocsPerOp: 31},
+	{name: "FileJSONAsync", fn: BenchmarkFileJSONAsync, maxAllocsPerOp: 33},
+	{name: "Fields", fn: BenchmarkFields, maxAllocsPerOp: 39},
+	{name: "WithPerRequest", fn: BenchmarkWithPerRequest, maxAllocsPerOp: 33},
+	{name: "WithNested", fn: BenchmarkWithNested, maxAllocsPerOp: 32},
+	{name: "DetailedError", fn: BenchmarkDetailedError, maxAllocsPerOp: 34},
+	{name: "Parallel", fn: BenchmarkParallel, maxAllocsPerOp: 33},
+	{name: "Rules1", fn: BenchmarkRules1, maxAllocsPerOp: 9},
+	{name: "Rules10", fn: BenchmarkRules10, maxAllocsPerOp: 9},
+	{name: "Rules100", fn: BenchmarkRules100, maxAllocsPerOp: 9},
+}
+
+// TestAllocationBudget runs every benchmark of the suite for budgetIterations iterations and fails for
+// each one allocating more per logged message than its budget.
+func TestAllocationBudget(t *testing.T) {
+	if testing.Short() || raceEnabled {
+		t.Skip("allocation budget not checked in short mode or with the race detector")
+	}
+	benchtime := flag.Lookup("test.benchtime")
+	previous := benchtime.Value.String()
+	if err := benchtime.Value.Set(budgetIterations); err != nil {
+		t.Fatal(err)
+	}
+	defer benchtime.Value.Set(previous)
+
+	for _, bm := range suite {
+		r := testing.Benchmark(bm.fn)
+		if r.N == 0 {
+			t.Errorf("%s failed", bm.name)
+			continue
+		}
+		if allocs := r.AllocsPerOp(); allocs > bm.maxAllocsPerOp {
+			t.Errorf("%s allocates %d times per message, the budget is %d", bm.name, allocs, bm.maxAllocsPerOp)
+		}
+	}
+}
+
+// BenchmarkDisabledLevel logs below the minimum level of the only rule.
+func BenchmarkDisabledLevel(b *testing.B) {
+	d := newDebugger(mklog.WithMinLevel(mklog.InfoLevel), mklog.WithConsoleOutput(true))
+	defer closeDebugger(d)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Debug(benchMessage, benchArgs...)
+	}
+}
+
+// BenchmarkConsolePlain logs plain text to the console, which is redirected to the null device.
+func BenchmarkConsolePlain(b *testing.B) {
+	defer redirectStdout(b)()
+	d := newDebugger(mklog.WithConsoleOutput(true), mklog.WithLogFormatter(mklog.PlainTextFormatter{}))
+	defer closeDebugger(d)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Info(benchMessage, benchArgs...)
+	}
+}
+
+// BenchmarkFileJSONSync logs JSON to a log file synchronously.
+func BenchmarkFileJSONSync(b *testing.B) {
+	d := newDebugger(mklog.WithFileLogging(tempDir(b), "bench", ".log"), mklog.WithLogFormatter(mklog.JSONFormatter{}))
+	defer closeDebugger(d)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Info(benchMessage, benchArgs...)
+	}
+}
+
+// BenchmarkFileJSONAsync logs JSON to a log file through the async queue, including the time to drain it.
+func BenchmarkFileJSONAsync(b *testing.B) {
+	d := newDebugger(mklog.WithFileLogging(tempDir(b), "bench", ".log"), mklog.WithLogFormatter(mklog.JSONFormatter{}),
+		mklog.WithAsyncLog(true, 1024))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Info(benchMessage, benchArgs...)
+	}
+	closeDebugger(d)
+}
+
+// BenchmarkFields logs JSON with fields bound by a handle and passed with the call.
+func BenchmarkFields(b *testing.B) {
+	d := newDebugger(mklog.WithFileWriter(io.Discard), mklog.WithLogFormatter(mklog.JSONFormatter{}))
+	defer closeDebugger(d)
+	l := d.With("request_id", "8f14e45f-ceea-467a-9af0-2f6b1f8b3c1d", "method", "GET", "path", "/api/v1/orders")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("order loaded", mklog.Fields{"order_id": 4711, "items": 3, "total": 129.90})
+	}
+}
+
+// BenchmarkWithPerRequest creates a handle with the fields of a request, as an HTTP middleware does, and logs
+// one JSON entry through it.
+func BenchmarkWithPerRequest(b *testing.B) {
+	d := newDebugger(mklog.WithFileWriter(io.Discard), mklog.WithLogFormatter(mklog.JSONFormatter{}))
+	defer closeDebugger(d)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := d.With("request_id", "8f14e45f-ceea-467a-9af0-2f6b1f8b3c1d", "method", "GET", "path", "/api/v1/orders")
+		l.Info("request completed")
+	}
+}
+
+// BenchmarkWithNested logs JSON through a handle three levels below the Debugger, each binding one field.
+func BenchmarkWithNested(b *testing.B) {
+	d := newDebugger(mklog.WithFileWriter(io.Discard), mklog.WithLogFormatter(mklog.JSONFormatter{}))
+	defer closeDebugger(d)
+	l := d.With("service", "billing").With("request_id", "8f14e45f-ceea-467a-9af0-2f6b1f8b3c1d").With("user", "user-4711")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("order loaded")
+	}
+}
+
+// BenchmarkDetailedError logs a wrapped error with detailed error output, capturing the caller and the stack.
+func BenchmarkDetailedError(b *testing.B) {
+	d := newDebugger(mklog.WithFileWriter(io.Discard), mklog.WithLogFormatter(mklog.PlainTextFormatter{}),
+		mklog.WithDetailedErrorOutput(true))
+	defer closeDebugger(d)
+	err := errors.New("connection refused")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Error("loading order failed: %v", d.Wrap(mklog.ErrorLevel, err, "order_id", 4711))
+	}
+}
+
+// BenchmarkParallel logs JSON to a log file from parallelGoroutines goroutines at once.
+func BenchmarkParallel(b *testing.B) {
+	d := newDebugger(mklog.WithFileLogging(tempDir(b), "bench", ".log"), mklog.WithLogFormatter(mklog.JSONFormatter{}))
+	defer closeDebugger(d)
+
+	goroutines := parallelGoroutines
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	for g := 0; g < goroutines; g++ {
+		n := b.N / goroutines
+		if g < b.N%goroutines {
+			n++
+		}
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				d.Info(benchMessage, benchArgs...)
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+// BenchmarkRules1 logs to a module with one rule accepting the entry.
+func BenchmarkRules1(b *testing.B) {
+	benchmarkRules(b, 1)
+}
+
+// BenchmarkRules10 logs to a module with ten rules, one of them accepting the entry.
+func BenchmarkRules10(b *testing.B) {
+	benchmarkRules(b, 10)
+}
+
+// BenchmarkRules100 logs to a module with a hundred rules, one of them accepting the entry.
+func BenchmarkRules100(b *testing.B) {
+	benchmarkRules(b, 100)
+}
+
+// benchmarkRules logs plain text to a module with n rules writing to io.Discard, of which only the first
+// accepts Info entries, measuring the selection of the rules next to a single write.
+func benchmarkRules(b *testing.B, n int) {
+	d := newDebugger(mklog.WithFileWriter(io.Discard), mklog.WithLogFormatter(mklog.PlainTextFormatter{}))
+	defer closeDebugger(d)
+	for i := 1; i < n; i++ {
+		d.NewLogRule("bench", mklog.WithMinLevel(mklog.ErrorLevel), mklog.WithMaxLevel(mklog.FatalLevel),
+			mklog.WithFileWriter(io.Discard), mklog.WithLogFormatter(mklog.PlainTextFormatter{}))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Info(benchMessage, benchArgs...)
+	}
+}
+
+// newDebugger creates a quiet Debugger with one rule for Info to Fatal entries built with the options.
+func newDebugger(opts ...mklog.Option) *mklog.Debugger {
+	d := &mklog.Debugger{}
+	d.SetQuiet(true)
+	opts = append([]mklog.Option{mklog.WithMinLevel(mklog.InfoLevel), mklog.WithMaxLevel(mklog.FatalLevel)}, opts...)
+	d.NewLogRule("bench", opts...)
+	return d
+}
+
+// closeDebugger closes the Debugger, draining async rules.
+func closeDebugger(d *mklog.Debugger) {
+	d.Close(context.Background())
+}
+
+// tempDir creates a directory for the log files of the benchmark, removed when it finishes.
+func tempDir(b *testing.B) string {
+	dir, err := os.MkdirTemp("", "mklog-bench-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// redirectStdout sends the console output to the null device and returns the function restoring it.
+func redirectStdout(b *testing.B) func() {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = null
+	return func() {
+		os.Stdout = stdout
+		null.Close()
+	}
+}
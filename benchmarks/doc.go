@@ -0,0 +1,11 @@
+// Package benchmarks measures the main logging paths of mklog and guards their allocations against a budget.
+//
+// The benchmarks are in the test files of the package. The budget is the table of allocations per logged message
+// in the suite, recorded from the current implementation; the async and parallel paths get a little headroom for
+// allocations depending on scheduling. Changes lowering the allocations of a path should lower its budget as well,
+// so that later changes cannot silently regress it. TestAllocationBudget checks the budget with go test, and
+//
+//	go test -run '^$' -bench . -benchmem ./benchmarks
+//
+// reports the measurements.
+package benchmarks
@@ -0,0 +1,90 @@
+package mklog
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// BurstCaptureField is the field marking the entries noting the start and end of a burst capture.
+const BurstCaptureField = "burst_capture"
+
+// errorBurst lowers the minimum level of a rule for a while after an error.
+type errorBurst struct {
+	duration time.Duration // Duration of the capture after the last error
+	level    LogLevel      // Minimum level while the capture is active
+	deadline int64         // Unix nanoseconds until which the capture is active, accessed atomically
+	active   int32         // Non-zero between the enabled and disabled notes, accessed atomically
+}
+
+// WithErrorBurstCapture lowers the minimum level and the Verbosity of the rule to level for the duration after an
+// entry at ErrorLevel or above passed the rule, e.g. to capture the Debug detail following a failure. Further
+// errors extend the capture. The start and end are noted with entries marked with the BurstCaptureField field;
+// the end is noted before the next entry of the rule after the capture expired.
+func WithErrorBurstCapture(duration time.Duration, level LogLevel) Option {
+	return func(lr *LogRule) {
+		if duration <= 0 {
+			lr.burst = nil
+			return
+		}
+		lr.burst = &errorBurst{duration: duration, level: level}
+	}
+}
+
+// burstAccepts reports whether an active burst capture accepts the level below the minimum level or the Verbosity
+// of the rule.
+func (lr *LogRule) burstAccepts(level LogLevel) bool {
+	b := lr.burst
+	if b == nil || level < b.level {
+		return false
+	}
+	return atomic.LoadInt64(&b.deadline) > lr.now().UnixNano()
+}
+
+// extendBurst starts or extends the burst capture of the rule when the entry is an error.
+func (lr *LogRule) extendBurst(entry Entry) {
+	b := lr.burst
+	if b == nil || entry.Level < ErrorLevel {
+		return
+	}
+
+	until := entry.Time.Add(b.duration).UnixNano()
+	for {
+		deadline := atomic.LoadInt64(&b.deadline)
+		if deadline >= until || atomic.CompareAndSwapInt64(&b.deadline, deadline, until) {
+			break
+		}
+	}
+	if atomic.CompareAndSwapInt32(&b.active, 0, 1) {
+		lr.noteBurst(entry.Time, fmt.Sprintf("burst capture enabled: logging from %s for %s",
+			lr.GetLogLevelName(b.level), b.duration))
+	}
+}
+
+// expireBurst notes the end of the burst capture of the rule once its deadline has passed.
+func (lr *LogRule) expireBurst(now time.Time) {
+	b := lr.burst
+	if b == nil || atomic.LoadInt32(&b.active) == 0 {
+		return
+	}
+	deadline := atomic.LoadInt64(&b.deadline)
+	if now.UnixNano() < deadline || !atomic.CompareAndSwapInt32(&b.active, 1, 0) {
+		return
+	}
+	lr.noteBurst(time.Unix(0, deadline), "burst capture disabled")
+}
+
+// noteBurst writes a note about the burst capture to the outputs and sinks of the rule.
+func (lr *LogRule) noteBurst(t time.Time, msg string) {
+	entry := Entry{
+		Time:       t,
+		Level:      InfoLevel,
+		LevelName:  lr.GetLogLevelName(InfoLevel),
+		Module:     lr.ModuleName,
+		Submodules: lr.Submodules,
+		Message:    msg,
+		Fields:     map[string]interface{}{BurstCaptureField: true},
+	}
+	finalMessage := lr.prepareMessage(entry.Time, entry.Message, entry.Level, false, entry.Fields)
+	lr.deferOutputErrors(entry, lr.writeEntry(&entry, finalMessage))
+}
@@ -0,0 +1,91 @@
+package mklog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestErrorBurstCapture activates the capture with an error, extends it with a second error and expires it
+// with a fake clock, expecting the Debug entries logged in between and the notes of its start and end.
+func TestErrorBurstCapture(t *testing.T) {
+	out := &syncBuffer{}
+	start := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.NewLogRule("app", WithFileWriter(out), WithDateFormat("15:04:05"), WithErrorBurstCapture(30*time.Second, DebugLevel))
+	rule := d.rules()["app"][0]
+
+	at := func(seconds int) { clock.Set(start.Add(time.Duration(seconds) * time.Second)) }
+	d.Debug("before the error")
+	d.Error("first error")
+	if !rule.shouldLog(DebugLevel) || rule.shouldLog(TraceLevel) {
+		t.Error("active capture does not accept exactly Debug and above")
+	}
+	at(10)
+	d.Debug("detail 1")
+	at(20)
+	d.Error("second error")
+	at(45) // After the first capture would have ended.
+	d.Debug("detail 2")
+	at(51)
+	if rule.shouldLog(DebugLevel) {
+		t.Error("expired capture still accepts Debug")
+	}
+	d.Debug("after the capture")
+	d.Info("back to normal")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"10:00:00 | ERROR | [app] : first error",
+		"10:00:00 | INFO | [app] : burst capture enabled: logging from DEBUG for 30s burst_capture=true",
+		"10:00:10 | DEBUG | [app] : detail 1",
+		"10:00:20 | ERROR | [app] : second error",
+		"10:00:45 | DEBUG | [app] : detail 2",
+		"10:00:50 | INFO | [app] : burst capture disabled burst_capture=true",
+		"10:00:51 | INFO | [app] : back to normal",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("log =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestErrorBurstCaptureReactivates starts a new capture, noted again, with an error after the last one expired.
+func TestErrorBurstCaptureReactivates(t *testing.T) {
+	out := &syncBuffer{}
+	clock := newFakeClock(time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC))
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.NewLogRule("app", WithFileWriter(out), WithErrorBurstCapture(time.Minute, TraceLevel))
+	defer d.Close(context.Background())
+
+	d.Error("first")
+	clock.Set(clock.Now().Add(2 * time.Minute))
+	d.Trace("dropped")
+	d.Error("second")
+	d.Trace("captured")
+
+	log := out.String()
+	if n := strings.Count(log, "burst capture enabled"); n != 2 {
+		t.Errorf("capture enabled %d times, want 2:\n%s", n, log)
+	}
+	if strings.Count(log, "burst capture disabled") != 1 || strings.Contains(log, "dropped") || !strings.Contains(log, "captured") {
+		t.Errorf("log =\n%s", log)
+	}
+}
+
+// TestErrorBurstCaptureDisabled leaves the levels of a rule alone without a positive duration.
+func TestErrorBurstCaptureDisabled(t *testing.T) {
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(out), WithErrorBurstCapture(time.Minute, DebugLevel), WithErrorBurstCapture(0, DebugLevel))
+	defer d.Close(context.Background())
+
+	d.Error("failure")
+	d.Debug("detail")
+	if log := out.String(); strings.Contains(log, "detail") || strings.Contains(log, "burst capture") {
+		t.Errorf("log =\n%s", log)
+	}
+}
@@ -0,0 +1,256 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// Default time Close waits for the rules when shutting down on a cancelled context or a Fatal entry
+	MKLOG_CloseTimeoutDefault = 5 * time.Second
+	// Time a rule that drained its buffer in time gets to close its file and sinks when the context of Close
+	// expired while waiting for other rules
+	MKLOG_CloseGraceDefault = 100 * time.Millisecond
+)
+
+// CloseReport describes how the rules were shut down by Close.
+type CloseReport struct {
+	Rules []RuleCloseReport `json:"rules"` // Reports of the rules, ordered by module and index
+}
+
+// RuleCloseReport describes the shutdown of one rule.
+type RuleCloseReport struct {
+	Module    string `json:"module"`    // Module of the rule
+	Index     int    `json:"index"`     // Index of the rule within the module
+	Undrained int    `json:"undrained"` // Messages left in the async buffer when the context expired
+	File      string `json:"file"`      // Log file of the rule
+	SyncError error  `json:"-"`         // Error syncing or closing the log file
+	Unwritten int    `json:"unwritten"` // Failed log file writes still queued after the last replay
+	TimedOut  bool   `json:"timed_out"` // Whether the context expired before the rule was shut down completely
+}
+
+// Undrained returns the number of messages left in the async buffers of all rules.
+func (r CloseReport) Undrained() int {
+	n := 0
+	for _, rule := range r.Rules {
+		n += rule.Undrained
+	}
+	return n
+}
+
+// TimedOut returns the reports of the rules that were not shut down completely.
+func (r CloseReport) TimedOut() []RuleCloseReport {
+	var rules []RuleCloseReport
+	for _, rule := range r.Rules {
+		if rule.TimedOut {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Close stops the maintenance scheduler and shuts down all rules in parallel: their async buffers are drained,
+// their log files synced and closed and their sinks closed. Hooks implementing io.Closer are closed afterwards,
+// so notifiers deliver their queued entries; hooks of rules removed with RemoveRule are left open. The
+// console gets back the mode and the output code page it had before the first console output. Logging
+// afterwards is a no-op. The hooks registered
+// with OnClose run in their phases: PreFlush before the rules stop, PostFlush once every rule wrote its async
+// buffer and PostFileClose after the log files and sinks were closed.
+//
+// When ctx expires, Close stops waiting for wedged rules and reports how many messages were left
+// undrained; the blocked steps keep releasing their resources in the background, so the process can exit.
+// Rules that drained their buffers in time still get MKLOG_CloseGraceDefault to close their files and sinks.
+// Calling Close again returns an empty report.
+//
+// Close must be called once the Debugger is no longer used: it also removes the Debugger from the process-wide
+// signal handling, which otherwise keeps the Debugger and its rules alive, see HandleSignals.
+func (d *Debugger) Close(ctx context.Context) (CloseReport, error) {
+	first := false
+	d.closeOnce.Do(func() {
+		d.flushOnceSummary()
+		close(d.closedChannel())
+		processSignals.remove(d)
+		first = true
+	})
+	hooks := d.takeCloseHooks()
+	errs := runCloseHooks(ctx, hooks, PreFlush)
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		flushed sync.WaitGroup        // Rules that have not written their async buffers yet
+		proceed = make(chan struct{}) // Closed after the PostFlush hooks, letting the rules close their files
+		report  CloseReport
+	)
+	closeRule := func(module string, i int, v *LogRule) {
+		wg.Add(1)
+		flushed.Add(1)
+		go func() {
+			defer wg.Done()
+			var once sync.Once
+			done := func() { once.Do(flushed.Done) }
+			defer done() // Rules shut down before or timed out never reach the flushed callback.
+			ruleReport, closed, err := v.shutdownWith(ctx, func() {
+				done()
+				select {
+				case <-proceed:
+				case <-ctx.Done():
+				}
+			})
+			if !closed {
+				return
+			}
+			ruleReport.Module, ruleReport.Index = module, i
+
+			mu.Lock()
+			report.Rules = append(report.Rules, ruleReport)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("[mklog] failed to close %s: %w", module, err))
+			}
+			mu.Unlock()
+		}()
+	}
+	for module, rules := range d.rules() {
+		for i, v := range rules {
+			closeRule(module, i, v)
+		}
+	}
+	for module, v := range d.fallbackRules().snapshot(true) {
+		closeRule(module, 0, v)
+	}
+	runWithContext(ctx, flushed.Wait)
+	postFlush := runCloseHooks(ctx, hooks, PostFlush)
+	close(proceed)
+	wg.Wait()
+	errs = append(errs, postFlush...)
+	if first {
+		errs = append(errs, d.closeNotifyHooks(ctx)...)
+		restoreConsole()
+	}
+	errs = append(errs, runCloseHooks(ctx, hooks, PostFileClose)...)
+
+	sort.Slice(report.Rules, func(i, j int) bool {
+		if report.Rules[i].Module != report.Rules[j].Module {
+			return report.Rules[i].Module < report.Rules[j].Module
+		}
+		return report.Rules[i].Index < report.Rules[j].Index
+	})
+	if len(report.TimedOut()) > 0 {
+		errs = append(errs, fmt.Errorf("[mklog] close did not complete: %w", ctx.Err()))
+	}
+	return report, errors.Join(errs...)
+}
+
+// shutdown disables the rule, waits for the messages in progress, drains the async buffer, syncs and closes
+// the log file and closes the sinks, giving up waiting when ctx expires. Logging to the rule afterwards is a no-op.
+// It reports false when the rule had already been shut down.
+func (lr *LogRule) shutdown(ctx context.Context) (report RuleCloseReport, closed bool, err error) {
+	return lr.shutdownWith(ctx, nil)
+}
+
+// shutdownWith shuts the rule down like shutdown, calling flushed, when not nil, after the async buffer was
+// written and before the log file and sinks are closed. It is not called when the rule was shut down already
+// or ctx expired first.
+func (lr *LogRule) shutdownWith(ctx context.Context, flushed func()) (report RuleCloseReport, closed bool, err error) {
+	if lr.lifecycle == nil {
+		if l, ok := lr.detached.Load().(*ruleLifecycle); ok {
+			l.closeOnce.Do(func() { close(l.released) })
+		}
+		if flushed != nil {
+			flushed()
+		}
+		report, err = lr.release(ctx, true)
+		return report, true, err
+	}
+
+	lr.lifecycle.closeOnce.Do(func() {
+		closed = true
+		close(lr.lifecycle.released)
+
+		// Producers blocked on a full buffer keep the rule from being disabled.
+		if !runWithContext(ctx, lr.lifecycle.disable) {
+			report = RuleCloseReport{File: lr.FileLog.CurrentFileName, TimedOut: true}
+			if lr.AsyncLog.Enable {
+				report.Undrained = len(lr.logChannel)
+			}
+			return
+		}
+
+		drained := true
+		if lr.AsyncLog.Enable {
+			lr.closeAsync()
+			if lr.asyncDone != nil {
+				select {
+				case <-lr.asyncDone: // Wait until the buffered messages are written.
+				case <-ctx.Done():
+					drained = false
+				}
+			}
+		}
+		if drained && flushed != nil {
+			flushed()
+		}
+		releaseCtx := ctx
+		if drained && ctx.Err() != nil {
+			// The rule was ready in time; other rules kept it waiting for the PostFlush hooks.
+			var cancel context.CancelFunc
+			releaseCtx, cancel = context.WithTimeout(context.Background(), MKLOG_CloseGraceDefault)
+			defer cancel()
+		}
+		report, err = lr.release(releaseCtx, drained)
+		if !drained {
+			report.Undrained = len(lr.logChannel)
+			report.TimedOut = true
+		}
+	})
+	return report, closed, err
+}
+
+// release syncs and closes the log file and closes the sinks of the rule.
+// The file is left to the async consumer when the buffer was not drained.
+func (lr *LogRule) release(ctx context.Context, drained bool) (RuleCloseReport, error) {
+	report := RuleCloseReport{File: lr.FileLog.CurrentFileName}
+
+	if drained {
+		if q := lr.failed; q != nil {
+			if !runWithContext(ctx, lr.replayFailed) {
+				report.TimedOut = true
+			}
+			report.Unwritten, _ = q.stats()
+		}
+		var syncErr error
+		if runWithContext(ctx, func() { syncErr = lr.CloseLogFile() }) {
+			report.SyncError = syncErr
+		} else {
+			report.TimedOut = true
+		}
+	}
+
+	var err error
+	if !runWithContext(ctx, func() { err = lr.CloseSinks() }) {
+		report.TimedOut = true
+		return report, report.SyncError
+	}
+	return report, errors.Join(report.SyncError, err)
+}
+
+// runWithContext runs fn and reports whether it finished before ctx expired.
+// An unfinished fn keeps running in the background.
+func runWithContext(ctx context.Context, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
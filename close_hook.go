@@ -0,0 +1,121 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	// Longest time Close waits for a single hook registered with OnClose
+	MKLOG_CloseHookTimeoutDefault = 5 * time.Second
+)
+
+// ClosePhase is the step of Close a hook registered with OnClose runs after.
+type ClosePhase int
+
+const (
+	PreFlush      ClosePhase = iota // Before the rules stop; entries logged by the hook are still written.
+	PostFlush                       // After the rules stopped and wrote their async buffers; log files and sinks are still open.
+	PostFileClose                   // After the log files and sinks were closed, e.g. to upload the last log file.
+)
+
+// String returns the name of the phase.
+func (p ClosePhase) String() string {
+	switch p {
+	case PreFlush:
+		return "PreFlush"
+	case PostFlush:
+		return "PostFlush"
+	case PostFileClose:
+		return "PostFileClose"
+	}
+	return fmt.Sprintf("ClosePhase(%d)", int(p))
+}
+
+// closeHook is a function run by Close in its phase.
+type closeHook struct {
+	name  string
+	phase ClosePhase
+	fn    func(ctx context.Context) error
+}
+
+// OnClose registers a function Close runs in the given phase, e.g. to upload the last log file in PostFileClose.
+// Hooks of a phase run one after the other in the order of registration, after the teardown of the features of
+// the Debugger in that phase: the maintenance scheduler stops in PreFlush and coalesced errors are delivered in
+// PostFileClose. Each hook gets a context expiring after MKLOG_CloseHookTimeoutDefault or with the context of
+// Close; Close moves on to the next hook when it expires and leaves the hook running in the background. Errors,
+// timeouts and panics of the hooks are returned by Close together with the errors of the rules. The hooks run on
+// the first call of Close only.
+func (d *Debugger) OnClose(fn func(ctx context.Context) error, phase ClosePhase) *Debugger {
+	if phase < PreFlush || phase > PostFileClose {
+		d.handleError(fmt.Errorf("[mklog] unknown close phase %v", phase))
+		return d
+	}
+	d.closeHooksMu.Lock()
+	d.closeHooks = append(d.closeHooks, closeHook{
+		name:  fmt.Sprintf("close hook %d", len(d.closeHooks)+1),
+		phase: phase,
+		fn:    fn,
+	})
+	d.closeHooksMu.Unlock()
+	return d
+}
+
+// takeCloseHooks returns the teardown of the features of the Debugger followed by the hooks registered with
+// OnClose, which are removed so a later Close does not run them again.
+func (d *Debugger) takeCloseHooks() []closeHook {
+	hooks := []closeHook{
+		{name: "maintenance scheduler", phase: PreFlush, fn: func(ctx context.Context) error {
+			d.stopMaintenance()
+			return nil
+		}},
+		{name: "error coalescing", phase: PostFileClose, fn: func(ctx context.Context) error {
+			d.flushCoalesced()
+			return nil
+		}},
+	}
+	d.closeHooksMu.Lock()
+	hooks = append(hooks, d.closeHooks...)
+	d.closeHooks = nil
+	d.closeHooksMu.Unlock()
+	return hooks
+}
+
+// runCloseHooks runs the hooks of the phase in order, returning their errors.
+func runCloseHooks(ctx context.Context, hooks []closeHook, phase ClosePhase) []error {
+	var errs []error
+	for _, h := range hooks {
+		if h.phase != phase {
+			continue
+		}
+		if err := h.run(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// run runs the hook with its timeout, recovering from its panics.
+func (h closeHook) run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, MKLOG_CloseHookTimeoutDefault)
+	defer cancel()
+
+	var err error
+	finished := runWithContext(ctx, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+			}
+		}()
+		err = h.fn(ctx)
+	})
+	if !finished {
+		return fmt.Errorf("[mklog] %s of phase %v did not finish: %w", h.name, h.phase, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("[mklog] %s of phase %v failed: %w", h.name, h.phase, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestOnCloseOrder runs the hooks phase by phase in the order of registration: PreFlush while the rules still
+// log, PostFlush after the async buffer was written and before the file writer is closed, PostFileClose after.
+func TestOnCloseOrder(t *testing.T) {
+	w := &syncCloseWriter{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(w), WithCloseFileWriter(true), WithAsyncLog(true, 16))
+
+	var order []string
+	record := func(name string, check func()) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, name)
+			if check != nil {
+				check()
+			}
+			return nil
+		}
+	}
+	calls := func() string {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return strings.Join(w.calls, ",")
+	}
+
+	d.OnClose(record("post-close 1", func() {
+		if got := calls(); !strings.HasSuffix(got, "sync,close") {
+			t.Errorf("PostFileClose: writer calls = %s, want it closed", got)
+		}
+	}), PostFileClose)
+	d.OnClose(record("post-flush 1", func() {
+		if got := calls(); got != "write,write" {
+			t.Errorf("PostFlush: writer calls = %s, want both entries written and the writer open", got)
+		}
+	}), PostFlush)
+	d.OnClose(record("pre-flush 1", func() { d.Info("from the PreFlush hook") }), PreFlush)
+	d.OnClose(record("post-flush 2", nil), PostFlush)
+	d.OnClose(record("pre-flush 2", nil), PreFlush)
+	d.OnClose(record("post-close 2", nil), PostFileClose)
+
+	d.Info("queued")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "pre-flush 1,pre-flush 2,post-flush 1,post-flush 2,post-close 1,post-close 2"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 6 {
+		t.Errorf("hooks ran again on the second Close: %v", order)
+	}
+}
+
+// TestOnCloseTimeout moves on to the next hook when a hook outlives its timeout and returns the timeout from
+// Close.
+func TestOnCloseTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { MKLOG_CloseHookTimeoutDefault = timeout }(MKLOG_CloseHookTimeoutDefault)
+	MKLOG_CloseHookTimeoutDefault = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	defer close(release)
+	var mu sync.Mutex
+	var ran []string
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	d.OnClose(func(context.Context) error {
+		<-release // Ignores its context.
+		return nil
+	}, PreFlush)
+	d.OnClose(func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("hook context without a deadline")
+		}
+		ran = append(ran, "next")
+		return nil
+	}, PreFlush)
+
+	start := time.Now()
+	_, err := d.Close(context.Background())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close took %v despite the hook timeout", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "close hook 1 of phase PreFlush did not finish") {
+		t.Errorf("Close error = %v, want the timeout of the first hook", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 1 {
+		t.Error("hook after the timed out hook did not run")
+	}
+}
+
+// TestOnCloseErrors joins the errors and panics of the hooks into the error of Close and reports unknown
+// phases to the error handler.
+func TestOnCloseErrors(t *testing.T) {
+	errUpload := errors.New("upload failed")
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(rec.handle)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	d.OnClose(func(context.Context) error { return errUpload }, PostFileClose)
+	d.OnClose(func(context.Context) error { panic("hook exploded") }, PostFlush)
+	d.OnClose(func(context.Context) error { return nil }, ClosePhase(7))
+
+	_, err := d.Close(context.Background())
+	if !errors.Is(err, errUpload) || !strings.Contains(err.Error(), "close hook 1 of phase PostFileClose failed: upload failed") {
+		t.Errorf("Close error = %v, want the error of the upload hook", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "close hook 2 of phase PostFlush failed: panic: hook exploded") {
+		t.Errorf("Close error = %v, want the panic of the second hook", err)
+	}
+	if errs := rec.get(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "unknown close phase ClosePhase(7)") {
+		t.Errorf("reported errors = %v, want the unknown phase", errs)
+	}
+}
@@ -0,0 +1,159 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncCloseWriter records the calls of its Write, Sync and Close methods and fails them as configured.
+type syncCloseWriter struct {
+	mu       sync.Mutex
+	calls    []string
+	syncErr  error
+	closeErr error
+}
+
+func (w *syncCloseWriter) record(call string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.calls = append(w.calls, call)
+}
+
+func (w *syncCloseWriter) Write(p []byte) (int, error) { w.record("write"); return len(p), nil }
+func (w *syncCloseWriter) Sync() error                 { w.record("sync"); return w.syncErr }
+func (w *syncCloseWriter) Close() error                { w.record("close"); return w.closeErr }
+
+// TestCloseSyncsBeforeClosing syncs the file writer of a rule after its last write and before closing it.
+func TestCloseSyncsBeforeClosing(t *testing.T) {
+	w := &syncCloseWriter{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(w), WithCloseFileWriter(true))
+	d.Info("last words")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(w.calls, ","); got != "write,sync,close" {
+		t.Errorf("calls = %s, want write,sync,close", got)
+	}
+}
+
+// TestCloseReturnsFileErrors returns the errors of syncing and closing the file writer, naming the rule, from
+// CloseLogFile and Debugger.Close, and keeps syncing a writer it does not close.
+func TestCloseReturnsFileErrors(t *testing.T) {
+	errSync, errClose := errors.New("disk gone"), errors.New("bad descriptor")
+
+	w := &syncCloseWriter{syncErr: errSync, closeErr: errClose}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(w), WithCloseFileWriter(true))
+	rule := d.rules()["app"][0]
+	err := rule.CloseLogFile()
+	if !errors.Is(err, errSync) || !errors.Is(err, errClose) ||
+		!strings.Contains(err.Error(), "syncing file writer of app: disk gone") ||
+		!strings.Contains(err.Error(), "closing file writer of app: bad descriptor") {
+		t.Errorf("CloseLogFile error = %v, want both errors naming the rule", err)
+	}
+	if err := rule.CloseLogFile(); err != nil {
+		t.Errorf("second CloseLogFile error = %v, want nil", err)
+	}
+
+	d.Close(context.Background())
+
+	w = &syncCloseWriter{syncErr: errSync}
+	d = (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("db", WithFileWriter(w))
+	report, err := d.Close(context.Background())
+	if !errors.Is(err, errSync) {
+		t.Errorf("Close error = %v, want the sync error", err)
+	}
+	if len(report.Rules) != 1 || !errors.Is(report.Rules[0].SyncError, errSync) {
+		t.Errorf("report = %+v, want the sync error of the rule", report.Rules)
+	}
+	if got := strings.Join(w.calls, ","); got != "sync" {
+		t.Errorf("calls = %s, want the writer synced but not closed", got)
+	}
+}
+
+// TestRotatingWriterCloseErrors names the log file in the error of a writer whose file can no longer be synced.
+func TestRotatingWriterCloseErrors(t *testing.T) {
+	w := newTestWriter(t, RotatingWriterOptions{})
+	writeLines(t, w, "entry")
+	if err := w.File().Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := w.Close()
+	if !errors.Is(err, os.ErrClosed) || !strings.Contains(err.Error(), "syncing log file "+w.FileName()) {
+		t.Errorf("Close error = %v, want the sync error naming %s", err, w.FileName())
+	}
+}
+
+// wedgedSink accepts every entry and blocks in Close until it is released.
+type wedgedSink struct {
+	release chan struct{}
+}
+
+func (s *wedgedSink) Write(entry Entry, formatted string) error { return nil }
+
+func (s *wedgedSink) Close() error {
+	<-s.release
+	return nil
+}
+
+// TestCloseWedgedSinks gives up on wedged rules once the context expires and reports for each rule the step it
+// hung in: the rule whose file writer blocks leaves its async buffer undrained, the rule whose sink blocks Close
+// drained its buffer first, and the healthy rule is shut down completely.
+func TestCloseWedgedSinks(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	w := &blockingWriter{started: make(chan struct{}, 4), release: release}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("api", WithFileWriter(&syncBuffer{}))
+	d.NewLogRule("db", WithFileWriter(w), WithAsyncLog(true, 16))
+	d.NewLogRule("mail", WithFileWriter(&syncBuffer{}), WithAsyncLog(true, 16), WithSink(&wedgedSink{release: release}))
+	var preFlush bool
+	d.OnClose(func(context.Context) error {
+		preFlush = true
+		return nil
+	}, PreFlush)
+
+	d.Info("first")
+	<-w.started // The consumer of db is stuck writing the first entry.
+	d.Info("second")
+	d.Info("third")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	report, err := d.Close(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close took %v despite the expired context", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "close did not complete") {
+		t.Errorf("Close error = %v, want the expired context", err)
+	}
+	if !preFlush {
+		t.Error("the PreFlush hook did not run before the rules were closed")
+	}
+
+	if len(report.Rules) != 3 {
+		t.Fatalf("report = %+v, want the three rules", report.Rules)
+	}
+	api, db, mail := report.Rules[0], report.Rules[1], report.Rules[2]
+	if api.Module != "api" || api.TimedOut || api.Undrained != 0 || api.SyncError != nil {
+		t.Errorf("api = %+v, want it shut down completely", api)
+	}
+	if db.Module != "db" || !db.TimedOut || db.Undrained != 2 {
+		t.Errorf("db = %+v, want it timed out with the two entries behind the stuck one undrained", db)
+	}
+	if mail.Module != "mail" || !mail.TimedOut || mail.Undrained != 0 {
+		t.Errorf("mail = %+v, want it timed out closing its sink after draining the buffer", mail)
+	}
+	if timedOut := report.TimedOut(); len(timedOut) != 2 || report.Undrained() != 2 {
+		t.Errorf("TimedOut = %+v, Undrained = %d, want db and mail with 2 undrained entries", timedOut, report.Undrained())
+	}
+}
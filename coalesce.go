@@ -0,0 +1,164 @@
+package mklog
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// Default window in which identical consecutive internal errors are coalesced
+	MKLOG_ErrorCoalesceWindowDefault = time.Second
+)
+
+// CoalescedError summarizes the identical internal errors that were not passed to the error handler, e.g. the
+// same failed write repeated thousands of times per second while the disk is full. It unwraps to the last of
+// them, so errors.As still finds a Notice.
+type CoalescedError struct {
+	Err     error         // Last of the suppressed errors
+	Repeats int           // Number of suppressed errors
+	Window  time.Duration // Window the errors were coalesced in
+}
+
+// Error returns the text of the error with the number of repeats.
+func (e *CoalescedError) Error() string {
+	return fmt.Sprintf("%v (repeated %d more times within %s)", e.Err, e.Repeats, e.Window)
+}
+
+// Unwrap returns the last of the suppressed errors.
+func (e *CoalescedError) Unwrap() error {
+	return e.Err
+}
+
+// errorKey identifies identical errors: the same text reported for the same rule.
+type errorKey struct {
+	rule *LogRule
+	text string
+}
+
+// errorCoalescer holds back identical consecutive errors within a window, passing the first one on right away
+// and a CoalescedError for the others when the window closes or a different error arrives.
+type errorCoalescer struct {
+	mu         sync.Mutex
+	window     time.Duration // Coalescing window, zero disables coalescing
+	last       errorKey      // Key of the last passed error
+	lastErr    error         // Last suppressed error
+	since      time.Time     // Start of the window of the last passed error
+	active     bool          // Whether the window of the last passed error is open
+	repeats    int           // Errors suppressed in the open window
+	timer      *time.Timer   // Closes the open window once errors were suppressed
+	suppressed uint64        // Total suppressed errors, accessed atomically
+}
+
+// SetErrorCoalescing sets the window in which identical consecutive internal errors of a rule are passed to the
+// error handler once: the first error right away, the repeats as one CoalescedError when the window closes or
+// a different error is reported. OutputErrors are compared by the errors of their outputs, so the failed
+// writes of different entries are identical. A summary closing a window is passed from a background goroutine.
+// Coalescing is on by default with MKLOG_ErrorCoalesceWindowDefault; a zero window passes every error on.
+func (d *Debugger) SetErrorCoalescing(window time.Duration) *Debugger {
+	c := d.errorCoalescer()
+	c.mu.Lock()
+	c.window = window
+	c.mu.Unlock()
+	return d
+}
+
+// SuppressedErrors returns the number of internal errors held back by coalescing so far.
+func (d *Debugger) SuppressedErrors() uint64 {
+	return atomic.LoadUint64(&d.errorCoalescer().suppressed)
+}
+
+// errorCoalescer returns the coalescer of the internal errors, creating it on first use.
+func (d *Debugger) errorCoalescer() *errorCoalescer {
+	d.coalesceOnce.Do(func() {
+		d.coalesce = &errorCoalescer{window: MKLOG_ErrorCoalesceWindowDefault}
+	})
+	return d.coalesce
+}
+
+// coalesceError passes the error about the rule to the error handler unless it repeats the error passed last
+// within the window. The rule is nil for errors not about a rule.
+func (d *Debugger) coalesceError(lr *LogRule, err error) {
+	c := d.errorCoalescer()
+	key := errorKey{rule: lr, text: coalesceText(err)}
+	now := d.now()
+
+	c.mu.Lock()
+	if c.window <= 0 {
+		c.mu.Unlock()
+		d.deliverError(err)
+		return
+	}
+	if c.active && key == c.last && now.Sub(c.since) < c.window {
+		c.repeats++
+		c.lastErr = err
+		atomic.AddUint64(&c.suppressed, 1)
+		if c.timer == nil {
+			since := c.since
+			c.timer = time.AfterFunc(c.window-now.Sub(since), func() { d.closeWindow(since) })
+		}
+		c.mu.Unlock()
+		return
+	}
+	summary := c.takeSummary()
+	c.last, c.since, c.active = key, now, true
+	c.mu.Unlock()
+
+	if summary != nil {
+		d.deliverError(summary)
+	}
+	d.deliverError(err)
+}
+
+// coalesceText returns the text identical errors share, leaving out the entry of an OutputError.
+func coalesceText(err error) string {
+	if e, ok := err.(*OutputError); ok && e.Err != nil {
+		return e.Err.Error()
+	}
+	return err.Error()
+}
+
+// closeWindow passes the summary of the window started at since to the error handler, unless another window
+// was started in the meantime.
+func (d *Debugger) closeWindow(since time.Time) {
+	c := d.errorCoalescer()
+	c.mu.Lock()
+	var summary error
+	if c.active && c.since.Equal(since) {
+		summary = c.takeSummary()
+	}
+	c.mu.Unlock()
+
+	if summary != nil {
+		d.deliverError(summary)
+	}
+}
+
+// flushCoalesced closes the open window, passing its summary to the error handler, e.g. on Close.
+func (d *Debugger) flushCoalesced() {
+	c := d.errorCoalescer()
+	c.mu.Lock()
+	summary := c.takeSummary()
+	c.active = false
+	c.mu.Unlock()
+
+	if summary != nil {
+		d.deliverError(summary)
+	}
+}
+
+// takeSummary returns the summary of the errors suppressed in the open window, nil when there are none, and
+// closes the window. The coalescer must be locked.
+func (c *errorCoalescer) takeSummary() error {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if c.repeats == 0 {
+		return nil
+	}
+	summary := &CoalescedError{Err: c.lastErr, Repeats: c.repeats, Window: c.window}
+	c.repeats, c.lastErr, c.active = 0, nil, false
+	return summary
+}
@@ -0,0 +1,153 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errorRecorder records the errors passed to the error handler, also from background goroutines.
+type errorRecorder struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (r *errorRecorder) handle(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+// get returns the errors recorded so far.
+func (r *errorRecorder) get() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]error(nil), r.errs...)
+}
+
+// newFailingDebugger returns a Debugger with rules a and b whose writes fail, reporting to the recorder.
+func newFailingDebugger(rec *errorRecorder, clock *fakeClock, window time.Duration) *Debugger {
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now).SetErrorCoalescing(window)
+	d.SetErrorHandler(rec.handle)
+	d.NewLogRule("a", WithFileWriter(failingWriter{}))
+	d.NewLogRule("b", WithFileWriter(failingWriter{}))
+	return d
+}
+
+// TestErrorCoalescingFlood passes the first of a flood of identical failed writes to the error handler and the
+// others as one summary on Close, counting them as suppressed.
+func TestErrorCoalescingFlood(t *testing.T) {
+	rec := &errorRecorder{}
+	d := newFailingDebugger(rec, newFakeClock(time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)), time.Hour)
+
+	for i := 0; i < 1000; i++ {
+		d.Module("a").Info("entry %d", i)
+	}
+	if n := len(rec.get()); n != 1 {
+		t.Fatalf("error handler called %d times during the flood, want 1", n)
+	}
+	if n := d.Stats().SuppressedErrors; n != 999 {
+		t.Errorf("suppressed errors = %d, want 999", n)
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := rec.get()
+	if len(errs) != 2 {
+		t.Fatalf("error handler called %d times, want the first error and the summary", len(errs))
+	}
+	var summary *CoalescedError
+	if !errors.As(errs[1], &summary) || summary.Repeats != 999 || summary.Window != time.Hour {
+		t.Fatalf("summary = %v, want 999 repeats within 1h", errs[1])
+	}
+	var out *OutputError
+	if !errors.As(summary, &out) || out.Module != "a" || !errors.Is(summary, io.ErrClosedPipe) {
+		t.Errorf("summary does not unwrap to the failed write: %v", summary)
+	}
+}
+
+// TestErrorCoalescingDifferentError closes the window when a different error is reported, and when the window
+// has passed on the fake clock.
+func TestErrorCoalescingDifferentError(t *testing.T) {
+	rec := &errorRecorder{}
+	clock := newFakeClock(time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC))
+	d := newFailingDebugger(rec, clock, time.Minute)
+	defer d.Close(context.Background())
+
+	for i := 0; i < 3; i++ {
+		d.Module("a").Info("entry")
+	}
+	d.Module("b").Info("entry") // Same text, other rule.
+	d.Module("b").Info("entry")
+	clock.Set(clock.Now().Add(2 * time.Minute))
+	d.Module("b").Info("entry")
+
+	var kinds []string
+	for _, err := range rec.get() {
+		var out *OutputError
+		errors.As(err, &out)
+		var summary *CoalescedError
+		if errors.As(err, &summary) {
+			kinds = append(kinds, fmt.Sprintf("%s repeated %d", out.Module, summary.Repeats))
+		} else {
+			kinds = append(kinds, out.Module)
+		}
+	}
+	want := []string{"a", "a repeated 2", "b", "b repeated 1", "b"}
+	if len(kinds) != len(want) {
+		t.Fatalf("errors = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("errors = %v, want %v", kinds, want)
+			break
+		}
+	}
+}
+
+// TestErrorCoalescingWindowTimer passes the summary from the background once the window closes without
+// another error.
+func TestErrorCoalescingWindowTimer(t *testing.T) {
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true).SetErrorCoalescing(100 * time.Millisecond)
+	d.SetErrorHandler(rec.handle)
+	d.NewLogRule("a", WithFileWriter(failingWriter{}))
+	defer d.Close(context.Background())
+
+	for i := 0; i < 5; i++ {
+		d.Info("entry")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(rec.get()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	errs := rec.get()
+	var summary *CoalescedError
+	if len(errs) != 2 || !errors.As(errs[1], &summary) || summary.Repeats != 4 {
+		t.Errorf("errors = %v, want the first error and a summary of 4 repeats", errs)
+	}
+}
+
+// TestErrorCoalescingDisabled passes every error to the error handler with a zero window.
+func TestErrorCoalescingDisabled(t *testing.T) {
+	rec := &errorRecorder{}
+	d := newFailingDebugger(rec, newFakeClock(time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)), 0)
+
+	for i := 0; i < 10; i++ {
+		d.Module("a").Info("entry")
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.get()); n != 10 {
+		t.Errorf("error handler called %d times, want 10", n)
+	}
+	if n := d.SuppressedErrors(); n != 0 {
+		t.Errorf("suppressed errors = %d, want 0", n)
+	}
+}
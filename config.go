@@ -1,10 +1,12 @@
 package mklog
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -28,52 +30,185 @@ func (y *YAMLConfigParser) ParseConfig(data []byte, config *Config) error {
 }
 
 type LogFormatterConfig struct {
-	Type       string `yaml:"type" json:"type"`
-	DateFormat string `yaml:"date_format" json:"date_format"`
+	Type       string                 `yaml:"type" json:"type"`
+	DateFormat string                 `yaml:"date_format" json:"date_format"`
+	Options    map[string]interface{} `yaml:",inline" json:"-"` // Formatter specific keys of the block, e.g. pretty for json.
+}
+
+// UnmarshalJSON decodes the formatter block, keeping the keys other than type and date_format as Options.
+func (c *LogFormatterConfig) UnmarshalJSON(data []byte) error {
+	type plain LogFormatterConfig
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	var options map[string]interface{}
+	if err := json.Unmarshal(data, &options); err != nil {
+		return err
+	}
+	delete(options, "type")
+	delete(options, "date_format")
+	if len(options) > 0 {
+		c.Options = options
+	}
+	return nil
 }
 
 type LogConfigManager struct {
-	parsers               map[string]ConfigParser
-	userDefinedFormatters map[string]UserDefinedFormatterFunc
+	parsers             map[string]ConfigParser
+	formatters          map[string]formatterEntry
+	strictFormatterOpts bool // Reject unknown formatter options instead of warning
+	strictKeys          bool // Reject unknown configuration keys instead of warning, see StrictKeys
+	sinkFactories       map[string]SinkFactory
+	noEnvOverride       bool         // Ignore the MKLOG_LEVEL environment variables
+	quiet               bool         // Suppress informational notices, see SetQuiet
+	errorHandler        ErrorHandler // Error handler of the loaded Debuggers
+	defaults            *Defaults    // Defaults of the loaded rules, see SetDefaults
 }
 
 type AsyncLogConf struct {
-	Enable     bool `yaml:"enable" json:"enable"`
-	BufferSize int  `yaml:"buffer_size" json:"buffer_size"`
+	Enable            bool     `yaml:"enable" json:"enable"`
+	BufferSize        int      `yaml:"buffer_size" json:"buffer_size"`
+	DrainWhenDisabled bool     `yaml:"drain_when_disabled" json:"drain_when_disabled"` // Keep writing queued messages after the rule is disabled.
+	FlushInterval     Duration `yaml:"flush_interval" json:"flush_interval"`           // Interval of flushing buffered file writers.
+	OverflowPolicy    string   `yaml:"overflow_policy" json:"overflow_policy"`         // "block", "drop_newest" or "drop_oldest".
+	SendTimeout       Duration `yaml:"send_timeout" json:"send_timeout"`               // Longest wait for room in the buffer with the block policy.
+	BatchSize         int      `yaml:"batch_size" json:"batch_size"`                   // Number of queued messages written at once.
+}
+
+// Duration is a duration of the configuration. Both formats accept duration strings like "250ms" or "1h" and
+// numbers of nanoseconds.
+type Duration time.Duration
+
+// UnmarshalJSON parses the duration from a JSON configuration file.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return d.set(v)
+}
+
+// UnmarshalYAML parses the duration from a YAML configuration file.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	return d.set(v)
+}
+
+// set converts a decoded duration, a duration string or a number of nanoseconds.
+func (d *Duration) set(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		*d = 0
+	case int:
+		*d = Duration(v)
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration: %v", v)
+	}
+	return nil
+}
+
+// String formats the duration like time.Duration.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// validate checks the async settings of a rule.
+func (c AsyncLogConf) validate() error {
+	if !validOverflowPolicy(c.OverflowPolicy) {
+		return fmt.Errorf("unknown overflow_policy %q", c.OverflowPolicy)
+	}
+	if (c.OverflowPolicy == OverflowDropNewest || c.OverflowPolicy == OverflowDropOldest) && c.BufferSize <= 0 {
+		return fmt.Errorf("overflow_policy %q requires a buffer_size greater than 0", c.OverflowPolicy)
+	}
+	if c.SendTimeout < 0 {
+		return fmt.Errorf("send_timeout must not be negative")
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("batch_size must not be negative")
+	}
+	if c.FlushInterval < 0 {
+		return fmt.Errorf("flush_interval must not be negative")
+	}
+	return nil
 }
 
 type FolderFileConf struct {
-	Enable           bool          `yaml:"enable" json:"enable"`                         // Flag indicating whether to create folders based on time.
-	FileFolderPeriod time.Duration `yaml:"file_folder_period" json:"file_folder_period"` // Period for creating folder for log files.
-	TimeFolderFormat string        `yaml:"time_folder_format" json:"time_folder_format"` // Format for time folders.
+	Enable           bool     `yaml:"enable" json:"enable"`                         // Flag indicating whether to create folders based on time.
+	FileFolderPeriod Duration `yaml:"file_folder_period" json:"file_folder_period"` // Period for creating folder for log files.
+	TimeFolderFormat string   `yaml:"time_folder_format" json:"time_folder_format"` // Format for time folders.
 }
 
 type LogFileConf struct {
-	DailyLog          bool   `yaml:"daily_log_enable" json:"daily_log_enable"`
-	Enable            bool   `yaml:"enable" json:"enable"`                             // Flag indicating whether to log to a file.
-	IsLimitedFileSize bool   `yaml:"is_limited_file_size" json:"is_limited_file_size"` // Flag indicating whether to limit file size.
-	MaxFileSize       int64  `yaml:"max_file_size" json:"max_file_size"`               // Maximum size of the log file.
-	FilePath          string `yaml:"file_path" json:"file_path"`                       // Path to the directory where log files are stored.
-	FileName          string `yaml:"file_name" json:"file_name"`                       // Base name of the log file.
-	FileType          string `yaml:"file_type" json:"file_type"`                       // Type of the log file (e.g., ".log").
-	DateFileFormat    string `yaml:"date_file_format" json:"date_file_format"`
-	DetailedError     bool   `yaml:"detailed_error" json:"detailed_error"`
+	DailyLog            bool     `yaml:"daily_log_enable" json:"daily_log_enable"`
+	Enable              bool     `yaml:"enable" json:"enable"`                             // Flag indicating whether to log to a file.
+	IsLimitedFileSize   bool     `yaml:"is_limited_file_size" json:"is_limited_file_size"` // Flag indicating whether to limit file size.
+	MaxFileSize         int64    `yaml:"max_file_size" json:"max_file_size"`               // Maximum size of the log file.
+	FilePath            string   `yaml:"file_path" json:"file_path"`                       // Path to the directory where log files are stored.
+	FileName            string   `yaml:"file_name" json:"file_name"`                       // Base name of the log file.
+	FileType            string   `yaml:"file_type" json:"file_type"`                       // Type of the log file (e.g., ".log").
+	DateFileFormat      string   `yaml:"date_file_format" json:"date_file_format"`
+	DetailedError       bool     `yaml:"detailed_error" json:"detailed_error"`
+	MaxBackups          int      `yaml:"max_backups" json:"max_backups"`                     // Number of rotated files kept when the size limit is reached.
+	CopyTruncate        bool     `yaml:"copy_truncate" json:"copy_truncate"`                 // Flag indicating whether to rotate by copying and truncating the log file.
+	MaxFileLines        int64    `yaml:"max_file_lines" json:"max_file_lines"`               // Maximum number of lines of the log file before it is rotated.
+	Retention           Duration `yaml:"retention" json:"retention"`                         // Retention of rotated and dated log files.
+	MaxDateFiles        int      `yaml:"max_date_files" json:"max_date_files"`               // Number of dated log files kept besides the active one.
+	Compress            bool     `yaml:"streaming_compression" json:"streaming_compression"` // Flag indicating whether to write gzip-compressed log files.
+	RotationInterval    Duration `yaml:"rotation_interval" json:"rotation_interval"`         // Period after which a new, timestamped log file is started.
+	ArchiveAfter        Duration `yaml:"archive_after" json:"archive_after"`                 // Age after which rotated and dated log files are archived.
+	ArchiveGroupBy      string   `yaml:"archive_group_by" json:"archive_group_by"`           // Grouping of the archives, "month" or "week".
+	Sanitize            bool     `yaml:"sanitize" json:"sanitize"`                           // Flag indicating whether to escape control characters in the file output.
+	WriteBOM            bool     `yaml:"write_bom" json:"write_bom"`                         // Flag indicating whether to start log files with a UTF-8 byte order mark.
+	LineEnding          string   `yaml:"line_ending" json:"line_ending"`                     // Line ending of the entries, "lf" or "\n" (default), "crlf" or "\r\n".
+	OpenMode            string   `yaml:"open_mode" json:"open_mode"`                         // Opening of existing log files, "append" (default) or "truncate".
+	FileNamePolicy      string   `yaml:"file_name_policy" json:"file_name_policy"`           // Handling of characters invalid in file names, "reject" (default) or "sanitize".
+	FailedQueueSize     int      `yaml:"failed_queue_size" json:"failed_queue_size"`         // Number of failed writes kept for replay.
+	FailedRetryInterval Duration `yaml:"failed_retry_interval" json:"failed_retry_interval"` // Period after which the failed writes are replayed.
+}
+
+type SinkConf struct {
+	Type    string                 `yaml:"type" json:"type"`       // Name the sink factory was registered with.
+	Options map[string]interface{} `yaml:"options" json:"options"` // Options passed to the sink factory.
 }
 
 type LogRulesConf struct {
-	MinLevel         LogLevel           `yaml:"min_level" json:"min_level"`
-	MaxLevel         LogLevel           `yaml:"max_level" json:"max_level"`
-	CurrentLevel     LogLevel           `yaml:"current_level" json:"current_level"`
-	DateFormat       string             `yaml:"date_format" json:"date_format"`
-	LogFormatterType LogFormatterConfig `yaml:"log_formatter" json:"log_formatter"`
-	ModuleName       string             `yaml:"module_name" json:"module_name"`
-	Submodules       []string           `yaml:"submodules" json:"submodules"`
-	ConsoleEnable    bool               `yaml:"console_enable" json:"console_enable"`
-	IsDebugMod       bool               `yaml:"is_debug_mod" json:"is_debug_mod"`
-	DebugModeStatus  LogLevel           `yaml:"debug_mode_status" json:"debug_mode_status"`
-	LogFile          LogFileConf        `yaml:"file_log" json:"file_log"`
-	FolderFIle       FolderFileConf     `yaml:"folder_file" json:"folder_file"`
-	AsyncLog         AsyncLogConf       `yaml:"async_log" json:"async_log"`
+	MinLevel                  LogLevel           `yaml:"min_level" json:"min_level"`
+	MaxLevel                  LogLevel           `yaml:"max_level" json:"max_level"`
+	CurrentLevel              LogLevel           `yaml:"current_level" json:"current_level"`
+	DateFormat                string             `yaml:"date_format" json:"date_format"`
+	TimestampLocation         string             `yaml:"timestamp_location" json:"timestamp_location"` // IANA name of the time zone timestamps are rendered in, e.g. "Europe/Berlin".
+	LogFormatterType          LogFormatterConfig `yaml:"log_formatter" json:"log_formatter"`
+	ModuleName                string             `yaml:"module_name" json:"module_name"`
+	Submodules                []string           `yaml:"submodules" json:"submodules"`
+	ConsoleEnable             bool               `yaml:"console_enable" json:"console_enable"`
+	ConsoleOnce               *bool              `yaml:"console_once" json:"console_once"`                                 // Skip the console output of entries another rule printed already; true by default.
+	ConsolePrefixes           map[string]string  `yaml:"console_prefixes" json:"console_prefixes"`                         // Prefix of the console output per level name, e.g. {"error": "✖"}.
+	ConsolePrefixBeforeFormat bool               `yaml:"console_prefix_before_format" json:"console_prefix_before_format"` // Prefix the message before it is formatted.
+	ConsolePrefixAlways       bool               `yaml:"console_prefix_always" json:"console_prefix_always"`               // Prefix the console output even when stdout is not a terminal.
+	RawOutput                 bool               `yaml:"raw_output" json:"raw_output"`                                     // Keep control characters in the console and file output.
+	MaxFieldBytes             int                `yaml:"max_field_bytes" json:"max_field_bytes"`                           // Maximum length of string and []byte field values.
+	MessagePrefix             string             `yaml:"message_prefix" json:"message_prefix"`                             // Text prepended to every message, e.g. "APP1|".
+	MessageSuffix             string             `yaml:"message_suffix" json:"message_suffix"`                             // Text appended to every message.
+	Verbosity                 *LogLevel          `yaml:"verbosity" json:"verbosity"`                                       // Lowest level logged in addition to min_level; overrides is_debug_mod.
+	IsDebugMod                bool               `yaml:"is_debug_mod" json:"is_debug_mod"`
+	DebugModeStatus           LogLevel           `yaml:"debug_mode_status" json:"debug_mode_status"`
+	LogFile                   LogFileConf        `yaml:"file_log" json:"file_log"`
+	FolderFIle                FolderFileConf     `yaml:"folder_file" json:"folder_file"`
+	AsyncLog                  AsyncLogConf       `yaml:"async_log" json:"async_log"`
+	Sinks                     []SinkConf         `yaml:"sinks" json:"sinks"`
+	FatalExitCode             int                `yaml:"fatal_exit_code" json:"fatal_exit_code"`
+	Disabled                  bool               `yaml:"disabled" json:"disabled"` // Create the rule disabled, see LogRule.Disable.
 }
 
 type Config struct {
@@ -87,7 +222,8 @@ func NewLogConfigManager() *LogConfigManager {
 			".yaml": &YAMLConfigParser{},
 			".yml":  &YAMLConfigParser{},
 		},
-		userDefinedFormatters: make(map[string]UserDefinedFormatterFunc),
+		formatters:    make(map[string]formatterEntry),
+		sinkFactories: make(map[string]SinkFactory),
 	}
 	return manager
 }
@@ -96,101 +232,414 @@ func (m *LogConfigManager) RegisterParser(fileType string, parser ConfigParser)
 	m.parsers[fileType] = parser
 }
 
-func (m *LogConfigManager) RegisterUserDefinedFormatter(name string, formatFunc UserDefinedFormatterFunc) {
-	m.userDefinedFormatters[name] = formatFunc
+// RegisterUserDefinedFormatter registers a formatter function under a log_formatter type name. Names are
+// case-insensitive like the type values of the configuration; registering a built-in name or a name that is
+// already registered returns an error.
+func (m *LogConfigManager) RegisterUserDefinedFormatter(name string, formatFunc UserDefinedFormatterFunc) error {
+	return m.registerFormatter(name, formatterEntry{factory: func(string, map[string]interface{}) (LogFormatter, error) {
+		return UserDefinedFormatter{formatFunc: formatFunc}, nil
+	}})
+}
+
+// RegisterFormatter registers a formatter factory under a log_formatter type name, like
+// RegisterUserDefinedFormatter. The keys are the options the factory consumes; other keys of the formatter
+// block are reported as unknown, see SetStrictFormatterOptions. It takes precedence over a formatter of the
+// same name registered for all managers with the package-level RegisterFormatter.
+func (m *LogConfigManager) RegisterFormatter(name string, factory FormatterFactory, keys ...string) error {
+	return m.registerFormatter(name, formatterEntry{factory: factory, keys: keys})
+}
+
+// SetStrictFormatterOptions makes LoadConfig fail on options of a formatter block the formatter does not
+// consume instead of passing a warning to the error handler.
+func (m *LogConfigManager) SetStrictFormatterOptions(strict bool) *LogConfigManager {
+	m.strictFormatterOpts = strict
+	return m
+}
+
+// registerFormatter validates the name and registers the formatter under its lower-case form.
+func (m *LogConfigManager) registerFormatter(name string, entry formatterEntry) error {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		return fmt.Errorf("[mklog] formatter name must not be empty")
+	}
+	if _, ok := builtinFormatters[key]; ok {
+		return fmt.Errorf("[mklog] formatter name %q is reserved for a built-in formatter", name)
+	}
+	if _, ok := m.formatters[key]; ok {
+		return fmt.Errorf("[mklog] formatter %q is already registered", name)
+	}
+	m.formatters[key] = entry
+	return nil
+}
+
+// withVerbosity applies the verbosity key of a rule configuration when it is set.
+func withVerbosity(level *LogLevel) Option {
+	return func(lr *LogRule) {
+		if level != nil {
+			lr.SetVerbosity(*level)
+		}
+	}
+}
+
+// Formatters returns the sorted log_formatter type names the manager accepts, the built-in names and
+// aliases as well as the formatters registered with the manager and with the package-level RegisterFormatter.
+func (m *LogConfigManager) Formatters() []string {
+	seen := make(map[string]struct{}, len(builtinFormatters)+len(m.formatters))
+	for name := range builtinFormatters {
+		seen[name] = struct{}{}
+	}
+	for name := range m.formatters {
+		seen[name] = struct{}{}
+	}
+	for _, name := range RegisteredFormatters() {
+		seen[name] = struct{}{}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterSink registers a sink factory under a sink type name. It replaces a sink of the same name registered
+// with the manager before and takes precedence over one registered with the package-level RegisterSink.
+func (m *LogConfigManager) RegisterSink(name string, factory SinkFactory) {
+	m.sinkFactories[strings.ToLower(name)] = factory
+}
+
+// Sinks returns the sorted sink type names the manager accepts, the ones registered with the manager and with
+// the package-level RegisterSink.
+func (m *LogConfigManager) Sinks() []string {
+	seen := make(map[string]struct{}, len(m.sinkFactories))
+	for name := range m.sinkFactories {
+		seen[name] = struct{}{}
+	}
+	for _, name := range RegisteredSinks() {
+		seen[name] = struct{}{}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sinkFactory returns the factory of the sink type, preferring the sinks registered with the manager.
+func (m *LogConfigManager) sinkFactory(sinkType string) (SinkFactory, bool) {
+	key := strings.ToLower(sinkType)
+	if factory, ok := m.sinkFactories[key]; ok {
+		return factory, true
+	}
+	return registeredSink(key)
 }
 
 func (m *LogConfigManager) LoadConfig(filePath string) (*Debugger, error) {
+	config, unknown, err := m.readConfig(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defaults := m.getDefaults()
+	debugger := m.newConfigDebugger()
+	warnUnknownKeys(debugger, unknown)
+
+	for ruleName, rules := range config.LogRules {
+		for _, rule := range rules {
+			opts, ok, err := m.ruleOptions(debugger, ruleName, rule, defaults, false)
+			if err != nil {
+				// Release the files and sinks of the rules created before.
+				closeConfigDebugger(debugger)
+				return nil, err
+			}
+			if ok {
+				debugger.NewLogRule(ruleName, opts...)
+			}
+		}
+	}
+
+	// Start the session with the fingerprint of the configuration, see Debugger.ConfigFingerprint.
+	if !m.quiet {
+		writeSessionHeader(debugger, filepath.Base(filePath))
+	}
+	return debugger, nil
+}
+
+// readConfig reads and parses the configuration file with the parser registered for its extension. It returns
+// the paths of the keys the parser ignored, or fails on them with StrictKeys.
+func (m *LogConfigManager) readConfig(filePath string) (Config, []string, error) {
+	var config Config
 	ext := filepath.Ext(filePath)
 	parser, ok := m.parsers[ext]
 	if !ok {
-		return nil, fmt.Errorf("[mklog] unsupported config format: %s", ext)
+		return config, nil, fmt.Errorf("[mklog] unsupported config format: %s", ext)
 	}
 
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("[mklog] failed to read config file: %w", err)
+		return config, nil, fmt.Errorf("[mklog] failed to read config file: %w", err)
 	}
 
-	var config Config
 	if err := parser.ParseConfig(data, &config); err != nil {
-		return nil, fmt.Errorf("[mklog] failed to parse config file: %w", err)
+		return config, nil, fmt.Errorf("[mklog] failed to parse config file: %w", err)
+	}
+	unknown, err := unknownKeys(parser, data)
+	if err != nil {
+		return config, nil, fmt.Errorf("[mklog] failed to parse config file: %w", err)
+	}
+	if m.strictKeys && len(unknown) > 0 {
+		return config, nil, fmt.Errorf("[mklog] unknown keys in config file: %s", strings.Join(unknown, ", "))
 	}
+	return config, unknown, nil
+}
 
-	debugger := &Debugger{
-		LogRules: make(map[string][]*LogRule),
+// warnUnknownKeys passes a warning about every key of the configuration the parser ignored to the error handler.
+func warnUnknownKeys(d *Debugger, unknown []string) {
+	for _, key := range unknown {
+		d.notice(nil, "", WarningLevel, nil, "[mklog] ignoring unknown config key %s", key)
 	}
+}
 
-	for ruleName, rules := range config.LogRules {
-		for _, rule := range rules {
+// newConfigDebugger creates the Debugger the rules of a configuration are added to.
+func (m *LogConfigManager) newConfigDebugger() *Debugger {
+	d := &Debugger{
+		noEnvOverride: m.noEnvOverride,
+		quiet:         m.quiet,
+	}
+	return d.SetErrorHandler(m.errorHandler)
+}
 
-			formatter, err := rule.getFormatter(m.userDefinedFormatters)
-			if err != nil {
-				return nil, fmt.Errorf("[mklog] failed to get formatter: %w", err)
-			}
+// ruleOptions validates the rule, fills in the defaults and returns the options building it. It reports false
+// for rules without console, file or sink output, which are not created. The sinks are created once the rest
+// of the rule is valid, so an invalid rule leaves no sink behind; with dryRun they are checked against the
+// registered factories but not created.
+func (m *LogConfigManager) ruleOptions(debugger *Debugger, ruleName string, rule LogRulesConf, defaults Defaults, dryRun bool) ([]Option, bool, error) {
+	var err error
+	if rule.DateFormat, err = ResolveDateFormat(rule.DateFormat); err != nil {
+		return nil, false, fmt.Errorf("[mklog] invalid date_format of rule %s: %w", ruleName, err)
+	}
 
-			if rule.AsyncLog.Enable && rule.AsyncLog.BufferSize <= 0 {
-				fmt.Println("[mklog] Buffersize set to default value")
-				rule.AsyncLog.BufferSize = MKLOG_BufferSizeDefault
-			}
+	// The formatter renders time fields with the resolved layout rather than the preset name.
+	formatter, err := m.getFormatter(debugger, ruleName, rule)
+	if err != nil {
+		return nil, false, fmt.Errorf("[mklog] failed to get formatter: %w", err)
+	}
 
-			if rule.FolderFIle.Enable && rule.LogFile.Enable {
-				if err := rule.checkFilePath(); err != nil {
-					return nil, fmt.Errorf("[mklog] failed to check file path: %w", err)
-				}
+	if err := rule.checkSinks(m.sinkFactory); err != nil {
+		return nil, false, fmt.Errorf("[mklog] failed to create sinks: %w", err)
+	}
 
-				if err := rule.checkFolderSettings(); err != nil {
-					return nil, fmt.Errorf("[mklog] failed to check folder settings: %w", err)
-				}
+	if rule.LogFile.LineEnding, err = configLineEnding(rule.LogFile.LineEnding); err != nil {
+		return nil, false, fmt.Errorf("[mklog] invalid line_ending of rule %s: %w", ruleName, err)
+	}
 
-				debugger.NewLogRule(ruleName,
-					WithMinLevel(rule.MinLevel),
-					WithMaxLevel(rule.MaxLevel),
-					WithConsoleOutput(rule.ConsoleEnable),
-					WithDebugMode(rule.IsDebugMod, rule.DebugModeStatus),
-					WithDetailedErrorOutput(rule.LogFile.DetailedError),
-					WithDateFormat(rule.DateFormat),
-					WithForrmatter(formatter),
-					WithTimeFolder(rule.FolderFIle.TimeFolderFormat, rule.FolderFIle.FileFolderPeriod, rule.FolderFIle.Enable),
-					WithFileLoggingDateFormat(rule.LogFile.FilePath, rule.LogFile.FileName, rule.LogFile.FileType, rule.LogFile.DateFileFormat, rule.LogFile.DailyLog),
-					WithAsyncLog(rule.AsyncLog.Enable, rule.AsyncLog.BufferSize),
-				)
-			} else if rule.LogFile.Enable {
-
-				if err := rule.checkFilePath(); err != nil {
-					return nil, fmt.Errorf("[mklog] failed to check file path: %w", err)
-				}
+	if !validOpenMode(rule.LogFile.OpenMode) {
+		return nil, false, fmt.Errorf("[mklog] invalid open_mode of rule %s: unsupported open mode: %s", ruleName, rule.LogFile.OpenMode)
+	}
 
-				debugger.NewLogRule(ruleName,
-					WithMinLevel(rule.MinLevel),
-					WithMaxLevel(rule.MaxLevel),
-					WithConsoleOutput(rule.ConsoleEnable),
-					WithDebugMode(rule.IsDebugMod, rule.DebugModeStatus),
-					WithDetailedErrorOutput(rule.LogFile.DetailedError),
-					WithDateFormat(rule.DateFormat),
-					WithForrmatter(formatter),
-					WithFileLoggingDateFormat(rule.LogFile.FilePath, rule.LogFile.FileName, rule.LogFile.FileType, rule.LogFile.DateFileFormat, rule.LogFile.DailyLog),
-					WithAsyncLog(rule.AsyncLog.Enable, rule.AsyncLog.BufferSize),
-				)
-			} else if rule.ConsoleEnable {
-				debugger.NewLogRule(ruleName,
-					WithMinLevel(rule.MinLevel),
-					WithMaxLevel(rule.MaxLevel),
-					WithConsoleOutput(rule.ConsoleEnable),
-					WithDetailedErrorOutput(rule.LogFile.DetailedError),
-					WithDebugMode(rule.IsDebugMod, rule.DebugModeStatus),
-					WithDateFormat(rule.DateFormat),
-					WithForrmatter(formatter),
-					WithAsyncLog(rule.AsyncLog.Enable, rule.AsyncLog.BufferSize),
-				)
+	decorator, err := rule.consoleDecorator()
+	if err != nil {
+		return nil, false, fmt.Errorf("[mklog] invalid console_prefixes of rule %s: %w", ruleName, err)
+	}
+
+	location, err := rule.timestampLocation()
+	if err != nil {
+		return nil, false, fmt.Errorf("[mklog] invalid timestamp_location of rule %s: %w", ruleName, err)
+	}
+
+	if err := rule.AsyncLog.validate(); err != nil {
+		return nil, false, fmt.Errorf("[mklog] invalid async_log of rule %s: %w", ruleName, err)
+	}
+
+	if rule.AsyncLog.Enable && rule.AsyncLog.BufferSize <= 0 {
+		debugger.notice(nil, ruleName, InfoLevel, nil, "[mklog] Buffersize set to default value")
+		rule.AsyncLog.BufferSize = defaults.BufferSize
+	}
+
+	if !rule.LogFile.Enable && !rule.ConsoleEnable && len(rule.Sinks) == 0 {
+		return nil, false, nil
+	}
+
+	opts := []Option{
+		WithMinLevel(rule.MinLevel),
+		WithMaxLevel(rule.MaxLevel),
+		WithConsoleOutput(rule.ConsoleEnable),
+		withConsoleOnce(rule.ConsoleOnce),
+		WithDebugMode(rule.IsDebugMod, rule.DebugModeStatus),
+		withVerbosity(rule.Verbosity),
+		WithDetailedErrorOutput(rule.LogFile.DetailedError),
+		WithDateFormat(rule.DateFormat),
+		WithTimestampLocation(location),
+		WithConsoleDecorator(decorator),
+		WithRawOutput(rule.RawOutput),
+		WithMaxFieldBytes(rule.MaxFieldBytes),
+		WithMessagePrefix(rule.MessagePrefix),
+		WithMessageSuffix(rule.MessageSuffix),
+		WithForrmatter(formatter),
+	}
+
+	if rule.LogFile.Enable {
+		if err := rule.checkFilePath(defaults); err != nil {
+			return nil, false, fmt.Errorf("[mklog] failed to check file path: %w", err)
+		}
+		if _, _, err := checkFileName(rule.LogFile.FileName, rule.LogFile.FileType, rule.LogFile.FileNamePolicy); err != nil {
+			return nil, false, fmt.Errorf("[mklog] invalid file name of rule %s: %w", ruleName, err)
+		}
+
+		if rule.FolderFIle.Enable {
+			if err := rule.checkFolderSettings(debugger, ruleName, defaults); err != nil {
+				return nil, false, fmt.Errorf("[mklog] failed to check folder settings: %w", err)
 			}
+			opts = append(opts, WithTimeFolder(rule.FolderFIle.TimeFolderFormat, time.Duration(rule.FolderFIle.FileFolderPeriod), rule.FolderFIle.Enable))
 		}
+
+		opts = append(opts,
+			WithFileLoggingDateFormat(rule.LogFile.FilePath, rule.LogFile.FileName, rule.LogFile.FileType, rule.LogFile.DateFileFormat, rule.LogFile.DailyLog),
+			withFileLimits(rule.LogFile),
+		)
 	}
 
-	return debugger, nil
+	var sinks []Sink
+	if !dryRun {
+		if sinks, err = rule.getSinks(m.sinkFactory); err != nil {
+			return nil, false, fmt.Errorf("[mklog] failed to create sinks: %w", err)
+		}
+	}
+
+	opts = append(opts,
+		WithAsyncLog(rule.AsyncLog.Enable, rule.AsyncLog.BufferSize),
+		withSinks(sinks),
+		WithFatalExitCode(rule.FatalExitCode),
+		WithDrainWhenDisabled(rule.AsyncLog.DrainWhenDisabled),
+		WithAsyncFlushInterval(time.Duration(rule.AsyncLog.FlushInterval)),
+		WithAsyncOverflowPolicy(rule.AsyncLog.OverflowPolicy, time.Duration(rule.AsyncLog.SendTimeout)),
+		WithAsyncBatchSize(rule.AsyncLog.BatchSize),
+		WithDisabled(rule.Disabled),
+	)
+	return opts, true, nil
 }
 
-func (rule *LogRulesConf) checkFilePath() error {
+// withConsoleOnce applies the console_once key when it is set.
+func withConsoleOnce(once *bool) Option {
+	return func(lr *LogRule) {
+		if once != nil {
+			lr.ConsoleOnce = *once
+		}
+	}
+}
+
+func withFileLimits(conf LogFileConf) Option {
+	return func(lr *LogRule) {
+		if conf.IsLimitedFileSize {
+			lr.FileLog.IsLimitedFileSize = true
+			lr.FileLog.MaxFileSize = conf.MaxFileSize
+		}
+		lr.FileLog.MaxBackups = conf.MaxBackups
+		lr.FileLog.CopyTruncate = conf.CopyTruncate
+		lr.FileLog.MaxFileLines = conf.MaxFileLines
+		lr.FileLog.MaxAge = time.Duration(conf.Retention)
+		lr.FileLog.MaxDateFiles = conf.MaxDateFiles
+		lr.FileLog.StreamingCompression = conf.Compress
+		lr.FileLog.RotationInterval = time.Duration(conf.RotationInterval)
+		lr.FileLog.ArchiveAfter = time.Duration(conf.ArchiveAfter)
+		lr.FileLog.ArchiveGroupBy = conf.ArchiveGroupBy
+		lr.FileLog.Sanitize = conf.Sanitize
+		lr.FileLog.WriteBOM = conf.WriteBOM
+		lr.FileLog.LineEnding = conf.LineEnding
+		lr.FileLog.OpenMode = conf.OpenMode
+		lr.FileLog.FileNamePolicy = conf.FileNamePolicy
+		lr.FileLog.FailedQueueSize = conf.FailedQueueSize
+		lr.FileLog.FailedRetryInterval = time.Duration(conf.FailedRetryInterval)
+	}
+}
+
+// configLineEnding resolves the line ending names "lf" and "crlf" and validates the line ending.
+func configLineEnding(ending string) (string, error) {
+	switch strings.ToLower(ending) {
+	case "lf":
+		return "\n", nil
+	case "crlf":
+		return "\r\n", nil
+	}
+	if !validLineEnding(ending) {
+		return "", fmt.Errorf("unsupported line ending %q", ending)
+	}
+	return ending, nil
+}
+
+// consoleDecorator builds the decorator of the console output from console_prefixes.
+func (rule *LogRulesConf) consoleDecorator() (ConsoleDecorator, error) {
+	decorator := ConsoleDecorator{
+		BeforeFormat: rule.ConsolePrefixBeforeFormat,
+		Always:       rule.ConsolePrefixAlways,
+	}
+	if len(rule.ConsolePrefixes) == 0 {
+		return decorator, nil
+	}
+	decorator.Prefixes = make(map[LogLevel]string, len(rule.ConsolePrefixes))
+	for name, prefix := range rule.ConsolePrefixes {
+		level, err := StringToLogLevel(name)
+		if err != nil {
+			return decorator, err
+		}
+		decorator.Prefixes[level] = prefix
+	}
+	return decorator, nil
+}
+
+// timestampLocation loads the time zone of timestamp_location, nil when it is not set.
+func (rule *LogRulesConf) timestampLocation() (*time.Location, error) {
+	if rule.TimestampLocation == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(rule.TimestampLocation)
+}
+
+func (rule *LogRulesConf) getSinks(sinkFactory func(string) (SinkFactory, bool)) ([]Sink, error) {
+	var sinks []Sink
+	for _, conf := range rule.Sinks {
+		factory, ok := sinkFactory(conf.Type)
+		if !ok {
+			return nil, fmt.Errorf("[mklog] unsupported sink type: %s", conf.Type)
+		}
+
+		sink, err := factory(conf.Options)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("[mklog] failed to create sink %s: %w", conf.Type, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// closeSinks closes the sinks created for a rule that is not added.
+func closeSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		sink.Close()
+	}
+}
+
+// closeConfigDebugger shuts down the rules added while loading a configuration that failed to load.
+func closeConfigDebugger(d *Debugger) {
+	ctx, cancel := context.WithTimeout(context.Background(), MKLOG_CloseTimeoutDefault)
+	defer cancel()
+	d.Close(ctx)
+}
+
+// checkSinks checks that a factory is registered for every sink of the rule without creating the sinks.
+func (rule *LogRulesConf) checkSinks(sinkFactory func(string) (SinkFactory, bool)) error {
+	for _, conf := range rule.Sinks {
+		if _, ok := sinkFactory(conf.Type); !ok {
+			return fmt.Errorf("[mklog] unsupported sink type: %s", conf.Type)
+		}
+	}
+	return nil
+}
+
+func (rule *LogRulesConf) checkFilePath(defaults Defaults) error {
 	if rule.LogFile.Enable {
 		if rule.LogFile.FilePath != "" {
 			if rule.LogFile.FileName == "" || rule.LogFile.FileType == "" {
@@ -205,24 +654,24 @@ func (rule *LogRulesConf) checkFilePath() error {
 				}
 
 				if rule.LogFile.FileName == "" || rule.LogFile.FileName == "." {
-					rule.LogFile.FileName = MKLOG_FileNameDefault
+					rule.LogFile.FileName = defaults.FileName
 				}
 
 				if rule.LogFile.FileType == "" {
-					rule.LogFile.FileType = MKLOG_FileTypeDefault
+					rule.LogFile.FileType = defaults.FileType
 				}
 
 				rule.LogFile.FilePath = dir
 			}
 		} else {
 			if rule.LogFile.FileName == "" {
-				rule.LogFile.FileName = MKLOG_FileNameDefault
+				rule.LogFile.FileName = defaults.FileName
 			}
 			if rule.LogFile.FileType == "" {
-				rule.LogFile.FileType = MKLOG_FileTypeDefault
+				rule.LogFile.FileType = defaults.FileType
 			}
 			if rule.LogFile.FilePath == "" {
-				rule.LogFile.FilePath = "./" + MKLOG_DirDefault
+				rule.LogFile.FilePath = "./" + defaults.Dir
 			}
 		}
 
@@ -234,15 +683,15 @@ func (rule *LogRulesConf) checkFilePath() error {
 	return nil
 }
 
-func (rule *LogRulesConf) checkFolderSettings() error {
+func (rule *LogRulesConf) checkFolderSettings(d *Debugger, ruleName string, defaults Defaults) error {
 	if rule.FolderFIle.Enable {
 		if rule.FolderFIle.TimeFolderFormat == "" {
-			rule.FolderFIle.TimeFolderFormat = MKLOG_TimeFolderFormatDefault
-			fmt.Println("[mklog] TimeFolderFormat is not specified. Using default:", MKLOG_TimeFolderFormatDefault)
+			rule.FolderFIle.TimeFolderFormat = defaults.TimeFolderFormat
+			d.notice(nil, ruleName, InfoLevel, nil, "[mklog] TimeFolderFormat is not specified. Using default: %s", defaults.TimeFolderFormat)
 		}
 		if rule.FolderFIle.FileFolderPeriod == 0 {
-			rule.FolderFIle.FileFolderPeriod = MKLOG_FileFolderPeriodDefault
-			fmt.Println("[mklog] FileFolderPeriod is not specified. Using default:", MKLOG_FileFolderPeriodDefault)
+			rule.FolderFIle.FileFolderPeriod = Duration(defaults.FileFolderPeriod)
+			d.notice(nil, ruleName, InfoLevel, nil, "[mklog] FileFolderPeriod is not specified. Using default: %s", defaults.FileFolderPeriod)
 		}
 	}
 
@@ -257,30 +706,31 @@ func fileNameWithoutExt(fileName string) string {
 	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
 }
 
-func (rule *LogRulesConf) getFormatter(userDefinedFormatters map[string]UserDefinedFormatterFunc) (LogFormatter, error) {
-	formatterType := strings.ToLower(rule.LogFormatterType.Type)
-	var formatter LogFormatter
-
-	switch formatterType {
-	case "plaintextformatter", "plaintext", "plain", "text", "simple":
-		formatter = PlainTextFormatter{dateFormat: rule.DateFormat}
-		return formatter, nil
-	case "jsonformatter", "json":
-		formatter = JSONFormatter{dateFormat: rule.DateFormat}
-		return formatter, nil
-	case "yamlformatter", "yaml", "yml":
-		formatter = YAMLFormatter{dateFormat: rule.DateFormat}
-		return formatter, nil
-	case "xmlformatter", "xml":
-		formatter = XMLFormatter{dateFormat: rule.DateFormat}
-		return formatter, nil
-	default:
-		if formatFunc, exists := userDefinedFormatters[formatterType]; exists {
-			formatter = UserDefinedFormatter{formatFunc: formatFunc}
-		} else {
-			return nil, fmt.Errorf("[mklog] unsupported log formatter type: %s", rule.LogFormatterType)
+// getFormatter creates the formatter of the rule from its log_formatter block, reporting the options the
+// formatter does not consume.
+func (m *LogConfigManager) getFormatter(d *Debugger, ruleName string, rule LogRulesConf) (LogFormatter, error) {
+	conf := rule.LogFormatterType
+	formatterType := strings.ToLower(strings.TrimSpace(conf.Type))
+
+	entry, ok := builtinFormatters[formatterType]
+	if !ok {
+		if entry, ok = m.formatters[formatterType]; !ok {
+			if entry, ok = registeredFormatter(formatterType); !ok {
+				return nil, fmt.Errorf("[mklog] unsupported log formatter type: %s", conf.Type)
+			}
 		}
 	}
 
-	return nil, fmt.Errorf("unsupported log formatter type: %s", rule.LogFormatterType)
+	if unknown := entry.unknownOptions(conf.Options); len(unknown) > 0 {
+		if m.strictFormatterOpts {
+			return nil, fmt.Errorf("[mklog] unknown options of formatter %s: %s", conf.Type, strings.Join(unknown, ", "))
+		}
+		d.notice(nil, ruleName, WarningLevel, nil, "[mklog] ignoring unknown options of formatter %s: %s", conf.Type, strings.Join(unknown, ", "))
+	}
+
+	formatter, err := entry.factory(rule.DateFormat, conf.Options)
+	if err != nil {
+		return nil, fmt.Errorf("[mklog] invalid options of formatter %s: %w", conf.Type, err)
+	}
+	return formatter, nil
 }
@@ -0,0 +1,118 @@
+package mklog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ruleSnapshot is the set of rules read by log calls with the fingerprint of their configuration.
+type ruleSnapshot struct {
+	rules       map[string][]*LogRule
+	fingerprint string
+}
+
+// ruleConfig is the configuration of a rule hashed into the fingerprint: its exported settings without the
+// state changing while it logs, and the types and settings of the values configuration files cannot express.
+type ruleConfig struct {
+	Rule              LogRule           `json:"rule"`
+	Formatter         string            `json:"formatter"`
+	FormatterSettings json.RawMessage   `json:"formatter_settings,omitempty"`
+	ConsoleDecorator  *ConsoleDecorator `json:"console_decorator,omitempty"`
+	TimestampLocation string            `json:"timestamp_location,omitempty"`
+	Sinks             []string          `json:"sinks,omitempty"`
+	Hooks             []string          `json:"hooks,omitempty"`
+	Disabled          bool              `json:"disabled"`
+}
+
+// ConfigFingerprint returns the SHA-256 hash, hex encoded, of the configuration of the rules, e.g. to tell from
+// the logs of an incident which logging configuration the binary was running. It is computed whenever rules are
+// added or removed, by LoadConfig as well as by NewLogRule, AddRule and RemoveRule, from the settings the rules
+// were built with; changes made afterwards with the setters of a rule are not included. Rules with equal
+// settings have the same fingerprint regardless of the order of the keys in a configuration file.
+func (d *Debugger) ConfigFingerprint() string {
+	if s := d.ruleSet.Load(); s != nil {
+		return s.fingerprint
+	}
+	return rulesFingerprint(d.LogRules)
+}
+
+// SetConfigFingerprintField adds the fingerprint of the configuration to every entry as a static field under the
+// key, e.g. "config_fingerprint", following changes of the rules. An empty key removes the field.
+func (d *Debugger) SetConfigFingerprintField(key string) *Debugger {
+	d.staticMu.Lock()
+	d.fingerprintField = key
+	d.staticMu.Unlock()
+	d.refreshStaticFields()
+	return d
+}
+
+// recordConfig records the configuration of the rule for the fingerprint. It must be called before the rule is
+// added, as the state of a rule that logs changes concurrently.
+func (lr *LogRule) recordConfig() {
+	rule := *lr
+	rule.CurrentLevel = 0
+	rule.LogFormatter = nil
+	rule.FileLog.CurrentFileName = ""
+	config := ruleConfig{
+		Rule:             rule,
+		Formatter:        formatterName(lr.LogFormatter),
+		ConsoleDecorator: lr.ConsoleDecorator,
+		Disabled:         !lr.Enabled(),
+	}
+	if settings, err := json.Marshal(lr.LogFormatter); err == nil {
+		config.FormatterSettings = settings
+	}
+	if lr.TimestampLocation != nil {
+		config.TimestampLocation = lr.TimestampLocation.String()
+	}
+	for _, sink := range lr.Sinks {
+		config.Sinks = append(config.Sinks, typeName(sink))
+	}
+	for _, hook := range lr.hooks() {
+		config.Hooks = append(config.Hooks, typeName(hook))
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		data, _ = json.Marshal(config.Formatter) // Unreachable with the types of LogRule; keeps the rule counted.
+	}
+	lr.config = data
+}
+
+// rulesFingerprint hashes the recorded configurations of the rules by module. Modules are hashed in the order
+// of their names and rules in their order within the module.
+func rulesFingerprint(rules map[string][]*LogRule) string {
+	configs := make(map[string][]json.RawMessage, len(rules))
+	for module, moduleRules := range rules {
+		for _, lr := range moduleRules {
+			if lr.config == nil {
+				lr.recordConfig() // Rules added to a Debugger literal.
+			}
+			configs[module] = append(configs[module], lr.config)
+		}
+	}
+	data, _ := json.Marshal(configs) // Map keys are sorted.
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeSessionHeader starts the session of a loaded configuration in the log file of every rule with a comment
+// line naming the configuration and its fingerprint, so the configuration a process ran with can be told from
+// its log files. FileReader skips the line like any line it cannot parse.
+func writeSessionHeader(d *Debugger, name string) {
+	header := fmt.Sprintf("# mklog session %s config=%s config_fingerprint=%s",
+		time.Now().Format(time.RFC3339), name, d.ConfigFingerprint())
+	for _, rules := range d.rules() {
+		for _, rule := range rules {
+			if !rule.FileLog.Enable {
+				continue
+			}
+			if err := rule.writeFile(time.Now(), header+rule.lineEnding()); err != nil {
+				rule.reportError(fmt.Errorf("[mklog] failed to write the session header: %w", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package mklog
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadFingerprint loads the configuration quietly and returns the fingerprint of its rules.
+func loadFingerprint(t *testing.T, path string) string {
+	t.Helper()
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	return d.ConfigFingerprint()
+}
+
+// TestConfigFingerprintKeyOrder gives semantically equal configurations in YAML and JSON with different key
+// orders the same fingerprint, and a different one once a level changes.
+func TestConfigFingerprintKeyOrder(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	yamlConfig := writeConfig(t, "config.yaml", `
+log_rules:
+  db:
+    - min_level: WARNING
+      max_level: FATAL
+      log_formatter: {type: json}
+      file_log: {enable: true, file_path: `+dir+`, file_name: db, file_type: .log}
+  api:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: api, file_type: .log}
+`)
+	jsonConfig := writeConfig(t, "config.json", `{"log_rules": {
+  "api": [{"file_log": {"file_type": ".log", "file_name": "api", "file_path": "`+dir+`", "enable": true},
+           "log_formatter": {"type": "plain"}, "max_level": 5, "min_level": 2}],
+  "db": [{"log_formatter": {"type": "json"}, "file_log": {"file_name": "db", "enable": true, "file_type": ".log", "file_path": "`+dir+`"},
+          "max_level": 5, "min_level": 3}]
+}}`)
+	changed := writeConfig(t, "changed.yaml", `
+log_rules:
+  api:
+    - min_level: DEBUG
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: api, file_type: .log}
+  db:
+    - min_level: WARNING
+      max_level: FATAL
+      log_formatter: {type: json}
+      file_log: {enable: true, file_path: `+dir+`, file_name: db, file_type: .log}
+`)
+
+	fromYAML, fromJSON := loadFingerprint(t, yamlConfig), loadFingerprint(t, jsonConfig)
+	if len(fromYAML) != 64 || fromYAML != fromJSON {
+		t.Errorf("fingerprints of equal configurations differ: %s, %s", fromYAML, fromJSON)
+	}
+	if fromYAML != loadFingerprint(t, yamlConfig) {
+		t.Error("fingerprint of the same configuration changed between loads")
+	}
+	if loadFingerprint(t, changed) == fromYAML {
+		t.Error("fingerprint did not change with the min_level")
+	}
+}
+
+// TestConfigFingerprintRuleChanges recomputes the fingerprint of programmatic rules when rules are added and
+// removed, and gives debuggers with the same rules the same fingerprint.
+func TestConfigFingerprintRuleChanges(t *testing.T) {
+	build := func(level LogLevel) *Debugger {
+		d := (&Debugger{}).SetQuiet(true)
+		d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithMinLevel(level))
+		return d
+	}
+	d, same, other := build(InfoLevel), build(InfoLevel), build(ErrorLevel)
+	defer d.Close(context.Background())
+	defer same.Close(context.Background())
+	defer other.Close(context.Background())
+
+	before := d.ConfigFingerprint()
+	if before != same.ConfigFingerprint() || before == other.ConfigFingerprint() {
+		t.Errorf("fingerprints %s, same rules %s, other level %s", before, same.ConfigFingerprint(), other.ConfigFingerprint())
+	}
+	d.NewLogRule("db", WithFileWriter(&syncBuffer{}), WithSink(&fieldSink{}))
+	added := d.ConfigFingerprint()
+	if added == before {
+		t.Error("fingerprint did not change when a rule was added")
+	}
+	if err := d.RemoveRule(context.Background(), "db", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.ConfigFingerprint(); got != before {
+		t.Errorf("fingerprint after removing the rule = %s, want %s", got, before)
+	}
+}
+
+// TestConfigFingerprintField adds the fingerprint to every entry next to the static fields and follows rule
+// changes.
+func TestConfigFingerprintField(t *testing.T) {
+	sink := &fieldSink{}
+	d := (&Debugger{}).SetQuiet(true).SetStaticFields("service", "api").SetConfigFingerprintField("config_fingerprint")
+	defer d.Close(context.Background())
+	d.NewLogRule("app", WithSink(sink))
+	d.Module("app").Info("first")
+	first := d.ConfigFingerprint()
+
+	d.NewLogRule("db", WithFileWriter(&syncBuffer{}))
+	d.Module("app").Info("second")
+	d.SetConfigFingerprintField("")
+	d.Module("app").Info("third")
+
+	if len(sink.fields) != 3 {
+		t.Fatalf("%d entries, want 3", len(sink.fields))
+	}
+	if got := sink.fields[0]["config_fingerprint"]; got != first || sink.fields[0]["service"] != "api" {
+		t.Errorf("first entry fields = %v, want the fingerprint %s and the static field", sink.fields[0], first)
+	}
+	if got := sink.fields[1]["config_fingerprint"]; got != d.ConfigFingerprint() || got == first {
+		t.Errorf("second entry fingerprint = %v, want the one after adding the rule", got)
+	}
+	if _, ok := sink.fields[2]["config_fingerprint"]; ok || sink.fields[2]["service"] != "api" {
+		t.Errorf("third entry fields = %v, want the static field only", sink.fields[2])
+	}
+}
+
+// TestConfigFingerprintSessionHeader starts the log file with a header line holding the fingerprint when a
+// configuration is loaded by a manager that is not quiet, without logging an entry.
+func TestConfigFingerprintSessionHeader(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	d, err := NewLogConfigManager().LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: logfmt}
+      file_log: {enable: true, file_path: `+dir+`, file_name: app, file_type: .log}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := d.ConfigFingerprint()
+	d.Info("started")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dir, "app.log"))), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "# mklog session ") ||
+		!strings.HasSuffix(lines[0], " config=config.yaml config_fingerprint="+fingerprint) {
+		t.Fatalf("log = %q, want the session header with fingerprint %s and the entry", lines, fingerprint)
+	}
+
+	r, err := NewFileReader(filepath.Join(dir, "app.log"), "logfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var messages []string
+	for r.Next() {
+		messages = append(messages, r.Entry().Message)
+	}
+	if len(messages) != 1 || messages[0] != "started" {
+		t.Errorf("entries read back = %q, want only the logged entry", messages)
+	}
+}
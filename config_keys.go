@@ -0,0 +1,157 @@
+package mklog
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StrictKeys makes LoadConfig, Plan and ModuleConstants fail on keys the configuration does not declare, e.g. a
+// misspelled buffer_sized in an async_log block, with an error naming the path of every such key. By default
+// the keys are ignored and LoadConfig and Plan pass a warning per key to the error handler instead. Keys are
+// checked in files read by the built-in JSON and YAML parsers; the options of sinks and the formatter
+// specific keys of log_formatter blocks are free-form, see SetStrictFormatterOptions for the latter.
+func (m *LogConfigManager) StrictKeys(strict bool) *LogConfigManager {
+	m.strictKeys = strict
+	return m
+}
+
+// unknownKeys returns the paths of the keys of a configuration file the parser ignored. Files of other parsers
+// than the built-in ones are not checked.
+func unknownKeys(parser ConfigParser, data []byte) ([]string, error) {
+	var doc interface{}
+	var tag string
+	switch parser.(type) {
+	case *JSONConfigParser:
+		tag = "json"
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	case *YAMLConfigParser:
+		tag = "yaml"
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	var unknown []string
+	collectUnknownKeys(doc, reflect.TypeOf(Config{}), tag, "", &unknown)
+	return unknown, nil
+}
+
+// collectUnknownKeys appends the paths of the keys of the decoded value that the type does not declare, matching
+// them against the names of the struct tag like the parser of the format does. Values of a different shape than
+// the type are left to the parser, which reports them itself.
+func collectUnknownKeys(v interface{}, t reflect.Type, tag, path string, unknown *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		fields, ok := documentMap(v)
+		if !ok {
+			return
+		}
+		known, open := structKeys(t, tag)
+		for _, key := range sortedDocumentKeys(fields) {
+			field, ok := known[key]
+			if !ok && tag == "json" {
+				field, ok = foldedKey(known, key) // encoding/json matches keys case-insensitively.
+			}
+			switch {
+			case ok:
+				collectUnknownKeys(fields[key], field.Type, tag, keyPath(path, key), unknown)
+			case !open:
+				*unknown = append(*unknown, keyPath(path, key))
+			}
+		}
+	case reflect.Map:
+		entries, ok := documentMap(v)
+		if !ok {
+			return
+		}
+		for _, key := range sortedDocumentKeys(entries) {
+			collectUnknownKeys(entries[key], t.Elem(), tag, keyPath(path, key), unknown)
+		}
+	case reflect.Slice:
+		items, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			collectUnknownKeys(item, t.Elem(), tag, fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	}
+}
+
+// structKeys returns the fields of the struct by key name and whether it accepts any key, as LogFormatterConfig
+// does by keeping the keys it does not declare as options.
+func structKeys(t reflect.Type, tag string) (map[string]reflect.StructField, bool) {
+	known := make(map[string]reflect.StructField, t.NumField())
+	open := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Contains(field.Tag.Get("yaml"), "inline") {
+			open = true
+			continue
+		}
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		known[name] = field
+	}
+	return known, open
+}
+
+// foldedKey returns the field whose key equals the key under Unicode case-folding.
+func foldedKey(known map[string]reflect.StructField, key string) (reflect.StructField, bool) {
+	for name, field := range known {
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// documentMap returns the keys and values of a decoded mapping, as decoded by encoding/json or yaml.v3.
+func documentMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			converted[fmt.Sprint(k)] = v
+		}
+		return converted, true
+	}
+	return nil, false
+}
+
+// sortedDocumentKeys returns the keys of the mapping in order, so the paths are reported in a stable order.
+func sortedDocumentKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keyPath returns the path of the key below the path, joined with a dot.
+func keyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
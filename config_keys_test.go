@@ -0,0 +1,122 @@
+package mklog
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// misspelledConfigs hold the same misspelled keys at the top, rule and nested block levels in each format.
+var misspelledConfigs = map[string]string{
+	"config.yaml": `
+log_rule: {}
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      min_levle: DEBUG
+      console_enable: true
+      log_formatter: {type: plain}
+      async_log:
+        enable: false
+        buffer_sized: 100
+`,
+	"config.json": `{
+  "log_rule": {},
+  "log_rules": {
+    "app": [{
+      "min_level": 2,
+      "max_level": 5,
+      "min_levle": 1,
+      "console_enable": true,
+      "log_formatter": {"type": "plain"},
+      "async_log": {"enable": false, "buffer_sized": 100}
+    }]
+  }
+}`,
+}
+
+var misspelledKeys = []string{"log_rule", "log_rules.app[0].async_log.buffer_sized", "log_rules.app[0].min_levle"}
+
+// TestStrictKeysRejectsMisspelledKeys fails to load and plan the configurations, naming the path of every
+// misspelled key.
+func TestStrictKeysRejectsMisspelledKeys(t *testing.T) {
+	for name, content := range misspelledConfigs {
+		path := writeConfig(t, name, content)
+		m := NewLogConfigManager().SetQuiet(true).StrictKeys(true)
+
+		_, loadErr := m.LoadConfig(path)
+		_, planErr := m.Plan(path)
+		for _, err := range []error{loadErr, planErr} {
+			if err == nil {
+				t.Fatalf("%s: strict mode accepted misspelled keys", name)
+			}
+			for _, key := range misspelledKeys {
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("%s: error %q does not name %s", name, err, key)
+				}
+			}
+		}
+	}
+}
+
+// TestLenientKeysWarnAboutMisspelledKeys loads the configurations by default, warning about every misspelled
+// key in the notices of Plan and through the error handler of LoadConfig.
+func TestLenientKeysWarnAboutMisspelledKeys(t *testing.T) {
+	for name, content := range misspelledConfigs {
+		path := writeConfig(t, name, content)
+		var warnings []string
+		m := NewLogConfigManager().SetQuiet(true).SetErrorHandler(func(err error) { warnings = append(warnings, err.Error()) })
+
+		d, err := m.LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		d.Close(context.Background())
+		plan, err := m.Plan(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(plan.Rules) != 1 || plan.Rules[0].MinLevel != InfoLevel {
+			t.Errorf("%s: planned %+v, want the app rule from INFO", name, plan.Rules)
+		}
+
+		for _, key := range misspelledKeys {
+			for source, messages := range map[string][]string{"plan": plan.Notices, "error handler": warnings} {
+				if !containsSubstring(messages, key) {
+					t.Errorf("%s: %s warnings %q do not name %s", name, source, messages, key)
+				}
+			}
+		}
+	}
+}
+
+// TestStrictKeysAcceptsDeclaredKeys loads a configuration with only declared keys, including the free-form
+// options of formatter blocks and sinks, in strict mode.
+func TestStrictKeysAcceptsDeclaredKeys(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: json, pretty: false}
+      sinks: [{type: discard, options: {anything: goes}}]
+`)
+	m := NewLogConfigManager().SetQuiet(true).StrictKeys(true)
+	m.RegisterSink("discard", func(map[string]interface{}) (Sink, error) { return trackedSink{closed: new(int32)}, nil })
+	d, err := m.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close(context.Background())
+}
+
+// containsSubstring checks whether any of the messages contains the text.
+func containsSubstring(messages []string, text string) bool {
+	for _, msg := range messages {
+		if strings.Contains(msg, text) {
+			return true
+		}
+	}
+	return false
+}
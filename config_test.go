@@ -0,0 +1,495 @@
+package mklog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeConfig writes the configuration to a file of the given name in a temporary directory and returns its path.
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadConfigDatePresetRendersTimeFields checks that a date_format preset reaches the formatter resolved, so
+// time fields are rendered with its layout.
+func TestLoadConfigDatePresetRendersTimeFields(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      date_format: rfc3339
+      log_formatter: {type: json}
+      file_log:
+        enable: true
+        file_path: `+filepath.ToSlash(dir)+`
+        file_name: app
+        file_type: .log
+`)
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC)
+	d.With("at", at).Info("request")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry struct {
+		Timestamp string                 `json:"timestamp"`
+		Fields    map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &entry); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	if got, want := entry.Fields["at"], at.Format(time.RFC3339); got != want {
+		t.Errorf("time field = %v, want %v", got, want)
+	}
+	if _, err := time.Parse(time.RFC3339, entry.Timestamp); err != nil {
+		t.Errorf("timestamp %q is not RFC 3339: %v", entry.Timestamp, err)
+	}
+}
+
+// trackedSink counts the sinks of a sink factory that were closed.
+type trackedSink struct {
+	closed *int32
+}
+
+func (s trackedSink) Write(Entry, string) error { return nil }
+func (s trackedSink) Close() error              { atomic.AddInt32(s.closed, 1); return nil }
+
+// TestLoadConfigClosesSinksOnError checks that a configuration failing on a later rule closes the sinks created
+// for the rules before it and creates none for the invalid rule.
+func TestLoadConfigClosesSinksOnError(t *testing.T) {
+	var created, closed int32
+	m := NewLogConfigManager().SetQuiet(true)
+	m.RegisterSink("tracked", func(map[string]interface{}) (Sink, error) {
+		atomic.AddInt32(&created, 1)
+		return trackedSink{closed: &closed}, nil
+	})
+
+	path := writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      sinks: [{type: tracked}]
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      sinks: [{type: tracked}]
+      file_log: {line_ending: "\t"}
+`)
+	if _, err := m.LoadConfig(path); err == nil || !strings.Contains(err.Error(), "line_ending") {
+		t.Fatalf("LoadConfig error = %v, want an invalid line_ending", err)
+	}
+	if created != 1 || closed != 1 {
+		t.Errorf("created %d and closed %d sinks, want 1 and 1", created, closed)
+	}
+}
+
+// TestConfigDurations reads every duration of the configuration as a duration string and as nanoseconds in
+// both formats, and checks that they reach the loaded rule.
+func TestConfigDurations(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	configs := map[string]string{
+		"strings.yaml": `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      async_log: {enable: true, buffer_size: 8, flush_interval: 250ms, send_timeout: 2s}
+      folder_file: {enable: true, file_folder_period: 24h, time_folder_format: "2006-01-02"}
+      file_log:
+        enable: true
+        file_path: ` + dir + `
+        file_name: app
+        file_type: .log
+        retention: 168h
+        rotation_interval: 1h
+        archive_after: 48h
+        failed_retry_interval: 30s
+`,
+		"nanoseconds.yaml": `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      async_log: {enable: true, buffer_size: 8, flush_interval: 250000000, send_timeout: 2000000000}
+      folder_file: {enable: true, file_folder_period: 86400000000000, time_folder_format: "2006-01-02"}
+      file_log:
+        enable: true
+        file_path: ` + dir + `
+        file_name: app
+        file_type: .log
+        retention: 604800000000000
+        rotation_interval: 3600000000000
+        archive_after: 172800000000000
+        failed_retry_interval: 30000000000
+`,
+		"strings.json": `{"log_rules": {"app": [{
+  "min_level": 2, "max_level": 5, "log_formatter": {"type": "plain"},
+  "async_log": {"enable": true, "buffer_size": 8, "flush_interval": "250ms", "send_timeout": "2s"},
+  "folder_file": {"enable": true, "file_folder_period": "24h", "time_folder_format": "2006-01-02"},
+  "file_log": {"enable": true, "file_path": "` + dir + `", "file_name": "app", "file_type": ".log",
+    "retention": "168h", "rotation_interval": "1h", "archive_after": "48h", "failed_retry_interval": "30s"}
+}]}}`,
+		"nanoseconds.json": `{"log_rules": {"app": [{
+  "min_level": 2, "max_level": 5, "log_formatter": {"type": "plain"},
+  "async_log": {"enable": true, "buffer_size": 8, "flush_interval": 250000000, "send_timeout": 2000000000},
+  "folder_file": {"enable": true, "file_folder_period": 86400000000000, "time_folder_format": "2006-01-02"},
+  "file_log": {"enable": true, "file_path": "` + dir + `", "file_name": "app", "file_type": ".log",
+    "retention": 604800000000000, "rotation_interval": 3600000000000, "archive_after": 172800000000000,
+    "failed_retry_interval": 30000000000}
+}]}}`,
+	}
+
+	for name, content := range configs {
+		d, err := NewLogConfigManager().SetQuiet(true).StrictKeys(true).LoadConfig(writeConfig(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		rule := d.rules()["app"][0]
+		got := []time.Duration{
+			rule.AsyncLog.FlushInterval, rule.AsyncLog.SendTimeout, rule.FileFolder.FileFolderPeriod,
+			rule.FileLog.MaxAge, rule.FileLog.RotationInterval, rule.FileLog.ArchiveAfter, rule.FileLog.FailedRetryInterval,
+		}
+		want := []time.Duration{
+			250 * time.Millisecond, 2 * time.Second, 24 * time.Hour,
+			168 * time.Hour, time.Hour, 48 * time.Hour, 30 * time.Second,
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: durations = %v, want %v", name, got, want)
+				break
+			}
+		}
+		d.Close(context.Background())
+	}
+}
+
+// TestConfigDurationInvalid rejects a duration that is neither a duration string nor a number.
+func TestConfigDurationInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.yaml": "log_rules: {app: [{file_log: {retention: a week}}]}",
+		"config.json": `{"log_rules": {"app": [{"file_log": {"retention": "a week"}}]}}`,
+	} {
+		if _, err := NewLogConfigManager().SetQuiet(true).LoadConfig(writeConfig(t, name, content)); err == nil || !strings.Contains(err.Error(), "invalid duration") {
+			t.Errorf("%s: LoadConfig error = %v, want an invalid duration", name, err)
+		}
+	}
+}
+
+// TestConfigMaxDateFiles reads max_date_files and removes the surplus dated files of the rule when it starts.
+func TestConfigMaxDateFiles(t *testing.T) {
+	dir := t.TempDir()
+	for day := 1; day <= 7; day++ {
+		name := filepath.Join(dir, time.Date(2000, 1, day, 0, 0, 0, 0, time.Local).Format("2006-01-02")+"_app.log")
+		if err := os.WriteFile(name, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log:
+        enable: true
+        daily_log_enable: true
+        date_file_format: "2006-01-02"
+        max_date_files: 2
+        file_path: `+filepath.ToSlash(dir)+`
+        file_name: app
+        file_type: .log
+`)
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close(context.Background())
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"2000-01-06_app.log", "2000-01-07_app.log", time.Now().Format("2006-01-02") + "_app.log"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
+
+// TestConfigTimestampLocation renders the timestamps of a rule in the zone of timestamp_location and rejects
+// unknown zones, naming the rule.
+func TestConfigTimestampLocation(t *testing.T) {
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+	m := NewLogConfigManager().SetQuiet(true)
+	d, err := m.LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  tokyo:
+    - min_level: INFO
+      max_level: FATAL
+      date_format: rfc3339
+      timestamp_location: Asia/Tokyo
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+filepath.ToSlash(t.TempDir())+`, file_name: tokyo, file_type: .log}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	rule := d.rules()["tokyo"][0]
+	if got := rule.timestampTime(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)).Format(time.RFC3339); got != "2024-01-15T21:00:00+09:00" {
+		t.Errorf("timestamp = %s, want 2024-01-15T21:00:00+09:00", got)
+	}
+
+	_, err = m.LoadConfig(writeConfig(t, "config.json", `{"log_rules": {"api": [{"min_level": 2, "max_level": 5, "log_formatter": {"type": "plain"}, "timestamp_location": "Mars/Olympus_Mons"}]}}`))
+	if err == nil || !strings.Contains(err.Error(), "invalid timestamp_location of rule api") {
+		t.Errorf("LoadConfig error = %v, want the unknown zone of rule api", err)
+	}
+}
+
+// TestConfigSanitize sets the file sanitization and the raw output of rules from the configuration.
+func TestConfigSanitize(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  sanitized:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: sanitized, file_type: .log, sanitize: true}
+  raw:
+    - min_level: INFO
+      max_level: FATAL
+      raw_output: true
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: raw, file_type: .log, sanitize: true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Module("sanitized").Info("a\rb")
+	d.Module("raw").Info("a\rb")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(dir, "sanitized.log")); !strings.HasSuffix(got, `a\x0db`+"\n") {
+		t.Errorf("sanitized file = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "raw.log")); !strings.HasSuffix(got, "a\rb\n") {
+		t.Errorf("raw file = %q", got)
+	}
+}
+
+// TestConfigFileEncoding starts the log file of a rule with a BOM and ends its entries with CRLF from the
+// configuration, and rejects unknown line endings.
+func TestConfigFileEncoding(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	m := NewLogConfigManager().SetQuiet(true)
+	d, err := m.LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: app, file_type: .log, write_bom: true, line_ending: CRLF}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Info("one")
+	d.Info("two")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "\xef\xbb\xbf") || strings.Count(string(data), "\r\n") != 2 || strings.Count(string(data), "\n") != 2 {
+		t.Errorf("file = %q, want a BOM and two CRLF entries", data)
+	}
+
+	_, err = m.LoadConfig(writeConfig(t, "config.json", `{"log_rules": {"api": [{"min_level": 2, "max_level": 5, "log_formatter": {"type": "plain"}, "file_log": {"line_ending": "cr"}}]}}`))
+	if err == nil || !strings.Contains(err.Error(), "invalid line_ending of rule api") {
+		t.Errorf("LoadConfig error = %v, want the invalid line ending of rule api", err)
+	}
+}
+
+// TestRegisterUserDefinedFormatterNames registers a formatter with a mixed-case name, looks it up with another
+// case from the configuration and lists it, and rejects empty, built-in and duplicate names.
+func TestRegisterUserDefinedFormatterNames(t *testing.T) {
+	m := NewLogConfigManager().SetQuiet(true)
+	format := func(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+		return "mine: " + logMessage
+	}
+	if err := m.RegisterUserDefinedFormatter("MyFmt", format); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.ToSlash(t.TempDir())
+	d, err := m.LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: MYFMT}
+      file_log: {enable: true, file_path: `+dir+`, file_name: app, file_type: .log}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Info("hello")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != "mine: hello\n" {
+		t.Errorf("log = %q, want the registered formatter", got)
+	}
+
+	names := strings.Join(m.Formatters(), ",")
+	for _, want := range []string{"myfmt", "json", "plain"} {
+		if !strings.Contains(","+names+",", ","+want+",") {
+			t.Errorf("Formatters() = %s, want %s", names, want)
+		}
+	}
+	if strings.Contains(names, "MyFmt") {
+		t.Errorf("Formatters() = %s, want lower-case names", names)
+	}
+
+	for name, want := range map[string]string{
+		"  ":    "must not be empty",
+		"JSON":  `formatter name "JSON" is reserved for a built-in formatter`,
+		"myFMT": `formatter "myFMT" is already registered`,
+	} {
+		if err := m.RegisterUserDefinedFormatter(name, format); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("RegisterUserDefinedFormatter(%q) error = %v, want %q", name, err, want)
+		}
+	}
+}
+
+// TestConfigFormatterOptions configures pretty JSON, CSV without a header and a template formatter registered
+// with its option key purely from YAML.
+func TestConfigFormatterOptions(t *testing.T) {
+	m := NewLogConfigManager().SetQuiet(true)
+	err := m.RegisterFormatter("template", func(dateFormat string, options map[string]interface{}) (LogFormatter, error) {
+		template, _ := options["template"].(string)
+		if template == "" {
+			return nil, errors.New("template must be a non-empty string")
+		}
+		return UserDefinedFormatter{formatFunc: func(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+			return strings.NewReplacer("{level}", logLevel, "{module}", moduleName, "{message}", logMessage).Replace(template)
+		}}, nil
+	}, "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.ToSlash(t.TempDir())
+	d, err := m.LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  json:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: json, pretty: true}
+      file_log: {enable: true, file_path: `+dir+`, file_name: json, file_type: .log}
+  csv:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: csv, header: false}
+      file_log: {enable: true, file_path: `+dir+`, file_name: csv, file_type: .log}
+  tpl:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter:
+        type: template
+        template: "<{level}> {module}: {message}"
+      file_log: {enable: true, file_path: `+dir+`, file_name: tpl, file_type: .log}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, module := range []string{"json", "csv", "tpl"} {
+		d.Module(module).Info("hello")
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(dir, "json.log")); !strings.HasPrefix(got, "{\n  \"") || !strings.Contains(got, "\n  \"logMessage\": \"hello\",\n") {
+		t.Errorf("json log = %q, want indented JSON", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "csv.log")); strings.Contains(got, CSVFormatter{}.Header()) || strings.Count(got, "\n") != 1 {
+		t.Errorf("csv log = %q, want one record without a header", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "tpl.log")); got != "<INFO> tpl: hello\n" {
+		t.Errorf("template log = %q", got)
+	}
+}
+
+// TestConfigFormatterOptionsInvalid warns about unknown formatter options, rejects them in strict mode and
+// rejects options of the wrong type.
+func TestConfigFormatterOptionsInvalid(t *testing.T) {
+	config := func(block string) string {
+		return writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: `+block+`
+      file_log: {enable: true, file_path: `+filepath.ToSlash(t.TempDir())+`, file_name: app, file_type: .log}
+`)
+	}
+
+	var warnings []string
+	m := NewLogConfigManager().SetQuiet(true).SetErrorHandler(func(err error) { warnings = append(warnings, err.Error()) })
+	d, err := m.LoadConfig(config("{type: json, pretty: true, colour: red, size: 3}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "ignoring unknown options of formatter json: colour, size") {
+		t.Errorf("warnings = %q, want the unknown options", warnings)
+	}
+
+	m.SetStrictFormatterOptions(true)
+	if _, err := m.LoadConfig(config("{type: json, colour: red}")); err == nil || !strings.Contains(err.Error(), "unknown options of formatter json: colour") {
+		t.Errorf("strict LoadConfig error = %v, want the unknown option", err)
+	}
+	if _, err := m.LoadConfig(config(`{type: json, pretty: "yes"}`)); err == nil || !strings.Contains(err.Error(), "option pretty must be a boolean") {
+		t.Errorf("LoadConfig error = %v, want the invalid pretty option", err)
+	}
+}
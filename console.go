@@ -0,0 +1,131 @@
+package mklog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ConsoleDecorator marks the console output of a rule per level, e.g. with "✔", "⚠" and "✖" markers,
+// while log files and sinks keep the plain formatted message.
+type ConsoleDecorator struct {
+	Prefixes     map[LogLevel]string // Prefix of the console output per level; levels without a prefix are not decorated.
+	BeforeFormat bool                // Prefix the message before it is formatted instead of the formatted line.
+	Always       bool                // Decorate even when stdout is not a terminal, e.g. when it is piped.
+}
+
+// consoleFlag is the type of ForceConsole.
+type consoleFlag bool
+
+// ForceConsole, passed among the arguments of a log method, prints the entry to the console even through rules
+// without console output, e.g. a file-only rule reporting something the operator must see right away:
+//
+//	d.Warning("license expires in %d days", days, mklog.ForceConsole)
+//
+// It is removed from the arguments before the message is formatted and only affects the entry of the call.
+// Disabled rules, rules dropping the entry in their middleware and WithConsoleOnce still apply.
+const ForceConsole consoleFlag = true
+
+var (
+	stdoutTerminalOnce sync.Once
+	stdoutTerminal     bool
+)
+
+// WithConsoleDecorator decorates the console output of the rule. Unless Always is set, the decorator only
+// applies while stdout is a terminal, so redirected output stays plain.
+func WithConsoleDecorator(decorator ConsoleDecorator) Option {
+	return func(lr *LogRule) {
+		if len(decorator.Prefixes) == 0 {
+			lr.ConsoleDecorator = nil
+			return
+		}
+		lr.ConsoleDecorator = &decorator
+	}
+}
+
+// WithLevelPrefixes prefixes the formatted console lines of the levels with the given markers while stdout
+// is a terminal, like WithConsoleDecorator.
+func WithLevelPrefixes(prefixes map[LogLevel]string) Option {
+	return WithConsoleDecorator(ConsoleDecorator{Prefixes: prefixes})
+}
+
+// isStdoutTerminal reports whether stdout is a character device, checked once per process.
+func isStdoutTerminal() bool {
+	stdoutTerminalOnce.Do(func() {
+		if info, err := os.Stdout.Stat(); err == nil {
+			stdoutTerminal = info.Mode()&os.ModeCharDevice != 0
+		}
+	})
+	return stdoutTerminal
+}
+
+// splitConsoleFlag removes ForceConsole from the arguments of a log call, reporting whether it was passed.
+// The arguments are returned unchanged when they do not hold it.
+func splitConsoleFlag(args []interface{}) ([]interface{}, bool) {
+	found := false
+	for _, arg := range args {
+		if _, ok := arg.(consoleFlag); ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return args, false
+	}
+
+	rest := make([]interface{}, 0, len(args)-1)
+	for _, arg := range args {
+		if _, ok := arg.(consoleFlag); !ok {
+			rest = append(rest, arg)
+		}
+	}
+	return rest, true
+}
+
+// consoleOutput reports whether the rule prints the entry to the console.
+func (lr *LogRule) consoleOutput(entry Entry) bool {
+	return lr.IsConsoleOutput || entry.forceConsole
+}
+
+// consolePrefix returns the prefix the decorator of the rule adds to the console output of the level.
+func (lr *LogRule) consolePrefix(level LogLevel) (string, bool) {
+	dec := lr.ConsoleDecorator
+	if dec == nil {
+		return "", false
+	}
+	prefix, ok := dec.Prefixes[level]
+	if !ok || (!dec.Always && !isStdoutTerminal()) {
+		return "", false
+	}
+	return prefix, true
+}
+
+// writeConsole prints the formatted message of the entry to stdout with control characters escaped unless the
+// rule has raw output, decorated by the ConsoleDecorator of the rule and adapted to the capabilities of the
+// console, see setupConsole. Prefixes added before formatting are escaped like the message.
+func (lr *LogRule) writeConsole(entry Entry, finalMessage string) {
+	if entry.skipConsole {
+		return
+	}
+	prefix, ok := lr.consolePrefix(entry.Level)
+	if ok && lr.ConsoleDecorator.BeforeFormat {
+		finalMessage = lr.prepareMessage(entry.Time, prefix+entry.Message, entry.Level, lr.renderStack(entry.Level), entry.Fields, entry.Err)
+	}
+	if !lr.RawOutput {
+		finalMessage = sanitizeControl(finalMessage)
+	}
+	if ok && !lr.ConsoleDecorator.BeforeFormat {
+		finalMessage = prefix + finalMessage
+	}
+	printConsoleLine(adaptConsoleOutput(finalMessage))
+}
+
+// printConsoleLine prints the text to stdout, adding a line break unless it ends with one already.
+func printConsoleLine(text string) {
+	if strings.HasSuffix(text, "\n") {
+		fmt.Print(text)
+		return
+	}
+	fmt.Println(text)
+}
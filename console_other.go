@@ -0,0 +1,8 @@
+//go:build !windows
+
+package mklog
+
+// systemConsole returns nil, as terminals outside Windows interpret escape sequences and UTF-8 already.
+func systemConsole() consoleAPI {
+	return nil
+}
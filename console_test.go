@@ -0,0 +1,141 @@
+package mklog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConsolePrefixPerLevel prefixes the console lines of the decorated levels only, after or before
+// formatting, and keeps the log file plain.
+func TestConsolePrefixPerLevel(t *testing.T) {
+	at := time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC)
+	prefixes := map[LogLevel]string{WarningLevel: "⚠ ", ErrorLevel: "✖ "}
+	tests := []struct {
+		name      string
+		decorator ConsoleDecorator
+		want      []string
+	}{
+		{"after format", ConsoleDecorator{Prefixes: prefixes, Always: true}, []string{
+			"2024-03-09T10:20:30Z | INFO | [app] : started",
+			"⚠ 2024-03-09T10:20:30Z | WARNING | [app] : disk almost full",
+			"✖ 2024-03-09T10:20:30Z | ERROR | [app] : disk full",
+		}},
+		{"before format", ConsoleDecorator{Prefixes: prefixes, Always: true, BeforeFormat: true}, []string{
+			"2024-03-09T10:20:30Z | INFO | [app] : started",
+			"2024-03-09T10:20:30Z | WARNING | [app] : ⚠ disk almost full",
+			"2024-03-09T10:20:30Z | ERROR | [app] : ✖ disk full",
+		}},
+	}
+	if !isStdoutTerminal() {
+		tests = append(tests, struct {
+			name      string
+			decorator ConsoleDecorator
+			want      []string
+		}{"not a terminal", ConsoleDecorator{Prefixes: prefixes}, []string{
+			"2024-03-09T10:20:30Z | INFO | [app] : started",
+			"2024-03-09T10:20:30Z | WARNING | [app] : disk almost full",
+			"2024-03-09T10:20:30Z | ERROR | [app] : disk full",
+		}})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &syncBuffer{}
+			console := captureStdout(t, func() {
+				d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return at })
+				d.NewLogRule("app", WithFileWriter(file), WithConsoleOutput(true), WithDateFormat(time.RFC3339),
+					WithConsoleDecorator(tt.decorator))
+				d.Info("started")
+				d.Warning("disk almost full")
+				d.Error("disk full")
+				d.Close(context.Background())
+			})
+
+			if got := strings.Split(strings.TrimSuffix(console, "\n"), "\n"); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("console = %q, want %q", got, tt.want)
+			}
+			if strings.ContainsAny(file.String(), "⚠✖") {
+				t.Errorf("log file = %q, want no prefixes", file.String())
+			}
+		})
+	}
+}
+
+// TestConsoleOnceDefaults enables ConsoleOnce for rules built by NewLogRule and from a configuration unless
+// console_once is false, and leaves it off for rules built as struct literals.
+func TestConsoleOnceDefaults(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithConsoleOnce(false))
+	defer d.Close(context.Background())
+	if rules := d.rules()["app"]; !rules[0].ConsoleOnce || rules[1].ConsoleOnce {
+		t.Errorf("ConsoleOnce = %v, %v, want true by default and false with WithConsoleOnce(false)",
+			rules[0].ConsoleOnce, rules[1].ConsoleOnce)
+	}
+	if (&LogRule{}).ConsoleOnce {
+		t.Error("ConsoleOnce of a zero rule is true")
+	}
+
+	path := writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      console_enable: true
+      log_formatter: {type: plain}
+    - min_level: ERROR
+      console_enable: true
+      console_once: false
+      log_formatter: {type: plain}
+`)
+	loaded, err := NewLogConfigManager().SetQuiet(true).LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close(context.Background())
+	if rules := loaded.rules()["app"]; len(rules) != 2 || !rules[0].ConsoleOnce || rules[1].ConsoleOnce {
+		t.Errorf("loaded rules = %v, want ConsoleOnce by default and off with console_once: false", rules)
+	}
+}
+
+// TestSeparateLogAndErrorPreset writes Error entries to both log files of DefaultSeparateLogAndError and prints
+// the entries of the first rule to the console once, leaving the Fatal entries of the error rule to its file.
+func TestSeparateLogAndErrorPreset(t *testing.T) {
+	dir := t.TempDir()
+	saved := GetDefaults()
+	SetDefaults(Defaults{Dir: dir})
+	t.Cleanup(func() { SetDefaults(saved) })
+
+	console := captureStdout(t, func() {
+		d := DefaultSeparateLogAndError("app").SetQuiet(true)
+		d.Info("started")
+		d.Error("disk full")
+		d.Custom(FatalLevel, "out of memory")
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	for msg, want := range map[string]int{"started": 1, "disk full": 1, "out of memory": 0} {
+		if n := strings.Count(console, msg); n != want {
+			t.Errorf("console printed %q %d times, want %d:\n%s", msg, n, want, console)
+		}
+	}
+	files := map[string]string{}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files[filepath.Ext(path)] += readFile(t, path)
+		}
+		return nil
+	})
+	if log := files[saved.FileType]; !strings.Contains(log, "started") || !strings.Contains(log, "disk full") ||
+		strings.Contains(log, "out of memory") {
+		t.Errorf("log file = %q, want the Info and Error entries", log)
+	}
+	if errLog := files[".err"]; strings.Contains(errLog, "started") || !strings.Contains(errLog, "disk full") ||
+		!strings.Contains(errLog, "out of memory") {
+		t.Errorf("error file = %q, want the Error and Fatal entries", errLog)
+	}
+}
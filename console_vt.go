@@ -0,0 +1,136 @@
+package mklog
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	enableVirtualTerminalProcessing = 0x0004 // ENABLE_VIRTUAL_TERMINAL_PROCESSING console mode flag
+	utf8CodePage                    = 65001  // CP_UTF8 code page
+)
+
+// MKLOG_ConsoleASCIIFallback replaces non-ASCII characters of the console output with '?' when the console
+// code page cannot be switched to UTF-8, so older Windows consoles show placeholders instead of garbled text.
+var MKLOG_ConsoleASCIIFallback = false
+
+// consoleAPI is the system layer of the console setup, so the setup logic can be exercised with a fake console.
+type consoleAPI interface {
+	StdHandles() []uintptr                            // Handles of stdout and stderr
+	GetConsoleMode(handle uintptr) (uint32, error)    // Mode of the console handle, an error when it is no console
+	SetConsoleMode(handle uintptr, mode uint32) error // Changes the mode of the console handle
+	GetConsoleOutputCP() uint32                       // Active output code page of the console
+	SetConsoleOutputCP(codePage uint32) error         // Changes the output code page of the console
+}
+
+// consoleState is the outcome of the console setup.
+type consoleState struct {
+	vt         bool               // ANSI escape sequences are interpreted
+	utf8       bool               // Output is decoded as UTF-8
+	modes      map[uintptr]uint32 // Modes of the console handles the setup changed, by handle
+	codePage   uint32             // Output code page before the setup
+	switchedCP bool               // The setup switched the output code page
+}
+
+var (
+	consoleMu    sync.Mutex
+	consoleReady bool // The console is set up, see currentConsole
+	consoleSetup consoleState
+	// consoleSystem returns the console layer of the platform, replaced in tests.
+	consoleSystem = systemConsole
+)
+
+// ansiSequence matches ANSI CSI escape sequences such as color codes.
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// currentConsole sets up the console of the process on first use and returns its state.
+func currentConsole() consoleState {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	if !consoleReady {
+		consoleSetup = setupConsole(consoleSystem())
+		consoleReady = true
+	}
+	return consoleSetup
+}
+
+// restoreConsole gives the console back the modes and the output code page it had before the setup, so the
+// shell or the parent process does not keep the settings of the logger. It is called when a Debugger is
+// closed; console output logged afterwards sets the console up again.
+func restoreConsole() {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	if consoleReady {
+		consoleSetup.restore(consoleSystem())
+		consoleReady = false
+	}
+}
+
+// setupConsole enables VT processing on the console handles and switches the output code page to UTF-8,
+// remembering the previous settings for restore. Handles that are no console, e.g. redirected output, are
+// left alone. A nil api, as on platforms other than Windows, reports a console supporting both.
+func setupConsole(api consoleAPI) consoleState {
+	if api == nil {
+		return consoleState{vt: true, utf8: true}
+	}
+
+	state := consoleState{vt: true}
+	for _, handle := range api.StdHandles() {
+		mode, err := api.GetConsoleMode(handle)
+		if err != nil {
+			continue // Not a console, escape sequences reach a file or pipe unchanged.
+		}
+		if mode&enableVirtualTerminalProcessing == 0 {
+			if err := api.SetConsoleMode(handle, mode|enableVirtualTerminalProcessing); err != nil {
+				state.vt = false
+				continue
+			}
+			if state.modes == nil {
+				state.modes = make(map[uintptr]uint32)
+			}
+			state.modes[handle] = mode
+		}
+	}
+
+	state.codePage = api.GetConsoleOutputCP()
+	switch {
+	case state.codePage == utf8CodePage:
+		state.utf8 = true
+	case api.SetConsoleOutputCP(utf8CodePage) == nil:
+		state.utf8, state.switchedCP = true, true
+	}
+	return state
+}
+
+// restore sets the console handles back to their modes and the output code page back to the one before the
+// setup, only for the settings the setup changed.
+func (s consoleState) restore(api consoleAPI) {
+	if api == nil {
+		return
+	}
+	for handle, mode := range s.modes {
+		api.SetConsoleMode(handle, mode)
+	}
+	if s.switchedCP {
+		api.SetConsoleOutputCP(s.codePage)
+	}
+}
+
+// adaptConsoleOutput strips escape sequences the console cannot interpret and, with
+// MKLOG_ConsoleASCIIFallback, replaces characters its code page cannot show.
+func adaptConsoleOutput(s string) string {
+	state := currentConsole()
+	if !state.vt {
+		s = ansiSequence.ReplaceAllString(s, "")
+	}
+	if !state.utf8 && MKLOG_ConsoleASCIIFallback {
+		s = strings.Map(func(r rune) rune {
+			if r > 0x7f {
+				return '?'
+			}
+			return r
+		}, s)
+	}
+	return s
+}
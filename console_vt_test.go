@@ -0,0 +1,115 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakeConsole is a console layer recording the settings of its handles.
+type fakeConsole struct {
+	modes    map[uintptr]uint32 // Modes of the console handles, other handles are no console
+	codePage uint32
+	failMode bool // SetConsoleMode fails
+	failCP   bool // SetConsoleOutputCP fails
+	cpCalls  []uint32
+}
+
+func (c *fakeConsole) StdHandles() []uintptr { return []uintptr{1, 2} }
+
+func (c *fakeConsole) GetConsoleMode(handle uintptr) (uint32, error) {
+	mode, ok := c.modes[handle]
+	if !ok {
+		return 0, errors.New("not a console")
+	}
+	return mode, nil
+}
+
+func (c *fakeConsole) SetConsoleMode(handle uintptr, mode uint32) error {
+	if c.failMode {
+		return errors.New("not supported")
+	}
+	c.modes[handle] = mode
+	return nil
+}
+
+func (c *fakeConsole) GetConsoleOutputCP() uint32 { return c.codePage }
+
+func (c *fakeConsole) SetConsoleOutputCP(codePage uint32) error {
+	c.cpCalls = append(c.cpCalls, codePage)
+	if c.failCP {
+		return errors.New("not supported")
+	}
+	c.codePage = codePage
+	return nil
+}
+
+// TestSetupConsoleRestore enables VT processing and UTF-8 on a legacy console and restores its modes and code
+// page afterwards, leaving redirected handles and settings it did not change alone.
+func TestSetupConsoleRestore(t *testing.T) {
+	tests := []struct {
+		name     string
+		console  fakeConsole
+		want     consoleState
+		wantCP   []uint32 // Code pages set by the setup and the restore
+		wantMode uint32   // Mode of handle 1 after the restore
+	}{
+		{"legacy", fakeConsole{modes: map[uintptr]uint32{1: 0x3}, codePage: 437},
+			consoleState{vt: true, utf8: true}, []uint32{utf8CodePage, 437}, 0x3},
+		{"modern", fakeConsole{modes: map[uintptr]uint32{1: 0x7, 2: 0x7}, codePage: utf8CodePage},
+			consoleState{vt: true, utf8: true}, nil, 0x7},
+		{"no UTF-8", fakeConsole{modes: map[uintptr]uint32{1: 0x3}, codePage: 850, failCP: true},
+			consoleState{vt: true, utf8: false}, []uint32{utf8CodePage}, 0x3},
+		{"no VT", fakeConsole{modes: map[uintptr]uint32{1: 0x3}, codePage: 437, failMode: true},
+			consoleState{vt: false, utf8: true}, []uint32{utf8CodePage, 437}, 0x3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.console
+			state := setupConsole(&c)
+			if state.vt != tt.want.vt || state.utf8 != tt.want.utf8 {
+				t.Errorf("state = vt %v, utf8 %v, want vt %v, utf8 %v", state.vt, state.utf8, tt.want.vt, tt.want.utf8)
+			}
+			if _, ok := c.modes[2]; ok != (tt.name == "modern") {
+				t.Errorf("the redirected handle 2 got mode %#x", c.modes[2])
+			}
+			state.restore(&c)
+			if c.modes[1] != tt.wantMode {
+				t.Errorf("mode after restore = %#x, want %#x", c.modes[1], tt.wantMode)
+			}
+			if len(c.cpCalls) != len(tt.wantCP) {
+				t.Fatalf("code pages set = %v, want %v", c.cpCalls, tt.wantCP)
+			}
+			for i := range c.cpCalls {
+				if c.cpCalls[i] != tt.wantCP[i] {
+					t.Errorf("code pages set = %v, want %v", c.cpCalls, tt.wantCP)
+				}
+			}
+		})
+	}
+}
+
+// TestCloseRestoresConsole sets the console up on the first console output and restores it when the Debugger
+// is closed.
+func TestCloseRestoresConsole(t *testing.T) {
+	c := &fakeConsole{modes: map[uintptr]uint32{1: 0x3}, codePage: 1252}
+	restoreConsole()
+	consoleSystem = func() consoleAPI { return c }
+	defer func() {
+		restoreConsole()
+		consoleSystem = systemConsole
+	}()
+
+	d := &Debugger{}
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	if got := adaptConsoleOutput("grün"); got != "grün" {
+		t.Errorf("adaptConsoleOutput = %q, want the text unchanged", got)
+	}
+	if c.codePage != utf8CodePage || c.modes[1] != 0x3|enableVirtualTerminalProcessing {
+		t.Fatalf("console = code page %d, mode %#x, want UTF-8 with VT processing", c.codePage, c.modes[1])
+	}
+	d.Close(context.Background())
+	if c.codePage != 1252 || c.modes[1] != 0x3 {
+		t.Errorf("console after Close = code page %d, mode %#x, want 1252 and %#x", c.codePage, c.modes[1], 0x3)
+	}
+}
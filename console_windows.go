@@ -0,0 +1,55 @@
+//go:build windows
+
+package mklog
+
+import "syscall"
+
+var (
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode     = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleOutputCP = kernel32.NewProc("GetConsoleOutputCP")
+	procSetConsoleOutputCP = kernel32.NewProc("SetConsoleOutputCP")
+)
+
+// windowsConsole calls the console functions of kernel32.
+type windowsConsole struct{}
+
+// systemConsole returns the console layer of Windows.
+func systemConsole() consoleAPI {
+	return windowsConsole{}
+}
+
+func (windowsConsole) StdHandles() []uintptr {
+	var handles []uintptr
+	for _, std := range []int{syscall.STD_OUTPUT_HANDLE, syscall.STD_ERROR_HANDLE} {
+		if h, err := syscall.GetStdHandle(std); err == nil && h != syscall.InvalidHandle {
+			handles = append(handles, uintptr(h))
+		}
+	}
+	return handles
+}
+
+func (windowsConsole) GetConsoleMode(handle uintptr) (uint32, error) {
+	var mode uint32
+	err := syscall.GetConsoleMode(syscall.Handle(handle), &mode)
+	return mode, err
+}
+
+func (windowsConsole) SetConsoleMode(handle uintptr, mode uint32) error {
+	if r, _, err := procSetConsoleMode.Call(handle, uintptr(mode)); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (windowsConsole) GetConsoleOutputCP() uint32 {
+	r, _, _ := procGetConsoleOutputCP.Call()
+	return uint32(r)
+}
+
+func (windowsConsole) SetConsoleOutputCP(codePage uint32) error {
+	if r, _, err := procSetConsoleOutputCP.Call(uintptr(codePage)); r == 0 {
+		return err
+	}
+	return nil
+}
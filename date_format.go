@@ -0,0 +1,87 @@
+package mklog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	UnixDateFormat   = "unix"    // Date format rendering timestamps as seconds since the Unix epoch.
+	UnixMsDateFormat = "unix_ms" // Date format rendering timestamps as milliseconds since the Unix epoch.
+)
+
+// dateFormatPresets maps the names accepted by ResolveDateFormat onto the date formats.
+var dateFormatPresets = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"iso8601":     "2006-01-02T15:04:05.000Z07:00",
+	"unix":        UnixDateFormat,
+	"unix_ms":     UnixMsDateFormat,
+	"kitchen":     time.Kitchen,
+}
+
+// ResolveDateFormat returns the date format of a preset name — "rfc3339", "rfc3339nano", "iso8601", "unix",
+// "unix_ms" or "kitchen", ignoring case — or the name itself when it is a Go reference layout like
+// "2006-01-02 15:04:05". The unix presets render the timestamp as a number instead of using a layout.
+// Names that are neither, e.g. "yyyy-MM-dd", return an error. An empty name is returned unchanged.
+func ResolveDateFormat(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	if layout, ok := dateFormatPresets[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return layout, nil
+	}
+	// A layout renders any time other than the reference time differently from its own text.
+	if probe := time.Date(1999, time.November, 28, 21, 33, 44, 0, time.UTC); probe.Format(name) == name {
+		return "", fmt.Errorf("unknown date format %q, expected a preset or a Go reference layout", name)
+	}
+	return name, nil
+}
+
+// resolveDateFormat returns the date format of a preset name, or the name itself when it is not a preset.
+func resolveDateFormat(name string) string {
+	if layout, ok := dateFormatPresets[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return layout
+	}
+	return name
+}
+
+// formatTimestamp renders the time with the date format, which may be one of the unix presets.
+func formatTimestamp(t time.Time, dateFormat string) string {
+	switch dateFormat {
+	case UnixDateFormat:
+		return strconv.FormatInt(t.Unix(), 10)
+	case UnixMsDateFormat:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.Format(dateFormat)
+}
+
+// appendTimestamp appends the time rendered like formatTimestamp to dst.
+func appendTimestamp(dst []byte, t time.Time, dateFormat string) []byte {
+	switch dateFormat {
+	case UnixDateFormat:
+		return strconv.AppendInt(dst, t.Unix(), 10)
+	case UnixMsDateFormat:
+		return strconv.AppendInt(dst, t.UnixMilli(), 10)
+	}
+	return t.AppendFormat(dst, dateFormat)
+}
+
+// parseTimestamp parses a timestamp rendered by formatTimestamp in the local time zone.
+func parseTimestamp(value string, dateFormat string) (time.Time, error) {
+	switch dateFormat {
+	case UnixDateFormat, UnixMsDateFormat:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if dateFormat == UnixDateFormat {
+			return time.Unix(n, 0), nil
+		}
+		return time.UnixMilli(n), nil
+	}
+	return time.ParseInLocation(dateFormat, value, time.Local)
+}
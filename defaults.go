@@ -0,0 +1,99 @@
+package mklog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Defaults are the settings NewDebugLogger, NewLogRule, the Default* constructors and LoadConfig fall back to
+// when a rule leaves them unset. Zero fields keep the default they are merged onto.
+type Defaults struct {
+	Dir              string        // Directory of log files
+	FileName         string        // Base name of log files
+	FileType         string        // Extension of log files
+	FileFolderPeriod time.Duration // Period of time folders
+	TimeFolderFormat string        // Date format of time folder names
+	TimeFileFormat   string        // Date format of dated log file names
+	TimeLogFormat    string        // Timestamp format of log entries
+	Formatter        LogFormatter  // Formatter of rules without one
+	BufferSize       int           // Buffer size of async logging
+}
+
+// managedDefaults holds the package defaults, taken from the deprecated MKLOG_* variables when the package is
+// initialized and replaced by SetDefaults.
+var managedDefaults atomic.Pointer[Defaults]
+
+func init() {
+	managedDefaults.Store(&Defaults{
+		Dir:              MKLOG_DirDefault,
+		FileName:         MKLOG_FileNameDefault,
+		FileType:         MKLOG_FileTypeDefault,
+		FileFolderPeriod: MKLOG_FileFolderPeriodDefault,
+		TimeFolderFormat: MKLOG_TimeFolderFormatDefault,
+		TimeFileFormat:   MKLOG_TimeFileFormatDefault,
+		TimeLogFormat:    MKLOG_TimeLogFormatDefault,
+		Formatter:        MKLOG_FormatterDefault,
+		BufferSize:       MKLOG_BufferSizeDefault,
+	})
+}
+
+// SetDefaults replaces the package defaults. Zero fields of defaults keep the current default. It is safe to
+// call while rules are being built; rules built before keep their settings.
+func SetDefaults(defaults Defaults) {
+	merged := defaults.merge(GetDefaults())
+	managedDefaults.Store(&merged)
+}
+
+// GetDefaults returns the package defaults. They start with the initial values of the deprecated MKLOG_*
+// variables; writing the variables has no effect.
+func GetDefaults() Defaults {
+	return *managedDefaults.Load()
+}
+
+// SetDefaults sets the defaults of the rules loaded by the manager, so that libraries loading their own
+// configurations do not depend on the package defaults. Zero fields fall back to the package defaults at load
+// time.
+func (m *LogConfigManager) SetDefaults(defaults Defaults) *LogConfigManager {
+	m.defaults = &defaults
+	return m
+}
+
+// getDefaults returns the defaults of the manager merged onto the package defaults.
+func (m *LogConfigManager) getDefaults() Defaults {
+	if m.defaults == nil {
+		return GetDefaults()
+	}
+	return m.defaults.merge(GetDefaults())
+}
+
+// merge returns the defaults with their zero fields taken from base.
+func (d Defaults) merge(base Defaults) Defaults {
+	if d.Dir == "" {
+		d.Dir = base.Dir
+	}
+	if d.FileName == "" {
+		d.FileName = base.FileName
+	}
+	if d.FileType == "" {
+		d.FileType = base.FileType
+	}
+	if d.FileFolderPeriod == 0 {
+		d.FileFolderPeriod = base.FileFolderPeriod
+	}
+	if d.TimeFolderFormat == "" {
+		d.TimeFolderFormat = base.TimeFolderFormat
+	}
+	if d.TimeFileFormat == "" {
+		d.TimeFileFormat = base.TimeFileFormat
+	}
+	if d.TimeLogFormat == "" {
+		d.TimeLogFormat = base.TimeLogFormat
+	}
+	if d.Formatter == nil {
+		d.Formatter = base.Formatter
+	}
+	if d.BufferSize <= 0 {
+		d.BufferSize = base.BufferSize
+	}
+	return d
+}
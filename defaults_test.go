@@ -0,0 +1,66 @@
+package mklog
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// TestSetDefaultsWhileBuildingRules changes the package defaults while several goroutines build rules, run with
+// -race. Every rule takes the buffer size of one consistent set of defaults.
+func TestSetDefaultsWhileBuildingRules(t *testing.T) {
+	const builders, rules = 4, 25
+
+	saved := GetDefaults()
+	t.Cleanup(func() { SetDefaults(saved) })
+
+	d := (&Debugger{}).SetQuiet(true)
+	var wg sync.WaitGroup
+	for g := 0; g < builders; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rules; i++ {
+				d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithAsyncLog(true, 0))
+				NewLogConfigManager().SetDefaults(Defaults{FileName: "lib"}).getDefaults()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rules; i++ {
+			SetDefaults(Defaults{BufferSize: 32 + 32*(i%2), TimeLogFormat: "15:04:05"})
+		}
+	}()
+	wg.Wait()
+
+	for _, rule := range d.rules()["app"] {
+		if size := rule.AsyncLog.BufferSize; size != 32 && size != 64 && size != saved.BufferSize {
+			t.Errorf("rule has buffer size %d, want one of the defaults", size)
+		}
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSetDefaultsKeepsZeroFields merges the set defaults onto the current ones.
+func TestSetDefaultsKeepsZeroFields(t *testing.T) {
+	saved := GetDefaults()
+	t.Cleanup(func() { SetDefaults(saved) })
+
+	SetDefaults(Defaults{Dir: "var/log"})
+	got := GetDefaults()
+	if got.Dir != "var/log" {
+		t.Errorf("Dir = %q, want var/log", got.Dir)
+	}
+	if got.FileName != saved.FileName || got.BufferSize != saved.BufferSize {
+		t.Errorf("zero fields replaced the defaults: %+v", got)
+	}
+
+	m := NewLogConfigManager().SetDefaults(Defaults{FileName: "lib"})
+	if got := m.getDefaults(); got.FileName != "lib" || got.Dir != "var/log" {
+		t.Errorf("manager defaults = %+v, want the lib file name in var/log", got)
+	}
+}
@@ -0,0 +1,210 @@
+package mklog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ruleAttr is a setting of a rule shown by String and GoString.
+type ruleAttr struct {
+	key   string
+	value string
+}
+
+// String returns a multi-line summary of the settings of the rule for diagnostics, e.g. in panics or support
+// bundles: module, level window, verbosity, formatter, console and file outputs, async settings and sinks. The
+// levels are the ones in effect, noting the configured ones while BumpVerbosity shifts them.
+// It holds no channels, file handles or sink options, so it is safe to print where secrets must not leak.
+func (lr *LogRule) String() string {
+	if lr == nil {
+		return "<nil rule>"
+	}
+	var b strings.Builder
+	for i, attr := range lr.describe() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%-10s %s", attr.key+":", attr.value)
+	}
+	return b.String()
+}
+
+// GoString returns the summary of String on a single line, so %#v prints the settings of the rule instead of
+// its internal state.
+func (lr *LogRule) GoString() string {
+	if lr == nil {
+		return "(*mklog.LogRule)(nil)"
+	}
+	attrs := lr.describe()
+	parts := make([]string, len(attrs))
+	for i, attr := range attrs {
+		parts[i] = fmt.Sprintf("%s: %q", attr.key, attr.value)
+	}
+	return "&mklog.LogRule{" + strings.Join(parts, ", ") + "}"
+}
+
+// describe returns the settings of the rule shown by String and GoString.
+func (lr *LogRule) describe() []ruleAttr {
+	module := lr.ModuleName
+	if len(lr.Submodules) > 0 {
+		module += " (" + strings.Join(lr.Submodules, ", ") + ")"
+	}
+	minLevel, verbosity := lr.levelBounds()
+	levels := lr.GetLogLevelName(minLevel) + ".." + lr.GetLogLevelName(lr.MaxLevel)
+	verbosityName := lr.GetLogLevelName(verbosity)
+	shift := lr.verbosityShift()
+	if minLevel != lr.MinLevel {
+		levels += fmt.Sprintf(" (shifted %+d from %s)", shift, lr.GetLogLevelName(lr.MinLevel))
+	}
+	if verbosity != lr.Verbosity {
+		verbosityName += fmt.Sprintf(" (shifted %+d from %s)", shift, lr.GetLogLevelName(lr.Verbosity))
+	}
+	attrs := []ruleAttr{
+		{"module", module},
+		{"enabled", fmt.Sprint(lr.Enabled())},
+		{"levels", levels},
+		{"verbosity", verbosityName},
+		{"formatter", formatterName(lr.LogFormatter)},
+		{"console", onOff(lr.IsConsoleOutput)},
+		{"file", lr.describeFile()},
+		{"async", lr.describeAsync()},
+	}
+	sinks := "none"
+	if len(lr.Sinks) > 0 {
+		names := make([]string, len(lr.Sinks))
+		for i, sink := range lr.Sinks {
+			names[i] = outputName(i, sink)
+		}
+		sinks = strings.Join(names, ", ")
+	}
+	return append(attrs, ruleAttr{"sinks", sinks})
+}
+
+// verbosityShift returns the shift of the levels of the rule set with BumpVerbosity.
+func (lr *LogRule) verbosityShift() int {
+	if lr.debugger == nil {
+		return 0
+	}
+	return lr.debugger.VerbosityShift()
+}
+
+// describeFile returns the configured log file of the rule, or the type of the writer replacing it.
+func (lr *LogRule) describeFile() string {
+	switch {
+	case !lr.FileLog.Enable:
+		return "off"
+	case lr.FileLog.Writer != nil:
+		return fmt.Sprintf("writer (%T)", lr.FileLog.Writer)
+	}
+	file := lr.fileTarget()
+	if lr.FileLog.IsDateFile {
+		file += ", dated"
+	}
+	if lr.FileLog.IsLimitedFileSize {
+		file += fmt.Sprintf(", max %d bytes", lr.FileLog.MaxFileSize)
+	}
+	if lr.FileLog.MaxBackups > 0 {
+		file += fmt.Sprintf(", %d backups", lr.FileLog.MaxBackups)
+	}
+	return file
+}
+
+// describeAsync returns the async settings of the rule.
+func (lr *LogRule) describeAsync() string {
+	async := lr.AsyncLog
+	if !async.Enable {
+		return "off"
+	}
+	policy := async.OverflowPolicy
+	if policy == "" {
+		policy = OverflowBlock
+	}
+	desc := fmt.Sprintf("buffer %d, overflow %s", async.BufferSize, policy)
+	if async.BatchSize > 1 {
+		desc += fmt.Sprintf(", batch %d", async.BatchSize)
+	}
+	if async.StallTimeout > 0 {
+		desc += fmt.Sprintf(", stall timeout %v", async.StallTimeout)
+	}
+	return desc
+}
+
+// onOff returns the state of a flag as shown by String.
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+// String returns the summaries of all rules of the Debugger as printed by Dump.
+func (d *Debugger) String() string {
+	var b strings.Builder
+	d.Dump(&b)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// GoString returns the number of rules, in total and by module, on a single line, so %#v does not print the
+// internal state of the Debugger. It counts the rules Dump writes, including the instantiated fallback rules.
+func (d *Debugger) GoString() string {
+	all := d.describedRules()
+	var parts []string
+	for i := 0; i < len(all); {
+		j := i + 1
+		for j < len(all) && all[j].info.Module == all[i].info.Module {
+			j++
+		}
+		parts = append(parts, fmt.Sprintf("%q: %d", all[i].info.Module, j-i))
+		i = j
+	}
+	return fmt.Sprintf("&mklog.Debugger{rules: %d, modules: {%s}}", len(all), strings.Join(parts, ", "))
+}
+
+// describedRule is a rule of the Debugger as written by Dump.
+type describedRule struct {
+	info RuleInfo
+	rule *LogRule
+}
+
+// describedRules returns the rules of the Debugger, including the instantiated fallback rules, ordered by module
+// and index like Rules.
+func (d *Debugger) describedRules() []describedRule {
+	var all []describedRule
+	for module, rules := range d.rules() {
+		for i, v := range rules {
+			all = append(all, describedRule{RuleInfo{Module: module, Index: i}, v})
+		}
+	}
+	for module, v := range d.fallbackRules().snapshot(false) {
+		all = append(all, describedRule{RuleInfo{Module: module, Fallback: true}, v})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].info.Module != all[j].info.Module {
+			return all[i].info.Module < all[j].info.Module
+		}
+		return all[i].info.Index < all[j].info.Index
+	})
+	return all
+}
+
+// Dump writes the summary of every rule, see LogRule.String, to w ordered by module and index like Rules,
+// including the instantiated fallback rules. It returns the first error of w.
+func (d *Debugger) Dump(w io.Writer) error {
+	all := d.describedRules()
+	if _, err := fmt.Fprintf(w, "%d rules\n", len(all)); err != nil {
+		return err
+	}
+	for _, r := range all {
+		header := fmt.Sprintf("rule %s[%d]", r.info.Module, r.info.Index)
+		if r.info.Fallback {
+			header = fmt.Sprintf("fallback rule %s", r.info.Module)
+		}
+		body := "  " + strings.ReplaceAll(r.rule.String(), "\n", "\n  ")
+		if _, err := fmt.Fprintf(w, "%s\n%s\n", header, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
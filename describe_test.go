@@ -0,0 +1,98 @@
+package mklog
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+// namedSink is a sink discarding the entries, listed by its name.
+type namedSink struct{}
+
+func (namedSink) Write(entry Entry, formatted string) error { return nil }
+func (namedSink) Close() error                              { return nil }
+func (namedSink) Name() string                              { return "audit-db" }
+
+// checkGolden compares got with the golden file at path, rewriting it with -update.
+func checkGolden(t *testing.T, path, got string) {
+	t.Helper()
+	name := filepath.Base(path)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file:\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// TestDescribeGolden dumps a Debugger with several modules, a fallback rule and a shifted verbosity.
+func TestDescribeGolden(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetFallbackRule(WithFileWriter(io.Discard), WithLogFormatter(&JSONFormatter{}))
+	d.NewLogRule("api",
+		WithMaxLevel(FatalLevel),
+		WithLogFormatter(&PlainTextFormatter{}),
+		WithFileWriter(io.Discard),
+		WithAsyncLog(true, 128),
+		WithAsyncBatchSize(16),
+	)
+	d.NewLogRule("api",
+		WithMinLevel(ErrorLevel),
+		WithMaxLevel(FatalLevel),
+		WithLogFormatter(&LogfmtFormatter{}),
+		WithConsoleOutput(true),
+		WithFileLogging("logs", "api-errors", ".log"),
+		WithMaxFileSize(1<<20),
+		WithMaxBackups(3),
+	)
+	d.NewLogRule("db",
+		WithVerbosity(TraceLevel),
+		WithMinLevel(DebugLevel),
+		WithLogFormatter(&JSONFormatter{}),
+		WithSink(namedSink{}),
+		func(lr *LogRule) { lr.Submodules = []string{"pool", "migrations"} },
+	)
+	d.rules()["db"][0].Disable()
+	d.Module("billing").Info("instantiates the fallback rule")
+
+	var b strings.Builder
+	if err := d.Dump(&b); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, filepath.Join(testdata, "dump.golden"), b.String())
+	checkGolden(t, filepath.Join(testdata, "gostring.golden"), d.GoString()+"\n"+d.rules()["db"][0].GoString()+"\n")
+	// GoString counts the rules Dump writes, including the fallback rule.
+	count := strings.SplitN(b.String(), " ", 2)[0]
+	if !strings.HasPrefix(d.GoString(), "&mklog.Debugger{rules: "+count+",") {
+		t.Errorf("GoString %s does not count the %s rules of Dump", d.GoString(), count)
+	}
+
+	d.BumpVerbosity(1)
+	checkGolden(t, filepath.Join(testdata, "dump_shifted.golden"), d.String()+"\n")
+
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
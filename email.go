@@ -0,0 +1,302 @@
+package mklog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+var (
+	// Default settings for email notifications
+	MKLOG_EmailSubjectDefault    = "[mklog] {{.Count}} log entries, highest level {{.Level.GetLogLevelName}}" // Default subject template
+	MKLOG_EmailMaxPendingDefault = 1000                                                                       // Default number of entries kept until the next digest
+	MKLOG_EmailTimeoutDefault    = 30 * time.Second                                                           // Default limit of connecting to the server and delivering one email
+)
+
+// EmailConfig configures the SMTP connection and the messages sent by EmailNotifier.
+type EmailConfig struct {
+	Host     string        `yaml:"host" json:"host"`         // SMTP server host.
+	Port     int           `yaml:"port" json:"port"`         // SMTP server port.
+	Username string        `yaml:"username" json:"username"` // User name for plain auth, no auth when empty.
+	Password string        `yaml:"password" json:"password"` // Password for plain auth.
+	From     string        `yaml:"from" json:"from"`         // Sender address.
+	To       []string      `yaml:"to" json:"to"`             // Recipient addresses.
+	Subject  string        `yaml:"subject" json:"subject"`   // Subject template receiving the EmailDigest.
+	TLS      bool          `yaml:"tls" json:"tls"`           // Use implicit TLS instead of STARTTLS.
+	Digest   time.Duration `yaml:"digest" json:"digest"`     // Window collecting entries into one email, zero sends each entry separately.
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`   // Limit of connecting and delivering one email, MKLOG_EmailTimeoutDefault when zero.
+}
+
+// EmailDigest is the data passed to the subject template of EmailNotifier.
+type EmailDigest struct {
+	Entries []Entry  // Entries collected for the email.
+	Count   int      // Number of collected entries.
+	Level   LogLevel // Highest level among the collected entries.
+}
+
+// EmailNotifier is a Hook sending log entries by email.
+// In digest mode entries are collected over the digest window and sent as a single email,
+// so a burst of errors results in at most one email per window. Emails that fail to send
+// are reported to the error handler and retried with the next digest.
+type EmailNotifier struct {
+	config  EmailConfig
+	subject *template.Template
+	levels  []LogLevel
+
+	mu           sync.Mutex     // Guards pending, closed and errorHandler
+	pending      []Entry        // Entries waiting for the next email
+	closed       bool           // Whether Close was called
+	errorHandler ErrorHandler   // Handler for failed sends
+	wake         chan struct{}  // Signals entries to send immediately when digest is disabled
+	done         chan struct{}  // Closed to stop the sending goroutine
+	closeOnce    sync.Once      // Guards closing of done
+	wg           sync.WaitGroup // Tracks the sending goroutine
+}
+
+// NewEmailNotifier creates a notifier emailing entries of the given levels.
+// Without levels Error and Fatal entries are sent.
+func NewEmailNotifier(config EmailConfig, levels ...LogLevel) (*EmailNotifier, error) {
+	if config.Host == "" || config.From == "" || len(config.To) == 0 {
+		return nil, fmt.Errorf("[mklog] email notifier requires host, from and to addresses")
+	}
+	if config.Port == 0 {
+		if config.TLS {
+			config.Port = 465
+		} else {
+			config.Port = 25
+		}
+	}
+	if config.Subject == "" {
+		config.Subject = MKLOG_EmailSubjectDefault
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = MKLOG_EmailTimeoutDefault
+	}
+
+	subject, err := template.New("subject").Parse(config.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("[mklog] failed to parse email subject template: %w", err)
+	}
+	if len(levels) == 0 {
+		levels = []LogLevel{ErrorLevel, FatalLevel}
+	}
+
+	n := &EmailNotifier{
+		config:  config,
+		subject: subject,
+		levels:  levels,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	n.wg.Add(1)
+	go n.run()
+	return n, nil
+}
+
+// Levels returns the log levels the notifier is fired for.
+func (n *EmailNotifier) Levels() []LogLevel {
+	return n.levels
+}
+
+// Fire collects the entry for the next email without blocking; it fails if the queue is full or the notifier
+// is closed.
+func (n *EmailNotifier) Fire(entry Entry) error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return fmt.Errorf("email notifier is closed, entry dropped")
+	}
+	if len(n.pending) >= MKLOG_EmailMaxPendingDefault {
+		n.mu.Unlock()
+		return fmt.Errorf("email queue is full, entry dropped")
+	}
+	n.pending = append(n.pending, entry)
+	n.mu.Unlock()
+
+	if n.config.Digest <= 0 {
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close stops accepting entries and sends the ones collected so far, giving up on an unresponsive server after
+// the timeout of the configuration.
+func (n *EmailNotifier) Close() error {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+	n.closeOnce.Do(func() {
+		close(n.done)
+	})
+	n.wg.Wait()
+	return nil
+}
+
+// SetErrorHandler sets the handler receiving failed sends.
+func (n *EmailNotifier) SetErrorHandler(handler ErrorHandler) {
+	n.mu.Lock()
+	n.errorHandler = handler
+	n.mu.Unlock()
+}
+
+// run sends collected entries on every digest tick or wake up until the notifier is closed.
+func (n *EmailNotifier) run() {
+	defer n.wg.Done()
+
+	var tick <-chan time.Time
+	if n.config.Digest > 0 {
+		ticker := time.NewTicker(n.config.Digest)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			n.flush()
+		case <-n.wake:
+			n.flush()
+		case <-n.done:
+			n.flush()
+			return
+		}
+	}
+}
+
+// flush sends the pending entries, keeping them for the next attempt when sending fails.
+func (n *EmailNotifier) flush() {
+	n.mu.Lock()
+	entries := n.pending
+	n.pending = nil
+	n.mu.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+
+	if err := n.send(entries); err != nil {
+		n.mu.Lock()
+		n.pending = append(entries, n.pending...)
+		if len(n.pending) > MKLOG_EmailMaxPendingDefault {
+			n.pending = n.pending[len(n.pending)-MKLOG_EmailMaxPendingDefault:]
+		}
+		handler := n.errorHandler
+		n.mu.Unlock()
+
+		handler.Report(fmt.Errorf("[mklog] failed to send email notification: %w", err))
+	}
+}
+
+// send builds one email from the entries and delivers it.
+func (n *EmailNotifier) send(entries []Entry) error {
+	digest := EmailDigest{Entries: entries, Count: len(entries)}
+	for _, e := range entries {
+		if e.Level > digest.Level {
+			digest.Level = e.Level
+		}
+	}
+
+	var subject bytes.Buffer
+	if err := n.subject.Execute(&subject, digest); err != nil {
+		return fmt.Errorf("failed to render subject: %w", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subjectReplacer.Replace(subject.String()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	for _, e := range entries {
+		fmt.Fprintf(&msg, "%s | %s | [%s] : %s", e.Time.Format(GetDefaults().TimeLogFormat), e.LevelName, e.Module, e.Message)
+		if e.Err != nil {
+			fmt.Fprintf(&msg, " (%s)", e.Err)
+		}
+		msg.WriteString("\r\n")
+	}
+
+	addr := net.JoinHostPort(n.config.Host, strconv.Itoa(n.config.Port))
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+	}
+
+	return n.deliver(addr, auth, msg.Bytes())
+}
+
+// subjectReplacer folds the line breaks of a rendered subject, which would otherwise start new headers.
+var subjectReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// deliver sends the message over a connection limited by the timeout of the configuration, using implicit TLS
+// or STARTTLS when the server offers it.
+func (n *EmailNotifier) deliver(addr string, auth smtp.Auth, msg []byte) error {
+	dialer := &net.Dialer{Timeout: n.config.Timeout}
+	tlsConfig := &tls.Config{ServerName: n.config.Host}
+
+	var conn net.Conn
+	var err error
+	if n.config.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(n.config.Timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !n.config.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.config.From); err != nil {
+		return err
+	}
+	for _, to := range n.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,197 @@
+package mklog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts SMTP sessions on a local port and records the messages delivered to it.
+type fakeSMTPServer struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []string
+	silent   bool // Accept connections without ever answering
+}
+
+func newFakeSMTPServer(t *testing.T, silent bool) *fakeSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{ln: ln, silent: silent}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+// config returns an email configuration delivering to the server.
+func (s *fakeSMTPServer) config() EmailConfig {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return EmailConfig{Host: host, Port: p, From: "app@example.com", To: []string{"ops@example.com"}}
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		if s.silent {
+			go func() {
+				io.Copy(io.Discard, conn) // Until the client gives up.
+				conn.Close()
+			}()
+			continue
+		}
+		go s.session(conn)
+	}
+}
+
+// session answers the commands of one client with success until it quits.
+func (s *fakeSMTPServer) session(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// TestEmailNotifierDigest collects the entries of a digest window into one email and sends it on Close.
+func TestEmailNotifierDigest(t *testing.T) {
+	server := newFakeSMTPServer(t, false)
+	config := server.config()
+	config.Digest = time.Hour
+	config.Subject = "{{.Count}} entries\rBcc: attacker@example.com"
+
+	n, err := NewEmailNotifier(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 3, 9, 10, 20, 30, 0, time.Local)
+	for _, msg := range []string{"disk full", "write failed"} {
+		if err := n.Fire(Entry{Time: at, Level: ErrorLevel, LevelName: "ERROR", Module: "db", Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := n.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("got %d emails, want 1", len(messages))
+	}
+	msg := messages[0]
+	if !strings.Contains(msg, "Subject: 2 entries Bcc: attacker@example.com\r\n") {
+		t.Errorf("line breaks of the subject are not folded:\n%s", msg)
+	}
+	if strings.Contains(msg, "\r\nBcc:") {
+		t.Errorf("subject injected a header:\n%s", msg)
+	}
+	for _, want := range []string{
+		at.Format(GetDefaults().TimeLogFormat) + " | ERROR | [db] : disk full\r\n",
+		"[db] : write failed\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("email lacks %q:\n%s", want, msg)
+		}
+	}
+}
+
+// TestEmailNotifierFireAfterClose rejects entries fired after Close instead of losing them silently.
+func TestEmailNotifierFireAfterClose(t *testing.T) {
+	server := newFakeSMTPServer(t, false)
+	n, err := NewEmailNotifier(server.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Close()
+
+	if err := n.Fire(Entry{Level: ErrorLevel, Message: "late"}); err == nil {
+		t.Error("Fire after Close succeeded")
+	}
+}
+
+// TestEmailNotifierTimeout gives up on a server that accepts the connection but never answers.
+func TestEmailNotifierTimeout(t *testing.T) {
+	server := newFakeSMTPServer(t, true)
+	config := server.config()
+	config.Timeout = 100 * time.Millisecond
+
+	n, err := NewEmailNotifier(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 1)
+	n.SetErrorHandler(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	if err := n.Fire(Entry{Level: ErrorLevel, Message: "unsent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		n.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close hangs on an unresponsive server")
+	}
+	select {
+	case <-errs:
+	default:
+		t.Error("the failed send was not reported")
+	}
+}
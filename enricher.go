@@ -0,0 +1,85 @@
+package mklog
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Enricher adds application-wide data such as request IDs, feature flags or build info to an entry.
+// The context is the one passed to the *Ctx log methods and nil for the other methods. Enrichers run
+// for every entry accepted by a rule, so they must be cheap. The entry is reused once the log call returns,
+// so enrichers must not keep the pointer; the fields they add are kept.
+type Enricher func(ctx context.Context, e *Entry)
+
+// registeredEnricher is an enricher with the identity used to unregister it.
+type registeredEnricher struct {
+	id uint64
+	fn Enricher
+}
+
+var (
+	enrichersMu sync.Mutex                           // Serializes changes of the enrichers
+	enrichers   atomic.Pointer[[]registeredEnricher] // Enrichers in registration order, replaced on change
+	enricherSeq uint64                               // Identity of the last registered enricher
+)
+
+// RegisterEnricher adds the enricher to every Debugger instance of the process. Enrichers run in the
+// order of registration, before the middleware chain. The entry starts with empty fields the enrichers
+// add to; they are then merged with the other field sources, where the fields bound by handles and
+// those of the log call take precedence, see FieldCollisionPolicy. The returned function unregisters
+// the enricher.
+func RegisterEnricher(fn Enricher) (unregister func()) {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+
+	enricherSeq++
+	id := enricherSeq
+	var list []registeredEnricher
+	if current := enrichers.Load(); current != nil {
+		list = append(list, *current...)
+	}
+	list = append(list, registeredEnricher{id: id, fn: fn})
+	enrichers.Store(&list)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { removeEnricher(id) })
+	}
+}
+
+// removeEnricher unregisters the enricher with the identity.
+func removeEnricher(id uint64) {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+
+	current := enrichers.Load()
+	if current == nil {
+		return
+	}
+	var list []registeredEnricher
+	for _, e := range *current {
+		if e.id != id {
+			list = append(list, e)
+		}
+	}
+	if len(list) == 0 {
+		enrichers.Store(nil)
+		return
+	}
+	enrichers.Store(&list)
+}
+
+// runEnrichers passes the entry to the registered enrichers and returns the fields they added.
+func runEnrichers(ctx context.Context, entry *Entry) map[string]interface{} {
+	list := enrichers.Load()
+	if list == nil {
+		return nil
+	}
+
+	entry.Fields = make(map[string]interface{})
+	for _, e := range *list {
+		e.fn(ctx, entry)
+	}
+	return entry.Fields
+}
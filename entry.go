@@ -0,0 +1,74 @@
+package mklog
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry represents a single log event as it is passed to hooks.
+type Entry struct {
+	Time       time.Time // Time when the entry was logged.
+	Level      LogLevel  // Log level of the entry.
+	LevelName  string    // Display name of the log level for the matching rule.
+	Module     string    // Name of the module of the matching rule.
+	Submodules []string  // Submodules of the matching rule.
+	Message    string    // Log message with the arguments applied.
+	Err        error     // First error found among the arguments, if any.
+
+	Fields map[string]interface{} // Structured fields attached to the entry.
+	Rule   RuleRef                // Rule the entry was dispatched to; zero for entries no rule accepted.
+	Seq    uint64                 // Position of the entry among the entries written by the rule, from 1; zero before.
+
+	skipConsole  bool // Set when another rule printed the entry to the console already, see WithConsoleOnce
+	forceConsole bool // Set when the log call passed ForceConsole
+}
+
+// EntryFormatter is an optional interface of log formatters receiving the whole entry, including the
+// numeric level next to its display name, so severities can be mapped even with custom level names.
+// Rules prefer it over FieldsFormatter and Format.
+type EntryFormatter interface {
+	// FormatEntry formats the entry with the timestamp already rendered in the rule's date format.
+	FormatEntry(entry Entry, timestamp string) string
+}
+
+// unknownLevel marks entries rebuilt from a display name that does not name a built-in level.
+const unknownLevel LogLevel = -1
+
+// entryFromNames rebuilds the entry of a Format or FormatFields call, resolving the level from its display name.
+func entryFromNames(logMessage string, logLevel string, moduleName string, submodules []string, fields map[string]interface{}) Entry {
+	level, err := StringToLogLevel(logLevel)
+	if err != nil {
+		level = unknownLevel
+	}
+	return Entry{
+		Level:      level,
+		LevelName:  logLevel,
+		Module:     moduleName,
+		Submodules: submodules,
+		Message:    logMessage,
+		Fields:     fields,
+	}
+}
+
+// QueuedEntry is an entry queued for the async consumer of a rule together with its formatted message,
+// so the consumer writes it exactly like a synchronous rule would, including the level of the entry.
+type QueuedEntry struct {
+	Entry Entry  // Entry as accepted by the rule; its time decides the file the message is written to
+	Text  string // Message formatted by the rule
+}
+
+// entryPool recycles the entries built for every rule accepting a message. Enrichers and dispatch receive them
+// by pointer, which would otherwise allocate an entry per rule and message; outputs, hooks, middleware, recorders
+// and async queues receive copies, so reusing an entry is invisible to them.
+var entryPool = sync.Pool{New: func() interface{} { return new(Entry) }}
+
+// acquireEntry returns an empty entry from the pool.
+func acquireEntry() *Entry {
+	return entryPool.Get().(*Entry)
+}
+
+// releaseEntry resets the entry, dropping its references, and returns it to the pool.
+func releaseEntry(e *Entry) {
+	*e = Entry{}
+	entryPool.Put(e)
+}
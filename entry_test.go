@@ -0,0 +1,76 @@
+package mklog
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// retainingHook keeps every entry it is fired for.
+type retainingHook struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (h *retainingHook) Levels() []LogLevel { return []LogLevel{InfoLevel} }
+func (h *retainingHook) Fire(entry Entry) error {
+	h.mu.Lock()
+	h.entries = append(h.entries, entry)
+	h.mu.Unlock()
+	return nil
+}
+
+// TestEntryPoolReuse logs from several goroutines to two rules while a hook keeps the entries, run with -race.
+// The kept entries must not change when the pooled entries they were copied from are reused.
+func TestEntryPoolReuse(t *testing.T) {
+	const workers, messages = 4, 250
+
+	hook := &retainingHook{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	d.NewLogRule("db", WithFileWriter(&syncBuffer{}))
+	d.AddHook(hook)
+
+	var wg sync.WaitGroup
+	for g := 0; g < workers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < messages; i++ {
+				d.With("worker", g, "n", i).Info("message %d of %d", i, g)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if len(hook.entries) != 2*workers*messages {
+		t.Fatalf("hook kept %d entries, want %d", len(hook.entries), 2*workers*messages)
+	}
+	seqs := map[string]map[uint64]bool{"app": {}, "db": {}}
+	for _, e := range hook.entries {
+		if want := fmt.Sprintf("message %v of %v", e.Fields["n"], e.Fields["worker"]); e.Message != want {
+			t.Fatalf("kept entry has message %q with fields %v, want %q", e.Message, e.Fields, want)
+		}
+		if e.Module != e.Rule.Module {
+			t.Fatalf("kept entry of module %q refers to rule of module %q", e.Module, e.Rule.Module)
+		}
+		if e.Seq == 0 || seqs[e.Module][e.Seq] {
+			t.Fatalf("kept entry of module %q has Seq %d, want a new one", e.Module, e.Seq)
+		}
+		seqs[e.Module][e.Seq] = true
+	}
+}
+
+// BenchmarkLogPooledEntry reports the allocations of a log call accepted by one rule, whose entry comes from
+// the entry pool.
+func BenchmarkLogPooledEntry(b *testing.B) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(io.Discard))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Info("message %d", i)
+	}
+}
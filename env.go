@@ -0,0 +1,72 @@
+package mklog
+
+import (
+	"os"
+	"strings"
+)
+
+var (
+	// Environment variable overriding the minimum level of all rules built by NewLogRule.
+	// MKLOG_LevelEnvVar + "_" + module, e.g. MKLOG_LEVEL_API, overrides the rules of one module and takes precedence.
+	MKLOG_LevelEnvVar = "MKLOG_LEVEL"
+)
+
+// DisableEnvOverride stops NewLogRule from applying the MKLOG_LEVEL environment variables,
+// e.g. for libraries that must not change their logging because of the environment of the application.
+func (d *Debugger) DisableEnvOverride() *Debugger {
+	d.noEnvOverride = true
+	return d
+}
+
+// DisableEnvOverride stops the rules loaded by the manager from applying the MKLOG_LEVEL environment variables.
+func (m *LogConfigManager) DisableEnvOverride() *LogConfigManager {
+	m.noEnvOverride = true
+	return m
+}
+
+// applyEnvLevel replaces the minimum level of the rule with the level from the environment, the module
+// variable taking precedence over the global one. A level below the Verbosity of the rule lowers the Verbosity
+// as well, so that e.g. MKLOG_LEVEL=debug logs the Debug entries of a rule built without debug mode.
+func (d *Debugger) applyEnvLevel(lr *LogRule) {
+	if d.noEnvOverride {
+		return
+	}
+
+	name := envLevelVar(lr.ModuleName)
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		name = MKLOG_LevelEnvVar
+		if value, ok = os.LookupEnv(name); !ok || value == "" {
+			return
+		}
+	}
+
+	level, err := StringToLogLevel(value)
+	if err != nil {
+		d.notice(lr, lr.ModuleName, WarningLevel, err, "[mklog] ignoring %s: %v", name, err)
+		return
+	}
+	if level != lr.MinLevel {
+		d.notice(lr, lr.ModuleName, InfoLevel, nil, "[mklog] %s=%s overrides the minimum level of %s (was %s)", name, value, lr.ModuleName, lr.MinLevel.GetLogLevelName())
+	}
+	lr.MinLevel = level
+	if level < lr.Verbosity {
+		lr.SetVerbosity(level)
+	}
+}
+
+// envLevelVar returns the environment variable overriding the level of the module,
+// the module name upper-cased with every character other than letters and digits replaced by "_".
+func envLevelVar(module string) string {
+	var sb strings.Builder
+	sb.WriteString(MKLOG_LevelEnvVar)
+	sb.WriteByte('_')
+	for _, r := range strings.ToUpper(module) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
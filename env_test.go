@@ -0,0 +1,142 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestEnvLevelGlobal lowers the level of every rule with MKLOG_LEVEL, also below the Verbosity of a rule built
+// without debug mode, and notes the override once per rule.
+func TestEnvLevelGlobal(t *testing.T) {
+	t.Setenv(MKLOG_LevelEnvVar, "debug")
+	rec := &errorRecorder{}
+	sink := &entrySink{}
+	d := &Debugger{}
+	d.SetErrorHandler(rec.handle)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithLogFormatter(&JSONFormatter{}), WithSink(sink))
+	defer d.Close(context.Background())
+
+	if !d.Enabled(DebugLevel) || d.Enabled(TraceLevel) {
+		t.Errorf("Enabled(Debug) = %v, Enabled(Trace) = %v, want true, false", d.Enabled(DebugLevel), d.Enabled(TraceLevel))
+	}
+	d.Debug("details")
+	d.Trace("more details")
+	if got := sink.messages(); len(got) != 1 || got[0] != "details" {
+		t.Errorf("sink messages = %q, want only the Debug entry", got)
+	}
+
+	var overrides int
+	for _, err := range rec.get() {
+		var n *Notice
+		if errors.As(err, &n) && strings.Contains(n.Message, "MKLOG_LEVEL=debug overrides") {
+			overrides++
+		}
+	}
+	if overrides != 1 {
+		t.Errorf("override notices = %d, want 1: %v", overrides, rec.get())
+	}
+}
+
+// TestEnvLevelModule prefers the variable of the module over the global one and leaves other modules to the
+// global variable.
+func TestEnvLevelModule(t *testing.T) {
+	t.Setenv(MKLOG_LevelEnvVar, "error")
+	t.Setenv(MKLOG_LevelEnvVar+"_BILLING_API", "trace")
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("billing-api", WithFileWriter(&syncBuffer{}), WithLogFormatter(&JSONFormatter{}))
+	d.NewLogRule("web", WithFileWriter(&syncBuffer{}), WithLogFormatter(&JSONFormatter{}))
+	defer d.Close(context.Background())
+
+	if !d.EnabledFor("billing-api", TraceLevel) {
+		t.Error("billing-api does not log Trace entries, want the module variable to apply")
+	}
+	if d.EnabledFor("web", WarningLevel) || !d.EnabledFor("web", ErrorLevel) {
+		t.Error("web logs below Error or not at Error, want the global variable to apply")
+	}
+}
+
+// TestEnvLevelRaise raises the minimum level of a rule in debug mode without touching its Verbosity.
+func TestEnvLevelRaise(t *testing.T) {
+	t.Setenv(MKLOG_LevelEnvVar, "warning")
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithLogFormatter(&JSONFormatter{}), WithVerbosity(TraceLevel))
+	defer d.Close(context.Background())
+
+	rule := d.rules()["app"][0]
+	if rule.MinLevel != WarningLevel || rule.Verbosity != TraceLevel {
+		t.Errorf("MinLevel = %v, Verbosity = %v, want Warning and Trace", rule.MinLevel, rule.Verbosity)
+	}
+	if d.Enabled(InfoLevel) {
+		t.Error("Enabled(Info) = true, want the raised level to apply")
+	}
+}
+
+// TestEnvLevelInvalid ignores an unknown level with a warning.
+func TestEnvLevelInvalid(t *testing.T) {
+	t.Setenv(MKLOG_LevelEnvVar, "loud")
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(rec.handle)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithLogFormatter(&JSONFormatter{}))
+	defer d.Close(context.Background())
+
+	if d.Enabled(DebugLevel) || !d.Enabled(InfoLevel) {
+		t.Error("the levels of the rule changed, want the invalid value ignored")
+	}
+	errs := rec.get()
+	var n *Notice
+	if len(errs) != 1 || !errors.As(errs[0], &n) || n.Level != WarningLevel || !strings.Contains(n.Message, "ignoring MKLOG_LEVEL") {
+		t.Errorf("errors = %v, want one warning about the ignored variable", errs)
+	}
+}
+
+// TestEnvLevelDisabled keeps the levels of the code for Debuggers and managers opting out.
+func TestEnvLevelDisabled(t *testing.T) {
+	t.Setenv(MKLOG_LevelEnvVar, "trace")
+	d := (&Debugger{}).SetQuiet(true).DisableEnvOverride()
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithLogFormatter(&JSONFormatter{}))
+	defer d.Close(context.Background())
+	if d.Enabled(DebugLevel) {
+		t.Error("Debugger with DisableEnvOverride logs Debug entries")
+	}
+
+	path := writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      console_enable: true
+      log_formatter: {type: json}
+`)
+	loaded, err := NewLogConfigManager().SetQuiet(true).DisableEnvOverride().LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close(context.Background())
+	if loaded.Enabled(DebugLevel) {
+		t.Error("Debugger loaded with DisableEnvOverride logs Debug entries")
+	}
+}
+
+// TestEnvLevelLoadConfig applies the module variable to the rules loaded from a configuration.
+func TestEnvLevelLoadConfig(t *testing.T) {
+	t.Setenv(MKLOG_LevelEnvVar+"_APP", "debug")
+	path := writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      console_enable: true
+      log_formatter: {type: json}
+`)
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	if !d.Enabled(DebugLevel) {
+		t.Error("Enabled(Debug) = false, want MKLOG_LEVEL_APP to apply to the loaded rule")
+	}
+}
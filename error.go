@@ -1,17 +1,45 @@
 package mklog
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
+// ModulePath is the import path of the mklog module, skipped by default when the caller of an error is determined.
+const ModulePath = "github.com/SHEP4RDO/mklog"
+
+var (
+	callerSkipMu       sync.RWMutex
+	callerSkipPackages = []string{ModulePath} // Package path prefixes skipped when looking for the caller
+)
+
+// SetCallerSkipPackages replaces the package path prefixes whose frames NewDetailedError skips when it
+// determines the function, file and line of an error, e.g. to skip the error helper packages of an
+// application. A prefix matches the package itself and the packages below it.
+func SetCallerSkipPackages(prefixes ...string) {
+	callerSkipMu.Lock()
+	callerSkipPackages = append([]string(nil), prefixes...)
+	callerSkipMu.Unlock()
+}
+
+// AddCallerSkipPackages adds package path prefixes to those skipped by NewDetailedError.
+func AddCallerSkipPackages(prefixes ...string) {
+	callerSkipMu.Lock()
+	callerSkipPackages = append(callerSkipPackages[:len(callerSkipPackages):len(callerSkipPackages)], prefixes...)
+	callerSkipMu.Unlock()
+}
+
 // DetailedError represents an error with additional information about the stack trace.
 type DetailedError struct {
 	Err              error     // Original error.
 	StackInfo        string    // Stack trace information.
+	OriginStack      string    // Stack trace carried by the wrapped error, e.g. one created with github.com/pkg/errors.
 	Time             time.Time // Time when the error occurred.
 	FunctionName     string    // Name of the function that caused the error.
 	CallingArguments string    // Arguments passed to the function.
@@ -20,12 +48,117 @@ type DetailedError struct {
 }
 
 // NewDetailedError creates a new DetailedError, capturing the original error and contextual information.
+// The function, file and line are those of the first caller outside the packages set with SetCallerSkipPackages,
+// so errors created through helper packages point at the code of interest.
 func NewDetailedError(err error, args ...interface{}) DetailedError {
-	stackInfo := getStackInfo()                                            // Gather stack trace information.
-	functionName, file, line, callingArguments := getFunctionInfo(args...) // Get function info and arguments.
+	return newDetailedError(err, findCaller(0), 0, args...)
+}
+
+// NewDetailedErrorSkip creates a new DetailedError like NewDetailedError, taking the function, file and line
+// from the frame skip levels above the caller of NewDetailedErrorSkip instead of skipping packages;
+// a skip of 0 reports the caller itself.
+func NewDetailedErrorSkip(skip int, err error, args ...interface{}) DetailedError {
+	frame, ok := callerFrame(skip + 2)
+	if !ok {
+		frame = runtime.Frame{}
+	}
+	return newDetailedError(err, frame, 0, args...)
+}
+
+// NewPanicError creates a DetailedError for the value recovered from a panic, to be called from the deferred
+// function recovering it. Its stack starts at the function that panicked, which is reported as the origin, and
+// ends before the first frame whose function name starts with boundary, e.g. the middleware installing the
+// deferred function, leaving out the runtime panic frames and the frames of the server calling the middleware.
+func NewPanicError(v interface{}, boundary string, args ...interface{}) DetailedError {
+	err, ok := v.(error)
+	if !ok {
+		err = fmt.Errorf("%v", v)
+	}
+
+	const depth = 64
+	var pcs [depth]uintptr
+	n := runtime.Callers(2, pcs[:]) // Skip runtime.Callers and NewPanicError.
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var (
+		sb       strings.Builder
+		origin   runtime.Frame
+		panicked bool
+	)
+	sb.WriteString("Stack Trace:\n")
+	for {
+		frame, more := frames.Next()
+		if !panicked {
+			// The frames up to runtime.gopanic belong to the recovery itself.
+			panicked = frame.Function == "runtime.gopanic"
+		} else if boundary != "" && strings.HasPrefix(frame.Function, boundary) {
+			break
+		} else if !strings.HasPrefix(frame.Function, "runtime.") {
+			if origin.PC == 0 {
+				origin = frame
+			}
+			sb.WriteString(fmt.Sprintf("  %s:%d %s\n", filepath.Base(frame.File), frame.Line, frame.Function))
+		}
+		if !more {
+			break
+		}
+	}
+
+	functionName, file, line, callingArguments := getFunctionInfo(origin, args...)
+	return DetailedError{
+		Err:              err,
+		StackInfo:        sb.String(),
+		Time:             time.Now(),
+		FunctionName:     functionName,
+		CallingArguments: callingArguments,
+		File:             file,
+		Line:             line,
+	}
+}
+
+// Wrap returns the error as a DetailedError for an entry at the level. The stack and the caller are only
+// captured when a rule accepting the level renders them, see WithStackAtLevel, so wrapping errors logged
+// at lower levels does not pay for runtime.Callers. A nil err gives a DetailedError without an error.
+func (d *Debugger) Wrap(level LogLevel, err error, args ...interface{}) DetailedError {
+	if err == nil || !d.capturesStack(level) {
+		return DetailedError{Err: err, Time: time.Now()}
+	}
+	return newDetailedError(err, findCaller(0), 0, args...)
+}
+
+// capturesStack reports whether any rule accepting the level renders the stack of a DetailedError.
+func (d *Debugger) capturesStack(level LogLevel) bool {
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			if v.shouldLog(level) && v.renderStack(level) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderStack reports whether the rule renders the stack of a DetailedError for an entry at the level.
+func (lr *LogRule) renderStack(level LogLevel) bool {
+	return lr.DetailedErrorOutput && level >= lr.StackAtLevel
+}
+
+// newDetailedError creates a new DetailedError reporting the frame as the origin of the error.
+// The first wrappers frames outside the skipped packages are left out of the stack, see Logger.WithCallerSkip.
+// When the error already carries a stack, it is rendered first as the origin of the error,
+// followed by the stack of the logging site.
+func newDetailedError(err error, frame runtime.Frame, wrappers int, args ...interface{}) DetailedError {
+	stackInfo := getStackInfo(wrappers) // Gather stack trace information.
+	originStack := OriginStack(err)
+	if originStack != "" {
+		stackInfo = "Stack Trace (origin):\n" + originStack +
+			"Stack Trace (logged at):\n" + strings.TrimPrefix(stackInfo, "Stack Trace:\n")
+	}
+	functionName, file, line, callingArguments := getFunctionInfo(frame, args...) // Get function info and arguments.
 	return DetailedError{
 		Err:              err,
 		StackInfo:        stackInfo,
+		OriginStack:      originStack,
 		Time:             time.Now(),
 		FunctionName:     functionName,
 		CallingArguments: callingArguments,
@@ -45,16 +178,21 @@ func (de DetailedError) ErrorStack() string {
 		de.StackInfo)
 }
 
-// Error returns the string representation of the original error.
+// Error returns the string representation of the original error, "<nil>" when there is none, e.g. for a
+// nil error passed to Wrap.
 func (de DetailedError) Error() string {
+	if de.Err == nil {
+		return "<nil>"
+	}
 	return de.Err.Error()
 }
 
-// getStackInfo collects stack trace information for the current goroutine.
-func getStackInfo() string {
+// getStackInfo collects stack trace information for the current goroutine, leaving out the first
+// wrappers frames outside the runtime and the skipped packages.
+func getStackInfo(wrappers int) string {
 	const depth = 32
 	var pcs [depth]uintptr
-	n := runtime.Callers(3, pcs[:]) // Skip 3 levels to start from the actual call.
+	n := runtime.Callers(4, pcs[:]) // Skip 4 levels to start from the actual call.
 	frames := runtime.CallersFrames(pcs[:n])
 
 	var sb strings.Builder
@@ -62,8 +200,12 @@ func getStackInfo() string {
 
 	for {
 		frame, more := frames.Next()
-		// Exclude Go system functions and mklog functions.
-		if !strings.Contains(frame.Function, "runtime.") && !strings.Contains(frame.Function, "mklog.") {
+		// Exclude Go system functions, the skipped packages and the wrappers.
+		switch {
+		case skipFrame(frame):
+		case wrappers > 0:
+			wrappers--
+		default:
 			sb.WriteString(fmt.Sprintf("  %s:%d %s\n", filepath.Base(frame.File), frame.Line, frame.Function))
 		}
 		if !more {
@@ -74,15 +216,160 @@ func getStackInfo() string {
 	return sb.String()
 }
 
-// getFunctionInfo returns information about the function, arguments, file, and line of the call.
-func getFunctionInfo(args ...interface{}) (string, string, int, string) {
-	pc, file, line, ok := runtime.Caller(2) // Get information about the calling function.
-	if !ok {
+// OriginStack returns the stack trace carried by the error chain, one frame per line, or an empty string.
+// Errors exposing the stack with a StackTrace method, like those of github.com/pkg/errors, are preferred;
+// otherwise the stack printed by the %+v verb of an error implementing fmt.Formatter is used.
+// The innermost stack of the chain is returned, as it points closest to where the error was created.
+func OriginStack(err error) string {
+	var origin string
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if pcs, ok := stackTrace(e); ok && len(pcs) > 0 {
+			origin = formatFrames(pcs)
+		}
+	}
+	if origin != "" || err == nil {
+		return origin
+	}
+
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if stack := formattedStack(e); stack != "" {
+			origin = stack
+		}
+	}
+	return origin
+}
+
+// stackTrace returns the program counters of an error with a StackTrace method returning a slice of
+// uintptr based frames, e.g. errors.StackTrace of github.com/pkg/errors, without depending on the package.
+func stackTrace(err error) ([]uintptr, bool) {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() {
+		return nil, false
+	}
+	t := method.Type()
+	if t.NumIn() != 0 || t.NumOut() != 1 || t.Out(0).Kind() != reflect.Slice || t.Out(0).Elem().Kind() != reflect.Uintptr {
+		return nil, false
+	}
+
+	frames := method.Call(nil)[0]
+	pcs := make([]uintptr, frames.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(frames.Index(i).Uint())
+	}
+	return pcs, true
+}
+
+// formatFrames renders the program counters like getStackInfo, skipping the runtime and the skipped packages.
+func formatFrames(pcs []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" && !skipFrame(frame) {
+			sb.WriteString(fmt.Sprintf("  %s:%d %s\n", filepath.Base(frame.File), frame.Line, frame.Function))
+		}
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// formattedStack returns the stack an error implementing fmt.Formatter prints after its message with the
+// %+v verb, indented like getStackInfo, or an empty string when the verb adds nothing to the message.
+func formattedStack(err error) string {
+	if _, ok := err.(fmt.Formatter); !ok {
+		return ""
+	}
+	msg := err.Error()
+	verbose := fmt.Sprintf("%+v", err)
+	if verbose == msg || !strings.HasPrefix(verbose, msg) {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.Trim(verbose[len(msg):], "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// findCaller returns the first frame of the current goroutine outside the runtime and the skipped packages,
+// after leaving out wrappers such frames, e.g. those of the logging façade of an application.
+func findCaller(wrappers int) runtime.Frame {
+	const depth = 32
+	var pcs [depth]uintptr
+	n := runtime.Callers(3, pcs[:]) // Skip runtime.Callers, findCaller and NewDetailedError.
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var first, found runtime.Frame
+	for i := 0; ; i++ {
+		frame, more := frames.Next()
+		if i == 0 {
+			first = frame
+		}
+		if !skipFrame(frame) {
+			if wrappers == 0 {
+				return frame
+			}
+			wrappers--
+			found = frame
+		}
+		if !more {
+			if found.PC != 0 {
+				return found // Fewer frames than wrappers to skip, report the outermost one.
+			}
+			return first // Every frame is skipped, report the direct caller.
+		}
+	}
+}
+
+// callerFrame returns the frame skip levels above the caller of callerFrame.
+func callerFrame(skip int) (runtime.Frame, bool) {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+1, pcs[:]) == 0 {
+		return runtime.Frame{}, false
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	return frame, true
+}
+
+// skipFrame reports whether the frame belongs to the runtime or to one of the skipped packages.
+func skipFrame(frame runtime.Frame) bool {
+	pkg := funcPackage(frame.Function)
+	if pkg == "runtime" || strings.HasPrefix(pkg, "runtime/") {
+		return true
+	}
+
+	callerSkipMu.RLock()
+	defer callerSkipMu.RUnlock()
+	for _, prefix := range callerSkipPackages {
+		if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// funcPackage returns the package path of a fully qualified function name,
+// e.g. "github.com/SHEP4RDO/mklog" for "github.com/SHEP4RDO/mklog.(*Debugger).Info".
+func funcPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// getFunctionInfo returns information about the function, arguments, file, and line of the frame.
+func getFunctionInfo(frame runtime.Frame, args ...interface{}) (string, string, int, string) {
+	if frame.PC == 0 {
 		return "unknown", "unknown", 0, ""
 	}
-	function := runtime.FuncForPC(pc)
-	if function == nil {
-		return "unknown", filepath.Base(file), line, ""
+	if frame.Function == "" {
+		return "unknown", filepath.Base(frame.File), frame.Line, ""
 	}
-	return function.Name(), filepath.Base(file), line, fmt.Sprintf("%v", args) // Format the arguments into a string.
+	return frame.Function, filepath.Base(frame.File), frame.Line, fmt.Sprintf("%v", args) // Format the arguments into a string.
 }
@@ -0,0 +1,228 @@
+package mklog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/SHEP4RDO/mklog"
+	"github.com/SHEP4RDO/mklog/testdata/errwrap"
+)
+
+// callerLine returns the line its caller calls it from.
+func callerLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+// TestDetailedErrorCallerThroughWrappers reports the test file as the origin of errors created directly and
+// through one and two layers of an error helper package below the module path.
+func TestDetailedErrorCallerThroughWrappers(t *testing.T) {
+	err := errors.New("boom")
+	direct, directLine := mklog.NewDetailedError(err), callerLine()
+	once, onceLine := errwrap.Wrap(err), callerLine()
+	twice, twiceLine := errwrap.WrapTwice(err), callerLine()
+
+	for _, tt := range []struct {
+		name string
+		de   mklog.DetailedError
+		line int
+	}{
+		{"direct", direct, directLine},
+		{"one wrapper", once, onceLine},
+		{"two wrappers", twice, twiceLine},
+	} {
+		if tt.de.File != "error_test.go" || tt.de.Line != tt.line {
+			t.Errorf("%s: reported %s:%d, want error_test.go:%d", tt.name, tt.de.File, tt.de.Line, tt.line)
+		}
+		if tt.de.FunctionName != "github.com/SHEP4RDO/mklog_test.TestDetailedErrorCallerThroughWrappers" {
+			t.Errorf("%s: function %s", tt.name, tt.de.FunctionName)
+		}
+	}
+}
+
+// TestDetailedErrorCallerSkipPackages reports the helper package once it is no longer skipped, and the test
+// file again when it is added back.
+func TestDetailedErrorCallerSkipPackages(t *testing.T) {
+	defer mklog.SetCallerSkipPackages(mklog.ModulePath)
+
+	mklog.SetCallerSkipPackages()
+	if de := errwrap.WrapTwice(errors.New("boom")); de.File != "errwrap.go" {
+		t.Errorf("without skipped packages reported %s:%d, want errwrap.go", de.File, de.Line)
+	}
+
+	mklog.AddCallerSkipPackages(mklog.ModulePath + "/testdata")
+	de, line := errwrap.WrapTwice(errors.New("boom")), callerLine()
+	if de.File != "error_test.go" || de.Line != line {
+		t.Errorf("with the helper skipped reported %s:%d, want error_test.go:%d", de.File, de.Line, line)
+	}
+}
+
+// wrapSkip creates a DetailedError reporting the caller of wrapSkip.
+func wrapSkip(err error) mklog.DetailedError {
+	return mklog.NewDetailedErrorSkip(1, err)
+}
+
+// TestNewDetailedErrorSkip reports the frame the given number of levels above the caller.
+func TestNewDetailedErrorSkip(t *testing.T) {
+	de, line := mklog.NewDetailedErrorSkip(0, errors.New("boom")), callerLine()
+	if de.File != "error_test.go" || de.Line != line {
+		t.Errorf("skip 0 reported %s:%d, want error_test.go:%d", de.File, de.Line, line)
+	}
+	de, line = wrapSkip(errors.New("boom")), callerLine()
+	if de.File != "error_test.go" || de.Line != line || de.FunctionName != "github.com/SHEP4RDO/mklog_test.TestNewDetailedErrorSkip" {
+		t.Errorf("skip 1 reported %s:%d in %s, want the test at line %d", de.File, de.Line, de.FunctionName, line)
+	}
+}
+
+// createPkgError returns an error carrying the stack of its creation and the line it
+// was created at.
+func createPkgError() (error, int) {
+	return errwrap.New("disk full"), callerLine()
+}
+
+// TestDetailedErrorPrefersOriginStack renders the stack an error was created with as the origin,
+// followed by the stack of the logging site, also when the error is wrapped again.
+func TestDetailedErrorPrefersOriginStack(t *testing.T) {
+	created, line := createPkgError()
+	origin := fmt.Sprintf("  error_test.go:%d github.com/SHEP4RDO/mklog_test.createPkgError\n", line)
+
+	for name, err := range map[string]error{
+		"plain":        created,
+		"wrapped":      errwrap.WithStack(created, "saving"),
+		"fmt wrapped":  fmt.Errorf("saving: %w", created),
+		"wrapped both": fmt.Errorf("request: %w", errwrap.WithMessage(created, "saving")),
+	} {
+		de := mklog.NewDetailedError(err)
+		if !strings.HasPrefix(de.OriginStack, origin) {
+			t.Errorf("%s: origin stack = %q, want it to start at %q", name, de.OriginStack, origin)
+		}
+		if !strings.HasPrefix(de.StackInfo, "Stack Trace (origin):\n"+origin) {
+			t.Errorf("%s: stack does not start with the origin:\n%s", name, de.StackInfo)
+		}
+		loggedAt := strings.Index(de.StackInfo, "Stack Trace (logged at):\n")
+		if loggedAt < 0 || !strings.Contains(de.StackInfo[loggedAt:], "TestDetailedErrorPrefersOriginStack") {
+			t.Errorf("%s: stack does not end with the logging site:\n%s", name, de.StackInfo)
+		}
+	}
+}
+
+// formattedError prints a stack of its own with the %+v verb, without a StackTrace method.
+type formattedError struct{}
+
+func (formattedError) Error() string { return "formatted" }
+
+func (e formattedError) Format(s fmt.State, verb rune) {
+	fmt.Fprint(s, e.Error())
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprint(s, "\nmain.load\n\t/src/main.go:12\n")
+	}
+}
+
+// TestOriginStackFormatter falls back to the stack an error prints with %+v and finds no stack on plain errors.
+func TestOriginStackFormatter(t *testing.T) {
+	if got, want := mklog.OriginStack(fmt.Errorf("load: %w", formattedError{})), "  main.load\n  /src/main.go:12\n"; got != want {
+		t.Errorf("OriginStack = %q, want %q", got, want)
+	}
+	if got := mklog.OriginStack(errors.New("plain")); got != "" {
+		t.Errorf("OriginStack of a plain error = %q", got)
+	}
+	if de := mklog.NewDetailedError(errors.New("plain")); strings.Contains(de.StackInfo, "(origin)") {
+		t.Errorf("plain error rendered with an origin:\n%s", de.StackInfo)
+	}
+}
+
+// TestLoggedDetailedErrorShowsOrigin writes the creation site of an error to the log of a rule with
+// detailed error output.
+func TestLoggedDetailedErrorShowsOrigin(t *testing.T) {
+	var out strings.Builder
+	d := (&mklog.Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", mklog.WithFileWriter(&out), mklog.WithDetailedErrorOutput(true))
+
+	created, line := createPkgError()
+	d.Error("save failed", mklog.NewDetailedError(errwrap.WithStack(created, "saving")))
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("error_test.go:%d github.com/SHEP4RDO/mklog_test.createPkgError", line); !strings.Contains(out.String(), want) {
+		t.Errorf("log does not name the origin %q:\n%s", want, out.String())
+	}
+}
+
+// TestWrapStackLevel captures the caller and the stack only for levels a rule renders them at, and wraps a
+// nil error without panicking.
+func TestWrapStackLevel(t *testing.T) {
+	d := (&mklog.Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", mklog.WithFileWriter(&strings.Builder{}), mklog.WithDetailedErrorOutput(true))
+	err := errors.New("boom")
+
+	if de := d.Wrap(mklog.InfoLevel, err); de.StackInfo != "" || de.File != "" || de.Err != err {
+		t.Errorf("Wrap at Info = %+v, want the error without a stack", de)
+	}
+	de, line := d.Wrap(mklog.ErrorLevel, err), callerLine()
+	if de.StackInfo == "" || de.File != "error_test.go" || de.Line != line {
+		t.Errorf("Wrap at Error reported %s:%d with stack %q, want error_test.go:%d", de.File, de.Line, de.StackInfo, line)
+	}
+	if got := d.Wrap(mklog.ErrorLevel, nil).Error(); got != "<nil>" {
+		t.Errorf("Wrap of a nil error = %q, want <nil>", got)
+	}
+}
+
+// BenchmarkWrap wraps an error for a rule logging from Info with detailed error output, below the stack level
+// of the rule, where the stack is skipped, and at it, where it is captured.
+func BenchmarkWrap(b *testing.B) {
+	d := (&mklog.Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", mklog.WithFileWriter(io.Discard), mklog.WithMinLevel(mklog.InfoLevel),
+		mklog.WithDetailedErrorOutput(true))
+	err := errors.New("boom")
+
+	for _, bm := range []struct {
+		name  string
+		level mklog.LogLevel
+	}{{"below stack level", mklog.InfoLevel}, {"at stack level", mklog.ErrorLevel}} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				d.Wrap(bm.level, err)
+			}
+		})
+	}
+}
+
+// appLogger is a logging wrapper of an application calling Wrap two frames below its callers.
+type appLogger struct {
+	log *mklog.Logger
+}
+
+func (a appLogger) Fail(err error) mklog.DetailedError { return a.wrap(err) }
+
+func (a appLogger) wrap(err error) mklog.DetailedError { return a.log.Wrap(mklog.ErrorLevel, err) }
+
+// TestWrapCallerSkipThroughWrapper reports the caller of a two-frame wrapper as the origin of the error, with
+// the skip set at once or added up by nested handles, and leaves the wrapper frames out of the stack.
+func TestWrapCallerSkipThroughWrapper(t *testing.T) {
+	d := (&mklog.Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", mklog.WithFileWriter(&strings.Builder{}), mklog.WithDetailedErrorOutput(true))
+	err := errors.New("boom")
+
+	for _, tt := range []struct {
+		name string
+		log  *mklog.Logger
+	}{
+		{"skip 2", d.WithCallerSkip(2)},
+		{"nested skips", d.WithCallerSkip(1).WithCallerSkip(1)},
+		{"kept by With", d.WithCallerSkip(2).With(mklog.Fields{"user": "42"})},
+	} {
+		de, line := appLogger{tt.log}.Fail(err), callerLine()
+		if de.File != "error_test.go" || de.Line != line || !strings.HasSuffix(de.FunctionName, "TestWrapCallerSkipThroughWrapper") {
+			t.Errorf("%s: reported %s:%d in %s, want error_test.go:%d", tt.name, de.File, de.Line, de.FunctionName, line)
+		}
+		if strings.Contains(de.StackInfo, "appLogger") {
+			t.Errorf("%s: stack holds the wrapper frames:\n%s", tt.name, de.StackInfo)
+		}
+	}
+}
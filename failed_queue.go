@@ -0,0 +1,121 @@
+package mklog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// failedQueue keeps the log file writes that failed, oldest first, until the file accepts writes again.
+type failedQueue struct {
+	mu       sync.Mutex
+	writes   []failedWrite // Failed writes, oldest first
+	capacity int           // Maximum number of kept writes
+	dropped  uint64        // Writes dropped because the queue was full
+	failing  bool          // Whether the failure of the file was reported and not yet its recovery
+}
+
+// failedWrite is a log file write kept for replay.
+type failedWrite struct {
+	logged time.Time // Time the message was logged, selecting the dated or rotated file
+	text   string    // Lines of the write with their line endings
+}
+
+// WithFailedEntryQueue keeps up to capacity log file writes that failed, e.g. while the file is locked by a
+// virus scanner, and writes them in their original order, with their original timestamps, before any newer
+// entry once the file accepts writes again. The queue is replayed by the next write and every retryInterval;
+// a zero interval replays it on the next write only. When the queue is full the oldest write is dropped and
+// counted in the RuleStats. A batch of the async consumer counts as one write.
+func WithFailedEntryQueue(capacity int, retryInterval time.Duration) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.FailedQueueSize = capacity
+		lr.FileLog.FailedRetryInterval = retryInterval
+	}
+}
+
+// scheduleFailedQueue creates the failed entry queue of the rule and registers the maintenance task replaying it.
+func (d *LogRule) scheduleFailedQueue(name string) {
+	if d.FileLog.FailedQueueSize <= 0 {
+		return
+	}
+	d.failed = &failedQueue{capacity: d.FileLog.FailedQueueSize}
+	if d.FileLog.FailedRetryInterval <= 0 || d.debugger == nil {
+		return
+	}
+	d.debugger.AddMaintenanceTask(name, d.FileLog.FailedRetryInterval, func(time.Time) error {
+		d.replayFailed()
+		return nil
+	})
+}
+
+// writeLog writes the message to the log file. With a failed entry queue, the queued writes are replayed
+// first and a failed write is queued instead of returning its error; the failure and the recovery of the
+// file are reported once each.
+func (d *LogRule) writeLog(logged time.Time, msg string) error {
+	q := d.failed
+	if q == nil {
+		return d.writeFile(logged, msg)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err := d.replayFailedLocked()
+	if err == nil {
+		err = d.writeFile(logged, msg)
+	}
+	if err != nil {
+		q.push(failedWrite{logged: logged, text: msg})
+		if !q.failing {
+			q.failing = true
+			d.deferError(fmt.Errorf("[mklog] writing to the log file of %s failed, queueing entries for retry: %w", d.ModuleName, err))
+		}
+	}
+	return nil
+}
+
+// replayFailed writes the queued writes of the rule to the log file.
+func (d *LogRule) replayFailed() {
+	defer d.reportDeferred() // Deferred first, so it runs after the queue is unlocked.
+	q := d.failed
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	d.replayFailedLocked()
+}
+
+// replayFailedLocked writes the queued writes in order, stopping at the first failure. The queue must be locked.
+func (d *LogRule) replayFailedLocked() error {
+	q := d.failed
+	replayed := 0
+	for len(q.writes) > 0 {
+		w := q.writes[0]
+		if err := d.writeFile(w.logged, w.text); err != nil {
+			return err
+		}
+		q.writes[0] = failedWrite{}
+		q.writes = q.writes[1:]
+		replayed++
+	}
+	if q.failing {
+		q.failing = false
+		d.deferError(fmt.Errorf("[mklog] log file of %s recovered, %d queued writes replayed, %d dropped",
+			d.ModuleName, replayed, q.dropped))
+	}
+	return nil
+}
+
+// push adds the write to the queue, dropping the oldest write when the queue is full.
+func (q *failedQueue) push(w failedWrite) {
+	if len(q.writes) >= q.capacity {
+		q.writes[0] = failedWrite{}
+		q.writes = q.writes[1:]
+		q.dropped++
+	}
+	q.writes = append(q.writes, w)
+}
+
+// stats returns the number of queued and dropped writes.
+func (q *failedQueue) stats() (queued int, dropped uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.writes), q.dropped
+}
@@ -0,0 +1,176 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockableWriter fails every write while it is locked, like a log file held by a virus scanner.
+type lockableWriter struct {
+	mu     sync.Mutex
+	locked bool
+	out    strings.Builder
+}
+
+func (w *lockableWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.locked {
+		return 0, io.ErrClosedPipe
+	}
+	return w.out.Write(p)
+}
+
+func (w *lockableWriter) setLocked(locked bool) {
+	w.mu.Lock()
+	w.locked = locked
+	w.mu.Unlock()
+}
+
+func (w *lockableWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.out.String()
+}
+
+// TestFailedEntryQueueReplay logs entries while the file is locked and expects them written before the first
+// entry after it is unlocked, in order and with the times they were logged at.
+func TestFailedEntryQueueReplay(t *testing.T) {
+	w := &lockableWriter{locked: true}
+	clock := newFakeClock(time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC))
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.SetErrorHandler(func(error) {})
+	d.NewLogRule("app", WithFileWriter(w), WithDateFormat("15:04:05"), WithFailedEntryQueue(10, 0))
+
+	var want []string
+	for i := 0; i < 5; i++ {
+		clock.Set(clock.Now().Add(time.Second))
+		d.Info("queued %d", i)
+		want = append(want, fmt.Sprintf("10:00:%02d | INFO | [app] : queued %d", i+1, i))
+	}
+	if stats := d.rules()["app"][0].Stats(); stats.RetryQueued != 5 {
+		t.Fatalf("queued %d writes, want 5", stats.RetryQueued)
+	}
+
+	w.setLocked(false)
+	clock.Set(clock.Now().Add(time.Minute))
+	d.Info("after unlock")
+	want = append(want, "10:01:05 | INFO | [app] : after unlock")
+
+	if got := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("file =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if stats := d.rules()["app"][0].Stats(); stats.RetryQueued != 0 || stats.RetryDropped != 0 {
+		t.Errorf("stats after replay: %d queued, %d dropped", stats.RetryQueued, stats.RetryDropped)
+	}
+}
+
+// TestFailedEntryQueueOverflow drops the oldest writes beyond the capacity and counts them.
+func TestFailedEntryQueueOverflow(t *testing.T) {
+	w := &lockableWriter{locked: true}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(func(error) {})
+	d.NewLogRule("app", WithFileWriter(w), WithFailedEntryQueue(3, 0))
+
+	for i := 0; i < 5; i++ {
+		d.Info("entry %d", i)
+	}
+	if stats := d.rules()["app"][0].Stats(); stats.RetryQueued != 3 || stats.RetryDropped != 2 {
+		t.Errorf("stats = %d queued, %d dropped, want 3 and 2", stats.RetryQueued, stats.RetryDropped)
+	}
+
+	w.setLocked(false)
+	d.Info("entry 5")
+	out := w.String()
+	for i := 0; i < 6; i++ {
+		if kept := strings.Contains(out, fmt.Sprintf("entry %d\n", i)); kept != (i >= 2) {
+			t.Errorf("entry %d kept = %v in\n%s", i, kept, out)
+		}
+	}
+}
+
+// TestFailedEntryQueueReplayOnClose writes the queued entries when the Debugger is closed after the file was
+// unlocked, leaving none unwritten.
+func TestFailedEntryQueueReplayOnClose(t *testing.T) {
+	w := &lockableWriter{locked: true}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(func(error) {})
+	d.NewLogRule("app", WithFileWriter(w), WithFailedEntryQueue(10, time.Hour))
+
+	for i := 0; i < 3; i++ {
+		d.Info("entry %d", i)
+	}
+	w.setLocked(false)
+	report, err := d.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rules) != 1 || report.Rules[0].Unwritten != 0 {
+		t.Errorf("close report = %+v, want no unwritten entries", report.Rules)
+	}
+	if got := strings.Count(w.String(), "\n"); got != 3 {
+		t.Errorf("wrote %d entries on Close, want 3:\n%s", got, w.String())
+	}
+}
+
+// TestFailedEntryQueueReopenedFile closes the real log file of a rule, logs entries while a directory keeps it
+// from being reopened, and expects every entry in the file, in order, once it is reopened.
+func TestFailedEntryQueueReopenedFile(t *testing.T) {
+	const n = 10
+	dir := t.TempDir()
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(func(error) {})
+	d.NewLogRule("app", WithFileLogging(dir, "app", ".log"), WithFailedEntryQueue(2*n, 0))
+	defer d.Close(context.Background())
+	d.Info("before")
+
+	name := filepath.Join(dir, "app.log")
+	writer := d.rules()["app"][0].fileWriter()
+	writer.mu.Lock()
+	writer.closeFile()
+	writer.mu.Unlock()
+	if err := os.Rename(name, name+".aside"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(name, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"before"}
+	for i := 0; i < n; i++ {
+		d.Info("queued %d", i)
+		want = append(want, fmt.Sprintf("queued %d", i))
+	}
+	if stats := d.rules()["app"][0].Stats(); stats.RetryQueued != n {
+		t.Fatalf("queued %d writes, want %d", stats.RetryQueued, n)
+	}
+
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(name+".aside", name); err != nil {
+		t.Fatal(err)
+	}
+	d.Info("after")
+	want = append(want, "after")
+
+	lines := strings.Split(strings.TrimSuffix(readFile(t, name), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("file holds %d lines, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, " : "+want[i]) {
+			t.Errorf("line %d = %q, want %q", i, line, want[i])
+		}
+	}
+	if stats := d.rules()["app"][0].Stats(); stats.RetryQueued != 0 || stats.RetryDropped != 0 {
+		t.Errorf("stats after replay: %d queued, %d dropped", stats.RetryQueued, stats.RetryDropped)
+	}
+}
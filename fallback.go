@@ -0,0 +1,128 @@
+package mklog
+
+import (
+	"strings"
+	"sync"
+)
+
+// ModulePlaceholder is replaced by the module name in the file path and file name of fallback rules.
+const ModulePlaceholder = "{module}"
+
+// fallbackRules instantiates the fallback rule of the Debugger for modules without rules.
+type fallbackRules struct {
+	mu       sync.Mutex
+	defined  bool                // Whether a template rule was set
+	opts     []Option            // Options of the template rule
+	strict   bool                // Report modules without rules instead of instantiating the template
+	rules    map[string]*LogRule // Instantiated rules by module
+	reported map[string]bool     // Modules reported in strict mode
+	closed   bool                // Whether the Debugger was closed, which stops instantiating rules
+}
+
+// SetFallbackRule defines a template rule instantiated on first use for every module without rules that is logged
+// to through Debugger.Module. The module name is substituted for ModulePlaceholder in the file path and file name,
+// so each module gets its own file:
+//
+//	d.SetFallbackRule(mklog.WithFileLogging("logs", "{module}", ".log"), mklog.WithConsoleOutput(true))
+//	d.Module("billing").Info("invoice sent") // written to logs/billing.log
+//
+// Instantiated rules are listed by Rules and closed with the Debugger; they only receive the entries of their
+// module. Calling SetFallbackRule again applies to modules logged to for the first time afterwards.
+func (d *Debugger) SetFallbackRule(opts ...Option) *Debugger {
+	f := d.fallbackRules()
+	f.mu.Lock()
+	f.defined = true
+	f.opts = append([]Option(nil), opts...)
+	f.strict = false
+	f.mu.Unlock()
+	return d
+}
+
+// SetStrictModules reports the first entry logged through Debugger.Module to a module without rules as a
+// warning Notice "no rule for module X" instead of instantiating the fallback rule. Later entries of the
+// module are dropped silently.
+func (d *Debugger) SetStrictModules(strict bool) *Debugger {
+	f := d.fallbackRules()
+	f.mu.Lock()
+	f.strict = strict
+	f.mu.Unlock()
+	return d
+}
+
+// fallbackRules returns the fallback rules of the Debugger, creating them on first use.
+func (d *Debugger) fallbackRules() *fallbackRules {
+	d.fallbackOnce.Do(func() {
+		d.fallback = &fallbackRules{rules: make(map[string]*LogRule), reported: make(map[string]bool)}
+	})
+	return d.fallback
+}
+
+// moduleRules returns the rules of the module by module, instantiating the fallback rule for a module without rules.
+func (d *Debugger) moduleRules(module string) map[string][]*LogRule {
+	if rules := d.rules()[module]; len(rules) > 0 {
+		return map[string][]*LogRule{module: rules}
+	}
+	if lr := d.fallbackRules().ruleFor(d, module); lr != nil {
+		return map[string][]*LogRule{module: {lr}}
+	}
+	return nil
+}
+
+// ruleFor returns the fallback rule of the module, instantiating it on first use. It returns nil without a
+// template, in strict mode and after the Debugger was closed.
+func (f *fallbackRules) ruleFor(d *Debugger, module string) *LogRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if lr := f.rules[module]; lr != nil {
+		return lr
+	}
+	if f.strict {
+		if !f.reported[module] {
+			f.reported[module] = true
+			d.notice(nil, module, WarningLevel, nil, "[mklog] no rule for module %s", module)
+		}
+		return nil
+	}
+	if !f.defined || f.closed {
+		return nil
+	}
+
+	lr := d.buildLogRule(module, f.opts...)
+	if usesModulePlaceholder(lr) {
+		name, err := checkModuleFileName(module, lr.FileLog.FileNamePolicy)
+		if err != nil {
+			// Keep the other outputs of the rule rather than writing outside the configured files.
+			lr.FileLog.Enable = false
+			d.notice(lr, module, ErrorLevel, err, "[mklog] file logging of the fallback rule disabled for module %s: %v", module, err)
+		}
+		lr.FileLog.FilePath = strings.ReplaceAll(lr.FileLog.FilePath, ModulePlaceholder, name)
+		lr.FileLog.FileName = strings.ReplaceAll(lr.FileLog.FileName, ModulePlaceholder, name)
+	}
+	f.rules[module] = lr
+	if err := d.startLogRule(lr, "fallback/"+module); err != nil {
+		d.notice(lr, module, ErrorLevel, err, "%v", err)
+	}
+	return lr
+}
+
+// usesModulePlaceholder reports whether the rule logs to files named after its module.
+func usesModulePlaceholder(lr *LogRule) bool {
+	return lr.FileLog.Enable &&
+		(strings.Contains(lr.FileLog.FilePath, ModulePlaceholder) || strings.Contains(lr.FileLog.FileName, ModulePlaceholder))
+}
+
+// snapshot returns the instantiated rules by module; with close set, no rules are instantiated afterwards.
+func (f *fallbackRules) snapshot(close bool) map[string]*LogRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if close {
+		f.closed = true
+	}
+	rules := make(map[string]*LogRule, len(f.rules))
+	for module, lr := range f.rules {
+		rules[module] = lr
+	}
+	return rules
+}
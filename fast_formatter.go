@@ -0,0 +1,84 @@
+package mklog
+
+import (
+	"sync"
+	"time"
+)
+
+// AppendFormatter is an optional interface of log formatters rendering the timestamp themselves.
+// Rules prefer it over Format, passing the time of the entry and the rule's date format,
+// which avoids formatting the timestamp into an intermediate string. The date format may be
+// UnixDateFormat or UnixMsDateFormat, which are not layouts of time.Time.Format.
+type AppendFormatter interface {
+	// AppendFormat appends the formatted log message to dst and returns the extended buffer.
+	AppendFormat(dst []byte, logMessage string, logLevel string, moduleName string, submodules []string, t time.Time, dateFormat string) []byte
+}
+
+// FastTextFormatter is a LogFormatter producing the same output as PlainTextFormatter
+// by appending directly into pooled byte slices instead of using fmt.
+type FastTextFormatter struct {
+	dateFormat         string
+	MultilineMode      string // Rendering of line breaks, MultilineRaw (default), MultilineEscape or MultilineIndent.
+	SubmoduleSeparator string // Separator of the submodule chain, MKLOG_SubmoduleSeparatorDefault when empty.
+}
+
+// formatBufferPool holds the buffers used by AppendFormatter implementations.
+var formatBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// Format formats the log message in plain text.
+func (f FastTextFormatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	bp := formatBufferPool.Get().(*[]byte)
+	b := append((*bp)[:0], timestamp...)
+	b = appendPlainText(b, logMessage, logLevel, moduleName, submodules, f.SubmoduleSeparator)
+	s := string(b)
+
+	*bp = b
+	formatBufferPool.Put(bp)
+	return s
+}
+
+// AppendFormat appends the log message in plain text, rendering the time with the date format.
+func (f FastTextFormatter) AppendFormat(dst []byte, logMessage string, logLevel string, moduleName string, submodules []string, t time.Time, dateFormat string) []byte {
+	dst = appendTimestamp(dst, t, dateFormat)
+	return appendPlainText(dst, logMessage, logLevel, moduleName, submodules, f.SubmoduleSeparator)
+}
+
+// appendPlainText appends everything following the timestamp in the PlainTextFormatter layout.
+func appendPlainText(b []byte, logMessage string, logLevel string, moduleName string, submodules []string, sep string) []byte {
+	b = append(b, " | "...)
+	b = append(b, logLevel...)
+	b = append(b, " | ["...)
+	b = append(b, moduleName...)
+	if len(submodules) > 0 {
+		if sep == "" {
+			sep = MKLOG_SubmoduleSeparatorDefault
+		}
+		b = append(b, "] - ["...)
+		for i, s := range submodules {
+			if i > 0 {
+				b = append(b, sep...)
+			}
+			b = append(b, s...)
+		}
+		b = append(b, "]: "...)
+	} else {
+		b = append(b, "] : "...)
+	}
+	return append(b, logMessage...)
+}
+
+// formatAppend renders the log message with the AppendFormatter using a pooled buffer.
+func formatAppend(f AppendFormatter, logMessage string, logLevel string, moduleName string, submodules []string, t time.Time, dateFormat string) string {
+	bp := formatBufferPool.Get().(*[]byte)
+	b := f.AppendFormat((*bp)[:0], logMessage, logLevel, moduleName, submodules, t, dateFormat)
+	s := string(b)
+
+	*bp = b
+	formatBufferPool.Put(bp)
+	return s
+}
@@ -0,0 +1,135 @@
+package mklog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fastTextCase is an entry rendered by both text formatters.
+type fastTextCase struct {
+	message    string
+	level      string
+	module     string
+	submodules []string
+	separator  string
+	dateFormat string
+	multiline  string
+}
+
+var fastTextCases = []fastTextCase{
+	{message: "server started", level: "INFO", module: "app", dateFormat: "2006-01-02 15:04:05"},
+	{message: "slow query", level: "WARN", module: "db", submodules: []string{"pool"}, dateFormat: time.RFC3339},
+	{message: "retry", level: "DEBUG", module: "db", submodules: []string{"pool", "conn"}, separator: "/", dateFormat: time.RFC3339Nano},
+	{message: "in kitchen", level: "TRACE", module: "app", dateFormat: time.Kitchen},
+	{message: "as seconds", level: "ERROR", module: "app", dateFormat: UnixDateFormat},
+	{message: "as milliseconds", level: "FATAL", module: "app", dateFormat: UnixMsDateFormat},
+	{message: "first\nsecond\r\nthird", level: "ERROR", module: "app", dateFormat: "2006-01-02T15:04:05.000Z07:00", multiline: MultilineEscape},
+	{message: "first\nsecond", level: "ERROR", module: "app", submodules: []string{"job"}, dateFormat: time.RFC3339, multiline: MultilineIndent},
+	{message: "", level: "CUSTOM", module: "", dateFormat: time.RFC3339},
+	{message: "unicode ✓ and 100% literal %s", level: "INFO", module: "ünï", dateFormat: time.RFC3339},
+}
+
+var fastTextTime = time.Date(2024, 3, 9, 10, 20, 30, 123456789, time.FixedZone("CET", 3600))
+
+// TestFastTextFormatterGolden renders entries with FastTextFormatter through Format and AppendFormat, byte for
+// byte like PlainTextFormatter, and compares them with the golden file.
+func TestFastTextFormatterGolden(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var golden strings.Builder
+	for _, c := range fastTextCases {
+		plain := PlainTextFormatter{MultilineMode: c.multiline, SubmoduleSeparator: c.separator}
+		fast := FastTextFormatter{MultilineMode: c.multiline, SubmoduleSeparator: c.separator}
+		timestamp := formatTimestamp(fastTextTime, c.dateFormat)
+
+		want := plain.FormatMultiline(plain.Format(c.message, c.level, c.module, c.submodules, timestamp))
+		formatted := fast.FormatMultiline(fast.Format(c.message, c.level, c.module, c.submodules, timestamp))
+		appended := fast.FormatMultiline(string(fast.AppendFormat(nil, c.message, c.level, c.module, c.submodules, fastTextTime, c.dateFormat)))
+		if formatted != want {
+			t.Errorf("Format = %q, want %q", formatted, want)
+		}
+		if appended != want {
+			t.Errorf("AppendFormat = %q, want %q", appended, want)
+		}
+		golden.WriteString(appended + "\n")
+	}
+	checkGolden(t, filepath.Join(wd, "testdata", "fasttext.golden"), golden.String())
+}
+
+// TestFastTextFormatterRuleOutput writes the same entries through a rule of each text formatter and expects
+// byte-identical log files, including the error details appended to the message.
+func TestFastTextFormatterRuleOutput(t *testing.T) {
+	clock := func() time.Time { return fastTextTime }
+	var plainOut, fastOut syncBuffer
+	withSubmodules := func(lr *LogRule) { lr.Submodules = []string{"http", "client"} }
+	plain := (&Debugger{}).SetQuiet(true).SetClock(clock)
+	plain.NewLogRule("app", WithFileWriter(&plainOut), WithLogFormatter(PlainTextFormatter{}), WithDateFormat(time.RFC3339Nano))
+	plain.NewLogRule("api", WithFileWriter(&plainOut), WithLogFormatter(PlainTextFormatter{}), withSubmodules)
+	fast := (&Debugger{}).SetQuiet(true).SetClock(clock)
+	fast.NewLogRule("app", WithFileWriter(&fastOut), WithLogFormatter(FastTextFormatter{}), WithDateFormat(time.RFC3339Nano))
+	fast.NewLogRule("api", WithFileWriter(&fastOut), WithLogFormatter(FastTextFormatter{}), withSubmodules)
+
+	for _, d := range []*Debugger{plain, fast} {
+		d.Info("request %d done", 42)
+		d.Warning("retrying")
+		d.Error("write failed: %v", errors.New("disk full"))
+	}
+	if plainOut.String() == "" || fastOut.String() != plainOut.String() {
+		t.Errorf("FastTextFormatter wrote\n%s\nPlainTextFormatter wrote\n%s", fastOut.String(), plainOut.String())
+	}
+}
+
+// TestFastTextFormatterAllocs appends an entry into a buffer with room for it without allocating.
+func TestFastTextFormatterAllocs(t *testing.T) {
+	f := FastTextFormatter{}
+	submodules := []string{"pool", "conn"}
+	buf := make([]byte, 0, 256)
+	for _, dateFormat := range []string{time.RFC3339Nano, UnixMsDateFormat} {
+		allocs := testing.AllocsPerRun(100, func() {
+			buf = f.AppendFormat(buf[:0], "slow query", "WARN", "db", submodules, fastTextTime, dateFormat)
+		})
+		if allocs != 0 {
+			t.Errorf("AppendFormat with %q allocates %v times, want 0", dateFormat, allocs)
+		}
+	}
+}
+
+// BenchmarkPlainTextFormatter formats an entry with PlainTextFormatter, rendering the timestamp first like a
+// rule does.
+func BenchmarkPlainTextFormatter(b *testing.B) {
+	f := PlainTextFormatter{}
+	submodules := []string{"pool", "conn"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f.Format("slow query", "WARN", "db", submodules, formatTimestamp(fastTextTime, time.RFC3339Nano))
+	}
+}
+
+// BenchmarkFastTextFormatter formats the same entry with FastTextFormatter the way a rule does, into a pooled
+// buffer copied into the message string.
+func BenchmarkFastTextFormatter(b *testing.B) {
+	f := FastTextFormatter{}
+	submodules := []string{"pool", "conn"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		formatAppend(f, "slow query", "WARN", "db", submodules, fastTextTime, time.RFC3339Nano)
+	}
+}
+
+// BenchmarkFastTextFormatterAppend appends the entry into a reused buffer, which does not allocate.
+func BenchmarkFastTextFormatterAppend(b *testing.B) {
+	f := FastTextFormatter{}
+	submodules := []string{"pool", "conn"}
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = f.AppendFormat(buf[:0], "slow query", "WARN", "db", submodules, fastTextTime, time.RFC3339Nano)
+	}
+}
@@ -0,0 +1,70 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// exitFunc terminates the process after a Fatal entry, replaced in tests.
+var exitFunc = os.Exit
+
+// SetOnFatal sets the callback invoked synchronously for every Fatal entry, e.g. to release locks
+// or notify an orchestrator before the process exits.
+//
+// Fatal proceeds in this order:
+//  1. the entry is written by every accepting rule, then passed to the rule's sinks and hooks;
+//  2. if an accepting rule has a fatal exit code (WithFatalExitCode), the Debugger is closed within
+//     MKLOG_CloseTimeoutDefault, draining async buffers and closing log files, sinks and the hooks implementing
+//     io.Closer, so that notifiers deliver the entry;
+//  3. the callback runs; a panic in it is reported to the error handler and does not stop the exit;
+//  4. the process exits with the fatal exit code.
+//
+// Without a fatal exit code, Fatal returns after the callback like any other log method.
+func (d *Debugger) SetOnFatal(fn func(Entry)) *Debugger {
+	d.onFatal = fn
+	return d
+}
+
+// handleFatal flushes the Debugger, runs the OnFatal callback and exits when a rule accepting the entry asks for it.
+func (d *Debugger) handleFatal(entry Entry) {
+	code, exit := d.fatalExitCode()
+	if exit {
+		ctx, cancel := context.WithTimeout(context.Background(), MKLOG_CloseTimeoutDefault)
+		if _, err := d.Close(ctx); err != nil {
+			d.handleError(err)
+		}
+		cancel()
+	}
+
+	d.runOnFatal(entry)
+
+	if exit {
+		exitFunc(code)
+	}
+}
+
+// fatalExitCode returns the exit code of the first rule accepting Fatal entries that has one.
+func (d *Debugger) fatalExitCode() (int, bool) {
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			if v.FatalExitCode != 0 && v.shouldLog(FatalLevel) {
+				return v.FatalExitCode, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// runOnFatal invokes the OnFatal callback, reporting a panic instead of propagating it.
+func (d *Debugger) runOnFatal(entry Entry) {
+	if d.onFatal == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			d.handleError(fmt.Errorf("[mklog] OnFatal callback panicked: %v", r))
+		}
+	}()
+	d.onFatal(entry)
+}
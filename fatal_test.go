@@ -0,0 +1,52 @@
+package mklog
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestFatalDeliversWebhookBeforeExit checks that the webhook alert of a Fatal entry is sent before the process exits.
+func TestFatalDeliversWebhookBeforeExit(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond) // A slow webhook is still waited for.
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		payloads = append(payloads, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	notifier, err := NewWebhookNotifier(server.URL, `{"text": {{json .Message}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithMaxLevel(FatalLevel), WithFileWriter(io.Discard), WithFatalExitCode(3))
+	d.AddHook(notifier)
+
+	exitCode := 0
+	defer func(exit func(int)) { exitFunc = exit }(exitFunc)
+	exitFunc = func(code int) {
+		exitCode = code
+		mu.Lock()
+		defer mu.Unlock()
+		if len(payloads) != 1 || !strings.Contains(payloads[0], "database lost") {
+			t.Errorf("got payloads %q at exit, want the Fatal entry", payloads)
+		}
+	}
+
+	d.Fatal("database lost")
+	if exitCode != 3 {
+		t.Errorf("got exit code %d, want 3", exitCode)
+	}
+	if err := notifier.Fire(Entry{Level: FatalLevel}); err == nil {
+		t.Error("notifier accepted an entry after Close")
+	}
+}
@@ -0,0 +1,143 @@
+package mklog
+
+// WithGroup returns a handle nesting the fields bound by it and by handles derived from it, as well as the
+// Fields of their log calls, under the group name, like the groups of log/slog, so the fields of different
+// subsystems stay apart even with the same key:
+//
+//	httpLog := d.WithGroup("http").With("method", r.Method, "id", reqID)
+//	httpLog.Info("request", mklog.Fields{"status": 200})
+//
+// JSON, YAML and CSV render groups as nested objects ({"http": {"id": ..., "method": ..., "status": 200}}),
+// plain text, logfmt and XML flatten them with dots (http.id=... http.method=... http.status=200). Static fields
+// and the fields of enrichers stay at the top level. Groups of the same name from several sources are merged
+// key by key with the collision policy of the Debugger; a group and a flat field of the same name collide like
+// two flat fields. An empty name returns an equivalent handle without a group.
+func (d *Debugger) WithGroup(name string) *Logger {
+	l := &Logger{debugger: d}
+	return l.WithGroup(name)
+}
+
+// WithGroup returns a handle with the fields of l nesting the fields bound by it and by handles derived from it
+// under the group name, inside the groups of l, see Debugger.WithGroup.
+func (l *Logger) WithGroup(name string) *Logger {
+	child := &Logger{debugger: l.debugger, parent: l, skip: l.skip, module: l.module, groups: l.groups}
+	if name != "" {
+		child.group = name
+		child.groups = append(append([]string(nil), l.groups...), name)
+	}
+	return child
+}
+
+// openGroup returns the group with the name in the fields to add fields to. An existing group is copied, as it
+// may be shared; a flat field of the same name is replaced, like a flat field of an inner handle would be.
+func openGroup(fields map[string]interface{}, name string) Fields {
+	group := Fields{}
+	if existing, ok := fields[name].(Fields); ok {
+		for k, v := range existing {
+			group[k] = v
+		}
+	}
+	fields[name] = group
+	return group
+}
+
+// groupCallFields nests the Fields among the arguments of a log call in the groups of the handle. The arguments
+// are returned unchanged without groups.
+func (l *Logger) groupCallFields(args []interface{}) []interface{} {
+	if len(l.groups) == 0 {
+		return args
+	}
+	var grouped []interface{}
+	for i, arg := range args {
+		f, ok := arg.(Fields)
+		if !ok {
+			continue
+		}
+		if grouped == nil {
+			grouped = append([]interface{}(nil), args...)
+		}
+		var v interface{} = f
+		for j := len(l.groups) - 1; j >= 0; j-- {
+			v = Fields{l.groups[j]: v}
+		}
+		grouped[i] = v
+	}
+	if grouped == nil {
+		return args
+	}
+	return grouped
+}
+
+// mergeGroups returns a new group with the fields of both groups, resolving keys set by both with the policy
+// like assembleFields does for top-level keys. Nested groups are merged the same way.
+func mergeGroups(first, second Fields, policy FieldCollisionPolicy) Fields {
+	merged := make(Fields, len(first)+len(second))
+	for k, v := range first {
+		merged[k] = v
+	}
+	for k, v := range second {
+		existing, exists := merged[k]
+		if !exists {
+			merged[k] = v
+			continue
+		}
+		if a, ok := existing.(Fields); ok {
+			if b, ok := v.(Fields); ok {
+				merged[k] = mergeGroups(a, b, policy)
+				continue
+			}
+		}
+		switch policy {
+		case FieldKeepFirst:
+		case FieldSuffixDuplicate:
+			merged[suffixedKey(merged, k)] = v
+		default:
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// flattenFields returns the fields with nested groups flattened into keys joined with dots, for the formatters
+// without nested values. The fields are returned unchanged when they hold no groups. A flat key wins over a
+// grouped field flattening to the same key.
+func flattenFields(fields map[string]interface{}) map[string]interface{} {
+	nested := false
+	for _, v := range fields {
+		if _, ok := groupValue(v); ok {
+			nested = true
+			break
+		}
+	}
+	if !nested {
+		return fields
+	}
+
+	flat := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if _, ok := groupValue(v); !ok {
+			flat[k] = v
+		}
+	}
+	for k, v := range fields {
+		if group, ok := groupValue(v); ok {
+			for gk, gv := range flattenFields(group) {
+				if _, exists := flat[k+"."+gk]; !exists {
+					flat[k+"."+gk] = gv
+				}
+			}
+		}
+	}
+	return flat
+}
+
+// groupValue returns the fields of a group value, also after normalizeFields turned it into a plain map.
+func groupValue(v interface{}) (map[string]interface{}, bool) {
+	switch group := v.(type) {
+	case Fields:
+		return group, true
+	case map[string]interface{}:
+		return group, true
+	}
+	return nil, false
+}
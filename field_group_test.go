@@ -0,0 +1,112 @@
+package mklog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestFieldGroupsTwoLevels nests the bound and call fields of a handle two groups deep, nesting them in JSON
+// and flattening them with dots in logfmt and plain text, while static fields stay at the top level.
+func TestFieldGroupsTwoLevels(t *testing.T) {
+	jsonOut, logfmtOut, plainOut := &syncBuffer{}, &syncBuffer{}, &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true).SetStaticFields("service", "api")
+	d.NewLogRule("app", WithFileWriter(jsonOut), WithLogFormatter(JSONFormatter{}))
+	d.NewLogRule("app", WithFileWriter(logfmtOut), WithLogFormatter(LogfmtFormatter{}))
+	d.NewLogRule("app", WithFileWriter(plainOut), WithDateFormat("-"))
+
+	http := d.WithGroup("http").With("method", "GET")
+	http.WithGroup("tls").With("version", "1.3").Info("handshake", Fields{"resumed": true})
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var data struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(jsonOut.String()), &data); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(data.Fields); got != "map[http:map[method:GET tls:map[resumed:true version:1.3]] service:api]" {
+		t.Errorf("json fields = %s", got)
+	}
+	for name, out := range map[string]string{"logfmt": logfmtOut.String(), "plain": plainOut.String()} {
+		for _, want := range []string{"service=api", "http.method=GET", "http.tls.version=1.3", "http.tls.resumed=true"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s = %q, want %s", name, out, want)
+			}
+		}
+	}
+}
+
+// TestFieldGroupsCollisions keeps the same key in different groups apart, lets a group and a flat field of the
+// same name collide like two flat fields and merges the groups of the same name key by key.
+func TestFieldGroupsCollisions(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy FieldCollisionPolicy
+		static []interface{}
+		log    func(d *Debugger)
+		want   string
+	}{
+		{"same key in two groups", FieldOverride, nil, func(d *Debugger) {
+			d.WithGroup("http").With("id", "r1").Info("msg", Fields{"id": "u1"})
+		}, "map[http:map[id:u1]]"},
+		{"same key in sibling groups", FieldOverride, nil, func(d *Debugger) {
+			d.With("id", "top").WithGroup("db").With("id", "q1").Info("msg")
+		}, "map[db:map[id:q1] id:top]"},
+		{"group key in the call of a group", FieldOverride, nil, func(d *Debugger) {
+			d.WithGroup("http").With("method", "GET").Info("msg", Fields{"http": "call"})
+		}, "map[http:map[http:call method:GET]]"},
+		{"inner group replaces flat key of the handle", FieldKeepFirst, nil, func(d *Debugger) {
+			d.With("http", "flat").WithGroup("http").With("method", "GET").Info("msg")
+		}, "map[http:map[method:GET]]"},
+		{"static flat key then group overridden", FieldOverride, []interface{}{"http", "flat"}, func(d *Debugger) {
+			d.WithGroup("http").With("method", "GET").Info("msg")
+		}, "map[http:map[method:GET]]"},
+		{"static flat key then group kept", FieldKeepFirst, []interface{}{"http", "flat"}, func(d *Debugger) {
+			d.WithGroup("http").With("method", "GET").Info("msg")
+		}, "map[http:flat]"},
+		{"static flat key then group suffixed", FieldSuffixDuplicate, []interface{}{"http", "flat"}, func(d *Debugger) {
+			d.WithGroup("http").With("method", "GET").Info("msg")
+		}, "map[http:flat http_1:map[method:GET]]"},
+		{"groups of the same name merged", FieldKeepFirst, nil, func(d *Debugger) {
+			d.WithGroup("http").With("method", "GET", "path", "/a").Info("msg", Fields{"path": "/b", "status": 200})
+		}, "map[http:map[method:GET path:/a status:200]]"},
+		{"empty group name", FieldOverride, nil, func(d *Debugger) {
+			d.WithGroup("").With("id", "r1").Info("msg")
+		}, "map[id:r1]"},
+	}
+	for _, tt := range tests {
+		sink := &fieldSink{}
+		d := (&Debugger{}).SetQuiet(true).SetFieldCollisionPolicy(tt.policy).SetStaticFields(tt.static...)
+		d.NewLogRule("app", WithSink(sink))
+		tt.log(d)
+		d.Close(context.Background())
+		if len(sink.fields) != 1 {
+			t.Errorf("%s: %d entries", tt.name, len(sink.fields))
+			continue
+		}
+		if got := fmt.Sprint(sink.fields[0]); got != tt.want {
+			t.Errorf("%s: fields = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestFlattenFields joins the keys of nested groups with dots, preferring a flat key over a grouped field
+// flattening to the same key.
+func TestFlattenFields(t *testing.T) {
+	flat := Fields{"a": 1}
+	if got := flattenFields(flat); fmt.Sprint(got) != "map[a:1]" {
+		t.Errorf("flat fields = %v", got)
+	}
+	nested := map[string]interface{}{
+		"http":        Fields{"method": "GET", "tls": map[string]interface{}{"version": "1.3"}},
+		"http.method": "POST",
+	}
+	if got := fmt.Sprint(flattenFields(nested)); got != "map[http.method:POST http.tls.version:1.3]" {
+		t.Errorf("flattened = %s", got)
+	}
+}
@@ -0,0 +1,81 @@
+package mklog
+
+import "unicode/utf8"
+
+// TruncatedFieldSuffix is appended to the key of a field cut by WithMaxFieldBytes to name its companion key.
+const TruncatedFieldSuffix = "_truncated"
+
+// WithMaxFieldBytes limits string and []byte field values of the entries of the rule to n bytes, e.g. for dumped
+// request bodies. Longer values are cut at a rune boundary and a companion key with the TruncatedFieldSuffix is
+// set to true, so structured formatters still produce valid documents. Values of nested fields are limited as
+// well. Zero or a negative n disables the limit.
+func WithMaxFieldBytes(n int) Option {
+	return func(lr *LogRule) {
+		lr.MaxFieldBytes = n
+	}
+}
+
+// limitFields returns the fields with the values longer than the MaxFieldBytes of the rule cut. The fields are
+// returned unchanged when nothing is cut; otherwise a copy is returned, as they may be shared by other rules.
+func (lr *LogRule) limitFields(fields map[string]interface{}) map[string]interface{} {
+	if lr.MaxFieldBytes <= 0 || len(fields) == 0 {
+		return fields
+	}
+	limited, _ := limitFieldValues(fields, lr.MaxFieldBytes)
+	return limited
+}
+
+// limitFieldValues cuts the values of the fields, copying the map on the first change, and reports whether
+// anything was cut.
+func limitFieldValues(fields map[string]interface{}, limit int) (map[string]interface{}, bool) {
+	var limited map[string]interface{}
+	for k, v := range fields {
+		cut, truncated := limitFieldValue(v, limit)
+		if !truncated {
+			continue
+		}
+		if limited == nil {
+			limited = make(map[string]interface{}, len(fields)+1)
+			for key, value := range fields {
+				limited[key] = value
+			}
+		}
+		limited[k] = cut
+		if _, nested := cut.(map[string]interface{}); !nested {
+			limited[k+TruncatedFieldSuffix] = true
+		}
+	}
+	if limited == nil {
+		return fields, false
+	}
+	return limited, true
+}
+
+// limitFieldValue returns the value cut to the limit and whether it was changed.
+func limitFieldValue(v interface{}, limit int) (interface{}, bool) {
+	switch value := v.(type) {
+	case string:
+		if len(value) <= limit {
+			return v, false
+		}
+		return value[:runeBoundary(value, limit)], true
+	case []byte:
+		if len(value) <= limit {
+			return v, false
+		}
+		return append([]byte(nil), value[:runeBoundary(string(value), limit)]...), true
+	case map[string]interface{}:
+		return limitFieldValues(value, limit)
+	case Fields:
+		return limitFieldValues(value, limit)
+	}
+	return v, false
+}
+
+// runeBoundary returns the largest length up to limit that does not split a rune of s.
+func runeBoundary(s string, limit int) int {
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return limit
+}
@@ -0,0 +1,103 @@
+package mklog
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestLimitFieldValueRuneBoundary cuts strings and byte slices at the limit without splitting multibyte runes
+// and leaves short values and other types alone.
+func TestLimitFieldValueRuneBoundary(t *testing.T) {
+	tests := []struct {
+		in        interface{}
+		limit     int
+		want      string
+		truncated bool
+	}{
+		{"short", 8, "short", false},
+		{"exactly8", 8, "exactly8", false},
+		{"abcdefghij", 4, "abcd", true},
+		{"héllo", 2, "h", true},        // é takes bytes 1 and 2
+		{"héllo", 3, "hé", true},       // é ends at the limit
+		{"日本語のテキスト", 7, "日本", true},    // 3 bytes per rune
+		{"ok 👍🏽 done", 5, "ok ", true}, // 4 bytes per emoji rune
+		{[]byte("日本語"), 4, "日", true},
+		{12345678, 2, "12345678", false},
+	}
+	for _, tt := range tests {
+		got, truncated := limitFieldValue(tt.in, tt.limit)
+		var s string
+		switch v := got.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		default:
+			s = fieldString(v)
+		}
+		if s != tt.want || truncated != tt.truncated || !utf8.ValidString(s) {
+			t.Errorf("limitFieldValue(%q, %d) = %q, %v, want %q, %v", tt.in, tt.limit, s, truncated, tt.want, tt.truncated)
+		}
+	}
+}
+
+// TestMaxFieldBytesJSON cuts long top-level and nested values of a rule, marking them with companion keys,
+// keeps the JSON document valid and leaves the fields of another rule without a limit intact.
+func TestMaxFieldBytesJSON(t *testing.T) {
+	limited, full := &syncBuffer{}, &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(limited), WithLogFormatter(JSONFormatter{}), WithMaxFieldBytes(8))
+	d.NewLogRule("app", WithFileWriter(full), WithLogFormatter(JSONFormatter{}))
+
+	body := strings.Repeat("ü", 10) // 20 bytes
+	d.WithGroup("http").With("path", "/orders/12345").Info("request", Fields{"body": []byte(body), "status": 200}, Fields{"user": "ann"})
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var entry struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(limited.String()), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", limited.String(), err)
+	}
+	http, _ := entry.Fields["http"].(map[string]interface{})
+	if http["path"] != "/orders/" || http["path_truncated"] != true {
+		t.Errorf("http = %v, want the path cut to 8 bytes", http)
+	}
+	if http["status"] != float64(200) || http["status_truncated"] != nil {
+		t.Errorf("http = %v, want the status untouched", http)
+	}
+	if http["user"] != "ann" || http["user_truncated"] != nil {
+		t.Errorf("http = %v, want the short user untouched", http)
+	}
+	if _, truncated := entry.Fields["http_truncated"]; truncated {
+		t.Errorf("fields = %v, want no companion key for the group", entry.Fields)
+	}
+	if strings.Contains(full.String(), "_truncated") || !strings.Contains(full.String(), "/orders/12345") {
+		t.Errorf("rule without a limit = %q", full.String())
+	}
+}
+
+// TestMaxFieldBytesLogfmt keeps logfmt output parseable, with the companion keys of flattened groups.
+func TestMaxFieldBytesLogfmt(t *testing.T) {
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(out), WithLogFormatter(LogfmtFormatter{}), WithMaxFieldBytes(5))
+
+	d.WithGroup("req").Info("dump", Fields{"query": "a=1 b=2 c=3"}, Fields{"id": "r1"})
+	d.Info("plain", Fields{"msg": "日本語"})
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	log := out.String()
+	for _, want := range []string{`req.query="a=1 b"`, "req.query_truncated=true", "req.id=r1", "msg=日", "msg_truncated=true"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log does not contain %s:\n%s", want, log)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package mklog
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Renderings of time.Duration field values
+const (
+	DurationString = "string" // Go duration string, e.g. "1.5s" (default)
+	DurationMillis = "ms"     // Milliseconds as a float, e.g. 1500
+)
+
+// Renderings of time.Time field values
+const (
+	TimeDateFormat = "date_format" // Date format of the formatter, RFC 3339 when it has none (default)
+	TimeRFC3339    = "rfc3339"     // RFC 3339 with fractional seconds
+)
+
+// Renderings of []byte field values
+const (
+	BytesBase64 = "base64" // Standard base64, like encoding/json (default)
+	BytesHex    = "hex"    // Lower case hexadecimal
+)
+
+// FieldRendering decides how the structured formatters, JSON, YAML, XML, CSV and logfmt, render field values
+// their encoders disagree on: durations, times and byte slices. Errors are always rendered with Error().
+// Values of nested fields are rendered the same way. The zero value selects the defaults of each setting.
+type FieldRendering struct {
+	Durations string // DurationString (default) or DurationMillis.
+	Times     string // TimeDateFormat (default) or TimeRFC3339.
+	Bytes     string // BytesBase64 (default) or BytesHex.
+}
+
+// validate checks the settings of the rendering.
+func (r FieldRendering) validate() error {
+	switch r.Durations {
+	case "", DurationString, DurationMillis:
+	default:
+		return fmt.Errorf("option duration_format must be %q or %q, got %q", DurationString, DurationMillis, r.Durations)
+	}
+	switch r.Times {
+	case "", TimeDateFormat, TimeRFC3339:
+	default:
+		return fmt.Errorf("option time_format must be %q or %q, got %q", TimeDateFormat, TimeRFC3339, r.Times)
+	}
+	switch r.Bytes {
+	case "", BytesBase64, BytesHex:
+	default:
+		return fmt.Errorf("option bytes_format must be %q or %q, got %q", BytesBase64, BytesHex, r.Bytes)
+	}
+	return nil
+}
+
+// fieldRenderingKeys are the option keys of the field rendering in log_formatter blocks.
+var fieldRenderingKeys = []string{"duration_format", "time_format", "bytes_format"}
+
+// fieldRenderingOption returns the field rendering of a formatter block from its duration_format, time_format
+// and bytes_format options.
+func fieldRenderingOption(options map[string]interface{}) (FieldRendering, error) {
+	var r FieldRendering
+	dsts := []*string{&r.Durations, &r.Times, &r.Bytes}
+	for i, key := range fieldRenderingKeys {
+		dst := dsts[i]
+		v, ok := options[key]
+		if !ok || v == nil {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return FieldRendering{}, fmt.Errorf("option %s must be a string, got %v", key, v)
+		}
+		*dst = s
+	}
+	return r, r.validate()
+}
+
+// normalizeFields returns a copy of the fields with the special values rendered, passing the date format of the
+// formatter for times.
+func (r FieldRendering) normalizeFields(fields map[string]interface{}, dateFormat string) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		out[k] = r.normalizeValue(v, dateFormat)
+	}
+	return out
+}
+
+// normalizeValue renders a single field value, descending into nested fields.
+func (r FieldRendering) normalizeValue(v interface{}, dateFormat string) interface{} {
+	switch value := v.(type) {
+	case time.Duration:
+		if r.Durations == DurationMillis {
+			return float64(value) / float64(time.Millisecond)
+		}
+		return value.String()
+	case time.Time:
+		if r.Times == TimeRFC3339 || dateFormat == "" {
+			return value.Format(time.RFC3339Nano)
+		}
+		return formatTimestamp(value, dateFormat)
+	case []byte:
+		if r.Bytes == BytesHex {
+			return hex.EncodeToString(value)
+		}
+		return base64.StdEncoding.EncodeToString(value)
+	case error:
+		return value.Error()
+	case map[string]interface{}:
+		return r.normalizeFields(value, dateFormat)
+	case Fields:
+		return r.normalizeFields(value, dateFormat)
+	}
+	return v
+}
@@ -0,0 +1,135 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFieldRenderingValues renders each special field type under each setting.
+func TestFieldRenderingValues(t *testing.T) {
+	at := time.Date(2024, 3, 9, 10, 20, 30, 500000000, time.UTC)
+	tests := []struct {
+		name       string
+		rendering  FieldRendering
+		dateFormat string
+		value      interface{}
+		want       interface{}
+	}{
+		{"duration default", FieldRendering{}, "", 1500 * time.Millisecond, "1.5s"},
+		{"duration string", FieldRendering{Durations: DurationString}, "", 90 * time.Second, "1m30s"},
+		{"duration ms", FieldRendering{Durations: DurationMillis}, "", 1500 * time.Millisecond, 1500.0},
+		{"duration ms fraction", FieldRendering{Durations: DurationMillis}, "", 250 * time.Microsecond, 0.25},
+		{"time default", FieldRendering{}, "2006-01-02 15:04", at, "2024-03-09 10:20"},
+		{"time date format", FieldRendering{Times: TimeDateFormat}, UnixDateFormat, at, "1709979630"},
+		{"time without date format", FieldRendering{Times: TimeDateFormat}, "", at, "2024-03-09T10:20:30.5Z"},
+		{"time rfc3339", FieldRendering{Times: TimeRFC3339}, "2006-01-02 15:04", at, "2024-03-09T10:20:30.5Z"},
+		{"bytes default", FieldRendering{}, "", []byte("hi!"), "aGkh"},
+		{"bytes base64", FieldRendering{Bytes: BytesBase64}, "", []byte{0xff, 0x00}, "/wA="},
+		{"bytes hex", FieldRendering{Bytes: BytesHex}, "", []byte{0xff, 0x00}, "ff00"},
+		{"error", FieldRendering{Bytes: BytesHex}, "", errors.New("disk full"), "disk full"},
+		{"other", FieldRendering{Durations: DurationMillis}, "", 42, 42},
+		{"nested", FieldRendering{Durations: DurationMillis, Bytes: BytesHex}, "",
+			Fields{"took": 2 * time.Second, "raw": map[string]interface{}{"id": []byte{1}}},
+			map[string]interface{}{"took": 2000.0, "raw": map[string]interface{}{"id": "01"}}},
+	}
+	for _, tt := range tests {
+		if got := tt.rendering.normalizeValue(tt.value, tt.dateFormat); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestFieldRenderingFormatters renders the same fields alike in every structured formatter, with the defaults
+// and with milliseconds, RFC 3339 and hex.
+func TestFieldRenderingFormatters(t *testing.T) {
+	entry := Entry{
+		Level:     InfoLevel,
+		LevelName: "INFO",
+		Module:    "app",
+		Message:   "done",
+		Fields: map[string]interface{}{
+			"took": 1500 * time.Millisecond,
+			"at":   time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC),
+			"raw":  []byte{0xca, 0xfe},
+		},
+	}
+	custom := FieldRendering{Durations: DurationMillis, Times: TimeRFC3339, Bytes: BytesHex}
+	tests := []struct {
+		name   string
+		format func(FieldRendering) string
+		want   []string
+		custom []string
+	}{
+		{"json", func(r FieldRendering) string {
+			return JSONFormatter{dateFormat: time.Kitchen, FieldRendering: r}.FormatEntry(entry, "ts")
+		}, []string{`"took":"1.5s"`, `"at":"10:20AM"`, `"raw":"yv4="`}, []string{`"took":1500`, `"at":"2024-03-09T10:20:30Z"`, `"raw":"cafe"`}},
+		{"yaml", func(r FieldRendering) string {
+			return YAMLFormatter{dateFormat: time.Kitchen, FieldRendering: r}.FormatEntry(entry, "ts")
+		}, []string{"took: 1.5s", "at: 10:20AM", "raw: yv4="}, []string{"took: 1500", "at: \"2024-03-09T10:20:30Z\"", "raw: cafe"}},
+		{"xml", func(r FieldRendering) string {
+			return XMLFormatter{dateFormat: time.Kitchen, Compact: true, FieldRendering: r}.FormatEntry(entry, "ts")
+		}, []string{">1.5s<", ">10:20AM<", ">yv4=<"}, []string{">1500<", ">2024-03-09T10:20:30Z<", ">cafe<"}},
+		{"csv", func(r FieldRendering) string {
+			return CSVFormatter{dateFormat: time.Kitchen, FieldRendering: r}.FormatEntry(entry, "ts")
+		}, []string{`""took"":""1.5s""`, `""at"":""10:20AM""`, `""raw"":""yv4=""`}, []string{`""took"":1500`, `""at"":""2024-03-09T10:20:30Z""`, `""raw"":""cafe""`}},
+		{"logfmt", func(r FieldRendering) string {
+			return LogfmtFormatter{dateFormat: time.Kitchen, FieldRendering: r}.FormatFields("done", "INFO", "app", nil, "ts", entry.Fields)
+		}, []string{"took=1.5s", "at=10:20AM", `raw="yv4="`}, []string{"took=1500", "at=2024-03-09T10:20:30Z", "raw=cafe"}},
+	}
+	for _, tt := range tests {
+		for _, c := range []struct {
+			rendering FieldRendering
+			want      []string
+		}{{FieldRendering{}, tt.want}, {custom, tt.custom}} {
+			out := tt.format(c.rendering)
+			for _, want := range c.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("%s %+v: %s does not contain %s", tt.name, c.rendering, out, want)
+				}
+			}
+		}
+	}
+}
+
+// TestFieldRenderingConfig reads the rendering from the options of a formatter block and rejects unknown
+// settings.
+func TestFieldRenderingConfig(t *testing.T) {
+	config := func(formatter string) string {
+		return writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: `+formatter+`
+      file_log: {enable: true, file_path: `+filepath.ToSlash(t.TempDir())+`, file_name: app, file_type: .log}
+`)
+	}
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config(`{type: json, duration_format: ms, time_format: rfc3339, bytes_format: hex}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	want := FieldRendering{Durations: DurationMillis, Times: TimeRFC3339, Bytes: BytesHex}
+	if f, ok := d.rules()["app"][0].LogFormatter.(JSONFormatter); !ok || f.FieldRendering != want {
+		t.Errorf("formatter = %#v, want JSON with %+v", d.rules()["app"][0].LogFormatter, want)
+	}
+
+	tests := []struct {
+		formatter, want string
+	}{
+		{`{type: yaml, duration_format: seconds}`, `option duration_format must be "string" or "ms", got "seconds"`},
+		{`{type: xml, time_format: unix}`, `option time_format must be "date_format" or "rfc3339", got "unix"`},
+		{`{type: csv, bytes_format: base32}`, `option bytes_format must be "base64" or "hex", got "base32"`},
+		{`{type: logfmt, bytes_format: 16}`, "option bytes_format must be a string"},
+	}
+	for _, tt := range tests {
+		if _, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config(tt.formatter)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: LoadConfig error = %v, want %q", tt.formatter, err, tt.want)
+		}
+	}
+}
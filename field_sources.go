@@ -0,0 +1,203 @@
+package mklog
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+)
+
+// Fields are structured fields of a single log call, passed among the arguments of a log method:
+//
+//	d.Info("user %s logged in", name, mklog.Fields{"user_id": id})
+//
+// They are removed from the arguments before the message is formatted.
+type Fields map[string]interface{}
+
+// FieldCollisionPolicy decides the value of a key set by more than one source of the fields of an entry.
+// The sources in increasing precedence are the static fields of the Debugger, the fields added by enrichers
+// from the context, the fields bound by a Logger handle and the Fields of the log call.
+type FieldCollisionPolicy int32
+
+const (
+	FieldOverride        FieldCollisionPolicy = iota // The source with the higher precedence replaces the value.
+	FieldKeepFirst                                   // The value of the source with the lower precedence is kept.
+	FieldSuffixDuplicate                             // The value of the source with the higher precedence is added as key_1, key_2, ...
+)
+
+// fieldSourceNames names the sources of the fields of an entry in increasing precedence.
+var fieldSourceNames = [...]string{"static", "context", "handle", "call"}
+
+// SetStaticFields sets fields, given as alternating keys and values, added to every entry of the Debugger,
+// e.g. the service name and version. They have the lowest precedence of the field sources.
+func (d *Debugger) SetStaticFields(keyValues ...interface{}) *Debugger {
+	var fields map[string]interface{}
+	if len(keyValues) > 0 {
+		fields = make(map[string]interface{}, len(keyValues)/2)
+		addFields(fields, keyValues)
+	}
+	d.staticMu.Lock()
+	d.userStatic = fields
+	d.staticMu.Unlock()
+	d.refreshStaticFields()
+	return d
+}
+
+// refreshStaticFields publishes the static fields set by the user with the fingerprint field, if any.
+func (d *Debugger) refreshStaticFields() {
+	d.staticMu.Lock()
+	defer d.staticMu.Unlock()
+	if d.fingerprintField == "" {
+		if len(d.userStatic) == 0 {
+			d.staticFields.Store(nil)
+		} else {
+			fields := d.userStatic
+			d.staticFields.Store(&fields)
+		}
+		return
+	}
+	fields := make(map[string]interface{}, len(d.userStatic)+1)
+	for k, v := range d.userStatic {
+		fields[k] = v
+	}
+	fields[d.fingerprintField] = d.ConfigFingerprint()
+	d.staticFields.Store(&fields)
+}
+
+// SetFieldCollisionPolicy sets how keys set by more than one field source are resolved, FieldOverride by default.
+func (d *Debugger) SetFieldCollisionPolicy(policy FieldCollisionPolicy) *Debugger {
+	atomic.StoreInt32(&d.fieldPolicy, int32(policy))
+	return d
+}
+
+// SetFieldCollisionReport logs a warning the first time a key is set by more than one field source,
+// naming the sources, e.g. while debugging where an unexpected value comes from.
+func (d *Debugger) SetFieldCollisionReport(enabled bool) *Debugger {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&d.reportCollisions, v)
+	return d
+}
+
+// splitCallFields removes the Fields from the arguments of a log call and merges them.
+// The arguments are returned unchanged when they hold no Fields.
+func splitCallFields(args []interface{}) ([]interface{}, map[string]interface{}) {
+	found := false
+	for _, arg := range args {
+		if _, ok := arg.(Fields); ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return args, nil
+	}
+
+	var fields map[string]interface{}
+	rest := make([]interface{}, 0, len(args)-1)
+	for _, arg := range args {
+		f, ok := arg.(Fields)
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{}, len(f))
+		}
+		for k, v := range f {
+			if a, ok := fields[k].(Fields); ok {
+				if b, ok := v.(Fields); ok {
+					v = mergeGroups(a, b, FieldOverride) // Fields of the same groups, see WithGroup
+				}
+			}
+			fields[k] = v
+		}
+	}
+	return rest, fields
+}
+
+// assembleFields sets the fields of the entry from its sources in increasing precedence: the static fields,
+// the fields added by the enrichers, the fields bound by the handle and the fields of the log call.
+// Keys set by more than one source are resolved with the collision policy of the Debugger. The enrichers
+// only run when enrich is set, as the bare entry of a log call no rule accepted does not pay for them.
+func (d *Debugger) assembleFields(ctx context.Context, entry *Entry, enrich bool, bound, call map[string]interface{}) {
+	var static, enriched map[string]interface{}
+	if p := d.staticFields.Load(); p != nil {
+		static = *p
+	}
+	if enrich {
+		enriched = runEnrichers(ctx, entry)
+	}
+	sources := [...]map[string]interface{}{static, enriched, bound, call}
+
+	used, total, only := 0, 0, -1
+	for i, s := range sources {
+		if len(s) > 0 {
+			used++
+			total += len(s)
+			only = i
+		}
+	}
+	switch used {
+	case 0:
+		entry.Fields = nil
+		return
+	case 1:
+		entry.Fields = sources[only] // A single source cannot collide and is shared, like the fields of a handle.
+		return
+	}
+
+	policy := FieldCollisionPolicy(atomic.LoadInt32(&d.fieldPolicy))
+	report := atomic.LoadInt32(&d.reportCollisions) != 0
+	fields := make(map[string]interface{}, total)
+	origin := make(map[string]int, total) // Source of the value of each key
+	for i, s := range sources {
+		for k, v := range s {
+			first, exists := origin[k]
+			if !exists {
+				fields[k] = v
+				origin[k] = i
+				continue
+			}
+			if a, ok := fields[k].(Fields); ok {
+				if b, ok := v.(Fields); ok {
+					fields[k] = mergeGroups(a, b, policy) // Groups are merged key by key, see WithGroup.
+					origin[k] = i
+					continue
+				}
+			}
+			if report {
+				d.reportCollision(k, first, i)
+			}
+
+			switch policy {
+			case FieldKeepFirst:
+			case FieldSuffixDuplicate:
+				key := suffixedKey(fields, k)
+				fields[key] = v
+				origin[key] = i
+			default:
+				fields[k] = v
+				origin[k] = i
+			}
+		}
+	}
+	entry.Fields = fields
+}
+
+// suffixedKey returns the first of key_1, key_2, ... not present in the fields.
+func suffixedKey(fields map[string]interface{}, key string) string {
+	for n := 1; ; n++ {
+		suffixed := key + "_" + strconv.Itoa(n)
+		if _, ok := fields[suffixed]; !ok {
+			return suffixed
+		}
+	}
+}
+
+// reportCollision logs a warning about the key set by both sources, once per key.
+func (d *Debugger) reportCollision(key string, first, second int) {
+	d.WarnOnce("mklog.field_collision."+key, "[mklog] field %q set by the %s and %s fields",
+		key, fieldSourceNames[first], fieldSourceNames[second])
+}
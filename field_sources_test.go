@@ -0,0 +1,135 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fieldSink records a copy of the fields of the entries it receives.
+type fieldSink struct {
+	fields []map[string]interface{}
+}
+
+func (s *fieldSink) Write(entry Entry, formatted string) error {
+	fields := make(map[string]interface{}, len(entry.Fields))
+	for k, v := range entry.Fields {
+		fields[k] = v
+	}
+	s.fields = append(s.fields, fields)
+	return nil
+}
+
+func (s *fieldSink) Close() error { return nil }
+
+// contextFieldsKey is the context key of the fields added by the enricher of the tests.
+type contextFieldsKey struct{}
+
+// enrichFromContext registers an enricher adding the fields stored in the context of the log call.
+func enrichFromContext(t *testing.T) {
+	t.Cleanup(RegisterEnricher(func(ctx context.Context, e *Entry) {
+		if ctx == nil {
+			return
+		}
+		if fields, ok := ctx.Value(contextFieldsKey{}).(Fields); ok {
+			for k, v := range fields {
+				e.Fields[k] = v
+			}
+		}
+	}))
+}
+
+// TestFieldCollisionPolicies sets keys from the static, context, handle and call sources and expects the
+// fields each collision policy resolves them to.
+func TestFieldCollisionPolicies(t *testing.T) {
+	enrichFromContext(t)
+
+	tests := []struct {
+		name    string
+		policy  FieldCollisionPolicy
+		static  []interface{}
+		context Fields
+		handle  []interface{}
+		call    Fields
+		want    string
+	}{
+		{"no collision", FieldOverride, []interface{}{"service", "api"}, Fields{"request_id": "r1"}, []interface{}{"user", "ann"}, Fields{"attempt": 2},
+			"map[attempt:2 request_id:r1 service:api user:ann]"},
+		{"override static by context", FieldOverride, []interface{}{"env", "prod"}, Fields{"env": "canary"}, nil, nil,
+			"map[env:canary]"},
+		{"override all sources", FieldOverride, []interface{}{"id", "static"}, Fields{"id": "context"}, []interface{}{"id", "handle"}, Fields{"id": "call"},
+			"map[id:call]"},
+		{"override handle by call", FieldOverride, nil, nil, []interface{}{"id", "handle", "user", "ann"}, Fields{"id": "call"},
+			"map[id:call user:ann]"},
+		{"keep first of all sources", FieldKeepFirst, []interface{}{"id", "static"}, Fields{"id": "context"}, []interface{}{"id", "handle"}, Fields{"id": "call"},
+			"map[id:static]"},
+		{"keep first of context and call", FieldKeepFirst, nil, Fields{"id": "context"}, nil, Fields{"id": "call", "attempt": 2},
+			"map[attempt:2 id:context]"},
+		{"suffix all sources", FieldSuffixDuplicate, []interface{}{"id", "static"}, Fields{"id": "context"}, []interface{}{"id", "handle"}, Fields{"id": "call"},
+			"map[id:static id_1:context id_2:handle id_3:call]"},
+		{"suffix around a taken key", FieldSuffixDuplicate, []interface{}{"id", "static", "id_1", "taken"}, nil, nil, Fields{"id": "call"},
+			"map[id:static id_1:taken id_2:call]"},
+		{"groups merged key by key", FieldKeepFirst, nil, nil, []interface{}{"http", Fields{"method": "GET", "path": "/a"}}, Fields{"http": Fields{"path": "/b", "status": 200}},
+			"map[http:map[method:GET path:/a status:200]]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &fieldSink{}
+			d := (&Debugger{}).SetQuiet(true).SetFieldCollisionPolicy(tt.policy).SetStaticFields(tt.static...)
+			d.NewLogRule("app", WithSink(sink))
+			defer d.Close(context.Background())
+
+			ctx := context.WithValue(context.Background(), contextFieldsKey{}, tt.context)
+			handle := d.Module("app")
+			if tt.handle != nil {
+				handle = handle.With(tt.handle...)
+			}
+			args := []interface{}{}
+			if tt.call != nil {
+				args = append(args, tt.call)
+			}
+			handle.InfoCtx(ctx, "entry", args...)
+
+			if len(sink.fields) != 1 {
+				t.Fatalf("sink received %d entries, want 1", len(sink.fields))
+			}
+			if got := fmt.Sprint(sink.fields[0]); got != tt.want {
+				t.Errorf("fields = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFieldCollisionReport warns once per key set by more than one source, naming the sources, and not at all
+// without the report enabled.
+func TestFieldCollisionReport(t *testing.T) {
+	enrichFromContext(t)
+
+	for _, report := range []bool{false, true} {
+		out := &syncBuffer{}
+		d := (&Debugger{}).SetQuiet(true).SetStaticFields("env", "prod").SetFieldCollisionReport(report)
+		d.NewLogRule("app", WithFileWriter(out))
+
+		ctx := context.WithValue(context.Background(), contextFieldsKey{}, Fields{"env": "canary"})
+		for i := 0; i < 3; i++ {
+			d.InfoCtx(ctx, "entry %d", i, Fields{"env": "test"})
+		}
+		d.Info("user", Fields{"user": "ann"})
+		d.Info("user again", Fields{"user": "bob"})
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		log := out.String()
+		warnings := strings.Count(log, "| WARNING |")
+		switch {
+		case !report && warnings != 0:
+			t.Errorf("report disabled: %d warnings:\n%s", warnings, log)
+		case report && warnings != 1:
+			t.Errorf("report enabled: %d warnings, want 1 for env:\n%s", warnings, log)
+		case report && !strings.Contains(log, `field "env" set by the static and context fields`):
+			t.Errorf("warning does not name the sources:\n%s", log)
+		}
+	}
+}
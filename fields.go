@@ -0,0 +1,81 @@
+package mklog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// badFieldKey is the key of a trailing value without a key, as used by log/slog.
+const badFieldKey = "!BADKEY"
+
+// FieldsFormatter is an optional interface of log formatters rendering the structured fields of an entry
+// into their own layout. Other formatters get the fields appended as logfmt key=value pairs.
+type FieldsFormatter interface {
+	// FormatFields formats the log message like Format, including the fields.
+	FormatFields(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string, fields map[string]interface{}) string
+}
+
+// addFields adds alternating key/value pairs to the fields map. Keys that are not strings are
+// converted with fmt.Sprint and a trailing value without a key is stored under "!BADKEY".
+func addFields(fields map[string]interface{}, keyValues []interface{}) {
+	for i := 0; i < len(keyValues); i += 2 {
+		if i+1 == len(keyValues) {
+			fields[badFieldKey] = keyValues[i]
+			break
+		}
+		key, ok := keyValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyValues[i])
+		}
+		fields[key] = keyValues[i+1]
+	}
+}
+
+// sortedFieldKeys returns the keys of the fields in lexical order, so the output is stable.
+func sortedFieldKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appendLogfmtFields appends the fields as space separated logfmt key=value pairs, flattening groups with dots.
+func appendLogfmtFields(sb *strings.Builder, fields map[string]interface{}) {
+	fields = flattenFields(fields)
+	for _, k := range sortedFieldKeys(fields) {
+		sb.WriteByte(' ')
+		sb.WriteString(logfmtValue(k))
+		sb.WriteByte('=')
+		sb.WriteString(logfmtValue(fieldString(fields[k])))
+	}
+}
+
+// fieldString renders a field value as text.
+func fieldString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatWithFields renders the message with the formatter, including the fields when there are any.
+func formatWithFields(f LogFormatter, logMessage string, logLevel string, moduleName string, submodules []string, timestamp string, fields map[string]interface{}) string {
+	if ff, ok := f.(FieldsFormatter); ok {
+		return ff.FormatFields(logMessage, logLevel, moduleName, submodules, timestamp, fields)
+	}
+
+	formatted := f.Format(logMessage, logLevel, moduleName, submodules, timestamp)
+	var sb strings.Builder
+	sb.WriteString(formatted)
+	appendLogfmtFields(&sb, fields)
+	return sb.String()
+}
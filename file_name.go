@@ -0,0 +1,102 @@
+package mklog
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Policies for characters invalid in file names
+const (
+	FileNameReject   = "reject"   // Fail to create the log file (default)
+	FileNameSanitize = "sanitize" // Replace the characters with '_'
+)
+
+// invalidFileNameChars are the characters Windows does not allow in file names. They are checked on every
+// platform, so configurations stay portable.
+const invalidFileNameChars = `<>:"|?*`
+
+// WithFileNamePolicy sets how characters invalid in file names, <>:"|?* and control characters, are handled in
+// the file name, the file type and the dates and folders rendered into them: FileNameReject (default) fails to
+// create the log file, FileNameSanitize replaces them with '_'. Names escaping the file path, e.g.
+// "../../etc/cron.d/evil", are rejected with either policy.
+func WithFileNamePolicy(policy string) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.FileNamePolicy = policy
+	}
+}
+
+// validFileNamePolicy checks whether the policy names a file name policy; empty selects FileNameReject.
+func validFileNamePolicy(policy string) bool {
+	switch policy {
+	case "", FileNameReject, FileNameSanitize:
+		return true
+	}
+	return false
+}
+
+// checkFileName checks the name and type of log files under the policy, returning them with invalid characters
+// replaced when the policy sanitizes them. Names escaping the directory are always rejected.
+func checkFileName(name, fileType, policy string) (string, string, error) {
+	if !validFileNamePolicy(policy) {
+		return "", "", fmt.Errorf("unsupported file name policy: %s", policy)
+	}
+	var err error
+	if name, err = cleanFileNamePart(name, policy); err != nil {
+		return "", "", err
+	}
+	if fileType, err = cleanFileNamePart(fileType, policy); err != nil {
+		return "", "", err
+	}
+	if escapesDir(name + fileType) {
+		return "", "", fmt.Errorf("file name %q escapes the log directory", name+fileType)
+	}
+	return name, fileType, nil
+}
+
+// checkModuleFileName checks a module name substituted into file paths and names, which must be a single
+// path element.
+func checkModuleFileName(module, policy string) (string, error) {
+	if module == "" || module == "." || module == ".." || strings.ContainsAny(module, `/\`) {
+		return "", fmt.Errorf("module name %q cannot be used in file names", module)
+	}
+	return cleanFileNamePart(module, policy)
+}
+
+// cleanFileNamePart rejects a part of a file name containing invalid characters, or replaces them when the
+// policy sanitizes them.
+func cleanFileNamePart(part, policy string) (string, error) {
+	if !hasInvalidFileNameChars(part) {
+		return part, nil
+	}
+	if policy != FileNameSanitize {
+		return "", fmt.Errorf("file name %q contains characters invalid in file names (%s or control characters)", part, invalidFileNameChars)
+	}
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(invalidFileNameChars, r) {
+			return '_'
+		}
+		return r
+	}, part), nil
+}
+
+// hasInvalidFileNameChars reports whether the text contains characters invalid in file names.
+func hasInvalidFileNameChars(s string) bool {
+	for _, r := range s {
+		if r < ' ' || strings.ContainsRune(invalidFileNameChars, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// escapesDir reports whether the relative name leaves its directory after cleaning, treating both slashes and
+// backslashes as separators, so names are judged the same on every platform.
+func escapesDir(name string) bool {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return true
+	}
+	cleaned := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	return cleaned == ".." || strings.HasPrefix(cleaned, "../") || strings.HasPrefix(cleaned, "/")
+}
@@ -0,0 +1,134 @@
+package mklog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCheckFileName rejects names escaping the log directory with either policy, and characters invalid on
+// Windows unless the policy sanitizes them, on every platform.
+func TestCheckFileName(t *testing.T) {
+	tests := []struct {
+		name, fileType, policy string
+		wantName, wantErr      string
+	}{
+		{"app", ".log", "", "app", ""},
+		{"logs/app", ".log", FileNameReject, "logs/app", ""},
+		{"../../etc/cron.d/evil", "", "", "", "escapes the log directory"},
+		{"../../etc/cron.d/evil", "", FileNameSanitize, "", "escapes the log directory"},
+		{`..\..\evil`, ".log", "", "", "escapes the log directory"},
+		{"logs/../../evil", ".log", "", "", "escapes the log directory"},
+		{"/etc/evil", ".log", "", "", "escapes the log directory"},
+		{"app", "/../../evil", "", "", "escapes the log directory"},
+		{`app<1>:"x"|y?*`, ".log", "", "", "contains characters invalid in file names"},
+		{"app\x00", ".log", FileNameReject, "", "contains characters invalid in file names"},
+		{`app<1>:"x"|y?*`, ".log", FileNameSanitize, "app_1___x__y__", ""},
+		{"app\ttab", ".log", FileNameSanitize, "app_tab", ""},
+		{"app", ".log", "escape", "", "unsupported file name policy: escape"},
+	}
+	for _, tt := range tests {
+		name, _, err := checkFileName(tt.name, tt.fileType, tt.policy)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkFileName(%q, %q, %q) error = %v, want %q", tt.name, tt.fileType, tt.policy, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || name != tt.wantName {
+			t.Errorf("checkFileName(%q, %q, %q) = %q, %v, want %q", tt.name, tt.fileType, tt.policy, name, err, tt.wantName)
+		}
+	}
+}
+
+// TestFileNameRuleErrors fails rule construction for traversal and invalid names and writes sanitized names
+// into the log directory.
+func TestFileNameRuleErrors(t *testing.T) {
+	dir := t.TempDir()
+	d := (&Debugger{}).SetQuiet(true)
+	defer d.Close(context.Background())
+
+	if _, err := d.NewLogRuleE("evil", WithFileLogging(dir, "../../etc/cron.d/evil", "")); err == nil ||
+		!strings.Contains(err.Error(), "escapes the log directory") {
+		t.Errorf("traversal: error = %v, want the name rejected", err)
+	}
+	if _, err := d.NewLogRuleE("colon", WithFileLogging(dir, "app:1", ".log")); err == nil ||
+		!strings.Contains(err.Error(), "contains characters invalid in file names") {
+		t.Errorf("invalid characters: error = %v, want the name rejected", err)
+	}
+	if _, err := d.NewLogRuleE("app", WithFileLogging(dir, "app:1", ".log"), WithFileNamePolicy(FileNameSanitize)); err != nil {
+		t.Fatal(err)
+	}
+	d.Module("app").Info("sanitized")
+	if got := readFile(t, filepath.Join(dir, "app_1.log")); !strings.Contains(got, "sanitized") {
+		t.Errorf("log = %q, want the entry in app_1.log", got)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(filepath.Dir(dir)), "etc")); err == nil {
+		t.Error("traversal created a directory outside the log directory")
+	}
+}
+
+// TestFileNameModuleTemplate checks module names substituted into the file names of fallback rules, disabling
+// file logging of modules whose names would escape the log directory.
+func TestFileNameModuleTemplate(t *testing.T) {
+	dir := t.TempDir()
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(rec.handle)
+	d.SetFallbackRule(WithFileLogging(dir, ModulePlaceholder, ".log"), WithFileNamePolicy(FileNameSanitize))
+
+	d.Module("billing").Info("invoice sent")
+	d.Module("a|b").Info("sanitized")
+	d.Module("../evil").Info("escaped")
+	d.Module("..").Info("parent")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	files := listFiles(t, dir)
+	if strings.Join(files, ",") != "a_b.log,billing.log" {
+		t.Errorf("files = %v, want a_b.log and billing.log", files)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil.log")); err == nil {
+		t.Error("module name escaped the log directory")
+	}
+	errs := rec.get()
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want one per rejected module", errs)
+	}
+	for _, err := range errs {
+		if !strings.Contains(err.Error(), "cannot be used in file names") {
+			t.Errorf("error = %v, want the module name rejected", err)
+		}
+	}
+}
+
+// TestFileNameConfig fails LoadConfig for file names the policy of the rule rejects.
+func TestFileNameConfig(t *testing.T) {
+	config := func(name, policy string) string {
+		return writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+filepath.ToSlash(t.TempDir())+`, file_name: '`+name+`', file_type: .log, file_name_policy: `+policy+`}
+`)
+	}
+	for _, name := range []string{"../../etc/cron.d/evil", "app?"} {
+		if _, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config(name, FileNameReject)); err == nil ||
+			!strings.Contains(err.Error(), "invalid file name of rule app") {
+			t.Errorf("file name %q: LoadConfig error = %v, want the name rejected", name, err)
+		}
+	}
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config("app?", FileNameSanitize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	if got := d.rules()["app"][0].FileLog.FileNamePolicy; got != FileNameSanitize {
+		t.Errorf("policy = %q, want sanitize", got)
+	}
+}
@@ -0,0 +1,87 @@
+package mklog
+
+import (
+	"sync"
+)
+
+// FlightRecorderField is the field marking entries replayed by the flight recorder.
+const FlightRecorderField = "flight_recorder"
+
+// flightRecorder keeps the latest entries below the minimum level of a rule in a ring buffer.
+type flightRecorder struct {
+	mu      sync.Mutex
+	entries []Entry  // Ring buffer of the recorded entries
+	start   int      // Index of the oldest entry
+	count   int      // Number of recorded entries
+	dumpAt  LogLevel // Level of the entries replaying the buffer
+}
+
+// WithFlightRecorder keeps the last capacity entries below the minimum level of the rule in memory instead
+// of dropping them. When an entry at or above dumpAtLevel is logged, the buffered entries are written first,
+// in their original order and marked with the FlightRecorderField field, followed by the triggering entry.
+// The oldest entries are evicted when the buffer is full. Entries pass the middleware chain before they are
+// recorded, so entries dropped by the chain are not replayed and replays carry the changes of the chain.
+func WithFlightRecorder(capacity int, dumpAtLevel LogLevel) Option {
+	return func(lr *LogRule) {
+		if capacity <= 0 {
+			lr.recorder = nil
+			return
+		}
+		lr.recorder = &flightRecorder{
+			entries: make([]Entry, capacity),
+			dumpAt:  dumpAtLevel,
+		}
+	}
+}
+
+// add records the entry, evicting the oldest one when the buffer is full.
+func (r *flightRecorder) add(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	capacity := len(r.entries)
+	if r.count < capacity {
+		r.entries[(r.start+r.count)%capacity] = entry
+		r.count++
+		return
+	}
+	r.entries[r.start] = entry
+	r.start = (r.start + 1) % capacity
+}
+
+// drain removes and returns the recorded entries, oldest first.
+func (r *flightRecorder) drain() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.count == 0 {
+		return nil
+	}
+	capacity := len(r.entries)
+	entries := make([]Entry, r.count)
+	for i := range entries {
+		idx := (r.start + i) % capacity
+		entries[i] = r.entries[idx]
+		r.entries[idx] = Entry{} // Release the fields of the entry.
+	}
+	r.start, r.count = 0, 0
+	return entries
+}
+
+// replayRecorded writes the recorded entries of the rule before an entry at the dump level.
+func (lr *LogRule) replayRecorded(level LogLevel) {
+	if lr.recorder == nil || level < lr.recorder.dumpAt {
+		return
+	}
+	for _, entry := range lr.recorder.drain() {
+		fields := make(map[string]interface{}, len(entry.Fields)+1)
+		for k, v := range entry.Fields {
+			fields[k] = v
+		}
+		fields[FlightRecorderField] = true
+		entry.Fields = fields
+
+		finalMessage := lr.prepareMessage(entry.Time, entry.Message, entry.Level, lr.renderStack(entry.Level), fields, entry.Err)
+		lr.deferOutputErrors(entry, lr.writeEntry(&entry, finalMessage))
+	}
+}
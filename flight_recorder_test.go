@@ -0,0 +1,88 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newRecorderDebugger returns a Debugger with a rule logging from Info, recording the entries below with the
+// flight recorder of the capacity and replaying them at Error, and the sink of the rule.
+func newRecorderDebugger(capacity int) (*Debugger, *entrySink) {
+	sink := &entrySink{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithSink(sink), WithMinLevel(InfoLevel),
+		WithFlightRecorder(capacity, ErrorLevel))
+	return d, sink
+}
+
+// TestFlightRecorderReplay replays the recorded entries in their original order and with their levels before
+// the entry triggering the replay, marks only the replayed entries, and replays each entry once.
+func TestFlightRecorderReplay(t *testing.T) {
+	d, sink := newRecorderDebugger(10)
+	defer d.Close(context.Background())
+
+	d.Debug("connecting")
+	d.Trace("handshake")
+	d.Info("serving")
+	d.Debug("query")
+	d.Warning("slow query")
+	d.Error("query failed")
+	d.Error("query failed again")
+
+	want := []string{"serving", "slow query", "connecting", "handshake", "query", "query failed", "query failed again"}
+	if got := sink.messages(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("sink messages = %q, want %q", got, want)
+	}
+	levels := []LogLevel{InfoLevel, WarningLevel, DebugLevel, TraceLevel, DebugLevel, ErrorLevel, ErrorLevel}
+	for i, entry := range sink.entries {
+		replayed := i >= 2 && i <= 4
+		if entry.Level != levels[i] || (entry.Fields[FlightRecorderField] == true) != replayed {
+			t.Errorf("entry %q at %v with fields %v, want %v and marked %v", entry.Message, entry.Level, entry.Fields,
+				levels[i], replayed)
+		}
+	}
+}
+
+// TestFlightRecorderEviction keeps only the latest entries of a full buffer.
+func TestFlightRecorderEviction(t *testing.T) {
+	d, sink := newRecorderDebugger(3)
+	defer d.Close(context.Background())
+
+	for i := 1; i <= 7; i++ {
+		d.Debug("step %d", i)
+	}
+	d.Error("failed")
+
+	want := []string{"step 5", "step 6", "step 7", "failed"}
+	if got := sink.messages(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sink messages = %q, want %q", got, want)
+	}
+}
+
+// TestFlightRecorderRing drains the ring oldest first across the wrap-around and empties it.
+func TestFlightRecorderRing(t *testing.T) {
+	r := &flightRecorder{entries: make([]Entry, 4)}
+	for round, n := range []int{2, 4, 9} {
+		for i := 0; i < n; i++ {
+			r.add(Entry{Message: fmt.Sprint(i)})
+		}
+		var got []string
+		for _, entry := range r.drain() {
+			got = append(got, entry.Message)
+		}
+		var want []string
+		for i := n - len(r.entries); i < n; i++ {
+			if i >= 0 {
+				want = append(want, fmt.Sprint(i))
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("round %d: drained %v, want %v", round, got, want)
+		}
+		if rest := r.drain(); rest != nil {
+			t.Errorf("round %d: second drain = %v, want nothing", round, rest)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package mklog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Interval in which followers check their log file for new lines, rotation and truncation
+	MKLOG_FollowPollInterval = 250 * time.Millisecond
+)
+
+// Follower follows a log file written by another process like tail -F: it parses the lines appended to the
+// file, re-opens the file when it is replaced by rotation and starts over when it is truncated, e.g. by
+// CopyTruncate. Lines the old file received before it was replaced are read before switching. Rotation is
+// detected by polling the file every PollInterval, comparing the identity of the open file with the one at
+// the path.
+type Follower struct {
+	DateFormat   string        // Layout of the timestamps in the file, the TimeLogFormat of GetDefaults by default.
+	PollInterval time.Duration // Interval of the checks, MKLOG_FollowPollInterval by default.
+
+	path      string        // Path of the followed log file
+	parser    LineParser    // Parser of the file format
+	entries   chan Entry    // Parsed entries, closed when the follower stops
+	errors    chan error    // Parse and read errors
+	stop      chan struct{} // Closed by Stop
+	stopOnce  sync.Once     // Guards closing of stop
+	startOnce sync.Once     // Guards the start of the goroutine
+	done      chan struct{} // Closed when the goroutine finished
+
+	file    *os.File      // File being followed
+	info    os.FileInfo   // Identity of the followed file
+	reader  *bufio.Reader // Reader of the followed file
+	offset  int64         // Bytes of the followed file read so far
+	partial string        // Start of a line not terminated yet
+	held    *Entry        // Last entry, held back for its continuation lines
+}
+
+// Follow follows the log file written in the given format like tail -F, see Follower, delivering the entries
+// appended to it on the returned channel until the returned function is called. With fromEnd only entries
+// written after the call are delivered, otherwise the file is read from the start. Lines that cannot be parsed
+// are skipped; use NewFollower to receive them as errors.
+func Follow(path string, format string, fromEnd bool) (<-chan Entry, func(), error) {
+	f, err := NewFollower(path, format, fromEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	f.Start()
+	return f.Entries(), f.Stop, nil
+}
+
+// NewFollower opens the log file written in the given format ("plain", "json", "logfmt" or a registered
+// format) for following. It fails when the file cannot be opened. Following begins with Start, so DateFormat
+// and PollInterval can be set first.
+func NewFollower(path string, format string, fromEnd bool) (*Follower, error) {
+	parser, err := lineParser(format)
+	if err != nil {
+		return nil, err
+	}
+	f := &Follower{
+		DateFormat:   GetDefaults().TimeLogFormat,
+		PollInterval: MKLOG_FollowPollInterval,
+		path:         path,
+		parser:       parser,
+		entries:      make(chan Entry, 64),
+		errors:       make(chan error, 16),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, fmt.Errorf("[mklog] failed to open log file: %w", err)
+	}
+	if fromEnd {
+		if f.offset, err = f.file.Seek(0, io.SeekEnd); err != nil {
+			f.file.Close()
+			return nil, fmt.Errorf("[mklog] failed to seek log file: %w", err)
+		}
+		f.reader.Reset(f.file)
+	}
+	return f, nil
+}
+
+// Start starts following the file in a background goroutine.
+func (f *Follower) Start() {
+	f.startOnce.Do(func() { go f.run() })
+}
+
+// Entries returns the channel of the followed entries, closed after Stop.
+func (f *Follower) Entries() <-chan Entry {
+	return f.entries
+}
+
+// Errors returns the channel of the lines that could not be parsed and of read errors. Errors are dropped
+// while the channel is full, so it may be ignored.
+func (f *Follower) Errors() <-chan error {
+	return f.errors
+}
+
+// Stop stops following and closes the file, waiting for the goroutine of Start to finish.
+func (f *Follower) Stop() {
+	f.stopOnce.Do(func() { close(f.stop) })
+	f.startOnce.Do(func() {
+		// Stopped before Start: nothing runs that would close the file and channels.
+		f.file.Close()
+		close(f.entries)
+		close(f.done)
+	})
+	<-f.done
+}
+
+// run polls the file until the follower is stopped.
+func (f *Follower) run() {
+	defer close(f.done)
+	defer close(f.entries)
+	defer f.file.Close()
+
+	interval := f.PollInterval
+	if interval <= 0 {
+		interval = MKLOG_FollowPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if !f.poll() {
+			return
+		}
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the lines appended since the last poll and switches files on rotation or truncation. It reports
+// false once the follower is stopped.
+func (f *Follower) poll() bool {
+	if !f.readLines() {
+		return false
+	}
+
+	info, err := os.Stat(f.path)
+	switch {
+	case err != nil:
+		// Rotated away and not recreated yet; keep the old file until the new one appears.
+		return true
+	case !os.SameFile(f.info, info):
+		// Replaced by rotation: the old file may still have received lines before it was renamed.
+		if !f.readLines() {
+			return false
+		}
+		f.file.Close()
+		if err := f.open(); err != nil {
+			f.reportError(fmt.Errorf("[mklog] failed to reopen log file: %w", err))
+			return true
+		}
+		return f.readLines()
+	case info.Size() < f.offset:
+		// Truncated in place.
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			f.reportError(fmt.Errorf("[mklog] failed to seek log file: %w", err))
+			return true
+		}
+		f.offset, f.partial = 0, ""
+		f.reader.Reset(f.file)
+		return f.readLines()
+	}
+	return true
+}
+
+// open opens the file at the path, reading it from the start.
+func (f *Follower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.info, f.offset, f.partial = file, info, 0, ""
+	if f.reader == nil {
+		f.reader = bufio.NewReaderSize(file, 64*1024)
+	} else {
+		f.reader.Reset(file)
+	}
+	return nil
+}
+
+// readLines parses the complete lines available in the file, keeping an unterminated line for the next read,
+// and delivers the parsed entries. It reports false once the follower is stopped.
+func (f *Follower) readLines() bool {
+	for {
+		chunk, err := f.reader.ReadString('\n')
+		f.offset += int64(len(chunk))
+		if err != nil {
+			f.partial += chunk
+			if err != io.EOF {
+				f.reportError(fmt.Errorf("[mklog] failed to read log file: %w", err))
+			}
+			return f.flushHeld()
+		}
+		line := strings.TrimRight(f.partial+chunk, "\r\n")
+		f.partial = ""
+		if !f.handleLine(line) {
+			return false
+		}
+	}
+}
+
+// handleLine parses one line, appending continuation lines written in MultilineIndent mode to the held entry.
+func (f *Follower) handleLine(line string) bool {
+	if strings.TrimSpace(line) == "" {
+		return true
+	}
+	if f.held != nil && strings.HasPrefix(line, MKLOG_MultilineIndentPrefix) {
+		f.held.Message += "\n" + line[len(MKLOG_MultilineIndentPrefix):]
+		return true
+	}
+	entry, err := f.parser(line, f.DateFormat)
+	if err != nil {
+		f.reportError(fmt.Errorf("[mklog] failed to parse line %q: %w", line, err))
+		return true
+	}
+	if !f.flushHeld() {
+		return false
+	}
+	f.held = &entry
+	return true
+}
+
+// flushHeld delivers the held entry. It reports false once the follower is stopped.
+func (f *Follower) flushHeld() bool {
+	if f.held == nil {
+		return true
+	}
+	select {
+	case f.entries <- *f.held:
+		f.held = nil
+		return true
+	case <-f.stop:
+		return false
+	}
+}
+
+// reportError passes the error to the Errors channel unless it is full.
+func (f *Follower) reportError(err error) {
+	select {
+	case f.errors <- err:
+	default:
+	}
+}
@@ -0,0 +1,171 @@
+package mklog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// appendLog appends plain text entries with the messages to the log file.
+func appendLog(t *testing.T, path string, msgs ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, msg := range msgs {
+		if _, err := f.WriteString("10:20:30 | INFO | [app] : " + msg + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// receive returns the messages of the next n followed entries, failing when they do not arrive in time.
+func receive(t *testing.T, entries <-chan Entry, n int) []string {
+	t.Helper()
+	var msgs []string
+	timeout := time.After(5 * time.Second)
+	for len(msgs) < n {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				t.Fatalf("entries closed after %v", msgs)
+			}
+			msgs = append(msgs, e.Message)
+		case <-timeout:
+			t.Fatalf("received %v, want %d entries", msgs, n)
+		}
+	}
+	return msgs
+}
+
+// newTestFollower follows the plain text log file with a short poll interval.
+func newTestFollower(t *testing.T, path string, fromEnd bool) *Follower {
+	t.Helper()
+	f, err := NewFollower(path, "plain", fromEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.DateFormat = "15:04:05"
+	f.PollInterval = 5 * time.Millisecond
+	f.Start()
+	t.Cleanup(f.Stop)
+	return f
+}
+
+// TestFollowRotation keeps following the log file when it is renamed and recreated, reading the lines the
+// old file received after the last poll before the lines of the new one.
+func TestFollowRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "one", "two")
+	f := newTestFollower(t, path, false)
+	if got := strings.Join(receive(t, f.Entries(), 2), ","); got != "one,two" {
+		t.Fatalf("entries = %s, want one,two", got)
+	}
+
+	for i, rotated := range []string{path + ".1", path + ".2"} {
+		appendLog(t, path, "before rotation")
+		if err := os.Rename(path, rotated); err != nil {
+			t.Fatal(err)
+		}
+		appendLog(t, rotated, "late write to the old file")
+		appendLog(t, path, "after rotation")
+		got := strings.Join(receive(t, f.Entries(), 3), ",")
+		if got != "before rotation,late write to the old file,after rotation" {
+			t.Errorf("rotation %d: entries = %s", i+1, got)
+		}
+	}
+}
+
+// TestFollowTruncation starts over at the beginning of a log file truncated in place.
+func TestFollowTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "a long entry written before the truncation")
+	f := newTestFollower(t, path, false)
+	receive(t, f.Entries(), 1)
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, path, "short")
+	if got := receive(t, f.Entries(), 1); got[0] != "short" {
+		t.Errorf("entry after truncation = %q, want short", got[0])
+	}
+}
+
+// TestFollowFromEnd skips the entries written before following began and joins a line completed by a later
+// write.
+func TestFollowFromEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "old")
+	f := newTestFollower(t, path, true)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.WriteString("10:20:30 | INFO | [app] : sp")
+	time.Sleep(20 * time.Millisecond)
+	file.WriteString("lit\n")
+	if got := receive(t, f.Entries(), 1); got[0] != "split" {
+		t.Errorf("entry = %q, want split", got[0])
+	}
+}
+
+// TestFollowParseErrors reports unparsable lines on the error channel and keeps following.
+func TestFollowParseErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, path, "valid")
+	f := newTestFollower(t, path, false)
+
+	if got := receive(t, f.Entries(), 1); got[0] != "valid" {
+		t.Errorf("entry = %q, want valid", got[0])
+	}
+	select {
+	case err := <-f.Errors():
+		if !strings.Contains(err.Error(), `failed to parse line "garbage"`) {
+			t.Errorf("error = %v, want the unparsable line", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("parse error not reported")
+	}
+}
+
+// TestFollowStop closes the entries channel on stop, also for a follower never started, and fails for
+// missing files and unknown formats.
+func TestFollowStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "entry")
+	entries, stop, err := Follow(path, "plain", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if _, ok := <-entries; ok {
+		t.Error("entries not closed after stop")
+	}
+	stop()
+
+	f, err := NewFollower(path, "plain", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Stop()
+	if _, ok := <-f.Entries(); ok {
+		t.Error("entries of an unstarted follower not closed")
+	}
+
+	if _, _, err := Follow(filepath.Join(filepath.Dir(path), "missing.log"), "plain", false); err == nil {
+		t.Error("following a missing file succeeded")
+	}
+	if _, _, err := Follow(path, "protobuf", false); err == nil || !strings.Contains(err.Error(), "unsupported log file format: protobuf") {
+		t.Errorf("Follow error = %v, want the unsupported format", err)
+	}
+}
@@ -0,0 +1,136 @@
+package mklog
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FormatterFactory creates a log formatter from the date format of the rule and the extra keys of its
+// log_formatter configuration block, e.g. {"pretty": true} for
+//
+//	log_formatter: {type: json, pretty: true}
+type FormatterFactory func(dateFormat string, options map[string]interface{}) (LogFormatter, error)
+
+// formatterEntry is a formatter factory with the option keys it documents.
+type formatterEntry struct {
+	factory FormatterFactory
+	keys    []string // Option keys consumed by the factory; other keys are unknown
+}
+
+// builtinFormatters creates the built-in formatters by their log_formatter type names and aliases.
+var builtinFormatters = map[string]formatterEntry{}
+
+func init() {
+	register := func(entry formatterEntry, names ...string) {
+		for _, name := range names {
+			builtinFormatters[name] = entry
+		}
+	}
+	register(formatterEntry{factory: func(dateFormat string, options map[string]interface{}) (LogFormatter, error) {
+		mode, err := multilineOption(options)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := submoduleSeparatorOption(options)
+		return PlainTextFormatter{dateFormat: dateFormat, MultilineMode: mode, SubmoduleSeparator: sep}, err
+	}, keys: []string{"multiline", "submodule_separator"}}, "plaintextformatter", "plaintext", "plain", "text", "simple")
+	register(formatterEntry{factory: func(dateFormat string, options map[string]interface{}) (LogFormatter, error) {
+		mode, err := multilineOption(options)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := submoduleSeparatorOption(options)
+		return FastTextFormatter{dateFormat: dateFormat, MultilineMode: mode, SubmoduleSeparator: sep}, err
+	}, keys: []string{"multiline", "submodule_separator"}}, "fasttextformatter", "fasttext")
+	register(formatterEntry{factory: func(dateFormat string, options map[string]interface{}) (LogFormatter, error) {
+		pretty, err := boolOption(options, "pretty", false)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := submoduleSeparatorOption(options)
+		if err != nil {
+			return nil, err
+		}
+		rendering, err := fieldRenderingOption(options)
+		return JSONFormatter{dateFormat: dateFormat, Pretty: pretty, SubmoduleSeparator: sep, FieldRendering: rendering}, err
+	}, keys: structuredKeys("pretty")}, "jsonformatter", "json")
+	register(formatterEntry{factory: func(dateFormat string, options map[string]interface{}) (LogFormatter, error) {
+		sep, err := submoduleSeparatorOption(options)
+		if err != nil {
+			return nil, err
+		}
+		rendering, err := fieldRenderingOption(options)
+		return LogfmtFormatter{dateFormat: dateFormat, SubmoduleSeparator: sep, FieldRendering: rendering}, err
+	}, keys: structuredKeys()}, "logfmtformatter", "logfmt")
+	register(formatterEntry{factory: func(dateFormat string, options map[string]interface{}) (LogFormatter, error) {
+		sep, err := submoduleSeparatorOption(options)
+		if err != nil {
+			return nil, err
+		}
+		rendering, err := fieldRenderingOption(options)
+		return YAMLFormatter{dateFormat: dateFormat, SubmoduleSeparator: sep, FieldRendering: rendering}, err
+	}, keys: structuredKeys()}, "yamlformatter", "yaml", "yml")
+	register(formatterEntry{factory: func(dateFormat string, options map[string]interface{}) (LogFormatter, error) {
+		compact, err := boolOption(options, "compact", false)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := submoduleSeparatorOption(options)
+		if err != nil {
+			return nil, err
+		}
+		rendering, err := fieldRenderingOption(options)
+		return XMLFormatter{dateFormat: dateFormat, Compact: compact, SubmoduleSeparator: sep, FieldRendering: rendering}, err
+	}, keys: structuredKeys("compact")}, "xmlformatter", "xml")
+	register(formatterEntry{factory: func(dateFormat string, options map[string]interface{}) (LogFormatter, error) {
+		header, err := boolOption(options, "header", true)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := submoduleSeparatorOption(options)
+		if err != nil {
+			return nil, err
+		}
+		rendering, err := fieldRenderingOption(options)
+		return CSVFormatter{dateFormat: dateFormat, NoHeader: !header, SubmoduleSeparator: sep, FieldRendering: rendering}, err
+	}, keys: structuredKeys("header")}, "csvformatter", "csv")
+}
+
+// structuredKeys returns the option keys of a structured formatter: its own keys, the submodule separator and
+// the field rendering.
+func structuredKeys(keys ...string) []string {
+	keys = append(keys, "submodule_separator")
+	return append(keys, fieldRenderingKeys...)
+}
+
+// unknownOptions returns the sorted option keys the formatter does not document.
+func (e formatterEntry) unknownOptions(options map[string]interface{}) []string {
+	var unknown []string
+	for key := range options {
+		known := false
+		for _, k := range e.keys {
+			if k == key {
+				known = true
+				break
+			}
+		}
+		if !known {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// boolOption returns the boolean option of the key, or the default when the key is not set.
+func boolOption(options map[string]interface{}, key string, def bool) (bool, error) {
+	v, ok := options[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return def, fmt.Errorf("option %s must be a boolean, got %v", key, v)
+	}
+	return b, nil
+}
@@ -4,4 +4,4 @@ go 1.20
 
 require gopkg.in/yaml.v2 v2.4.0
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,23 @@
+module github.com/SHEP4RDO/mklog/grpclog
+
+go 1.20
+
+require (
+	github.com/SHEP4RDO/mklog v0.1.0
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.30.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// Builds inside the repository use the root module next to this one; dependents resolve the required release,
+// which must be tagged before this module is.
+replace github.com/SHEP4RDO/mklog => ../
@@ -0,0 +1,244 @@
+// Package grpclog provides gRPC server interceptors logging requests through an mklog Debugger.
+//
+// It lives in its own module so the core package does not depend on gRPC. Each call is logged with the
+// method, peer, duration_ms and code as structured fields, at a level derived from the gRPC status code.
+// The recovery interceptors convert panics of handlers into codes.Internal and log them at Error with the
+// stack of the panic, so a misbehaving handler never takes the process down.
+package grpclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SHEP4RDO/mklog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// Default settings of the interceptors
+	PayloadLimitDefault = 1024 // Default number of bytes of a logged payload
+)
+
+// Option configures the interceptors.
+type Option func(*options)
+
+// options holds the settings of the interceptors.
+type options struct {
+	skip         map[string]bool
+	payloads     bool
+	payloadLimit int
+	levels       func(codes.Code) mklog.LogLevel
+}
+
+// WithSkipMethods disables logging of the given full method names, e.g. "/grpc.health.v1.Health/Check".
+func WithSkipMethods(methods ...string) Option {
+	return func(o *options) {
+		for _, m := range methods {
+			o.skip[m] = true
+		}
+	}
+}
+
+// WithPayloadLogging logs request and response messages at the Trace level, cut to limit bytes.
+// A limit of zero or less uses PayloadLimitDefault.
+func WithPayloadLogging(limit int) Option {
+	return func(o *options) {
+		o.payloads = true
+		if limit <= 0 {
+			limit = PayloadLimitDefault
+		}
+		o.payloadLimit = limit
+	}
+}
+
+// WithCodeLevels replaces the mapping of gRPC codes onto log levels.
+func WithCodeLevels(levels func(codes.Code) mklog.LogLevel) Option {
+	return func(o *options) {
+		o.levels = levels
+	}
+}
+
+// newOptions applies the options to the defaults.
+func newOptions(opts []Option) *options {
+	o := &options{
+		skip:         make(map[string]bool),
+		payloadLimit: PayloadLimitDefault,
+		levels:       CodeLevel,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// CodeLevel is the default mapping of gRPC codes onto log levels: server faults are errors,
+// conditions the client may resolve by retrying are warnings and client mistakes are informational.
+func CodeLevel(code codes.Code) mklog.LogLevel {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return mklog.InfoLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return mklog.WarningLevel
+	default:
+		return mklog.ErrorLevel
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor logging every unary call through the Debugger.
+func UnaryServerInterceptor(d *mklog.Debugger, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if o.skip[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		logger := d.With("method", info.FullMethod, "peer", peerAddr(ctx))
+		if o.payloads {
+			logger.Custom(mklog.TraceLevel, "%s request: %s", info.FullMethod, payload(req, o.payloadLimit))
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		elapsed := time.Since(start)
+
+		if o.payloads && err == nil {
+			logger.Custom(mklog.TraceLevel, "%s response: %s", info.FullMethod, payload(resp, o.payloadLimit))
+		}
+		logCall(logger, o, info.FullMethod, elapsed, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor logging every streaming call through the Debugger
+// once the stream has finished.
+func StreamServerInterceptor(d *mklog.Debugger, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if o.skip[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		logger := d.With("method", info.FullMethod, "peer", peerAddr(ss.Context()))
+		stream := &loggedStream{ServerStream: ss, logger: logger, options: o, method: info.FullMethod}
+
+		start := time.Now()
+		err := handler(srv, stream)
+		elapsed := time.Since(start)
+
+		logCall(logger.With("received", stream.received, "sent", stream.sent), o, info.FullMethod, elapsed, err)
+		return err
+	}
+}
+
+// UnaryServerRecovery returns an interceptor converting panics of unary handlers into codes.Internal.
+// It should be the innermost interceptor, so the logging interceptor sees the resulting status.
+func UnaryServerRecovery(d *mklog.Debugger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(d, ctx, info.FullMethod, r, "github.com/SHEP4RDO/mklog/grpclog.UnaryServerRecovery.")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerRecovery returns an interceptor converting panics of streaming handlers into codes.Internal.
+func StreamServerRecovery(d *mklog.Debugger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(d, ss.Context(), info.FullMethod, r, "github.com/SHEP4RDO/mklog/grpclog.StreamServerRecovery.")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs the panic with its stack at the Error level and returns the status sent to the client.
+// The stack ends at the recovery interceptor, whose functions start with boundary.
+func recovered(d *mklog.Debugger, ctx context.Context, method string, r interface{}, boundary string) error {
+	de := mklog.NewPanicError(r, boundary, method)
+	d.With("method", method, "peer", peerAddr(ctx), "code", codes.Internal.String(), "stack", de.StackInfo).
+		Error("%s panicked: %v", method, de)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// logCall writes the entry of a finished call, passing a DetailedError through so its stack is rendered.
+func logCall(logger *mklog.Logger, o *options, method string, elapsed time.Duration, err error) {
+	code := status.Code(err)
+	logger = logger.With("code", code.String(), "duration_ms", float64(elapsed)/float64(time.Millisecond))
+	if err == nil {
+		logger.Custom(o.levels(code), "%s finished: %s", method, code)
+		return
+	}
+
+	var de mklog.DetailedError
+	if errors.As(err, &de) {
+		logger.Custom(o.levels(code), "%s finished: %s: %v", method, code, de)
+		return
+	}
+	logger.Custom(o.levels(code), "%s finished: %s: %v", method, code, err)
+}
+
+// peerAddr returns the address of the client of the call.
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// payload renders a message for the Trace log, cut to at most limit bytes at a rune boundary.
+func payload(msg interface{}, limit int) string {
+	s := fmt.Sprintf("%v", msg)
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "...(truncated)"
+}
+
+// loggedStream counts the messages of a stream and logs their payloads when enabled.
+type loggedStream struct {
+	grpc.ServerStream
+	logger   *mklog.Logger
+	options  *options
+	method   string
+	received int
+	sent     int
+}
+
+// RecvMsg receives a message from the client.
+func (s *loggedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		if s.options.payloads {
+			s.logger.Custom(mklog.TraceLevel, "%s received: %s", s.method, payload(m, s.options.payloadLimit))
+		}
+	}
+	return err
+}
+
+// SendMsg sends a message to the client.
+func (s *loggedStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		if s.options.payloads {
+			s.logger.Custom(mklog.TraceLevel, "%s sent: %s", s.method, payload(m, s.options.payloadLimit))
+		}
+	}
+	return err
+}
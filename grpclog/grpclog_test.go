@@ -0,0 +1,292 @@
+package grpclog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/SHEP4RDO/mklog"
+	"github.com/SHEP4RDO/mklog/logtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Methods of the echo service of the tests.
+const (
+	echoMethod = "/test.Echo/Echo"
+	chatMethod = "/test.Echo/Chat"
+)
+
+// echoService is the handler type of the echo service; the handlers do not need a server implementation.
+type echoService interface{}
+
+// echo answers the request with its value. "panic" panics and "code:N" fails with the gRPC code N.
+func echo(ctx context.Context, req interface{}) (interface{}, error) {
+	value := req.(*wrapperspb.StringValue).GetValue()
+	if value == "panic" {
+		panic("kaboom")
+	}
+	if n, ok := strings.CutPrefix(value, "code:"); ok {
+		code, _ := strconv.Atoi(n)
+		return nil, status.Error(codes.Code(code), "failed on purpose")
+	}
+	return wrapperspb.String(value), nil
+}
+
+// echoHandler decodes the request and runs echo through the interceptor.
+func echoHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return echo(ctx, in)
+	}
+	return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: echoMethod}, echo)
+}
+
+// chatHandler echoes every message of the stream until the client closes it; "panic" panics.
+func chatHandler(srv interface{}, stream grpc.ServerStream) error {
+	for {
+		in := new(wrapperspb.StringValue)
+		if err := stream.RecvMsg(in); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if in.GetValue() == "panic" {
+			panic("kaboom")
+		}
+		if err := stream.SendMsg(in); err != nil {
+			return err
+		}
+	}
+}
+
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*echoService)(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "Echo", Handler: echoHandler}},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Chat", Handler: chatHandler, ServerStreams: true, ClientStreams: true},
+	},
+}
+
+// newServer starts the echo service with the logging and recovery interceptors on a bufconn listener and
+// returns a connection to it and the recorder of the entries of the Debugger, which logs every level.
+func newServer(t *testing.T, opts ...Option) (*grpc.ClientConn, *logtest.Recorder) {
+	t.Helper()
+	d := (&mklog.Debugger{}).SetQuiet(true)
+	d.NewLogRule("grpc", mklog.WithFileWriter(io.Discard), mklog.WithMinLevel(mklog.TraceLevel),
+		mklog.WithVerbosity(mklog.TraceLevel))
+	rec := logtest.Capture(t, d)
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(d, opts...), UnaryServerRecovery(d)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(d, opts...), StreamServerRecovery(d)),
+	)
+	srv.RegisterService(&echoServiceDesc, struct{}{})
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+		d.Close(context.Background())
+	})
+	return conn, rec
+}
+
+// call invokes the unary echo method and returns the code of the result.
+func call(t *testing.T, conn *grpc.ClientConn, value string) codes.Code {
+	t.Helper()
+	err := conn.Invoke(context.Background(), echoMethod, wrapperspb.String(value), new(wrapperspb.StringValue))
+	return status.Code(err)
+}
+
+// TestUnaryCodeLevels logs each finished call with its fields at the level mapped from its code.
+func TestUnaryCodeLevels(t *testing.T) {
+	tests := []struct {
+		value string
+		code  codes.Code
+		level mklog.LogLevel
+	}{
+		{"hello", codes.OK, mklog.InfoLevel},
+		{"code:5", codes.NotFound, mklog.InfoLevel},
+		{"code:14", codes.Unavailable, mklog.WarningLevel},
+		{"code:4", codes.DeadlineExceeded, mklog.WarningLevel},
+		{"code:13", codes.Internal, mklog.ErrorLevel},
+		{"code:2", codes.Unknown, mklog.ErrorLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			conn, rec := newServer(t)
+			if got := call(t, conn, tt.value); got != tt.code {
+				t.Fatalf("code = %v, want %v", got, tt.code)
+			}
+
+			entry := rec.ExpectEntry(tt.level, echoMethod+" finished: "+tt.code.String())
+			if entry.Fields["code"] != tt.code.String() || entry.Fields["method"] != echoMethod || entry.Fields["peer"] != "bufconn" {
+				t.Errorf("fields = %v, want the code, method and peer of the call", entry.Fields)
+			}
+			if _, ok := entry.Fields["duration_ms"].(float64); !ok {
+				t.Errorf("duration_ms = %#v, want a float64", entry.Fields["duration_ms"])
+			}
+		})
+	}
+}
+
+// TestUnaryCustomCodeLevels replaces the mapping of codes onto levels.
+func TestUnaryCustomCodeLevels(t *testing.T) {
+	conn, rec := newServer(t, WithCodeLevels(func(code codes.Code) mklog.LogLevel {
+		if code == codes.NotFound {
+			return mklog.ErrorLevel
+		}
+		return CodeLevel(code)
+	}))
+	call(t, conn, "code:5")
+	rec.ExpectEntry(mklog.ErrorLevel, "finished: NotFound")
+}
+
+// TestUnaryPanic answers a panicking handler with codes.Internal and logs the panic with its stack, followed by
+// the finished call at Error.
+func TestUnaryPanic(t *testing.T) {
+	conn, rec := newServer(t)
+	if got := call(t, conn, "panic"); got != codes.Internal {
+		t.Fatalf("code = %v, want Internal", got)
+	}
+	if got := call(t, conn, "hello"); got != codes.OK {
+		t.Fatalf("code after the panic = %v, want OK", got)
+	}
+
+	entry := rec.ExpectEntry(mklog.ErrorLevel, echoMethod+" panicked: ")
+	if stack, _ := entry.Fields["stack"].(string); !strings.Contains(stack, "grpclog.echo") {
+		t.Errorf("stack = %q, want the panicking handler", stack)
+	}
+	rec.ExpectOrdered(
+		logtest.Want(mklog.ErrorLevel, "panicked: "),
+		logtest.Want(mklog.ErrorLevel, "finished: Internal"),
+		logtest.Want(mklog.InfoLevel, "finished: OK"),
+	)
+	rec.ExpectNoEntries(mklog.FatalLevel)
+}
+
+// TestUnaryPayloads logs the request and response at Trace, cut to the limit without splitting a rune, and
+// logs no payloads by default.
+func TestUnaryPayloads(t *testing.T) {
+	conn, rec := newServer(t, WithPayloadLogging(16))
+	call(t, conn, "grüße aus köln und münchen")
+
+	request := rec.ExpectEntry(mklog.TraceLevel, echoMethod+" request: ")
+	response := rec.ExpectEntry(mklog.TraceLevel, echoMethod+" response: ")
+	for _, entry := range []mklog.Entry{request, response} {
+		if !strings.HasSuffix(entry.Message, "...(truncated)") || !utf8.ValidString(entry.Message) {
+			t.Errorf("payload entry = %q, want valid UTF-8 cut at the limit", entry.Message)
+		}
+	}
+
+	conn, rec = newServer(t)
+	call(t, conn, "hello")
+	rec.ExpectNoEntries(mklog.TraceLevel)
+}
+
+// TestSkipMethods logs no entries for skipped methods.
+func TestSkipMethods(t *testing.T) {
+	conn, rec := newServer(t, WithSkipMethods(echoMethod), WithPayloadLogging(0))
+	call(t, conn, "hello")
+	if entries := rec.Entries(); len(entries) != 0 {
+		t.Errorf("entries = %v, want none for a skipped method", entries)
+	}
+}
+
+// chat sends the values on the chat stream, closes it and returns the code it ended with.
+func chat(t *testing.T, conn *grpc.ClientConn, values ...string) codes.Code {
+	t.Helper()
+	stream, err := conn.NewStream(context.Background(), &echoServiceDesc.Streams[0], chatMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		if err := stream.SendMsg(wrapperspb.String(v)); err != nil {
+			break
+		}
+	}
+	stream.CloseSend()
+	for {
+		if err := stream.RecvMsg(new(wrapperspb.StringValue)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return codes.OK
+			}
+			return status.Code(err)
+		}
+	}
+}
+
+// TestStreamCounts logs a finished stream with the number of messages received and sent, and the payloads of
+// the messages at Trace.
+func TestStreamCounts(t *testing.T) {
+	conn, rec := newServer(t, WithPayloadLogging(0))
+	if got := chat(t, conn, "one", "two", "three"); got != codes.OK {
+		t.Fatalf("code = %v, want OK", got)
+	}
+
+	entry := rec.ExpectEntry(mklog.InfoLevel, chatMethod+" finished: OK")
+	if entry.Fields["received"] != 3 || entry.Fields["sent"] != 3 || entry.Fields["method"] != chatMethod {
+		t.Errorf("fields = %v, want 3 messages received and sent", entry.Fields)
+	}
+	rec.ExpectOrdered(
+		logtest.Want(mklog.TraceLevel, chatMethod+" received: value:\"one\""),
+		logtest.Want(mklog.TraceLevel, chatMethod+" sent: value:\"one\""),
+		logtest.Want(mklog.TraceLevel, chatMethod+" received: value:\"three\""),
+		logtest.Want(mklog.InfoLevel, chatMethod+" finished: OK"),
+	)
+}
+
+// TestStreamPanic ends a stream whose handler panics with codes.Internal and logs the panic.
+func TestStreamPanic(t *testing.T) {
+	conn, rec := newServer(t)
+	if got := chat(t, conn, "one", "panic"); got != codes.Internal {
+		t.Fatalf("code = %v, want Internal", got)
+	}
+	rec.ExpectOrdered(
+		logtest.Want(mklog.ErrorLevel, chatMethod+" panicked: "),
+		logtest.Want(mklog.ErrorLevel, chatMethod+" finished: Internal"),
+	)
+}
+
+// TestPayloadTruncation cuts payloads at a rune boundary at or below the limit.
+func TestPayloadTruncation(t *testing.T) {
+	tests := []struct {
+		msg   string
+		limit int
+		want  string
+	}{
+		{"short", 16, "short"},
+		{"exactly8", 8, "exactly8"},
+		{"abcdefgh", 4, "abcd...(truncated)"},
+		{"aé", 2, "a...(truncated)"},   // é is two bytes, the second would be cut.
+		{"日本語", 4, "日...(truncated)"},  // Each rune is three bytes.
+		{"日本語", 2, "...(truncated)"},   // Not even the first rune fits.
+		{"€uro", 3, "€...(truncated)"}, // The limit ends on a rune boundary.
+	}
+	for _, tt := range tests {
+		if got := payload(tt.msg, tt.limit); got != tt.want {
+			t.Errorf("payload(%q, %d) = %q, want %q", tt.msg, tt.limit, got, tt.want)
+		}
+	}
+}
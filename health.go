@@ -0,0 +1,69 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Healthy reports whether the Debugger can still persist logs: the log file of every rule with file
+// logging is open and its last write succeeded, and no async queue is at the high watermark or bypassed
+// by the watchdog. The problems of all rules are joined into one error. It only inspects in-memory state
+// and open file handles, so it is cheap enough to call every few seconds.
+func (d *Debugger) Healthy(ctx context.Context) error {
+	var errs []error
+	for module, rules := range d.rules() {
+		for i, v := range rules {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := v.healthy(); err != nil {
+				errs = append(errs, fmt.Errorf("[mklog] rule %s/%d: %w", module, i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HealthHandler returns an http.Handler answering 200 when the Debugger is healthy and 503 with the
+// problems otherwise, e.g. for a Kubernetes readiness probe.
+func (d *Debugger) HealthHandler() http.Handler {
+	return http.HandlerFunc(d.handleHealth)
+}
+
+// handleHealth writes the result of Healthy.
+func (d *Debugger) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := d.Healthy(r.Context()); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// healthy checks the log file and the async queue of the rule.
+func (lr *LogRule) healthy() error {
+	var errs []error
+	if lr.FileLog.Enable {
+		if writer := lr.FileLog.writer; writer != nil {
+			if err := writer.Healthy(); err != nil {
+				errs = append(errs, err)
+			}
+		} else if lr.FileLog.target == nil {
+			errs = append(errs, fmt.Errorf("log file is not open"))
+		}
+	}
+
+	if lr.AsyncLog.Enable {
+		depth, capacity := len(lr.logChannel), cap(lr.logChannel)
+		if capacity > 0 && float64(depth) >= float64(capacity)*MKLOG_AsyncHighWatermarkDefault {
+			errs = append(errs, fmt.Errorf("async queue is full: %d/%d", depth, capacity))
+		}
+		if lr.degraded() {
+			errs = append(errs, fmt.Errorf("async consumer is stalled, writing to the console only"))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,96 @@
+package mklog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// blockingWriter blocks every write until it is released, signalling each write that started.
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.started <- struct{}{}
+	<-w.release
+	return len(p), nil
+}
+
+// probe returns the status code and body of the health handler of the Debugger.
+func probe(d *Debugger) (int, string) {
+	rec := httptest.NewRecorder()
+	d.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	return rec.Code, rec.Body.String()
+}
+
+// TestHealthyWithOpenFile reports a rule writing to an open log file as healthy, also through the handler
+// and the admin endpoint.
+func TestHealthyWithOpenFile(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileLogging(t.TempDir(), "app", ".log"), WithAsyncLog(true, 8))
+	defer d.Close(context.Background())
+	d.Info("written")
+
+	if err := d.Healthy(context.Background()); err != nil {
+		t.Fatalf("Healthy = %v", err)
+	}
+	if code, body := probe(d); code != http.StatusOK || body != "ok\n" {
+		t.Errorf("handler = %d %q, want 200", code, body)
+	}
+	rec := httptest.NewRecorder()
+	d.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("admin /health = %d, want 200", rec.Code)
+	}
+}
+
+// TestHealthyWithClosedFile reports the rule whose log file was closed underneath it.
+func TestHealthyWithClosedFile(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("api", WithFileLogging(t.TempDir(), "api", ".log"))
+	defer d.Close(context.Background())
+
+	if err := d.rules()["api"][0].fileWriter().Close(); err != nil {
+		t.Fatal(err)
+	}
+	err := d.Healthy(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rule api/0") || !strings.Contains(err.Error(), "log file is not open") {
+		t.Fatalf("Healthy = %v, want the closed file of rule api/0", err)
+	}
+	if code, body := probe(d); code != http.StatusServiceUnavailable || !strings.Contains(body, "log file is not open") {
+		t.Errorf("handler = %d %q, want 503 naming the closed file", code, body)
+	}
+}
+
+// TestHealthyWithFullQueue reports the async queue of a rule whose writer is stuck once the queue fills up.
+func TestHealthyWithFullQueue(t *testing.T) {
+	const capacity = 4
+	w := &blockingWriter{started: make(chan struct{}, capacity+1), release: make(chan struct{})}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(w), WithAsyncLog(true, capacity))
+
+	d.Info("stuck")
+	<-w.started
+	if err := d.Healthy(context.Background()); err != nil {
+		t.Fatalf("Healthy with an empty queue = %v", err)
+	}
+	for i := 0; i < capacity; i++ {
+		d.Info("queued %d", i)
+	}
+	err := d.Healthy(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "async queue is full: 4/4") {
+		t.Fatalf("Healthy = %v, want a full queue", err)
+	}
+	if code, _ := probe(d); code != http.StatusServiceUnavailable {
+		t.Errorf("handler = %d, want 503", code)
+	}
+
+	close(w.release)
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
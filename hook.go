@@ -0,0 +1,230 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Hook is an interface for components notified about log entries accepted by a rule.
+type Hook interface {
+	// Levels returns the log levels the hook should be fired for.
+	Levels() []LogLevel
+	// Fire is called for each entry with one of the hook's levels. It must not block the caller.
+	Fire(entry Entry) error
+}
+
+// ErrorHandlerSetter is implemented by hooks and sinks reporting background failures on their own.
+// The Debugger passes its error handler to them when they are attached.
+type ErrorHandlerSetter interface {
+	SetErrorHandler(handler ErrorHandler)
+}
+
+// LevelMatcher is implemented by hooks deciding for every level whether they are fired, e.g. to receive the
+// entries of custom levels too. Levels is not consulted for such hooks.
+type LevelMatcher interface {
+	FiresFor(level LogLevel) bool
+}
+
+// hookList publishes the hooks of an active rule, so that they can be attached and detached while it logs.
+type hookList struct {
+	mu    sync.Mutex             // Serializes changes of the hooks
+	hooks atomic.Pointer[[]Hook] // Hooks read by log calls; nil until the first change, leaving the Hooks field in effect
+}
+
+// AddHook attaches the hook to every log rule of the Debugger instance, including rules added afterwards and
+// fallback rules. It is safe while logging: a log call in progress completes with the previous hooks.
+func (d *Debugger) AddHook(hook Hook) *Debugger {
+	if h, ok := hook.(ErrorHandlerSetter); ok {
+		h.SetErrorHandler(d.handleError)
+	}
+	d.hooksMu.Lock()
+	defer d.hooksMu.Unlock()
+	hooks := append(d.attachedHooks(), hook)
+	d.hooks.Store(&hooks)
+	return d
+}
+
+// AddHook attaches the hook to the log rule. Hooks of an active rule are replaced atomically, so it is safe
+// while the rule logs.
+func (d *LogRule) AddHook(hook Hook) *LogRule {
+	if h, ok := hook.(ErrorHandlerSetter); ok {
+		h.SetErrorHandler(d.reportError)
+	}
+	d.updateHooks(func(hooks []Hook) []Hook {
+		return append(hooks, hook)
+	})
+	return d
+}
+
+// RemoveHook detaches the hook from the Debugger instance and from every log rule it was attached to.
+// Hooks are matched with ==, so a hook of a type that is not comparable, e.g. a struct holding a slice, is never
+// removed; attach such hooks by pointer.
+func (d *Debugger) RemoveHook(hook Hook) *Debugger {
+	d.hooksMu.Lock()
+	hooks := withoutHook(d.attachedHooks(), hook)
+	d.hooks.Store(&hooks)
+	d.hooksMu.Unlock()
+
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			v.RemoveHook(hook)
+		}
+	}
+	for _, v := range d.fallbackRules().snapshot(false) {
+		v.RemoveHook(hook)
+	}
+	return d
+}
+
+// RemoveHook detaches the hook from the log rule. Like Debugger.RemoveHook it cannot remove hooks of a type
+// that is not comparable.
+func (d *LogRule) RemoveHook(hook Hook) *LogRule {
+	d.updateHooks(func(hooks []Hook) []Hook {
+		return withoutHook(hooks, hook)
+	})
+	return d
+}
+
+// attachedHooks returns the hooks attached to the Debugger with AddHook. The slice is never modified.
+func (d *Debugger) attachedHooks() []Hook {
+	if hooks := d.hooks.Load(); hooks != nil {
+		return (*hooks)[:len(*hooks):len(*hooks)]
+	}
+	return nil
+}
+
+// hooks returns the hooks of the rule. The slice of an active rule is never modified, so it can be iterated
+// without locks while hooks are attached and detached; changes replace it, see updateHooks.
+func (lr *LogRule) hooks() []Hook {
+	if lr.hookList != nil {
+		if hooks := lr.hookList.hooks.Load(); hooks != nil {
+			return *hooks
+		}
+	}
+	return lr.Hooks
+}
+
+// updateHooks applies the change to the hooks of the rule. The hooks of an active rule are published as a new
+// slice, the ones of a rule being built are changed in place. The change must not modify the slice it is passed.
+func (lr *LogRule) updateHooks(change func(hooks []Hook) []Hook) {
+	if lr.hookList == nil {
+		lr.Hooks = change(lr.Hooks)
+		return
+	}
+	lr.hookList.mu.Lock()
+	defer lr.hookList.mu.Unlock()
+	current := lr.hooks()
+	hooks := change(current[:len(current):len(current)])
+	lr.hookList.hooks.Store(&hooks)
+}
+
+// withoutHook returns a copy of the hooks without the hook.
+func withoutHook(hooks []Hook, hook Hook) []Hook {
+	remaining := hooks[:0:0]
+	for _, h := range hooks {
+		if !sameHook(h, hook) {
+			remaining = append(remaining, h)
+		}
+	}
+	return remaining
+}
+
+// fireHooks passes the entry to every hook of the rule and of the Debugger registered for the entry level,
+// returning the errors of the failed hooks named after them.
+func (lr *LogRule) fireHooks(entry Entry) []error {
+	hooks := lr.hooks()
+	errs := lr.fireHookList(hooks, 0, entry, nil)
+	if lr.debugger != nil {
+		errs = lr.fireHookList(lr.debugger.attachedHooks(), len(hooks), entry, errs)
+	}
+	return errs
+}
+
+// fireHookList fires the hooks for the entry, appending the errors to errs. Hooks are named by their index
+// starting at offset.
+func (lr *LogRule) fireHookList(hooks []Hook, offset int, entry Entry, errs []error) []error {
+	for i, hook := range hooks {
+		if !hookHasLevel(hook, entry.Level) {
+			continue
+		}
+		if err := lr.fireHook(hook, entry); err != nil {
+			errs = append(errs, fmt.Errorf("hook %s: %w", outputName(offset+i, hook), err))
+		}
+	}
+	return errs
+}
+
+// hookHasLevel checks whether the hook is registered for the log level.
+func hookHasLevel(hook Hook, level LogLevel) bool {
+	if m, ok := hook.(LevelMatcher); ok {
+		return m.FiresFor(level)
+	}
+	for _, l := range hook.Levels() {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// closeNotifyHooks closes the hooks implementing io.Closer that are attached to the Debugger or to its rules, each one
+// once, so that notifiers deliver their queued entries. It gives up waiting when ctx expires and returns the
+// errors of the failed hooks named after them.
+func (d *Debugger) closeNotifyHooks(ctx context.Context) []error {
+	hooks := d.attachedHooks()
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			hooks = append(hooks, v.hooks()...)
+		}
+	}
+	for _, v := range d.fallbackRules().snapshot(false) {
+		hooks = append(hooks, v.hooks()...)
+	}
+
+	var closers []io.Closer
+	for i, hook := range hooks {
+		closer, ok := hook.(io.Closer)
+		if !ok || hookIndex(hooks[:i], hook) >= 0 {
+			continue
+		}
+		closers = append(closers, closer)
+	}
+
+	var errs []error
+	if !runWithContext(ctx, func() {
+		for i, closer := range closers {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("[mklog] failed to close hook %s: %w", outputName(i, closer), err))
+			}
+		}
+	}) {
+		return []error{fmt.Errorf("[mklog] hooks not closed: %w", ctx.Err())}
+	}
+	return errs
+}
+
+// hookIndex returns the index of the hook among the hooks, or -1.
+func hookIndex(hooks []Hook, hook Hook) int {
+	for i, h := range hooks {
+		if sameHook(h, hook) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameHook reports whether the hooks are equal, comparing hooks of types that are not comparable, which would
+// panic, as different.
+func sameHook(a, b Hook) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !reflect.TypeOf(a).Comparable() || !reflect.TypeOf(b).Comparable() {
+		return false
+	}
+	return a == b
+}
@@ -0,0 +1,135 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingHook counts the entries it is fired for.
+type countingHook struct {
+	fired int64
+}
+
+func (h *countingHook) Levels() []LogLevel     { return []LogLevel{InfoLevel} }
+func (h *countingHook) Fire(entry Entry) error { atomic.AddInt64(&h.fired, 1); return nil }
+
+// TestAddRemoveHookWhileLogging attaches and detaches hooks while several goroutines log, run with -race.
+func TestAddRemoveHookWhileLogging(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				d.Info("message %d", i)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		hook := &countingHook{}
+		d.AddHook(hook)
+		d.rules()["app"][0].AddHook(hook)
+		d.RemoveHook(hook)
+	}
+	wg.Wait()
+
+	hook := &countingHook{}
+	d.AddHook(hook)
+	d.NewLogRule("later", WithFileWriter(&syncBuffer{}))
+	d.Info("fired twice")
+	if got := atomic.LoadInt64(&hook.fired); got != 2 {
+		t.Errorf("hook fired %d times, want 2", got)
+	}
+}
+
+// sliceHook is a value hook that is not comparable, as it holds a slice.
+type sliceHook struct {
+	levels []LogLevel
+}
+
+func (h sliceHook) Levels() []LogLevel     { return h.levels }
+func (h sliceHook) Fire(entry Entry) error { return nil }
+
+// TestRemoveNonComparableHook removes hooks next to value hooks that are not comparable without panicking,
+// keeping the hooks that cannot be matched.
+func TestRemoveNonComparableHook(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	defer d.Close(context.Background())
+	rule := d.rules()["app"][0]
+
+	value, counting := sliceHook{levels: []LogLevel{InfoLevel}}, &countingHook{}
+	d.AddHook(value).AddHook(counting)
+	rule.AddHook(value).AddHook(counting)
+
+	d.RemoveHook(sliceHook{levels: []LogLevel{InfoLevel}})
+	d.RemoveHook(counting)
+	if hooks := d.attachedHooks(); len(hooks) != 1 {
+		t.Errorf("Debugger hooks = %v, want the value hook only", hooks)
+	}
+	if hooks := rule.hooks(); len(hooks) != 1 {
+		t.Errorf("rule hooks = %v, want the value hook only", hooks)
+	}
+	d.Info("still logging")
+	if n := atomic.LoadInt64(&counting.fired); n != 0 {
+		t.Errorf("removed hook fired %d times", n)
+	}
+}
+
+// orderedHook records the entries it is fired for and its Close in a shared event log.
+type orderedHook struct {
+	name   string
+	mu     *sync.Mutex
+	events *[]string
+}
+
+func (h orderedHook) Levels() []LogLevel { return []LogLevel{InfoLevel} }
+func (h orderedHook) Fire(entry Entry) error {
+	h.mu.Lock()
+	*h.events = append(*h.events, h.name+" "+entry.Message)
+	h.mu.Unlock()
+	return nil
+}
+func (h orderedHook) Close() error {
+	h.mu.Lock()
+	*h.events = append(*h.events, h.name+" closed")
+	h.mu.Unlock()
+	return nil
+}
+
+// TestHooksOnShutdown fires the hooks of the rule before those of the Debugger for every entry of an async rule
+// drained on Close, and closes each hook once after the last entry.
+func TestHooksOnShutdown(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithAsyncLog(true, 64))
+	d.rules()["app"][0].AddHook(orderedHook{"rule", &mu, &events})
+	d.AddHook(orderedHook{"debugger", &mu, &events})
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		d.Info("entry %d", i)
+		want = append(want, fmt.Sprintf("rule entry %d", i), fmt.Sprintf("debugger entry %d", i))
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	d.Info("after close")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(events[:len(events)-2], "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("fired\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+	if closes := events[len(events)-2:]; closes[0] != "debugger closed" || closes[1] != "rule closed" {
+		t.Errorf("closed %v, want the hook of the Debugger and then the one of the rule", closes)
+	}
+}
@@ -0,0 +1,207 @@
+// Package httplog provides an HTTP middleware writing one access log entry per request through an mklog Debugger.
+//
+// Entries carry the request as structured fields (method, path, status, bytes, duration_ms, remote_addr
+// and request_id), so structured formatters produce queryable output. The level follows the status code:
+// 5xx responses are logged at Error, 4xx at Warning and everything else at Info. Requests whose handler
+// panics are logged at Error too, as the panic unwinds through the middleware.
+//
+// Recoverer complements it by logging panics of handlers with their stack and answering them with a 500.
+package httplog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+	"net/http"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+var (
+	// Default settings of the middleware
+	RequestIDHeaderDefault = "X-Request-ID" // Default header carrying the request id
+	RequestIDMaxLength     = 128            // Longest request id accepted from a request header
+)
+
+// Option configures the middleware.
+type Option func(*options)
+
+// options holds the settings of the middleware.
+type options struct {
+	skip       map[string]bool
+	sampleRate float64
+	header     string
+	random     func() float64
+}
+
+// WithSkipPaths disables logging of requests to the given paths, e.g. health checks.
+func WithSkipPaths(paths ...string) Option {
+	return func(o *options) {
+		for _, p := range paths {
+			o.skip[p] = true
+		}
+	}
+}
+
+// WithSampleRate logs only the given fraction (0 to 1) of successful requests; 4xx and 5xx responses are always logged.
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.sampleRate = rate
+	}
+}
+
+// WithRequestIDHeader sets the header the request id is read from and echoed in.
+func WithRequestIDHeader(header string) Option {
+	return func(o *options) {
+		o.header = header
+	}
+}
+
+// requestIDKey is the context key of the request id.
+type requestIDKey struct{}
+
+// RequestID returns the request id assigned by the middleware, or "" outside of it.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware returns a middleware logging every request through the Debugger. The request id is taken from
+// the request header or generated, echoed in the response header and available to handlers through RequestID.
+// Ids from the header longer than RequestIDMaxLength or with characters other than letters, digits and
+// "-", "_", ".", ":" are replaced by a generated one, so clients cannot inject text into logs and responses.
+// The entry is written when the handler returns or panics; a panicking request is logged at Error with
+// status 500 unless the response was already started, and the panic is passed on.
+func Middleware(d *mklog.Debugger, opts ...Option) func(http.Handler) http.Handler {
+	o := &options{
+		skip:       make(map[string]bool),
+		sampleRate: 1,
+		header:     RequestIDHeaderDefault,
+		random:     mathrand.Float64,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if o.skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id := r.Header.Get(o.header)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(o.header, id)
+			r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			start := time.Now()
+			completed := false
+			defer func() {
+				elapsed := time.Since(start)
+				level := statusLevel(rw.status)
+				if !completed {
+					// The handler panicked; the panic keeps unwinding after the entry is written.
+					if !rw.wroteHeader {
+						rw.status = http.StatusInternalServerError
+					}
+					level = mklog.ErrorLevel
+				} else if rw.status < 400 && o.sampleRate < 1 && o.random() >= o.sampleRate {
+					return
+				}
+
+				d.With(
+					"method", r.Method,
+					"path", r.URL.Path,
+					"status", rw.status,
+					"bytes", rw.bytes,
+					"duration_ms", float64(elapsed)/float64(time.Millisecond),
+					"remote_addr", r.RemoteAddr,
+					"request_id", id,
+				).Custom(level, "%s %s %d", r.Method, r.URL.Path, rw.status)
+			}()
+			next.ServeHTTP(rw, r)
+			completed = true
+		})
+	}
+}
+
+// statusLevel returns the log level of a response status.
+func statusLevel(status int) mklog.LogLevel {
+	switch {
+	case status >= 500:
+		return mklog.ErrorLevel
+	case status >= 400:
+		return mklog.WarningLevel
+	default:
+		return mklog.InfoLevel
+	}
+}
+
+// validRequestID reports whether a request id from a request header can be used as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > RequestIDMaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random 128-bit request id.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// responseWriter records the status and the number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// WriteHeader records the status of the response.
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes of the response body.
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush flushes the response when the underlying writer supports it.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,189 @@
+package httplog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SHEP4RDO/mklog"
+	"github.com/SHEP4RDO/mklog/logtest"
+)
+
+// newAccessDebugger returns a Debugger discarding its output and the recorder of its entries.
+func newAccessDebugger(t *testing.T) (*mklog.Debugger, *logtest.Recorder) {
+	t.Helper()
+	d := (&mklog.Debugger{}).SetQuiet(true)
+	d.NewLogRule("http", mklog.WithFileWriter(&strings.Builder{}))
+	t.Cleanup(func() { d.Close(context.Background()) })
+	return d, logtest.Capture(t, d)
+}
+
+// statusHandler answers every request with the status.
+func statusHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("body"))
+	})
+}
+
+// withRandom replaces the random source of the sampling.
+func withRandom(values ...float64) Option {
+	return func(o *options) {
+		o.random = func() float64 {
+			v := values[0]
+			values = values[1:]
+			return v
+		}
+	}
+}
+
+// TestMiddlewareStatusLevels logs each request once with its fields at the level of the status.
+func TestMiddlewareStatusLevels(t *testing.T) {
+	tests := []struct {
+		status int
+		level  mklog.LogLevel
+	}{
+		{http.StatusOK, mklog.InfoLevel},
+		{http.StatusFound, mklog.InfoLevel},
+		{http.StatusNotFound, mklog.WarningLevel},
+		{http.StatusTooManyRequests, mklog.WarningLevel},
+		{http.StatusInternalServerError, mklog.ErrorLevel},
+		{http.StatusBadGateway, mklog.ErrorLevel},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			d, rec := newAccessDebugger(t)
+			h := Middleware(d)(statusHandler(tt.status))
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := rec.Entries()
+			if len(entries) != 1 || entries[0].Level != tt.level {
+				t.Fatalf("entries = %v, want one entry at %v", entries, tt.level)
+			}
+			fields := entries[0].Fields
+			if fields["method"] != "GET" || fields["path"] != "/orders" || fields["status"] != tt.status ||
+				fields["bytes"] != int64(4) || fields["remote_addr"] != req.RemoteAddr {
+				t.Errorf("fields = %v, want the request and the response", fields)
+			}
+			if _, ok := fields["duration_ms"].(float64); !ok {
+				t.Errorf("duration_ms = %#v, want a float64", fields["duration_ms"])
+			}
+		})
+	}
+}
+
+// TestMiddlewareSkipPaths passes skipped paths to the handler without logging them.
+func TestMiddlewareSkipPaths(t *testing.T) {
+	d, rec := newAccessDebugger(t)
+	h := Middleware(d, WithSkipPaths("/healthz"))(statusHandler(http.StatusOK))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	if w.Body.String() != "body" || w.Header().Get(RequestIDHeaderDefault) != "" {
+		t.Errorf("skipped response = %q with request id %q, want the handler answer only", w.Body.String(),
+			w.Header().Get(RequestIDHeaderDefault))
+	}
+	entries := rec.Entries()
+	if len(entries) != 1 || entries[0].Message != "GET /orders 200" {
+		t.Errorf("entries = %v, want only the orders request", entries)
+	}
+}
+
+// TestMiddlewareSampling logs the sampled fraction of successful requests and every failed request.
+func TestMiddlewareSampling(t *testing.T) {
+	d, rec := newAccessDebugger(t)
+	ok := Middleware(d, WithSampleRate(0.5), withRandom(0.2, 0.7, 0.5))(statusHandler(http.StatusOK))
+	for _, path := range []string{"/a", "/b", "/c"} {
+		ok.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	failed := Middleware(d, WithSampleRate(0), withRandom())(statusHandler(http.StatusServiceUnavailable))
+	failed.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/d", nil))
+
+	rec.ExpectOrdered(
+		logtest.Want(mklog.InfoLevel, "GET /a 200"),
+		logtest.Want(mklog.ErrorLevel, "GET /d 503"),
+	)
+	if entries := rec.Entries(); len(entries) != 2 {
+		t.Errorf("entries = %v, want the sampled and the failed request", entries)
+	}
+}
+
+// TestMiddlewareRequestID passes a valid request id from the header on to the handler, the response and the
+// entry, and replaces missing and invalid ones by a generated id.
+func TestMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"valid", "req-42_a.b:c", true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("a", RequestIDMaxLength+1), false},
+		{"longest", strings.Repeat("a", RequestIDMaxLength), true},
+		{"newline", "abc\nGET /admin 200", false},
+		{"space", "abc def", false},
+		{"non-ASCII", "käse", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, rec := newAccessDebugger(t)
+			var seen string
+			h := Middleware(d, WithRequestIDHeader("X-Trace"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestID(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Trace", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if tt.keep && seen != tt.header {
+				t.Errorf("request id = %q, want the header", seen)
+			}
+			if !tt.keep && (len(seen) != 32 || seen == tt.header) {
+				t.Errorf("request id = %q, want a generated id", seen)
+			}
+			if got := w.Header().Get("X-Trace"); got != seen {
+				t.Errorf("response header = %q, want %q", got, seen)
+			}
+			if got := rec.ExpectEntry(mklog.InfoLevel, "GET /orders 200").Fields["request_id"]; got != seen {
+				t.Errorf("request_id field = %v, want %q", got, seen)
+			}
+		})
+	}
+}
+
+// TestMiddlewareLogsPanic logs a request whose handler panics at Error with status 500 and passes the panic on.
+func TestMiddlewareLogsPanic(t *testing.T) {
+	d, rec := newAccessDebugger(t)
+	h := Middleware(d, WithSampleRate(0), withRandom())(http.HandlerFunc(explode))
+
+	if v := serve(h, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/7", nil)); v != "kaboom" {
+		t.Fatalf("panic = %v, want the panic of the handler passed on", v)
+	}
+	entry := rec.ExpectEntry(mklog.ErrorLevel, "GET /orders/7 500")
+	if entry.Fields["status"] != http.StatusInternalServerError {
+		t.Errorf("status field = %v, want 500", entry.Fields["status"])
+	}
+}
+
+// TestMiddlewareWithRecoverer reports the 500 written by Recoverer installed inside the middleware.
+func TestMiddlewareWithRecoverer(t *testing.T) {
+	d, rec := newAccessDebugger(t)
+	h := Middleware(d)(Recoverer(d)(http.HandlerFunc(explode)))
+
+	w := httptest.NewRecorder()
+	if v := serve(h, w, httptest.NewRequest(http.MethodGet, "/orders/7", nil)); v != nil {
+		t.Fatalf("panic = %v, want it recovered", v)
+	}
+	rec.ExpectOrdered(
+		logtest.Want(mklog.ErrorLevel, "panic serving GET /orders/7: kaboom"),
+		logtest.Want(mklog.ErrorLevel, "GET /orders/7 500"),
+	)
+}
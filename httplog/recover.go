@@ -0,0 +1,71 @@
+package httplog
+
+import (
+	"net/http"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+// RecoverOption configures the Recoverer middleware.
+type RecoverOption func(*recoverOptions)
+
+// recoverOptions holds the settings of the Recoverer middleware.
+type recoverOptions struct {
+	repanic bool
+}
+
+// WithRepanic panics again with the original value after the panic was logged and answered,
+// e.g. in development to let a debugger or the test runner see the panic.
+func WithRepanic(repanic bool) RecoverOption {
+	return func(o *recoverOptions) {
+		o.repanic = repanic
+	}
+}
+
+// Recoverer returns a middleware recovering from panics of the handler. The panic is logged at Error
+// with a DetailedError holding the panic value and the stack from the panicking function down to the
+// handler, and a 500 response is written unless the handler already started the response.
+// Panics with http.ErrAbortHandler are passed on, as they abort the response on purpose.
+// Installed inside Middleware, the access log entry reports the 500 status of recovered requests.
+func Recoverer(d *mklog.Debugger, opts ...RecoverOption) func(http.Handler) http.Handler {
+	o := &recoverOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				d.With(
+					"method", r.Method,
+					"path", r.URL.Path,
+					"request_id", RequestID(r.Context()),
+				).Error("panic serving %s %s: %v", r.Method, r.URL.Path, panicError(v, r))
+
+				if !rw.wroteHeader {
+					http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+				if o.repanic {
+					panic(v)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// panicError returns a DetailedError for the panic value, called from the deferred function of Recoverer.
+// Its stack starts at the function that panicked and ends at the handler called by the middleware,
+// leaving out the runtime panic frames and the frames of the HTTP server.
+func panicError(v interface{}, r *http.Request) mklog.DetailedError {
+	return mklog.NewPanicError(v, "github.com/SHEP4RDO/mklog/httplog.Recoverer.", r.Method, r.URL.Path)
+}
@@ -0,0 +1,131 @@
+package httplog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+// newRecoverDebugger returns a Debugger writing entries with their error details to the buffer.
+func newRecoverDebugger(out *strings.Builder) *mklog.Debugger {
+	d := (&mklog.Debugger{}).SetQuiet(true)
+	d.NewLogRule("http", mklog.WithFileWriter(out), mklog.WithDetailedErrorOutput(true))
+	return d
+}
+
+// explode is the handler function panicking in the tests.
+func explode(w http.ResponseWriter, r *http.Request) {
+	panic("kaboom")
+}
+
+// serve runs the request through the handler and returns the value it panicked with, if any.
+func serve(h http.Handler, w http.ResponseWriter, r *http.Request) (panicked interface{}) {
+	defer func() { panicked = recover() }()
+	h.ServeHTTP(w, r)
+	return nil
+}
+
+// TestRecovererLogsPanic answers a panicking handler of a server with a 500 and logs the panic at Error with
+// the stack from the panicking function down to the handler, without the frames of the server.
+func TestRecovererLogsPanic(t *testing.T) {
+	var out strings.Builder
+	d := newRecoverDebugger(&out)
+	srv := httptest.NewServer(Recoverer(d)(http.HandlerFunc(explode)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/orders/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusInternalServerError || strings.TrimSpace(string(body)) != "Internal Server Error" {
+		t.Errorf("response = %d %q, want 500", resp.StatusCode, body)
+	}
+	log := out.String()
+	for _, want := range []string{
+		"| ERROR |",
+		"panic serving GET /orders/7: kaboom",
+		"Function: github.com/SHEP4RDO/mklog/httplog.explode",
+		"File: recover_test.go:",
+		"Arguments: [GET /orders/7]",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log does not contain %q:\n%s", want, log)
+		}
+	}
+	stack := log[strings.Index(log, "Stack Trace:"):]
+	if !strings.Contains(stack, "httplog.explode") {
+		t.Errorf("stack does not contain the handler:\n%s", stack)
+	}
+	for _, unwanted := range []string{"runtime.gopanic", "httplog.Recoverer", "net/http.serverHandler", "net/http.(*conn)"} {
+		if strings.Contains(stack, unwanted) {
+			t.Errorf("stack contains %q:\n%s", unwanted, stack)
+		}
+	}
+}
+
+// TestRecovererKeepsStartedResponse does not overwrite the status of a response the handler already started.
+func TestRecovererKeepsStartedResponse(t *testing.T) {
+	var out strings.Builder
+	d := newRecoverDebugger(&out)
+	defer d.Close(context.Background())
+	h := Recoverer(d)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic(errors.New("lost connection to the queue"))
+	}))
+
+	rec := httptest.NewRecorder()
+	serve(h, rec, httptest.NewRequest(http.MethodPost, "/jobs", nil))
+	if rec.Code != http.StatusAccepted || rec.Body.String() != "partial" {
+		t.Errorf("response = %d %q, want the started 202", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(out.String(), "panic serving POST /jobs: lost connection to the queue") {
+		t.Errorf("log = %q", out.String())
+	}
+}
+
+// TestRecovererPassesAbortHandler passes http.ErrAbortHandler on without logging or answering it.
+func TestRecovererPassesAbortHandler(t *testing.T) {
+	var out strings.Builder
+	d := newRecoverDebugger(&out)
+	defer d.Close(context.Background())
+	h := Recoverer(d)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	rec := httptest.NewRecorder()
+	if v := serve(h, rec, httptest.NewRequest(http.MethodGet, "/stream", nil)); v != http.ErrAbortHandler {
+		t.Fatalf("panic = %v, want http.ErrAbortHandler", v)
+	}
+	if out.Len() != 0 || rec.Body.Len() != 0 {
+		t.Errorf("aborted request logged %q and answered %q", out.String(), rec.Body.String())
+	}
+}
+
+// TestRecovererRepanic panics again with the original value after logging and answering the panic.
+func TestRecovererRepanic(t *testing.T) {
+	var out strings.Builder
+	d := newRecoverDebugger(&out)
+	defer d.Close(context.Background())
+	h := Recoverer(d, WithRepanic(true))(http.HandlerFunc(explode))
+
+	rec := httptest.NewRecorder()
+	if v := serve(h, rec, httptest.NewRequest(http.MethodGet, "/", nil)); v != "kaboom" {
+		t.Fatalf("panic = %v, want the original value", v)
+	}
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(out.String(), "kaboom") {
+		t.Errorf("response %d, log %q: want a logged 500 before the panic", rec.Code, out.String())
+	}
+}
@@ -0,0 +1,202 @@
+package mklog
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// Default settings of the write latency tracking
+	MKLOG_WriteLatencyWeightDefault       = 0.2         // Weight of the latest write in the moving average of the write latency
+	MKLOG_SlowWriteWarningIntervalDefault = time.Minute // Minimum interval between two slow write warnings of a rule
+)
+
+// WriteStats describes the write latency of one output of a rule.
+type WriteStats struct {
+	Module string        `json:"module"` // Module of the rule
+	Index  int           `json:"index"`  // Index of the rule within the module
+	Sink   string        `json:"sink"`   // Output of the rule, "file" or "sinks[i]"
+	Type   string        `json:"type"`   // Type of the writer or sink
+	Writes uint64        `json:"writes"` // Number of timed writes
+	Slow   uint64        `json:"slow"`   // Number of writes exceeding the slow write threshold
+	EWMA   time.Duration `json:"ewma"`   // Exponentially weighted moving average of the write latency
+	Max    time.Duration `json:"max"`    // Highest write latency since the last reset
+}
+
+// WithSlowWriteThreshold passes a warning to the error handler when a single write to the log file
+// or a sink of the rule takes longer than threshold, at most once per MKLOG_SlowWriteWarningIntervalDefault.
+func WithSlowWriteThreshold(threshold time.Duration) Option {
+	return func(lr *LogRule) {
+		lr.SlowWriteThreshold = threshold
+	}
+}
+
+// writeLatency is the latency record of one output.
+type writeLatency struct {
+	writes uint64
+	slow   uint64
+	ewma   float64
+	max    time.Duration
+}
+
+// writeMetrics tracks the traffic and the write latency of the outputs of a rule.
+type writeMetrics struct {
+	// Updated atomically, kept first for 64-bit alignment
+	levels    [FatalLevel + 1]uint64 // Entries accepted per built-in level
+	bytes     uint64                 // Bytes written to the log file
+	errors    uint64                 // Failed writes to the log file and the sinks
+	lastWrite int64                  // Unix nanoseconds of the last successful write
+	panics    uint64                 // Panics recovered from the formatter, hooks and sinks
+
+	mu          sync.Mutex
+	custom      map[LogLevel]uint64 // Entries accepted per custom level
+	file        writeLatency
+	sinks       []writeLatency
+	lastWarning time.Time // Time of the last slow write warning
+}
+
+// newWriteMetrics creates the latency tracking of a rule.
+func newWriteMetrics() *writeMetrics {
+	return &writeMetrics{}
+}
+
+// fileSink is the index recording writes to the log file.
+const fileSink = -1
+
+// observeWrite records the latency of a write to the output, the log file for fileSink or the sink with the index,
+// and warns about a write slower than the threshold of the rule.
+func (lr *LogRule) observeWrite(sink int, elapsed time.Duration) {
+	m := lr.metrics
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	var l *writeLatency
+	if sink == fileSink {
+		l = &m.file
+	} else {
+		for len(m.sinks) <= sink {
+			m.sinks = append(m.sinks, writeLatency{})
+		}
+		l = &m.sinks[sink]
+	}
+
+	if l.writes == 0 {
+		l.ewma = float64(elapsed)
+	} else {
+		l.ewma += MKLOG_WriteLatencyWeightDefault * (float64(elapsed) - l.ewma)
+	}
+	l.writes++
+	if elapsed > l.max {
+		l.max = elapsed
+	}
+
+	warn := false
+	if lr.SlowWriteThreshold > 0 && elapsed > lr.SlowWriteThreshold {
+		l.slow++
+		now := lr.now()
+		if m.lastWarning.IsZero() || now.Sub(m.lastWarning) >= MKLOG_SlowWriteWarningIntervalDefault {
+			m.lastWarning = now
+			warn = true
+		}
+	}
+	m.mu.Unlock()
+
+	if warn {
+		lr.deferError(fmt.Errorf("[mklog] slow write to %s of %s: took %s, threshold %s",
+			sinkName(sink), lr.ModuleName, elapsed, lr.SlowWriteThreshold))
+	}
+}
+
+// now returns the current time of the Debugger owning the rule.
+func (lr *LogRule) now() time.Time {
+	if lr.debugger != nil {
+		return lr.debugger.now()
+	}
+	return time.Now()
+}
+
+// sinkName returns the name of the output in the write statistics.
+func sinkName(sink int) string {
+	if sink == fileSink {
+		return "file"
+	}
+	return fmt.Sprintf("sinks[%d]", sink)
+}
+
+// writeStats returns the write latency of the outputs of the rule.
+func (lr *LogRule) writeStats(module string, index int) []WriteStats {
+	m := lr.metrics
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stats []WriteStats
+	add := func(sink int, typ string, l writeLatency) {
+		stats = append(stats, WriteStats{
+			Module: module,
+			Index:  index,
+			Sink:   sinkName(sink),
+			Type:   typ,
+			Writes: l.writes,
+			Slow:   l.slow,
+			EWMA:   time.Duration(l.ewma),
+			Max:    l.max,
+		})
+	}
+	if m.file.writes > 0 {
+		add(fileSink, fmt.Sprintf("%T", lr.FileLog.target), m.file)
+	}
+	for i, l := range m.sinks {
+		if l.writes == 0 {
+			continue
+		}
+		typ := ""
+		if i < len(lr.Sinks) {
+			typ = fmt.Sprintf("%T", lr.Sinks[i])
+		}
+		add(i, typ, l)
+	}
+	return stats
+}
+
+// writeStats returns the write latency of the outputs of all rules.
+func (d *Debugger) writeStats() []WriteStats {
+	var stats []WriteStats
+	for module, rules := range d.rules() {
+		for i, v := range rules {
+			stats = append(stats, v.writeStats(module, i)...)
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Module != stats[j].Module {
+			return stats[i].Module < stats[j].Module
+		}
+		return stats[i].Index < stats[j].Index
+	})
+	return stats
+}
+
+// ResetWriteStats resets the maximum write latency of all outputs, starting a new observation window.
+func (d *Debugger) ResetWriteStats() {
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			m := v.metrics
+			if m == nil {
+				continue
+			}
+			m.mu.Lock()
+			m.file.max = 0
+			for i := range m.sinks {
+				m.sinks[i].max = 0
+			}
+			m.mu.Unlock()
+		}
+	}
+}
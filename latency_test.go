@@ -0,0 +1,62 @@
+package mklog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowSink takes delay for every write.
+type slowSink struct {
+	delay time.Duration
+}
+
+func (s *slowSink) Write(entry Entry, formatted string) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func (s *slowSink) Close() error { return nil }
+
+// TestSlowWriteThreshold warns about the writes of a sink slower than the threshold, at most once per warning
+// interval on the Debugger clock, and counts them in the write statistics of that sink only.
+func TestSlowWriteThreshold(t *testing.T) {
+	start := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.SetErrorHandler(rec.handle)
+	d.SetErrorCoalescing(0)
+	d.NewLogRule("billing", WithFileWriter(&syncBuffer{}), WithSink(&entrySink{}),
+		WithSink(&slowSink{delay: 60 * time.Millisecond}), WithSlowWriteThreshold(20*time.Millisecond))
+	defer d.Close(context.Background())
+
+	d.Info("first")
+	d.Info("second") // Within the warning interval.
+	clock.Set(start.Add(MKLOG_SlowWriteWarningIntervalDefault))
+	d.Info("third")
+
+	errs := rec.get()
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want two slow write warnings", errs)
+	}
+	for _, err := range errs {
+		if !strings.HasPrefix(err.Error(), "[mklog] slow write to sinks[1] of billing: took ") ||
+			!strings.HasSuffix(err.Error(), ", threshold 20ms") {
+			t.Errorf("warning = %q, want the slow sink named with the threshold", err)
+		}
+	}
+
+	stats := d.writeStats()
+	slow := map[string]uint64{}
+	for _, s := range stats {
+		if s.Writes != 3 {
+			t.Errorf("%s: %d writes, want 3", s.Sink, s.Writes)
+		}
+		slow[s.Sink] = s.Slow
+	}
+	if slow["sinks[1]"] != 3 || slow["sinks[0]"] != 0 || slow["file"] != 0 {
+		t.Errorf("slow writes = %v, want the three writes of sinks[1]", slow)
+	}
+}
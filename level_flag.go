@@ -0,0 +1,60 @@
+package mklog
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// String returns the lower-case name of the level, so *LogLevel satisfies flag.Value.
+func (l *LogLevel) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.ToLower(l.GetLogLevelName())
+}
+
+// Set parses the level with StringToLogLevel, accepting the built-in and registered custom names.
+func (l *LogLevel) Set(value string) error {
+	level, err := StringToLogLevel(value)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q, valid levels: %s", value, strings.Join(levelNames(), ", "))
+	}
+	*l = level
+	return nil
+}
+
+// Type returns the type name shown in the usage of pflag.
+func (l *LogLevel) Type() string {
+	return "level"
+}
+
+// LevelFlag defines a log level flag with the default value on the flag set, flag.CommandLine when fs is nil,
+// and returns the address of the parsed level.
+//
+//	level := mklog.LevelFlag(nil, "log-level", mklog.InfoLevel, "minimum log level")
+//	flag.Parse()
+func LevelFlag(fs *flag.FlagSet, name string, def LogLevel, usage string) *LogLevel {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	level := def
+	fs.Var(&level, name, usage)
+	return &level
+}
+
+// levelNames returns the names of the built-in levels followed by the registered custom levels.
+func levelNames() []string {
+	names := []string{"trace", "debug", "info", "warning", "error", "fatal"}
+
+	customLevelsMu.RLock()
+	custom := make([]string, 0, len(customLevels))
+	for _, c := range customLevels {
+		custom = append(custom, strings.ToLower(c.name))
+	}
+	customLevelsMu.RUnlock()
+
+	sort.Strings(custom)
+	return append(names, custom...)
+}
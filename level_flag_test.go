@@ -0,0 +1,84 @@
+package mklog
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestLevelFlagParse parses the level names, abbreviations and the default of a flag set.
+func TestLevelFlagParse(t *testing.T) {
+	tests := []struct {
+		args []string
+		want LogLevel
+	}{
+		{nil, WarningLevel},
+		{[]string{"--log-level=debug"}, DebugLevel},
+		{[]string{"-log-level", "ERROR"}, ErrorLevel},
+		{[]string{"--log-level=trace"}, TraceLevel},
+		{[]string{"--log-level=w"}, WarningLevel},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("app", flag.ContinueOnError)
+		level := LevelFlag(fs, "log-level", WarningLevel, "minimum log level")
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if *level != tt.want {
+			t.Errorf("%v: level = %v, want %v", tt.args, *level, tt.want)
+		}
+	}
+}
+
+// TestLevelFlagInvalid rejects unknown names with the flag error listing the valid names, keeps the default
+// and prints it in the usage.
+func TestLevelFlagInvalid(t *testing.T) {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	var usage strings.Builder
+	fs.SetOutput(&usage)
+	level := LevelFlag(fs, "log-level", InfoLevel, "minimum log level")
+
+	err := fs.Parse([]string{"--log-level=verbose"})
+	if err == nil {
+		t.Fatal("parsed an unknown level")
+	}
+	want := `invalid value "verbose" for flag -log-level: invalid log level "verbose", valid levels: trace, debug, info, warning, error, fatal`
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+	if *level != InfoLevel {
+		t.Errorf("level = %v after the invalid value, want the default", *level)
+	}
+	if !strings.Contains(usage.String(), `(default info)`) {
+		t.Errorf("usage = %q", usage.String())
+	}
+}
+
+// TestLevelFlagCustomLevel parses a registered custom level and lists it among the valid names.
+func TestLevelFlagCustomLevel(t *testing.T) {
+	const audit LogLevel = 30
+	if err := RegisterLevel(audit, "AUDIT", SyslogNotice); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		customLevelsMu.Lock()
+		delete(customLevels, audit)
+		customLevelsMu.Unlock()
+	}()
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	level := LevelFlag(fs, "log-level", InfoLevel, "minimum log level")
+	if err := fs.Parse([]string{"--log-level=audit"}); err != nil {
+		t.Fatal(err)
+	}
+	if *level != audit || level.String() != "audit" {
+		t.Errorf("level = %v (%s), want the custom level", int(*level), level)
+	}
+
+	err := fs.Parse([]string{"--log-level=nope"})
+	if err == nil || !strings.HasSuffix(err.Error(), "error, fatal, audit") {
+		t.Errorf("error = %v, want the custom level among the valid names", err)
+	}
+}
@@ -0,0 +1,150 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ruleLifecycle coordinates the shutdown of a rule with logging in progress,
+// so that no message is sent to a closed channel, file or sink.
+type ruleLifecycle struct {
+	mu        sync.RWMutex  // Held for reading while a message is dispatched to the rule
+	disabled  bool          // Whether the rule ignores new messages
+	closeOnce sync.Once     // Guards the shutdown of the rule
+	asyncOnce sync.Once     // Guards closing of the async channel
+	doneOnce  sync.Once     // Guards closing of asyncDone
+	startOnce sync.Once     // Guards the start of the async consumer
+	asyncMu   sync.Mutex    // Orders changes of the async channel with the start of the consumer
+	fileMu    sync.Mutex    // Orders opening and closing the log file with writes to it
+	outputMu  sync.Mutex    // Serializes the writes of the entries to the outputs and sinks, see writeEntry
+	seq       uint64        // Entries written to the outputs, guarded by outputMu
+	started   bool          // Whether the async consumer has been started
+	released  chan struct{} // Closed on shutdown to release the context watcher and the message forwarding
+	plainOnce sync.Once     // Guards creation of the plain message channel
+	plain     chan string   // Channel forwarding plain messages to the async queue, see GetLogChannel
+	errMu     sync.Mutex    // Guards deferred
+	deferred  []error       // Internal errors raised while outputMu or fileMu was held, see deferError
+}
+
+// newRuleLifecycle creates the lifecycle of an active rule.
+func newRuleLifecycle() *ruleLifecycle {
+	return &ruleLifecycle{released: make(chan struct{})}
+}
+
+// acquire reports whether the rule accepts messages and keeps it from shutting down until release.
+// Rules without a lifecycle always accept messages.
+func (l *ruleLifecycle) acquire() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.RLock()
+	if l.disabled {
+		l.mu.RUnlock()
+		return false
+	}
+	return true
+}
+
+// release ends the dispatch started by a successful acquire.
+func (l *ruleLifecycle) release() {
+	if l != nil {
+		l.mu.RUnlock()
+	}
+}
+
+// disable stops the rule from accepting messages, waiting for the dispatches in progress.
+func (l *ruleLifecycle) disable() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.disabled = true
+	l.mu.Unlock()
+}
+
+// BindContext shuts the Debugger down with Close when the context is cancelled.
+// The goroutine watching the context ends when Close is called first.
+func (d *Debugger) BindContext(ctx context.Context) *Debugger {
+	closed := d.closedChannel()
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeCtx, cancel := context.WithTimeout(context.Background(), MKLOG_CloseTimeoutDefault)
+			defer cancel()
+			if _, err := d.Close(closeCtx); err != nil {
+				d.handleError(err)
+			}
+		case <-closed:
+		}
+	}()
+	return d
+}
+
+// closedChannel returns the channel closed by Close, creating it on first use.
+func (d *Debugger) closedChannel() chan struct{} {
+	d.closedOnce.Do(func() {
+		d.closed = make(chan struct{})
+	})
+	return d.closed
+}
+
+// watchContext shuts the rule down when the context is cancelled, or returns when the rule is shut down first.
+func (lr *LogRule) watchContext(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		closeCtx, cancel := context.WithTimeout(context.Background(), MKLOG_CloseTimeoutDefault)
+		defer cancel()
+		if _, _, err := lr.shutdown(closeCtx); err != nil {
+			lr.reportError(err)
+		}
+	case <-lr.lifecycle.released:
+	}
+}
+
+// closeAsync disables the rule and closes its async channel once, so the consumer drains the buffer and exits.
+func (lr *LogRule) closeAsync() {
+	if lr.lifecycle == nil {
+		close(lr.logChannel)
+		return
+	}
+	lr.lifecycle.disable()
+	lr.lifecycle.asyncOnce.Do(func() {
+		close(lr.logChannel)
+	})
+}
+
+// StopAsyncLogging stops the rule from accepting messages and terminates the async consumer after it has
+// written the queued messages, waiting until it has finished or the context is done. The log file stays
+// open until Close.
+func (lr *LogRule) StopAsyncLogging(ctx context.Context) error {
+	if !lr.AsyncLog.Enable || lr.logChannel == nil {
+		return nil
+	}
+	if !runWithContext(ctx, lr.closeAsync) {
+		return fmt.Errorf("[mklog] async consumer of %s not stopped: %w", lr.ModuleName, ctx.Err())
+	}
+	if lr.asyncDone == nil {
+		return nil
+	}
+	select {
+	case <-lr.asyncDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("[mklog] async consumer of %s not stopped, %d messages queued: %w", lr.ModuleName, len(lr.logChannel), ctx.Err())
+	}
+}
+
+// asyncFinished signals that the async consumer has written all buffered messages.
+func (lr *LogRule) asyncFinished() {
+	if lr.asyncDone == nil {
+		return
+	}
+	if lr.lifecycle == nil {
+		close(lr.asyncDone)
+		return
+	}
+	lr.lifecycle.doneOnce.Do(func() {
+		close(lr.asyncDone)
+	})
+}
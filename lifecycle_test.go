@@ -0,0 +1,252 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// orderSink records the messages it receives and checks that their Seq counts up from 1.
+type orderSink struct {
+	mu       sync.Mutex
+	messages []string
+	badSeq   []uint64
+}
+
+func (s *orderSink) Write(entry Entry, formatted string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, entry.Message)
+	if entry.Seq != uint64(len(s.messages)) {
+		s.badSeq = append(s.badSeq, entry.Seq)
+	}
+	return nil
+}
+
+func (s *orderSink) Close() error { return nil }
+
+// TestStopAsyncLoggingWhileLogging stops the async consumer from two goroutines while others keep logging,
+// run with -race. No entry may be sent to the closed queue, and the log file must hold every entry the sink
+// of the rule received, in the same order.
+func TestStopAsyncLoggingWhileLogging(t *testing.T) {
+	const rounds, loggers, messages = 20, 4, 200
+
+	for round := 0; round < rounds; round++ {
+		file := &syncBuffer{}
+		sink := &orderSink{}
+		d := (&Debugger{}).SetQuiet(true)
+		d.NewLogRule("app",
+			WithFileWriter(file),
+			WithLogFormatter(&JSONFormatter{}),
+			WithAsyncLog(true, 8),
+			WithSink(sink),
+		)
+		rule := d.rules()["app"][0]
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for g := 0; g < loggers; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				<-start
+				for i := 0; i < messages; i++ {
+					d.Info("%d-%d", g, i)
+				}
+			}(g)
+		}
+		for s := 0; s < 2; s++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if err := rule.StopAsyncLogging(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		sink.mu.Lock()
+		received, badSeq := sink.messages, sink.badSeq
+		sink.mu.Unlock()
+		if len(badSeq) > 0 {
+			t.Fatalf("round %d: sink received Seq %v out of order", round, badSeq)
+		}
+		var written []string
+		for _, line := range strings.Split(strings.TrimSpace(file.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			i := strings.Index(line, `"logMessage":"`)
+			if i < 0 {
+				t.Fatalf("round %d: torn line %q", round, line)
+			}
+			rest := line[i+len(`"logMessage":"`):]
+			written = append(written, rest[:strings.IndexByte(rest, '"')])
+		}
+		if fmt.Sprint(written) != fmt.Sprint(received) {
+			t.Fatalf("round %d: file has %d entries, sink %d, or their order differs", round, len(written), len(received))
+		}
+		if len(received) > loggers*messages {
+			t.Fatalf("round %d: %d entries for %d logged", round, len(received), loggers*messages)
+		}
+	}
+}
+
+// TestErrorHandlerLogsWhileWriting lets the error handler log to the rule that raised the error while writing:
+// the dropped message warning of the full async queue is delivered after the rule released its output lock.
+func TestErrorHandlerLogsWhileWriting(t *testing.T) {
+	w := &blockingWriter{started: make(chan struct{}, 8), release: make(chan struct{})}
+	sink := &orderSink{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(func(err error) { d.Warning("handled: %v", err) })
+	d.NewLogRule("app", WithFileWriter(w), WithSink(sink), WithAsyncLog(true, 1),
+		WithAsyncOverflowPolicy(OverflowDropNewest, 0))
+
+	d.Info("first")
+	<-w.started // The consumer holds the first entry, so the second fills the queue.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Info("second")
+		d.Info("third")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging from the error handler deadlocked")
+	}
+	close(w.release)
+	d.Close(context.Background())
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	got := strings.Join(sink.messages, "|")
+	if !strings.HasPrefix(got, "first|second|third|handled: [mklog] async queue of app is full, 1 messages dropped") {
+		t.Errorf("sink messages = %q, want the warning logged by the handler after the entries", got)
+	}
+}
+
+// slowFailingWriter blocks every write until it is released, signalling each write that started, and fails it.
+type slowFailingWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *slowFailingWriter) Write(p []byte) (int, error) {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return 0, fmt.Errorf("disk full")
+}
+
+// TestErrorHandlerLogsDuringClose lets the error handler log to the rule whose write failed while Close waits
+// for that write: the rule is released before the handler runs, so neither the log call nor Close hang.
+func TestErrorHandlerLogsDuringClose(t *testing.T) {
+	w := &slowFailingWriter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	d := (&Debugger{}).SetQuiet(true)
+	var once sync.Once
+	d.SetErrorHandler(func(err error) {
+		once.Do(func() { d.Info("handled: %v", err) })
+	})
+	d.NewLogRule("app", WithFileWriter(w))
+
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		d.Info("first")
+	}()
+	<-w.started
+
+	closed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := d.Close(ctx)
+		closed <- err
+	}()
+	time.Sleep(20 * time.Millisecond) // Let Close wait for the write in progress.
+	close(w.release)
+
+	select {
+	case <-logged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("log call with a logging error handler did not return during Close")
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close() = %v, want nil", err)
+	}
+}
+
+// waitCalls waits until the writer recorded the call sequence and returns its calls.
+func waitCalls(w *syncCloseWriter, want string) string {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w.mu.Lock()
+		calls := strings.Join(w.calls, ",")
+		w.mu.Unlock()
+		if calls == want || time.Now().After(deadline) {
+			return calls
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestBindContext closes the Debugger when the bound context is cancelled: the async buffer is written, the
+// file writer synced and closed, and logging afterwards is a no-op.
+func TestBindContext(t *testing.T) {
+	w := &syncCloseWriter{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(w), WithCloseFileWriter(true), WithAsyncLog(true, 16))
+	ctx, cancel := context.WithCancel(context.Background())
+	d.BindContext(ctx)
+
+	d.Info("before")
+	cancel()
+	if got := waitCalls(w, "write,sync,close"); got != "write,sync,close" {
+		t.Fatalf("writer calls = %s, want the entry written before the writer is closed", got)
+	}
+	d.Info("after")
+	time.Sleep(20 * time.Millisecond)
+	if got := waitCalls(w, "write,sync,close"); got != "write,sync,close" {
+		t.Errorf("writer calls = %s, want no write after the context was cancelled", got)
+	}
+	select {
+	case <-d.closedChannel():
+	default:
+		t.Error("the Debugger was not closed")
+	}
+}
+
+// TestWithContext shuts down only the rule bound to the cancelled context, leaving the other rules logging.
+func TestWithContext(t *testing.T) {
+	bound, other := &syncCloseWriter{}, &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("job", WithFileWriter(bound), WithCloseFileWriter(true), WithContext(ctx))
+	d.NewLogRule("app", WithFileWriter(other))
+	defer d.Close(context.Background())
+
+	d.Info("before")
+	cancel()
+	if got := waitCalls(bound, "write,sync,close"); got != "write,sync,close" {
+		t.Fatalf("writer calls = %s, want the writer of the bound rule closed", got)
+	}
+	d.Info("after")
+	if got := waitCalls(bound, "write,sync,close"); got != "write,sync,close" {
+		t.Errorf("writer calls = %s, want no write to the shut down rule", got)
+	}
+	if !strings.Contains(other.String(), "after") {
+		t.Errorf("other rule = %q, want it to keep logging", other.String())
+	}
+}
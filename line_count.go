@@ -0,0 +1,93 @@
+package mklog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lineCountBlockSize is the size of the blocks read when counting the lines of a log file.
+const lineCountBlockSize = 256 * 1024
+
+// lineCountFile returns the name of the file keeping the line count of the writer's last closed file, hidden
+// next to the log files, so that reopening a line-limited file only counts the lines appended since.
+func (w *RotatingWriter) lineCountFile() string {
+	return filepath.Join(w.options.FilePath, "."+w.options.FileName+w.options.FileType+".lines")
+}
+
+// saveLineCount records the size and the line count of the closed file. Nothing is recorded without a line limit.
+func (w *RotatingWriter) saveLineCount(fileName string, lines int64) {
+	if w.options.MaxFileLines <= 0 {
+		return
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return
+	}
+	os.WriteFile(w.lineCountFile(), []byte(fmt.Sprintf("%s\n%d\n%d\n", fileName, info.Size(), lines)), 0644)
+}
+
+// dropLineCount removes the recorded line count, whose file is about to be truncated, trimmed or rotated.
+func (w *RotatingWriter) dropLineCount() {
+	if w.options.MaxFileLines > 0 {
+		os.Remove(w.lineCountFile())
+	}
+}
+
+// loadLineCount returns the lines of the file of the given size. A count recorded for the file when it was
+// smaller is extended by the lines appended since; otherwise the whole file is counted.
+func (w *RotatingWriter) loadLineCount(fileName string, size int64) int64 {
+	var recordedSize, lines int64 = -1, -1
+	if data, err := os.ReadFile(w.lineCountFile()); err == nil {
+		if fields := strings.Split(string(data), "\n"); len(fields) == 4 && fields[0] == fileName {
+			recordedSize, _ = strconv.ParseInt(fields[1], 10, 64)
+			lines, _ = strconv.ParseInt(fields[2], 10, 64)
+		}
+	}
+	if recordedSize <= 0 || recordedSize > size || lines < 0 {
+		return countFileLines(fileName, w.options.Compress, 0)
+	}
+	if recordedSize == size {
+		return lines
+	}
+	return lines + countFileLines(fileName, w.options.Compress, recordedSize)
+}
+
+// countFileLines counts the lines of the file from the offset on, decompressing it when it is compressed; the
+// offset of a compressed file must start a gzip member. Files that cannot be read count as empty, and a
+// compressed file cut off by a crash counts the lines up to the cut.
+func countFileLines(fileName string, compressed bool, offset int64) int64 {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0
+	}
+
+	var r io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return 0
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var lines int64
+	buf := make([]byte, lineCountBlockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if err != nil {
+			return lines
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package mklog
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -13,6 +15,7 @@ type FileLog struct {
 	Enable            bool `json:"enable" yaml:"enable"`                             // Flag indicating whether to log to a file.
 	IsDateFile        bool `json:"is_date_file" yaml:"is_date_file"`                 // Flag indicating whether to include the date in the log file name.
 	IsLimitedFileSize bool `json:"is_limited_file_size" yaml:"is_limited_file_size"` // Flag indicating whether to limit the file size.
+	Sanitize          bool `json:"sanitize" yaml:"sanitize"`                         // Flag indicating whether to escape control characters in the file output.
 
 	// files
 	File            *os.File `json:"-" yaml:"-"`                                 // Pointer to the log file (ignored in configuration).
@@ -22,6 +25,57 @@ type FileLog struct {
 	CurrentFileName string   `json:"current_file_name" yaml:"current_file_name"` // Current full name of the log file.
 	FileType        string   `json:"file_type" yaml:"file_type"`                 // Type of the log file (e.g., ".log").
 	DateFileFormat  string   `json:"date_file_format" yaml:"date_file_format"`   // Date format used in the log file name.
+
+	// rotation
+	MaxBackups   int           `json:"max_backups" yaml:"max_backups"`       // Number of rotated files kept when the size limit is reached; zero trims the file instead.
+	MaxAge       time.Duration `json:"max_age" yaml:"max_age"`               // Retention of rotated and dated log files; zero keeps them forever.
+	CopyTruncate bool          `json:"copy_truncate" yaml:"copy_truncate"`   // Flag indicating whether to rotate by copying and truncating the log file instead of renaming it.
+	MaxFileLines int64         `json:"max_file_lines" yaml:"max_file_lines"` // Maximum number of lines of the log file before it is rotated; zero disables the limit.
+
+	// dated files
+	MaxDateFiles int `json:"max_date_files" yaml:"max_date_files"` // Number of dated log files kept besides the active one; zero keeps them all.
+
+	// compression
+	StreamingCompression bool `json:"streaming_compression" yaml:"streaming_compression"` // Flag indicating whether to write gzip-compressed log files.
+
+	// interval rotation
+	RotationInterval time.Duration `json:"rotation_interval" yaml:"rotation_interval"` // Period after which a new, timestamped log file is started; zero disables it.
+
+	// archiving
+	ArchiveAfter   time.Duration `json:"archive_after" yaml:"archive_after"`       // Age after which rotated and dated log files are moved into zip archives; zero disables it.
+	ArchiveGroupBy string        `json:"archive_group_by" yaml:"archive_group_by"` // Grouping of the archives, "month" or "week".
+
+	// encoding
+	WriteBOM   bool   `json:"write_bom" yaml:"write_bom"`     // Flag indicating whether to start every log file with a UTF-8 byte order mark.
+	LineEnding string `json:"line_ending" yaml:"line_ending"` // Line ending of the entries, "\n" (default) or "\r\n".
+
+	// opening
+	OpenMode       string `json:"open_mode" yaml:"open_mode"`               // OpenAppend (default) or OpenTruncate, emptying the log file when the rule opens it.
+	FileNamePolicy string `json:"file_name_policy" yaml:"file_name_policy"` // FileNameReject (default) or FileNameSanitize, handling characters invalid in file names.
+
+	// failed entry queue
+	FailedQueueSize     int           `json:"failed_queue_size" yaml:"failed_queue_size"`         // Number of failed writes kept for replay; zero reports failed writes instead.
+	FailedRetryInterval time.Duration `json:"failed_retry_interval" yaml:"failed_retry_interval"` // Period after which the failed writes are replayed; zero replays them on the next write only.
+
+	// writer
+	Writer      io.Writer `json:"-" yaml:"-"`                       // Writer used instead of log files, bypassing file creation and rotation.
+	CloseWriter bool      `json:"close_writer" yaml:"close_writer"` // Flag allowing CloseLogFile to close Writer when it implements io.Closer.
+
+	writer *RotatingWriter  // Writer managing the log files
+	target io.Writer        // Destination of the written messages
+	now    func() time.Time // Clock used for file management
+	opened fileLocation     // Location the log files were opened at
+	closed bool             // Whether CloseLogFile closed the file, which keeps it from being opened lazily
+}
+
+// fileLocation is the directory, name and type of the log files.
+type fileLocation struct {
+	path, name, fileType string
+}
+
+// location returns the current location of the log files.
+func (f *FileLog) location() fileLocation {
+	return fileLocation{path: f.FilePath, name: f.FileName, fileType: f.FileType}
 }
 
 type FileFolder struct {
@@ -33,154 +87,248 @@ type FileFolder struct {
 // createLogFile initializes and opens the log file if logging to a file is enabled.
 func (d *LogRule) createLogFile() error {
 	if d.FileLog.Enable {
-		// Create the log directory if it does not exist.
-		if err := os.MkdirAll(d.FileLog.FilePath, os.ModePerm); err != nil {
-			return fmt.Errorf("failed to create log directory: %w", err)
+		if d.FileLog.writer != nil {
+			d.FileLog.writer.Close()
+			d.FileLog.writer = nil
+		}
+		d.FileLog.target = nil
+		if d.logFinishChannel == nil || d.FileLog.closed {
+			d.logFinishChannel = make(chan struct{}) // Rules added with AddRule and reopened files get a new channel.
 		}
 
-		var logFolder string
-		// Determine whether to create a time-based folder for log files.
-		if d.FileFolder.Enable {
-			currentTime := time.Now()
-			var folderName string
-
-			// Format folder name based on the specified time period.
-			if d.FileFolder.FileFolderPeriod < time.Hour {
-				folderName = currentTime.Format(d.FileFolder.TimeFolderFormat)
-			} else {
-				folderName = currentTime.Truncate(d.FileFolder.FileFolderPeriod).Format(d.FileFolder.TimeFolderFormat)
-			}
-
-			// Create the time-based folder for log files.
-			logFolder = filepath.Join(d.FileLog.FilePath, folderName)
-			if err := os.MkdirAll(logFolder, os.ModePerm); err != nil {
-				return fmt.Errorf("failed to create time folder: %w", err)
-			}
-		} else {
-			logFolder = d.FileLog.FilePath // Use the main log directory.
+		if !validLineEnding(d.FileLog.LineEnding) {
+			return fmt.Errorf("unsupported line ending %q, use \"\\n\" or \"\\r\\n\"", d.FileLog.LineEnding)
 		}
 
-		var fileName string
-		// Determine the log file name based on the date settings.
-		if d.FileLog.IsDateFile {
-			dateStr := time.Now().Format(d.FileLog.DateFileFormat)
-			fileName = filepath.Join(logFolder, fmt.Sprintf("%s_%s%s", dateStr, d.FileLog.FileName, d.FileLog.FileType))
-		} else {
-			fileName = filepath.Join(logFolder, fmt.Sprintf("%s%s", d.FileLog.FileName, d.FileLog.FileType))
+		// Use the injected writer instead of log files.
+		if d.FileLog.Writer != nil {
+			if d.hasRotationOptions() {
+				return fmt.Errorf("file writer cannot be combined with date files, time folders, size limits, retention, compression, interval rotation or archiving")
+			}
+			d.FileLog.target = d.FileLog.Writer
+			d.FileLog.closed = false
+			return nil
 		}
 
-		// Open the log file for writing.
-		file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		writer, err := NewRotatingWriter(d.rotatingWriterOptions())
 		if err != nil {
-			return fmt.Errorf("failed to open log file: %w", err)
+			return err
 		}
-		d.FileLog.File = file
-		d.FileLog.CurrentFileName = fileName
+		d.FileLog.writer = writer
+		d.FileLog.target = writer
+		d.FileLog.File = writer.File()
+		d.FileLog.CurrentFileName = writer.FileName()
+		d.FileLog.opened = d.FileLog.location()
+		d.FileLog.closed = false
 	}
 	return nil
 }
 
-// CloseLogFile closes the log file and signals the log finishing channel.
-func (d *LogRule) CloseLogFile() {
-	if d.FileLog.File != nil {
-		close(d.logFinishChannel) // Signal that logging has finished.
-		d.FileLog.File.Close()    // Close the log file.
-		d.FileLog.File = nil      // Clear the file pointer.
+// openLogFile opens the log file on the first write of a rule whose file logging was enabled after it was
+// built, and reopens it when the path, name or type of the log files changed since it was opened. A file
+// closed by CloseLogFile stays closed until CreateLogFile is called.
+func (d *LogRule) openLogFile() error {
+	if d.FileLog.closed {
+		return nil
 	}
+	if d.FileLog.target != nil && (d.FileLog.Writer != nil || d.FileLog.opened == d.FileLog.location()) {
+		return nil
+	}
+	return d.createLogFile()
 }
 
-// writeLog writes the provided log message to the log file if logging to a file is enabled.
-func (d *LogRule) writeLog(msg string) error {
-	if d.FileLog.File != nil {
-		now := time.Now()
-		var fileName string
+// hasRotationOptions checks whether any of the file management options is enabled.
+func (d *LogRule) hasRotationOptions() bool {
+	return d.FileLog.IsDateFile || d.FileFolder.Enable || d.FileLog.IsLimitedFileSize ||
+		d.FileLog.MaxBackups > 0 || d.FileLog.MaxAge > 0 || d.FileLog.StreamingCompression || d.FileLog.RotationInterval > 0 ||
+		d.FileLog.ArchiveAfter > 0 || d.FileLog.MaxFileLines > 0
+}
 
-		// Determine the appropriate log file name.
-		if d.FileLog.IsDateFile {
-			fileDate := now.Format("02.01.2006")
-			fileName = fmt.Sprintf("%s_%s%s", fileDate, d.FileLog.FileName, d.FileLog.FileType)
-		} else {
-			fileName = fmt.Sprintf("%s%s", d.FileLog.FileName, d.FileLog.FileType)
-		}
+// rotatingWriterOptions builds the options of the file writer from the rule settings.
+func (d *LogRule) rotatingWriterOptions() RotatingWriterOptions {
+	options := RotatingWriterOptions{
+		FilePath:         d.FileLog.FilePath,
+		FileName:         d.FileLog.FileName,
+		FileType:         d.FileLog.FileType,
+		IsDateFile:       d.FileLog.IsDateFile,
+		DateFileFormat:   d.FileLog.DateFileFormat,
+		TimeFolder:       d.FileFolder.Enable,
+		TimeFolderFormat: d.FileFolder.TimeFolderFormat,
+		FileFolderPeriod: d.FileFolder.FileFolderPeriod,
+		MaxBackups:       d.FileLog.MaxBackups,
+		MaxFileLines:     d.FileLog.MaxFileLines,
+		CopyTruncate:     d.FileLog.CopyTruncate,
+		MaxAge:           d.FileLog.MaxAge,
+		MaxDateFiles:     d.FileLog.MaxDateFiles,
+		Compress:         d.FileLog.StreamingCompression,
+		RotationInterval: d.FileLog.RotationInterval,
+		ArchiveAfter:     d.FileLog.ArchiveAfter,
+		ArchiveGroupBy:   d.FileLog.ArchiveGroupBy,
+		OpenMode:         d.FileLog.OpenMode,
+		FileNamePolicy:   d.FileLog.FileNamePolicy,
+		Now:              d.FileLog.now,
+	}
+	if d.FileLog.IsLimitedFileSize {
+		options.MaxFileSize = d.FileLog.MaxFileSize
+	}
+	if h, ok := d.LogFormatter.(HeaderProvider); ok && h.Header() != "" {
+		options.Header = h.Header() + d.lineEnding()
+	}
+	options.BOM = d.FileLog.WriteBOM
+	return options
+}
 
-		// Check if the log file name has changed and create a new log file if necessary.
-		if fileName != d.FileLog.CurrentFileName {
-			d.FileLog.CurrentFileName = fileName
-			if err := d.createLogFile(); err != nil {
-				return err
-			}
-		}
+// CloseLogFile commits the log file to stable storage, closes it and signals the log finishing channel. It
+// returns the errors of syncing and closing the file, naming it. An injected writer is synced when it
+// implements Sync, unless it is a terminal, pipe or other device like os.Stdout, and only closed when CloseWriter
+// is set and it implements io.Closer.
+func (d *LogRule) CloseLogFile() error {
+	if l := d.lifecycle; l != nil {
+		l.fileMu.Lock()
+		defer l.fileMu.Unlock()
+	}
+	if d.FileLog.target == nil {
+		return nil
+	}
 
-		// Check if the log file size limit is enabled and trim if necessary.
-		if d.FileLog.IsLimitedFileSize {
-			fileInfo, err := d.FileLog.File.Stat()
-			if err != nil {
-				return fmt.Errorf("failed to get file info: %w", err)
+	var err error
+	d.FileLog.closed = true
+	if d.logFinishChannel != nil {
+		close(d.logFinishChannel) // Signal that logging has finished.
+	}
+	if d.FileLog.writer != nil {
+		err = d.FileLog.writer.Close() // Sync and close the log file.
+		d.FileLog.writer = nil
+	} else {
+		var errs []error
+		if syncer, ok := d.FileLog.target.(interface{ Sync() error }); ok && syncable(d.FileLog.target) {
+			if syncErr := syncer.Sync(); syncErr != nil {
+				errs = append(errs, fmt.Errorf("syncing file writer of %s: %w", d.ModuleName, syncErr))
 			}
-
-			newMsgSize := int64(len(msg))
-
-			// If the log file exceeds the maximum size, trim it.
-			if fileInfo.Size()+newMsgSize > d.FileLog.MaxFileSize {
-				overSize := (fileInfo.Size() + newMsgSize) - d.FileLog.MaxFileSize
-				if err := d.trimLogFile(overSize); err != nil {
-					return fmt.Errorf("failed to trim log file: %w", err)
-				}
+		}
+		if closer, ok := d.FileLog.target.(io.Closer); ok && d.FileLog.CloseWriter {
+			if closeErr := closer.Close(); closeErr != nil {
+				errs = append(errs, fmt.Errorf("closing file writer of %s: %w", d.ModuleName, closeErr))
 			}
 		}
+		err = errors.Join(errs...)
+	}
+	d.FileLog.target = nil
+	d.FileLog.File = nil // Clear the file pointer.
+	return err
+}
 
-		// Write the log message to the file.
-		_, err := d.FileLog.File.WriteString(msg)
-		return err
+// syncable reports whether syncing the writer can succeed: files that are terminals, pipes or other devices, such
+// as os.Stdout, have nothing to commit to storage and fail to sync.
+func syncable(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return true
 	}
-	return fmt.Errorf("log file is not open") // Return an error if the log file is not open.
+	info, err := f.Stat()
+	return err != nil || info.Mode().IsRegular()
 }
 
-// trimLogFile trims the beginning of the log file by the specified size to fit the new log message.
-func (d *LogRule) trimLogFile(overSize int64) error {
-	// Open the existing log file for reading and writing.
-	oldFile, err := os.OpenFile(d.FileLog.CurrentFileName, os.O_RDWR, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open old log file: %w", err)
+// RotateNow forces a rotation of the log file regardless of its size or date, keeping
+// MaxBackups backups (at least one) and applying compression and retention like automatic rotation.
+// Concurrent writes land either in the rotated or in the new file.
+func (d *LogRule) RotateNow() error {
+	if l := d.lifecycle; l != nil {
+		l.fileMu.Lock()
+		defer l.fileMu.Unlock()
+	}
+	if d.FileLog.writer == nil {
+		return fmt.Errorf("log file of %s is not open or uses an injected writer", d.ModuleName)
+	}
+	if err := d.FileLog.writer.Rotate(); err != nil {
+		return err
 	}
-	defer oldFile.Close() // Ensure the file is closed after this function returns.
+	d.FileLog.File = d.FileLog.writer.File()
+	d.FileLog.CurrentFileName = d.FileLog.writer.FileName()
+	return nil
+}
 
-	// Get the information about the old log file.
-	oldFileInfo, err := oldFile.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to get file info: %w", err)
+// fileWriter returns the writer managing the log files of the rule, nil when no log file is open or an injected
+// writer is used. Writes, reopening and closing replace it, so the writer is read while holding the file lock.
+func (d *LogRule) fileWriter() *RotatingWriter {
+	if l := d.lifecycle; l != nil {
+		l.fileMu.Lock()
+		defer l.fileMu.Unlock()
 	}
+	return d.FileLog.writer
+}
 
-	bytesToKeep := oldFileInfo.Size() - overSize // Calculate how many bytes to keep.
+// scheduleRetention registers a maintenance task removing expired log files, so that retention
+// also applies while no new file is started.
+func (d *LogRule) scheduleRetention(name string) {
+	if d.FileLog.writer == nil || d.FileLog.MaxAge <= 0 || d.debugger == nil {
+		return
+	}
 
-	if bytesToKeep <= 0 {
-		// If there are no bytes to keep, truncate the file.
-		if err := oldFile.Truncate(0); err != nil {
-			return fmt.Errorf("failed to truncate log file: %w", err)
+	interval := MKLOG_RetentionCheckIntervalDefault
+	if d.FileLog.MaxAge < interval {
+		interval = d.FileLog.MaxAge
+	}
+	d.debugger.AddMaintenanceTask(name, interval, func(now time.Time) error {
+		if writer := d.fileWriter(); writer != nil {
+			writer.expire()
 		}
 		return nil
-	}
-
-	buffer := make([]byte, bytesToKeep) // Create a buffer for the remaining log data.
+	})
+}
 
-	// Read the remaining log data into the buffer.
-	if _, err := oldFile.ReadAt(buffer, overSize); err != nil {
-		return fmt.Errorf("failed to read remaining log data: %w", err)
+// writeFile writes the provided log message to the log file if logging to a file is enabled.
+// The time the message was logged decides the dated or rotated file it is written to.
+func (d *LogRule) writeFile(logged time.Time, msg string) error {
+	if l := d.lifecycle; l != nil {
+		l.fileMu.Lock()
+		defer l.fileMu.Unlock()
 	}
-
-	// Write the remaining log data back to the start of the file.
-	if _, err := oldFile.Seek(0, 0); err != nil {
-		return fmt.Errorf("failed to seek in log file: %w", err)
+	if err := d.openLogFile(); err != nil {
+		err = fmt.Errorf("opening log file: %w", err)
+		d.countWrite(0, err)
+		return err
 	}
-
-	if _, err := oldFile.Write(buffer); err != nil {
-		return fmt.Errorf("failed to write remaining log data: %w", err)
+	if writer := d.FileLog.writer; writer != nil {
+		start := time.Now()
+		n, err := writer.WriteAt(logged, []byte(msg))
+		d.observeWrite(fileSink, time.Since(start))
+		d.countWrite(n, err)
+		d.FileLog.File = writer.File()
+		d.FileLog.CurrentFileName = writer.FileName()
+		return err
 	}
+	if d.FileLog.target != nil {
+		start := time.Now()
+		n, err := io.WriteString(d.FileLog.target, msg)
+		d.observeWrite(fileSink, time.Since(start))
+		d.countWrite(n, err)
+		return err
+	}
+	err := fmt.Errorf("log file is not open") // Return an error if the log file is not open.
+	d.countWrite(0, err)
+	return err
+}
+
+// validLineEnding reports whether the line ending is supported; empty selects "\n".
+func validLineEnding(ending string) bool {
+	return ending == "" || ending == "\n" || ending == "\r\n"
+}
 
-	// Truncate the file to the new size.
-	if err := oldFile.Truncate(bytesToKeep); err != nil {
-		return fmt.Errorf("failed to truncate log file: %w", err)
+// lineEnding returns the line ending of the log file entries.
+func (d *LogRule) lineEnding() string {
+	if d.FileLog.LineEnding == "" {
+		return "\n"
 	}
+	return d.FileLog.LineEnding
+}
 
-	return nil
+// fileLine renders a formatted message as a line of the log file: the line endings formatters append are
+// replaced by the one line ending of the rule, after control characters are escaped when sanitizing.
+func (d *LogRule) fileLine(msg string) string {
+	msg = strings.TrimRight(msg, "\r\n")
+	if d.FileLog.Sanitize && !d.RawOutput {
+		msg = sanitizeControl(msg)
+	}
+	return msg + d.lineEnding()
 }
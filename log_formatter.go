@@ -1,8 +1,14 @@
 package mklog
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v2"
 )
@@ -15,17 +21,19 @@ type LogFormatter interface {
 
 // PlainTextFormatter is a LogFormatter implementation that formats log messages in plain text.
 type PlainTextFormatter struct {
-	dateFormat string
+	dateFormat         string
+	MultilineMode      string // Rendering of line breaks, MultilineRaw (default), MultilineEscape or MultilineIndent.
+	SubmoduleSeparator string // Separator of the submodule chain, MKLOG_SubmoduleSeparatorDefault when empty.
 }
 
-// Format formats the log message in plain text.
+// Format formats the log message in plain text, rendering the submodules as one chain, e.g. "[db.pool]".
 func (f PlainTextFormatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
 	if len(submodules) > 0 {
-		return fmt.Sprintf("%s | %s | [%s] - %v: %s",
+		return fmt.Sprintf("%s | %s | [%s] - [%s]: %s",
 			timestamp,
 			logLevel,
 			moduleName,
-			submodules,
+			joinSubmodules(submodules, f.SubmoduleSeparator),
 			logMessage,
 		)
 	} else {
@@ -40,31 +48,46 @@ func (f PlainTextFormatter) Format(logMessage string, logLevel string, moduleNam
 
 // JSONFormatter is a LogFormatter implementation that formats log messages in JSON.
 type JSONFormatter struct {
-	dateFormat string
+	dateFormat         string
+	Pretty             bool   // Indent the JSON document over several lines.
+	SubmoduleSeparator string // Join the submodules into one string with the separator instead of an array.
+	FieldRendering            // Rendering of duration, time and byte slice field values.
 }
 
 // Format formats the log message in JSON.
 func (f JSONFormatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	return f.FormatFields(logMessage, logLevel, moduleName, submodules, timestamp, nil)
+}
 
-	logData := make(map[string]interface{})
-	if len(submodules) > 0 {
-		logData = map[string]interface{}{
-			"timestamp":  timestamp,
-			"logLevel":   logLevel,
-			"moduleName": moduleName,
-			"submodules": submodules,
-			"logMessage": logMessage,
-		}
-	} else {
-		logData = map[string]interface{}{
-			"timestamp":  timestamp,
-			"logLevel":   logLevel,
-			"moduleName": moduleName,
-			"logMessage": logMessage,
-		}
+// FormatFields formats the log message in JSON, adding the fields as the "fields" object.
+func (f JSONFormatter) FormatFields(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string, fields map[string]interface{}) string {
+	return f.FormatEntry(entryFromNames(logMessage, logLevel, moduleName, submodules, fields), timestamp)
+}
+
+// FormatEntry formats the entry in JSON, adding the numeric level as "severity".
+func (f JSONFormatter) FormatEntry(entry Entry, timestamp string) string {
+	logData := map[string]interface{}{
+		"timestamp":  timestamp,
+		"logLevel":   entry.LevelName,
+		"moduleName": entry.Module,
+		"logMessage": entry.Message,
+	}
+	if entry.Level != unknownLevel {
+		logData["severity"] = int(entry.Level)
+	}
+	if len(entry.Submodules) > 0 {
+		logData["submodules"] = submodulesValue(entry.Submodules, f.SubmoduleSeparator)
+	}
+	if len(entry.Fields) > 0 {
+		logData["fields"] = f.normalizeFields(entry.Fields, f.dateFormat)
 	}
 
-	logJSON, _ := json.Marshal(logData)
+	var logJSON []byte
+	if f.Pretty {
+		logJSON, _ = json.MarshalIndent(logData, "", "  ")
+	} else {
+		logJSON, _ = json.Marshal(logData)
+	}
 	return string(logJSON) + "\n"
 }
 
@@ -83,58 +106,263 @@ func (f UserDefinedFormatter) Format(logMessage string, logLevel string, moduleN
 
 // XMLFormatter is a LogFormatter implementation that formats log messages in XML.
 type XMLFormatter struct {
-	dateFormat string
+	dateFormat         string
+	Compact            bool   // Render the entry on a single line without indentation.
+	SubmoduleSeparator string // Separator of the submodule chain, MKLOG_SubmoduleSeparatorDefault when empty.
+	FieldRendering            // Rendering of duration, time and byte slice field values.
+}
+
+// Static parts of the XML entry, rendered once instead of on every call.
+var (
+	xmlEntryOpen       = []byte("<LogEntry>")
+	xmlEntryClose      = []byte("</LogEntry>")
+	xmlTimestampOpen   = []byte("<Timestamp>")
+	xmlTimestampClose  = []byte("</Timestamp>")
+	xmlLogLevelOpen    = []byte("<LogLevel>")
+	xmlLogLevelClose   = []byte("</LogLevel>")
+	xmlSeverityOpen    = []byte("<Severity>")
+	xmlSeverityClose   = []byte("</Severity>")
+	xmlModuleNameOpen  = []byte("<ModuleName>")
+	xmlModuleNameClose = []byte("</ModuleName>")
+	xmlSubmodulesOpen  = []byte("<Submodules>")
+	xmlSubmodulesClose = []byte("</Submodules>")
+	xmlMessageOpen     = []byte("<Message>")
+	xmlMessageClose    = []byte("</Message>")
+	xmlFieldsOpen      = []byte("<Fields>")
+	xmlFieldsClose     = []byte("</Fields>")
+	xmlFieldOpen       = []byte(`<Field Key="`)
+	xmlFieldClose      = []byte("</Field>")
+	xmlIndent          = []byte("    ")
+	xmlNewline         = []byte("\n")
+)
+
+// xmlBufferPool holds the buffers used by XMLFormatter.
+var xmlBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
 }
 
 // Format formats the log message in XML.
 func (f XMLFormatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	return f.FormatFields(logMessage, logLevel, moduleName, submodules, timestamp, nil)
+}
+
+// FormatFields formats the log message in XML, adding the fields as Field elements keyed by the Key attribute.
+func (f XMLFormatter) FormatFields(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string, fields map[string]interface{}) string {
+	return f.FormatEntry(entryFromNames(logMessage, logLevel, moduleName, submodules, fields), timestamp)
+}
+
+// FormatEntry formats the entry in XML, adding the numeric level as the Severity element.
+func (f XMLFormatter) FormatEntry(entry Entry, timestamp string) string {
+	submodules, fields := entry.Submodules, entry.Fields
+	if len(fields) > 0 {
+		fields = flattenFields(f.normalizeFields(fields, f.dateFormat))
+	}
+	buf := xmlBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	buf.Write(xmlEntryOpen)
+	f.newline(buf)
+	f.element(buf, xmlTimestampOpen, xmlTimestampClose, timestamp)
+	f.element(buf, xmlLogLevelOpen, xmlLogLevelClose, entry.LevelName)
+	if entry.Level != unknownLevel {
+		f.element(buf, xmlSeverityOpen, xmlSeverityClose, strconv.Itoa(int(entry.Level)))
+	}
+	f.element(buf, xmlModuleNameOpen, xmlModuleNameClose, entry.Module)
 	if len(submodules) > 0 {
-		return fmt.Sprintf("<LogEntry>\n"+
-			"    <Timestamp>%s</Timestamp>\n"+
-			"    <LogLevel>%s</LogLevel>\n"+
-			"    <ModuleName>%s</ModuleName>\n"+
-			"    <Submodules>%v</Submodules>\n"+
-			"    <Message>%s</Message>\n"+
-			"</LogEntry>\n",
-			timestamp,
-			logLevel,
-			moduleName,
-			submodules,
-			logMessage,
-		)
-	} else {
-		return fmt.Sprintf("<LogEntry>\n"+
-			"    <Timestamp>%s</Timestamp>\n"+
-			"    <LogLevel>%s</LogLevel>\n"+
-			"    <ModuleName>%s</ModuleName>\n"+
-			"    <Message>%s</Message>\n"+
-			"</LogEntry>\n",
-			timestamp,
-			logLevel,
-			moduleName,
-			logMessage,
-		)
+		f.indent(buf)
+		buf.Write(xmlSubmodulesOpen)
+		xml.EscapeText(buf, []byte(joinSubmodules(submodules, f.SubmoduleSeparator)))
+		buf.Write(xmlSubmodulesClose)
+		f.newline(buf)
+	}
+	f.element(buf, xmlMessageOpen, xmlMessageClose, entry.Message)
+	if len(fields) > 0 {
+		f.indent(buf)
+		buf.Write(xmlFieldsOpen)
+		f.newline(buf)
+		for _, k := range sortedFieldKeys(fields) {
+			f.indent(buf)
+			f.indent(buf)
+			buf.Write(xmlFieldOpen)
+			xml.EscapeText(buf, []byte(k))
+			buf.WriteString(`">`)
+			xml.EscapeText(buf, []byte(fieldString(fields[k])))
+			buf.Write(xmlFieldClose)
+			f.newline(buf)
+		}
+		f.indent(buf)
+		buf.Write(xmlFieldsClose)
+		f.newline(buf)
+	}
+	buf.Write(xmlEntryClose)
+	f.newline(buf)
+
+	s := buf.String()
+	xmlBufferPool.Put(buf)
+	return s
+}
+
+// element writes one indented element with escaped text content.
+func (f XMLFormatter) element(buf *bytes.Buffer, open, close []byte, text string) {
+	f.indent(buf)
+	buf.Write(open)
+	xml.EscapeText(buf, []byte(text))
+	buf.Write(close)
+	f.newline(buf)
+}
+
+// indent writes the indentation of child elements unless the formatter is compact.
+func (f XMLFormatter) indent(buf *bytes.Buffer) {
+	if !f.Compact {
+		buf.Write(xmlIndent)
+	}
+}
+
+// newline writes a line break unless the formatter is compact.
+func (f XMLFormatter) newline(buf *bytes.Buffer) {
+	if !f.Compact {
+		buf.Write(xmlNewline)
 	}
 }
 
 // YAMLFormatter is a LogFormatter implementation that formats log messages in YAML.
 type YAMLFormatter struct {
-	dateFormat string
+	dateFormat         string
+	SubmoduleSeparator string // Join the submodules into one string with the separator instead of a sequence.
+	FieldRendering            // Rendering of duration, time and byte slice field values.
 }
 
 // Format formats the log message in YAML.
 func (f YAMLFormatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	return f.FormatFields(logMessage, logLevel, moduleName, submodules, timestamp, nil)
+}
+
+// FormatFields formats the log message in YAML, adding the fields as the "fields" mapping.
+func (f YAMLFormatter) FormatFields(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string, fields map[string]interface{}) string {
+	return f.FormatEntry(entryFromNames(logMessage, logLevel, moduleName, submodules, fields), timestamp)
+}
+
+// FormatEntry formats the entry in YAML, adding the numeric level as "severity".
+func (f YAMLFormatter) FormatEntry(entry Entry, timestamp string) string {
 	logData := make(map[string]interface{})
 	logData["timestamp"] = timestamp
-	logData["logLevel"] = logLevel
-	logData["moduleName"] = moduleName
+	logData["logLevel"] = entry.LevelName
+	if entry.Level != unknownLevel {
+		logData["severity"] = int(entry.Level)
+	}
+	logData["moduleName"] = entry.Module
 
-	if len(submodules) > 0 {
-		logData["submodules"] = submodules
+	if len(entry.Submodules) > 0 {
+		logData["submodules"] = submodulesValue(entry.Submodules, f.SubmoduleSeparator)
 	}
 
-	logData["logMessage"] = logMessage
+	logData["logMessage"] = entry.Message
+
+	if len(entry.Fields) > 0 {
+		logData["fields"] = f.normalizeFields(entry.Fields, f.dateFormat)
+	}
 
 	logYAML, _ := yaml.Marshal(logData)
 	return string(logYAML) + "\n"
 }
+
+// HeaderProvider is an optional interface of log formatters whose log files start with a header line,
+// e.g. the column names of CSV. File logging writes the header once at the start of every new file:
+// when it is created and after each rotation or rollover, but never into a file that already has content.
+type HeaderProvider interface {
+	// Header returns the header line without the line ending, or an empty string for no header.
+	Header() string
+}
+
+// CSVFormatter is a LogFormatter implementation that formats log messages as CSV records with the columns
+// time, level, module, submodules, message and fields. Submodules are joined with SubmoduleSeparator and the
+// fields are a JSON object. It implements HeaderProvider, so log files start with the column names.
+type CSVFormatter struct {
+	dateFormat         string
+	NoHeader           bool   // Start log files without the column names.
+	SubmoduleSeparator string // Separator of the submodule chain, MKLOG_SubmoduleSeparatorDefault when empty.
+	FieldRendering            // Rendering of duration, time and byte slice field values.
+}
+
+// Header returns the column names of the CSV records.
+func (f CSVFormatter) Header() string {
+	if f.NoHeader {
+		return ""
+	}
+	return "time,level,module,submodules,message,fields"
+}
+
+// Format formats the log message as a CSV record.
+func (f CSVFormatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	return f.FormatFields(logMessage, logLevel, moduleName, submodules, timestamp, nil)
+}
+
+// FormatFields formats the log message as a CSV record, adding the fields as a JSON object.
+func (f CSVFormatter) FormatFields(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string, fields map[string]interface{}) string {
+	return f.FormatEntry(entryFromNames(logMessage, logLevel, moduleName, submodules, fields), timestamp)
+}
+
+// FormatEntry formats the entry as a CSV record.
+func (f CSVFormatter) FormatEntry(entry Entry, timestamp string) string {
+	var fields string
+	if len(entry.Fields) > 0 {
+		data, _ := json.Marshal(f.normalizeFields(entry.Fields, f.dateFormat))
+		fields = string(data)
+	}
+
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{timestamp, entry.LevelName, entry.Module, joinSubmodules(entry.Submodules, f.SubmoduleSeparator), entry.Message, fields})
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// LogfmtFormatter is a LogFormatter implementation that formats log messages as logfmt key=value pairs.
+type LogfmtFormatter struct {
+	dateFormat         string
+	SubmoduleSeparator string // Separator of the submodule chain, MKLOG_SubmoduleSeparatorDefault when empty.
+	FieldRendering            // Rendering of duration, time and byte slice field values.
+}
+
+// Format formats the log message in logfmt.
+func (f LogfmtFormatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	var sb strings.Builder
+	sb.WriteString("time=")
+	sb.WriteString(logfmtValue(timestamp))
+	sb.WriteString(" level=")
+	sb.WriteString(logfmtValue(logLevel))
+	sb.WriteString(" module=")
+	sb.WriteString(logfmtValue(moduleName))
+	if len(submodules) > 0 {
+		sb.WriteString(" submodules=")
+		sb.WriteString(logfmtValue(joinSubmodules(submodules, f.SubmoduleSeparator)))
+	}
+	sb.WriteString(" msg=")
+	sb.WriteString(logfmtValue(logMessage))
+	return sb.String()
+}
+
+// FormatFields formats the log message in logfmt, appending the fields as key=value pairs.
+func (f LogfmtFormatter) FormatFields(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string, fields map[string]interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(f.Format(logMessage, logLevel, moduleName, submodules, timestamp))
+	if len(fields) > 0 {
+		appendLogfmtFields(&sb, f.normalizeFields(fields, f.dateFormat))
+	}
+	return sb.String()
+}
+
+// logfmtValue quotes the value when it is empty or contains spaces, quotes, equal signs or control characters.
+func logfmtValue(v string) string {
+	if v == "" {
+		return `""`
+	}
+	for _, r := range v {
+		if r <= ' ' || r == '"' || r == '=' || r == '\\' || r == 0x7f {
+			return strconv.Quote(v)
+		}
+	}
+	return v
+}
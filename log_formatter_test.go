@@ -0,0 +1,135 @@
+package mklog
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xmlEntry is the element XMLFormatter renders, read back with encoding/xml.
+type xmlEntry struct {
+	XMLName    xml.Name `xml:"LogEntry"`
+	Timestamp  string   `xml:"Timestamp"`
+	LogLevel   string   `xml:"LogLevel"`
+	Severity   int      `xml:"Severity"`
+	ModuleName string   `xml:"ModuleName"`
+	Submodules string   `xml:"Submodules"`
+	Message    string   `xml:"Message"`
+	Fields     []struct {
+		Key   string `xml:"Key,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"Fields>Field"`
+}
+
+// TestXMLFormatterRoundTrip parses a log file of indented and compact entries with encoding/xml and gets back
+// the values of the entries, including markup characters and line breaks.
+func TestXMLFormatterRoundTrip(t *testing.T) {
+	entry := Entry{
+		Level:      ErrorLevel,
+		LevelName:  "ERROR",
+		Module:     "billing & <payments>",
+		Submodules: []string{"api", "v2"},
+		Message:    "charge \"42\" failed: <nil> & 'retry'\nsecond line\ttabbed",
+		Fields:     map[string]interface{}{"user": "o'brien <admin>", "amount": 12.5, "nested": map[string]interface{}{"ok": true}},
+	}
+	var file strings.Builder
+	for _, f := range []XMLFormatter{{}, {Compact: true}} {
+		file.WriteString(f.FormatEntry(entry, "2024-03-09 10:20:30"))
+	}
+
+	dec := xml.NewDecoder(strings.NewReader(file.String()))
+	var parsed []xmlEntry
+	for {
+		var e xmlEntry
+		if err := dec.Decode(&e); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("decoding %q: %v", file.String(), err)
+		}
+		parsed = append(parsed, e)
+	}
+	if len(parsed) != 2 {
+		t.Fatalf("parsed %d entries, want 2", len(parsed))
+	}
+	for i, e := range parsed {
+		if e.Timestamp != "2024-03-09 10:20:30" || e.LogLevel != "ERROR" || e.Severity != int(ErrorLevel) ||
+			e.ModuleName != entry.Module || e.Submodules != "api.v2" || e.Message != entry.Message {
+			t.Errorf("entry %d = %+v, want the values of the entry", i, e)
+		}
+		fields := map[string]string{}
+		for _, f := range e.Fields {
+			fields[f.Key] = f.Value
+		}
+		want := map[string]string{"amount": "12.5", "nested.ok": "true", "user": "o'brien <admin>"}
+		if len(fields) != len(want) {
+			t.Errorf("entry %d fields = %v, want %v", i, fields, want)
+		}
+		for k, v := range want {
+			if fields[k] != v {
+				t.Errorf("entry %d field %s = %q, want %q", i, k, fields[k], v)
+			}
+		}
+	}
+}
+
+// TestJSONSeverityWithCustomLevelNames keeps the numeric severity of the level in the JSON output of a rule
+// renaming its levels, and leaves it out for entries rebuilt from a name that is no level.
+func TestJSONSeverityWithCustomLevelNames(t *testing.T) {
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(out), WithLogFormatter(JSONFormatter{}), func(lr *LogRule) {
+		lr.SetCustomLogLevelNames(map[LogLevel]string{WarningLevel: "CAUTION", ErrorLevel: "ALARM"})
+	})
+	d.Info("started")
+	d.Warning("disk almost full")
+	d.Error("disk full")
+	d.Close(context.Background())
+
+	want := []struct {
+		name     string
+		severity LogLevel
+	}{{"INFO", InfoLevel}, {"CAUTION", WarningLevel}, {"ALARM", ErrorLevel}}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("output %q, want %d lines", out.String(), len(want))
+	}
+	for i, line := range lines {
+		var doc struct {
+			LogLevel string `json:"logLevel"`
+			Severity *int   `json:"severity"`
+		}
+		if err := json.Unmarshal([]byte(line), &doc); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if doc.LogLevel != want[i].name || doc.Severity == nil || *doc.Severity != int(want[i].severity) {
+			t.Errorf("line %q, want logLevel %s with severity %d", line, want[i].name, want[i].severity)
+		}
+	}
+
+	if got := (JSONFormatter{}).Format("legacy", "CAUTION", "app", nil, ""); strings.Contains(got, "severity") {
+		t.Errorf("Format with an unknown level name = %q, want no severity", got)
+	}
+}
+
+// BenchmarkXMLFormatter formats an entry with fields with XMLFormatter.
+func BenchmarkXMLFormatter(b *testing.B) {
+	f := XMLFormatter{}
+	entry := Entry{
+		Level:      WarningLevel,
+		LevelName:  "WARN",
+		Module:     "db",
+		Submodules: []string{"pool", "conn"},
+		Message:    "slow query <select>",
+		Fields:     map[string]interface{}{"took_ms": 1500, "table": "orders"},
+	}
+	timestamp := formatTimestamp(time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC), time.RFC3339Nano)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f.FormatEntry(entry, timestamp)
+	}
+}
@@ -0,0 +1,171 @@
+package mklog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRotateAllWhileLogging rotates the log files in the middle of a burst of messages, run with -race. Every
+// message ends up exactly once and whole in the current file or one of the backups.
+func TestRotateAllWhileLogging(t *testing.T) {
+	const goroutines, messages, rotations = 4, 500, 20
+
+	dir := t.TempDir()
+	d := (&Debugger{}).SetQuiet(true)
+	// Keep more backups than rotations, so no message is deleted.
+	if _, err := d.NewLogRuleE("app", WithFileLogging(dir, "app", ".log"), WithMaxBackups(rotations+1)); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < messages; i++ {
+				d.Info("goroutine %d message %d", g, i)
+			}
+		}(g)
+	}
+	for i := 0; i < rotations; i++ {
+		if err := d.RotateAll(); err != nil {
+			t.Error(err)
+		}
+	}
+	wg.Wait()
+
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "app.log*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]int)
+	lines := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			lines++
+			i := strings.Index(line, "goroutine ")
+			if i < 0 {
+				t.Errorf("torn line in %s: %q", filepath.Base(file), line)
+				continue
+			}
+			seen[line[i:]]++
+		}
+	}
+
+	if lines != goroutines*messages {
+		t.Errorf("got %d lines in %d files, want %d", lines, len(files), goroutines*messages)
+	}
+	for g := 0; g < goroutines; g++ {
+		for i := 0; i < messages; i++ {
+			if n := seen[fmt.Sprintf("goroutine %d message %d", g, i)]; n != 1 {
+				t.Errorf("goroutine %d message %d written %d times, want once", g, i, n)
+			}
+		}
+	}
+}
+
+// TestFileBOMAndCRLF inspects the raw bytes of the files of a rule writing a BOM and CRLF line endings: every
+// file starts with one BOM, including the one started by rotation, and every entry ends with exactly one CRLF,
+// also when the message or the formatter ends with a line break.
+func TestFileBOMAndCRLF(t *testing.T) {
+	dir := t.TempDir()
+	d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC) })
+	d.NewLogRule("app",
+		WithFileLogging(dir, "app", ".log"),
+		WithLogFormatter(newlineFormatter{}),
+		WithDateFormat("15:04:05"),
+		WithWriteBOM(true),
+		WithLineEnding("\r\n"),
+	)
+	d.Info("first")
+	d.Info("second\n")
+	if err := d.rules()["app"][0].RotateNow(); err != nil {
+		t.Fatal(err)
+	}
+	d.Info("third\r\n")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	bom := []byte{0xef, 0xbb, 0xbf}
+	want := map[string][]byte{
+		"app.log.1": append(append([]byte{}, bom...), "10:20:30 INFO first\r\n10:20:30 INFO second\r\n"...),
+		"app.log":   append(append([]byte{}, bom...), "10:20:30 INFO third\r\n"...),
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("%s = % x, want % x", name, data, content)
+		}
+	}
+}
+
+// TestFileBOMBeforeHeader writes the BOM before the header of the formatter, and neither again when the file
+// is reopened with content.
+func TestFileBOMBeforeHeader(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		d := (&Debugger{}).SetQuiet(true)
+		d.NewLogRule("app", WithFileLogging(dir, "app", ".csv"), WithLogFormatter(CSVFormatter{}), WithDateFormat("15"), WithWriteBOM(true), WithLineEnding("\r\n"))
+		d.Info("entry %d", i)
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "\xef\xbb\xbf" + CSVFormatter{}.Header() + "\r\n"
+	if !bytes.HasPrefix(data, []byte(prefix)) || bytes.Count(data, []byte{0xef, 0xbb, 0xbf}) != 1 ||
+		bytes.Count(data, []byte("\r\n")) != 3 || bytes.Count(data, []byte("\n")) != 3 {
+		t.Errorf("file = %q, want one BOM and header and two CRLF entries", data)
+	}
+}
+
+// TestUnsupportedLineEnding rejects a rule with a line ending other than LF or CRLF.
+func TestUnsupportedLineEnding(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	defer d.Close(context.Background())
+	_, err := d.NewLogRuleE("app", WithFileLogging(t.TempDir(), "app", ".log"), WithLineEnding("\r"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported line ending") {
+		t.Errorf("NewLogRuleE error = %v, want the unsupported line ending", err)
+	}
+}
+
+// TestCloseKeepsStdStreams closes os.Stdout and os.Stderr used as file writers without errors and leaves them open,
+// so the process can keep writing to them after Close.
+func TestCloseKeepsStdStreams(t *testing.T) {
+	for _, stream := range []*os.File{os.Stdout, os.Stderr} {
+		d := (&Debugger{}).SetQuiet(true)
+		d.NewLogRule("app", WithFileWriter(stream))
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Errorf("%s: Close error = %v", stream.Name(), err)
+		}
+		if _, err := stream.Stat(); err != nil {
+			t.Fatalf("%s: %v after Close, want it open", stream.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package mklog
+
+import (
+	"context"
+	"time"
+)
+
+// Logger is an immutable handle of a Debugger carrying pre-bound fields, e.g. the request_id, method
+// and path of one HTTP request. Its log methods add the bound fields to every entry without changing
+// the shared Debugger, so handles are cheap to create per request and safe for concurrent use.
+type Logger struct {
+	debugger *Debugger
+	parent   *Logger       // Handle the fields are added to, nil for handles created by Debugger.With
+	fields   []interface{} // Alternating keys and values bound by this handle
+	skip     int           // Frames of wrapper functions skipped when capturing the caller, see WithCallerSkip
+	module   string        // Module whose rules receive the entries, all rules when empty, see Debugger.Module
+	group    string        // Group the fields of this handle and its descendants are nested in, see WithGroup
+	groups   []string      // Groups of the handle chain, outermost first
+}
+
+// With returns a handle adding the fields, given as alternating keys and values, to every entry.
+//
+//	reqLog := d.With("request_id", id, "method", r.Method, "path", r.URL.Path)
+//	reqLog.Info("request started")
+func (d *Debugger) With(fields ...interface{}) *Logger {
+	return &Logger{debugger: d, fields: copyFields(fields)}
+}
+
+// Module returns a handle logging to the rules of the module only, instead of every rule of the Debugger.
+// A module without rules gets the fallback rule of the Debugger, see SetFallbackRule; without one its
+// entries are dropped.
+//
+//	dbLog := d.Module("db")
+//	dbLog.Info("connected")
+//
+// Names unknown to the strict registry are reported to the error handler, see RegisterModules and ModuleE.
+func (d *Debugger) Module(name string) *Logger {
+	if err := d.checkModule(name); err != nil {
+		d.notice(nil, name, ErrorLevel, err, "%v", err)
+	}
+	return &Logger{debugger: d, module: name}
+}
+
+// Module returns a handle with the fields of l logging to the rules of the module only.
+func (l *Logger) Module(name string) *Logger {
+	if err := l.debugger.checkModule(name); err != nil {
+		l.debugger.notice(nil, name, ErrorLevel, err, "%v", err)
+	}
+	return &Logger{debugger: l.debugger, parent: l, skip: l.skip, module: name, groups: l.groups}
+}
+
+// With returns a handle adding the fields to those bound by l; fields with the same key override l's values.
+func (l *Logger) With(fields ...interface{}) *Logger {
+	return &Logger{debugger: l.debugger, parent: l, fields: copyFields(fields), skip: l.skip, module: l.module, groups: l.groups}
+}
+
+// WithCallerSkip returns a handle whose Wrap skips n more frames when capturing the caller and the stack,
+// e.g. 1 for a logging façade of an application calling the handle, so errors point at the caller of the façade
+// instead of the façade itself.
+//
+//	func (a *AppLogger) Error(err error) { a.log.Error("%v", a.log.Wrap(mklog.ErrorLevel, err)) }
+//	app := &AppLogger{log: d.WithCallerSkip(1)}
+func (d *Debugger) WithCallerSkip(n int) *Logger {
+	return &Logger{debugger: d, skip: n}
+}
+
+// WithCallerSkip returns a handle with the fields of l skipping n frames more than l, for nested wrappers.
+func (l *Logger) WithCallerSkip(n int) *Logger {
+	return &Logger{debugger: l.debugger, parent: l, skip: l.skip + n, module: l.module, groups: l.groups}
+}
+
+// Debugger returns the Debugger the handle logs to.
+func (l *Logger) Debugger() *Debugger {
+	return l.debugger
+}
+
+// Fields returns the fields bound by the handle and its parents.
+func (l *Logger) Fields() map[string]interface{} {
+	return l.collectFields()
+}
+
+// Wrap returns the error as a DetailedError for an entry at the level like Debugger.Wrap, leaving out the
+// frames skipped by the handle from the caller and the stack.
+func (l *Logger) Wrap(level LogLevel, err error, args ...interface{}) DetailedError {
+	if err == nil || !l.debugger.capturesStack(level) {
+		return DetailedError{Err: err, Time: time.Now()}
+	}
+	return newDetailedError(err, findCaller(l.skip), l.skip, args...)
+}
+
+// CustomTrace logs a message at the specified log level with the bound fields, like Custom.
+//
+// Deprecated: use Custom.
+func (l *Logger) CustomTrace(logLevel LogLevel, msg string, args ...interface{}) {
+	l.log(nil, logLevel, msg, args)
+}
+
+// CustomDebug logs a message at the specified log level with the bound fields, like Custom.
+//
+// Deprecated: use Custom.
+func (l *Logger) CustomDebug(logLevel LogLevel, msg string, args ...interface{}) {
+	l.log(nil, logLevel, msg, args)
+}
+
+// Custom logs a message at the specified log level with the bound fields.
+func (l *Logger) Custom(logLevel LogLevel, msg string, args ...interface{}) {
+	l.log(nil, logLevel, msg, args)
+}
+
+// Trace logs a message at the Trace level with the bound fields.
+func (l *Logger) Trace(msg string, args ...interface{}) {
+	l.log(nil, TraceLevel, msg, args)
+}
+
+// Debug logs a message at the Debug level with the bound fields.
+func (l *Logger) Debug(msg string, args ...interface{}) {
+	l.log(nil, DebugLevel, msg, args)
+}
+
+// Info logs a message at the Info level with the bound fields.
+func (l *Logger) Info(msg string, args ...interface{}) {
+	l.log(nil, InfoLevel, msg, args)
+}
+
+// Warning logs a message at the Warning level with the bound fields.
+func (l *Logger) Warning(msg string, args ...interface{}) {
+	l.log(nil, WarningLevel, msg, args)
+}
+
+// Error logs a message at the Error level with the bound fields.
+func (l *Logger) Error(msg string, args ...interface{}) {
+	l.log(nil, ErrorLevel, msg, args)
+}
+
+// Fatal logs a message at the Fatal level with the bound fields, with the same exit behavior as Debugger.Fatal.
+func (l *Logger) Fatal(msg string, args ...interface{}) {
+	entry := l.log(nil, FatalLevel, msg, args)
+	l.debugger.handleFatal(entry)
+}
+
+// CustomCtx logs a message at the specified log level with the bound fields, passing the context to the enrichers.
+func (l *Logger) CustomCtx(ctx context.Context, logLevel LogLevel, msg string, args ...interface{}) {
+	l.log(ctx, logLevel, msg, args)
+}
+
+// TraceCtx logs a message at the Trace level with the bound fields, passing the context to the enrichers.
+func (l *Logger) TraceCtx(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, TraceLevel, msg, args)
+}
+
+// DebugCtx logs a message at the Debug level with the bound fields, passing the context to the enrichers.
+func (l *Logger) DebugCtx(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, DebugLevel, msg, args)
+}
+
+// InfoCtx logs a message at the Info level with the bound fields, passing the context to the enrichers.
+func (l *Logger) InfoCtx(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, InfoLevel, msg, args)
+}
+
+// WarningCtx logs a message at the Warning level with the bound fields, passing the context to the enrichers.
+func (l *Logger) WarningCtx(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, WarningLevel, msg, args)
+}
+
+// ErrorCtx logs a message at the Error level with the bound fields, passing the context to the enrichers.
+func (l *Logger) ErrorCtx(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, ErrorLevel, msg, args)
+}
+
+// FatalCtx logs a message at the Fatal level with the bound fields, like Fatal, passing the context to the enrichers.
+func (l *Logger) FatalCtx(ctx context.Context, msg string, args ...interface{}) {
+	entry := l.log(ctx, FatalLevel, msg, args)
+	l.debugger.handleFatal(entry)
+}
+
+// collectFields merges the fields of the handle chain into a new map, the innermost handle winning.
+func (l *Logger) collectFields() map[string]interface{} {
+	n := 0
+	for h := l; h != nil; h = h.parent {
+		n += len(h.fields) / 2
+	}
+	fields := make(map[string]interface{}, n)
+	l.addFieldsTo(fields)
+	return fields
+}
+
+// addFieldsTo adds the fields of the parents first, so that the handle overrides them. It returns the map the
+// fields of the handle went to, the innermost group of the handle chain, for the fields of its descendants.
+func (l *Logger) addFieldsTo(fields map[string]interface{}) map[string]interface{} {
+	target := fields
+	if l.parent != nil {
+		target = l.parent.addFieldsTo(fields)
+	}
+	if l.group != "" {
+		target = openGroup(target, l.group)
+	}
+	addFields(target, l.fields)
+	return target
+}
+
+// log logs the message with the bound fields to the rules of the module of the handle, nesting the Fields of
+// the call in the groups of the handle.
+func (l *Logger) log(ctx context.Context, logLevel LogLevel, msg string, args []interface{}) Entry {
+	return l.debugger.logModule(ctx, l.module, logLevel, l.collectFields(), msg, l.groupCallFields(args)...)
+}
+
+// copyFields copies the fields so the handle stays immutable when the caller reuses the slice.
+func copyFields(fields []interface{}) []interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	return append([]interface{}(nil), fields...)
+}
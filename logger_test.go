@@ -0,0 +1,48 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestWithConcurrent derives handles from one shared handle in many goroutines, each logging with its own
+// fields and reusing its field slice afterwards, run with -race. Every entry carries the fields of its handle
+// and the shared ones, and the shared handle keeps its own.
+func TestWithConcurrent(t *testing.T) {
+	const goroutines, messages = 16, 50
+	sink := &entrySink{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithSink(sink))
+	base := d.With("service", "billing")
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			fields := []interface{}{"worker", g, "service", "billing"}
+			worker := base.With(fields...)
+			fields[1] = -1 // The handle copied the fields.
+			for i := 0; i < messages; i++ {
+				worker.With("seq", i).Info("worker %d message %d", g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	d.Close(context.Background())
+
+	if len(sink.entries) != goroutines*messages {
+		t.Fatalf("got %d entries, want %d", len(sink.entries), goroutines*messages)
+	}
+	for _, entry := range sink.entries {
+		want := fmt.Sprintf("worker %v message %v", entry.Fields["worker"], entry.Fields["seq"])
+		if entry.Message != want || entry.Fields["service"] != "billing" || len(entry.Fields) != 3 {
+			t.Fatalf("entry %q with fields %v, want the fields of its handle", entry.Message, entry.Fields)
+		}
+	}
+	if fields := base.Fields(); len(fields) != 1 || fields["service"] != "billing" {
+		t.Errorf("shared handle fields = %v, want only service", fields)
+	}
+}
@@ -0,0 +1,187 @@
+// Package logtest captures the entries of an mklog Debugger in tests and asserts on them.
+//
+// Capture attaches a recorder to every rule of a Debugger constructed by the code under test
+// and detaches it when the test finishes:
+//
+//	func TestCheckout(t *testing.T) {
+//		svc := NewService() // builds its own *mklog.Debugger
+//		rec := logtest.Capture(t, svc.Logger())
+//
+//		svc.Checkout("cart-1")
+//
+//		rec.ExpectEntry(mklog.InfoLevel, "checkout started")
+//		rec.ExpectNoEntries(mklog.ErrorLevel)
+//		rec.ExpectOrdered(
+//			logtest.Want(mklog.InfoLevel, "checkout started"),
+//			logtest.Want(mklog.InfoLevel, "checkout finished"),
+//		)
+//	}
+//
+// Entries are recorded when they are dispatched, before async rules queue them, so assertions
+// never wait for an async consumer. A message accepted by several rules is recorded once per rule.
+package logtest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+var (
+	// Default settings of the failure messages
+	ContextEntriesDefault = 20 // Number of recorded entries listed in a failure message
+)
+
+// Expectation describes an entry by its level and a substring of its message.
+type Expectation struct {
+	Level   mklog.LogLevel
+	Message string
+}
+
+// Want returns the expectation of an entry at the level whose message contains substr.
+func Want(level mklog.LogLevel, substr string) Expectation {
+	return Expectation{Level: level, Message: substr}
+}
+
+// String describes the expectation in failure messages.
+func (e Expectation) String() string {
+	return fmt.Sprintf("%s entry containing %q", e.Level.GetLogLevelName(), e.Message)
+}
+
+// matches checks whether the entry fulfills the expectation.
+func (e Expectation) matches(entry mklog.Entry) bool {
+	return entry.Level == e.Level && strings.Contains(entry.Message, e.Message)
+}
+
+// Recorder is a hook recording the entries of a Debugger.
+type Recorder struct {
+	t testing.TB
+
+	mu      sync.Mutex
+	entries []mklog.Entry
+}
+
+// Capture attaches a new Recorder to every rule of the Debugger and detaches it at the cleanup of the test.
+// Rules added to the Debugger afterwards, including fallback rules, are captured too.
+func Capture(t testing.TB, d *mklog.Debugger) *Recorder {
+	t.Helper()
+	r := &Recorder{t: t}
+	d.AddHook(r)
+	t.Cleanup(func() {
+		d.RemoveHook(r)
+	})
+	return r
+}
+
+// Levels returns all built-in log levels. Entries of custom levels are recorded too, see FiresFor.
+func (r *Recorder) Levels() []mklog.LogLevel {
+	return []mklog.LogLevel{
+		mklog.TraceLevel,
+		mklog.DebugLevel,
+		mklog.InfoLevel,
+		mklog.WarningLevel,
+		mklog.ErrorLevel,
+		mklog.FatalLevel,
+	}
+}
+
+// FiresFor reports that entries of every level are recorded, including custom levels.
+func (r *Recorder) FiresFor(level mklog.LogLevel) bool {
+	return true
+}
+
+// Fire records the entry.
+func (r *Recorder) Fire(entry mklog.Entry) error {
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+	return nil
+}
+
+// Entries returns a copy of the recorded entries in the order they were logged.
+func (r *Recorder) Entries() []mklog.Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]mklog.Entry(nil), r.entries...)
+}
+
+// Reset discards the recorded entries.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+// ExpectEntry reports a test failure unless an entry at the level contains substr in its message,
+// and returns the first matching entry.
+func (r *Recorder) ExpectEntry(level mklog.LogLevel, substr string) mklog.Entry {
+	r.t.Helper()
+	want := Want(level, substr)
+	entries := r.Entries()
+	for _, e := range entries {
+		if want.matches(e) {
+			return e
+		}
+	}
+	r.t.Errorf("logtest: expected %s, got:\n%s", want, describe(entries))
+	return mklog.Entry{}
+}
+
+// ExpectNoEntries reports a test failure if any entry was logged at the level.
+func (r *Recorder) ExpectNoEntries(level mklog.LogLevel) {
+	r.t.Helper()
+	entries := r.Entries()
+	for _, e := range entries {
+		if e.Level == level {
+			r.t.Errorf("logtest: expected no %s entries, got:\n%s", level.GetLogLevelName(), describe(entries))
+			return
+		}
+	}
+}
+
+// ExpectOrdered reports a test failure unless the expectations are matched by entries in the given order.
+// Other entries may be logged in between.
+func (r *Recorder) ExpectOrdered(expectations ...Expectation) {
+	r.t.Helper()
+	entries := r.Entries()
+	next := 0
+	for _, want := range expectations {
+		found := false
+		for next < len(entries) {
+			e := entries[next]
+			next++
+			if want.matches(e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.t.Errorf("logtest: expected %s after the previous expectations, got:\n%s", want, describe(entries))
+			return
+		}
+	}
+}
+
+// describe lists the last recorded entries for a failure message.
+func describe(entries []mklog.Entry) string {
+	if len(entries) == 0 {
+		return "  (no entries)"
+	}
+
+	var sb strings.Builder
+	start := 0
+	if len(entries) > ContextEntriesDefault {
+		start = len(entries) - ContextEntriesDefault
+		fmt.Fprintf(&sb, "  ... %d earlier entries\n", start)
+	}
+	for i, e := range entries[start:] {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&sb, "  %s [%s] %s", e.Level.GetLogLevelName(), e.Module, e.Message)
+	}
+	return sb.String()
+}
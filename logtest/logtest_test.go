@@ -0,0 +1,137 @@
+package logtest_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/SHEP4RDO/mklog"
+	"github.com/SHEP4RDO/mklog/logtest"
+)
+
+// service stands for code under test that builds its own Debugger.
+type service struct {
+	log *mklog.Debugger
+}
+
+// newDebugger returns an empty Debugger without the notices of rules built with the default formatter.
+func newDebugger() *mklog.Debugger {
+	return (&mklog.Debugger{}).SetQuiet(true)
+}
+
+func newService(async bool) *service {
+	d := newDebugger()
+	d.NewLogRule("checkout",
+		mklog.WithMinLevel(mklog.InfoLevel),
+		mklog.WithMaxLevel(mklog.FatalLevel),
+		mklog.WithFileWriter(io.Discard),
+		mklog.WithAsyncLog(async, 16),
+	)
+	return &service{log: d}
+}
+
+func (s *service) Checkout(cart string) {
+	s.log.Info("checkout started for %s", cart)
+	s.log.Debug("cart contents loaded")
+	s.log.Info("checkout finished for %s", cart)
+}
+
+func TestCaptureExample(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		svc := newService(async)
+		rec := logtest.Capture(t, svc.log)
+
+		svc.Checkout("cart-1")
+
+		rec.ExpectEntry(mklog.InfoLevel, "checkout started")
+		rec.ExpectNoEntries(mklog.ErrorLevel)
+		rec.ExpectNoEntries(mklog.DebugLevel)
+		rec.ExpectOrdered(
+			logtest.Want(mklog.InfoLevel, "checkout started"),
+			logtest.Want(mklog.InfoLevel, "checkout finished"),
+		)
+		if _, err := svc.log.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCaptureCustomLevels(t *testing.T) {
+	const auditLevel = mklog.FatalLevel + 1
+
+	d := newDebugger()
+	d.NewLogRule("audit", mklog.WithMaxLevel(auditLevel), mklog.WithFileWriter(io.Discard))
+	rec := logtest.Capture(t, d)
+
+	d.Custom(auditLevel, "user %s signed in", "alice")
+
+	if entry := rec.ExpectEntry(auditLevel, "signed in"); entry.Module != "audit" {
+		t.Errorf("got module %q, want audit", entry.Module)
+	}
+}
+
+func TestCaptureRulesAddedLater(t *testing.T) {
+	d := newDebugger()
+	d.SetFallbackRule(mklog.WithFileWriter(io.Discard))
+	rec := logtest.Capture(t, d)
+
+	d.NewLogRule("orders", mklog.WithFileWriter(io.Discard))
+	d.Module("orders").Info("order placed")
+	d.Module("billing").Info("invoice sent") // Instantiates the fallback rule of billing.
+
+	rec.ExpectOrdered(
+		logtest.Want(mklog.InfoLevel, "order placed"),
+		logtest.Want(mklog.InfoLevel, "invoice sent"),
+	)
+}
+
+func TestCaptureDetachesAtCleanup(t *testing.T) {
+	d := newDebugger()
+	d.NewLogRule("app", mklog.WithFileWriter(io.Discard))
+
+	var rec *logtest.Recorder
+	t.Run("captured", func(t *testing.T) {
+		rec = logtest.Capture(t, d)
+		d.Info("during the test")
+		rec.ExpectEntry(mklog.InfoLevel, "during the test")
+	})
+	d.Info("after the test")
+
+	if entries := rec.Entries(); len(entries) != 1 {
+		t.Errorf("got %d entries after cleanup, want 1", len(entries))
+	}
+}
+
+// failingTB records the failure messages of the assertions instead of failing the test.
+type failingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (f *failingTB) Helper()        {}
+func (f *failingTB) Cleanup(func()) {}
+func (f *failingTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFailureMessagesListEntries(t *testing.T) {
+	d := newDebugger()
+	d.NewLogRule("app", mklog.WithFileWriter(io.Discard))
+	tb := &failingTB{}
+	rec := logtest.Capture(tb, d)
+
+	d.Info("first")
+	d.Warning("second")
+	rec.ExpectEntry(mklog.ErrorLevel, "third")
+
+	if len(tb.errors) != 1 {
+		t.Fatalf("got %d failures, want 1", len(tb.errors))
+	}
+	for _, want := range []string{`ERROR entry containing "third"`, "INFO [app] first", "WARNING [app] second"} {
+		if !strings.Contains(tb.errors[0], want) {
+			t.Errorf("failure message %q does not contain %q", tb.errors[0], want)
+		}
+	}
+}
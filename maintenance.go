@@ -0,0 +1,247 @@
+package mklog
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// Default settings of the maintenance scheduler
+	MKLOG_MaintenanceCoalesceDefault    = time.Second // Tasks due within this window of each other run in the same wakeup
+	MKLOG_RetentionCheckIntervalDefault = time.Hour   // Default interval of the retention task of file logging
+)
+
+// MaintenanceTask is a periodic background task run by the maintenance scheduler of a Debugger.
+// It receives the current time of the Debugger clock.
+type MaintenanceTask func(now time.Time) error
+
+// MaintenanceTaskStats describes the state of a registered maintenance task.
+type MaintenanceTaskStats struct {
+	Name      string        `json:"name"`       // Name the task was registered with
+	Interval  time.Duration `json:"interval"`   // Interval between runs
+	Runs      uint64        `json:"runs"`       // Number of completed runs
+	Failures  uint64        `json:"failures"`   // Number of runs returning an error or panicking
+	LastRun   time.Time     `json:"last_run"`   // Start of the last run, zero before the first run
+	NextRun   time.Time     `json:"next_run"`   // Time the task is due next
+	LastError string        `json:"last_error"` // Error of the last failed run
+}
+
+// maintenanceTask is a task registered with the scheduler.
+type maintenanceTask struct {
+	run   MaintenanceTask
+	stats MaintenanceTaskStats
+}
+
+// maintenanceScheduler runs the periodic tasks of a Debugger from a single goroutine.
+type maintenanceScheduler struct {
+	debugger *Debugger
+
+	mu      sync.Mutex
+	running sync.Mutex // Serializes runs of due tasks
+	tasks   map[string]*maintenanceTask
+	started bool
+	closed  bool          // Set when the Debugger is closed; no tasks are accepted afterwards
+	wake    chan struct{} // Signals changed tasks
+	done    chan struct{} // Closed to stop the goroutine
+	stopped sync.WaitGroup
+}
+
+// AddMaintenanceTask registers a task run every interval by the maintenance scheduler of the Debugger,
+// starting the scheduler on first use. A task registered under an existing name replaces it.
+// Errors and panics of the task are passed to the error handler. Tasks cannot be added once the Debugger is
+// closed.
+func (d *Debugger) AddMaintenanceTask(name string, interval time.Duration, task MaintenanceTask) error {
+	if interval <= 0 {
+		return fmt.Errorf("[mklog] maintenance task %s requires a positive interval", name)
+	}
+
+	s := d.maintenanceScheduler()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return fmt.Errorf("[mklog] maintenance task %s added after the Debugger was closed", name)
+	}
+	s.tasks[name] = &maintenanceTask{
+		run: task,
+		stats: MaintenanceTaskStats{
+			Name:     name,
+			Interval: interval,
+			NextRun:  d.now().Add(interval),
+		},
+	}
+	if !s.started {
+		s.started = true
+		s.stopped.Add(1)
+		go s.loop()
+	}
+	s.mu.Unlock()
+
+	s.signal()
+	return nil
+}
+
+// RemoveMaintenanceTask unregisters the task with the given name.
+func (d *Debugger) RemoveMaintenanceTask(name string) {
+	s := d.maintenanceScheduler()
+	s.mu.Lock()
+	delete(s.tasks, name)
+	s.mu.Unlock()
+	s.signal()
+}
+
+// RunMaintenance runs all maintenance tasks that are due at the current time of the Debugger clock.
+// The scheduler calls it on its own; it is exported for clocks that do not advance in real time.
+func (d *Debugger) RunMaintenance() {
+	d.maintenanceScheduler().runDue(d.now())
+}
+
+// maintenanceStats returns the state of the registered tasks ordered by name.
+func (d *Debugger) maintenanceStats() []MaintenanceTaskStats {
+	s := d.maintenanceScheduler()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]MaintenanceTaskStats, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		stats = append(stats, t.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// stopMaintenance stops the scheduler goroutine for good and waits for the running tasks.
+func (d *Debugger) stopMaintenance() {
+	s := d.maintenanceScheduler()
+	s.mu.Lock()
+	s.closed = true
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	close(s.done)
+	s.mu.Unlock()
+
+	s.stopped.Wait()
+}
+
+// maintenanceScheduler returns the scheduler of the Debugger, creating it on first use.
+func (d *Debugger) maintenanceScheduler() *maintenanceScheduler {
+	d.maintenanceOnce.Do(func() {
+		d.maintenance = &maintenanceScheduler{
+			debugger: d,
+			tasks:    make(map[string]*maintenanceTask),
+			wake:     make(chan struct{}, 1),
+			done:     make(chan struct{}),
+		}
+	})
+	return d.maintenance
+}
+
+// signal wakes the scheduler goroutine to recompute its next wakeup.
+func (s *maintenanceScheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop sleeps until the earliest task is due and runs the due tasks until the scheduler is stopped.
+func (s *maintenanceScheduler) loop() {
+	defer s.stopped.Done()
+
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.nextWait())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			s.runDue(s.debugger.now())
+		case <-s.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-done:
+			return
+		}
+		timer.Reset(s.nextWait())
+	}
+}
+
+// nextWait returns the time until the earliest task is due.
+func (s *maintenanceScheduler) nextWait() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, t := range s.tasks {
+		if next.IsZero() || t.stats.NextRun.Before(next) {
+			next = t.stats.NextRun
+		}
+	}
+	if next.IsZero() {
+		return time.Hour
+	}
+
+	wait := next.Sub(s.debugger.now())
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// runDue runs the tasks due at now, together with the tasks due within the coalescing window.
+func (s *maintenanceScheduler) runDue(now time.Time) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	horizon := now.Add(MKLOG_MaintenanceCoalesceDefault)
+
+	s.mu.Lock()
+	var due []*maintenanceTask
+	for _, t := range s.tasks {
+		if t.stats.NextRun.Before(horizon) {
+			due = append(due, t)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].stats.Name < due[j].stats.Name })
+	for _, t := range due {
+		err := t.safeRun(now)
+
+		s.mu.Lock()
+		t.stats.Runs++
+		t.stats.LastRun = now
+		t.stats.NextRun = now.Add(t.stats.Interval)
+		if err != nil {
+			t.stats.Failures++
+			t.stats.LastError = err.Error()
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			s.debugger.handleError(fmt.Errorf("[mklog] maintenance task %s failed: %w", t.stats.Name, err))
+		}
+	}
+}
+
+// safeRun runs the task, converting a panic into an error.
+func (t *maintenanceTask) safeRun(now time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return t.run(now)
+}
@@ -0,0 +1,138 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMaintenanceRegistration lists the registered tasks by name with their next run, replaces a task
+// registered under an existing name and rejects intervals that are not positive.
+func TestMaintenanceRegistration(t *testing.T) {
+	start := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	d := (&Debugger{}).SetQuiet(true).SetClock(newFakeClock(start).Now)
+	defer d.Close(context.Background())
+	noop := func(time.Time) error { return nil }
+
+	for _, name := range []string{"retention", "archive", "replay"} {
+		if err := d.AddMaintenanceTask(name, time.Hour, noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.AddMaintenanceTask("replay", time.Minute, noop); err != nil {
+		t.Fatal(err)
+	}
+	d.RemoveMaintenanceTask("archive")
+	if err := d.AddMaintenanceTask("watchdog", 0, noop); err == nil {
+		t.Error("AddMaintenanceTask accepted a zero interval")
+	}
+
+	stats := d.maintenanceStats()
+	if len(stats) != 2 || stats[0].Name != "replay" || stats[1].Name != "retention" {
+		t.Fatalf("tasks = %+v, want replay and retention", stats)
+	}
+	if stats[0].Interval != time.Minute || !stats[0].NextRun.Equal(start.Add(time.Minute)) {
+		t.Errorf("replay = %+v, want the replaced interval of a minute", stats[0])
+	}
+	if !stats[1].NextRun.Equal(start.Add(time.Hour)) || stats[1].Runs != 0 {
+		t.Errorf("retention = %+v, want its first run an hour after registration", stats[1])
+	}
+}
+
+// TestMaintenanceFakeClock runs each task once per interval elapsed on the Debugger clock, records failures
+// and panics and passes them to the error handler.
+func TestMaintenanceFakeClock(t *testing.T) {
+	start := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.SetErrorHandler(rec.handle)
+	d.SetErrorCoalescing(0)
+	defer d.Close(context.Background())
+
+	var minutely, fiveMinutely int32
+	d.AddMaintenanceTask("minutely", time.Minute, func(time.Time) error {
+		atomic.AddInt32(&minutely, 1)
+		return nil
+	})
+	d.AddMaintenanceTask("five-minutely", 5*time.Minute, func(time.Time) error {
+		if atomic.AddInt32(&fiveMinutely, 1) == 1 {
+			return errors.New("disk full")
+		}
+		panic("corrupt index")
+	})
+
+	d.RunMaintenance() // Nothing is due yet.
+	for i := 1; i <= 10; i++ {
+		clock.Set(start.Add(time.Duration(i) * time.Minute))
+		d.RunMaintenance()
+	}
+
+	if got := atomic.LoadInt32(&minutely); got != 10 {
+		t.Errorf("minutely task ran %d times, want 10", got)
+	}
+	if got := atomic.LoadInt32(&fiveMinutely); got != 2 {
+		t.Errorf("five-minutely task ran %d times, want 2", got)
+	}
+	stats := d.maintenanceStats()
+	if s := stats[0]; s.Name != "five-minutely" || s.Runs != 2 || s.Failures != 2 || !strings.HasPrefix(s.LastError, "panic: corrupt index") {
+		t.Errorf("five-minutely = %+v, want 2 failed runs ending with the panic", s)
+	}
+	if s := stats[1]; s.Runs != 10 || s.Failures != 0 || !s.LastRun.Equal(start.Add(10*time.Minute)) ||
+		!s.NextRun.Equal(start.Add(11*time.Minute)) {
+		t.Errorf("minutely = %+v, want 10 runs, the last one now", s)
+	}
+
+	errs := rec.get()
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "maintenance task five-minutely failed: disk full") ||
+		!strings.Contains(errs[1].Error(), "panic: corrupt index") {
+		t.Errorf("errors = %v, want the error and the panic of the task", errs)
+	}
+}
+
+// TestMaintenanceShutdown waits in Close for a running task, stops the scheduler and rejects tasks added
+// afterwards instead of starting it again.
+func TestMaintenanceShutdown(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	started, release := make(chan struct{}), make(chan struct{})
+	var runs int32
+	d.AddMaintenanceTask("slow", time.Millisecond, func(time.Time) error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	})
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		d.Close(context.Background())
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a task was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+
+	n := atomic.LoadInt32(&runs)
+	if err := d.AddMaintenanceTask("late", time.Millisecond, func(time.Time) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}); err == nil || !strings.Contains(err.Error(), "after the Debugger was closed") {
+		t.Errorf("AddMaintenanceTask after Close = %v, want it rejected", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := atomic.LoadInt32(&runs); got != n {
+		t.Errorf("tasks ran %d times after Close, want none", got-n)
+	}
+	if d.maintenance.started {
+		t.Error("the scheduler was started again after Close")
+	}
+}
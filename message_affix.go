@@ -0,0 +1,28 @@
+package mklog
+
+// WithMessagePrefix prepends the text to the message of every entry of the rule, e.g. a tag like "APP1|" that
+// scripts parsing the log file select the lines of a subsystem by. It is added to the message before formatting,
+// so structured formatters place it inside the message field rather than in front of the line. The prefix is
+// added last, after middleware like RedactMiddleware and console prefixes added before formatting, so these
+// never change or move it. Hooks receive the message of the entry without it. An empty prefix adds nothing.
+func WithMessagePrefix(prefix string) Option {
+	return func(lr *LogRule) {
+		lr.MessagePrefix = prefix
+	}
+}
+
+// WithMessageSuffix appends the text to the message of every entry of the rule like WithMessagePrefix. It
+// follows the message itself, before the error details of a DetailedError. An empty suffix adds nothing.
+func WithMessageSuffix(suffix string) Option {
+	return func(lr *LogRule) {
+		lr.MessageSuffix = suffix
+	}
+}
+
+// decorateMessage returns the message with the prefix and suffix of the rule.
+func (lr *LogRule) decorateMessage(message string) string {
+	if lr.MessagePrefix == "" && lr.MessageSuffix == "" {
+		return message
+	}
+	return lr.MessagePrefix + message + lr.MessageSuffix
+}
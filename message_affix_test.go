@@ -0,0 +1,94 @@
+package mklog
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestMessageAffixPlacement places the prefix and suffix around the message in plain text output and inside
+// the message field of JSON output, leaving the rest of the line alone.
+func TestMessageAffixPlacement(t *testing.T) {
+	plain, jsonOut := &syncBuffer{}, &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(plain), WithDateFormat("-"), WithMessagePrefix("APP1|"), WithMessageSuffix("|END"))
+	d.NewLogRule("app", WithFileWriter(jsonOut), WithLogFormatter(JSONFormatter{}), WithMessagePrefix("APP1|"))
+	d.With("user", "ann").Info("started")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := plain.String(); !strings.HasPrefix(got, "- | INFO | [app] : APP1|started|END") {
+		t.Errorf("plain = %q, want the message wrapped", got)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonOut.String()), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["logMessage"] != "APP1|started" || data["moduleName"] != "app" {
+		t.Errorf("json = %v, want the prefix in logMessage only", data)
+	}
+	if fields, _ := data["fields"].(map[string]interface{}); fields["user"] != "ann" {
+		t.Errorf("json fields = %v, want them untouched", data["fields"])
+	}
+}
+
+// TestMessageAffixEmpty leaves the message unchanged without prefix and suffix.
+func TestMessageAffixEmpty(t *testing.T) {
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(out), WithDateFormat("-"), WithMessagePrefix(""), WithMessageSuffix(""))
+	d.Info("started")
+	d.Close(context.Background())
+	if got := out.String(); got != "- | INFO | [app] : started\n" {
+		t.Errorf("log = %q", got)
+	}
+}
+
+// TestMessageAffixAfterMiddleware adds the prefix after redaction, so a pattern matching it cannot change it,
+// and keeps it out of the message hooks receive.
+func TestMessageAffixAfterMiddleware(t *testing.T) {
+	out := &syncBuffer{}
+	hook := &retainingHook{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(out), WithDateFormat("-"), WithMessagePrefix("APP1|"))
+	d.Use(RedactMiddleware(regexp.MustCompile(`[A-Z]+[0-9]`), "***"))
+	d.AddHook(hook)
+	d.Info("token ABC1 issued")
+	d.Close(context.Background())
+
+	if got := out.String(); !strings.HasSuffix(got, "[app] : APP1|token *** issued\n") {
+		t.Errorf("log = %q, want the prefix kept and the message redacted", got)
+	}
+	if len(hook.entries) != 1 || strings.Contains(hook.entries[0].Message, "APP1|") {
+		t.Errorf("hook entries = %+v, want the message without the prefix", hook.entries)
+	}
+}
+
+// TestMessageAffixConfig sets the prefix and suffix of a rule from the configuration.
+func TestMessageAffixConfig(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      message_prefix: "APP1|"
+      message_suffix: " #"
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: app, file_type: .log}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Info("started")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); !strings.HasSuffix(got, "[app] : APP1|started #\n") {
+		t.Errorf("log = %q, want the configured prefix and suffix", got)
+	}
+}
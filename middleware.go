@@ -0,0 +1,89 @@
+package mklog
+
+import "regexp"
+
+// EntryHandler processes an entry accepted by a rule, formatting and writing it at the end of the chain.
+type EntryHandler func(entry Entry)
+
+// Middleware wraps the handler of the next step of the chain. It may modify the entry or add fields before
+// calling next, or drop the entry by not calling it. The fields map is shared by all rules of a log call,
+// so middleware adding or changing fields must pass a copy.
+type Middleware func(next EntryHandler) EntryHandler
+
+// Use appends the middleware to the chain run for every entry after the rules are filtered and before the
+// message is formatted, once per accepting rule. Middleware runs in the order of registration. The chain is
+// replaced atomically, so Use is safe while logging; a log call in progress completes with the previous chain.
+func (d *Debugger) Use(mw Middleware) *Debugger {
+	d.middlewareMu.Lock()
+	defer d.middlewareMu.Unlock()
+
+	var chain []Middleware
+	if current := d.middleware.Load(); current != nil {
+		chain = append(chain, *current...)
+	}
+	chain = append(chain, mw)
+	d.middleware.Store(&chain)
+	return d
+}
+
+// handleEntry passes the entry through the middleware chain to the final handler.
+func (d *Debugger) handleEntry(entry Entry, final EntryHandler) {
+	chain := d.middleware.Load()
+	if chain == nil {
+		final(entry)
+		return
+	}
+	h := final
+	for i := len(*chain) - 1; i >= 0; i-- {
+		h = (*chain)[i](h)
+	}
+	h(entry)
+}
+
+// cloneFields returns a copy of the fields with room for extra keys.
+func cloneFields(fields map[string]interface{}, extra int) map[string]interface{} {
+	c := make(map[string]interface{}, len(fields)+extra)
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
+
+// RedactMiddleware replaces every match of the pattern in the message and in string fields with the replacement,
+// e.g. to strip internal host names before entries leave the process.
+func RedactMiddleware(pattern *regexp.Regexp, replacement string) Middleware {
+	return func(next EntryHandler) EntryHandler {
+		return func(entry Entry) {
+			entry.Message = pattern.ReplaceAllString(entry.Message, replacement)
+			if len(entry.Fields) > 0 {
+				fields := cloneFields(entry.Fields, 0)
+				for k, v := range fields {
+					if s, ok := v.(string); ok {
+						fields[k] = pattern.ReplaceAllString(s, replacement)
+					}
+				}
+				entry.Fields = fields
+			}
+			next(entry)
+		}
+	}
+}
+
+// EnrichMiddleware adds the fields computed by fn to every entry. Fields already set on the entry take precedence.
+func EnrichMiddleware(fn func(entry Entry) map[string]interface{}) Middleware {
+	return func(next EntryHandler) EntryHandler {
+		return func(entry Entry) {
+			extra := fn(entry)
+			if len(extra) > 0 {
+				fields := cloneFields(entry.Fields, len(extra))
+				for k, v := range extra {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+				entry.Fields = fields
+			}
+			next(entry)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package mklog
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// entrySink records a copy of the entries it receives.
+type entrySink struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (s *entrySink) Write(entry Entry, formatted string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.Fields = cloneFields(entry.Fields, 0)
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *entrySink) Close() error { return nil }
+
+// messages returns the messages of the recorded entries.
+func (s *entrySink) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := make([]string, len(s.entries))
+	for i, entry := range s.entries {
+		messages[i] = entry.Message
+	}
+	return messages
+}
+
+// tagMiddleware appends the tag to the message and to the "chain" field.
+func tagMiddleware(tag string) Middleware {
+	return func(next EntryHandler) EntryHandler {
+		return func(entry Entry) {
+			entry.Message += " " + tag
+			fields := cloneFields(entry.Fields, 1)
+			chain, _ := fields["chain"].(string)
+			fields["chain"] = chain + tag
+			entry.Fields = fields
+			next(entry)
+		}
+	}
+}
+
+// vetoMiddleware drops the entries whose message contains the word.
+func vetoMiddleware(word string) Middleware {
+	return func(next EntryHandler) EntryHandler {
+		return func(entry Entry) {
+			if !strings.Contains(entry.Message, word) {
+				next(entry)
+			}
+		}
+	}
+}
+
+// TestMiddlewareOrder runs the middleware in the order of registration before the entry is formatted.
+func TestMiddlewareOrder(t *testing.T) {
+	sink := &entrySink{}
+	file := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.Use(tagMiddleware("a")).Use(tagMiddleware("b")).Use(tagMiddleware("c"))
+	d.NewLogRule("app", WithFileWriter(file), WithSink(sink))
+	defer d.Close(context.Background())
+
+	d.Info("request")
+	if len(sink.entries) != 1 {
+		t.Fatalf("sink received %d entries, want 1", len(sink.entries))
+	}
+	if got := sink.entries[0]; got.Message != "request a b c" || got.Fields["chain"] != "abc" {
+		t.Errorf("entry = %q with chain %v, want %q with chain abc", got.Message, got.Fields["chain"], "request a b c")
+	}
+	if !strings.Contains(file.String(), "request a b c") {
+		t.Errorf("log file = %q, want the message changed by the chain", file.String())
+	}
+}
+
+// TestMiddlewareDrop drops vetoed entries from every output, also when a later middleware would change them,
+// and keeps writing the other entries.
+func TestMiddlewareDrop(t *testing.T) {
+	sink := &entrySink{}
+	file := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.Use(vetoMiddleware("healthz")).Use(tagMiddleware("seen"))
+	d.NewLogRule("app", WithFileWriter(file), WithSink(sink))
+	defer d.Close(context.Background())
+
+	d.Info("GET /healthz")
+	d.Info("GET /orders")
+	if got := sink.messages(); len(got) != 1 || got[0] != "GET /orders seen" {
+		t.Errorf("sink messages = %q, want only the orders request", got)
+	}
+	if strings.Contains(file.String(), "healthz") {
+		t.Errorf("log file = %q, want the vetoed entry dropped", file.String())
+	}
+}
+
+// TestMiddlewareEnrich adds the fields of EnrichMiddleware without replacing the fields of the call.
+func TestMiddlewareEnrich(t *testing.T) {
+	sink := &entrySink{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.Use(EnrichMiddleware(func(Entry) map[string]interface{} {
+		return map[string]interface{}{"region": "eu", "user": "enriched"}
+	}))
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithSink(sink))
+	defer d.Close(context.Background())
+
+	d.With("user", "alice").Info("login")
+	if got := sink.entries[0].Fields; got["region"] != "eu" || got["user"] != "alice" {
+		t.Errorf("fields = %v, want region added and user kept", got)
+	}
+}
+
+// TestFlightRecorderThroughMiddleware passes recorded entries through the chain before they are recorded, so
+// replays are redacted like the entries written directly and vetoed entries are never replayed.
+func TestFlightRecorderThroughMiddleware(t *testing.T) {
+	sink := &entrySink{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.Use(RedactMiddleware(regexp.MustCompile(`secret-[0-9]+`), "***")).Use(vetoMiddleware("healthz"))
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithSink(sink), WithMinLevel(InfoLevel),
+		WithFlightRecorder(10, ErrorLevel))
+	defer d.Close(context.Background())
+
+	d.Debug("token secret-123 in debug")
+	d.Debug("GET /healthz")
+	d.Error("failed with secret-456")
+
+	want := []string{"token *** in debug", "failed with ***"}
+	got := sink.messages()
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sink messages = %q, want %q", got, want)
+	}
+}
@@ -1,10 +1,14 @@
 package mklog
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,6 +32,8 @@ func (l *LogLevel) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	}
 
 	switch strings.ToUpper(levelStr) {
+	case "TRACE":
+		*l = TraceLevel
 	case "INFO":
 		*l = InfoLevel
 	case "DEBUG":
@@ -44,6 +50,10 @@ func (l *LogLevel) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// The MKLOG_* variables below hold the initial package defaults.
+//
+// Deprecated: they are read once when the package is initialized, so writing them has no effect; use
+// SetDefaults or LogConfigManager.SetDefaults instead.
 var (
 	// Default file paths for logging
 	MKLOG_DirDefault      = "logs"     // Default directory for log files
@@ -59,7 +69,7 @@ var (
 	MKLOG_TimeLogFormatDefault    = "2006-01-02 15:04:05" // Default timestamp format for log entries
 
 	// Default log formatter configuration
-	MKLOG_FormatterDefault = PlainTextFormatter{"02.01.2006"}
+	MKLOG_FormatterDefault = PlainTextFormatter{dateFormat: "02.01.2006"}
 
 	// Default buffer size for asynchronous logging
 	MKLOG_BufferSizeDefault = 100 // Default size of the log buffer
@@ -67,15 +77,23 @@ var (
 
 // AsyncLog configures asynchronous logging settings.
 type AsyncLog struct {
-	Enable     bool `json:"Enable" yaml:"Enable"`           // Enable asynchronous logging
-	BufferSize int  `json:"buffer_size" yaml:"buffer_size"` // Size of the log buffer
+	Enable         bool          `json:"Enable" yaml:"Enable"`                     // Enable asynchronous logging
+	BufferSize     int           `json:"buffer_size" yaml:"buffer_size"`           // Size of the log buffer
+	StallTimeout   time.Duration `json:"stall_timeout" yaml:"stall_timeout"`       // Time the buffer may stay full before the watchdog reports a stall; zero disables the watchdog
+	DegradeOnStall bool          `json:"degrade_on_stall" yaml:"degrade_on_stall"` // Write to the console only while the consumer is stalled
+
+	DrainWhenDisabled bool          `json:"drain_when_disabled" yaml:"drain_when_disabled"` // Keep writing queued messages after the rule is disabled
+	FlushInterval     time.Duration `json:"flush_interval" yaml:"flush_interval"`           // Interval of flushing buffered file writers while messages are queued; zero flushes only on close
+	OverflowPolicy    string        `json:"overflow_policy" yaml:"overflow_policy"`         // Handling of messages logged while the buffer is full, OverflowBlock when empty
+	SendTimeout       time.Duration `json:"send_timeout" yaml:"send_timeout"`               // Longest wait for room in the buffer with OverflowBlock before the message is dropped; zero waits indefinitely
+	BatchSize         int           `json:"batch_size" yaml:"batch_size"`                   // Number of queued messages written to the log file at once
 }
 
 // LogRule defines the rules for logging levels and outputs.
 type LogRule struct {
 	MinLevel            LogLevel            `json:"min_level" yaml:"min_level"`                           // Minimum log level
 	MaxLevel            LogLevel            `json:"max_level" yaml:"max_level"`                           // Maximum log level
-	CurrentLevel        LogLevel            `json:"current_level" yaml:"current_level"`                   // Current log level
+	CurrentLevel        LogLevel            `json:"current_level" yaml:"current_level"`                   // Current log level set by WithCurrentLevel; log calls do not update it
 	FileName            string              `json:"file_name" yaml:"file_name"`                           // Name of the log file
 	FileType            string              `json:"file_type" yaml:"file_type"`                           // Type of the log file
 	IsDateFile          bool                `json:"is_date_file" yaml:"is_date_file"`                     // Flag for date-based file naming
@@ -84,147 +102,469 @@ type LogRule struct {
 	ModuleName          string              `json:"module_name" yaml:"module_name"`                       // Name of the module being logged
 	Submodules          []string            `json:"submodules" yaml:"submodules"`                         // List of submodules for logging
 	IsConsoleOutput     bool                `json:"is_console_output" yaml:"is_console_output"`           // Flag for console output of logs
-	DebugMode           bool                `json:"debug_mode" yaml:"debug_mode"`                         // Flag for enabling debug mode
-	DebugModeStatus     LogLevel            `json:"debug_mode_status" yaml:"debug_mode_status"`           // Current status of debug mode
+	ConsoleOnce         bool                `json:"console_once" yaml:"console_once"`                     // Flag skipping the console output of entries another rule printed already, see WithConsoleOnce; true for rules built by NewLogRule
+	ConsoleDecorator    *ConsoleDecorator   `json:"-" yaml:"-"`                                           // Per-level decoration of the console output
+	RawOutput           bool                `json:"raw_output" yaml:"raw_output"`                         // Flag disabling the escaping of control characters in the console and file output
+	Verbosity           LogLevel            `json:"verbosity" yaml:"verbosity"`                           // Lowest level logged in addition to MinLevel, see WithVerbosity
+	DebugMode           bool                `json:"debug_mode" yaml:"debug_mode"`                         // Deprecated: use Verbosity. Flag for enabling debug mode
+	DebugModeStatus     LogLevel            `json:"debug_mode_status" yaml:"debug_mode_status"`           // Deprecated: use Verbosity. Current status of debug mode
 	DateFormat          string              `json:"date_format" yaml:"date_format"`                       // Date format for log entries
+	TimestampLocation   *time.Location      `json:"-" yaml:"-"`                                           // Location the timestamps of entries are rendered in; nil keeps the local time
 	DetailedErrorOutput bool                `json:"detailed_error_output" yaml:"detailed_error_output"`   // Flag for detailed error output
+	StackAtLevel        LogLevel            `json:"stack_at_level" yaml:"stack_at_level"`                 // Minimum level of entries rendering the stack of a DetailedError
 	CustomLogLevelNames map[LogLevel]string `json:"custom_log_level_names" yaml:"custom_log_level_names"` // Custom names for log levels
+	FatalExitCode       int                 `json:"fatal_exit_code" yaml:"fatal_exit_code"`               // Exit code of the process after a Fatal entry accepted by the rule; zero keeps it running
+	SlowWriteThreshold  time.Duration       `json:"slow_write_threshold" yaml:"slow_write_threshold"`     // Duration of a single write reported as slow; zero disables the warning
+	MaxFieldBytes       int                 `json:"max_field_bytes" yaml:"max_field_bytes"`               // Maximum length of string and []byte field values; zero keeps them whole
+	MessagePrefix       string              `json:"message_prefix" yaml:"message_prefix"`                 // Text prepended to the message of every entry, see WithMessagePrefix
+	MessageSuffix       string              `json:"message_suffix" yaml:"message_suffix"`                 // Text appended to the message of every entry, see WithMessageSuffix
 
 	FileLog    FileLog    `json:"file_log" yaml:"file_log"`       // Configuration for file logging
 	FileFolder FileFolder `json:"file_folder" yaml:"file_folder"` // Configuration for folder logging
 	AsyncLog   AsyncLog   `json:"async_log" yaml:"async_log"`     // Configuration for asynchronous logging
+	Hooks      []Hook     `json:"-" yaml:"-"`                     // Hooks notified about accepted entries, set before the rule is added; use AddHook and RemoveHook afterwards
+	Sinks      []Sink     `json:"-" yaml:"-"`                     // Additional outputs receiving accepted entries
+
+	debugger          *Debugger        `json:"-" yaml:"-"` // Debugger owning the rule
+	logFinishChannel  chan struct{}    `json:"-" yaml:"-"` // Channel to signal completion of logging
+	signalChannel     chan os.Signal   `json:"-" yaml:"-"` // Channel for OS signal handling
+	logChannel        chan QueuedEntry `json:"-" yaml:"-"` // Channel for log message transmission
+	asyncDone         chan struct{}    `json:"-" yaml:"-"` // Channel closed when the async consumer has drained the log channel
+	ctx               context.Context  `json:"-" yaml:"-"` // Context shutting the rule down when cancelled
+	lifecycle         *ruleLifecycle   `json:"-" yaml:"-"` // Coordination of shutdown with logging in progress
+	hookList          *hookList        `json:"-" yaml:"-"` // Hooks of the active rule, see updateHooks
+	verbositySet      bool             `json:"-" yaml:"-"` // Whether the Verbosity was set explicitly, so that AddRule keeps a TraceLevel Verbosity
+	watchdog          *asyncWatchdog   `json:"-" yaml:"-"` // Progress tracking of the async consumer
+	metrics           *writeMetrics    `json:"-" yaml:"-"` // Write latency of the outputs
+	recorder          *flightRecorder  `json:"-" yaml:"-"` // Buffer of the entries below the minimum level
+	burst             *errorBurst      `json:"-" yaml:"-"` // Temporary lowering of the minimum level after errors
+	failed            *failedQueue     `json:"-" yaml:"-"` // Failed log file writes waiting for replay
+	quiet             bool             `json:"-" yaml:"-"` // Suppress informational notices while the rule is built
+	formatterPanics   int32            `json:"-" yaml:"-"` // Consecutive panics of the formatter, accessed atomically
+	target            atomic.Value     `json:"-" yaml:"-"` // *fileTarget of the configured log file, see RuleRef
+	detached          atomic.Value     `json:"-" yaml:"-"` // *ruleLifecycle of a rule not added to a Debugger, see startGuards
+	formatterDisabled int32            `json:"-" yaml:"-"` // Non-zero once the formatter panicked too often, accessed atomically
+	disabled          int32            `json:"-" yaml:"-"` // Non-zero while the rule is disabled at runtime
+	taskKey           string           `json:"-" yaml:"-"` // Key naming the maintenance tasks of the rule
+	config            []byte           `json:"-" yaml:"-"` // Configuration of the rule hashed into the fingerprint, see recordConfig
+}
+
+// ErrorHandler receives internal errors raised while logging, such as failed writes or hook failures.
+type ErrorHandler func(err error)
 
-	logFinishChannel chan struct{}  `json:"-" yaml:"-"` // Channel to signal completion of logging
-	signalChannel    chan os.Signal `json:"-" yaml:"-"` // Channel for OS signal handling
-	logChannel       chan string    `json:"-" yaml:"-"` // Channel for log message transmission
+// Report passes the error to the handler, or prints it to stdout like a Debugger without an error handler when
+// the handler is nil. Hooks and sinks report their background failures through it.
+func (h ErrorHandler) Report(err error) {
+	if h != nil {
+		h(err)
+		return
+	}
+	fmt.Println(err)
 }
 
 // Debugger is a logging utility that provides various configuration options for logging.
 type Debugger struct {
-	LogRules map[string][]*LogRule `yaml:"log_rules"` // Map of logging rules categorized by module names
+	// Map of logging rules categorized by module names. It is a copy of the rules made on every change of the
+	// rules; log calls read their own snapshot, so modifying it has no effect. Add and remove rules with
+	// NewLogRule, AddRule and RemoveRule, and read them with Rules.
+	LogRules map[string][]*LogRule `yaml:"log_rules"`
+
+	errorHandler     atomic.Pointer[ErrorHandler]           // Handler for internal errors, printing to stdout when nil
+	onFatal          func(Entry)                            // Callback invoked for Fatal entries before the process exits
+	clock            func() time.Time                       // Source of the current time, time.Now when nil
+	maintenance      *maintenanceScheduler                  // Scheduler of periodic background tasks
+	maintenanceOnce  sync.Once                              // Guards creation of the scheduler
+	closed           chan struct{}                          // Channel closed by Close
+	closedOnce       sync.Once                              // Guards creation of the closed channel
+	closeOnce        sync.Once                              // Guards closing of the closed channel
+	signals          *signalHandler                         // Signals the Debugger subscribed to, see processSignals
+	signalOnce       sync.Once                              // Guards creation of the signal handler
+	once             *onceRegistry                          // Keys of the once-only logging helpers
+	onceMu           sync.Mutex                             // Guards the once registry
+	noEnvOverride    bool                                   // Ignore the MKLOG_LEVEL environment variables
+	quiet            bool                                   // Suppress informational notices, see SetQuiet
+	middleware       atomic.Pointer[[]Middleware]           // Middleware chain run before formatting
+	middlewareMu     sync.Mutex                             // Serializes changes of the middleware chain
+	recent           atomic.Pointer[recentBuffer]           // Latest accepted entries, see SetRecentBuffer
+	staticFields     atomic.Pointer[map[string]interface{}] // Fields added to every entry, see SetStaticFields
+	userStatic       map[string]interface{}                 // Fields set with SetStaticFields, without the fingerprint field
+	fingerprintField string                                 // Key of the fingerprint among the static fields, see SetConfigFingerprintField
+	staticMu         sync.Mutex                             // Guards the static fields set by the user and the fingerprint field
+	fieldPolicy      int32                                  // FieldCollisionPolicy of the field sources, accessed atomically
+	reportCollisions int32                                  // Non-zero to warn about field collisions, accessed atomically
+	fallback         *fallbackRules                         // Rules instantiated for modules without rules, see SetFallbackRule
+	fallbackOnce     sync.Once                              // Guards creation of the fallback rules
+	coalesce         *errorCoalescer                        // Coalescing of identical internal errors, see SetErrorCoalescing
+	coalesceOnce     sync.Once                              // Guards creation of the error coalescer
+	verbosityShift   int32                                  // Steps the levels of the rules are shifted by, see BumpVerbosity; accessed atomically
+	modules          moduleRegistry                         // Module names rules and handles are restricted to, see RegisterModules
+	ruleSet          atomic.Pointer[ruleSnapshot]           // Snapshot of the rules read by log calls, see updateRules
+	rulesMu          sync.Mutex                             // Serializes changes of the rules
+	ruleKeys         map[string]int                         // Rules added per module, naming their maintenance tasks; guarded by rulesMu
+	closeHooks       []closeHook                            // Hooks run by Close, see OnClose
+	closeHooksMu     sync.Mutex                             // Guards the close hooks
+	hooks            atomic.Pointer[[]Hook]                 // Hooks attached to every rule, see AddHook
+	hooksMu          sync.Mutex                             // Serializes changes of the attached hooks
 }
 
 // NewDebugLogger initializes a new Debugger instance with default logging rules for a module.
 func NewDebugLogger(moduleName string, submodules ...string) *Debugger {
+	defaults := GetDefaults()
 	initRule := &LogRule{
-		MinLevel:            DebugLevel,                                 // Set minimum log level to Debug
-		MaxLevel:            FatalLevel,                                 // Set maximum log level to Fatal
-		CurrentLevel:        InfoLevel,                                  // Set current log level to Info
-		LogFormatter:        PlainTextFormatter{"02.01.2006"},           // Set log formatting
-		ModuleName:          moduleName,                                 // Set the module name
-		IsConsoleOutput:     true,                                       // Enable console output
-		DebugMode:           true,                                       // Enable debug mode
-		DebugModeStatus:     TraceLevel,                                 // Set debug mode status
-		DateFormat:          "02.01.2006",                               // Set date format for logs
-		DetailedErrorOutput: false,                                      // Disable detailed error output by default
-		logFinishChannel:    make(chan struct{}),                        // Channel for signaling log completion
-		signalChannel:       make(chan os.Signal, 1),                    // Channel for handling OS signals
-		logChannel:          make(chan string, MKLOG_BufferSizeDefault), // Channel for log message transmission
+		MinLevel:            DebugLevel,                                  // Set minimum log level to Debug
+		MaxLevel:            FatalLevel,                                  // Set maximum log level to Fatal
+		CurrentLevel:        InfoLevel,                                   // Set current log level to Info
+		LogFormatter:        defaults.Formatter,                          // Set log formatting
+		ModuleName:          moduleName,                                  // Set the module name
+		IsConsoleOutput:     true,                                        // Enable console output
+		ConsoleOnce:         true,                                        // Print entries once when several rules match
+		DebugMode:           true,                                        // Enable debug mode
+		DebugModeStatus:     TraceLevel,                                  // Set debug mode status
+		Verbosity:           TraceLevel,                                  // Log the Trace and Debug entries
+		DateFormat:          "02.01.2006",                                // Set date format for logs
+		DetailedErrorOutput: false,                                       // Disable detailed error output by default
+		StackAtLevel:        ErrorLevel,                                  // Render error stacks from the Error level
+		logFinishChannel:    make(chan struct{}),                         // Channel for signaling log completion
+		signalChannel:       make(chan os.Signal, 1),                     // Channel for handling OS signals
+		logChannel:          make(chan QueuedEntry, defaults.BufferSize), // Channel for log message transmission
+		lifecycle:           newRuleLifecycle(),                          // Shutdown coordination of the rule
+		metrics:             newWriteMetrics(),                           // Write latency of the outputs
+		hookList:            &hookList{},                                 // Hooks attached while logging
 		FileLog: FileLog{
 			Enable:     false, // Disable file logging by default
 			IsDateFile: false, // Disable date-based file naming by default
 		},
 	}
 
-	p := &Debugger{
-		LogRules: make(map[string][]*LogRule), // Initialize log rules map
-	}
+	p := &Debugger{}
 
-	p.LogRules[moduleName] = append(p.LogRules[moduleName], initRule) // Add initial logging rule for the module
+	initRule.debugger = p
+	initRule.recordConfig()
+	p.updateRules(func(rules map[string][]*LogRule) {
+		rules[moduleName] = append(rules[moduleName], initRule) // Add initial logging rule for the module
+	})
 
-	signal.Notify(p.LogRules[moduleName][0].signalChannel, os.Interrupt) // Setup OS interrupt notification
-	return p                                                             // Return the initialized Debugger instance
+	p.signalHandler() // Setup OS interrupt notification
+	return p          // Return the initialized Debugger instance
 }
 
-// AddRule adds a new logging rule to the Debugger instance for a specified module.
-// If the module does not exist, it initializes a new slice for log rules.
-func (d *Debugger) AddRule(moduleName string, rule LogRule) *Debugger {
-	if _, exists := d.LogRules[moduleName]; !exists {
-		d.LogRules[moduleName] = []*LogRule{}
+// AddRule adds a new logging rule to the Debugger instance for a specified module, applying the options to it.
+// If the module does not exist, it initializes a new slice for log rules. A rule with the zero Verbosity
+// takes it from the deprecated DebugMode and DebugModeStatus fields, see WithVerbosity, unless the Verbosity was
+// set with SetVerbosity or the WithVerbosity option:
+//
+//	d.AddRule("app", rule, mklog.WithVerbosity(mklog.TraceLevel))
+//
+// Rules of modules the strict registry does not know are reported to the error handler instead, see
+// RegisterModules.
+func (d *Debugger) AddRule(moduleName string, rule LogRule, opts ...Option) *Debugger {
+	if err := d.checkModule(moduleName); err != nil {
+		d.notice(nil, moduleName, ErrorLevel, err, "%v", err)
+		return d
+	}
+	for _, opt := range opts {
+		opt(&rule)
+	}
+	if rule.Verbosity == TraceLevel && !rule.verbositySet {
+		rule.syncVerbosity()
+	}
+	rule.debugger = d
+	if rule.lifecycle == nil {
+		rule.lifecycle = newRuleLifecycle()
+	}
+	if rule.metrics == nil {
+		rule.metrics = newWriteMetrics()
+	}
+	if rule.hookList == nil {
+		rule.hookList = &hookList{}
+	}
+	rule.recordConfig()
+	d.updateRules(func(rules map[string][]*LogRule) {
+		rules[moduleName] = append(rules[moduleName], &rule)
+	})
+	if rule.AsyncLog.Enable {
+		rule.StartAsyncLogging()
 	}
-	d.LogRules[moduleName] = append(d.LogRules[moduleName], &rule)
 	return d
 }
 
 // NewLogRule creates a new logging rule with default configuration for a given module name.
-// It accepts optional configuration functions to customize the log rule.
+// It accepts optional configuration functions to customize the log rule. The rule prints an entry another rule
+// printed already only with WithConsoleOnce(false), see WithConsoleOnce. A log file that cannot be created
+// is reported to the error handler as a Notice; use NewLogRuleE to get the error instead.
 func (d *Debugger) NewLogRule(moduleName string, opts ...Option) *Debugger {
+	lr, err := d.addLogRule(moduleName, opts...)
+	if err != nil {
+		d.notice(lr, moduleName, ErrorLevel, err, "%v", err)
+	}
+	return d
+}
+
+// NewLogRuleE creates a new logging rule like NewLogRule, returning the error of creating the log file.
+// The rule is added even when the file cannot be created, as NewLogRule does, but not for a module the strict
+// registry does not know, see RegisterModules.
+func (d *Debugger) NewLogRuleE(moduleName string, opts ...Option) (*Debugger, error) {
+	_, err := d.addLogRule(moduleName, opts...)
+	return d, err
+}
+
+// addLogRule builds the rule, adds it to the Debugger and starts its background work. Rules of modules the
+// strict registry does not know are not added, see RegisterModules.
+func (d *Debugger) addLogRule(moduleName string, opts ...Option) (*LogRule, error) {
+	if err := d.checkModule(moduleName); err != nil {
+		return nil, err
+	}
+	lr := d.buildLogRule(moduleName, opts...)
+	lr.recordConfig()
+
+	// Add the new log rule to the array of rules for the module.
+	var key string
+	d.updateRules(func(rules map[string][]*LogRule) {
+		key = d.ruleKey(moduleName)
+		rules[moduleName] = append(rules[moduleName], lr)
+	})
+	return lr, d.startLogRule(lr, key)
+}
+
+// buildLogRule creates a rule of the module with the default settings and applies the options.
+func (d *Debugger) buildLogRule(moduleName string, opts ...Option) *LogRule {
 	// Create a base configuration with default values.
+	defaults := GetDefaults()
 	lr := &LogRule{
 		MinLevel:        InfoLevel,             // Minimum log level for this rule.
 		MaxLevel:        ErrorLevel,            // Maximum log level for this rule.
 		CurrentLevel:    InfoLevel,             // Current log level for logging.
 		ModuleName:      moduleName,            // Name of the module associated with this rule.
 		IsConsoleOutput: false,                 // Disable console output by default.
+		ConsoleOnce:     true,                  // Print entries once when several rules match.
 		DateFormat:      "02-01-2006 15:04:05", // Default date format for logs.
+		StackAtLevel:    ErrorLevel,            // Render error stacks from the Error level.
+		Verbosity:       InfoLevel,             // Leave out Trace and Debug entries by default.
 		FileLog: FileLog{
-			Enable:     false,      // Disable file logging by default.
-			FilePath:   "logs",     // Default directory for log files.
-			FileName:   "log_file", // Default log file name.
-			FileType:   ".log",     // Default file extension for log files.
-			IsDateFile: false,      // Disable date in file name by default.
+			Enable:     false,             // Disable file logging by default.
+			FilePath:   defaults.Dir,      // Default directory for log files.
+			FileName:   defaults.FileName, // Default log file name.
+			FileType:   defaults.FileType, // Default file extension for log files.
+			IsDateFile: false,             // Disable date in file name by default.
 		},
 		signalChannel:    make(chan os.Signal, 1), // Channel to handle OS signals.
 		logFinishChannel: make(chan struct{}),     // Channel to signal the end of logging.
+		debugger:         d,                       // Debugger owning the rule.
+		lifecycle:        newRuleLifecycle(),      // Shutdown coordination of the rule.
+		metrics:          newWriteMetrics(),       // Write latency of the outputs.
 	}
 
 	// Apply any provided options to customize the log rule.
 	for _, opt := range opts {
 		opt(lr)
 	}
+	lr.hookList = &hookList{} // Hooks attached by the options are kept in Hooks.
+	d.applyEnvLevel(lr)
 
 	// Set default log formatter if not specified.
 	if lr.LogFormatter == nil {
-		lr.LogFormatter = &PlainTextFormatter{"02.01.2006"}
-		fmt.Println("[Warning] LogFormatter not set, using PlainTextFormatter.")
+		lr.LogFormatter = defaults.Formatter
+		d.notice(lr, moduleName, InfoLevel, nil, "[Warning] LogFormatter not set, using %T.", defaults.Formatter)
+	}
+	if lr.AsyncLog.Enable && lr.AsyncLog.BufferSize <= 0 {
+		lr.AsyncLog.BufferSize = defaults.BufferSize
+		d.notice(lr, moduleName, InfoLevel, nil, "[mklog] Buffersize set to default value")
 	}
+	return lr
+}
 
-	// Add the new log rule to the array of rules for the module.
-	d.LogRules[moduleName] = append(d.LogRules[moduleName], lr)
-	signal.Notify(lr.signalChannel, os.Interrupt) // Notify on OS interrupts.
+// startLogRule creates the log file of the rule and starts its background work, naming its maintenance tasks
+// after the key. It returns the error of creating the log file.
+func (d *Debugger) startLogRule(lr *LogRule, key string) error {
+	d.signalHandler() // Notify on OS interrupts.
+	lr.taskKey = key
+
+	// The rule is published already, so writes of other goroutines wait for the file to be set up.
+	lr.lifecycle.fileMu.Lock()
+	// Use the clock of the Debugger for file management unless the rule has its own.
+	if lr.FileLog.now == nil {
+		lr.FileLog.now = d.clock
+	}
 
 	// Create the log file if file logging is enabled.
+	var fileErr error
 	if lr.FileLog.Enable {
 		if err := lr.createLogFile(); err != nil {
-			fmt.Println("[mklog] error while creating log file ", lr.ModuleName, ": ", err)
+			fileErr = fmt.Errorf("[mklog] error while creating log file %s: %w", lr.ModuleName, err)
 		}
 	}
+	lr.lifecycle.fileMu.Unlock()
+	if lr.FileLog.Enable {
+		lr.scheduleRetention("retention/" + key)
+		lr.scheduleArchiving("archiving/" + key)
+		lr.scheduleFailedQueue("failed/" + key)
+	}
 
 	// Start asynchronous logging if enabled.
 	if lr.AsyncLog.Enable {
-		lr.logChannel = make(chan string, lr.AsyncLog.BufferSize)
-		go lr.StartAsyncLogging()
+		lr.scheduleWatchdog("watchdog/" + key)
+		lr.StartAsyncLogging()
 	}
 
+	// Shut the rule down with its context.
+	if lr.ctx != nil {
+		go lr.watchContext(lr.ctx)
+	}
+
+	return fileErr
+}
+
+// SetErrorHandler sets the handler receiving internal errors of the Debugger instance.
+// Passing nil restores the default behavior of printing errors to stdout.
+// Identical consecutive errors are coalesced, see SetErrorCoalescing. The handler may log with the Debugger:
+// errors raised while a rule writes an entry are passed to it after the rule has released its locks.
+func (d *Debugger) SetErrorHandler(handler ErrorHandler) *Debugger {
+	if handler == nil {
+		d.errorHandler.Store(nil)
+	} else {
+		d.errorHandler.Store(&handler)
+	}
 	return d
 }
 
+// SetClock sets the source of the current time used for file management and maintenance, e.g. a fake clock in tests.
+// Rules created afterwards use the clock unless they set their own with WithClock.
+func (d *Debugger) SetClock(now func() time.Time) *Debugger {
+	d.clock = now
+	return d
+}
+
+// now returns the current time of the Debugger clock.
+func (d *Debugger) now() time.Time {
+	if d.clock != nil {
+		return d.clock()
+	}
+	return time.Now()
+}
+
+// handleError passes the error to the configured error handler or prints it to stdout, coalescing identical
+// consecutive errors, see SetErrorCoalescing.
+func (d *Debugger) handleError(err error) {
+	d.coalesceError(nil, err)
+}
+
+// deliverError passes the error to the configured error handler or prints it to stdout.
+func (d *Debugger) deliverError(err error) {
+	var handler ErrorHandler
+	if h := d.errorHandler.Load(); h != nil {
+		handler = *h
+	}
+	handler.Report(err)
+}
+
+// reportError passes the error to the error handler of the Debugger owning the rule.
+func (lr *LogRule) reportError(err error) {
+	if lr.debugger == nil {
+		ErrorHandler(nil).Report(err)
+		return
+	}
+	lr.debugger.coalesceError(lr, err)
+}
+
+// deferError keeps an internal error raised while the rule is acquired by a dispatch or holds its output or
+// file lock until reportDeferred passes it to the error handler, so that a handler logging to the rule does not
+// wait for itself or for a concurrent Close. Rules without a lifecycle take no locks and report the error at once.
+func (lr *LogRule) deferError(err error) {
+	l := lr.lifecycle
+	if l == nil {
+		lr.reportError(err)
+		return
+	}
+	l.errMu.Lock()
+	l.deferred = append(l.deferred, err)
+	l.errMu.Unlock()
+}
+
+// reportDeferred passes the errors kept by deferError to the error handler, in the order they were raised.
+// It must be called without holding the output or file lock of the rule.
+func (lr *LogRule) reportDeferred() {
+	l := lr.lifecycle
+	if l == nil {
+		return
+	}
+	l.errMu.Lock()
+	errs := l.deferred
+	l.deferred = nil
+	l.errMu.Unlock()
+	for _, err := range errs {
+		lr.reportError(err)
+	}
+}
+
 // CloseAsyncLogging closes all log channels for asynchronous logging in the Debugger instance.
 func (d *Debugger) CloseAsyncLogging() {
-	for _, rules := range d.LogRules {
+	for _, rules := range d.rules() {
 		for _, v := range rules {
 			if v.AsyncLog.Enable {
-				close(v.logChannel) // Close the log channel to stop logging.
+				v.closeAsync() // Close the log channel to stop logging.
+			}
+		}
+	}
+}
+
+// CloseSinks closes the sinks of all log rules in the Debugger instance and returns the first error encountered.
+func (d *Debugger) CloseSinks() error {
+	var firstErr error
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			if err := v.CloseSinks(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// RotateAll forces a rotation of the log files of all rules with file logging enabled.
+// Rotation continues past failing rules and the errors are returned joined.
+func (d *Debugger) RotateAll() error {
+	var errs []error
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			if v.fileWriter() == nil {
+				continue
+			}
+			if err := v.RotateNow(); err != nil {
+				errs = append(errs, fmt.Errorf("[mklog] rotation failed for %s: %w", v.ModuleName, err))
 			}
 		}
 	}
+	return errors.Join(errs...)
 }
 
-// SetDebugMode enables or disables debug mode for the log rule.
+// SetDebugMode enables or disables debug mode for the log rule, setting the Verbosity accordingly.
+//
+// Deprecated: use SetVerbosity.
 func (d *LogRule) SetDebugMode(mode bool) *LogRule {
 	d.DebugMode = mode
+	d.syncVerbosity()
 	return d
 }
 
-// SetDebugLevel sets the debug level for the log rule.
+// SetDebugLevel sets the debug level for the log rule, setting the Verbosity accordingly.
+//
+// Deprecated: use SetVerbosity.
 func (d *LogRule) SetDebugLevel(level LogLevel) *LogRule {
 	d.DebugModeStatus = level
+	d.syncVerbosity()
 	return d
 }
 
-// SetDateFormat sets the date format for log messages in the log rule.
+// SetDateFormat sets the date format for log messages in the log rule, a Go reference layout or a preset name
+// accepted by ResolveDateFormat.
 func (d *LogRule) SetDateFormat(format string) *LogRule {
-	d.DateFormat = format
+	d.DateFormat = resolveDateFormat(format)
+	return d
+}
+
+// SetTimestampLocation sets the location the timestamps of log messages are rendered in, nil for the local time.
+func (d *LogRule) SetTimestampLocation(loc *time.Location) *LogRule {
+	d.TimestampLocation = loc
 	return d
 }
 
@@ -246,31 +586,119 @@ func (d *LogRule) SetConsoleOutput(mode bool) *LogRule {
 	return d
 }
 
+// SetConsoleOnce skips the console output of entries another rule printed already, see WithConsoleOnce.
+func (d *LogRule) SetConsoleOnce(once bool) *LogRule {
+	d.ConsoleOnce = once
+	return d
+}
+
 // SetDetailedErrorOutput enables or disables detailed error output.
 func (d *LogRule) SetDetailedErrorOutput(enabled bool) *LogRule {
 	d.DetailedErrorOutput = enabled
 	return d
 }
 
-// StartAsyncLogging starts a goroutine to handle asynchronous logging.
-// It listens for log messages and writes them to the log file and/or console.
+// StartAsyncLogging starts the goroutine writing the queued messages to the log file and/or console.
+// The consumer is started at most once per rule, so further calls have no effect; NewLogRule and AddRule
+// start it for rules with async logging enabled.
 func (lr *LogRule) StartAsyncLogging() {
 	if !lr.AsyncLog.Enable {
-		fmt.Println("[mklog] async logging is disabled")
+		if lr.debugger != nil {
+			lr.debugger.notice(lr, lr.ModuleName, InfoLevel, nil, "[mklog] async logging is disabled")
+		} else {
+			fmt.Println("[mklog] async logging is disabled")
+		}
+		return
+	}
+	if lr.lifecycle == nil {
+		lr.startGuards().startOnce.Do(func() { go lr.runConsumer() })
 		return
 	}
-	go func() {
-		for logMessage := range lr.logChannel {
-			if lr.FileLog.Enable {
-				if err := lr.writeLog(logMessage); err != nil {
-					fmt.Println("[mklog] error while writing to log file ", lr.ModuleName, " : ", err)
-				}
+	lr.lifecycle.startOnce.Do(func() {
+		lr.lifecycle.asyncMu.Lock()
+		lr.lifecycle.started = true
+		if lr.logChannel == nil {
+			lr.logChannel = make(chan QueuedEntry, lr.AsyncLog.BufferSize)
+		}
+		if lr.asyncDone == nil {
+			lr.asyncDone = make(chan struct{})
+		}
+		lr.lifecycle.asyncMu.Unlock()
+		go lr.runConsumer()
+	})
+}
+
+// runConsumer writes the queued messages in batches of up to AsyncLog.BatchSize until the queue is closed.
+// A partial batch is written at the flush interval, or as soon as the queue is idle without one.
+func (lr *LogRule) runConsumer() {
+	defer lr.asyncFinished()
+
+	var tick <-chan time.Time
+	if lr.AsyncLog.FlushInterval > 0 {
+		ticker := time.NewTicker(lr.AsyncLog.FlushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	size := lr.AsyncLog.BatchSize
+	if size < 1 {
+		size = 1
+	}
+
+	batch := make([]QueuedEntry, 0, size)
+	pending := false // Messages were written since the last flush
+	for {
+		select {
+		case queued, ok := <-lr.logChannel:
+			if !ok {
+				lr.consumeBatch(batch)
+				return
+			}
+			batch = append(batch, queued)
+			if len(batch) >= size || (tick == nil && len(lr.logChannel) == 0) {
+				lr.consumeBatch(batch)
+				batch = batch[:0]
+				pending = true
+			}
+		case <-tick:
+			if len(batch) > 0 {
+				lr.consumeBatch(batch)
+				batch = batch[:0]
+				pending = true
 			}
-			if lr.IsConsoleOutput {
-				fmt.Println(logMessage) // Output log message to the console.
+			if pending {
+				lr.flushFile()
+				pending = false
 			}
 		}
-	}()
+	}
+}
+
+// consume writes a message taken from the async queue to the log file and the console.
+func (lr *LogRule) consume(queued QueuedEntry) {
+	defer lr.reportDeferred()
+	// Queued messages of a disabled rule are discarded unless it drains them.
+	if !lr.Enabled() && !lr.AsyncLog.DrainWhenDisabled {
+		lr.markWritten()
+		return
+	}
+	if err := lr.print(queued.Entry, queued.Text); err != nil {
+		lr.reportOutputErrors(queued.Entry, []error{fmt.Errorf("file: %w", err)})
+		return
+	}
+	lr.markWritten()
+}
+
+// flushFile flushes the buffered data of the log file writer, if it buffers any.
+func (lr *LogRule) flushFile() {
+	var err error
+	if writer := lr.FileLog.writer; writer != nil {
+		err = writer.Flush()
+	} else if f, ok := lr.FileLog.target.(interface{ Flush() error }); ok {
+		err = f.Flush()
+	}
+	if err != nil {
+		lr.reportError(fmt.Errorf("[mklog] error while flushing log file %s: %w", lr.ModuleName, err))
+	}
 }
 
 //#region File
@@ -278,6 +706,10 @@ func (lr *LogRule) StartAsyncLogging() {
 // CreateLogFile initializes the log file for the current LogRule.
 // It calls the private createLogFile method and returns any error encountered.
 func (d *LogRule) CreateLogFile() error {
+	if l := d.lifecycle; l != nil {
+		l.fileMu.Lock()
+		defer l.fileMu.Unlock()
+	}
 	err := d.createLogFile()
 	return err
 }
@@ -338,6 +770,13 @@ func (d *LogRule) SetFileFolderPeriod(period time.Duration) *LogRule {
 	return d
 }
 
+// SetFileWriter sets a writer, e.g. os.Stdout, used for file logging instead of log files.
+// It returns the updated LogRule instance to allow method chaining.
+func (d *LogRule) SetFileWriter(w io.Writer) *LogRule {
+	d.FileLog.Writer = w
+	return d
+}
+
 // SetLimitedFileSize enables or disables the limitation on the log file size.
 // It returns the updated LogRule instance to allow method chaining.
 func (d *LogRule) SetLimitedFileSize(isLimited bool) *LogRule {
@@ -355,7 +794,7 @@ func (d *LogRule) SetMaxFileSize(size int64) *LogRule {
 // InitFiles initializes all log files defined in the Debugger's log rules.
 // It iterates over each rule and calls the createLogFile method for each.
 func (d *Debugger) InitFiles() *Debugger {
-	for _, rule := range d.LogRules {
+	for _, rule := range d.rules() {
 		for _, v := range rule {
 			v.createLogFile()
 		}
@@ -378,15 +817,125 @@ func (d *LogRule) GetLogFinishChannel() chan struct{} {
 	return d.logFinishChannel
 }
 
-// SetLogChannel set the channel that using for log messages.
-func (d *LogRule) SetLogChannel(channel chan string) *LogRule {
+// SetEntryChannel sets the channel of the async queue, carrying each entry with its formatted message.
+// The channel belongs to the consumer once it is started, so it can only be set before StartAsyncLogging.
+func (d *LogRule) SetEntryChannel(channel chan QueuedEntry) error {
+	if d.lifecycle != nil {
+		d.lifecycle.asyncMu.Lock()
+		defer d.lifecycle.asyncMu.Unlock()
+		if d.lifecycle.started {
+			return fmt.Errorf("[mklog] async consumer of %s is already running", d.ModuleName)
+		}
+	}
 	d.logChannel = channel
+	return nil
+}
+
+// GetEntryChannel returns a receive-only view of the async queue, e.g. to inspect its length and capacity.
+// Messages received from it are not written by the consumer.
+func (d *LogRule) GetEntryChannel() <-chan QueuedEntry {
+	return d.logChannel
+}
+
+// SetLogChannel sets the channel used for log messages. The async queue takes over the capacity of
+// the channel; messages sent on the channel are forwarded to the queue, stamped with the time they
+// are received and without a known level. Like SetEntryChannel, it can only be used before StartAsyncLogging;
+// afterwards the error is reported to the error handler, see SetLogChannelE.
+//
+// Deprecated: plain messages lose the level of the entry. Use SetEntryChannel.
+func (d *LogRule) SetLogChannel(channel chan string) *LogRule {
+	if err := d.SetLogChannelE(channel); err != nil {
+		d.reportError(err)
+	}
 	return d
 }
 
-// GetLogChannel returns the channel used for log messages.
+// SetLogChannelE sets the channel used for log messages like SetLogChannel, returning the error when the async
+// consumer is already running.
+//
+// Deprecated: plain messages lose the level of the entry. Use SetEntryChannel.
+func (d *LogRule) SetLogChannelE(channel chan string) error {
+	if err := d.SetEntryChannel(make(chan QueuedEntry, cap(channel))); err != nil {
+		return err
+	}
+	go d.forwardMessages(channel)
+	return nil
+}
+
+// GetLogChannel returns the channel of the rule forwarding the messages sent on it to the async queue,
+// stamped with the time they are received. Every call returns the same channel; the forwarding stops when the
+// rule is shut down, so it must not be used afterwards.
+//
+// Deprecated: plain messages lose the level of the entry. Use GetEntryChannel.
 func (d *LogRule) GetLogChannel() chan string {
-	return d.logChannel
+	l := d.startGuards()
+	l.plainOnce.Do(func() {
+		l.plain = make(chan string)
+		go d.forwardMessages(l.plain)
+	})
+	return l.plain
+}
+
+// startGuards returns the lifecycle whose guards start the background work of the rule once. Rules not added
+// to a Debugger get a lifecycle of their own on first use, released when the rule is shut down.
+func (d *LogRule) startGuards() *ruleLifecycle {
+	if d.lifecycle != nil {
+		return d.lifecycle
+	}
+	if l, ok := d.detached.Load().(*ruleLifecycle); ok {
+		return l
+	}
+	d.detached.CompareAndSwap(nil, newRuleLifecycle())
+	return d.detached.Load().(*ruleLifecycle)
+}
+
+// forwardMessages queues the messages of a plain message channel until it is closed or the rule shuts down.
+// Messages of rules without async logging, or not added to a Debugger, are dropped and reported.
+func (d *LogRule) forwardMessages(channel chan string) {
+	l := d.startGuards()
+	for {
+		select {
+		case msg, ok := <-channel:
+			if !ok {
+				return
+			}
+			if d.lifecycle == nil {
+				d.reportError(fmt.Errorf("[mklog] rule %s is not added to a Debugger, message dropped: %s", d.ModuleName, msg))
+				continue
+			}
+			d.forwardMessage(msg)
+		case <-l.released:
+			return
+		}
+	}
+}
+
+// forwardMessage queues a message received on a plain message channel. Shutting the rule down releases a
+// message waiting for room in the queue.
+func (d *LogRule) forwardMessage(msg string) {
+	if !d.AsyncLog.Enable {
+		d.reportError(fmt.Errorf("[mklog] async logging of %s is disabled, message dropped: %s", d.ModuleName, msg))
+		return
+	}
+	if !d.lifecycle.acquire() {
+		return
+	}
+	defer d.lifecycle.release()
+	select {
+	case d.logChannel <- QueuedEntry{Entry: d.plainEntry(msg), Text: msg}:
+	case <-d.lifecycle.released:
+	}
+}
+
+// plainEntry returns the entry of a message received on a plain message channel.
+func (d *LogRule) plainEntry(msg string) Entry {
+	return Entry{
+		Time:       d.now(),
+		Level:      unknownLevel,
+		Module:     d.ModuleName,
+		Submodules: d.Submodules,
+		Message:    msg,
+	}
 }
 
 //#endregion
@@ -397,9 +946,8 @@ func (d *LogRule) GetLogChannel() chan string {
 // It initializes log rules with a minimum log level of InfoLevel and a maximum log level of FatalLevel,
 // and enables console output and debug mode.
 func DefaultConsoleLogging(moduleName string) *Debugger {
-	d := &Debugger{
-		LogRules: make(map[string][]*LogRule),
-	}
+	defaults := GetDefaults()
+	d := &Debugger{}
 
 	d.NewLogRule(
 		moduleName,
@@ -407,8 +955,8 @@ func DefaultConsoleLogging(moduleName string) *Debugger {
 		WithMaxLevel(FatalLevel),
 		WithConsoleOutput(true),
 		WithDebugMode(false, InfoLevel),
-		WithDateFormat(MKLOG_TimeLogFormatDefault),
-		WithForrmatter(MKLOG_FormatterDefault),
+		WithDateFormat(defaults.TimeLogFormat),
+		WithForrmatter(defaults.Formatter),
 	)
 	return d
 }
@@ -416,16 +964,15 @@ func DefaultConsoleLogging(moduleName string) *Debugger {
 // DefaultLogFileSettings creates a Debugger instance with default settings for file logging.
 // It initializes log rules similar to DefaultConsoleLogging, but includes file logging settings.
 func DefaultLogFileSettings(moduleName string) *Debugger {
-	d := &Debugger{
-		LogRules: make(map[string][]*LogRule),
-	}
+	defaults := GetDefaults()
+	d := &Debugger{}
 	d.NewLogRule(moduleName,
 		WithMinLevel(InfoLevel),
 		WithMaxLevel(FatalLevel),
 		WithConsoleOutput(true),
 		WithDebugMode(false, InfoLevel),
-		WithDateFormat(MKLOG_TimeLogFormatDefault),
-		WithFileLoggingDateFormat(MKLOG_DirDefault, MKLOG_FileNameDefault, MKLOG_FileTypeDefault, MKLOG_TimeFileFormatDefault, true),
+		WithDateFormat(defaults.TimeLogFormat),
+		WithFileLoggingDateFormat(defaults.Dir, defaults.FileName, defaults.FileType, defaults.TimeFileFormat, true),
 	)
 	return d
 }
@@ -433,9 +980,8 @@ func DefaultLogFileSettings(moduleName string) *Debugger {
 // DefaultLogFileAndFolderSettings creates a Debugger instance with default settings for both file and folder logging.
 // It initializes log rules with console output, file logging, and a time-based folder structure.
 func DefaultLogFileAndFolderSettings(moduleName string) *Debugger {
-	d := &Debugger{
-		LogRules: make(map[string][]*LogRule),
-	}
+	defaults := GetDefaults()
+	d := &Debugger{}
 
 	d.NewLogRule(
 		moduleName,
@@ -443,42 +989,44 @@ func DefaultLogFileAndFolderSettings(moduleName string) *Debugger {
 		WithMaxLevel(FatalLevel),
 		WithConsoleOutput(true),
 		WithDebugMode(false, InfoLevel),
-		WithForrmatter(MKLOG_FormatterDefault),
-		WithDateFormat(MKLOG_TimeLogFormatDefault),
-		WithFileLoggingDateFormat(MKLOG_DirDefault, MKLOG_FileNameDefault, MKLOG_FileTypeDefault, MKLOG_TimeFileFormatDefault, true),
-		WithTimeFolder(MKLOG_TimeFolderFormatDefault, MKLOG_FileFolderPeriodDefault, true),
+		WithForrmatter(defaults.Formatter),
+		WithDateFormat(defaults.TimeLogFormat),
+		WithFileLoggingDateFormat(defaults.Dir, defaults.FileName, defaults.FileType, defaults.TimeFileFormat, true),
+		WithTimeFolder(defaults.TimeFolderFormat, defaults.FileFolderPeriod, true),
 	)
 	return d
 }
 
 // DefaultSeparateLogAndError creates a Debugger instance with separate logging settings for standard and error logs.
 // It sets up two log rules: one for InfoLevel to ErrorLevel and another for ErrorLevel to FatalLevel.
+// Only the first rule prints to the console; the error rule writes to the error file alone, so Fatal entries
+// are not printed.
 func DefaultSeparateLogAndError(moduleName string) *Debugger {
-	d := &Debugger{
-		LogRules: make(map[string][]*LogRule),
-	}
+	defaults := GetDefaults()
+	d := &Debugger{}
 
 	d.NewLogRule(
 		moduleName,
 		WithMinLevel(InfoLevel),
 		WithMaxLevel(ErrorLevel),
-		WithFileLoggingDateFormat(MKLOG_DirDefault, MKLOG_FileNameDefault, MKLOG_FileTypeDefault, MKLOG_TimeFileFormatDefault, true),
-		WithTimeFolder(MKLOG_TimeFolderFormatDefault, MKLOG_FileFolderPeriodDefault, true),
+		WithFileLoggingDateFormat(defaults.Dir, defaults.FileName, defaults.FileType, defaults.TimeFileFormat, true),
+		WithTimeFolder(defaults.TimeFolderFormat, defaults.FileFolderPeriod, true),
 		WithConsoleOutput(true),
 		WithDebugMode(false, InfoLevel),
-		WithForrmatter(MKLOG_FormatterDefault),
+		WithForrmatter(defaults.Formatter),
 	)
 
 	d.NewLogRule(
 		moduleName,
 		WithMinLevel(ErrorLevel),
 		WithMaxLevel(FatalLevel),
-		WithFileLoggingDateFormat(MKLOG_DirDefault, "err", ".err", MKLOG_TimeFileFormatDefault, true),
-		WithTimeFolder(MKLOG_TimeFolderFormatDefault, MKLOG_FileFolderPeriodDefault, true),
-		WithDateFormat(MKLOG_TimeLogFormatDefault),
+		WithConsoleOutput(false), // Error entries are printed by the first rule already.
+		WithFileLoggingDateFormat(defaults.Dir, "err", ".err", defaults.TimeFileFormat, true),
+		WithTimeFolder(defaults.TimeFolderFormat, defaults.FileFolderPeriod, true),
+		WithDateFormat(defaults.TimeLogFormat),
 		WithDebugMode(false, InfoLevel),
 		WithDetailedErrorOutput(true),
-		WithForrmatter(MKLOG_FormatterDefault),
+		WithForrmatter(defaults.Formatter),
 	)
 
 	return d
@@ -491,12 +1039,14 @@ func DefaultSeparateLogAndError(moduleName string) *Debugger {
 // SetLogFormatter sets the log formatter for the Debugger instance.
 func (d *LogRule) SetLogFormatter(formatter LogFormatter) *LogRule {
 	d.LogFormatter = formatter
+	d.resetFormatterPanics()
 	return d
 }
 
 // SetUserDefinedFormatter sets a user-defined log formatter function and date format.
 func (d *LogRule) SetUserDefinedFormatter(formatFunc UserDefinedFormatterFunc) *LogRule {
 	d.LogFormatter = UserDefinedFormatter{formatFunc}
+	d.resetFormatterPanics()
 	return d
 }
 
@@ -525,6 +1075,9 @@ func StringToLogLevel(level string) (LogLevel, error) {
 	case "trace", "t":
 		return TraceLevel, nil
 	default:
+		if l, ok := registeredLevelByName(level); ok {
+			return l, nil
+		}
 		return 0, fmt.Errorf("invalid log level: %s", level)
 	}
 }
@@ -548,6 +1101,9 @@ func (l LogLevel) GetLogLevelName() string {
 	case FatalLevel:
 		return "FATAL"
 	default:
+		if c, ok := registeredLevel(l); ok {
+			return c.name
+		}
 		return "UNKNOWN"
 	}
 }
@@ -0,0 +1,249 @@
+package mklog
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestGetLogChannel forwards plain messages to the async queue through one channel per rule, whose forwarding
+// ends with the rule.
+func TestGetLogChannel(t *testing.T) {
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	if _, err := d.NewLogRuleE("app", WithFileWriter(out), WithAsyncLog(true, 16)); err != nil {
+		t.Fatal(err)
+	}
+	rule := d.rules()["app"][0]
+
+	before := runtime.NumGoroutine()
+	channel := rule.GetLogChannel()
+	for i := 0; i < 100; i++ {
+		if rule.GetLogChannel() != channel {
+			t.Fatal("GetLogChannel returned a new channel")
+		}
+	}
+	if n := runtime.NumGoroutine() - before; n > 1 {
+		t.Errorf("GetLogChannel started %d goroutines, want 1", n)
+	}
+
+	channel <- "plain message"
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "plain message") {
+		t.Errorf("output = %q, want the plain message", out.String())
+	}
+
+	// The forwarding goroutine ends with the rule.
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left after Close, want at most %d", n, before)
+	}
+}
+
+// waitGoroutines waits until at most n goroutines are running and returns the number running.
+func waitGoroutines(n int) int {
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return runtime.NumGoroutine()
+}
+
+// TestStartAsyncLoggingConcurrent starts one async consumer and one forwarding goroutine per rule however
+// many goroutines start the rule, also for rules not added to a Debugger, and stops them when the rule shuts
+// down.
+func TestStartAsyncLoggingConcurrent(t *testing.T) {
+	start := func(rule *LogRule) chan string {
+		var (
+			wg       sync.WaitGroup
+			channels [16]chan string
+		)
+		for i := range channels {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				rule.StartAsyncLogging()
+				channels[i] = rule.GetLogChannel()
+			}(i)
+		}
+		wg.Wait()
+		for _, channel := range channels {
+			if channel != channels[0] {
+				t.Fatal("GetLogChannel returned different channels")
+			}
+		}
+		return channels[0]
+	}
+
+	t.Run("debugger", func(t *testing.T) {
+		out := &syncBuffer{}
+		d := (&Debugger{}).SetQuiet(true)
+		d.NewLogRule("app", WithFileWriter(out), WithAsyncLog(true, 16))
+		rule := d.rules()["app"][0]
+
+		before := runtime.NumGoroutine()
+		channel := start(rule)
+		if n := runtime.NumGoroutine() - before; n > 1 {
+			t.Errorf("started %d goroutines, want only the forwarding one next to the running consumer", n)
+		}
+		channel <- "plain message"
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(out.String(), "plain message"); got != 1 {
+			t.Errorf("output = %q, want the plain message once", out.String())
+		}
+		if n := waitGoroutines(before - 1); n > before-1 {
+			t.Errorf("%d goroutines left after Close, want at most %d", n, before-1)
+		}
+	})
+
+	t.Run("detached", func(t *testing.T) {
+		var dropped int32
+		rule := &LogRule{ModuleName: "bare", AsyncLog: AsyncLog{Enable: true}, logChannel: make(chan QueuedEntry)}
+		before := runtime.NumGoroutine()
+		channel := start(rule)
+		if n := runtime.NumGoroutine() - before; n > 2 {
+			t.Errorf("started %d goroutines, want one consumer and one forwarding goroutine", n)
+		}
+
+		// Messages of a rule without a Debugger are dropped; the forwarding stops when the rule shuts down.
+		rule.debugger = (&Debugger{}).SetErrorHandler(func(error) { atomic.AddInt32(&dropped, 1) })
+		channel <- "plain message"
+		if err := rule.StopAsyncLogging(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := rule.shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if n := waitGoroutines(before); n > before {
+			t.Errorf("%d goroutines left after shutdown, want at most %d", n, before)
+		}
+		if atomic.LoadInt32(&dropped) != 1 {
+			t.Errorf("reported %d dropped messages, want 1", dropped)
+		}
+	})
+}
+
+// TestSetLogChannelE fails once the async consumer is running, while SetLogChannel reports the error.
+func TestSetLogChannelE(t *testing.T) {
+	var reported []error
+	d := (&Debugger{}).SetQuiet(true).SetErrorHandler(func(err error) { reported = append(reported, err) })
+	if _, err := d.NewLogRuleE("app", WithFileWriter(&syncBuffer{}), WithAsyncLog(true, 16)); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	rule := d.rules()["app"][0]
+
+	if err := rule.SetLogChannelE(make(chan string)); err == nil {
+		t.Error("SetLogChannelE succeeded with the consumer running")
+	}
+	if rule.SetLogChannel(make(chan string)) != rule {
+		t.Error("SetLogChannel did not return the rule")
+	}
+	if len(reported) != 1 {
+		t.Errorf("reported %d errors, want 1", len(reported))
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+// TestSetErrorHandlerWhileLogging replaces the error handler while several goroutines report failed writes,
+// run with -race.
+func TestSetErrorHandlerWhileLogging(t *testing.T) {
+	var reported int64
+	handler := func(error) { atomic.AddInt64(&reported, 1) }
+
+	d := (&Debugger{}).SetQuiet(true).SetErrorHandler(handler)
+	d.SetErrorCoalescing(0)
+	d.NewLogRule("app", WithFileWriter(failingWriter{}))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				d.Info("message %d", i)
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		d.SetErrorHandler(handler)
+	}
+	wg.Wait()
+
+	if atomic.LoadInt64(&reported) == 0 {
+		t.Error("no failed write was reported")
+	}
+}
+
+// flushBuffer keeps the written data in a buffer until it is flushed, like a buffered file writer.
+type flushBuffer struct {
+	mu      sync.Mutex
+	pending strings.Builder
+	flushed strings.Builder
+	flushes int
+}
+
+func (b *flushBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pending.Write(p)
+}
+
+func (b *flushBuffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushed.WriteString(b.pending.String())
+	b.pending.Reset()
+	b.flushes++
+	return nil
+}
+
+// state returns the flushed data and the number of flushes.
+func (b *flushBuffer) state() (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushed.String(), b.flushes
+}
+
+// TestAsyncFlushInterval writes a partial batch and flushes the buffered log file at the flush interval
+// while the rule stays open, and does not flush again while no messages were written.
+func TestAsyncFlushInterval(t *testing.T) {
+	out := &flushBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(out), WithAsyncLog(true, 16), WithAsyncBatchSize(8),
+		WithAsyncFlushInterval(10*time.Millisecond))
+	defer d.Close(context.Background())
+
+	d.Info("first")
+	d.Info("second")
+	deadline := time.Now().Add(5 * time.Second)
+	flushed, flushes := out.state()
+	for !strings.Contains(flushed, "second") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		flushed, flushes = out.state()
+	}
+	if !strings.Contains(flushed, "first") || !strings.Contains(flushed, "second") {
+		t.Fatalf("flushed %q before Close, want both messages", flushed)
+	}
+
+	time.Sleep(50 * time.Millisecond) // Five idle intervals.
+	if _, idle := out.state(); idle != flushes {
+		t.Errorf("flushed %d times while idle, want no flush", idle-flushes)
+	}
+}
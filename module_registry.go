@@ -0,0 +1,161 @@
+package mklog
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// moduleRegistry holds the module names registered with RegisterModules.
+type moduleRegistry struct {
+	mu      sync.RWMutex
+	names   map[string]struct{} // Registered module names, nil while the registry is lenient
+	panicky bool                // Panic on unregistered names instead of returning an error
+}
+
+// RegisterModules registers module names and makes the registry strict: afterwards rules can only be added for
+// registered modules and Module rejects other names, so a typo like d.Module("paymnets") is caught instead of
+// silently logging nothing. The modules with rules at the first call, e.g. the one of NewDebugLogger, are
+// registered as well. It can be called again to register more names. Without a call every name is accepted.
+// Constants of the module names of a configuration file can be generated with LogConfigManager.ModuleConstants.
+func (d *Debugger) RegisterModules(names ...string) *Debugger {
+	r := &d.modules
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names == nil {
+		r.names = make(map[string]struct{}, len(names)+len(d.rules()))
+		for name := range d.rules() {
+			r.names[name] = struct{}{}
+		}
+	}
+	for _, name := range names {
+		r.names[name] = struct{}{}
+	}
+	return d
+}
+
+// SetUnknownModulePanic makes the strict registry panic on unregistered module names instead of returning or
+// reporting an error, e.g. in development builds, so typos fail loudly.
+func (d *Debugger) SetUnknownModulePanic(enabled bool) *Debugger {
+	d.modules.mu.Lock()
+	d.modules.panicky = enabled
+	d.modules.mu.Unlock()
+	return d
+}
+
+// Modules returns the sorted names of the registered modules and of the modules with rules.
+func (d *Debugger) Modules() []string {
+	seen := make(map[string]struct{})
+	d.modules.mu.RLock()
+	for name := range d.modules.names {
+		seen[name] = struct{}{}
+	}
+	d.modules.mu.RUnlock()
+	for name := range d.rules() {
+		seen[name] = struct{}{}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ModuleE returns a handle logging to the rules of the module like Module, failing for modules the strict
+// registry does not know, see RegisterModules.
+func (d *Debugger) ModuleE(name string) (*Logger, error) {
+	if err := d.checkModule(name); err != nil {
+		return nil, err
+	}
+	return &Logger{debugger: d, module: name}, nil
+}
+
+// checkModule returns an error for a module unknown to the strict registry, or panics when the registry is
+// set to panic. Every name is known while the registry is lenient.
+func (d *Debugger) checkModule(name string) error {
+	r := &d.modules
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.names == nil {
+		return nil
+	}
+	if _, ok := r.names[name]; ok {
+		return nil
+	}
+	err := fmt.Errorf("[mklog] module %q is not registered", name)
+	if r.panicky {
+		panic(err)
+	}
+	return err
+}
+
+// ModuleConstants generates Go source of the given package declaring a constant per rule name of the
+// configuration file and a slice of them for Debugger.RegisterModules, e.g. for go:generate. The constants are
+// named after the rule names in camel case with a Module prefix, so "payment-gateway" becomes
+// ModulePaymentGateway.
+func (m *LogConfigManager) ModuleConstants(filePath, pkg string) ([]byte, error) {
+	config, _, err := m.readConfig(filePath)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(config.LogRules))
+	for name := range config.LogRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	idents := make(map[string]string, len(names))
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by mklog from %s; DO NOT EDIT.\n\n", filepath.Base(filePath))
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "// Module names of the log rules in %s.\n", filepath.Base(filePath))
+	buf.WriteString("const (\n")
+	for _, name := range names {
+		ident := moduleIdent(name)
+		if other, ok := idents[ident]; ok {
+			return nil, fmt.Errorf("[mklog] modules %q and %q map to the same constant %s", other, name, ident)
+		}
+		idents[ident] = name
+		fmt.Fprintf(&buf, "%s = %q\n", ident, name)
+	}
+	buf.WriteString(")\n\n")
+	buf.WriteString("// Modules lists the module names, e.g. for Debugger.RegisterModules.\n")
+	buf.WriteString("var Modules = []string{\n")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s,\n", moduleIdent(name))
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("[mklog] failed to format module constants: %w", err)
+	}
+	return src, nil
+}
+
+// moduleIdent returns the name of the constant of a module: the letters and digits of the name in camel case,
+// prefixed with Module.
+func moduleIdent(name string) string {
+	var sb strings.Builder
+	sb.WriteString("Module")
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
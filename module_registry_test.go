@@ -0,0 +1,139 @@
+package mklog
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestModuleRegistryLenient accepts every module name without registered modules.
+func TestModuleRegistryLenient(t *testing.T) {
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(rec.handle)
+	defer d.Close(context.Background())
+
+	if _, err := d.NewLogRuleE("payments", WithFileWriter(&syncBuffer{})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ModuleE("paymnets"); err != nil {
+		t.Errorf("ModuleE error = %v, want every name accepted", err)
+	}
+	d.Module("paymnets").Info("dropped")
+	if errs := rec.get(); len(errs) != 0 {
+		t.Errorf("errors = %v, want none", errs)
+	}
+}
+
+// TestModuleRegistryStrict rejects rules and handles of unregistered modules once modules are registered,
+// keeping the modules that had rules before.
+func TestModuleRegistryStrict(t *testing.T) {
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(rec.handle)
+	defer d.Close(context.Background())
+
+	out := &syncBuffer{}
+	d.NewLogRule("app", WithFileWriter(out))
+	d.RegisterModules("payments", "db")
+
+	if _, err := d.NewLogRuleE("payments", WithFileWriter(out)); err != nil {
+		t.Errorf("registered module: %v", err)
+	}
+	if _, err := d.NewLogRuleE("paymnets", WithFileWriter(out)); err == nil || err.Error() != `[mklog] module "paymnets" is not registered` {
+		t.Errorf("unregistered rule: error = %v", err)
+	}
+	d.NewLogRule("cache", WithFileWriter(out))
+	if _, ok := d.rules()["cache"]; ok {
+		t.Error("NewLogRule added a rule of an unregistered module")
+	}
+	for _, name := range []string{"app", "payments", "db"} {
+		if _, err := d.ModuleE(name); err != nil {
+			t.Errorf("ModuleE(%q) error = %v", name, err)
+		}
+	}
+	if l, err := d.ModuleE("paymnets"); err == nil || l != nil {
+		t.Errorf("ModuleE(paymnets) = %v, %v, want an error", l, err)
+	}
+
+	d.Module("payments").Module("paymnets").Info("typo")
+	errs := rec.get()
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), `module "cache" is not registered`) ||
+		!strings.Contains(errs[1].Error(), `module "paymnets" is not registered`) {
+		t.Errorf("errors = %v, want the cache rule and the paymnets handle", errs)
+	}
+
+	d.RegisterModules("cache")
+	want := []string{"app", "cache", "db", "payments"}
+	if got := d.Modules(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Modules = %v, want %v", got, want)
+	}
+}
+
+// TestModuleRegistryPanic panics on unregistered module names in panic mode.
+func TestModuleRegistryPanic(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true).RegisterModules("app").SetUnknownModulePanic(true)
+	defer d.Close(context.Background())
+
+	d.Module("app")
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(error).Error(), `module "ap" is not registered`) {
+			t.Errorf("recovered %v, want the unregistered module", r)
+		}
+	}()
+	d.Module("ap")
+	t.Error("Module did not panic")
+}
+
+// TestModuleConstants generates constants of the rule names of a configuration file that type-check.
+func TestModuleConstants(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	rule := `
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: ` + dir + `, file_name: app, file_type: .log}`
+	config := writeConfig(t, "mklog.yaml", "log_rules:\n  payment-gateway:"+rule+"\n  db:"+rule+"\n  http_api.v2:"+rule+"\n")
+
+	src, err := NewLogConfigManager().ModuleConstants(config, "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "modules.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+	pkg, err := (&types.Config{}).Check("logs", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("generated source does not compile: %v\n%s", err, src)
+	}
+	consts := map[string]string{
+		"ModuleDb":             `"db"`,
+		"ModuleHttpApiV2":      `"http_api.v2"`,
+		"ModulePaymentGateway": `"payment-gateway"`,
+	}
+	for name, value := range consts {
+		c, ok := pkg.Scope().Lookup(name).(*types.Const)
+		if !ok || c.Val().ExactString() != value {
+			t.Errorf("constant %s = %v, want %s", name, pkg.Scope().Lookup(name), value)
+		}
+	}
+	if v, ok := pkg.Scope().Lookup("Modules").(*types.Var); !ok || v.Type().String() != "[]string" {
+		t.Errorf("Modules = %v, want a []string", pkg.Scope().Lookup("Modules"))
+	}
+	if !strings.HasPrefix(string(src), "// Code generated by mklog from mklog.yaml; DO NOT EDIT.") {
+		t.Errorf("generated source lacks the header:\n%s", src)
+	}
+
+	clash := writeConfig(t, "clash.yaml", "log_rules:\n  a-b:"+rule+"\n  a_b:"+rule+"\n")
+	if _, err := NewLogConfigManager().ModuleConstants(clash, "logs"); err == nil || !strings.Contains(err.Error(), "map to the same constant ModuleAB") {
+		t.Errorf("ModuleConstants error = %v, want the clash", err)
+	}
+}
@@ -0,0 +1,71 @@
+package mklog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Multi-line modes of the text formatters
+const (
+	MultilineRaw    = "raw"    // Write line breaks unchanged (default)
+	MultilineEscape = "escape" // Replace line breaks with a literal \n, keeping one line per entry
+	MultilineIndent = "indent" // Start continuation lines with MultilineIndentPrefix, see FileReader
+)
+
+var (
+	// Prefix of the continuation lines in MultilineIndent mode
+	MKLOG_MultilineIndentPrefix = "    > "
+)
+
+// MultilineFormatter is an optional interface of log formatters deciding how the line breaks of an entry are
+// written, e.g. to keep one line per entry for tail and grep. Rules pass it the formatted entry including the
+// appended error details, such as the stack of a DetailedError.
+type MultilineFormatter interface {
+	// FormatMultiline returns the formatted entry with its line breaks rendered.
+	FormatMultiline(formatted string) string
+}
+
+// FormatMultiline renders the line breaks of the entry according to MultilineMode.
+func (f PlainTextFormatter) FormatMultiline(formatted string) string {
+	return formatMultiline(f.MultilineMode, formatted)
+}
+
+// FormatMultiline renders the line breaks of the entry according to MultilineMode.
+func (f FastTextFormatter) FormatMultiline(formatted string) string {
+	return formatMultiline(f.MultilineMode, formatted)
+}
+
+// validMultilineMode checks whether the mode names a multi-line mode; empty selects MultilineRaw.
+func validMultilineMode(mode string) bool {
+	switch mode {
+	case "", MultilineRaw, MultilineEscape, MultilineIndent:
+		return true
+	}
+	return false
+}
+
+// multilineOption returns the multiline option of a text formatter block.
+func multilineOption(options map[string]interface{}) (string, error) {
+	v, ok := options["multiline"]
+	if !ok || v == nil {
+		return "", nil
+	}
+	mode, ok := v.(string)
+	if !ok || !validMultilineMode(mode) {
+		return "", fmt.Errorf("option multiline must be %q, %q or %q, got %v", MultilineRaw, MultilineEscape, MultilineIndent, v)
+	}
+	return mode, nil
+}
+
+// formatMultiline renders the line breaks of the text in the mode. Trailing line breaks are dropped in the
+// escape and indent modes, as they would only add an empty continuation.
+func formatMultiline(mode string, text string) string {
+	if mode != MultilineEscape && mode != MultilineIndent || !strings.ContainsAny(text, "\r\n") {
+		return text
+	}
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if mode == MultilineEscape {
+		return strings.ReplaceAll(text, "\n", `\n`)
+	}
+	return strings.ReplaceAll(text, "\n", "\n"+MKLOG_MultilineIndentPrefix)
+}
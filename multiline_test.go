@@ -0,0 +1,127 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFormatMultiline renders the line breaks of a text in each multi-line mode.
+func TestFormatMultiline(t *testing.T) {
+	const text = "first\r\nsecond\nthird\n\n"
+	tests := []struct {
+		mode, want string
+	}{
+		{"", text},
+		{MultilineRaw, text},
+		{MultilineEscape, `first\nsecond\nthird`},
+		{MultilineIndent, "first\n    > second\n    > third"},
+	}
+	for _, tt := range tests {
+		if got := formatMultiline(tt.mode, text); got != tt.want {
+			t.Errorf("mode %q: %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+	if got := formatMultiline(MultilineEscape, "single line"); got != "single line" {
+		t.Errorf("single line rendered as %q", got)
+	}
+}
+
+// logDetailedError logs an Error entry with the stack of a DetailedError through a plain text rule in the
+// multi-line mode to a log file in the directory and returns the content of the file.
+func logDetailedError(t *testing.T, dir, mode string) string {
+	t.Helper()
+	d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return time.Date(2024, 3, 9, 10, 20, 30, 0, time.Local) })
+	d.NewLogRule("app",
+		WithFileLogging(dir, "app", ".log"),
+		WithLogFormatter(PlainTextFormatter{MultilineMode: mode}),
+		WithDateFormat(time.RFC3339),
+		WithDetailedErrorOutput(true),
+	)
+	d.Error("save failed: %v", NewDetailedError(errors.New("disk full")))
+	d.Info("next entry")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return readFile(t, filepath.Join(dir, "app.log"))
+}
+
+// TestMultilineDetailedErrorStack writes the stack of a DetailedError over several lines in raw mode, on the
+// line of the entry in escape mode and on prefixed continuation lines in indent mode, which the file reader
+// joins back into the entry.
+func TestMultilineDetailedErrorStack(t *testing.T) {
+	raw := logDetailedError(t, t.TempDir(), MultilineRaw)
+	if strings.Count(raw, "\n") < 4 || !strings.Contains(raw, "\nStack Trace") {
+		t.Errorf("raw log = %q, want the stack on lines of its own", raw)
+	}
+
+	escaped := logDetailedError(t, t.TempDir(), MultilineEscape)
+	lines := strings.Split(strings.TrimSuffix(escaped, "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `\nStack Trace`) ||
+		!strings.HasSuffix(lines[1], "[app] : next entry") {
+		t.Errorf("escaped log = %q, want one line per entry", escaped)
+	}
+
+	dir := t.TempDir()
+	indented := logDetailedError(t, dir, MultilineIndent)
+	lines = strings.Split(strings.TrimSuffix(indented, "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "2024-03-09T10:20:30") || !strings.HasSuffix(lines[len(lines)-1], "[app] : next entry") {
+		t.Fatalf("indented log = %q", indented)
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if !strings.HasPrefix(line, MKLOG_MultilineIndentPrefix) {
+			t.Errorf("continuation line %q does not start with the prefix", line)
+		}
+	}
+
+	r, err := NewFileReader(filepath.Join(dir, "app.log"), "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.DateFormat = time.RFC3339
+	var entries []Entry
+	for r.Next() {
+		entries = append(entries, r.Entry())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Level != ErrorLevel || entries[1].Message != "next entry" {
+		t.Fatalf("read %d entries: %+v", len(entries), entries)
+	}
+	if msg := entries[0].Message; !strings.HasPrefix(msg, "save failed: disk full\n") || !strings.Contains(msg, "\nStack Trace") ||
+		strings.Contains(msg, MKLOG_MultilineIndentPrefix) {
+		t.Errorf("reassembled message = %q, want the stack without the prefixes", msg)
+	}
+}
+
+// TestMultilineConfig sets the multi-line mode of a text formatter from the configuration and rejects unknown
+// modes.
+func TestMultilineConfig(t *testing.T) {
+	config := func(mode string) string {
+		return writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: fasttext, multiline: `+mode+`}
+      file_log: {enable: true, file_path: `+filepath.ToSlash(t.TempDir())+`, file_name: app, file_type: .log}
+`)
+	}
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config(MultilineEscape))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	if f, ok := d.rules()["app"][0].LogFormatter.(FastTextFormatter); !ok || f.MultilineMode != MultilineEscape {
+		t.Errorf("formatter = %#v, want fast text in escape mode", d.rules()["app"][0].LogFormatter)
+	}
+
+	if _, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config("fold")); err == nil || !strings.Contains(err.Error(), "option multiline must be") {
+		t.Errorf("LoadConfig error = %v, want the unknown multiline mode", err)
+	}
+}
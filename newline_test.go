@@ -0,0 +1,106 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newlineFormatter is a formatter ending every entry with line breaks of its own, like formatters written for
+// versions where formatters owned the newline.
+type newlineFormatter struct{}
+
+func (newlineFormatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	return timestamp + " " + logLevel + " " + logMessage + "\r\n\n"
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// TestNewlinesGolden logs entries whose messages end with line breaks through every formatter, synchronously
+// and asynchronously, to the console and to the file with each line ending. Every entry must take exactly one
+// line ending: the one of the rule in the file and "\n" on the console.
+func TestNewlinesGolden(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatters := []struct {
+		name      string
+		formatter LogFormatter
+	}{
+		{"plain", PlainTextFormatter{}},
+		{"fasttext", FastTextFormatter{}},
+		{"json", &JSONFormatter{}},
+		{"logfmt", &LogfmtFormatter{}},
+		{"newline", newlineFormatter{}},
+	}
+	messages := []string{"plain message", "trailing LF\n", "trailing CRLF\r\n", "trailing breaks\n\r\n"}
+	at := time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC)
+
+	var golden strings.Builder
+	for _, f := range formatters {
+		for _, async := range []bool{false, true} {
+			for _, ending := range []string{"\n", "\r\n"} {
+				file := &syncBuffer{}
+				console := captureStdout(t, func() {
+					d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return at })
+					opts := []Option{
+						WithLogFormatter(f.formatter),
+						WithDateFormat(time.RFC3339),
+						WithFileWriter(file),
+						WithLineEnding(ending),
+						WithConsoleOutput(true),
+					}
+					if async {
+						opts = append(opts, WithAsyncLog(true, 16))
+					}
+					d.NewLogRule("app", opts...)
+					for _, msg := range messages {
+						d.Info("%s", msg)
+					}
+					if _, err := d.Close(context.Background()); err != nil {
+						t.Fatal(err)
+					}
+				})
+
+				name := fmt.Sprintf("%s async=%v ending=%q", f.name, async, ending)
+				if n := strings.Count(file.String(), ending); n != len(messages) || strings.Count(file.String(), "\n") != len(messages) {
+					t.Errorf("%s: file has %d line endings for %d entries: %q", name, n, len(messages), file.String())
+				}
+				if ending == "\n" && strings.Contains(file.String(), "\r") {
+					t.Errorf("%s: file has carriage returns: %q", name, file.String())
+				}
+				if n := strings.Count(console, "\n"); n != len(messages) || strings.Contains(console, "\n\n") {
+					t.Errorf("%s: console has %d line breaks for %d entries: %q", name, n, len(messages), console)
+				}
+				fmt.Fprintf(&golden, "%s\nfile:    %q\nconsole: %q\n\n", name, file.String(), console)
+			}
+		}
+	}
+	checkGolden(t, filepath.Join(wd, "testdata", "newlines.golden"), golden.String())
+}
@@ -0,0 +1,59 @@
+package mklog
+
+import "fmt"
+
+// Notice is a diagnostic about the setup of a rule, e.g. a default taking effect, a log file that could not be
+// created or a formatter disabled after it panicked repeatedly. Notices are passed to the error handler of the
+// Debugger, so applications can tell them from other internal errors with errors.As and route them by level.
+type Notice struct {
+	Level   LogLevel // Severity of the notice; notices below WarningLevel are informational.
+	Module  string   // Module of the rule the notice is about.
+	Message string   // Text of the notice.
+	Err     error    // Error causing the notice, if any.
+}
+
+// Error returns the text of the notice.
+func (n *Notice) Error() string {
+	return n.Message
+}
+
+// Unwrap returns the error causing the notice.
+func (n *Notice) Unwrap() error {
+	return n.Err
+}
+
+// SetQuiet suppresses the informational notices of the Debugger, e.g. for command line programs whose stdout
+// must only carry their own output. Warnings and errors are still passed to the error handler.
+func (d *Debugger) SetQuiet(quiet bool) *Debugger {
+	d.quiet = quiet
+	return d
+}
+
+// WithQuiet suppresses the informational notices raised while the rule is built, like Debugger.SetQuiet.
+func WithQuiet(quiet bool) Option {
+	return func(lr *LogRule) {
+		lr.quiet = quiet
+	}
+}
+
+// SetQuiet suppresses the informational notices raised while the manager loads configurations.
+func (m *LogConfigManager) SetQuiet(quiet bool) *LogConfigManager {
+	m.quiet = quiet
+	return m
+}
+
+// SetErrorHandler sets the error handler of the Debuggers loaded by the manager, receiving the notices raised
+// while the configuration is loaded as well.
+func (m *LogConfigManager) SetErrorHandler(handler ErrorHandler) *LogConfigManager {
+	m.errorHandler = handler
+	return m
+}
+
+// notice passes a notice about the rule to the error handler unless it is informational and the Debugger or
+// the rule is quiet. The rule may be nil for notices about the configuration of a rule not built yet.
+func (d *Debugger) notice(lr *LogRule, module string, level LogLevel, err error, format string, args ...interface{}) {
+	if level < WarningLevel && (d.quiet || (lr != nil && lr.quiet)) {
+		return
+	}
+	d.coalesceError(lr, &Notice{Level: level, Module: module, Message: fmt.Sprintf(format, args...), Err: err})
+}
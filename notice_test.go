@@ -0,0 +1,54 @@
+package mklog
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestQuietKeepsStdoutClean builds rules raising informational notices and logs through a file-only rule: in
+// quiet mode, set on the Debugger or on the rule, nothing reaches stdout, while warnings still reach the error
+// handler.
+func TestQuietKeepsStdoutClean(t *testing.T) {
+	tests := []struct {
+		name   string
+		quiet  bool // Set on the Debugger
+		opts   []Option
+		silent bool
+	}{
+		{"default", false, nil, false},
+		{"Debugger.SetQuiet", true, nil, true},
+		{"WithQuiet", false, []Option{WithQuiet(true)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &syncBuffer{}
+			stdout := captureStdout(t, func() {
+				d := (&Debugger{}).SetQuiet(tt.quiet)
+				d.NewLogRule("app", append([]Option{WithFileWriter(file), WithAsyncLog(true, 0)}, tt.opts...)...)
+				d.Info("written to the file")
+				d.Close(context.Background())
+			})
+
+			if tt.silent && stdout != "" {
+				t.Errorf("stdout = %q, want nothing", stdout)
+			}
+			if !tt.silent && (!strings.Contains(stdout, "LogFormatter not set") || !strings.Contains(stdout, "Buffersize set to default value")) {
+				t.Errorf("stdout = %q, want the notices", stdout)
+			}
+			if !strings.Contains(file.String(), "written to the file") {
+				t.Errorf("log file = %q, want the entry", file.String())
+			}
+		})
+	}
+
+	rec := &errorRecorder{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(rec.handle)
+	d.SetErrorCoalescing(0)
+	d.notice(nil, "app", WarningLevel, nil, "[mklog] ignoring unknown config key %s", "colour")
+	d.notice(nil, "app", InfoLevel, nil, "[mklog] Buffersize set to default value")
+	if errs := rec.get(); len(errs) != 1 || errs[0].Error() != "[mklog] ignoring unknown config key colour" {
+		t.Errorf("errors = %v, want only the warning", errs)
+	}
+}
@@ -0,0 +1,168 @@
+package mklog
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+)
+
+var (
+	// Default number of keys remembered by the once-only logging helpers
+	MKLOG_OnceRegistrySizeDefault = 1024
+)
+
+// onceEntry is a key logged by a once-only helper.
+type onceEntry struct {
+	key        string
+	level      LogLevel
+	message    string // Message of the first occurrence
+	suppressed uint64 // Number of occurrences after the first one
+}
+
+// onceRegistry remembers the keys of the once-only helpers, evicting the least recently used key when full.
+type onceRegistry struct {
+	size    int
+	summary bool // Log the suppressed counts on Close
+	order   *list.List
+	keys    map[string]*list.Element
+}
+
+// SetOnceRegistrySize bounds the number of keys remembered by the once-only helpers.
+// When the registry is full, the least recently used key is forgotten and logged again on its next occurrence.
+func (d *Debugger) SetOnceRegistrySize(size int) *Debugger {
+	d.onceMu.Lock()
+	defer d.onceMu.Unlock()
+	r := d.onceRegistry()
+	r.size = size
+	r.evict()
+	return d
+}
+
+// SetOnceSummary logs the number of suppressed occurrences of every remembered key when the Debugger is closed.
+func (d *Debugger) SetOnceSummary(enabled bool) *Debugger {
+	d.onceMu.Lock()
+	d.onceRegistry().summary = enabled
+	d.onceMu.Unlock()
+	return d
+}
+
+// OnceCount returns the number of occurrences of the key suppressed by the once-only helpers.
+func (d *Debugger) OnceCount(key string) uint64 {
+	d.onceMu.Lock()
+	defer d.onceMu.Unlock()
+	if e, ok := d.onceRegistry().keys[key]; ok {
+		return e.Value.(*onceEntry).suppressed
+	}
+	return 0
+}
+
+// WarnOnce logs the message at the Warning level on the first occurrence of the key and only counts the following ones.
+func (d *Debugger) WarnOnce(key string, msg string, args ...interface{}) {
+	d.CustomOnce(WarningLevel, key, msg, args...)
+}
+
+// CustomOnce logs the message at the level on the first occurrence of the key and only counts the following ones.
+// The message is not formatted for repeated occurrences. Fields and ForceConsole among the arguments apply to
+// the first occurrence and are left out of the message kept for the summary, see SetOnceSummary.
+func (d *Debugger) CustomOnce(logLevel LogLevel, key string, msg string, args ...interface{}) {
+	d.onceMu.Lock()
+	r := d.onceRegistry()
+	if e, ok := r.keys[key]; ok {
+		e.Value.(*onceEntry).suppressed++
+		r.order.MoveToFront(e)
+		d.onceMu.Unlock()
+		return
+	}
+	args, force := splitConsoleFlag(args)
+	args, callFields := splitCallFields(args)
+	entry := &onceEntry{key: key, level: logLevel, message: fmt.Sprintf(msg, args...)}
+	r.keys[key] = r.order.PushFront(entry)
+	r.evict()
+	d.onceMu.Unlock()
+
+	d.logFormatted(nil, d.rules(), logLevel, nil, callFields, force, entry.message, d.extractError(args...))
+}
+
+// onceRegistry returns the registry of the once-only helpers, creating it on first use. onceMu must be held.
+func (d *Debugger) onceRegistry() *onceRegistry {
+	if d.once == nil {
+		d.once = &onceRegistry{
+			size:  MKLOG_OnceRegistrySizeDefault,
+			order: list.New(),
+			keys:  make(map[string]*list.Element),
+		}
+	}
+	return d.once
+}
+
+// evict forgets the least recently used keys beyond the size of the registry.
+func (r *onceRegistry) evict() {
+	for r.size > 0 && r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.keys, oldest.Value.(*onceEntry).key)
+	}
+}
+
+// flushOnceSummary logs the suppressed counts of the once-only helpers when the summary is enabled.
+func (d *Debugger) flushOnceSummary() {
+	d.onceMu.Lock()
+	if d.once == nil || !d.once.summary {
+		d.onceMu.Unlock()
+		return
+	}
+	var entries []onceEntry
+	for _, e := range d.once.keys {
+		if entry := e.Value.(*onceEntry); entry.suppressed > 0 {
+			entries = append(entries, *entry)
+		}
+	}
+	d.onceMu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	for _, e := range entries {
+		d.log(nil, e.level, nil, "%s (repeated %d more times)", e.message, e.suppressed)
+	}
+}
+
+// TraceIf logs a message at the Trace level when cond is true, without formatting it otherwise.
+func (d *Debugger) TraceIf(cond bool, msg string, args ...interface{}) {
+	if cond {
+		d.log(nil, TraceLevel, nil, msg, args...)
+	}
+}
+
+// DebugIf logs a message at the Debug level when cond is true, without formatting it otherwise.
+func (d *Debugger) DebugIf(cond bool, msg string, args ...interface{}) {
+	if cond {
+		d.log(nil, DebugLevel, nil, msg, args...)
+	}
+}
+
+// InfoIf logs a message at the Info level when cond is true, without formatting it otherwise.
+func (d *Debugger) InfoIf(cond bool, msg string, args ...interface{}) {
+	if cond {
+		d.log(nil, InfoLevel, nil, msg, args...)
+	}
+}
+
+// WarningIf logs a message at the Warning level when cond is true, without formatting it otherwise.
+func (d *Debugger) WarningIf(cond bool, msg string, args ...interface{}) {
+	if cond {
+		d.log(nil, WarningLevel, nil, msg, args...)
+	}
+}
+
+// ErrorIf logs a message at the Error level when cond is true, without formatting it otherwise.
+func (d *Debugger) ErrorIf(cond bool, msg string, args ...interface{}) {
+	if cond {
+		d.log(nil, ErrorLevel, nil, msg, args...)
+	}
+}
+
+// FatalIf logs a message at the Fatal level when cond is true, with the same exit behavior as Fatal.
+func (d *Debugger) FatalIf(cond bool, msg string, args ...interface{}) {
+	if cond {
+		d.Fatal(msg, args...)
+	}
+}
@@ -0,0 +1,105 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newOnceDebugger returns a Debugger with a rule logging from Trace and the sink of the rule.
+func newOnceDebugger() (*Debugger, *entrySink) {
+	sink := &entrySink{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithSink(sink))
+	return d, sink
+}
+
+// TestWarnOnceConcurrent logs a key once however many goroutines log it and counts the other occurrences.
+func TestWarnOnceConcurrent(t *testing.T) {
+	d, sink := newOnceDebugger()
+	defer d.Close(context.Background())
+
+	const n = 64
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d.WarnOnce("disk", "disk almost full (%d)", i)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := sink.messages(); len(got) != 1 || !strings.HasPrefix(got[0], "disk almost full (") {
+		t.Errorf("sink messages = %q, want one warning", got)
+	}
+	if got := d.OnceCount("disk"); got != n-1 {
+		t.Errorf("OnceCount = %d, want %d", got, n-1)
+	}
+}
+
+// TestOnceEviction forgets the least recently used key of a full registry, which is logged again on its next
+// occurrence, and keeps the keys used since.
+func TestOnceEviction(t *testing.T) {
+	d, sink := newOnceDebugger()
+	defer d.Close(context.Background())
+	d.SetOnceRegistrySize(2)
+
+	d.WarnOnce("a", "a")
+	d.WarnOnce("b", "b")
+	d.WarnOnce("a", "a") // a is now the most recently used key.
+	d.WarnOnce("c", "c") // Evicts b.
+	d.WarnOnce("a", "a")
+	d.WarnOnce("b", "b") // Logged again, evicts c.
+
+	want := []string{"a", "b", "c", "b"}
+	if got := sink.messages(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("sink messages = %q, want %q", got, want)
+	}
+	if got := d.OnceCount("a"); got != 2 {
+		t.Errorf("OnceCount(a) = %d, want 2", got)
+	}
+	if got := d.OnceCount("c"); got != 0 {
+		t.Errorf("OnceCount(c) = %d, want 0 for the evicted key", got)
+	}
+}
+
+// stringer counts how often it is formatted.
+type stringer struct{ calls *int }
+
+func (s stringer) String() string {
+	*s.calls++
+	return "value"
+}
+
+// TestCustomOnceArguments formats the message once, applies the Fields and ForceConsole of the call to the
+// entry and leaves them out of the message and the summary.
+func TestCustomOnceArguments(t *testing.T) {
+	d, sink := newOnceDebugger()
+	d.SetOnceSummary(true)
+
+	var calls int
+	for i := 0; i < 3; i++ {
+		d.CustomOnce(ErrorLevel, "pool", "pool exhausted: %s", stringer{&calls}, Fields{"pool": "db"}, ForceConsole)
+	}
+	if calls != 1 {
+		t.Errorf("argument formatted %d times, want once", calls)
+	}
+	d.Close(context.Background())
+
+	want := []string{"pool exhausted: value", "pool exhausted: value (repeated 2 more times)"}
+	if got := sink.messages(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("sink messages = %q, want %q", got, want)
+	}
+	if first := sink.entries[0]; first.Fields["pool"] != "db" || !first.forceConsole {
+		t.Errorf("first entry fields = %v, forced to the console %v, want the fields and the flag of the call",
+			first.Fields, first.forceConsole)
+	}
+	for _, m := range sink.messages() {
+		if strings.Contains(m, "EXTRA") || strings.Contains(m, fmt.Sprint(ForceConsole)) {
+			t.Errorf("message %q contains the marker arguments", m)
+		}
+	}
+}
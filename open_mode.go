@@ -0,0 +1,88 @@
+package mklog
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// Open modes of log files
+const (
+	OpenAppend   = "append"   // Append to an existing log file
+	OpenTruncate = "truncate" // Empty an existing log file when the rule opens it
+)
+
+// WithFileOpenMode sets whether the rule appends to an existing log file, OpenAppend (default), or empties it
+// when opening it, OpenTruncate, e.g. for short-lived tools whose log should only cover the last run. Only the
+// file opened at the start is emptied; files started later by date, folder or interval rotation are created
+// empty anyway. Rules sharing a log file must use the same open mode.
+func WithFileOpenMode(mode string) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.OpenMode = mode
+	}
+}
+
+// validOpenMode checks whether the mode names an open mode; empty selects OpenAppend.
+func validOpenMode(mode string) bool {
+	switch mode {
+	case "", OpenAppend, OpenTruncate:
+		return true
+	}
+	return false
+}
+
+// sharedFile is a log file held open by one or more writers.
+type sharedFile struct {
+	mode    string // Open mode of the writers
+	writers int    // Number of writers holding the file open
+}
+
+// sharedFiles tracks the log files open in the process by their absolute name, so writers sharing a file
+// cannot mix open modes.
+var sharedFiles = struct {
+	sync.Mutex
+	files map[string]*sharedFile
+}{files: make(map[string]*sharedFile)}
+
+// acquireSharedFile registers a writer opening the file with the mode. It fails when the file is held open
+// with another mode.
+func acquireSharedFile(name, mode string) error {
+	if mode == "" {
+		mode = OpenAppend
+	}
+	key := sharedFileKey(name)
+
+	sharedFiles.Lock()
+	defer sharedFiles.Unlock()
+	f, ok := sharedFiles.files[key]
+	if !ok {
+		sharedFiles.files[key] = &sharedFile{mode: mode, writers: 1}
+		return nil
+	}
+	if f.mode != mode {
+		return fmt.Errorf("log file %s is already open in %s mode, cannot open it in %s mode", name, f.mode, mode)
+	}
+	f.writers++
+	return nil
+}
+
+// releaseSharedFile unregisters a writer closing the file.
+func releaseSharedFile(name string) {
+	key := sharedFileKey(name)
+
+	sharedFiles.Lock()
+	defer sharedFiles.Unlock()
+	if f, ok := sharedFiles.files[key]; ok {
+		if f.writers--; f.writers <= 0 {
+			delete(sharedFiles.files, key)
+		}
+	}
+}
+
+// sharedFileKey returns the absolute name of the file, or the cleaned name when it cannot be resolved.
+func sharedFileKey(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
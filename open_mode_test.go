@@ -0,0 +1,111 @@
+package mklog
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runOnce logs the message to the log file in the directory with the open mode, like one run of a tool.
+func runOnce(t *testing.T, dir, mode, msg string) {
+	t.Helper()
+	d := (&Debugger{}).SetQuiet(true)
+	if _, err := d.NewLogRuleE("app", WithFileLogging(dir, "app", ".log"), WithDateFormat("-"), WithFileOpenMode(mode)); err != nil {
+		t.Fatal(err)
+	}
+	d.Info("%s", msg)
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFileOpenModeRuns runs twice against the same log file in each open mode: appending keeps the first run,
+// truncating keeps only the second.
+func TestFileOpenModeRuns(t *testing.T) {
+	tests := []struct {
+		mode, want string
+	}{
+		{"", "- | INFO | [app] : run 1\n- | INFO | [app] : run 2\n"},
+		{OpenAppend, "- | INFO | [app] : run 1\n- | INFO | [app] : run 2\n"},
+		{OpenTruncate, "- | INFO | [app] : run 2\n"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		runOnce(t, dir, tt.mode, "run 1")
+		runOnce(t, dir, tt.mode, "run 2")
+		if got := readFile(t, filepath.Join(dir, "app.log")); got != tt.want {
+			t.Errorf("mode %q: log = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+// TestFileOpenModeShared lets rules share a log file in the same open mode and refuses a rule opening it in
+// another mode until the file is closed.
+func TestFileOpenModeShared(t *testing.T) {
+	dir := t.TempDir()
+	d := (&Debugger{}).SetQuiet(true)
+	if _, err := d.NewLogRuleE("api", WithFileLogging(dir, "app", ".log"), WithFileOpenMode(OpenTruncate)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.NewLogRuleE("db", WithFileLogging(dir, "app", ".log"), WithFileOpenMode(OpenTruncate)); err != nil {
+		t.Errorf("second rule in the same mode: %v", err)
+	}
+	_, err := d.NewLogRuleE("web", WithFileLogging(dir, "app", ".log"))
+	if err == nil || !strings.Contains(err.Error(), "already open in truncate mode, cannot open it in append mode") {
+		t.Errorf("rule in another mode: error = %v, want the mixed modes refused", err)
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	runOnce(t, dir, OpenAppend, "after close")
+}
+
+// TestFileOpenModeInvalid rejects unknown open modes from options and the configuration.
+func TestFileOpenModeInvalid(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	defer d.Close(context.Background())
+	if _, err := d.NewLogRuleE("app", WithFileLogging(t.TempDir(), "app", ".log"), WithFileOpenMode("overwrite")); err == nil ||
+		!strings.Contains(err.Error(), "unsupported open mode: overwrite") {
+		t.Errorf("NewLogRuleE error = %v, want the unsupported open mode", err)
+	}
+
+	_, err := NewLogConfigManager().SetQuiet(true).LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+filepath.ToSlash(t.TempDir())+`, file_name: app, file_type: .log, open_mode: overwrite}
+`))
+	if err == nil || !strings.Contains(err.Error(), "invalid open_mode of rule app: unsupported open mode: overwrite") {
+		t.Errorf("LoadConfig error = %v, want the unsupported open mode", err)
+	}
+}
+
+// TestFileOpenModeConfig truncates the log file of a rule configured with open_mode: truncate.
+func TestFileOpenModeConfig(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	config := writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: app, file_type: .log, open_mode: truncate}
+`)
+	for _, msg := range []string{"run 1", "run 2"} {
+		d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Info("%s", msg)
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); strings.Count(got, "\n") != 1 || !strings.HasSuffix(got, "[app] : run 2\n") {
+		t.Errorf("log = %q, want only the second run", got)
+	}
+}
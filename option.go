@@ -1,6 +1,10 @@
 package mklog
 
-import "time"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 type Option func(*LogRule)
 
@@ -47,6 +51,38 @@ func WithFileLoggingDateFormat(filePath, fileName, fileType, dateFormat string,
 	}
 }
 
+// WithFileWriter enables file logging into the writer, e.g. os.Stdout, instead of log files.
+// The writer bypasses file creation and rotation, so it cannot be combined with date files,
+// time folders, size limits or retention.
+func WithFileWriter(w io.Writer) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.Enable = true
+		lr.FileLog.Writer = w
+	}
+}
+
+// WithWriteBOM starts every log file with a UTF-8 byte order mark, including files started by rotation,
+// for Windows tools that detect the encoding by it.
+func WithWriteBOM(enable bool) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.WriteBOM = enable
+	}
+}
+
+// WithLineEnding sets the line ending of the log file entries, "\n" (default) or "\r\n".
+func WithLineEnding(ending string) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.LineEnding = ending
+	}
+}
+
+// WithCloseFileWriter allows CloseLogFile to close the writer set by WithFileWriter when it implements io.Closer.
+func WithCloseFileWriter(closeWriter bool) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.CloseWriter = closeWriter
+	}
+}
+
 // WithTimeFolder enables folder organization by time period.
 func WithTimeFolder(timeFolderFormat string, folderPeriod time.Duration, isFolderTime bool) Option {
 	return func(lr *LogRule) {
@@ -56,18 +92,30 @@ func WithTimeFolder(timeFolderFormat string, folderPeriod time.Duration, isFolde
 	}
 }
 
-// WithDateFormat sets the date format for logs.
+// WithDateFormat sets the date format for logs, a Go reference layout or a preset name accepted by ResolveDateFormat.
 func WithDateFormat(format string) Option {
 	return func(lr *LogRule) {
-		lr.DateFormat = format
+		lr.DateFormat = resolveDateFormat(format)
 	}
 }
 
-// WithDebugMode enables debug mode with a specified debug level.
+// WithTimestampLocation renders the timestamps of entries in the location, e.g. time.UTC or a zone loaded with
+// time.LoadLocation, so rules of the same process can log in different time zones. Entries passed to sinks and
+// hooks keep their absolute time; nil renders the local time.
+func WithTimestampLocation(loc *time.Location) Option {
+	return func(lr *LogRule) {
+		lr.TimestampLocation = loc
+	}
+}
+
+// WithDebugMode enables debug mode with a specified debug level, setting the Verbosity accordingly.
+//
+// Deprecated: use WithVerbosity.
 func WithDebugMode(debugMode bool, debugLevel LogLevel) Option {
 	return func(lr *LogRule) {
 		lr.DebugMode = debugMode
 		lr.DebugModeStatus = debugLevel
+		lr.syncVerbosity()
 	}
 }
 
@@ -78,6 +126,14 @@ func WithDetailedErrorOutput(enable bool) Option {
 	}
 }
 
+// WithStackAtLevel renders the stack of a DetailedError with detailed error output only for entries at
+// or above the level, ErrorLevel by default; lower entries render the error message only.
+func WithStackAtLevel(level LogLevel) Option {
+	return func(rule *LogRule) {
+		rule.StackAtLevel = level
+	}
+}
+
 // WithLogFormatter sets a custom log formatter.
 func WithLogFormatter(formatter LogFormatter) Option {
 	return func(lr *LogRule) {
@@ -93,6 +149,89 @@ func WithMaxFileSize(maxFileSize int64) Option {
 	}
 }
 
+// WithMaxBackups rotates the log file when the size limit is reached, keeping the given number of backups.
+func WithMaxBackups(maxBackups int) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.MaxBackups = maxBackups
+	}
+}
+
+// WithMaxFileLines rotates the log file before it exceeds maxLines lines, for tools that cannot handle long
+// files regardless of their size. The lines of an existing file are counted when it is opened, so the limit
+// holds across restarts. It composes with the size limit and interval rotation, the first limit reached
+// rotating the file; the number of backups is set with WithMaxBackups, keeping one backup by default.
+// A single write with more lines, e.g. a batch of the async consumer, starts a new file but is not split.
+func WithMaxFileLines(maxLines int64) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.MaxFileLines = maxLines
+	}
+}
+
+// WithCopyTruncateRotation rotates the log file by copying it to the first backup and truncating it instead of
+// renaming it, for files also held open by other processes, e.g. log shippers tailing the file on Windows.
+// Copying costs more than renaming for large files.
+func WithCopyTruncateRotation(enable bool) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.CopyTruncate = enable
+	}
+}
+
+// WithRetention removes rotated and dated log files older than the given age.
+func WithRetention(maxAge time.Duration) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.MaxAge = maxAge
+	}
+}
+
+// WithMaxDateFiles keeps the newest n dated log files besides the active one, ordered by the date in
+// their names. Older dated files are deleted at startup and on each daily rollover. It applies to rules
+// using dated files without time folders.
+func WithMaxDateFiles(n int) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.MaxDateFiles = n
+	}
+}
+
+// WithStreamingCompression writes gzip-compressed log files, flushing the stream periodically so the tail stays readable.
+// A size limit must rotate the file with WithMaxBackups, since compressed files cannot be trimmed.
+func WithStreamingCompression(enable bool) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.StreamingCompression = enable
+	}
+}
+
+// WithRotationInterval starts a new log file every interval, aligned to wall-clock boundaries
+// (on the hour for time.Hour). The file names are prefixed with the start of the period and
+// the size limit and retention keep applying within and across periods.
+func WithRotationInterval(interval time.Duration) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.RotationInterval = interval
+	}
+}
+
+// WithClock sets the source of the current time used for file management, e.g. a fake clock in tests.
+func WithClock(now func() time.Time) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.now = now
+	}
+}
+
+// WithFatalExitCode makes Fatal entries accepted by the rule terminate the process with the exit code
+// after the Debugger is flushed and the OnFatal callback has run. Zero keeps the process running.
+func WithFatalExitCode(code int) Option {
+	return func(lr *LogRule) {
+		lr.FatalExitCode = code
+	}
+}
+
+// WithContext shuts the rule down when the context is cancelled: the async buffer is flushed,
+// the log file and sinks are closed and logging to the rule becomes a no-op.
+func WithContext(ctx context.Context) Option {
+	return func(lr *LogRule) {
+		lr.ctx = ctx
+	}
+}
+
 // WithConsoleOutput enables or disables console output for logs.
 func WithConsoleOutput(consoleOutput bool) Option {
 	return func(lr *LogRule) {
@@ -100,6 +239,17 @@ func WithConsoleOutput(consoleOutput bool) Option {
 	}
 }
 
+// WithConsoleOnce skips the console output of an entry another rule of the Debugger printed already, so an
+// entry matching several rules with console output, e.g. at the overlapping level of an application and an
+// error rule, is printed once. The rules are evaluated by module name and within a module in the order they
+// were added; the first rule printing the entry wins. Rules built by NewLogRule and the configuration enable
+// it by default; rules added with AddRule keep the value of their ConsoleOnce field.
+func WithConsoleOnce(once bool) Option {
+	return func(lr *LogRule) {
+		lr.ConsoleOnce = once
+	}
+}
+
 // WithFormatter sets a log formatter (duplicate of WithLogFormatter).
 func WithForrmatter(formatter LogFormatter) Option {
 	return func(lr *LogRule) {
@@ -114,3 +264,34 @@ func WithAsyncLog(enable bool, bufferSize int) Option {
 		lr.AsyncLog.BufferSize = bufferSize
 	}
 }
+
+// WithAsyncFlushInterval flushes buffered file writers of the async consumer at the interval while messages
+// were written since the last flush, so a lone message does not wait in a buffer when traffic stops.
+func WithAsyncFlushInterval(interval time.Duration) Option {
+	return func(lr *LogRule) {
+		lr.AsyncLog.FlushInterval = interval
+	}
+}
+
+// WithHook attaches a hook notified about the entries accepted by the rule.
+func WithHook(hook Hook) Option {
+	return func(lr *LogRule) {
+		lr.AddHook(hook)
+	}
+}
+
+// WithSink attaches an additional output receiving the entries accepted by the rule.
+func WithSink(sink Sink) Option {
+	return func(lr *LogRule) {
+		lr.AddSink(sink)
+	}
+}
+
+// withSinks attaches every sink in the slice to the rule.
+func withSinks(sinks []Sink) Option {
+	return func(lr *LogRule) {
+		for _, sink := range sinks {
+			lr.AddSink(sink)
+		}
+	}
+}
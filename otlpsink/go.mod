@@ -0,0 +1,26 @@
+module github.com/SHEP4RDO/mklog/otlpsink
+
+go 1.20
+
+require (
+	github.com/SHEP4RDO/mklog v0.1.0
+	go.opentelemetry.io/proto/otlp v1.0.0
+	google.golang.org/grpc v1.56.3
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.16.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20230530153820-e85fd2cbaebc // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230530153820-e85fd2cbaebc // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// Builds inside the repository use the root module next to this one; dependents resolve the required release,
+// which must be tagged before this module is.
+replace github.com/SHEP4RDO/mklog => ../
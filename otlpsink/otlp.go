@@ -0,0 +1,341 @@
+// Package otlpsink provides an mklog sink exporting entries as OpenTelemetry log records over OTLP/gRPC.
+//
+// It lives in its own module so the core package does not depend on OpenTelemetry. Entries are buffered
+// and exported in batches at the export interval or when a batch is full; failed exports are retried with
+// an exponential backoff and the buffer is bounded, so logging never blocks the application.
+package otlpsink
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+var (
+	// Default settings of the OTLP sink
+	ExportIntervalDefault = time.Second            // Default interval between exports
+	MaxBatchSizeDefault   = 512                    // Default number of records in a single export
+	QueueSizeDefault      = 8192                   // Default number of buffered entries before dropping
+	MaxRetriesDefault     = 3                      // Default number of retries of a failed export
+	RetryBackoffDefault   = 200 * time.Millisecond // Default delay before the first retry, doubled on each retry
+	ExportTimeoutDefault  = 10 * time.Second       // Default timeout of a single export
+)
+
+// Fields carrying the trace context of an entry as hex strings, see TraceEnricher.
+const (
+	TraceIDField = "trace_id"
+	SpanIDField  = "span_id"
+)
+
+// ScopeName is the name of the instrumentation scope of the exported records.
+const ScopeName = "github.com/SHEP4RDO/mklog"
+
+// Options configures the OTLP sink.
+type Options struct {
+	ServiceName    string             // Value of the service.name resource attribute.
+	Resource       map[string]string  // Additional resource attributes.
+	MaxBatchSize   int                // Maximum number of records of one export.
+	ExportInterval time.Duration      // Interval between exports.
+	QueueSize      int                // Maximum number of buffered entries, further entries are dropped.
+	MaxRetries     int                // Number of retries of a failed export; negative disables retries.
+	RetryBackoff   time.Duration      // Delay before the first retry, doubled on each retry.
+	ExportTimeout  time.Duration      // Timeout of a single export.
+	ErrorHandler   mklog.ErrorHandler // Handler for failed exports, see mklog.ErrorHandler.Report.
+}
+
+// Sink exports log entries to an OTLP collector.
+type Sink struct {
+	client   collogspb.LogsServiceClient
+	options  Options
+	resource *resourcepb.Resource
+	batch    *mklog.Batcher[mklog.Entry] // Buffer exported in batches by a background goroutine
+}
+
+// New creates a sink exporting through the client, e.g. collogspb.NewLogsServiceClient(conn).
+func New(client collogspb.LogsServiceClient, options Options) (*Sink, error) {
+	if client == nil {
+		return nil, fmt.Errorf("otlp sink requires a client")
+	}
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = MaxBatchSizeDefault
+	}
+	if options.ExportInterval <= 0 {
+		options.ExportInterval = ExportIntervalDefault
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = QueueSizeDefault
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	} else if options.MaxRetries == 0 {
+		options.MaxRetries = MaxRetriesDefault
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = RetryBackoffDefault
+	}
+	if options.ExportTimeout <= 0 {
+		options.ExportTimeout = ExportTimeoutDefault
+	}
+
+	s := &Sink{
+		client:   client,
+		options:  options,
+		resource: newResource(options),
+	}
+	s.batch = mklog.NewBatcher(mklog.BatcherOptions{
+		Name:          "otlp sink",
+		FlushInterval: options.ExportInterval,
+		BatchSize:     options.MaxBatchSize,
+		QueueSize:     options.QueueSize,
+		MaxRetries:    options.MaxRetries,
+		RetryBackoff:  options.RetryBackoff,
+		ErrorHandler:  options.ErrorHandler,
+	}, s.export)
+	return s, nil
+}
+
+// Write buffers the entry until the next export, dropping it when the buffer is full.
+func (s *Sink) Write(entry mklog.Entry, formatted string) error {
+	s.batch.Add(entry)
+	return nil
+}
+
+// Dropped returns the number of entries dropped because the buffer was full.
+func (s *Sink) Dropped() uint64 {
+	return s.batch.Dropped()
+}
+
+// Flush exports the buffered entries in batches of at most MaxBatchSize records, retrying failed exports.
+// Entries of a batch that could not be exported stay buffered for the next flush.
+func (s *Sink) Flush() error {
+	return s.batch.Flush()
+}
+
+// Close stops the sink and exports the remaining entries. The client connection is left open.
+func (s *Sink) Close() error {
+	return s.batch.Close()
+}
+
+// SetErrorHandler sets the handler receiving failed exports.
+func (s *Sink) SetErrorHandler(handler mklog.ErrorHandler) {
+	s.batch.SetErrorHandler(handler)
+}
+
+// export sends one batch to the collector.
+func (s *Sink) export(batch []mklog.Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.options.ExportTimeout)
+	defer cancel()
+
+	_, err := s.client.Export(ctx, s.request(batch))
+	return err
+}
+
+// request builds the export request of the batch.
+func (s *Sink) request(batch []mklog.Entry) *collogspb.ExportLogsServiceRequest {
+	records := make([]*logspb.LogRecord, 0, len(batch))
+	for _, e := range batch {
+		records = append(records, Record(e))
+	}
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: s.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: ScopeName},
+				LogRecords: records,
+			}},
+		}},
+	}
+}
+
+// newResource builds the resource of the exported records.
+func newResource(options Options) *resourcepb.Resource {
+	attrs := make(map[string]interface{}, len(options.Resource)+1)
+	for k, v := range options.Resource {
+		attrs[k] = v
+	}
+	if options.ServiceName != "" {
+		attrs["service.name"] = options.ServiceName
+	}
+	return &resourcepb.Resource{Attributes: attributes(attrs)}
+}
+
+// Record converts the entry into an OTLP log record. The module, submodules and error of the entry
+// become attributes next to its fields; the trace_id and span_id fields set the trace context.
+func Record(e mklog.Entry) *logspb.LogRecord {
+	attrs := make(map[string]interface{}, len(e.Fields)+3)
+	for k, v := range e.Fields {
+		attrs[k] = v
+	}
+	traceID := hexID(attrs, TraceIDField, 16)
+	spanID := hexID(attrs, SpanIDField, 8)
+	if e.Module != "" {
+		attrs["mklog.module"] = e.Module
+	}
+	if len(e.Submodules) > 0 {
+		attrs["mklog.submodules"] = e.Submodules
+	}
+	if e.Err != nil {
+		attrs["exception.message"] = e.Err.Error()
+		if detailed, ok := e.Err.(mklog.DetailedError); ok && detailed.StackInfo != "" {
+			attrs["exception.stacktrace"] = detailed.StackInfo
+		} else if stack := mklog.OriginStack(e.Err); stack != "" {
+			attrs["exception.stacktrace"] = stack
+		}
+	}
+
+	return &logspb.LogRecord{
+		TimeUnixNano:         uint64(e.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       SeverityNumber(e.Level),
+		SeverityText:         e.LevelName,
+		Body:                 stringValue(e.Message),
+		Attributes:           attributes(attrs),
+		TraceId:              traceID,
+		SpanId:               spanID,
+	}
+}
+
+// SeverityNumber maps the log level onto the OTLP severity number, using the syslog severity of custom levels.
+func SeverityNumber(level mklog.LogLevel) logspb.SeverityNumber {
+	switch level {
+	case mklog.TraceLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE
+	case mklog.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case mklog.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case mklog.WarningLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case mklog.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case mklog.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	}
+
+	switch level.SyslogSeverity() {
+	case mklog.SyslogEmergency, mklog.SyslogAlert, mklog.SyslogCritical:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case mklog.SyslogError:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case mklog.SyslogWarning:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case mklog.SyslogNotice:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO2
+	case mklog.SyslogInformational:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	}
+}
+
+// TraceEnricher returns an enricher storing the trace context extracted from the context of the *Ctx log
+// methods in the trace_id and span_id fields, e.g. with the span context of the OpenTelemetry SDK:
+//
+//	mklog.RegisterEnricher(otlpsink.TraceEnricher(func(ctx context.Context) (string, string) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		return sc.TraceID().String(), sc.SpanID().String()
+//	}))
+func TraceEnricher(extract func(ctx context.Context) (traceID, spanID string)) mklog.Enricher {
+	return func(ctx context.Context, e *mklog.Entry) {
+		if ctx == nil {
+			return
+		}
+		traceID, spanID := extract(ctx)
+		if traceID != "" {
+			e.Fields[TraceIDField] = traceID
+		}
+		if spanID != "" {
+			e.Fields[SpanIDField] = spanID
+		}
+	}
+}
+
+// hexID removes the hex encoded ID of the given byte length from the attributes and returns it decoded.
+// Values that are not valid IDs are kept as attributes.
+func hexID(attrs map[string]interface{}, key string, size int) []byte {
+	s, ok := attrs[key].(string)
+	if !ok {
+		return nil
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil || len(id) != size {
+		return nil
+	}
+	delete(attrs, key)
+	return id
+}
+
+// attributes converts the values into OTLP attributes ordered by key.
+func attributes(values map[string]interface{}) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]*commonpb.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, &commonpb.KeyValue{Key: k, Value: anyValue(values[k])})
+	}
+	return attrs
+}
+
+// anyValue converts a field value into an OTLP value, rendering unsupported types as text.
+func anyValue(v interface{}) *commonpb.AnyValue {
+	switch v := v.(type) {
+	case string:
+		return stringValue(v)
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return intValue(int64(v))
+	case int8:
+		return intValue(int64(v))
+	case int16:
+		return intValue(int64(v))
+	case int32:
+		return intValue(int64(v))
+	case int64:
+		return intValue(v)
+	case uint8:
+		return intValue(int64(v))
+	case uint16:
+		return intValue(int64(v))
+	case uint32:
+		return intValue(int64(v))
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(v)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []string:
+		values := make([]*commonpb.AnyValue, 0, len(v))
+		for _, s := range v {
+			values = append(values, stringValue(s))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case error:
+		return stringValue(v.Error())
+	case fmt.Stringer:
+		return stringValue(v.String())
+	default:
+		return stringValue(fmt.Sprint(v))
+	}
+}
+
+// stringValue returns an OTLP string value.
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+// intValue returns an OTLP integer value.
+func intValue(i int64) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: i}}
+}
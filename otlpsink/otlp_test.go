@@ -0,0 +1,315 @@
+package otlpsink
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+)
+
+// stubCollector is an in-memory LogsServiceClient failing the first exports it is told to fail.
+type stubCollector struct {
+	mu       sync.Mutex
+	failures int         // Number of exports still to fail
+	calls    []time.Time // Times of all exports, failed ones included
+	requests []*collogspb.ExportLogsServiceRequest
+}
+
+func (c *stubCollector) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest, opts ...grpc.CallOption) (*collogspb.ExportLogsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, time.Now())
+	if c.failures > 0 {
+		c.failures--
+		return nil, errors.New("collector unavailable")
+	}
+	c.requests = append(c.requests, req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+// setFailures makes the next n exports fail.
+func (c *stubCollector) setFailures(n int) {
+	c.mu.Lock()
+	c.failures = n
+	c.mu.Unlock()
+}
+
+// batches returns the bodies of the exported records, one slice per export.
+func (c *stubCollector) batches() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var batches [][]string
+	for _, req := range c.requests {
+		var bodies []string
+		for _, r := range req.ResourceLogs[0].ScopeLogs[0].LogRecords {
+			bodies = append(bodies, r.Body.GetStringValue())
+		}
+		batches = append(batches, bodies)
+	}
+	return batches
+}
+
+// exported returns the bodies of all exported records in export order.
+func (c *stubCollector) exported() []string {
+	var bodies []string
+	for _, batch := range c.batches() {
+		bodies = append(bodies, batch...)
+	}
+	return bodies
+}
+
+// entry returns an Info entry with the message.
+func entry(msg string) mklog.Entry {
+	return mklog.Entry{Time: time.Now(), Level: mklog.InfoLevel, LevelName: "INFO", Message: msg}
+}
+
+// TestBatching exports the entries in order in batches of at most MaxBatchSize records, with the resource and
+// scope of the sink.
+func TestBatching(t *testing.T) {
+	c := &stubCollector{}
+	s, err := New(c, Options{ServiceName: "checkout", MaxBatchSize: 3, ExportInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1", "2", "3", "4", "5", "6", "7"}
+	for _, msg := range want {
+		s.Write(entry(msg), "")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, batch := range c.batches() {
+		if len(batch) > 3 {
+			t.Errorf("batch %q exceeds MaxBatchSize", batch)
+		}
+	}
+	if got := c.exported(); len(got) != len(want) || got[0] != "1" || got[6] != "7" {
+		t.Errorf("exported = %q, want %q", got, want)
+	}
+	req := c.requests[0].ResourceLogs[0]
+	if attr := req.Resource.Attributes[0]; attr.Key != "service.name" || attr.Value.GetStringValue() != "checkout" {
+		t.Errorf("resource = %v, want service.name checkout", req.Resource.Attributes)
+	}
+	if req.ScopeLogs[0].Scope.Name != ScopeName {
+		t.Errorf("scope = %q, want %q", req.ScopeLogs[0].Scope.Name, ScopeName)
+	}
+}
+
+// TestExportInterval exports buffered entries on the interval without a full batch.
+func TestExportInterval(t *testing.T) {
+	c := &stubCollector{}
+	s, _ := New(c, Options{ExportInterval: 10 * time.Millisecond})
+	defer s.Close()
+	s.Write(entry("tick"), "")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(c.exported()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("entry not exported on the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestRetryBackoff retries failed exports with a doubling delay until one succeeds.
+func TestRetryBackoff(t *testing.T) {
+	const backoff = 20 * time.Millisecond
+	c := &stubCollector{failures: 2}
+	s, _ := New(c, Options{ExportInterval: time.Hour, MaxRetries: 3, RetryBackoff: backoff})
+	defer s.Close()
+	s.Write(entry("retried"), "")
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() = %v, want the third attempt to succeed", err)
+	}
+	if len(c.calls) != 3 {
+		t.Fatalf("exports = %d, want 3", len(c.calls))
+	}
+	if gap := c.calls[1].Sub(c.calls[0]); gap < backoff {
+		t.Errorf("first retry after %v, want at least %v", gap, backoff)
+	}
+	if gap := c.calls[2].Sub(c.calls[1]); gap < 2*backoff {
+		t.Errorf("second retry after %v, want at least %v", gap, 2*backoff)
+	}
+	if got := c.exported(); len(got) != 1 || got[0] != "retried" {
+		t.Errorf("exported = %q, want the entry once", got)
+	}
+}
+
+// TestRetryExhausted keeps the entries of a batch whose retries failed buffered for the next flush.
+func TestRetryExhausted(t *testing.T) {
+	c := &stubCollector{failures: 2}
+	s, _ := New(c, Options{ExportInterval: time.Hour, MaxRetries: 1, RetryBackoff: time.Millisecond})
+	defer s.Close()
+	s.Write(entry("first"), "")
+	s.Write(entry("second"), "")
+
+	if err := s.Flush(); err == nil {
+		t.Fatal("Flush() = nil, want the error of the last attempt")
+	}
+	if len(c.calls) != 2 || len(c.exported()) != 0 {
+		t.Fatalf("exports = %d with %q exported, want 2 failed attempts", len(c.calls), c.exported())
+	}
+	s.Write(entry("third"), "")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.exported(); len(got) != 3 || got[0] != "first" || got[2] != "third" {
+		t.Errorf("exported = %q, want the requeued entries before the new one", got)
+	}
+}
+
+// TestBoundedBuffer drops the entries exceeding QueueSize, counting them in Dropped, also when failed batches
+// are requeued.
+func TestBoundedBuffer(t *testing.T) {
+	c := &stubCollector{}
+	s, _ := New(c, Options{ExportInterval: time.Hour, QueueSize: 2, MaxRetries: -1})
+	for _, msg := range []string{"1", "2", "3", "4", "5"} {
+		s.Write(entry(msg), "")
+	}
+	if got := s.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want 3", got)
+	}
+
+	c.setFailures(1)
+	if err := s.Flush(); err == nil {
+		t.Fatal("Flush() = nil, want the failed export")
+	}
+	s.Write(entry("6"), "") // The requeued entries fill the buffer.
+	if got := s.Dropped(); got != 4 {
+		t.Errorf("Dropped() = %d after the requeue, want 4", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.exported(); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("exported = %q, want the first two entries", got)
+	}
+}
+
+// TestExportErrorsReported passes the error of a failed background export to the error handler.
+func TestExportErrorsReported(t *testing.T) {
+	c := &stubCollector{failures: 1 << 30}
+	errs := make(chan error, 8)
+	s, _ := New(c, Options{ExportInterval: 5 * time.Millisecond, MaxRetries: -1,
+		ErrorHandler: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}})
+	s.Write(entry("lost"), "")
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "collector unavailable") {
+			t.Errorf("error = %v, want the export error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("export error not reported")
+	}
+	c.setFailures(0)
+	s.Close()
+}
+
+// TestSeverityNumber maps the built-in levels onto their OTLP severities and custom levels by their syslog
+// severity.
+func TestSeverityNumber(t *testing.T) {
+	const audit, alarm mklog.LogLevel = 40, 41
+	if err := mklog.RegisterLevel(audit, "AUDIT", mklog.SyslogNotice); err != nil {
+		t.Fatal(err)
+	}
+	if err := mklog.RegisterLevel(alarm, "ALARM", mklog.SyslogCritical); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		level mklog.LogLevel
+		want  logspb.SeverityNumber
+	}{
+		{mklog.TraceLevel, logspb.SeverityNumber_SEVERITY_NUMBER_TRACE},
+		{mklog.DebugLevel, logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG},
+		{mklog.InfoLevel, logspb.SeverityNumber_SEVERITY_NUMBER_INFO},
+		{mklog.WarningLevel, logspb.SeverityNumber_SEVERITY_NUMBER_WARN},
+		{mklog.ErrorLevel, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR},
+		{mklog.FatalLevel, logspb.SeverityNumber_SEVERITY_NUMBER_FATAL},
+		{audit, logspb.SeverityNumber_SEVERITY_NUMBER_INFO2},
+		{alarm, logspb.SeverityNumber_SEVERITY_NUMBER_FATAL},
+	}
+	for _, tt := range tests {
+		if got := SeverityNumber(tt.level); got != tt.want {
+			t.Errorf("SeverityNumber(%s) = %v, want %v", tt.level.GetLogLevelName(), got, tt.want)
+		}
+	}
+}
+
+// TestRecordTraceContext moves valid trace and span IDs from the fields into the trace context of the record
+// and keeps invalid ones as attributes.
+func TestRecordTraceContext(t *testing.T) {
+	e := entry("traced")
+	e.Fields = map[string]interface{}{
+		TraceIDField: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanIDField:  "00f067aa0ba902b7",
+		"user":       "alice",
+	}
+	r := Record(e)
+	if got := r.TraceId; len(got) != 16 || got[0] != 0x4b || got[15] != 0x36 {
+		t.Errorf("TraceId = %x, want the decoded trace_id", got)
+	}
+	if got := r.SpanId; len(got) != 8 || got[0] != 0x00 || got[7] != 0xb7 {
+		t.Errorf("SpanId = %x, want the decoded span_id", got)
+	}
+	for _, attr := range r.Attributes {
+		if attr.Key == TraceIDField || attr.Key == SpanIDField {
+			t.Errorf("attribute %s kept next to the trace context", attr.Key)
+		}
+	}
+
+	e.Fields = map[string]interface{}{TraceIDField: "not-hex", SpanIDField: "00f0"}
+	r = Record(e)
+	if r.TraceId != nil || r.SpanId != nil || len(r.Attributes) != 2 {
+		t.Errorf("record = %v, want invalid IDs kept as attributes", r)
+	}
+}
+
+// traceKey is the context key of the trace IDs of the tests.
+type traceKey struct{}
+
+// TestTraceEnricher exports the trace context extracted from the context of a log call.
+func TestTraceEnricher(t *testing.T) {
+	t.Cleanup(mklog.RegisterEnricher(TraceEnricher(func(ctx context.Context) (string, string) {
+		ids, _ := ctx.Value(traceKey{}).([2]string)
+		return ids[0], ids[1]
+	})))
+	c := &stubCollector{}
+	s, _ := New(c, Options{ExportInterval: time.Hour})
+	d := (&mklog.Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", mklog.WithSink(s))
+
+	ctx := context.WithValue(context.Background(), traceKey{}, [2]string{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"})
+	d.InfoCtx(ctx, "traced")
+	d.Info("untraced")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	records := c.requests[0].ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("records = %d, want 2", len(records))
+	}
+	if len(records[0].TraceId) != 16 || len(records[0].SpanId) != 8 {
+		t.Errorf("traced record has trace %x and span %x, want both set", records[0].TraceId, records[0].SpanId)
+	}
+	if records[1].TraceId != nil || records[1].SpanId != nil {
+		t.Errorf("untraced record has trace %x and span %x, want none", records[1].TraceId, records[1].SpanId)
+	}
+	if records[0].SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_INFO || records[0].SeverityText != "INFO" {
+		t.Errorf("severity = %v %q, want INFO", records[0].SeverityNumber, records[0].SeverityText)
+	}
+}
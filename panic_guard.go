@@ -0,0 +1,89 @@
+package mklog
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// Consecutive panics of the formatter of a rule after which it is replaced by the fallback formatter
+	MKLOG_FormatterPanicLimit = 5
+)
+
+// fallbackFormatter formats the entries of rules whose formatter panicked.
+var fallbackFormatter LogFormatter = &PlainTextFormatter{dateFormat: "02.01.2006"}
+
+// safeFormat formats the log message with the formatter of the rule, recovering from its panics. An entry the
+// formatter panicked on is formatted with the fallback formatter instead, and after MKLOG_FormatterPanicLimit
+// consecutive panics the formatter is no longer used by the rule until it is replaced with SetLogFormatter.
+func (lr *LogRule) safeFormat(logged time.Time, logMessage string, logLevel LogLevel, fields map[string]interface{}) string {
+	if atomic.LoadInt32(&lr.formatterDisabled) != 0 {
+		return lr.formatWith(fallbackFormatter, logged, logMessage, logLevel, fields)
+	}
+	if msg, ok := lr.tryFormat(logged, logMessage, logLevel, fields); ok {
+		return msg
+	}
+	return lr.formatWith(fallbackFormatter, logged, logMessage, logLevel, fields)
+}
+
+// tryFormat formats the log message with the formatter of the rule, reporting whether it returned.
+func (lr *LogRule) tryFormat(logged time.Time, logMessage string, logLevel LogLevel, fields map[string]interface{}) (msg string, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			lr.formatterPanicked(r, debug.Stack())
+		}
+	}()
+	msg = lr.formatWith(lr.LogFormatter, logged, logMessage, logLevel, fields)
+	if atomic.LoadInt32(&lr.formatterPanics) != 0 {
+		atomic.StoreInt32(&lr.formatterPanics, 0)
+	}
+	return msg, true
+}
+
+// formatterPanicked reports a panic of the formatter and disables it after too many consecutive panics.
+func (lr *LogRule) formatterPanicked(r interface{}, stack []byte) {
+	lr.countPanic()
+	lr.deferError(fmt.Errorf("[mklog] formatter of %s panicked: %v\n%s", lr.ModuleName, r, stack))
+
+	panics := atomic.AddInt32(&lr.formatterPanics, 1)
+	if int(panics) < MKLOG_FormatterPanicLimit || !atomic.CompareAndSwapInt32(&lr.formatterDisabled, 0, 1) {
+		return
+	}
+	msg := fmt.Sprintf("[mklog] formatter %T of %s disabled after %d consecutive panics, using PlainTextFormatter",
+		lr.LogFormatter, lr.ModuleName, panics)
+	if lr.debugger == nil {
+		fmt.Println(msg)
+		return
+	}
+	lr.deferError(&Notice{Level: ErrorLevel, Module: lr.ModuleName, Message: msg})
+}
+
+// resetFormatterPanics enables the formatter of the rule again after it was replaced.
+func (lr *LogRule) resetFormatterPanics() {
+	atomic.StoreInt32(&lr.formatterPanics, 0)
+	atomic.StoreInt32(&lr.formatterDisabled, 0)
+}
+
+// fireHook passes the entry to the hook, converting a panic of the hook into an error.
+func (lr *LogRule) fireHook(hook Hook, entry Entry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			lr.countPanic()
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return hook.Fire(entry)
+}
+
+// writeSink writes the entry to the sink, converting a panic of the sink into an error.
+func (lr *LogRule) writeSink(sink Sink, entry Entry, formatted string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			lr.countPanic()
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return sink.Write(entry, formatted)
+}
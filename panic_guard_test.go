@@ -0,0 +1,168 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errorLog collects the errors passed to the error handler of a Debugger.
+type errorLog struct {
+	mu   sync.Mutex
+	errs []string
+}
+
+func (l *errorLog) handle(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err.Error())
+}
+
+// count returns the number of errors containing the text.
+func (l *errorLog) count(text string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, err := range l.errs {
+		if strings.Contains(err, text) {
+			n++
+		}
+	}
+	return n
+}
+
+// explodingFormat is a user-defined formatter panicking on messages containing "boom".
+func explodingFormat(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	if strings.Contains(logMessage, "boom") {
+		panic("formatter exploded on " + logMessage)
+	}
+	return "custom " + logLevel + " " + logMessage
+}
+
+// TestFormatterPanicFallback formats the entry a user-defined formatter panicked on with the plain text
+// formatter, so it still reaches the log file, reports the panic with its stack and counts it.
+func TestFormatterPanicFallback(t *testing.T) {
+	dir := t.TempDir()
+	errs := &errorLog{}
+	d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC) })
+	d.SetErrorHandler(errs.handle)
+	d.NewLogRule("app", WithFileLogging(dir, "app", ".log"), WithDateFormat("15:04:05"))
+	rule := d.rules()["app"][0]
+	rule.SetUserDefinedFormatter(explodingFormat)
+
+	d.Info("before")
+	d.Warning("boom in the formatter")
+	d.Info("after")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "custom INFO before\n10:20:30 | WARNING | [app] : boom in the formatter\ncustom INFO after\n"
+	if string(data) != want {
+		t.Errorf("log file = %q, want %q", data, want)
+	}
+	if errs.count("[mklog] formatter of app panicked: formatter exploded on boom in the formatter") != 1 ||
+		errs.count("panic_guard_test.go") != 1 {
+		t.Errorf("errors = %q, want the panic with its stack", errs.errs)
+	}
+	if s := rule.Stats(); s.Panics != 1 {
+		t.Errorf("panics = %d, want 1", s.Panics)
+	}
+}
+
+// TestFormatterDisabledAfterPanics stops using a formatter after MKLOG_FormatterPanicLimit consecutive panics
+// with a notice, counts only consecutive panics and uses the formatter again once it is replaced.
+func TestFormatterDisabledAfterPanics(t *testing.T) {
+	defer func(limit int) { MKLOG_FormatterPanicLimit = limit }(MKLOG_FormatterPanicLimit)
+	MKLOG_FormatterPanicLimit = 3
+
+	out := &syncBuffer{}
+	errs := &errorLog{}
+	d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC) })
+	d.SetErrorHandler(errs.handle)
+	d.NewLogRule("app", WithFileWriter(out), WithDateFormat("15:04:05"))
+	rule := d.rules()["app"][0]
+	rule.SetUserDefinedFormatter(explodingFormat)
+
+	d.Info("boom 1")
+	d.Info("boom 2")
+	d.Info("fine") // Resets the consecutive panics.
+	d.Info("boom 3")
+	d.Info("boom 4")
+	if errs.count("disabled after") != 0 {
+		t.Fatalf("formatter disabled before %d consecutive panics: %q", MKLOG_FormatterPanicLimit, errs.errs)
+	}
+	d.Info("boom 5")
+	if errs.count("formatter mklog.UserDefinedFormatter of app disabled after 3 consecutive panics") != 1 {
+		t.Fatalf("errors = %q, want the notice of the disabled formatter", errs.errs)
+	}
+	d.Info("not formatted by the user")
+	rule.SetUserDefinedFormatter(explodingFormat)
+	d.Info("formatted again")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	log := out.String()
+	for _, want := range []string{
+		"custom INFO fine\n",
+		"10:20:30 | INFO | [app] : boom 5\n",
+		"10:20:30 | INFO | [app] : not formatted by the user\n",
+		"custom INFO formatted again\n",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log does not contain %q:\n%s", want, log)
+		}
+	}
+	if s := rule.Stats(); s.Panics != 5 {
+		t.Errorf("panics = %d, want 5", s.Panics)
+	}
+}
+
+// panickingHook panics on every entry.
+type panickingHook struct{}
+
+func (panickingHook) Levels() []LogLevel { return []LogLevel{InfoLevel} }
+func (panickingHook) Fire(Entry) error   { panic("hook exploded") }
+
+// panickingSink panics on every entry.
+type panickingSink struct{}
+
+func (panickingSink) Write(Entry, string) error { panic(errors.New("sink exploded")) }
+
+func (panickingSink) Close() error { return nil }
+
+// TestHookAndSinkPanics reports and counts panics of hooks and sinks while the entry still reaches the file.
+func TestHookAndSinkPanics(t *testing.T) {
+	out := &syncBuffer{}
+	errs := &errorLog{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(errs.handle)
+	d.NewLogRule("app", WithFileWriter(out), WithSink(panickingSink{}))
+	rule := d.rules()["app"][0]
+	rule.AddHook(panickingHook{})
+
+	d.Info("survives")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "survives") {
+		t.Errorf("log = %q, want the entry", out.String())
+	}
+	if errs.count("hook exploded") != 1 || errs.count("sink exploded") != 1 {
+		t.Errorf("errors = %q, want the panics of the hook and the sink", errs.errs)
+	}
+	if s := rule.Stats(); s.Panics != 2 {
+		t.Errorf("panics = %d, want 2", s.Panics)
+	}
+}
@@ -0,0 +1,143 @@
+package mklog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Plan describes the rules LoadConfig would create from a configuration file, see LogConfigManager.Plan.
+type Plan struct {
+	File    string        `json:"file"`    // Configuration file the plan was made from
+	Rules   []PlannedRule `json:"rules"`   // Rules ordered by module and index, as Debugger.Rules orders them
+	Skipped []string      `json:"skipped"` // Rules without console, file or sink output as module/position, which are not created
+	Notices []string      `json:"notices"` // Notices LoadConfig would pass to the error handler
+}
+
+// PlannedRule describes a rule LoadConfig would create. The embedded RuleInfo matches the Debugger.Rules entry of
+// the created rule; its File is the log file for the time the plan was made.
+type PlannedRule struct {
+	RuleInfo
+	Verbosity  LogLevel   `json:"verbosity"`   // Lowest level logged in addition to MinLevel
+	DateFormat string     `json:"date_format"` // Timestamp format of the entries
+	FilePath   string     `json:"file_path"`   // Directory of the log files after defaulting
+	FileName   string     `json:"file_name"`   // Base name of the log files after defaulting
+	FileType   string     `json:"file_type"`   // Extension of the log files after defaulting
+	Folder     FileFolder `json:"folder"`      // Time folder settings
+	AsyncLog   AsyncLog   `json:"async_log"`   // Async settings after defaulting
+	SinkTypes  []string   `json:"sink_types"`  // Types of the sinks in the order of the configuration
+	Hooks      int        `json:"hooks"`       // Number of hooks
+}
+
+// Plan reads the configuration file like LoadConfig and describes the rules it would create, without creating
+// log files, sinks or goroutines. The rules are validated and defaulted by the same code as in LoadConfig, so a
+// file LoadConfig rejects makes Plan fail with the same error, except for errors raised by sink factories. The
+// notices, including the warnings about unknown keys, are collected in the plan instead of being passed to the
+// error handler.
+func (m *LogConfigManager) Plan(filePath string) (Plan, error) {
+	config, unknown, err := m.readConfig(filePath)
+	if err != nil {
+		return Plan{}, err
+	}
+	defaults := m.getDefaults()
+	plan := Plan{File: filePath}
+	debugger := m.newConfigDebugger()
+	debugger.SetErrorHandler(func(err error) {
+		plan.Notices = append(plan.Notices, err.Error())
+	})
+	warnUnknownKeys(debugger, unknown)
+
+	modules := make([]string, 0, len(config.LogRules))
+	for module := range config.LogRules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	for _, module := range modules {
+		index := 0
+		for position, rule := range config.LogRules[module] {
+			opts, ok, err := m.ruleOptions(debugger, module, rule, defaults, true)
+			if err != nil {
+				return Plan{}, err
+			}
+			if !ok {
+				plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s/%d", module, position))
+				continue
+			}
+			lr := debugger.buildLogRule(module, opts...)
+			plan.Rules = append(plan.Rules, lr.plan(module, index, rule.Sinks))
+			index++
+		}
+	}
+	return plan, nil
+}
+
+// plan describes the rule built but not started by Plan.
+func (d *LogRule) plan(module string, index int, sinks []SinkConf) PlannedRule {
+	planned := PlannedRule{
+		RuleInfo:   d.info(module, index),
+		Verbosity:  d.Verbosity,
+		DateFormat: d.DateFormat,
+		Hooks:      len(d.hooks()) + len(d.debugger.attachedHooks()),
+	}
+	planned.Sinks = len(sinks)
+	for _, sink := range sinks {
+		planned.SinkTypes = append(planned.SinkTypes, sink.Type)
+	}
+	if d.FileLog.Enable {
+		options := d.rotatingWriterOptions()
+		planned.File = options.fileName(d.debugger.now())
+		planned.FilePath = d.FileLog.FilePath
+		planned.FileName = d.FileLog.FileName
+		planned.FileType = d.FileLog.FileType
+		planned.Folder = d.FileFolder
+	}
+	if d.AsyncLog.Enable {
+		planned.AsyncLog = d.AsyncLog
+	}
+	return planned
+}
+
+// String renders the plan for humans, one block per rule.
+func (p Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "plan of %s: %d rules\n", p.File, len(p.Rules))
+	for _, r := range p.Rules {
+		fmt.Fprintf(&b, "%s/%d: %s-%s, verbosity %s, formatter %s",
+			r.Module, r.Index, r.MinLevel.GetLogLevelName(), r.MaxLevel.GetLogLevelName(),
+			r.Verbosity.GetLogLevelName(), r.Formatter)
+		if !r.Enabled {
+			b.WriteString(", disabled")
+		}
+		b.WriteString("\n")
+		if r.Console {
+			b.WriteString("  console\n")
+		}
+		if r.File != "" {
+			fmt.Fprintf(&b, "  file %s (path %s, name %s, type %s)\n", r.File, r.FilePath, r.FileName, r.FileType)
+		}
+		if r.Folder.Enable {
+			fmt.Fprintf(&b, "  time folders %s every %s\n", r.Folder.TimeFolderFormat, r.Folder.FileFolderPeriod)
+		}
+		if r.Async {
+			policy := r.AsyncLog.OverflowPolicy
+			if policy == "" {
+				policy = OverflowBlock
+			}
+			fmt.Fprintf(&b, "  async, buffer %d, overflow %s\n", r.AsyncLog.BufferSize, policy)
+		}
+		if len(r.SinkTypes) > 0 {
+			fmt.Fprintf(&b, "  sinks %s\n", strings.Join(r.SinkTypes, ", "))
+		}
+		if r.Hooks > 0 {
+			fmt.Fprintf(&b, "  %d hooks\n", r.Hooks)
+		}
+	}
+	for _, skipped := range p.Skipped {
+		fmt.Fprintf(&b, "skipped %s: no console, file or sink output\n", skipped)
+	}
+	for _, notice := range p.Notices {
+		fmt.Fprintf(&b, "notice: %s\n", notice)
+	}
+	return b.String()
+}
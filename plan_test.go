@@ -0,0 +1,77 @@
+package mklog
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestPlanMatchesLoadConfig plans a configuration and loads it: every planned rule describes the rule
+// LoadConfig creates at the same position, after the same defaulting, and the rules without outputs are
+// skipped by both.
+func TestPlanMatchesLoadConfig(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	path := writeConfig(t, "config.yaml", `
+log_rules:
+  api:
+    - min_level: INFO
+      max_level: FATAL
+      console_enable: true
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: api, file_type: .log}
+      folder_file: {enable: true, file_folder_period: 24h, time_folder_format: "2006-01-02"}
+      async_log: {enable: true, buffer_size: 64, overflow_policy: drop_oldest, batch_size: 8}
+    - min_level: ERROR
+      date_format: rfc3339
+      log_formatter: {type: json}
+      file_log: {enable: true, file_path: `+dir+`}
+  db:
+    - min_level: WARNING
+      disabled: true
+      verbosity: DEBUG
+      console_enable: true
+      log_formatter: {type: logfmt}
+      async_log: {enable: true}
+  idle:
+    - min_level: INFO
+      log_formatter: {type: plain}
+`)
+	m := NewLogConfigManager().SetQuiet(true)
+	plan, err := m.Plan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := m.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+
+	infos := d.Rules()
+	if len(plan.Rules) != len(infos) || len(plan.Rules) != 3 {
+		t.Fatalf("planned %d rules, loaded %d, want 3", len(plan.Rules), len(infos))
+	}
+	if !reflect.DeepEqual(plan.Skipped, []string{"idle/0"}) {
+		t.Errorf("skipped = %v, want idle/0", plan.Skipped)
+	}
+	for i, planned := range plan.Rules {
+		if planned.RuleInfo != infos[i] {
+			t.Errorf("rule %d: planned %+v, loaded %+v", i, planned.RuleInfo, infos[i])
+		}
+		rule := d.rules()[planned.Module][planned.Index]
+		if planned.Verbosity != rule.Verbosity || planned.DateFormat != rule.DateFormat {
+			t.Errorf("%s/%d: planned verbosity %v and date format %q, loaded %v and %q", planned.Module, planned.Index,
+				planned.Verbosity, planned.DateFormat, rule.Verbosity, rule.DateFormat)
+		}
+		if rule.FileLog.Enable && (planned.FilePath != rule.FileLog.FilePath || planned.FileName != rule.FileLog.FileName ||
+			planned.FileType != rule.FileLog.FileType || planned.Folder != rule.FileFolder) {
+			t.Errorf("%s/%d: planned file %s/%s%s in %+v, loaded %s/%s%s in %+v", planned.Module, planned.Index,
+				planned.FilePath, planned.FileName, planned.FileType, planned.Folder,
+				rule.FileLog.FilePath, rule.FileLog.FileName, rule.FileLog.FileType, rule.FileFolder)
+		}
+		if rule.AsyncLog.Enable && !reflect.DeepEqual(planned.AsyncLog, rule.AsyncLog) {
+			t.Errorf("%s/%d: planned async %+v, loaded %+v", planned.Module, planned.Index, planned.AsyncLog, rule.AsyncLog)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build linux && mklog_plugin
+
+package mklog
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// LoadPlugin opens a Go plugin built with -buildmode=plugin and calls its exported function MklogRegister, which
+// registers the formatters and sinks of the plugin with RegisterFormatter and RegisterSink:
+//
+//	func MklogRegister() error {
+//		return mklog.RegisterFormatter("acme", newAcmeFormatter)
+//	}
+//
+// The plugin must be built against the same version of mklog as the application. Plugins are only supported on
+// Linux with the mklog_plugin build tag; otherwise LoadPlugin returns an error.
+func LoadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("[mklog] failed to open plugin %s: %w", path, err)
+	}
+	symbol, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return fmt.Errorf("[mklog] plugin %s does not export %s: %w", path, PluginSymbol, err)
+	}
+	register, ok := symbol.(func() error)
+	if !ok {
+		return fmt.Errorf("[mklog] %s of plugin %s must be a func() error, got %T", PluginSymbol, path, symbol)
+	}
+	if err := register(); err != nil {
+		return fmt.Errorf("[mklog] failed to register plugin %s: %w", path, err)
+	}
+	return nil
+}
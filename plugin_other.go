@@ -0,0 +1,10 @@
+//go:build !linux || !mklog_plugin
+
+package mklog
+
+import "fmt"
+
+// LoadPlugin returns an error, as plugins are only supported on Linux with the mklog_plugin build tag.
+func LoadPlugin(path string) error {
+	return fmt.Errorf("[mklog] cannot load plugin %s: build with the mklog_plugin tag on linux", path)
+}
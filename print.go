@@ -1,222 +1,318 @@
 package mklog
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 )
 
-// CustomTrace logs a message at the specified log level and handles error extraction.
-// It checks all log rules to determine if the message should be logged based on the rules' conditions.
+// CustomTrace logs a message at the specified log level like Custom.
+//
+// Deprecated: use Custom; the debug mode condition it applied is replaced by the Verbosity of the rules.
 func (d *Debugger) CustomTrace(logLevel LogLevel, msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
+	d.log(nil, logLevel, nil, msg, args...)
+}
 
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, logLevel)
+// CustomDebug logs a message at the specified log level like Custom.
+//
+// Deprecated: use Custom; the debug mode condition it applied is replaced by the Verbosity of the rules.
+func (d *Debugger) CustomDebug(logLevel LogLevel, msg string, args ...interface{}) {
+	d.log(nil, logLevel, nil, msg, args...)
+}
 
-		for _, v := range loggableRules {
-			if v.DebugMode && v.DebugModeStatus == TraceLevel {
-				v.CurrentLevel = logLevel
+// Custom logs a message at a specified log level, checking the levels and the Verbosity of the rules.
+func (d *Debugger) Custom(logLevel LogLevel, msg string, args ...interface{}) {
+	d.log(nil, logLevel, nil, msg, args...)
+}
 
-				finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
-				if v.AsyncLog.Enable {
-					v.logChannel <- finalMessage
-				} else {
-					v.print(finalMessage)
-				}
-			}
-		}
-	}
+// Debug logs a message at the Debug level and checks if it should be output based on the defined rules.
+func (d *Debugger) Debug(msg string, args ...interface{}) {
+	d.log(nil, DebugLevel, nil, msg, args...)
 }
 
-// CustomDebug logs a message at the specified log level, similarly to CustomTrace.
-// It checks if the log should be output based on the rules defined in LogRules.
-func (d *Debugger) CustomDebug(logLevel LogLevel, msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
+// Trace logs a message at the Trace level, outputting it based on the console and file settings.
+func (d *Debugger) Trace(msg string, args ...interface{}) {
+	d.log(nil, TraceLevel, nil, msg, args...)
+}
 
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, logLevel)
+// Info logs a message at the Info level, similar to other log methods, checking for applicable rules.
+func (d *Debugger) Info(msg string, args ...interface{}) {
+	d.log(nil, InfoLevel, nil, msg, args...)
+}
 
-		for _, v := range loggableRules {
-			if v.DebugMode {
-				v.CurrentLevel = logLevel
+// Warning logs a message at the Warning level, checking if it should be printed based on the rules.
+func (d *Debugger) Warning(msg string, args ...interface{}) {
+	d.log(nil, WarningLevel, nil, msg, args...)
+}
 
-				finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
-				if v.AsyncLog.Enable {
-					v.logChannel <- finalMessage
-				} else {
-					v.print(finalMessage)
-				}
-			}
-		}
-	}
+// Error logs a message at the Error level, outputting it based on the defined logging rules.
+func (d *Debugger) Error(msg string, args ...interface{}) {
+	d.log(nil, ErrorLevel, nil, msg, args...)
 }
 
-// Custom logs a message at a specified log level, checking the appropriate rules.
-// This method is more general and does not have specific conditions like debug mode.
-func (d *Debugger) Custom(logLevel LogLevel, msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
+// Fatal logs a message at the Fatal level, handling output based on rules set in LogRules.
+// If a rule accepting the entry has a fatal exit code, the process exits afterwards; see SetOnFatal for the order of the steps.
+func (d *Debugger) Fatal(msg string, args ...interface{}) {
+	entry := d.log(nil, FatalLevel, nil, msg, args...)
+	d.handleFatal(entry)
+}
 
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, logLevel)
+// CustomCtx logs a message at the specified log level like Custom, passing the context to the enrichers.
+func (d *Debugger) CustomCtx(ctx context.Context, logLevel LogLevel, msg string, args ...interface{}) {
+	d.log(ctx, logLevel, nil, msg, args...)
+}
 
-		for _, v := range loggableRules {
-			v.CurrentLevel = logLevel
+// TraceCtx logs a message at the Trace level like Trace, passing the context to the enrichers.
+func (d *Debugger) TraceCtx(ctx context.Context, msg string, args ...interface{}) {
+	d.log(ctx, TraceLevel, nil, msg, args...)
+}
 
-			finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
-			if v.AsyncLog.Enable {
-				v.logChannel <- finalMessage
-			} else {
-				v.print(finalMessage)
-			}
-		}
-	}
+// DebugCtx logs a message at the Debug level like Debug, passing the context to the enrichers.
+func (d *Debugger) DebugCtx(ctx context.Context, msg string, args ...interface{}) {
+	d.log(ctx, DebugLevel, nil, msg, args...)
 }
 
-// Debug logs a message at the Debug level and checks if it should be output based on the defined rules.
-func (d *Debugger) Debug(msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
+// InfoCtx logs a message at the Info level, passing the context to the enrichers.
+func (d *Debugger) InfoCtx(ctx context.Context, msg string, args ...interface{}) {
+	d.log(ctx, InfoLevel, nil, msg, args...)
+}
 
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, DebugLevel)
+// WarningCtx logs a message at the Warning level, passing the context to the enrichers.
+func (d *Debugger) WarningCtx(ctx context.Context, msg string, args ...interface{}) {
+	d.log(ctx, WarningLevel, nil, msg, args...)
+}
 
-		for _, v := range loggableRules {
-			if v.DebugMode {
-				v.CurrentLevel = DebugLevel
+// ErrorCtx logs a message at the Error level, passing the context to the enrichers.
+func (d *Debugger) ErrorCtx(ctx context.Context, msg string, args ...interface{}) {
+	d.log(ctx, ErrorLevel, nil, msg, args...)
+}
 
-				finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
-				if v.AsyncLog.Enable {
-					v.logChannel <- finalMessage
-				} else {
-					v.print(finalMessage)
-				}
-			}
-		}
-	}
+// FatalCtx logs a message at the Fatal level like Fatal, passing the context to the enrichers.
+func (d *Debugger) FatalCtx(ctx context.Context, msg string, args ...interface{}) {
+	entry := d.log(ctx, FatalLevel, nil, msg, args...)
+	d.handleFatal(entry)
 }
 
-// Trace logs a message at the Trace level, outputting it based on the console and file settings.
-func (d *Debugger) Trace(msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
+// log formats the message once and dispatches it to every rule accepting the log level.
+// The time is captured once per call and shared by the formatted messages, file rollover, sinks and hooks.
+// The fields bound by a handle and the Fields among the arguments are merged with the static fields and the
+// fields of the global enrichers, which receive ctx when the message was logged by a *Ctx method, see
+// assembleFields. Each entry then passes the middleware chain registered with Use before it is formatted.
+// Rules are evaluated by module name and within a module in the order they were added.
+// It returns the entry of the last rule the message was dispatched to, or the bare entry if no rule accepted it.
+func (d *Debugger) log(ctx context.Context, logLevel LogLevel, fields map[string]interface{}, msg string, args ...interface{}) Entry {
+	return d.logRules(ctx, d.rules(), logLevel, fields, msg, args...)
+}
 
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, TraceLevel)
+// logModule logs the message like log, dispatching it to the rules of the module only unless the module is
+// empty. A module without rules gets its fallback rule, see SetFallbackRule.
+func (d *Debugger) logModule(ctx context.Context, module string, logLevel LogLevel, fields map[string]interface{}, msg string, args ...interface{}) Entry {
+	if module == "" {
+		return d.log(ctx, logLevel, fields, msg, args...)
+	}
+	return d.logRules(ctx, d.moduleRules(module), logLevel, fields, msg, args...)
+}
 
-		for _, v := range loggableRules {
-			if v.DebugMode && v.DebugModeStatus == TraceLevel {
-				v.CurrentLevel = TraceLevel
+// logRules logs the message like log to the given rules by module.
+func (d *Debugger) logRules(ctx context.Context, targets map[string][]*LogRule, logLevel LogLevel, fields map[string]interface{}, msg string, args ...interface{}) Entry {
+	args, force := splitConsoleFlag(args)
+	args, callFields := splitCallFields(args)
+	return d.logFormatted(ctx, targets, logLevel, fields, callFields, force, fmt.Sprintf(msg, args...), d.extractError(args...))
+}
 
-				finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
-				if v.AsyncLog.Enable {
-					v.logChannel <- finalMessage
-				} else {
-					v.print(finalMessage)
+// logFormatted dispatches the formatted message like logRules, with the Fields and the ForceConsole flag
+// already taken from the arguments of the call and the error among them.
+func (d *Debugger) logFormatted(ctx context.Context, targets map[string][]*LogRule, logLevel LogLevel, fields, callFields map[string]interface{}, force bool, logMessage string, err error) Entry {
+	now := d.now()
+
+	last := Entry{
+		Time:      now,
+		Level:     logLevel,
+		LevelName: logLevel.GetLogLevelName(),
+		Message:   logMessage,
+		Err:       err,
+	}
+	dispatched := false
+	consolePrinted := false // Whether a rule printed the entry to the console, see WithConsoleOnce
+
+	var buf [8]string
+	var ruleBuf [8]int
+	for _, module := range orderedModules(targets, buf[:0]) {
+		rules := targets[module]
+		// Keep the entries below the minimum level of rules with a flight recorder.
+		for i, v := range rules {
+			if minLevel, _ := v.levelBounds(); v.recorder != nil && logLevel < minLevel && v.Enabled() && !v.burstAccepts(logLevel) {
+				entry := acquireEntry()
+				*entry = Entry{
+					Time:       now,
+					Level:      logLevel,
+					LevelName:  v.GetLogLevelName(logLevel),
+					Module:     v.ModuleName,
+					Submodules: v.Submodules,
+					Message:    logMessage,
+					Err:        err,
+					Rule:       v.ref(module, i),
 				}
+				d.assembleFields(ctx, entry, true, fields, callFields)
+				entry.Fields = v.limitFields(entry.Fields)
+				d.handleEntry(*entry, v.recorder.add) // Recorded as the chain hands it on, so replays are redacted too.
+				releaseEntry(entry)
 			}
 		}
-	}
-}
-
-// Info logs a message at the Info level, similar to other log methods, checking for applicable rules.
-func (d *Debugger) Info(msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
 
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, InfoLevel)
-
-		for _, v := range loggableRules {
-			v.CurrentLevel = InfoLevel
+		for _, i := range filterLoggableRules(rules, logLevel, ruleBuf[:0]) {
+			v := rules[i]
+			if !v.lifecycle.acquire() {
+				continue
+			}
 
-			finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
+			entry := acquireEntry()
+			*entry = Entry{
+				Time:       now,
+				Level:      logLevel,
+				LevelName:  v.GetLogLevelName(logLevel),
+				Module:     v.ModuleName,
+				Submodules: v.Submodules,
+				Message:    logMessage,
+				Err:        err,
+				Rule:       v.ref(module, i),
+			}
 
-			if v.AsyncLog.Enable {
-				v.logChannel <- finalMessage
+			d.assembleFields(ctx, entry, true, fields, callFields)
+			entry.Fields = v.limitFields(entry.Fields)
+			entry.forceConsole = force
+			entry.skipConsole = consolePrinted && v.ConsoleOnce
+			dispatched = true
+			if d.middleware.Load() == nil {
+				v.dispatch(entry)
+				d.recordRecent(*entry)
+				last = *entry
+			} else if handled, ok := d.dispatchMiddleware(v, *entry); ok {
+				*entry = handled
+				last = handled
 			} else {
-				v.print(finalMessage)
+				entry.skipConsole = true // Dropped by the middleware, so not printed either.
+				v.lifecycle.release()
 			}
+			consolePrinted = consolePrinted || (v.consoleOutput(*entry) && !entry.skipConsole)
+			releaseEntry(entry)
 		}
 	}
+	if !dispatched {
+		entry := acquireEntry()
+		*entry = last
+		d.assembleFields(ctx, entry, false, fields, callFields)
+		last = *entry
+		releaseEntry(entry)
+	}
+	return last
 }
 
-// Warning logs a message at the Warning level, checking if it should be printed based on the rules.
-func (d *Debugger) Warning(msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
-
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, WarningLevel)
-
-		for _, v := range loggableRules {
-			v.CurrentLevel = WarningLevel
-
-			finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
-			if v.AsyncLog.Enable {
-				v.logChannel <- finalMessage
-			} else {
-				v.print(finalMessage)
-			}
+// dispatchMiddleware passes the entry through the middleware chain and dispatches the entry the chain hands on
+// to the rule. It returns that entry and whether the chain handed one on. The entry is dispatched once, as
+// dispatch releases the rule, even if a middleware calls the next handler repeatedly.
+func (d *Debugger) dispatchMiddleware(v *LogRule, entry Entry) (handled Entry, ok bool) {
+	d.handleEntry(entry, func(entry Entry) {
+		if ok {
+			return
 		}
-	}
+		v.dispatch(&entry)
+		d.recordRecent(entry)
+		handled, ok = entry, true
+	})
+	return handled, ok
 }
 
-// Error logs a message at the Error level, outputting it based on the defined logging rules.
-func (d *Debugger) Error(msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
-
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, ErrorLevel)
-
-		for _, v := range loggableRules {
-			v.CurrentLevel = ErrorLevel
-
-			finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
-			if v.AsyncLog.Enable {
-				v.logChannel <- finalMessage
-			} else {
-				v.print(finalMessage)
-			}
+// orderedModules appends the modules of the rules to buf sorted by name, so that rules are evaluated in a
+// stable order. The modules are sorted in place, as a Debugger has few of them.
+func orderedModules(targets map[string][]*LogRule, buf []string) []string {
+	modules := buf
+	for module := range targets {
+		modules = append(modules, module)
+	}
+	for i := 1; i < len(modules); i++ {
+		for j := i; j > 0 && modules[j] < modules[j-1]; j-- {
+			modules[j], modules[j-1] = modules[j-1], modules[j]
 		}
 	}
+	return modules
 }
 
-// Fatal logs a message at the Fatal level, handling output based on rules set in LogRules.
-func (d *Debugger) Fatal(msg string, args ...interface{}) {
-	logMessage := fmt.Sprintf(msg, args...)
-	err := d.extractError(args...)
-
-	for _, rules := range d.LogRules {
-		loggableRules := filterLoggableRules(rules, FatalLevel)
+// dispatch formats the entry and writes it to the outputs, sinks and hooks of the rule, numbering it with its
+// Seq. The entry may come from the entry pool, so only copies of it outlive the call.
+//
+// The caller has acquired the lifecycle of the rule; dispatch releases it once the outputs and sinks are
+// written, before the hooks and the error handler run, so that they may log to the rule while Close waits for
+// the dispatches in progress.
+func (lr *LogRule) dispatch(entry *Entry) {
+	lr.countEntry(entry.Level)
+	lr.expireBurst(entry.Time)
+	lr.replayRecorded(entry.Level)
+	finalMessage := lr.prepareMessage(entry.Time, entry.Message, entry.Level, lr.renderStack(entry.Level), entry.Fields, entry.Err)
+	errs := lr.writeEntry(entry, finalMessage)
+	lr.extendBurst(*entry)
+	lr.lifecycle.release()
+
+	lr.reportDeferred()
+	errs = append(errs, lr.fireHooks(*entry)...)
+	lr.reportOutputErrors(*entry, errs)
+}
 
-		for _, v := range loggableRules {
-			v.CurrentLevel = FatalLevel
+// writeEntry writes the final log message to the outputs and sinks of the rule, returning the errors of the
+// failed ones named after them, and numbers the entry with its Seq.
+//
+// Writes of a rule are serialized: the console, the log file and every sink receive the entries of the rule in
+// the same order, the order of their Seq, and all of them have received an entry before the next one is
+// written. With async logging the entry is queued in that order and the consumer writes the console and the log
+// file in queue order, except while the consumer is stalled and the console is written directly, see
+// WithAsyncWatchdog. Different rules write concurrently. Sinks therefore must not log to their own rule
+// synchronously, which would wait for itself; hooks run after the writes and are not serialized. The internal
+// errors raised while the writes hold the lock, e.g. slow writes, failed log file writes and formatter panics,
+// are kept with deferError and passed to the error handler by dispatch once the rule is released, so an error
+// handler may log to the rule.
+func (lr *LogRule) writeEntry(entry *Entry, finalMessage string) []error {
+	if l := lr.lifecycle; l != nil {
+		l.outputMu.Lock()
+		defer l.outputMu.Unlock()
+		l.seq++
+		entry.Seq = l.seq
+	}
+	var errs []error
+	if err := lr.output(*entry, finalMessage); err != nil {
+		errs = append(errs, fmt.Errorf("file: %w", err))
+	}
+	return append(errs, lr.writeSinks(*entry, finalMessage)...)
+}
 
-			finalMessage := v.prepareMessage(logMessage, v.CurrentLevel, v.DetailedErrorOutput, err)
-			if v.AsyncLog.Enable {
-				v.logChannel <- finalMessage
-			} else {
-				v.print(finalMessage)
-			}
-		}
+// output writes the final log message to the console and log file of the rule, through the async queue when enabled.
+// It returns the error of a synchronous log file write; the async consumer reports its errors itself.
+func (lr *LogRule) output(entry Entry, finalMessage string) error {
+	if lr.AsyncLog.Enable && lr.degraded() {
+		lr.writeConsole(entry, finalMessage) // The stalled consumer is bypassed until it recovers.
+	} else if lr.AsyncLog.Enable {
+		lr.enqueue(QueuedEntry{Entry: entry, Text: finalMessage})
+	} else {
+		return lr.print(entry, finalMessage)
 	}
+	return nil
 }
 
-// print outputs the final log message to the console and to the log file if enabled.
-func (lr *LogRule) print(finalMessage string) {
-	if lr.IsConsoleOutput {
-		fmt.Println(finalMessage)
+// print outputs the final log message to the console and to the log file if enabled, or to the console when
+// the entry forces it, see ForceConsole.
+// Synchronous rules and the async consumer share it, so both write entries the same way.
+// It returns the error of the log file write.
+func (lr *LogRule) print(entry Entry, finalMessage string) error {
+	if lr.consoleOutput(entry) {
+		lr.writeConsole(entry, finalMessage)
 	}
 
 	if lr.FileLog.Enable {
-		if err := lr.writeLog(finalMessage + "\n"); err != nil {
-			fmt.Println("[mklog] Error while writing to log file ", lr.ModuleName, " : ", err)
+		if err := lr.writeLog(entry.Time, lr.fileLine(finalMessage)); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 // extractError checks the arguments for any errors and returns the first found error.
@@ -232,9 +328,13 @@ func (d *Debugger) extractError(args ...interface{}) error {
 }
 
 // prepareMessage formats the log message with relevant details including timestamp and log level.
-func (lr *LogRule) prepareMessage(logMessage string, logLevel LogLevel, isDetailed bool, optionalArgs ...interface{}) string {
-	logLevelName := lr.GetLogLevelName(logLevel)
-	finalMessage := lr.LogFormatter.Format(logMessage, logLevelName, lr.ModuleName, lr.Submodules, time.Now().Format(lr.DateFormat))
+// A MultilineFormatter renders the line breaks of the result, including those of the error details. The message
+// carries the prefix and suffix of the rule, see WithMessagePrefix.
+// The timestamp is the time of the log call, shared by the entry passed to sinks and hooks. Trailing line
+// breaks, e.g. the one JSONFormatter ends its documents with, are stripped: the console and log files end
+// every entry with exactly one line break themselves, and sinks receive the entry without one.
+func (lr *LogRule) prepareMessage(logged time.Time, logMessage string, logLevel LogLevel, isDetailed bool, fields map[string]interface{}, optionalArgs ...interface{}) string {
+	finalMessage := lr.safeFormat(logged, lr.decorateMessage(logMessage), logLevel, fields)
 
 	for _, arg := range optionalArgs {
 		if detailedErr, ok := arg.(DetailedError); ok {
@@ -246,21 +346,100 @@ func (lr *LogRule) prepareMessage(logMessage string, logLevel LogLevel, isDetail
 			break
 		}
 	}
+	if f, ok := lr.LogFormatter.(MultilineFormatter); ok {
+		finalMessage = f.FormatMultiline(finalMessage)
+	}
+	return strings.TrimRight(finalMessage, "\r\n")
+}
+
+// formatWith formats the log message with the formatter, using the richest interface it implements.
+func (lr *LogRule) formatWith(formatter LogFormatter, logged time.Time, logMessage string, logLevel LogLevel, fields map[string]interface{}) string {
+	logLevelName := lr.GetLogLevelName(logLevel)
+	stamped := lr.timestampTime(logged)
+
+	var finalMessage string
+	if f, ok := formatter.(EntryFormatter); ok {
+		entry := Entry{
+			Time:       logged,
+			Level:      logLevel,
+			LevelName:  logLevelName,
+			Module:     lr.ModuleName,
+			Submodules: lr.Submodules,
+			Message:    logMessage,
+			Fields:     fields,
+		}
+		finalMessage = f.FormatEntry(entry, formatTimestamp(stamped, lr.DateFormat))
+	} else if len(fields) > 0 {
+		finalMessage = formatWithFields(formatter, logMessage, logLevelName, lr.ModuleName, lr.Submodules, formatTimestamp(stamped, lr.DateFormat), fields)
+	} else if f, ok := formatter.(AppendFormatter); ok {
+		finalMessage = formatAppend(f, logMessage, logLevelName, lr.ModuleName, lr.Submodules, stamped, lr.DateFormat)
+	} else {
+		finalMessage = formatter.Format(logMessage, logLevelName, lr.ModuleName, lr.Submodules, formatTimestamp(stamped, lr.DateFormat))
+	}
 	return finalMessage
 }
 
-// filterLoggableRules filters the log rules to find those that are applicable based on the log level.
-func filterLoggableRules(rules []*LogRule, level LogLevel) []*LogRule {
-	var loggableRules []*LogRule
-	for _, rule := range rules {
+// timestampTime returns the time in the location timestamps of the rule are rendered in.
+func (lr *LogRule) timestampTime(t time.Time) time.Time {
+	if lr.TimestampLocation == nil {
+		return t
+	}
+	return t.In(lr.TimestampLocation)
+}
+
+// filterLoggableRules appends the indexes of the log rules applicable to the log level to buf. All rules are
+// checked before any of them is dispatched to, so dispatching cannot change which rules receive the entry.
+// Passing a buffer on the stack of the caller avoids allocating for modules with a few applicable rules.
+func filterLoggableRules(rules []*LogRule, level LogLevel, buf []int) []int {
+	loggable := buf
+	for i, rule := range rules {
 		if rule.shouldLog(level) {
-			loggableRules = append(loggableRules, rule)
+			loggable = append(loggable, i)
 		}
 	}
-	return loggableRules
+	return loggable
 }
 
-// shouldLog determines if the log level falls within the rule's specified min and max levels.
+// shouldLog determines if the rule is enabled, the log level falls within the rule's specified min and max levels
+// and reaches the verbosity of the rule. An active burst capture lowers the min level and the verbosity, see
+// WithErrorBurstCapture, and BumpVerbosity shifts both.
 func (lr *LogRule) shouldLog(logLevel LogLevel) bool {
-	return lr.MinLevel <= logLevel && logLevel <= lr.MaxLevel
+	minLevel, verbosity := lr.levelBounds()
+	return lr.Enabled() && (minLevel <= logLevel && logLevel >= verbosity || lr.burstAccepts(logLevel)) &&
+		logLevel <= lr.MaxLevel
+}
+
+// Enabled reports whether a message at the level would be accepted by any rule, applying the levels and
+// the Verbosity of the rules like the log methods. It does not allocate, so it is cheap enough
+// to guard expensive computations of log arguments.
+func (d *Debugger) Enabled(level LogLevel) bool {
+	for _, rules := range d.rules() {
+		if rulesEnabled(rules, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnabledFor reports whether a message at the level would be accepted by any rule of the module, like Enabled.
+// The rules are resolved like Debugger.Module does: a module without rules is checked against its fallback rule,
+// which is instantiated like on the first entry, see SetFallbackRule.
+func (d *Debugger) EnabledFor(module string, level LogLevel) bool {
+	if rules := d.rules()[module]; len(rules) > 0 {
+		return rulesEnabled(rules, level)
+	}
+	if lr := d.fallbackRules().ruleFor(d, module); lr != nil {
+		return lr.shouldLog(level)
+	}
+	return false
+}
+
+// rulesEnabled checks whether any of the rules accepts the level.
+func rulesEnabled(rules []*LogRule, level LogLevel) bool {
+	for _, v := range rules {
+		if v.shouldLog(level) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,321 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logAt logs a message at the level through the Debugger method of the level.
+func logAt(d *Debugger, level LogLevel) {
+	switch level {
+	case TraceLevel:
+		d.Trace("at trace")
+	case DebugLevel:
+		d.Debug("at debug")
+	case InfoLevel:
+		d.Info("at info")
+	case WarningLevel:
+		d.Warning("at warning")
+	case ErrorLevel:
+		d.Error("at error")
+	}
+}
+
+// TestEnabledAgreesWithOutput checks that Enabled and EnabledFor report a level exactly when logging at it
+// writes output, across level ranges, verbosities, the deprecated debug mode and disabled rules.
+func TestEnabledAgreesWithOutput(t *testing.T) {
+	type config struct {
+		name string
+		opts []Option
+	}
+	var configs []config
+	for _, bounds := range [][2]LogLevel{{TraceLevel, FatalLevel}, {InfoLevel, FatalLevel}, {DebugLevel, WarningLevel}, {ErrorLevel, ErrorLevel}} {
+		for _, extra := range []config{
+			{"", nil},
+			{"verbosity warning", []Option{WithVerbosity(WarningLevel)}},
+			{"debug mode info", []Option{WithDebugMode(true, InfoLevel)}},
+			{"debug mode off", []Option{WithDebugMode(false, TraceLevel)}},
+			{"disabled", []Option{WithDisabled(true)}},
+		} {
+			configs = append(configs, config{
+				name: fmt.Sprintf("min %v max %v %s", bounds[0], bounds[1], extra.name),
+				opts: append([]Option{WithMinLevel(bounds[0]), WithMaxLevel(bounds[1])}, extra.opts...),
+			})
+		}
+	}
+
+	for _, c := range configs {
+		out := &syncBuffer{}
+		d := (&Debugger{}).SetQuiet(true)
+		d.NewLogRule("app", append([]Option{WithFileWriter(out)}, c.opts...)...)
+
+		for level := TraceLevel; level <= ErrorLevel; level++ {
+			enabled, enabledFor := d.Enabled(level), d.EnabledFor("app", level)
+			before := len(out.String())
+			logAt(d, level)
+			logged := len(out.String()) > before
+			if enabled != logged || enabledFor != logged {
+				t.Errorf("%s: level %v: Enabled = %v, EnabledFor = %v, logged = %v", c.name, level, enabled, enabledFor, logged)
+			}
+			if d.EnabledFor("other", level) {
+				t.Errorf("%s: level %v enabled for a module without rules", c.name, level)
+			}
+		}
+		d.Close(context.Background())
+	}
+}
+
+// TestEnabledForFallbackModule checks a module without rules against the fallback rule its entries are written
+// by, and reports it disabled without a fallback rule.
+func TestEnabledForFallbackModule(t *testing.T) {
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithMinLevel(ErrorLevel))
+	d.SetFallbackRule(WithFileWriter(out), WithMinLevel(InfoLevel))
+	defer d.Close(context.Background())
+
+	for level := TraceLevel; level <= ErrorLevel; level++ {
+		enabled := d.EnabledFor("billing", level)
+		before := len(out.String())
+		d.Module("billing").Custom(level, "invoice sent")
+		if logged := len(out.String()) > before; enabled != logged {
+			t.Errorf("level %v: EnabledFor = %v, logged = %v", level, enabled, logged)
+		}
+	}
+
+	rec := &errorRecorder{}
+	d.SetErrorHandler(rec.handle)
+	d.SetStrictModules(true)
+	if d.EnabledFor("jobs", ErrorLevel) {
+		t.Error("module without rules enabled in strict mode")
+	}
+	if errs := rec.get(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "no rule for module jobs") {
+		t.Errorf("reported errors = %v, want the missing rule of jobs", errs)
+	}
+}
+
+// TestEnabledFollowsRuntimeChanges keeps Enabled consistent with the output when levels and rules are changed
+// while the Debugger is in use.
+func TestEnabledFollowsRuntimeChanges(t *testing.T) {
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(out), WithMinLevel(InfoLevel), WithMaxLevel(FatalLevel))
+	defer d.Close(context.Background())
+
+	check := func(step string) {
+		t.Helper()
+		for level := TraceLevel; level <= ErrorLevel; level++ {
+			before := len(out.String())
+			enabled := d.Enabled(level)
+			logAt(d, level)
+			if logged := len(out.String()) > before; enabled != logged {
+				t.Errorf("%s: level %v: Enabled = %v, logged = %v", step, level, enabled, logged)
+			}
+		}
+	}
+
+	check("initial")
+	d.BumpVerbosity(2)
+	check("bumped")
+	if !d.Enabled(TraceLevel) {
+		t.Error("bumping by two steps did not enable Trace")
+	}
+	d.BumpVerbosity(-2)
+	d.rules()["app"][0].SetVerbosity(ErrorLevel)
+	check("verbosity error")
+	if err := d.SetRuleEnabled("app", 0, false); err != nil {
+		t.Fatal(err)
+	}
+	check("disabled")
+	if d.Enabled(ErrorLevel) {
+		t.Error("Error enabled with the only rule disabled")
+	}
+}
+
+// TestEnabledDoesNotAllocate asks for a level of a Debugger with several modules without allocating.
+func TestEnabledDoesNotAllocate(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	for _, module := range []string{"api", "db", "jobs"} {
+		d.NewLogRule(module, WithFileWriter(&syncBuffer{}), WithMinLevel(ErrorLevel))
+	}
+	defer d.Close(context.Background())
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		d.Enabled(DebugLevel)
+		d.EnabledFor("db", TraceLevel)
+	}); allocs != 0 {
+		t.Errorf("Enabled allocates %v times per call", allocs)
+	}
+}
+
+// entryTimeSink records the times of the entries it receives.
+type entryTimeSink struct {
+	times []time.Time
+}
+
+func (s *entryTimeSink) Write(entry Entry, formatted string) error {
+	s.times = append(s.times, entry.Time)
+	return nil
+}
+
+func (s *entryTimeSink) Close() error { return nil }
+
+// TestTimestampLocationPerRule formats the same entry through rules in two time zones and one without a
+// location, comparing the offsets, while the sinks of the rules receive the same absolute time.
+func TestTimestampLocationPerRule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+
+	at := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return at })
+	outputs := map[string]*syncBuffer{"region-eu": {}, "region-us": {}, "region-utc": {}}
+	sinks := map[string]*entryTimeSink{"region-eu": {}, "region-us": {}, "region-utc": {}}
+	for module, loc := range map[string]*time.Location{"region-eu": berlin, "region-us": newYork, "region-utc": nil} {
+		d.NewLogRule(module,
+			WithFileWriter(outputs[module]),
+			WithSink(sinks[module]),
+			WithDateFormat(time.RFC3339),
+			WithTimestampLocation(loc),
+		)
+	}
+	d.Info("deployed")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for module, want := range map[string]string{
+		"region-eu":  "2024-01-15T13:00:00+01:00",
+		"region-us":  "2024-01-15T07:00:00-05:00",
+		"region-utc": "2024-01-15T12:00:00Z",
+	} {
+		if got := outputs[module].String(); !strings.HasPrefix(got, want+" ") {
+			t.Errorf("%s: output %q, want the timestamp %s", module, got, want)
+		}
+		if times := sinks[module].times; len(times) != 1 || !times[0].Equal(at) || times[0].Location() != time.UTC {
+			t.Errorf("%s: sink received %v, want %v", module, times, at)
+		}
+	}
+}
+
+// gatedBuffer is a syncBuffer whose writes wait until the gate is closed, signalling each write that started
+// on started when set.
+type gatedBuffer struct {
+	gate    chan struct{}
+	started chan struct{}
+	syncBuffer
+}
+
+func (b *gatedBuffer) Write(p []byte) (int, error) {
+	if b.started != nil {
+		b.started <- struct{}{}
+	}
+	<-b.gate
+	return b.syncBuffer.Write(p)
+}
+
+// TestAsyncTimestampIsCallTime stamps the entries of an async rule with the time of the log call, not the time
+// the consumer writes them, in the log file and the sinks alike.
+func TestAsyncTimestampIsCallTime(t *testing.T) {
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	out := &gatedBuffer{gate: make(chan struct{})}
+	sink := &entryTimeSink{}
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.NewLogRule("app", WithFileWriter(out), WithSink(sink), WithDateFormat(time.RFC3339), WithAsyncLog(true, 8))
+
+	d.Info("first")
+	clock.Set(start.Add(time.Minute))
+	d.Info("second")
+	clock.Set(start.Add(time.Hour)) // The consumer writes both entries only now.
+	close(out.gate)
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{"2024-01-15T12:00:00Z ", "2024-01-15T12:01:00Z "}
+	if len(lines) != len(want) {
+		t.Fatalf("output %q, want two lines", out.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) {
+			t.Errorf("line %d = %q, want the timestamp %s", i, line, want[i])
+		}
+	}
+	if times := sink.times; len(times) != 2 || !times[0].Equal(start) || !times[1].Equal(start.Add(time.Minute)) {
+		t.Errorf("sink received %v, want the times of the calls", times)
+	}
+}
+
+// TestFilterLoggableRules keeps the applicable rules in their order for modules with 1, 10 and 100 rules,
+// growing past the buffer of the caller.
+func TestFilterLoggableRules(t *testing.T) {
+	for _, n := range []int{1, 10, 100} {
+		rules := make([]*LogRule, n)
+		var want []int
+		for i := range rules {
+			rules[i] = &LogRule{MinLevel: LogLevel(i % 5), MaxLevel: FatalLevel}
+			if LogLevel(i%5) <= WarningLevel {
+				want = append(want, i)
+			}
+		}
+		var buf [8]int
+		got := filterLoggableRules(rules, WarningLevel, buf[:0])
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%d rules: loggable %v, want %v", n, got, want)
+		}
+		if got := filterLoggableRules(rules, TraceLevel-1, buf[:0]); len(got) != 0 {
+			t.Errorf("%d rules: loggable below Trace %v, want none", n, got)
+		}
+	}
+}
+
+// TestDispatchFollowsRuntimeLevels dispatches to the rules of a module applicable at the time of each call
+// while their verbosity, the shift of BumpVerbosity and their enabled state change.
+func TestDispatchFollowsRuntimeLevels(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	defer d.Close(context.Background())
+	outs := make([]*syncBuffer, 10)
+	for i := range outs {
+		outs[i] = &syncBuffer{}
+		d.NewLogRule("app", WithFileWriter(outs[i]), WithMinLevel(LogLevel(i%5)), WithMaxLevel(FatalLevel), WithVerbosity(TraceLevel))
+	}
+
+	check := func(step string, want string) {
+		t.Helper()
+		var before []int
+		for _, out := range outs {
+			before = append(before, len(out.String()))
+		}
+		d.Debug("entry")
+		var got strings.Builder
+		for i, out := range outs {
+			if len(out.String()) > before[i] {
+				fmt.Fprint(&got, i)
+			}
+		}
+		if got.String() != want {
+			t.Errorf("%s: Debug entry written by rules %q, want %q", step, got.String(), want)
+		}
+	}
+
+	check("initial", "0156")
+	d.BumpVerbosity(1)
+	check("bumped", "012567")
+	d.BumpVerbosity(-1)
+	d.rules()["app"][0].SetVerbosity(ErrorLevel)
+	check("verbosity error", "156")
+	if err := d.SetRuleEnabled("app", 5, false); err != nil {
+		t.Fatal(err)
+	}
+	check("disabled", "16")
+}
@@ -0,0 +1,353 @@
+package mklog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LineParser parses one line written by a formatter back into an Entry.
+// The date format is the layout the timestamps were written with.
+type LineParser func(line string, dateFormat string) (Entry, error)
+
+var (
+	lineParsersMu sync.RWMutex
+	lineParsers   = map[string]LineParser{
+		"plaintext": parsePlainTextLine,
+		"plain":     parsePlainTextLine,
+		"text":      parsePlainTextLine,
+		"simple":    parsePlainTextLine,
+		"fasttext":  parsePlainTextLine,
+		"json":      parseJSONLine,
+		"logfmt":    parseLogfmtLine,
+	}
+)
+
+// RegisterLineParser registers a parser for lines of the given format, e.g. for a user-defined formatter.
+func RegisterLineParser(format string, parser LineParser) {
+	lineParsersMu.Lock()
+	lineParsers[strings.ToLower(format)] = parser
+	lineParsersMu.Unlock()
+}
+
+// lineParser returns the parser registered for the format.
+func lineParser(format string) (LineParser, error) {
+	lineParsersMu.RLock()
+	parser, ok := lineParsers[strings.ToLower(format)]
+	lineParsersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("[mklog] unsupported log file format: %s", format)
+	}
+	return parser, nil
+}
+
+// FileReader reads log files written by mklog back into Entry values.
+// Gzip-compressed files are detected and decompressed automatically.
+// Lines that cannot be parsed are skipped and counted.
+type FileReader struct {
+	DateFormat string           // Layout of the timestamps in the file, the TimeLogFormat of GetDefaults by default.
+	Filter     func(Entry) bool // Optional filter; entries for which it returns false are skipped without counting.
+	file       *os.File         // Underlying file
+	gz         *gzip.Reader     // Decompressor of gzip-compressed files
+	scanner    *bufio.Scanner   // Line scanner over the (decompressed) content
+	parser     LineParser       // Parser of the file format
+	entry      Entry            // Entry returned by the last call of Next
+	pending    string           // Line read ahead of the entry returned last
+	hasPending bool             // Whether pending holds a line
+	skipped    int              // Number of skipped corrupt lines
+	err        error            // First error other than corrupt lines
+}
+
+// NewFileReader opens the log file written in the given format ("plain", "json", "logfmt" or a registered format).
+func NewFileReader(path string, format string) (*FileReader, error) {
+	parser, err := lineParser(format)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("[mklog] failed to open log file: %w", err)
+	}
+
+	r := &FileReader{
+		DateFormat: GetDefaults().TimeLogFormat,
+		file:       file,
+		parser:     parser,
+	}
+
+	buffered := bufio.NewReader(file)
+	var content io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		r.gz, err = gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("[mklog] failed to open compressed log file: %w", err)
+		}
+		content = r.gz
+	}
+
+	r.scanner = bufio.NewScanner(content)
+	r.scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return r, nil
+}
+
+// Next advances to the next entry, returning false at the end of the file or on a read error.
+// Continuation lines written in MultilineIndent mode are joined to the message of their entry.
+func (r *FileReader) Next() bool {
+	for {
+		line, ok := r.readLine()
+		if !ok {
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		entry, err := r.parser(line, r.DateFormat)
+		if err != nil {
+			r.skipped++
+			continue
+		}
+		r.joinContinuation(&entry)
+		if r.Filter != nil && !r.Filter(entry) {
+			continue
+		}
+		r.entry = entry
+		return true
+	}
+	if err := r.scanner.Err(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("[mklog] failed to read log file: %w", err)
+	}
+	return false
+}
+
+// readLine returns the line read ahead or the next line of the file without its line ending.
+func (r *FileReader) readLine() (string, bool) {
+	if r.hasPending {
+		r.hasPending = false
+		return r.pending, true
+	}
+	if !r.scanner.Scan() {
+		return "", false
+	}
+	return strings.TrimRight(r.scanner.Text(), "\r"), true
+}
+
+// joinContinuation appends the continuation lines following the entry to its message, keeping the first
+// other line for the next entry.
+func (r *FileReader) joinContinuation(entry *Entry) {
+	for {
+		line, ok := r.readLine()
+		if !ok {
+			return
+		}
+		if !strings.HasPrefix(line, MKLOG_MultilineIndentPrefix) {
+			r.pending, r.hasPending = line, true
+			return
+		}
+		entry.Message += "\n" + line[len(MKLOG_MultilineIndentPrefix):]
+	}
+}
+
+// Entry returns the entry read by the last call of Next.
+func (r *FileReader) Entry() Entry {
+	return r.entry
+}
+
+// Skipped returns the number of corrupt lines skipped so far.
+func (r *FileReader) Skipped() int {
+	return r.skipped
+}
+
+// Err returns the first read error encountered, corrupt lines are not errors.
+func (r *FileReader) Err() error {
+	return r.err
+}
+
+// Close closes the log file.
+func (r *FileReader) Close() error {
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	return r.file.Close()
+}
+
+// parsedLevel fills the level of the entry from its display name, leaving it zero for custom names.
+func parsedLevel(e *Entry, name string) {
+	e.LevelName = name
+	if level, err := StringToLogLevel(name); err == nil {
+		e.Level = level
+	}
+}
+
+// parsedTime parses the timestamp, leaving the time zero when it does not match the layout.
+func parsedTime(value string, dateFormat string) time.Time {
+	t, err := parseTimestamp(value, dateFormat)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parsePlainTextLine parses a line in the PlainTextFormatter layout:
+// "timestamp | LEVEL | [module] : message" or "timestamp | LEVEL | [module] - [sub1.sub2]: message".
+// Submodules are split on MKLOG_SubmoduleSeparatorDefault, or on spaces in files of older versions.
+func parsePlainTextLine(line string, dateFormat string) (Entry, error) {
+	var e Entry
+
+	parts := strings.SplitN(line, " | ", 3)
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "[") {
+		return e, fmt.Errorf("line does not match the plain text layout")
+	}
+	e.Time = parsedTime(parts[0], dateFormat)
+	parsedLevel(&e, parts[1])
+
+	rest := parts[2][1:]
+	end := strings.Index(rest, "]")
+	if end < 0 {
+		return e, fmt.Errorf("missing module name")
+	}
+	e.Module = rest[:end]
+	rest = rest[end+1:]
+
+	switch {
+	case strings.HasPrefix(rest, " : "):
+		e.Message = rest[len(" : "):]
+	case strings.HasPrefix(rest, " - ["):
+		rest = rest[len(" - ["):]
+		end := strings.Index(rest, "]: ")
+		if end < 0 {
+			return e, fmt.Errorf("missing submodules")
+		}
+		e.Submodules = splitSubmodules(rest[:end], " ")
+		e.Message = rest[end+len("]: "):]
+	default:
+		return e, fmt.Errorf("missing message separator")
+	}
+	return e, nil
+}
+
+// parseJSONLine parses a line written by JSONFormatter, with the submodules as an array or a joined string.
+func parseJSONLine(line string, dateFormat string) (Entry, error) {
+	var data struct {
+		Timestamp  string                 `json:"timestamp"`
+		LogLevel   string                 `json:"logLevel"`
+		ModuleName string                 `json:"moduleName"`
+		Submodules json.RawMessage        `json:"submodules"`
+		LogMessage string                 `json:"logMessage"`
+		Fields     map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(line), &data); err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{
+		Time:    parsedTime(data.Timestamp, dateFormat),
+		Module:  data.ModuleName,
+		Message: data.LogMessage,
+		Fields:  data.Fields,
+	}
+	parsedLevel(&e, data.LogLevel)
+	if len(data.Submodules) > 0 {
+		var chain string
+		if err := json.Unmarshal(data.Submodules, &e.Submodules); err != nil {
+			if err := json.Unmarshal(data.Submodules, &chain); err != nil {
+				return Entry{}, fmt.Errorf("invalid submodules: %w", err)
+			}
+			e.Submodules = splitSubmodules(chain, MKLOG_SubmoduleSeparatorDefault)
+		}
+	}
+	return e, nil
+}
+
+// parseLogfmtLine parses a line written by LogfmtFormatter. Submodules are split on
+// MKLOG_SubmoduleSeparatorDefault, or on commas in files of older versions.
+func parseLogfmtLine(line string, dateFormat string) (Entry, error) {
+	pairs, err := tokenizeLogfmt(line)
+	if err != nil {
+		return Entry{}, err
+	}
+	if _, ok := pairs["msg"]; !ok {
+		return Entry{}, fmt.Errorf("missing msg key")
+	}
+
+	e := Entry{
+		Time:    parsedTime(pairs["time"], dateFormat),
+		Module:  pairs["module"],
+		Message: pairs["msg"],
+	}
+	parsedLevel(&e, pairs["level"])
+	if subs := pairs["submodules"]; subs != "" {
+		e.Submodules = splitSubmodules(subs, ",")
+	}
+
+	// Remaining pairs are the fields of the entry.
+	for k, v := range pairs {
+		switch k {
+		case "time", "level", "module", "submodules", "msg":
+			continue
+		}
+		if e.Fields == nil {
+			e.Fields = make(map[string]interface{})
+		}
+		e.Fields[k] = v
+	}
+	return e, nil
+}
+
+// tokenizeLogfmt splits a logfmt line into its key=value pairs, unquoting quoted values.
+func tokenizeLogfmt(line string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		eq := strings.IndexByte(line[i:], '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid logfmt pair at offset %d", i)
+		}
+		key := line[i : i+eq]
+		if strings.ContainsAny(key, " \"") {
+			return nil, fmt.Errorf("invalid logfmt key %q", key)
+		}
+		i += eq + 1
+
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for ; end < len(line); end++ {
+				if line[end] == '\\' {
+					end++
+				} else if line[end] == '"' {
+					break
+				}
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated quoted value for key %q", key)
+			}
+			value, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value for key %q: %w", key, err)
+			}
+			pairs[key] = value
+			i = end + 1
+		} else {
+			end := strings.IndexByte(line[i:], ' ')
+			if end < 0 {
+				end = len(line) - i
+			}
+			pairs[key] = line[i : i+end]
+			i += end
+		}
+	}
+	return pairs, nil
+}
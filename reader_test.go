@@ -0,0 +1,159 @@
+package mklog
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writtenEntry is an entry logged by the round-trip tests.
+type writtenEntry struct {
+	level   LogLevel
+	module  string
+	message string
+	fields  map[string]interface{}
+	text    string // Message read back from the text formatters, which append the fields to it
+}
+
+var writtenEntries = []writtenEntry{
+	{InfoLevel, "app", "server started", nil, "server started"},
+	{WarningLevel, "app", `quoted "value" with = and | separators`, map[string]interface{}{"user": "alice", "attempt": "3"},
+		`quoted "value" with = and | separators attempt=3 user=alice`},
+	{ErrorLevel, "db", "connection lost\nretrying in 5s", map[string]interface{}{"host": "db-1"}, "connection lost\nretrying in 5s host=db-1"},
+	{FatalLevel, "db", "giving up", nil, "giving up"},
+}
+
+// TestFileReaderRoundTrip writes entries through a rule with every built-in formatter and date format preset,
+// then reads them back from the log file and from a gzip-compressed copy of it.
+func TestFileReaderRoundTrip(t *testing.T) {
+	formats := []string{"plain", "fasttext", "json", "logfmt"}
+	presets := []string{"rfc3339", "rfc3339nano", "iso8601", "unix", "unix_ms", "kitchen"}
+	at := time.Date(2024, 3, 9, 10, 20, 30, 123456789, time.Local)
+
+	for _, format := range formats {
+		for _, preset := range presets {
+			t.Run(format+"/"+preset, func(t *testing.T) {
+				dateFormat, err := ResolveDateFormat(preset)
+				if err != nil {
+					t.Fatal(err)
+				}
+				options := map[string]interface{}{}
+				if format == "plain" || format == "fasttext" {
+					options["multiline"] = MultilineIndent // Read back as one entry.
+				}
+				formatter, err := builtinFormatters[format].factory(dateFormat, options)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				dir := t.TempDir()
+				d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return at })
+				for _, module := range []string{"app", "db"} {
+					d.NewLogRule(module,
+						WithMinLevel(TraceLevel),
+						WithMaxLevel(FatalLevel),
+						WithLogFormatter(formatter),
+						WithDateFormat(dateFormat),
+						WithFileLogging(dir, "app", ".log"),
+						func(lr *LogRule) {
+							if lr.ModuleName == "db" {
+								lr.Submodules = []string{"pool", "conn"}
+							}
+						},
+					)
+				}
+				for _, w := range writtenEntries {
+					var args []interface{}
+					for key, value := range w.fields {
+						args = append(args, key, value)
+					}
+					d.Module(w.module).With(args...).Custom(w.level, "%s", w.message)
+				}
+				if _, err := d.Close(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+
+				path := filepath.Join(dir, "app.log")
+				checkReadBack(t, path, format, dateFormat, at)
+				checkReadBack(t, gzipCopy(t, path), format, dateFormat, at)
+			})
+		}
+	}
+}
+
+// checkReadBack reads the log file and compares its entries with writtenEntries.
+func checkReadBack(t *testing.T, path, format, dateFormat string, at time.Time) {
+	t.Helper()
+	r, err := NewFileReader(path, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.DateFormat = dateFormat
+
+	var got []Entry
+	for r.Next() {
+		got = append(got, r.Entry())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Skipped() != 0 {
+		t.Errorf("%s: skipped %d lines", filepath.Base(path), r.Skipped())
+	}
+	if len(got) != len(writtenEntries) {
+		t.Fatalf("%s: read %d entries, want %d", filepath.Base(path), len(got), len(writtenEntries))
+	}
+
+	for i, w := range writtenEntries {
+		e := got[i]
+		message := w.message
+		if format == "plain" || format == "fasttext" {
+			message = w.text
+		}
+		if e.Level != w.level || e.Module != w.module || e.Message != message {
+			t.Errorf("entry %d = %s [%s] %q, want %s [%s] %q", i, e.LevelName, e.Module, e.Message,
+				w.level.GetLogLevelName(), w.module, message)
+		}
+		if want := formatTimestamp(at, dateFormat); formatTimestamp(e.Time, dateFormat) != want {
+			t.Errorf("entry %d has time %v, want %s", i, e.Time, want)
+		}
+		if w.module == "db" && fmt.Sprint(e.Submodules) != "[pool conn]" {
+			t.Errorf("entry %d has submodules %q, want pool and conn", i, e.Submodules)
+		}
+		if format == "json" || format == "logfmt" {
+			for key, value := range w.fields {
+				if fmt.Sprint(e.Fields[key]) != fmt.Sprint(value) {
+					t.Errorf("entry %d has field %s = %v, want %v", i, key, e.Fields[key], value)
+				}
+			}
+		}
+	}
+}
+
+// gzipCopy writes a gzip-compressed copy of the file next to it, like a compressed rotated file, and returns
+// its path.
+func gzipCopy(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
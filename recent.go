@@ -0,0 +1,109 @@
+package mklog
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// RecentEntry is an entry kept in the recent buffer, see SetRecentBuffer.
+type RecentEntry struct {
+	Time       time.Time              `json:"time"`                 // Time when the entry was logged.
+	Level      LogLevel               `json:"level"`                // Log level of the entry.
+	LevelName  string                 `json:"level_name"`           // Display name of the log level for the matching rule.
+	Module     string                 `json:"module"`               // Name of the module of the matching rule.
+	Submodules []string               `json:"submodules,omitempty"` // Submodules of the matching rule.
+	Message    string                 `json:"message"`              // Log message with the arguments applied.
+	Error      string                 `json:"error,omitempty"`      // Message of the error of the entry, if any.
+	Fields     map[string]interface{} `json:"fields,omitempty"`     // Structured fields attached to the entry.
+	Rule       RuleRef                `json:"rule"`                 // Rule the entry was dispatched to.
+}
+
+// RecentFilter selects the entries returned by Recent.
+type RecentFilter struct {
+	MinLevel LogLevel // Minimum level of the returned entries.
+	Module   string   // Module of the returned entries, every module when empty.
+	Limit    int      // Maximum number of returned entries, keeping the newest; zero returns all.
+}
+
+// recentBuffer is a lock-free ring of the latest entries accepted by any rule. Writers claim a slot with
+// an atomic counter and publish the entry with a pointer swap, so readers take snapshots without locking.
+type recentBuffer struct {
+	slots []atomic.Pointer[recentSlot] // Ring of the recorded entries
+	next  atomic.Uint64                // Sequence number of the next entry
+}
+
+// recentSlot is an entry of the recent buffer with its sequence number.
+type recentSlot struct {
+	seq   uint64
+	entry RecentEntry
+}
+
+// SetRecentBuffer keeps the last n entries accepted by any rule of the Debugger in memory, retrievable with
+// Recent and from GET /logs/recent of the AdminHandler. Entries are recorded after the middleware chain,
+// so RedactMiddleware applies to them as well. Zero or a negative n disables the buffer.
+func (d *Debugger) SetRecentBuffer(n int) *Debugger {
+	if n <= 0 {
+		d.recent.Store(nil)
+		return d
+	}
+	d.recent.Store(&recentBuffer{slots: make([]atomic.Pointer[recentSlot], n)})
+	return d
+}
+
+// recordRecent adds the entry to the recent buffer, if any.
+func (d *Debugger) recordRecent(entry Entry) {
+	r := d.recent.Load()
+	if r == nil {
+		return
+	}
+
+	slot := &recentSlot{
+		seq: r.next.Add(1) - 1,
+		entry: RecentEntry{
+			Time:       entry.Time,
+			Level:      entry.Level,
+			LevelName:  entry.LevelName,
+			Module:     entry.Module,
+			Submodules: entry.Submodules,
+			Message:    entry.Message,
+			Fields:     entry.Fields,
+			Rule:       entry.Rule,
+		},
+	}
+	if entry.Err != nil {
+		slot.entry.Error = entry.Err.Error()
+	}
+	r.slots[slot.seq%uint64(len(r.slots))].Store(slot)
+}
+
+// Recent returns the entries of the recent buffer matching the filter, oldest first.
+// It returns nil when the buffer is disabled.
+func (d *Debugger) Recent(filter RecentFilter) []RecentEntry {
+	r := d.recent.Load()
+	if r == nil {
+		return nil
+	}
+
+	slots := make([]*recentSlot, 0, len(r.slots))
+	for i := range r.slots {
+		slot := r.slots[i].Load()
+		if slot == nil || slot.entry.Level < filter.MinLevel {
+			continue
+		}
+		if filter.Module != "" && slot.entry.Module != filter.Module {
+			continue
+		}
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].seq < slots[j].seq })
+
+	if filter.Limit > 0 && len(slots) > filter.Limit {
+		slots = slots[len(slots)-filter.Limit:]
+	}
+	entries := make([]RecentEntry, len(slots))
+	for i, slot := range slots {
+		entries[i] = slot.entry
+	}
+	return entries
+}
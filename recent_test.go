@@ -0,0 +1,139 @@
+package mklog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// recentMessages returns the messages of the entries.
+func recentMessages(entries []RecentEntry) []string {
+	messages := make([]string, len(entries))
+	for i, e := range entries {
+		messages[i] = e.Message
+	}
+	return messages
+}
+
+// TestRecentBufferOverflow keeps only the newest entries, oldest first, once more entries were logged than the
+// buffer holds.
+func TestRecentBufferOverflow(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true).SetRecentBuffer(3)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	defer d.Close(context.Background())
+
+	if got := d.Recent(RecentFilter{}); len(got) != 0 {
+		t.Fatalf("empty buffer returned %v", recentMessages(got))
+	}
+	for i := 1; i <= 7; i++ {
+		d.Info("entry %d", i)
+	}
+	if got := fmt.Sprint(recentMessages(d.Recent(RecentFilter{}))); got != "[entry 5 entry 6 entry 7]" {
+		t.Errorf("recent = %s, want the last three entries", got)
+	}
+}
+
+// TestRecentBufferFilter selects the entries by minimum level, module and limit.
+func TestRecentBufferFilter(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true).SetRecentBuffer(16)
+	d.NewLogRule("api", WithFileWriter(&syncBuffer{}), WithMinLevel(DebugLevel))
+	d.NewLogRule("db", WithFileWriter(&syncBuffer{}), WithMinLevel(DebugLevel))
+	defer d.Close(context.Background())
+
+	api, db := d.Module("api"), d.Module("db")
+	api.Debug("api debug")
+	api.Error("api error 1")
+	db.Warning("db warning")
+	db.Error("db error")
+	api.Error("api error 2")
+
+	tests := []struct {
+		filter RecentFilter
+		want   string
+	}{
+		{RecentFilter{MinLevel: ErrorLevel}, "[api error 1 db error api error 2]"},
+		{RecentFilter{MinLevel: WarningLevel, Module: "db"}, "[db warning db error]"},
+		{RecentFilter{Module: "api", Limit: 2}, "[api error 1 api error 2]"},
+		{RecentFilter{MinLevel: FatalLevel}, "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(recentMessages(d.Recent(tt.filter))); got != tt.want {
+			t.Errorf("Recent(%+v) = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+// TestRecentBufferDisabled returns nil without a buffer and after the buffer was disabled.
+func TestRecentBufferDisabled(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	defer d.Close(context.Background())
+
+	d.Info("not kept")
+	if got := d.Recent(RecentFilter{}); got != nil {
+		t.Errorf("Recent without a buffer = %v", got)
+	}
+	d.SetRecentBuffer(4)
+	d.Info("kept")
+	d.SetRecentBuffer(0)
+	if got := d.Recent(RecentFilter{}); got != nil {
+		t.Errorf("Recent after disabling = %v", got)
+	}
+}
+
+// TestRecentBufferRedacted records the entries after the middleware chain, so redacted secrets never reach the
+// buffer.
+func TestRecentBufferRedacted(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true).SetRecentBuffer(4)
+	d.Use(RedactMiddleware(regexp.MustCompile(`token=\w+`), "token=***"))
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	defer d.Close(context.Background())
+
+	d.With("auth", "token=abc123").Info("login with token=abc123")
+	got := d.Recent(RecentFilter{})
+	if len(got) != 1 || got[0].Message != "login with token=***" || got[0].Fields["auth"] != "token=***" {
+		t.Errorf("recent = %+v, want the secret redacted", got)
+	}
+}
+
+// TestAdminRecent returns the filtered entries as JSON from the admin handler and rejects invalid parameters.
+func TestAdminRecent(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true).SetRecentBuffer(8)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	defer d.Close(context.Background())
+	for i := 1; i <= 4; i++ {
+		d.Error("failure %d", i)
+		d.Info("request %d", i)
+	}
+
+	rec := httptest.NewRecorder()
+	d.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs/recent?level=error&limit=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var entries []RecentEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(recentMessages(entries)); got != "[failure 3 failure 4]" || entries[0].Module != "app" || entries[0].Level != ErrorLevel {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	for _, query := range []string{"level=loud", "limit=-1", "limit=many"} {
+		rec := httptest.NewRecorder()
+		d.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs/recent?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	d.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/logs/recent", strings.NewReader("")))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,133 @@
+package mklog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Formatters and sinks registered for all LogConfigManager instances, typically from the init function of a
+// package providing them, so importing the package is enough to use them in configuration files:
+//
+//	package acmelog
+//
+//	func init() {
+//		if err := mklog.RegisterFormatter("acme", newAcmeFormatter, "tenant"); err != nil {
+//			panic(err)
+//		}
+//		if err := mklog.RegisterSink("acme-bus", newAcmeBusSink); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+// and in the application
+//
+//	import _ "example.com/acme/acmelog"
+//
+// Packages built as Go plugins can be loaded at runtime instead, see LoadPlugin.
+var (
+	registryMu       sync.RWMutex
+	globalFormatters = make(map[string]formatterEntry)
+	globalSinks      = make(map[string]SinkFactory)
+)
+
+// PluginSymbol is the name of the func() error a plugin exports to register its formatters and sinks, see
+// LoadPlugin.
+const PluginSymbol = "MklogRegister"
+
+// RegisterFormatter registers a formatter factory under a log_formatter type name for all LogConfigManager
+// instances. The keys are the options the factory consumes, see LogConfigManager.RegisterFormatter. Names are
+// case-insensitive; registering a built-in name or a name that is already registered returns an error, so two
+// imported packages cannot silently replace each other's formatter. Formatters registered with a manager take
+// precedence over the ones registered here.
+func RegisterFormatter(name string, factory FormatterFactory, keys ...string) error {
+	key, err := registryName("formatter", name)
+	if err != nil {
+		return err
+	}
+	if factory == nil {
+		return fmt.Errorf("[mklog] formatter %q has no factory", name)
+	}
+	if _, ok := builtinFormatters[key]; ok {
+		return fmt.Errorf("[mklog] formatter name %q is reserved for a built-in formatter", name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := globalFormatters[key]; ok {
+		return fmt.Errorf("[mklog] formatter %q is already registered", name)
+	}
+	globalFormatters[key] = formatterEntry{factory: factory, keys: keys}
+	return nil
+}
+
+// RegisterSink registers a sink factory under a sink type name for all LogConfigManager instances, like
+// RegisterFormatter. Registering a name that is already registered returns an error. Sinks registered with a
+// manager take precedence over the ones registered here.
+func RegisterSink(name string, factory SinkFactory) error {
+	key, err := registryName("sink", name)
+	if err != nil {
+		return err
+	}
+	if factory == nil {
+		return fmt.Errorf("[mklog] sink %q has no factory", name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := globalSinks[key]; ok {
+		return fmt.Errorf("[mklog] sink %q is already registered", name)
+	}
+	globalSinks[key] = factory
+	return nil
+}
+
+// RegisteredFormatters returns the sorted names of the formatters registered with RegisterFormatter.
+func RegisteredFormatters() []string {
+	registryMu.RLock()
+	names := make([]string, 0, len(globalFormatters))
+	for name := range globalFormatters {
+		names = append(names, name)
+	}
+	registryMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// RegisteredSinks returns the sorted names of the sinks registered with RegisterSink.
+func RegisteredSinks() []string {
+	registryMu.RLock()
+	names := make([]string, 0, len(globalSinks))
+	for name := range globalSinks {
+		names = append(names, name)
+	}
+	registryMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// registryName returns the lower-case form of a registered name, failing for empty names.
+func registryName(kind, name string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		return "", fmt.Errorf("[mklog] %s name must not be empty", kind)
+	}
+	return key, nil
+}
+
+// registeredFormatter returns the formatter registered with RegisterFormatter under the lower-case name.
+func registeredFormatter(key string) (formatterEntry, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	entry, ok := globalFormatters[key]
+	return entry, ok
+}
+
+// registeredSink returns the sink registered with RegisterSink under the lower-case name.
+func registeredSink(key string) (SinkFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := globalSinks[key]
+	return factory, ok
+}
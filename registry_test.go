@@ -0,0 +1,146 @@
+package mklog_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SHEP4RDO/mklog"
+	"github.com/SHEP4RDO/mklog/testdata/acmelog"
+)
+
+// writeRegistryConfig writes a configuration with a rule of module app to a temporary file and returns its path.
+func writeRegistryConfig(t *testing.T, rule string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "log_rules:\n  app:\n    - min_level: INFO\n      max_level: FATAL\n" + rule
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestRegistryInitRegistration lists the formatter and sink the imported acmelog package registers from its
+// init function, for the registries and for every manager.
+func TestRegistryInitRegistration(t *testing.T) {
+	contains := func(names []string, name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+	if !contains(mklog.RegisteredFormatters(), "acme") || !contains(mklog.RegisteredSinks(), "acme-bus") {
+		t.Errorf("registered formatters %v, sinks %v, want acme and acme-bus", mklog.RegisteredFormatters(), mklog.RegisteredSinks())
+	}
+	m := mklog.NewLogConfigManager()
+	if !contains(m.Formatters(), "acme") || !contains(m.Formatters(), "json") || !contains(m.Sinks(), "acme-bus") {
+		t.Errorf("manager formatters %v, sinks %v, want the registered and built-in names", m.Formatters(), m.Sinks())
+	}
+}
+
+// TestRegistryDuplicates rejects registering built-in and already registered names in any case, empty names
+// and missing factories.
+func TestRegistryDuplicates(t *testing.T) {
+	formatter := func(string, map[string]interface{}) (mklog.LogFormatter, error) { return mklog.JSONFormatter{}, nil }
+	sink := func(map[string]interface{}) (mklog.Sink, error) { return nil, nil }
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"formatter twice", mklog.RegisterFormatter("ACME", formatter), `formatter "ACME" is already registered`},
+		{"built-in formatter", mklog.RegisterFormatter("json", formatter), `formatter name "json" is reserved for a built-in formatter`},
+		{"empty formatter", mklog.RegisterFormatter("  ", formatter), "formatter name must not be empty"},
+		{"nil formatter", mklog.RegisterFormatter("unused-formatter", nil), `formatter "unused-formatter" has no factory`},
+		{"sink twice", mklog.RegisterSink("Acme-Bus", sink), `sink "Acme-Bus" is already registered`},
+		{"empty sink", mklog.RegisterSink("", sink), "sink name must not be empty"},
+		{"nil sink", mklog.RegisterSink("unused-sink", nil), `sink "unused-sink" has no factory`},
+	}
+	for _, tt := range tests {
+		if tt.err == nil || !strings.Contains(tt.err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, tt.err, tt.want)
+		}
+	}
+	for _, name := range mklog.RegisteredFormatters() {
+		if name == "unused-formatter" {
+			t.Error("formatter without a factory was registered")
+		}
+	}
+}
+
+// TestRegistryConfigResolution resolves the formatter and sink of a configuration by the names acmelog
+// registered them with, passing their options.
+func TestRegistryConfigResolution(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	path := writeRegistryConfig(t, `      log_formatter: {type: Acme, tenant: t1}
+      file_log: {enable: true, file_path: `+dir+`, file_name: app, file_type: .log}
+      sinks: [{type: acme-bus, options: {topic: audit}}]
+`)
+	d, err := mklog.NewLogConfigManager().SetQuiet(true).LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Info("hello")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "t1/INFO/app: hello\n" {
+		t.Errorf("log = %q, want the acme format", got)
+	}
+	bus, ok := acmelog.Buses.Load("audit")
+	if !ok {
+		t.Fatal("sink of topic audit not created")
+	}
+	if entries := bus.(*acmelog.Bus).Entries(); len(entries) != 1 || entries[0] != "t1/INFO/app: hello" {
+		t.Errorf("sink entries = %q, want the formatted entry", entries)
+	}
+
+	bad := writeRegistryConfig(t, "      log_formatter: {type: acme}\n      console_output: true\n")
+	if _, err := mklog.NewLogConfigManager().SetQuiet(true).LoadConfig(bad); err == nil || !strings.Contains(err.Error(), "option tenant is required") {
+		t.Errorf("LoadConfig error = %v, want the factory error", err)
+	}
+}
+
+// TestRegistryManagerPrecedence prefers formatters and sinks registered with a manager over the package-level
+// registrations of the same name.
+func TestRegistryManagerPrecedence(t *testing.T) {
+	var local []string
+	m := mklog.NewLogConfigManager().SetQuiet(true)
+	if err := m.RegisterFormatter("acme", func(string, map[string]interface{}) (mklog.LogFormatter, error) {
+		return mklog.LogfmtFormatter{}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m.RegisterSink("acme-bus", func(options map[string]interface{}) (mklog.Sink, error) {
+		local = append(local, options["topic"].(string))
+		return &acmelog.Bus{}, nil
+	})
+
+	d, err := m.LoadConfig(writeRegistryConfig(t, "      log_formatter: {type: acme}\n      sinks: [{type: acme-bus, options: {topic: local}}]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	if rules := d.Rules(); len(rules) != 1 || rules[0].Formatter != "mklog.LogfmtFormatter" || len(local) != 1 {
+		t.Errorf("rules %+v, local sinks %v, want the formatter and sink of the manager", rules, local)
+	}
+	if _, ok := acmelog.Buses.Load("local"); ok {
+		t.Error("package-level sink created despite the manager sink")
+	}
+}
+
+// TestLoadPluginWithoutTag fails to load plugins in builds without the mklog_plugin tag.
+func TestLoadPluginWithoutTag(t *testing.T) {
+	if err := mklog.LoadPlugin("acme.so"); err == nil || !strings.Contains(err.Error(), "acme.so") {
+		t.Errorf("LoadPlugin error = %v, want it to name the plugin", err)
+	}
+}
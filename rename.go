@@ -0,0 +1,25 @@
+package mklog
+
+import (
+	"os"
+	"time"
+)
+
+var (
+	// Retries of renaming a log file held open by another process on Windows
+	MKLOG_RenameRetryAttempts     = 5
+	MKLOG_RenameRetryDelayDefault = 50 * time.Millisecond // Delay before the first retry, doubled for every further retry
+)
+
+// renameFile renames the file, retrying while another process holds it open on Windows.
+func renameFile(oldName, newName string) error {
+	delay := MKLOG_RenameRetryDelayDefault
+	for attempt := 0; ; attempt++ {
+		err := os.Rename(oldName, newName)
+		if err == nil || attempt >= MKLOG_RenameRetryAttempts || !isSharingViolation(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package mklog
+
+// isSharingViolation returns false, as open files can be renamed and removed outside Windows.
+func isSharingViolation(err error) bool {
+	return false
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package mklog
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows error codes of files opened by another process
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// isSharingViolation reports whether the error is caused by another process holding the file open,
+// e.g. a log shipper or a virus scanner, so that retrying the operation may succeed.
+func isSharingViolation(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == errorSharingViolation || errno == errorLockViolation || errno == errorAccessDenied
+}
@@ -0,0 +1,874 @@
+package mklog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingWriterOptions configures the files managed by a RotatingWriter.
+type RotatingWriterOptions struct {
+	FilePath         string           // Directory where the log files are stored.
+	FileName         string           // Base name of the log file.
+	FileType         string           // Type (extension) of the log file, e.g. ".log".
+	IsDateFile       bool             // Prefix the file name with the current date, starting a new file when the date changes.
+	DateFileFormat   string           // Date format used in the file name.
+	TimeFolder       bool             // Store the files in time-based folders.
+	TimeFolderFormat string           // Time format of the folder names.
+	FileFolderPeriod time.Duration    // Period covered by one folder.
+	MaxFileSize      int64            // Maximum size of the file, zero disables the limit.
+	MaxBackups       int              // Number of rotated files kept when the size limit is reached; zero trims the beginning of the file instead.
+	CopyTruncate     bool             // Rotate by copying the file to the first backup and truncating it, for files held open by other processes.
+	MaxFileLines     int64            // Maximum number of lines of the file, counted at opening; reaching it rotates the file like the size limit, keeping at least one backup. Zero disables the limit.
+	MaxAge           time.Duration    // Retention of rotated and dated files, zero keeps them forever.
+	MaxDateFiles     int              // Number of dated files kept besides the active one, zero keeps them all; applies to dated files without time folders.
+	Compress         bool             // Write gzip-compressed files (".gz" is appended to the name); the size limit applies to compressed bytes.
+	FlushInterval    time.Duration    // Interval of flushing the compressed stream so the file tail stays readable.
+	RotationInterval time.Duration    // Period after which a new file is started, aligned to wall-clock boundaries; zero disables interval rotation.
+	ArchiveAfter     time.Duration    // Age after which rotated and dated files are moved into zip archives; zero disables archiving.
+	ArchiveGroupBy   string           // Grouping of the archives, ArchiveByMonth (default) or ArchiveByWeek.
+	Header           string           // Header written at the start of every new or empty file, including its line ending.
+	BOM              bool             // Start every new or empty file with a UTF-8 byte order mark, before the header.
+	OpenMode         string           // OpenAppend (default) or OpenTruncate, emptying the first file the writer opens.
+	FileNamePolicy   string           // FileNameReject (default) or FileNameSanitize, handling characters invalid in file names.
+	Now              func() time.Time // Source of the current time, time.Now when nil.
+}
+
+var (
+	// Default flush interval of compressed log files
+	MKLOG_CompressFlushIntervalDefault = time.Second
+
+	// Default time format of the period prefix of interval-rotated files
+	MKLOG_RotationFileFormatDefault = "2006-01-02_15-04"
+)
+
+// RotatingWriter is an io.WriteCloser writing to log files with the same date-file, time-folder
+// and size-limit management used by file logging of a LogRule.
+// It is safe for concurrent use, so other components can share the file handling without the Debugger formatting.
+type RotatingWriter struct {
+	options RotatingWriterOptions
+
+	mu        sync.Mutex
+	file      *os.File         // Currently open file
+	gz        *gzip.Writer     // Compressed stream over the file when compression is enabled
+	name      string           // Full name of the currently open file
+	head      int64            // Size of the header written at the start of the open file, kept when it is trimmed
+	size      int64            // Size of the currently open file
+	lines     int64            // Lines of the currently open file, counted with MaxFileLines only
+	headLines int64            // Lines of the header written at the start of the open file
+	now       func() time.Time // Source of the current time
+	flush     *time.Timer      // Pending flush of the compressed stream
+
+	lastErr  error // Error of the last write, nil after a successful write
+	openErr  error // Error of opening the next file while the writer stays on the current one, see Healthy
+	truncate bool  // Whether the next opened file is emptied, set until the first file is opened
+	closed   bool  // Whether Close was called; otherwise a file that failed to open is retried by the next write
+}
+
+// NewRotatingWriter creates the log directory and opens the current log file.
+// Compression cannot be combined with a size limit trimming the file, since a gzip stream cannot be trimmed in place.
+func NewRotatingWriter(options RotatingWriterOptions) (*RotatingWriter, error) {
+	if options.Compress {
+		if options.MaxFileSize > 0 && options.MaxBackups <= 0 {
+			return nil, fmt.Errorf("compressed log files cannot be trimmed, set MaxBackups to rotate them instead")
+		}
+		if options.FlushInterval <= 0 {
+			options.FlushInterval = MKLOG_CompressFlushIntervalDefault
+		}
+	}
+
+	if !validOpenMode(options.OpenMode) {
+		return nil, fmt.Errorf("unsupported open mode: %s", options.OpenMode)
+	}
+
+	var err error
+	if options.FileName, options.FileType, err = checkFileName(options.FileName, options.FileType, options.FileNamePolicy); err != nil {
+		return nil, err
+	}
+
+	switch options.ArchiveGroupBy {
+	case "":
+		options.ArchiveGroupBy = ArchiveByMonth
+	case ArchiveByMonth, ArchiveByWeek:
+	default:
+		return nil, fmt.Errorf("unsupported archive grouping: %s", options.ArchiveGroupBy)
+	}
+
+	w := &RotatingWriter{
+		options:  options,
+		now:      options.Now,
+		truncate: options.OpenMode == OpenTruncate,
+	}
+	if w.now == nil {
+		w.now = time.Now
+	}
+	if err := w.options.checkRenderedName(w.now()); err != nil {
+		return nil, err
+	}
+
+	if err := w.open(w.currentFileName(w.now())); err != nil {
+		return nil, err
+	}
+	w.removeSurplusDateFiles()
+	return w, nil
+}
+
+// Write writes p to the current log file, starting a new file first when the date, folder
+// or rotation period has changed and enforcing the size limit.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	return w.WriteAt(w.now(), p)
+}
+
+// WriteAt writes p like Write, choosing the file for the time t the message was logged rather than
+// the current time, so a message queued before midnight lands in the file of its own day.
+func (w *RotatingWriter) WriteAt(t time.Time, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.write(t, p)
+	w.lastErr = err
+	return n, err
+}
+
+// write writes p to the file for the time t, with the lock held.
+func (w *RotatingWriter) write(t time.Time, p []byte) (int, error) {
+	if w.file == nil {
+		if w.closed {
+			return 0, fmt.Errorf("log file is not open")
+		}
+		// A rotation could not reopen the file, try again rather than losing every later entry.
+		if err := w.open(w.currentFileName(t)); err != nil {
+			return 0, err
+		}
+	}
+
+	// Check if the log file name has changed and create a new log file if necessary.
+	// While the new file cannot be opened the entry is kept in the current one, and the next write tries again.
+	if fileName := w.currentFileName(t); fileName != w.name {
+		err := w.switchFile(fileName)
+		if w.name != fileName {
+			w.openErr = err
+			return w.writeCurrent(p)
+		}
+		w.openErr = nil
+		if err != nil {
+			return 0, err
+		}
+		w.removeExpired()
+		w.removeSurplusDateFiles()
+	}
+	return w.writeCurrent(p)
+}
+
+// writeCurrent writes p to the open file, enforcing the size and line limits, with the lock held.
+func (w *RotatingWriter) writeCurrent(p []byte) (int, error) {
+	// Compressed files only count the bytes already written to disk, as the compressed size of p is unknown.
+	incoming := int64(len(p))
+	if w.gz != nil {
+		incoming = 0
+	}
+
+	// Check if the log file size limit is enabled and rotate or trim if necessary.
+	if w.options.MaxFileSize > 0 && w.size+incoming > w.options.MaxFileSize {
+		if w.options.MaxBackups > 0 {
+			if w.size > 0 {
+				if err := w.rotate(w.options.MaxBackups); err != nil {
+					return 0, fmt.Errorf("failed to rotate log file: %w", err)
+				}
+			}
+		} else {
+			overSize := (w.size + int64(len(p))) - w.options.MaxFileSize
+			if err := w.trim(overSize); err != nil {
+				return 0, fmt.Errorf("failed to trim log file: %w", err)
+			}
+		}
+	}
+
+	// Check if the line limit is enabled and rotate if the file holds more than its header.
+	if w.options.MaxFileLines > 0 {
+		lines := int64(bytes.Count(p, []byte{'\n'}))
+		if w.lines > w.headLines && w.lines+lines > w.options.MaxFileLines {
+			if err := w.rotate(w.lineBackups()); err != nil {
+				return 0, fmt.Errorf("failed to rotate log file: %w", err)
+			}
+		}
+		w.lines += lines
+	}
+
+	if w.gz != nil {
+		n, err := w.gz.Write(p)
+		if err != nil {
+			return n, err
+		}
+		// With a size limit the stream is flushed right away so the size reflects the compressed bytes.
+		if w.options.MaxFileSize > 0 {
+			return n, w.gz.Flush()
+		}
+		w.scheduleFlush()
+		return n, nil
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Healthy reports whether the current log file is open, the last write succeeded and the writer did not stay
+// on the current file because the next one could not be opened.
+// It only inspects the open file handle, so it is cheap enough for frequent readiness probes.
+func (w *RotatingWriter) Healthy() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("log file is not open")
+	}
+	if _, err := w.file.Stat(); err != nil {
+		return fmt.Errorf("log file %s is not accessible: %w", w.name, err)
+	}
+	if w.lastErr != nil {
+		return fmt.Errorf("last write to %s failed: %w", w.name, w.lastErr)
+	}
+	if w.openErr != nil {
+		return fmt.Errorf("still writing to %s: %w", w.name, w.openErr)
+	}
+	return nil
+}
+
+// Close finishes the compressed stream, commits the current log file to stable storage and closes it,
+// returning the errors naming the file.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+
+	var errs []error
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("finishing compressed log file %s: %w", w.name, err))
+		}
+		w.gz = nil
+	}
+	if err := w.file.Sync(); err != nil {
+		errs = append(errs, fmt.Errorf("syncing log file %s: %w", w.name, err))
+	}
+	if err := w.closeFile(); err != nil {
+		errs = append(errs, fmt.Errorf("closing log file %s: %w", w.name, err))
+	}
+	return errors.Join(errs...)
+}
+
+// Flush writes the data buffered by the compressed stream to the current file without committing it to stable storage.
+func (w *RotatingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.gz == nil {
+		return nil
+	}
+	return w.gz.Flush()
+}
+
+// Sync flushes the compressed stream and commits the current file to stable storage.
+func (w *RotatingWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	return w.file.Sync()
+}
+
+// closeFile finishes the compressed stream, closes the current file and releases it as shared file.
+func (w *RotatingWriter) closeFile() error {
+	if w.flush != nil {
+		w.flush.Stop()
+		w.flush = nil
+	}
+
+	err := closeHandle(w.file, w.gz, w.name)
+	w.gz = nil
+	w.file = nil
+	w.saveLineCount(w.name, w.lines)
+	return err
+}
+
+// switchFile opens the file and closes the previous one once the new one is open, so that a failed opening
+// leaves the writer on the previous file.
+func (w *RotatingWriter) switchFile(fileName string) error {
+	file, gz, name, lines := w.file, w.gz, w.name, w.lines
+	err := w.open(fileName)
+	if w.file == file {
+		return err
+	}
+
+	if w.flush != nil {
+		w.flush.Stop()
+		w.flush = nil
+	}
+	if closeErr := closeHandle(file, gz, name); err == nil {
+		err = closeErr
+	}
+	w.saveLineCount(name, lines)
+	return err
+}
+
+// closeHandle finishes the compressed stream, closes the file and releases it as shared file.
+func closeHandle(file *os.File, gz *gzip.Writer, name string) error {
+	var err error
+	if gz != nil {
+		err = gz.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	releaseSharedFile(name)
+	return err
+}
+
+// scheduleFlush flushes the compressed stream after the flush interval unless a flush is already pending.
+func (w *RotatingWriter) scheduleFlush() {
+	if w.flush != nil {
+		return
+	}
+	w.flush = time.AfterFunc(w.options.FlushInterval, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		w.flush = nil
+		if w.gz != nil {
+			w.gz.Flush()
+		}
+	})
+}
+
+// FileName returns the full name of the current log file.
+func (w *RotatingWriter) FileName() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.name
+}
+
+// File returns the currently open log file, or nil after Close.
+func (w *RotatingWriter) File() *os.File {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file
+}
+
+// currentFileName returns the full name of the log file for the time.
+func (w *RotatingWriter) currentFileName(t time.Time) string {
+	return w.options.fileName(t)
+}
+
+// checkRenderedName checks the file name rendered for the time, including its date and time folder, against
+// the file name policy.
+func (o *RotatingWriterOptions) checkRenderedName(t time.Time) error {
+	rel, err := filepath.Rel(o.FilePath, o.fileName(t))
+	if err != nil || escapesDir(rel) {
+		return fmt.Errorf("file name %q escapes the log directory", rel)
+	}
+	if o.FileNamePolicy != FileNameSanitize && hasInvalidFileNameChars(rel) {
+		return fmt.Errorf("file name %q contains characters invalid in file names (%s or control characters), check the date and folder formats", rel, invalidFileNameChars)
+	}
+	return nil
+}
+
+// namePart returns the rendered part of a file name with invalid characters replaced when the policy sanitizes them.
+func (o *RotatingWriterOptions) namePart(part string) string {
+	if o.FileNamePolicy == FileNameSanitize {
+		part, _ = cleanFileNamePart(part, FileNameSanitize)
+	}
+	return part
+}
+
+// fileName returns the full name of the log file the options select for the time.
+func (o *RotatingWriterOptions) fileName(t time.Time) string {
+	logFolder := o.FilePath
+	// Determine whether to use a time-based folder for log files.
+	if o.TimeFolder {
+		var folderName string
+		// Format folder name based on the specified time period.
+		if o.FileFolderPeriod < time.Hour {
+			folderName = t.Format(o.TimeFolderFormat)
+		} else {
+			folderName = t.Truncate(o.FileFolderPeriod).Format(o.TimeFolderFormat)
+		}
+		logFolder = filepath.Join(o.FilePath, o.namePart(folderName))
+	}
+
+	fileName := fmt.Sprintf("%s%s", o.FileName, o.FileType)
+	// Determine the log file name based on the date settings; the rotation period replaces the date.
+	if o.RotationInterval > 0 {
+		periodStr := o.namePart(rotationPeriodStart(t, o.RotationInterval).Format(MKLOG_RotationFileFormatDefault))
+		fileName = fmt.Sprintf("%s_%s", periodStr, fileName)
+	} else if o.IsDateFile {
+		dateStr := o.namePart(t.Format(o.DateFileFormat))
+		fileName = fmt.Sprintf("%s_%s", dateStr, fileName)
+	}
+	if o.Compress {
+		fileName += ".gz"
+	}
+	return filepath.Join(logFolder, fileName)
+}
+
+// open creates the folders of the file and opens it for appending, emptying it first when the writer truncates.
+// The file is registered as shared file until closeFile.
+func (w *RotatingWriter) open(fileName string) error {
+	// Create the log directory if it does not exist.
+	if err := os.MkdirAll(w.options.FilePath, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if dir := filepath.Dir(fileName); dir != filepath.Clean(w.options.FilePath) {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create time folder: %w", err)
+		}
+	}
+
+	if err := acquireSharedFile(fileName, w.options.OpenMode); err != nil {
+		return err
+	}
+
+	// Open the log file for writing.
+	flag := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	if w.truncate {
+		flag |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(fileName, flag, 0644)
+	if err != nil {
+		releaseSharedFile(fileName)
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		releaseSharedFile(fileName)
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+	w.truncate = false
+
+	w.file = file
+	w.name = fileName
+	w.size = info.Size()
+	w.head = 0
+	w.lines, w.headLines = 0, 0
+	if w.options.MaxFileLines > 0 && w.size > 0 {
+		w.lines = w.loadLineCount(fileName, w.size)
+	}
+	if w.options.Compress {
+		// Every opening starts a new gzip member, which readers decompress as one stream.
+		w.gz = gzip.NewWriter(&countingWriter{w: file, n: &w.size})
+	}
+	return w.writeHeader()
+}
+
+// writeHeader writes the byte order mark and the header of the options when the open file is empty.
+func (w *RotatingWriter) writeHeader() error {
+	header := w.options.Header
+	if w.options.BOM {
+		header = "\ufeff" + header
+	}
+	if header == "" || w.size > 0 {
+		return nil
+	}
+	w.headLines = int64(strings.Count(header, "\n"))
+	w.lines += w.headLines
+	if w.gz != nil {
+		if _, err := io.WriteString(w.gz, header); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+		return w.gz.Flush()
+	}
+	n, err := io.WriteString(w.file, header)
+	w.size += int64(n)
+	w.head = int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	return nil
+}
+
+// Rotate closes the current file, renames it to the first backup and opens a fresh file,
+// regardless of the size limit or date; with CopyTruncate the file is copied and truncated instead.
+// Without MaxBackups a single backup is kept.
+// Concurrent writes land either in the rotated or in the new file.
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("log file is not open")
+	}
+
+	backups := w.options.MaxBackups
+	if backups < 1 {
+		backups = 1
+	}
+	if err := w.rotate(backups); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return nil
+}
+
+// rotate renames the current file to the first backup, shifting older backups up to the given count, and opens a new file.
+// The file is closed before it is renamed, as Windows refuses to rename open files; renames are retried while another
+// process holds the file open. With CopyTruncate the file is copied and truncated instead of renamed.
+func (w *RotatingWriter) rotate(backups int) error {
+	if w.options.CopyTruncate {
+		return w.copyTruncate(backups)
+	}
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	if err := w.shiftBackups(backups); err != nil {
+		return err
+	}
+	if err := renameFile(w.name, backupName(w.name, 1)); err != nil {
+		// Keep writing to the current file rather than losing entries.
+		if openErr := w.open(w.name); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	w.dropLineCount()
+	if err := w.open(w.name); err != nil {
+		return err
+	}
+	w.removeExpired()
+	return nil
+}
+
+// lineBackups returns the number of backups kept when the line limit rotates the file.
+func (w *RotatingWriter) lineBackups() int {
+	if w.options.MaxBackups < 1 {
+		return 1
+	}
+	return w.options.MaxBackups
+}
+
+// shiftBackups removes the last of the given count of backups and renames the others to the next number.
+func (w *RotatingWriter) shiftBackups(backups int) error {
+	os.Remove(backupName(w.name, backups))
+	for i := backups - 1; i >= 1; i-- {
+		if err := renameFile(backupName(w.name, i), backupName(w.name, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTruncate copies the current file to the first backup, shifting older backups up to the given count,
+// and truncates it, keeping the file open. Readers holding the file open keep reading the same file, at the
+// cost of copying it; entries written while it is copied are not lost, as the writer lock is held.
+func (w *RotatingWriter) copyTruncate(backups int) error {
+	if w.gz != nil {
+		// Finish the gzip member, so the backup holds a complete stream.
+		if err := w.gz.Close(); err != nil {
+			return err
+		}
+		w.gz = nil
+	}
+
+	w.dropLineCount()
+	err := w.shiftBackups(backups)
+	if err == nil {
+		err = copyFile(w.name, backupName(w.name, 1))
+	}
+	if err == nil {
+		err = w.file.Truncate(0)
+	}
+	if err != nil {
+		// Keep writing to the current file rather than losing entries.
+		if w.options.Compress {
+			w.gz = gzip.NewWriter(&countingWriter{w: w.file, n: &w.size})
+		}
+		return err
+	}
+
+	w.size = 0
+	w.head = 0
+	w.lines, w.headLines = 0, 0
+	if w.options.Compress {
+		w.gz = gzip.NewWriter(&countingWriter{w: w.file, n: &w.size})
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.removeExpired()
+	return nil
+}
+
+// copyFile copies the content of the file to a new file.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// trim removes the beginning of the log file by the specified size to fit the new log message.
+// A header written by the writer stays at the start of the file.
+func (w *RotatingWriter) trim(overSize int64) error {
+	// Open the existing log file for reading and writing.
+	oldFile, err := os.OpenFile(w.name, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open old log file: %w", err)
+	}
+	defer oldFile.Close() // Ensure the file is closed after this function returns.
+
+	// Get the information about the old log file.
+	oldFileInfo, err := oldFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	bytesToKeep := oldFileInfo.Size() - w.head - overSize // Calculate how many bytes after the header to keep.
+
+	if bytesToKeep <= 0 {
+		// If there are no bytes to keep, truncate the file to the header.
+		if err := oldFile.Truncate(w.head); err != nil {
+			return fmt.Errorf("failed to truncate log file: %w", err)
+		}
+		w.size = w.head
+		w.lines = w.headLines
+		return nil
+	}
+
+	buffer := make([]byte, bytesToKeep) // Create a buffer for the remaining log data.
+
+	// Read the remaining log data into the buffer.
+	if _, err := oldFile.ReadAt(buffer, w.head+overSize); err != nil {
+		return fmt.Errorf("failed to read remaining log data: %w", err)
+	}
+
+	// Write the remaining log data back after the header.
+	if _, err := oldFile.Seek(w.head, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek in log file: %w", err)
+	}
+
+	if _, err := oldFile.Write(buffer); err != nil {
+		return fmt.Errorf("failed to write remaining log data: %w", err)
+	}
+
+	// Truncate the file to the new size.
+	if err := oldFile.Truncate(w.head + bytesToKeep); err != nil {
+		return fmt.Errorf("failed to truncate log file: %w", err)
+	}
+
+	w.size = w.head + bytesToKeep
+	if w.options.MaxFileLines > 0 {
+		w.dropLineCount()
+		w.lines = countFileLines(w.name, false, 0)
+	}
+	return nil
+}
+
+// expire removes the expired log files of the writer.
+func (w *RotatingWriter) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil {
+		w.removeExpired()
+	}
+}
+
+// removeExpired deletes rotated backups and dated files of the writer older than MaxAge. With time folders the
+// folders below the log directory are searched as well, and the folders left empty are removed.
+// The currently open file is never removed.
+func (w *RotatingWriter) removeExpired() {
+	if w.options.MaxAge <= 0 {
+		return
+	}
+
+	dirs := []string{filepath.Dir(w.name)}
+	if w.options.TimeFolder {
+		dirs = w.logFolders()
+	}
+
+	cutoff := w.now().Add(-w.options.MaxAge)
+	var expired []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !w.isManagedFile(e.Name()) {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if path == w.name {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				continue
+			}
+			expired = append(expired, path)
+		}
+	}
+
+	sort.Strings(expired)
+	for _, path := range expired {
+		if os.Remove(path) == nil && w.options.TimeFolder {
+			w.removeEmptyFolders(filepath.Dir(path))
+		}
+	}
+}
+
+// logFolders returns the log directory and the folders below it.
+func (w *RotatingWriter) logFolders() []string {
+	var dirs []string
+	filepath.WalkDir(w.options.FilePath, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs
+}
+
+// removeEmptyFolders removes the folder and its parents below the log directory as long as they are empty.
+// Removing a folder that is not empty, like the one of the open file, fails and ends the removal.
+func (w *RotatingWriter) removeEmptyFolders(dir string) {
+	root := filepath.Clean(w.options.FilePath)
+	for dir != root && strings.HasPrefix(dir, root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// removeSurplusDateFiles deletes the dated files beyond the newest MaxDateFiles, ordered by the date
+// parsed from their names. Files not matching the dated name pattern and the active file are kept.
+func (w *RotatingWriter) removeSurplusDateFiles() {
+	if w.options.MaxDateFiles <= 0 || !w.options.IsDateFile || w.options.TimeFolder || w.options.RotationInterval > 0 {
+		return
+	}
+
+	dir := filepath.Dir(w.name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	suffix := "_" + w.options.FileName + w.options.FileType
+	if w.options.Compress {
+		suffix += ".gz"
+	}
+
+	type datedFile struct {
+		path string
+		date time.Time
+	}
+	var files []datedFile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), suffix) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if path == w.name {
+			continue
+		}
+		date, err := time.ParseInLocation(w.options.DateFileFormat, strings.TrimSuffix(e.Name(), suffix), time.Local)
+		if err != nil {
+			continue
+		}
+		files = append(files, datedFile{path: path, date: date})
+	}
+	if len(files) <= w.options.MaxDateFiles {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].date.After(files[j].date) })
+	for _, f := range files[w.options.MaxDateFiles:] {
+		os.Remove(f.path)
+	}
+}
+
+// isManagedFile checks whether the file name belongs to the writer's files, either as a dated file or as a backup.
+func (w *RotatingWriter) isManagedFile(name string) bool {
+	base := w.options.FileName + w.options.FileType
+	if w.options.Compress {
+		base += ".gz"
+	}
+	if name == base || strings.HasSuffix(name, "_"+base) {
+		return true
+	}
+
+	// Backups append ".N" to the active file name.
+	if i := strings.LastIndex(name, "."); i > 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			return w.isManagedFile(name[:i])
+		}
+	}
+	return false
+}
+
+// rotationPeriodStart returns the start of the rotation period containing t.
+// Periods up to a day are aligned to the local midnight, so an hourly interval rotates on the hour;
+// longer periods are aligned to the zero time. Periods are counted on the wall clock, so days with a
+// daylight saving time change start every period at the same local time as other days.
+func rotationPeriodStart(t time.Time, interval time.Duration) time.Time {
+	if interval > 24*time.Hour {
+		return t.Truncate(interval)
+	}
+	hour, min, sec := t.Clock()
+	wall := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second +
+		time.Duration(t.Nanosecond())
+	start := wall / interval * interval
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, int(start), t.Location())
+}
+
+// backupName returns the name of the n-th backup of the log file.
+func backupName(fileName string, n int) string {
+	return fileName + "." + strconv.Itoa(n)
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+// Write writes p and adds the written bytes to the counter.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,890 @@
+package mklog
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable source of the current time.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock(t time.Time) *fakeClock { return &fakeClock{t: t} }
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+// newTestWriter creates a writer in a temporary directory, closing it when the test ends.
+func newTestWriter(t *testing.T, options RotatingWriterOptions) *RotatingWriter {
+	t.Helper()
+	if options.FilePath == "" {
+		options.FilePath = t.TempDir()
+	}
+	if options.FileName == "" {
+		options.FileName, options.FileType = "app", ".log"
+	}
+	w, err := NewRotatingWriter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+// writeLines writes the lines to the writer, failing the test on errors.
+func writeLines(t *testing.T, w io.Writer, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the content of the file, decompressing names ending in ".gz" or a ".gz.N" backup.
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.Contains(filepath.Base(name), ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return string(data)
+}
+
+// listFiles returns the names of the files below the directory, relative to it and sorted.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var names []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestRotatingWriterSizeRotation renames full files to numbered backups, dropping the ones beyond MaxBackups.
+func TestRotatingWriterSizeRotation(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, MaxFileSize: 10, MaxBackups: 2})
+
+	writeLines(t, w, "first", "second", "third", "fourth")
+
+	name := filepath.Join(dir, "app.log")
+	want := map[string]string{
+		name:                "fourth\n",
+		backupName(name, 1): "third\n",
+		backupName(name, 2): "second\n",
+		backupName(name, 3): "",
+	}
+	for file, content := range want {
+		if content == "" {
+			if _, err := os.Stat(file); !os.IsNotExist(err) {
+				t.Errorf("%s exists, want it removed", file)
+			}
+			continue
+		}
+		if got := readFile(t, file); got != content {
+			t.Errorf("%s = %q, want %q", file, got, content)
+		}
+	}
+}
+
+// TestRotatingWriterSizeTrim trims the beginning of a full file without backups, keeping its header.
+func TestRotatingWriterSizeTrim(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, MaxFileSize: 12, Header: "# h\n"})
+
+	writeLines(t, w, "one", "two", "six")
+
+	if got, want := readFile(t, w.FileName()), "# h\ntwo\nsix\n"; got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+// TestRotatingWriterMaxFileLines rotates files reaching the line limit, counting the header lines.
+func TestRotatingWriterMaxFileLines(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, MaxFileLines: 3, MaxBackups: 2, Header: "# header\n"})
+
+	// The header counts towards the limit of every file, so each file holds two entries.
+	writeLines(t, w, "a", "b", "c", "d", "e")
+
+	name := filepath.Join(dir, "app.log")
+	for file, want := range map[string]string{
+		name:                "# header\ne\n",
+		backupName(name, 1): "# header\nc\nd\n",
+		backupName(name, 2): "# header\na\nb\n",
+	} {
+		if got := readFile(t, file); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+}
+
+// TestRotatingWriterMaxFileLinesCountsExistingFile counts the lines of a file the writer reopens.
+func TestRotatingWriterMaxFileLinesCountsExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(name, []byte("old 1\nold 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, MaxFileLines: 2})
+
+	writeLines(t, w, "new")
+
+	if got, want := readFile(t, backupName(name, 1)), "old 1\nold 2\n"; got != want {
+		t.Errorf("backup = %q, want %q", got, want)
+	}
+	if got, want := readFile(t, name), "new\n"; got != want {
+		t.Errorf("current = %q, want %q", got, want)
+	}
+}
+
+// TestRotatingWriterMaxFileLinesRecordedCount extends the line count recorded when the file was closed by the
+// lines appended since, and counts the whole file when the recorded count belongs to a larger file.
+func TestRotatingWriterMaxFileLinesRecordedCount(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		options := RotatingWriterOptions{FilePath: dir, FileName: "app", FileType: ".log", MaxFileLines: 100, Compress: compress}
+		w := newTestWriter(t, options)
+		writeLines(t, w, "a", "b")
+		w.Close()
+
+		// The recorded count is trusted for the part of the file it covers.
+		name := w.FileName()
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(w.lineCountFile(), []byte(fmt.Sprintf("%s\n%d\n50\n", name, info.Size())), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w = newTestWriter(t, options)
+		writeLines(t, w, "c")
+		w.Close()
+		w = newTestWriter(t, options)
+		if w.lines != 51 {
+			t.Errorf("compress %v: reopened with %d lines, want 51 recorded and 1 appended", compress, w.lines)
+		}
+		w.Close()
+
+		// A count recorded for a larger file is ignored.
+		if err := os.WriteFile(w.lineCountFile(), []byte(fmt.Sprintf("%s\n%d\n50\n", name, 1<<20)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if w = newTestWriter(t, options); w.lines != 3 {
+			t.Errorf("compress %v: reopened with %d lines, want the 3 lines of the file", compress, w.lines)
+		}
+	}
+}
+
+// TestRotatingWriterWriteAtDateFile picks the dated file by the time passed to WriteAt rather than the clock.
+func TestRotatingWriterWriteAtDateFile(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, 3, 9, 23, 59, 0, 0, time.Local)
+	clock := newFakeClock(day)
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, IsDateFile: true, DateFileFormat: "2006-01-02", Now: clock.Now})
+
+	// The clock has passed midnight, but the entry queued before it belongs to the previous day.
+	clock.Set(day.Add(2 * time.Minute))
+	if _, err := w.WriteAt(day, []byte("before midnight\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteAt(day.Add(2*time.Minute), []byte("after midnight\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := listFiles(t, dir), []string{"2024-03-09_app.log", "2024-03-10_app.log"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	if got := readFile(t, filepath.Join(dir, "2024-03-09_app.log")); got != "before midnight\n" {
+		t.Errorf("first day = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "2024-03-10_app.log")); got != "after midnight\n" {
+		t.Errorf("second day = %q", got)
+	}
+}
+
+// TestRotatingWriterResumesAfterFailedOpen keeps writing to the current file while the next dated file cannot
+// be opened, switches once it can, and reopens a file a rotation failed to reopen on the next write.
+func TestRotatingWriterResumesAfterFailedOpen(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, 3, 9, 23, 59, 0, 0, time.Local)
+	clock := newFakeClock(day)
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, IsDateFile: true, DateFileFormat: "2006-01-02", Now: clock.Now})
+	writeLines(t, w, "first day")
+
+	// A directory blocks the file of the next day.
+	blocked := filepath.Join(dir, "2024-03-10_app.log")
+	if err := os.Mkdir(blocked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	clock.Set(day.Add(2 * time.Minute))
+	writeLines(t, w, "blocked")
+	if err := w.Healthy(); err == nil || !strings.Contains(err.Error(), "still writing to") {
+		t.Errorf("Healthy = %v, want the failed opening reported", err)
+	}
+
+	if err := os.Remove(blocked); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, w, "second day")
+	if err := w.Healthy(); err != nil {
+		t.Errorf("Healthy = %v after the file opened", err)
+	}
+
+	// A rotation that could not reopen the file leaves no file open.
+	w.mu.Lock()
+	w.closeFile()
+	w.mu.Unlock()
+	writeLines(t, w, "reopened")
+
+	if got, want := readFile(t, filepath.Join(dir, "2024-03-09_app.log")), "first day\nblocked\n"; got != want {
+		t.Errorf("first day = %q, want %q", got, want)
+	}
+	if got, want := readFile(t, blocked), "second day\nreopened\n"; got != want {
+		t.Errorf("second day = %q, want %q", got, want)
+	}
+}
+
+// TestRotatingWriterWriteAtRotationInterval starts a new file when WriteAt crosses a rotation period.
+func TestRotatingWriterWriteAtRotationInterval(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 3, 9, 10, 20, 0, 0, time.Local)
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, RotationInterval: time.Hour, Now: newFakeClock(start).Now})
+
+	for _, at := range []time.Time{start, start.Add(30 * time.Minute), start.Add(50 * time.Minute)} {
+		if _, err := w.WriteAt(at, []byte(at.Format("15:04")+"\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := readFile(t, filepath.Join(dir, "2024-03-09_10-00_app.log")); got != "10:20\n10:50\n" {
+		t.Errorf("10:00 period = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "2024-03-09_11-00_app.log")); got != "11:10\n" {
+		t.Errorf("11:00 period = %q", got)
+	}
+}
+
+// TestRotatingWriterTimeFolder stores the files of each period in their own folder.
+func TestRotatingWriterTimeFolder(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	w := newTestWriter(t, RotatingWriterOptions{
+		FilePath: dir, TimeFolder: true, TimeFolderFormat: "2006-01-02", FileFolderPeriod: 24 * time.Hour, Now: newFakeClock(day).Now,
+	})
+
+	if _, err := w.WriteAt(day, []byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteAt(day.Add(24*time.Hour), []byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := listFiles(t, dir), []string{"2024-03-09/app.log", "2024-03-10/app.log"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+// TestRotatingWriterCompressedRotation keeps rotated compressed files as complete gzip streams.
+func TestRotatingWriterCompressedRotation(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, Compress: true, MaxFileSize: 40, MaxBackups: 3})
+
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	writeLines(t, w, lines...)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(dir, "app.log.gz")
+	if _, err := os.Stat(backupName(name, 1)); err != nil {
+		t.Fatalf("no rotated file: %v", err)
+	}
+
+	// The backups and the current file are complete gzip streams holding the latest lines in order.
+	var content string
+	for i := 3; i >= 1; i-- {
+		if _, err := os.Stat(backupName(name, i)); err == nil {
+			content += readFile(t, backupName(name, i))
+		}
+	}
+	content += readFile(t, name)
+	if want := strings.Join(lines, "\n") + "\n"; !strings.HasSuffix(want, content) || content == "" {
+		t.Errorf("content = %q, want a suffix of %q", content, want)
+	}
+}
+
+// TestRotatingWriterCompressRoundTrip decompresses exactly the written content after Close, also when the file
+// was reopened and continued in a second gzip member.
+func TestRotatingWriterCompressRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log.gz")
+	var want strings.Builder
+	for session := 0; session < 2; session++ {
+		w, err := NewRotatingWriter(RotatingWriterOptions{FilePath: dir, FileName: "app", FileType: ".log", Compress: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 500; i++ {
+			line := fmt.Sprintf("session %d entry %03d: gzip ünïcode and a longer payload to compress", session, i)
+			writeLines(t, w, line)
+			want.WriteString(line + "\n")
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, name); got != want.String() {
+			t.Fatalf("session %d: decompressed %d bytes, want the %d bytes written", session, len(got), want.Len())
+		}
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= int64(want.Len()) {
+		t.Errorf("compressed file has %d bytes for %d written, want it compressed", info.Size(), want.Len())
+	}
+}
+
+// TestRotatingWriterCopyTruncate copies the file to the backups and truncates it, keeping it open.
+func TestRotatingWriterCopyTruncate(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, MaxFileSize: 10, MaxBackups: 2, CopyTruncate: true})
+
+	file := w.File()
+	writeLines(t, w, "first", "second", "third")
+
+	// The file stays open and keeps its identity, the content moves to the backups.
+	if w.File() != file {
+		t.Error("copyTruncate reopened the log file")
+	}
+	name := filepath.Join(dir, "app.log")
+	for file, want := range map[string]string{
+		name:                "third\n",
+		backupName(name, 1): "second\n",
+		backupName(name, 2): "first\n",
+	} {
+		if got := readFile(t, file); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+}
+
+// TestRotatingWriterMaxBackups keeps MaxBackups files when rotating on demand.
+func TestRotatingWriterMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, MaxBackups: 2})
+
+	for i := 0; i < 5; i++ {
+		writeLines(t, w, "entry")
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := listFiles(t, dir), []string{"app.log", "app.log.1", "app.log.2"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+// TestRotatingWriterRetention removes the files of the writer older than MaxAge by the injected clock.
+func TestRotatingWriterRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 3, 9, 12, 0, 0, 0, time.Local)
+	clock := newFakeClock(now)
+
+	// Files of the writer older than the retention are removed, foreign files and recent backups are kept.
+	old := now.Add(-48 * time.Hour)
+	for name, mtime := range map[string]time.Time{
+		"app.log.1":            now.Add(-time.Hour),
+		"app.log.2":            old,
+		"2024-03-01_app.log":   old,
+		"other.log":            old,
+		"2024-03-01_other.txt": old,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, MaxAge: 24 * time.Hour, Now: clock.Now})
+	w.expire()
+
+	want := []string{"2024-03-01_other.txt", "app.log", "app.log.1", "other.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+// TestRotatingWriterMaxDateFiles keeps the newest MaxDateFiles dated files besides the active one.
+func TestRotatingWriterMaxDateFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, day := range []string{"2024-03-01", "2024-03-02", "2024-03-03"} {
+		if err := os.WriteFile(filepath.Join(dir, day+"_app.log"), []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newTestWriter(t, RotatingWriterOptions{
+		FilePath: dir, IsDateFile: true, DateFileFormat: "2006-01-02", MaxDateFiles: 1,
+		Now: newFakeClock(time.Date(2024, 3, 9, 12, 0, 0, 0, time.Local)).Now,
+	})
+
+	want := []string{"2024-03-03_app.log", "2024-03-09_app.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+// TestRotatingWriterMaxDateFilesAtRollover keeps the newest dated files by the date in their names when the
+// day rolls over, ignoring their modification times and the files not matching the dated name.
+func TestRotatingWriterMaxDateFilesAtRollover(t *testing.T) {
+	dir := t.TempDir()
+	touched := time.Date(2024, 3, 9, 0, 0, 0, 0, time.Local)
+	for i, day := range []string{"2024-03-02", "2024-03-03", "2024-03-04", "2024-03-05", "2024-03-06", "2024-03-07", "2024-03-08"} {
+		path := filepath.Join(dir, day+"_app.log")
+		if err := os.WriteFile(path, []byte(day+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// The oldest names get the newest modification times.
+		mtime := touched.Add(-time.Duration(i) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"notes_app.log", "2024-03-01_app.txt", "2024-13-01_app.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("keep\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	day := time.Date(2024, 3, 9, 23, 0, 0, 0, time.Local)
+	w := newTestWriter(t, RotatingWriterOptions{
+		FilePath: dir, IsDateFile: true, DateFileFormat: "2006-01-02", MaxDateFiles: 3, Now: newFakeClock(day).Now,
+	})
+	if _, err := w.WriteAt(day, []byte("ninth\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2024-03-01_app.txt", "2024-03-06_app.log", "2024-03-07_app.log", "2024-03-08_app.log", "2024-03-09_app.log", "2024-13-01_app.log", "notes_app.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files at startup = %v, want %v", got, want)
+	}
+
+	if _, err := w.WriteAt(day.Add(2*time.Hour), []byte("tenth\n")); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"2024-03-01_app.txt", "2024-03-07_app.log", "2024-03-08_app.log", "2024-03-09_app.log", "2024-03-10_app.log", "2024-13-01_app.log", "notes_app.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files after rollover = %v, want %v", got, want)
+	}
+}
+
+// TestRotatingWriterRetentionTimeFolders removes the expired files of past time folders and the folders left
+// empty, keeping recent folders and the current one.
+func TestRotatingWriterRetentionTimeFolders(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-48 * time.Hour)
+	for name, mtime := range map[string]time.Time{
+		"2024-03-01/app.log":   old,
+		"2024-03-01/app.log.1": old,
+		"2024-03-06/app.log":   old,
+		"2024-03-06/notes.txt": old,
+		"2024-03-08/app.log":   now.Add(-time.Hour),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := newTestWriter(t, RotatingWriterOptions{
+		FilePath: dir, TimeFolder: true, TimeFolderFormat: "2006-01-02", FileFolderPeriod: 24 * time.Hour,
+		MaxAge: 24 * time.Hour, Now: newFakeClock(now).Now,
+	})
+	w.expire()
+
+	want := []string{"2024-03-06/notes.txt", "2024-03-08/app.log", "2024-03-09/app.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2024-03-01")); !os.IsNotExist(err) {
+		t.Errorf("empty expired folder kept: %v", err)
+	}
+}
+
+// TestRotationPeriodStartDST aligns the periods to the local wall clock on days with a daylight saving time
+// change, which are an hour shorter or longer than other days.
+func TestRotationPeriodStartDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, loc)
+	}
+
+	for _, c := range []struct {
+		t        time.Time
+		interval time.Duration
+		want     time.Time
+	}{
+		{at(time.March, 9, 23, 59), 24 * time.Hour, at(time.March, 9, 0, 0)},
+		{at(time.March, 10, 0, 0), 24 * time.Hour, at(time.March, 10, 0, 0)},
+		{at(time.March, 10, 3, 30), time.Hour, at(time.March, 10, 3, 0)},     // First hour after the clocks skip 2:00.
+		{at(time.March, 10, 6, 30), 6 * time.Hour, at(time.March, 10, 6, 0)}, // Only five hours after midnight.
+		{at(time.March, 10, 23, 59), 24 * time.Hour, at(time.March, 10, 0, 0)},
+		{at(time.November, 3, 23, 30), 24 * time.Hour, at(time.November, 3, 0, 0)}, // 24.5 hours after midnight.
+		{at(time.November, 3, 23, 30), time.Hour, at(time.November, 3, 23, 0)},
+		{at(time.November, 3, 12, 15), 12 * time.Hour, at(time.November, 3, 12, 0)},
+		{at(time.November, 4, 0, 0), 24 * time.Hour, at(time.November, 4, 0, 0)},
+	} {
+		if got := rotationPeriodStart(c.t, c.interval); !got.Equal(c.want) {
+			t.Errorf("period of %v at %v starts %v, want %v", c.interval, c.t, got, c.want)
+		}
+	}
+}
+
+// TestRotatingWriterMidnightDST writes entries around the midnights of a day an hour longer than others with a
+// fake clock, and expects each in the file of the day it was logged.
+func TestRotatingWriterMidnightDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+	dir := t.TempDir()
+	start := time.Date(2024, 11, 2, 23, 59, 0, 0, loc)
+	clock := newFakeClock(start)
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, RotationInterval: 24 * time.Hour, Now: clock.Now})
+
+	for _, at := range []time.Time{
+		start,
+		time.Date(2024, 11, 3, 0, 0, 0, 0, loc),
+		time.Date(2024, 11, 3, 23, 30, 0, 0, loc), // The clocks went back an hour at 2:00.
+		time.Date(2024, 11, 4, 0, 0, 0, 0, loc),
+	} {
+		clock.Set(at.Add(time.Minute)) // Entries are written after they were logged.
+		if _, err := w.WriteAt(at, []byte(at.Format("01-02 15:04")+"\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]string{
+		"2024-11-02_00-00_app.log": "11-02 23:59\n",
+		"2024-11-03_00-00_app.log": "11-03 00:00\n11-03 23:30\n",
+		"2024-11-04_00-00_app.log": "11-04 00:00\n",
+	}
+	if got := listFiles(t, dir); len(got) != len(want) {
+		t.Fatalf("files = %v, want %d", got, len(want))
+	}
+	for name, content := range want {
+		if got := readFile(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+// TestRotationIntervalThroughRule logs through a rule with a fake clock across three hour boundaries and
+// expects one file per period, named after the start of the period instead of the time of its first entry.
+func TestRotationIntervalThroughRule(t *testing.T) {
+	dir := t.TempDir()
+	clock := newFakeClock(time.Date(2024, 3, 9, 9, 47, 0, 0, time.Local))
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.NewLogRule("app",
+		WithFileLogging(dir, "app", ".log"),
+		WithDateFormat("15:04"),
+		WithRotationInterval(time.Hour),
+		WithClock(clock.Now),
+	)
+
+	for _, at := range []string{"10:12", "11:03", "11:59", "12:30"} {
+		hm, _ := time.ParseInLocation("15:04", at, time.Local)
+		clock.Set(time.Date(2024, 3, 9, hm.Hour(), hm.Minute(), 0, 0, time.Local))
+		d.Info("at %s", at)
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file of the period the rule was created in is opened before the first entry.
+	want := map[string]string{
+		"2024-03-09_09-00_app.log": "",
+		"2024-03-09_10-00_app.log": "10:12 | INFO | [app] : at 10:12\n",
+		"2024-03-09_11-00_app.log": "11:03 | INFO | [app] : at 11:03\n11:59 | INFO | [app] : at 11:59\n",
+		"2024-03-09_12-00_app.log": "12:30 | INFO | [app] : at 12:30\n",
+	}
+	files := listFiles(t, dir)
+	if len(files) != len(want) {
+		t.Errorf("files = %v, want %d", files, len(want))
+	}
+	for _, name := range files {
+		if got := readFile(t, filepath.Join(dir, name)); got != want[name] {
+			t.Errorf("%s = %q, want %q", name, got, want[name])
+		}
+	}
+}
+
+// TestRotationIntervalWithSizeLimit rotates by size within a period and starts a fresh file, without
+// backups, when the next period begins, whichever comes first.
+func TestRotationIntervalWithSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 3, 9, 10, 0, 0, 0, time.Local)
+	w := newTestWriter(t, RotatingWriterOptions{
+		FilePath: dir, RotationInterval: 15 * time.Minute, MaxFileSize: 12, MaxBackups: 5, Now: newFakeClock(start).Now,
+	})
+
+	for i, minute := range []int{1, 2, 3, 16} {
+		at := start.Add(time.Duration(minute) * time.Minute)
+		if _, err := w.WriteAt(at, []byte(strings.Repeat(string(rune('a'+i)), 7)+"\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"2024-03-09_10-00_app.log", "2024-03-09_10-00_app.log.1", "2024-03-09_10-00_app.log.2", "2024-03-09_10-15_app.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	if got := readFile(t, filepath.Join(dir, want[1])); got != "bbbbbbb\n" {
+		t.Errorf("first backup = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, want[3])); got != "ddddddd\n" {
+		t.Errorf("10:15 period = %q", got)
+	}
+}
+
+// TestRotationIntervalRetention removes the files of past periods once they are older than the retention.
+func TestRotationIntervalRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 3, 9, 12, 5, 0, 0, time.Local)
+	for name, mtime := range map[string]time.Time{
+		"2024-03-09_09-00_app.log": now.Add(-3 * time.Hour),
+		"2024-03-09_11-00_app.log": now.Add(-time.Hour),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, RotationInterval: time.Hour, MaxAge: 2 * time.Hour, Now: newFakeClock(now).Now})
+	w.expire()
+
+	want := []string{"2024-03-09_11-00_app.log", "2024-03-09_12-00_app.log"}
+	if got := listFiles(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+// TestCSVHeaderPerFile rotates the CSV log of a rule twice and expects the header once at the start of each
+// of the three files.
+func TestCSVHeaderPerFile(t *testing.T) {
+	dir := t.TempDir()
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app",
+		WithFileLogging(dir, "app", ".csv"),
+		WithLogFormatter(CSVFormatter{}),
+		WithDateFormat("15:04:05"),
+		WithMaxFileSize(80),
+		WithMaxBackups(2),
+	)
+	for i := 1; i <= 3; i++ {
+		d.Info("record %d", i)
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	header := CSVFormatter{}.Header() + "\n"
+	files := listFiles(t, dir)
+	if len(files) != 3 {
+		t.Fatalf("files = %v, want the log and two backups", files)
+	}
+	headers := 0
+	for _, name := range files {
+		content := readFile(t, filepath.Join(dir, name))
+		headers += strings.Count(content, header)
+		if !strings.HasPrefix(content, header) || strings.Count(content, "\n") != 2 {
+			t.Errorf("%s = %q, want the header and one record", name, content)
+		}
+	}
+	if headers != 3 {
+		t.Errorf("%d header lines, want 3", headers)
+	}
+}
+
+// TestRotatingWriterHeaderOnNewFilesOnly writes the header into new files, including the file of a new date,
+// but not into a file reopened with content.
+func TestRotatingWriterHeaderOnNewFilesOnly(t *testing.T) {
+	dir := t.TempDir()
+	clock := newFakeClock(time.Date(2024, 3, 9, 23, 59, 0, 0, time.Local))
+	options := RotatingWriterOptions{FilePath: dir, IsDateFile: true, DateFileFormat: "2006-01-02", Header: "# h\n", Now: clock.Now}
+
+	w := newTestWriter(t, options)
+	writeLines(t, w, "one")
+	w.Close()
+	w = newTestWriter(t, options)
+	writeLines(t, w, "two")
+	clock.Set(time.Date(2024, 3, 10, 0, 1, 0, 0, time.Local))
+	writeLines(t, w, "three")
+
+	want := map[string]string{
+		"2024-03-09_app.log": "# h\none\ntwo\n",
+		"2024-03-10_app.log": "# h\nthree\n",
+	}
+	files := listFiles(t, dir)
+	if len(files) != len(want) {
+		t.Errorf("files = %v, want %d", files, len(want))
+	}
+	for _, name := range files {
+		if got := readFile(t, filepath.Join(dir, name)); got != want[name] {
+			t.Errorf("%s = %q, want %q", name, got, want[name])
+		}
+	}
+}
+
+// TestMaxFileLinesThroughRule rotates the log file of a configured rule exactly when it holds max_file_lines
+// lines, keeping one backup by default.
+func TestMaxFileLinesThroughRule(t *testing.T) {
+	dir := filepath.ToSlash(t.TempDir())
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: {type: plain}
+      file_log: {enable: true, file_path: `+dir+`, file_name: app, file_type: .log, max_file_lines: 4}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 6; i++ {
+		d.Info("entry %d", i)
+	}
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(dir, "app.log")
+	if got := listFiles(t, dir); strings.Join(got, ",") != ".app.log.lines,app.log,app.log.1" {
+		t.Fatalf("files = %v, want the line count, the log and one backup", got)
+	}
+	backup, current := readFile(t, backupName(name, 1)), readFile(t, name)
+	if strings.Count(backup, "\n") != 4 || !strings.HasSuffix(backup, "entry 4\n") {
+		t.Errorf("backup = %q, want entries 1 to 4", backup)
+	}
+	if strings.Count(current, "\n") != 2 || !strings.HasSuffix(current, "entry 6\n") {
+		t.Errorf("current = %q, want entries 5 and 6", current)
+	}
+}
+
+// TestMaxFileLinesWithSizeLimit rotates at whichever of the line and size limits is reached first.
+func TestMaxFileLinesWithSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, RotatingWriterOptions{FilePath: dir, MaxFileLines: 3, MaxFileSize: 12, MaxBackups: 5})
+
+	writeLines(t, w, "a", "b", "c", "d", "long line of text", "e")
+
+	name := filepath.Join(dir, "app.log")
+	for file, want := range map[string]string{
+		backupName(name, 3): "a\nb\nc\n",           // Line limit
+		backupName(name, 2): "d\n",                 // Size limit
+		backupName(name, 1): "long line of text\n", // Size limit
+		name:                "e\n",
+	} {
+		if got := readFile(t, file); got != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, want)
+		}
+	}
+}
+
+// TestMaxFileLinesBatchNotSplit starts a new file for a write with more lines than the limit without
+// splitting it, and counts the lines of a compressed file reopened by the writer.
+func TestMaxFileLinesBatchNotSplit(t *testing.T) {
+	dir := t.TempDir()
+	options := RotatingWriterOptions{FilePath: dir, MaxFileLines: 2, MaxBackups: 3, Compress: true}
+	w := newTestWriter(t, options)
+	writeLines(t, w, "a")
+	if _, err := io.WriteString(w, "b\nc\nd\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	w = newTestWriter(t, options)
+	writeLines(t, w, "e")
+	w.Close()
+
+	name := filepath.Join(dir, "app.log.gz")
+	for file, want := range map[string]string{
+		backupName(name, 2): "a\n",
+		backupName(name, 1): "b\nc\nd\n",
+		name:                "e\n",
+	} {
+		if got := readFile(t, file); got != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, want)
+		}
+	}
+}
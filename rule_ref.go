@@ -0,0 +1,58 @@
+package mklog
+
+import (
+	"path/filepath"
+	"reflect"
+)
+
+// RuleRef identifies the rule an entry was dispatched to, e.g. to label metrics of hooks by log file.
+type RuleRef struct {
+	Module    string `json:"module"`         // Module the rule is registered under
+	Index     int    `json:"index"`          // Index of the rule within the module at the time of the entry
+	File      string `json:"file,omitempty"` // Configured log file, without date or rotation parts; empty without log files
+	Formatter string `json:"formatter"`      // Type of the formatter, like RuleInfo.Formatter
+}
+
+// fileTarget caches the log file name of a rule for the location it was rendered for.
+type fileTarget struct {
+	location fileLocation
+	name     string
+}
+
+// ref returns the reference of the rule at the index of the module.
+func (lr *LogRule) ref(module string, index int) RuleRef {
+	return RuleRef{
+		Module:    module,
+		Index:     index,
+		File:      lr.fileTarget(),
+		Formatter: formatterName(lr.LogFormatter),
+	}
+}
+
+// fileTarget returns the configured log file of the rule, rendering it only when the location changed, so
+// dispatching does not allocate for it.
+func (lr *LogRule) fileTarget() string {
+	if !lr.FileLog.Enable || lr.FileLog.Writer != nil {
+		return ""
+	}
+	location := lr.FileLog.location()
+	if t, ok := lr.target.Load().(*fileTarget); ok && t.location == location {
+		return t.name
+	}
+	name := filepath.Join(location.path, location.name+location.fileType)
+	lr.target.Store(&fileTarget{location: location, name: name})
+	return name
+}
+
+// formatterName returns the type of the formatter as printed by %T, without allocating.
+func formatterName(f LogFormatter) string {
+	return typeName(f)
+}
+
+// typeName returns the type of the value as printed by %T.
+func typeName(v interface{}) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return reflect.TypeOf(v).String()
+}
@@ -0,0 +1,69 @@
+package mklog
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// refHook records the rule references of the entries it is fired for.
+type refHook struct {
+	mu   sync.Mutex
+	refs []RuleRef
+}
+
+func (h *refHook) Levels() []LogLevel { return []LogLevel{InfoLevel, ErrorLevel} }
+
+func (h *refHook) Fire(entry Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.refs = append(h.refs, entry.Rule)
+	return nil
+}
+
+// take returns the references recorded since the last call.
+func (h *refHook) take() []RuleRef {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	refs := h.refs
+	h.refs = nil
+	return refs
+}
+
+// TestRuleRefTwoRules passes the module, index, log file and formatter of each rule an entry flows through to
+// hooks and the recent buffer, and recomputes the index after a rule before it is removed.
+func TestRuleRefTwoRules(t *testing.T) {
+	dir := t.TempDir()
+	hook := &refHook{}
+	d := (&Debugger{}).SetQuiet(true).SetRecentBuffer(8)
+	defer d.Close(context.Background())
+	d.NewLogRule("api", WithFileLogging(dir, "api", ".log"), WithLogFormatter(PlainTextFormatter{}), WithDateFormat("-"))
+	d.NewLogRule("api", WithFileWriter(&syncBuffer{}), WithLogFormatter(JSONFormatter{}), WithMinLevel(ErrorLevel))
+	d.AddHook(hook)
+
+	fileRef := RuleRef{Module: "api", Index: 0, File: filepath.Join(dir, "api.log"), Formatter: "mklog.PlainTextFormatter"}
+	jsonRef := RuleRef{Module: "api", Index: 1, Formatter: "mklog.JSONFormatter"}
+
+	d.Info("only the file rule")
+	if refs := hook.take(); len(refs) != 1 || refs[0] != fileRef {
+		t.Errorf("Info refs = %+v, want %+v", refs, fileRef)
+	}
+	d.Error("both rules")
+	if refs := hook.take(); len(refs) != 2 || refs[0] != fileRef || refs[1] != jsonRef {
+		t.Errorf("Error refs = %+v, want %+v and %+v", refs, fileRef, jsonRef)
+	}
+	recent := d.Recent(RecentFilter{})
+	if len(recent) != 3 || recent[0].Rule != fileRef || recent[2].Rule != jsonRef {
+		t.Errorf("recent = %+v, want the refs of the rules", recent)
+	}
+
+	if err := d.RemoveRule(context.Background(), "api", 0); err != nil {
+		t.Fatal(err)
+	}
+	d.Error("after removal")
+	jsonRef.Index = 0
+	if refs := hook.take(); len(refs) != 1 || refs[0] != jsonRef {
+		t.Errorf("refs after removal = %+v, want %+v", refs, jsonRef)
+	}
+}
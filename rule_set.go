@@ -0,0 +1,99 @@
+package mklog
+
+import (
+	"context"
+	"fmt"
+)
+
+// rules returns the current snapshot of the rules by module. The snapshot is never modified, so it can be
+// iterated without locks while rules are added or removed; changes replace it, see updateRules.
+func (d *Debugger) rules() map[string][]*LogRule {
+	if s := d.ruleSet.Load(); s != nil {
+		return s.rules
+	}
+	return d.LogRules
+}
+
+// updateRules applies the change to a copy of the rules and publishes the copy as the new snapshot, together
+// with the fingerprint of its configuration. Changes are serialized, so concurrent additions and removals are
+// not lost; log calls keep iterating the snapshot they loaded. The change may replace the slices of the modules
+// but must not modify them in place.
+func (d *Debugger) updateRules(change func(rules map[string][]*LogRule)) {
+	d.rulesMu.Lock()
+	defer d.rulesMu.Unlock()
+
+	current := d.rules()
+	next := make(map[string][]*LogRule, len(current)+1)
+	for module, rules := range current {
+		next[module] = rules[:len(rules):len(rules)] // Appending copies the slice.
+	}
+	change(next)
+	d.ruleSet.Store(&ruleSnapshot{rules: next, fingerprint: rulesFingerprint(next)})
+	d.LogRules = copyRules(next) // Writes to the exported map must not reach the snapshot.
+	d.refreshStaticFields()
+}
+
+// copyRules returns a copy of the rules by module with copies of their slices.
+func copyRules(rules map[string][]*LogRule) map[string][]*LogRule {
+	c := make(map[string][]*LogRule, len(rules))
+	for module, moduleRules := range rules {
+		c[module] = append([]*LogRule(nil), moduleRules...)
+	}
+	return c
+}
+
+// ruleKey returns the key naming the maintenance tasks of a new rule of the module: the module and the number of
+// rules added to it before, which is the index of the rule unless rules were removed. Keys are not reused, so
+// the tasks of a removed rule do not collide with the ones of a later rule. It must be called in updateRules.
+func (d *Debugger) ruleKey(module string) string {
+	if d.ruleKeys == nil {
+		d.ruleKeys = make(map[string]int)
+	}
+	n := d.ruleKeys[module]
+	if rules := d.rules()[module]; n < len(rules) {
+		n = len(rules) // Rules added before the first key was taken, e.g. with AddRule.
+	}
+	d.ruleKeys[module] = n + 1
+	return fmt.Sprintf("%s/%d", module, n)
+}
+
+// RemoveRule removes the rule of the module at the given index and shuts it down like Close does: the rule stops
+// accepting messages, the messages in progress and the async buffer are written, and its log file and sinks
+// are closed, giving up waiting when ctx expires. The rules of the module behind it move up one index. Log calls
+// running concurrently either still reach the rule, before it is shut down, or skip it.
+func (d *Debugger) RemoveRule(ctx context.Context, module string, index int) error {
+	var removed *LogRule
+	var err error
+	d.updateRules(func(rules map[string][]*LogRule) {
+		moduleRules, ok := rules[module]
+		if !ok {
+			err = fmt.Errorf("[mklog] unknown module: %s", module)
+			return
+		}
+		if index < 0 || index >= len(moduleRules) {
+			err = fmt.Errorf("[mklog] module %s has no rule %d", module, index)
+			return
+		}
+		removed = moduleRules[index]
+		if len(moduleRules) == 1 {
+			delete(rules, module)
+			return
+		}
+		remaining := make([]*LogRule, 0, len(moduleRules)-1)
+		remaining = append(remaining, moduleRules[:index]...)
+		rules[module] = append(remaining, moduleRules[index+1:]...)
+	})
+	if err != nil {
+		return err
+	}
+
+	if removed.taskKey != "" {
+		for _, task := range []string{"retention/", "archiving/", "failed/", "watchdog/"} {
+			d.RemoveMaintenanceTask(task + removed.taskKey)
+		}
+	}
+	if _, _, err := removed.shutdown(ctx); err != nil {
+		return fmt.Errorf("[mklog] failed to close %s: %w", module, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package mklog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a writer whose content can be read while rules write to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestConcurrentDispatch logs to the same rule from several goroutines, run with -race.
+func TestConcurrentDispatch(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		t.Run(fmt.Sprintf("async=%v", async), func(t *testing.T) {
+			const goroutines, messages = 4, 500
+
+			out := &syncBuffer{}
+			d := (&Debugger{}).SetQuiet(true)
+			if _, err := d.NewLogRuleE("app", WithFileWriter(out), WithAsyncLog(async, 64)); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < messages; i++ {
+						d.Info("goroutine %d message %d", g, i)
+					}
+				}(g)
+			}
+			wg.Wait()
+
+			if _, err := d.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Count(out.String(), "\n"); got != goroutines*messages {
+				t.Errorf("got %d lines, want %d", got, goroutines*messages)
+			}
+		})
+	}
+}
+
+// TestConcurrentReconfiguration adds and removes rules, shifts the verbosity and instantiates fallback rules
+// while several goroutines log, run with -race.
+func TestConcurrentReconfiguration(t *testing.T) {
+	const loggers, messages, changes = 4, 500, 50
+
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetFallbackRule(WithFileWriter(&syncBuffer{}))
+	if _, err := d.NewLogRuleE("app", WithFileWriter(&syncBuffer{}), WithAsyncLog(true, 64)); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < loggers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < messages; i++ {
+				d.Info("goroutine %d message %d", g, i)
+				d.Debug("goroutine %d message %d", g, i)
+				d.Module(fmt.Sprintf("fallback%d", i%8)).Warning("goroutine %d message %d", g, i)
+				d.EnabledFor("reloaded", DebugLevel)
+			}
+		}(g)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < changes; i++ {
+			// Reload the rules of a module: add the new rule before removing the old one.
+			if _, err := d.NewLogRuleE("reloaded", WithFileWriter(&syncBuffer{}), WithAsyncLog(i%2 == 0, 16)); err != nil {
+				t.Error(err)
+				return
+			}
+			if i > 0 {
+				if err := d.RemoveRule(context.Background(), "reloaded", 0); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			d.BumpVerbosity(1 - 2*(i%2))
+			d.Rules()
+		}
+		close(stop)
+	}()
+
+	<-stop
+	wg.Wait()
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(d.rules()["reloaded"]); got != 1 {
+		t.Errorf("got %d rules of the reloaded module, want 1", got)
+	}
+}
+
+// TestLogRulesFieldIsACopy modifies the exported LogRules map while several goroutines log, run with -race.
+// Log calls read their snapshot, so the changes neither race with them nor remove the rule.
+func TestLogRulesFieldIsACopy(t *testing.T) {
+	const goroutines, messages = 4, 200
+
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	if _, err := d.NewLogRuleE("app", WithFileWriter(out)); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < messages; i++ {
+				d.Info("message %d", i)
+			}
+		}()
+	}
+	d.LogRules["app"][0] = nil
+	for i := 0; i < messages; i++ {
+		delete(d.LogRules, "app")
+		d.LogRules["other"] = nil
+	}
+	wg.Wait()
+
+	if got := strings.Count(out.String(), "\n"); got != goroutines*messages {
+		t.Errorf("got %d lines, want %d", got, goroutines*messages)
+	}
+}
+
+// TestDefaultConstructorsPublishRules checks that the Default* constructors publish their rules as a snapshot
+// distinct from the exported map.
+func TestDefaultConstructorsPublishRules(t *testing.T) {
+	d := DefaultConsoleLogging("app")
+	defer d.Close(context.Background())
+
+	if d.ruleSet.Load() == nil {
+		t.Fatal("no rule snapshot")
+	}
+	if rules := d.rules()["app"]; len(rules) != 1 {
+		t.Fatalf("got %d rules, want 1", len(rules))
+	}
+	delete(d.LogRules, "app")
+	if rules := d.rules()["app"]; len(rules) != 1 {
+		t.Errorf("deleting from LogRules removed the rule from the snapshot")
+	}
+}
@@ -0,0 +1,150 @@
+package mklog
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// RuleStats describes the traffic of a rule.
+type RuleStats struct {
+	Module         string              `json:"module"`           // Module of the rule
+	Index          int                 `json:"index"`            // Index of the rule within the module
+	EntriesByLevel map[LogLevel]uint64 `json:"entries_by_level"` // Entries accepted by the rule per level
+	BytesWritten   uint64              `json:"bytes_written"`    // Bytes written to the log file
+	WriteErrors    uint64              `json:"write_errors"`     // Failed writes to the log file and the sinks
+	Panics         uint64              `json:"panics"`           // Panics recovered from the formatter, hooks and sinks
+	Dropped        uint64              `json:"dropped"`          // Messages dropped by the async overflow policy
+	RetryQueued    int                 `json:"retry_queued"`     // Failed log file writes waiting for replay
+	RetryDropped   uint64              `json:"retry_dropped"`    // Failed log file writes dropped because the failed entry queue was full
+	QueueLen       int                 `json:"queue_len"`        // Number of messages waiting in the async queue
+	QueueCap       int                 `json:"queue_cap"`        // Capacity of the async queue
+	LastWrite      time.Time           `json:"last_write"`       // Time of the last successful write to the log file or a sink
+}
+
+// countEntry records an entry accepted by the rule.
+func (lr *LogRule) countEntry(level LogLevel) {
+	m := lr.metrics
+	if m == nil {
+		return
+	}
+	if level >= TraceLevel && level <= FatalLevel {
+		atomic.AddUint64(&m.levels[level], 1)
+		return
+	}
+	m.mu.Lock()
+	if m.custom == nil {
+		m.custom = make(map[LogLevel]uint64)
+	}
+	m.custom[level]++
+	m.mu.Unlock()
+}
+
+// countWrite records a write of n bytes to the log file, or a write to a sink when n is zero.
+func (lr *LogRule) countWrite(n int, err error) {
+	m := lr.metrics
+	if m == nil {
+		return
+	}
+	if n > 0 {
+		atomic.AddUint64(&m.bytes, uint64(n))
+	}
+	if err != nil {
+		atomic.AddUint64(&m.errors, 1)
+		return
+	}
+	atomic.StoreInt64(&m.lastWrite, lr.now().UnixNano())
+}
+
+// countPanic records a panic recovered from the formatter, a hook or a sink of the rule.
+func (lr *LogRule) countPanic() {
+	if m := lr.metrics; m != nil {
+		atomic.AddUint64(&m.panics, 1)
+	}
+}
+
+// Stats returns the traffic of the rule; its Index is set by Debugger.Stats.
+func (lr *LogRule) Stats() RuleStats {
+	s := RuleStats{
+		Module:         lr.ModuleName,
+		EntriesByLevel: make(map[LogLevel]uint64),
+	}
+	if lr.AsyncLog.Enable {
+		s.QueueLen, s.QueueCap = len(lr.logChannel), cap(lr.logChannel)
+	}
+	if w := lr.watchdog; w != nil {
+		s.Dropped = atomic.LoadUint64(&w.dropped)
+	}
+	if q := lr.failed; q != nil {
+		s.RetryQueued, s.RetryDropped = q.stats()
+	}
+
+	m := lr.metrics
+	if m == nil {
+		return s
+	}
+	for level := range m.levels {
+		if n := atomic.LoadUint64(&m.levels[level]); n > 0 {
+			s.EntriesByLevel[LogLevel(level)] = n
+		}
+	}
+	m.mu.Lock()
+	for level, n := range m.custom {
+		s.EntriesByLevel[level] = n
+	}
+	m.mu.Unlock()
+	s.BytesWritten = atomic.LoadUint64(&m.bytes)
+	s.WriteErrors = atomic.LoadUint64(&m.errors)
+	s.Panics = atomic.LoadUint64(&m.panics)
+	if last := atomic.LoadInt64(&m.lastWrite); last != 0 {
+		s.LastWrite = time.Unix(0, last)
+	}
+	return s
+}
+
+// ResetStats resets the entry, byte, error and dropped message counters of the rule.
+func (lr *LogRule) ResetStats() {
+	if w := lr.watchdog; w != nil {
+		atomic.StoreUint64(&w.dropped, 0)
+	}
+	m := lr.metrics
+	if m == nil {
+		return
+	}
+	for level := range m.levels {
+		atomic.StoreUint64(&m.levels[level], 0)
+	}
+	m.mu.Lock()
+	m.custom = nil
+	m.mu.Unlock()
+	atomic.StoreUint64(&m.bytes, 0)
+	atomic.StoreUint64(&m.errors, 0)
+}
+
+// ruleStats returns the traffic of all rules.
+func (d *Debugger) ruleStats() []RuleStats {
+	var stats []RuleStats
+	for module, rules := range d.rules() {
+		for i, v := range rules {
+			s := v.Stats()
+			s.Module, s.Index = module, i
+			stats = append(stats, s)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Module != stats[j].Module {
+			return stats[i].Module < stats[j].Module
+		}
+		return stats[i].Index < stats[j].Index
+	})
+	return stats
+}
+
+// ResetStats resets the traffic counters of all rules, see LogRule.ResetStats.
+func (d *Debugger) ResetStats() {
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			v.ResetStats()
+		}
+	}
+}
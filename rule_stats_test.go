@@ -0,0 +1,140 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pickySink fails to write the entries whose message contains "reject".
+type pickySink struct{}
+
+func (pickySink) Write(entry Entry, formatted string) error {
+	if strings.Contains(entry.Message, "reject") {
+		return errors.New("rejected")
+	}
+	return nil
+}
+
+func (pickySink) Close() error { return nil }
+
+// rejectingWriter fails to write the lines containing "reject".
+type rejectingWriter struct {
+	syncBuffer
+}
+
+func (w *rejectingWriter) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), "reject") {
+		return 0, errors.New("rejected")
+	}
+	return w.syncBuffer.Write(p)
+}
+
+// logMix logs two Debug, three Info, two Warning and one Error entries, the last Info one rejected by the
+// writer.
+func logMix(d *Debugger) {
+	d.Debug("debug 1")
+	d.Debug("debug 2")
+	d.Info("info 1")
+	d.Info("info 2")
+	d.Warning("warning 1")
+	d.Warning("warning 2")
+	d.Error("error 1")
+	d.Info("info 3 reject")
+}
+
+// TestRuleStatsCounts logs a known mix of levels with one failed write synchronously and asynchronously
+// and expects exact counts of the entries, bytes and errors.
+func TestRuleStatsCounts(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		w := &rejectingWriter{}
+		at := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+		d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return at })
+		d.SetErrorHandler(func(error) {})
+		opts := []Option{WithFileWriter(w), WithMinLevel(InfoLevel), WithMaxLevel(FatalLevel)}
+		if async {
+			opts = append(opts, WithAsyncLog(true, 16))
+		}
+		d.NewLogRule("app", opts...)
+		logMix(d)
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		stats := d.Stats().Rules
+		if len(stats) != 1 || stats[0].Module != "app" || stats[0].Index != 0 {
+			t.Fatalf("async %v: rules = %+v", async, stats)
+		}
+		s := stats[0]
+		want := map[LogLevel]uint64{InfoLevel: 3, WarningLevel: 2, ErrorLevel: 1}
+		if len(s.EntriesByLevel) != len(want) {
+			t.Errorf("async %v: entries = %v, want %v", async, s.EntriesByLevel, want)
+		}
+		for level, n := range want {
+			if s.EntriesByLevel[level] != n {
+				t.Errorf("async %v: %v entries = %d, want %d", async, level, s.EntriesByLevel[level], n)
+			}
+		}
+		if s.BytesWritten != uint64(len(w.String())) {
+			t.Errorf("async %v: bytes = %d, want %d", async, s.BytesWritten, len(w.String()))
+		}
+		if s.WriteErrors != 1 {
+			t.Errorf("async %v: write errors = %d, want 1", async, s.WriteErrors)
+		}
+		if !s.LastWrite.Equal(at) {
+			t.Errorf("async %v: last write = %v, want %v", async, s.LastWrite, at)
+		}
+	}
+}
+
+// TestRuleStatsSinks counts the failed writes of sinks of a rule without a log file.
+func TestRuleStatsSinks(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(func(error) {})
+	d.NewLogRule("app", WithSink(pickySink{}))
+	defer d.Close(context.Background())
+
+	d.Info("accept")
+	d.Info("reject")
+	d.Warning("reject again")
+
+	s := d.rules()["app"][0].Stats()
+	if s.EntriesByLevel[InfoLevel] != 2 || s.EntriesByLevel[WarningLevel] != 1 {
+		t.Errorf("entries = %v", s.EntriesByLevel)
+	}
+	if s.WriteErrors != 2 || s.BytesWritten != 0 || s.LastWrite.IsZero() {
+		t.Errorf("stats = %+v, want 2 write errors, no bytes and a last write", s)
+	}
+}
+
+// TestRuleStatsDroppedAndReset counts the entries dropped by a full async queue, reports the queue and resets
+// the counters.
+func TestRuleStatsDroppedAndReset(t *testing.T) {
+	w := &blockingWriter{started: make(chan struct{}, 8), release: make(chan struct{})}
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(func(error) {})
+	d.NewLogRule("app", WithFileWriter(w), WithAsyncLog(true, 2), WithAsyncOverflowPolicy(OverflowDropNewest, 0))
+	rule := d.rules()["app"][0]
+
+	d.Info("written")
+	<-w.started
+	for i := 0; i < 5; i++ {
+		d.Info("queued or dropped %d", i)
+	}
+	s := rule.Stats()
+	if s.Dropped != 3 || s.QueueLen != 2 || s.QueueCap != 2 {
+		t.Errorf("stats = %d dropped, queue %d/%d, want 3 dropped, queue 2/2", s.Dropped, s.QueueLen, s.QueueCap)
+	}
+
+	close(w.release)
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	d.ResetStats()
+	s = rule.Stats()
+	if len(s.EntriesByLevel) != 0 || s.BytesWritten != 0 || s.WriteErrors != 0 || s.Dropped != 0 {
+		t.Errorf("stats after reset = %+v", s)
+	}
+}
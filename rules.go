@@ -0,0 +1,117 @@
+package mklog
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// RuleInfo describes a log rule in the Rules snapshot.
+type RuleInfo struct {
+	Module    string   `json:"module"`    // Module of the rule
+	Index     int      `json:"index"`     // Index of the rule within the module
+	Enabled   bool     `json:"enabled"`   // Whether the rule accepts messages
+	MinLevel  LogLevel `json:"min_level"` // Minimum log level of the rule
+	MaxLevel  LogLevel `json:"max_level"` // Maximum log level of the rule
+	Console   bool     `json:"console"`   // Whether the rule writes to the console
+	File      string   `json:"file"`      // Current log file, empty without file logging
+	Async     bool     `json:"async"`     // Whether the rule logs asynchronously
+	Sinks     int      `json:"sinks"`     // Number of additional sinks
+	Formatter string   `json:"formatter"` // Type of the formatter
+	Fallback  bool     `json:"fallback"`  // Whether the rule was instantiated from the fallback rule, see SetFallbackRule
+}
+
+// WithDisabled creates the rule disabled, so it accepts messages only after Enable.
+func WithDisabled(disabled bool) Option {
+	return func(lr *LogRule) {
+		if disabled {
+			lr.Disable()
+		} else {
+			lr.Enable()
+		}
+	}
+}
+
+// WithDrainWhenDisabled keeps writing the messages queued for the async consumer after the rule
+// is disabled. By default the consumer discards them, so disabling stops file writes immediately.
+func WithDrainWhenDisabled(drain bool) Option {
+	return func(lr *LogRule) {
+		lr.AsyncLog.DrainWhenDisabled = drain
+	}
+}
+
+// Disable stops the rule from accepting messages until Enable is called.
+// Messages already queued for the async consumer are discarded unless DrainWhenDisabled is set.
+func (d *LogRule) Disable() *LogRule {
+	atomic.StoreInt32(&d.disabled, 1)
+	return d
+}
+
+// Enable lets a disabled rule accept messages again.
+func (d *LogRule) Enable() *LogRule {
+	atomic.StoreInt32(&d.disabled, 0)
+	return d
+}
+
+// Enabled reports whether the rule accepts messages.
+func (d *LogRule) Enabled() bool {
+	return atomic.LoadInt32(&d.disabled) == 0
+}
+
+// SetRuleEnabled enables or disables the rule of the module at the given index.
+func (d *Debugger) SetRuleEnabled(module string, index int, enabled bool) error {
+	rules, ok := d.rules()[module]
+	if !ok {
+		return fmt.Errorf("[mklog] unknown module: %s", module)
+	}
+	if index < 0 || index >= len(rules) {
+		return fmt.Errorf("[mklog] module %s has no rule %d", module, index)
+	}
+	if enabled {
+		rules[index].Enable()
+	} else {
+		rules[index].Disable()
+	}
+	return nil
+}
+
+// info describes the rule at the index of the module.
+func (d *LogRule) info(module string, index int) RuleInfo {
+	info := RuleInfo{
+		Module:    module,
+		Index:     index,
+		Enabled:   d.Enabled(),
+		MinLevel:  d.MinLevel,
+		MaxLevel:  d.MaxLevel,
+		Console:   d.IsConsoleOutput,
+		Async:     d.AsyncLog.Enable,
+		Sinks:     len(d.Sinks),
+		Formatter: formatterName(d.LogFormatter),
+	}
+	if d.FileLog.Enable {
+		info.File = d.FileLog.CurrentFileName
+	}
+	return info
+}
+
+// Rules returns a snapshot of the log rules ordered by module and index, including the instantiated fallback rules.
+func (d *Debugger) Rules() []RuleInfo {
+	var infos []RuleInfo
+	for module, rules := range d.rules() {
+		for i, v := range rules {
+			infos = append(infos, v.info(module, i))
+		}
+	}
+	for module, v := range d.fallbackRules().snapshot(false) {
+		info := v.info(module, 0)
+		info.Fallback = true
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Module != infos[j].Module {
+			return infos[i].Module < infos[j].Module
+		}
+		return infos[i].Index < infos[j].Index
+	})
+	return infos
+}
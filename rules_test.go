@@ -0,0 +1,99 @@
+package mklog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestSetRuleEnabled disables and enables one rule of a module while the other keeps logging, reports the
+// state in Rules and rejects unknown modules and indexes.
+func TestSetRuleEnabled(t *testing.T) {
+	first, second := &syncBuffer{}, &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(first))
+	d.NewLogRule("app", WithFileWriter(second), WithDisabled(true))
+	defer d.Close(context.Background())
+
+	d.Info("one")
+	if err := d.SetRuleEnabled("app", 1, true); err != nil {
+		t.Fatal(err)
+	}
+	d.Info("two")
+	if err := d.SetRuleEnabled("app", 0, false); err != nil {
+		t.Fatal(err)
+	}
+	d.Info("three")
+
+	if got := first.String(); !strings.Contains(got, "one") || !strings.Contains(got, "two") || strings.Contains(got, "three") {
+		t.Errorf("first rule wrote %q, want one and two", got)
+	}
+	if got := second.String(); strings.Contains(got, "one") || !strings.Contains(got, "two") || !strings.Contains(got, "three") {
+		t.Errorf("second rule wrote %q, want two and three", got)
+	}
+	if infos := d.Rules(); len(infos) != 2 || infos[0].Enabled || !infos[1].Enabled {
+		t.Errorf("Rules = %+v, want the first rule disabled and the second enabled", infos)
+	}
+
+	if err := d.SetRuleEnabled("db", 0, true); err == nil || !strings.Contains(err.Error(), "unknown module: db") {
+		t.Errorf("SetRuleEnabled of an unknown module = %v", err)
+	}
+	if err := d.SetRuleEnabled("app", 2, true); err == nil || !strings.Contains(err.Error(), "module app has no rule 2") {
+		t.Errorf("SetRuleEnabled of an unknown index = %v", err)
+	}
+}
+
+// TestDisableQueuedMessages discards the messages queued for the async consumer of a disabled rule, unless
+// the rule drains them.
+func TestDisableQueuedMessages(t *testing.T) {
+	for _, drain := range []bool{false, true} {
+		out := &gatedBuffer{gate: make(chan struct{}), started: make(chan struct{}, 4)}
+		d := (&Debugger{}).SetQuiet(true)
+		d.NewLogRule("app", WithFileWriter(out), WithAsyncLog(true, 8), WithDrainWhenDisabled(drain))
+		rule := d.rules()["app"][0]
+
+		d.Info("writing")
+		<-out.started // The consumer holds the first message.
+		d.Info("queued")
+		rule.Disable()
+		d.Info("dropped") // Not accepted by the disabled rule.
+		close(out.gate)
+		d.Close(context.Background())
+
+		got := out.String()
+		if !strings.Contains(got, "writing") || strings.Contains(got, "queued") != drain || strings.Contains(got, "dropped") {
+			t.Errorf("drain %v: output %q", drain, got)
+		}
+	}
+}
+
+// TestAdminRulesToggle disables a rule through the admin endpoint and rejects invalid requests.
+func TestAdminRulesToggle(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	defer d.Close(context.Background())
+
+	tests := []struct {
+		query string
+		code  int
+		body  string
+	}{
+		{"module=app&index=0&enabled=false", http.StatusOK, `{"enabled":false}`},
+		{"module=app&enabled=true", http.StatusOK, `{"enabled":true}`},
+		{"module=app&enabled=maybe", http.StatusBadRequest, "invalid enabled value: maybe"},
+		{"module=db&enabled=true", http.StatusNotFound, "unknown module: db"},
+		{"module=app&index=x&enabled=true", http.StatusNotFound, "invalid rule index: x"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		d.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rules?"+tt.query, nil))
+		if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("POST /rules?%s = %d %q, want %d %q", tt.query, rec.Code, rec.Body.String(), tt.code, tt.body)
+		}
+	}
+	if infos := d.Rules(); !infos[0].Enabled {
+		t.Errorf("Rules = %+v, want the rule enabled again", infos)
+	}
+}
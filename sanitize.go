@@ -0,0 +1,73 @@
+package mklog
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// WithRawOutput disables the escaping of control characters in the console and file output of the rule,
+// e.g. for applications logging ANSI colors on purpose.
+func WithRawOutput(raw bool) Option {
+	return func(lr *LogRule) {
+		lr.RawOutput = raw
+	}
+}
+
+// WithFileSanitization escapes control characters in the log file output like in the console output,
+// so logged user input cannot forge entries with carriage returns or hide them with escape sequences.
+func WithFileSanitization(enable bool) Option {
+	return func(lr *LogRule) {
+		lr.FileLog.Sanitize = enable
+	}
+}
+
+// sanitizeControl escapes the control characters of s as \xNN, and the C1 controls as \u00NN, keeping
+// newlines and tabs. Carriage returns, escape sequences and NUL bytes are rendered visibly instead of
+// moving the cursor, changing the terminal or hiding the rest of a line.
+func sanitizeControl(s string) string {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if isEscapedControl(rune(c)) {
+				break
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if isEscapedControl(r) {
+			break
+		}
+		i += size
+	}
+	if i == len(s) {
+		return s // Nothing to escape, the common case.
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s) + 8)
+	sb.WriteString(s[:i])
+	for _, r := range s[i:] {
+		switch {
+		case !isEscapedControl(r):
+			sb.WriteRune(r)
+		case r < utf8.RuneSelf:
+			sb.WriteString(`\x`)
+			sb.WriteByte(hexDigits[r>>4])
+			sb.WriteByte(hexDigits[r&0xf])
+		default:
+			sb.WriteString(`\u00`)
+			sb.WriteByte(hexDigits[r>>4&0xf])
+			sb.WriteByte(hexDigits[r&0xf])
+		}
+	}
+	return sb.String()
+}
+
+// isEscapedControl reports whether the rune is a control character other than newline and tab.
+func isEscapedControl(r rune) bool {
+	return (r < 0x20 && r != '\n' && r != '\t') || (r >= 0x7f && r <= 0x9f)
+}
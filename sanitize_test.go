@@ -0,0 +1,65 @@
+package mklog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSanitizeControl escapes carriage returns, escape sequences, NUL bytes and C1 controls and keeps newlines,
+// tabs and printable text.
+func TestSanitizeControl(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"user\rINFO forged", `user\x0dINFO forged`},
+		{"\x1b[31mred\x1b[0m", `\x1b[31mred\x1b[0m`},
+		{"name\x00hidden", `name\x00hidden`},
+		{"del\x7f and csi \u009b", `del\x7f and csi \u009b`},
+		{"line 1\n\tline 2", "line 1\n\tline 2"},
+		{"grüße\x07", `grüße\x07`},
+	}
+	for _, tt := range tests {
+		if got := sanitizeControl(tt.in); got != tt.want {
+			t.Errorf("sanitizeControl(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestSanitizeOutput escapes control characters on the console by default and in the file with file
+// sanitization, and keeps them in both with raw output.
+func TestSanitizeOutput(t *testing.T) {
+	const msg = "login\rERROR forged \x1b[2Kname\x00"
+	const escaped = `login\x0dERROR forged \x1b[2Kname\x00`
+	at := time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		opts            []Option
+		console, inFile string
+	}{
+		{"default", nil, escaped, msg},
+		{"file sanitization", []Option{WithFileSanitization(true)}, escaped, escaped},
+		{"raw output", []Option{WithFileSanitization(true), WithRawOutput(true)}, msg, msg},
+	}
+	for _, tt := range tests {
+		file := &syncBuffer{}
+		console := captureStdout(t, func() {
+			d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return at })
+			d.NewLogRule("app", append([]Option{WithFileWriter(file), WithDateFormat("15:04:05"), WithConsoleOutput(true)}, tt.opts...)...)
+			d.Info("%s", msg)
+			if _, err := d.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		if want := "10:20:30 | INFO | [app] : " + tt.inFile + "\n"; file.String() != want {
+			t.Errorf("%s: file = %q, want %q", tt.name, file.String(), want)
+		}
+		if !strings.Contains(console, tt.console+"\n") || strings.Count(console, "\n") != 1 {
+			t.Errorf("%s: console = %q, want %q on one line", tt.name, console, tt.console)
+		}
+	}
+}
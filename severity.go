@@ -0,0 +1,136 @@
+package mklog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Syslog severities as defined by RFC 5424.
+const (
+	SyslogEmergency     = 0 // System is unusable
+	SyslogAlert         = 1 // Action must be taken immediately
+	SyslogCritical      = 2 // Critical conditions
+	SyslogError         = 3 // Error conditions
+	SyslogWarning       = 4 // Warning conditions
+	SyslogNotice        = 5 // Normal but significant condition
+	SyslogInformational = 6 // Informational messages
+	SyslogDebug         = 7 // Debug-level messages
+)
+
+// customLevel is a log level registered with RegisterLevel.
+type customLevel struct {
+	name     string
+	severity int
+}
+
+var (
+	customLevelsMu sync.RWMutex
+	customLevels   = make(map[LogLevel]customLevel)
+)
+
+// RegisterLevel registers a custom log level with its display name and syslog severity (0 Emergency to 7 Debug),
+// so the level is named in the output, parsed by StringToLogLevel and mapped by the syslog helpers.
+// Built-in levels cannot be registered again.
+func RegisterLevel(level LogLevel, name string, syslogSeverity int) error {
+	if level >= TraceLevel && level <= FatalLevel {
+		return fmt.Errorf("[mklog] log level %d is a built-in level", level)
+	}
+	if name == "" {
+		return fmt.Errorf("[mklog] log level %d requires a name", level)
+	}
+	if syslogSeverity < SyslogEmergency || syslogSeverity > SyslogDebug {
+		return fmt.Errorf("[mklog] syslog severity %d of log level %s is out of range", syslogSeverity, name)
+	}
+
+	customLevelsMu.Lock()
+	customLevels[level] = customLevel{name: name, severity: syslogSeverity}
+	customLevelsMu.Unlock()
+	return nil
+}
+
+// registeredLevel returns the registration of a custom log level.
+func registeredLevel(level LogLevel) (customLevel, bool) {
+	customLevelsMu.RLock()
+	defer customLevelsMu.RUnlock()
+	c, ok := customLevels[level]
+	return c, ok
+}
+
+// registeredLevelByName returns the custom log level registered with the name, ignoring case.
+func registeredLevelByName(name string) (LogLevel, bool) {
+	customLevelsMu.RLock()
+	defer customLevelsMu.RUnlock()
+	for level, c := range customLevels {
+		if strings.EqualFold(c.name, name) {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// registeredLevelBySeverity returns the lowest custom log level registered with the syslog severity.
+func registeredLevelBySeverity(severity int) (LogLevel, bool) {
+	customLevelsMu.RLock()
+	defer customLevelsMu.RUnlock()
+	found := false
+	var lowest LogLevel
+	for level, c := range customLevels {
+		if c.severity == severity && (!found || level < lowest) {
+			lowest, found = level, true
+		}
+	}
+	return lowest, found
+}
+
+// SyslogSeverity returns the syslog severity of the level, from 0 (Emergency) to 7 (Debug).
+// Trace and Debug map to Debug, Fatal to Critical; unknown levels map to Notice.
+func (l LogLevel) SyslogSeverity() int {
+	switch l {
+	case TraceLevel, DebugLevel:
+		return SyslogDebug
+	case InfoLevel:
+		return SyslogInformational
+	case WarningLevel:
+		return SyslogWarning
+	case ErrorLevel:
+		return SyslogError
+	case FatalLevel:
+		return SyslogCritical
+	}
+	if c, ok := registeredLevel(l); ok {
+		return c.severity
+	}
+	return SyslogNotice
+}
+
+// Severity returns the severity of the level on a generic scale from 0 (least severe) to 7 (most severe),
+// the reverse of the syslog scale, for transports ranking levels in ascending order.
+func (l LogLevel) Severity() int {
+	return SyslogDebug - l.SyslogSeverity()
+}
+
+// FromSyslogSeverity returns the log level of a syslog severity. Severities without a built-in level
+// map to a custom level registered with that severity if there is one; otherwise Emergency and Alert
+// map to Fatal and Notice to Info. Values out of range are clamped.
+func FromSyslogSeverity(severity int) LogLevel {
+	switch severity {
+	case SyslogEmergency, SyslogAlert, SyslogNotice:
+		if level, ok := registeredLevelBySeverity(severity); ok {
+			return level
+		}
+	}
+
+	switch {
+	case severity <= SyslogCritical:
+		return FatalLevel
+	case severity == SyslogError:
+		return ErrorLevel
+	case severity == SyslogWarning:
+		return WarningLevel
+	case severity <= SyslogInformational:
+		return InfoLevel
+	default:
+		return DebugLevel
+	}
+}
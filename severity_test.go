@@ -0,0 +1,104 @@
+package mklog
+
+import "testing"
+
+// TestSyslogSeverityBuiltins maps every built-in level to its syslog and generic severities and back.
+func TestSyslogSeverityBuiltins(t *testing.T) {
+	tests := []struct {
+		level    LogLevel
+		syslog   int
+		severity int
+		back     LogLevel
+	}{
+		{TraceLevel, SyslogDebug, 0, DebugLevel},
+		{DebugLevel, SyslogDebug, 0, DebugLevel},
+		{InfoLevel, SyslogInformational, 1, InfoLevel},
+		{WarningLevel, SyslogWarning, 3, WarningLevel},
+		{ErrorLevel, SyslogError, 4, ErrorLevel},
+		{FatalLevel, SyslogCritical, 5, FatalLevel},
+		{LogLevel(42), SyslogNotice, 2, InfoLevel},
+	}
+	for _, tt := range tests {
+		if got := tt.level.SyslogSeverity(); got != tt.syslog {
+			t.Errorf("%v.SyslogSeverity() = %d, want %d", tt.level, got, tt.syslog)
+		}
+		if got := tt.level.Severity(); got != tt.severity {
+			t.Errorf("%v.Severity() = %d, want %d", tt.level, got, tt.severity)
+		}
+		if got := FromSyslogSeverity(tt.syslog); got != tt.back {
+			t.Errorf("FromSyslogSeverity(%d) = %v, want %v", tt.syslog, got, tt.back)
+		}
+	}
+}
+
+// TestFromSyslogSeverityWithoutBuiltin maps the severities without a built-in level and clamps values out of
+// range.
+func TestFromSyslogSeverityWithoutBuiltin(t *testing.T) {
+	tests := []struct {
+		severity int
+		want     LogLevel
+	}{
+		{-1, FatalLevel},
+		{SyslogEmergency, FatalLevel},
+		{SyslogAlert, FatalLevel},
+		{SyslogNotice, InfoLevel},
+		{8, DebugLevel},
+	}
+	for _, tt := range tests {
+		if got := FromSyslogSeverity(tt.severity); got != tt.want {
+			t.Errorf("FromSyslogSeverity(%d) = %v, want %v", tt.severity, got, tt.want)
+		}
+	}
+}
+
+// TestRegisterLevel declares the severity of a custom level at registration, names it in the output and
+// parses it back by name and by severity.
+func TestRegisterLevel(t *testing.T) {
+	const notice LogLevel = 20
+	if err := RegisterLevel(notice, "NOTICE", SyslogNotice); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		customLevelsMu.Lock()
+		delete(customLevels, notice)
+		customLevelsMu.Unlock()
+	}()
+
+	if got := notice.SyslogSeverity(); got != SyslogNotice {
+		t.Errorf("SyslogSeverity() = %d, want %d", got, SyslogNotice)
+	}
+	if got := notice.Severity(); got != 2 {
+		t.Errorf("Severity() = %d, want 2", got)
+	}
+	if got := notice.GetLogLevelName(); got != "NOTICE" {
+		t.Errorf("GetLogLevelName() = %q, want NOTICE", got)
+	}
+	if got, err := StringToLogLevel("notice"); err != nil || got != notice {
+		t.Errorf("StringToLogLevel(notice) = %v, %v", got, err)
+	}
+	if got := FromSyslogSeverity(SyslogNotice); got != notice {
+		t.Errorf("FromSyslogSeverity(Notice) = %v, want the custom level", got)
+	}
+}
+
+// TestRegisterLevelRejects refuses built-in levels, empty names and severities out of range.
+func TestRegisterLevelRejects(t *testing.T) {
+	tests := []struct {
+		level    LogLevel
+		name     string
+		severity int
+	}{
+		{ErrorLevel, "FAILURE", SyslogError},
+		{LogLevel(21), "", SyslogNotice},
+		{LogLevel(21), "AUDIT", 8},
+		{LogLevel(21), "AUDIT", -1},
+	}
+	for _, tt := range tests {
+		if err := RegisterLevel(tt.level, tt.name, tt.severity); err == nil {
+			t.Errorf("RegisterLevel(%d, %q, %d) succeeded", tt.level, tt.name, tt.severity)
+		}
+	}
+	if _, ok := registeredLevel(21); ok {
+		t.Error("rejected level was registered")
+	}
+}
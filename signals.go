@@ -0,0 +1,244 @@
+package mklog
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+)
+
+// ShutdownSignal is the signal delivered by Debugger.Shutdown, for platforms without usable OS signals
+// such as Windows services.
+var ShutdownSignal os.Signal = shutdownSignal{}
+
+// shutdownSignal is the os.Signal implementation of ShutdownSignal.
+type shutdownSignal struct{}
+
+func (shutdownSignal) String() string { return "shutdown" }
+func (shutdownSignal) Signal()        {}
+
+// signalHandler holds the signals a Debugger subscribed to. Its fields are guarded by the mutex of processSignals.
+type signalHandler struct {
+	notify        []os.Signal // Signals that close the Debugger if requested and are forwarded to the rules
+	closeOnSignal bool        // Whether a received signal closes the Debugger
+	verbosity     bool        // Whether the verbosity signals are handled, see HandleVerbositySignals
+	shutdown      atomic.Bool // Set while a shutdown triggered by Shutdown is pending
+}
+
+// accepts reports whether the Debugger subscribed to the signal.
+func (h *signalHandler) accepts(sig os.Signal) bool {
+	if _, ok := verbositySignals[sig]; ok && h.verbosity {
+		return true
+	}
+	for _, v := range h.notify {
+		if v == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// signalRegistry receives the signals of all live Debuggers on a single channel and dispatches them from a
+// single goroutine. Debuggers are registered on first use of the signal handling and removed by Close.
+type signalRegistry struct {
+	mu        sync.Mutex
+	once      sync.Once                    // Guards the start of the dispatching goroutine
+	signals   chan os.Signal               // Channel registered with signal.Notify
+	notified  map[os.Signal]bool           // Signals registered with the channel
+	debuggers map[*Debugger]*signalHandler // Live Debuggers using the signal handling
+}
+
+// processSignals is the signal registry of the process.
+var processSignals = &signalRegistry{
+	signals:   make(chan os.Signal, 1),
+	notified:  map[os.Signal]bool{},
+	debuggers: map[*Debugger]*signalHandler{},
+}
+
+// register adds the Debugger unless it is closed already, starting the dispatching goroutine on first use.
+func (r *signalRegistry) register(d *Debugger, h *signalHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-d.closedChannel():
+		return
+	default:
+	}
+	r.debuggers[d] = h
+	r.update()
+	r.once.Do(func() { go r.run() })
+}
+
+// remove drops the Debugger, so it no longer receives signals and is not kept alive by the registry.
+func (r *signalRegistry) remove(d *Debugger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.debuggers[d]; ok {
+		delete(r.debuggers, d)
+		r.update()
+	}
+}
+
+// update registers the signals the live Debuggers subscribed to with the channel, and stops the delivery of the
+// others so they get their default behaviour back. It must be called with the mutex held.
+func (r *signalRegistry) update() {
+	want := map[os.Signal]bool{}
+	for _, h := range r.debuggers {
+		for _, sig := range h.notify {
+			want[sig] = true
+		}
+		if h.verbosity {
+			for sig := range verbositySignals {
+				want[sig] = true
+			}
+		}
+	}
+	for sig := range r.notified {
+		if !want[sig] {
+			// Notify only adds signals, so the channel is registered again with the remaining ones.
+			signal.Stop(r.signals)
+			r.notified = map[os.Signal]bool{}
+			break
+		}
+	}
+	var added []os.Signal
+	for sig := range want {
+		if !r.notified[sig] {
+			added = append(added, sig)
+		}
+	}
+	if len(added) > 0 {
+		signal.Notify(r.signals, added...)
+	}
+	r.notified = want
+}
+
+// run dispatches the received signals to the Debuggers subscribed to them, in parallel so one Debugger slow to
+// close does not hold up the others, and waits for them before receiving the next signal.
+func (r *signalRegistry) run() {
+	for sig := range r.signals {
+		r.mu.Lock()
+		var targets []*Debugger
+		for d, h := range r.debuggers {
+			if h.accepts(sig) {
+				targets = append(targets, d)
+			}
+		}
+		r.mu.Unlock()
+
+		var wg sync.WaitGroup
+		for _, d := range targets {
+			wg.Add(1)
+			go func(d *Debugger) {
+				defer wg.Done()
+				d.dispatchSignal(sig)
+			}(d)
+		}
+		wg.Wait()
+	}
+}
+
+// HandleSignals closes the Debugger when one of the signals is received, then forwards the signal
+// to the signal channels of the rules so the application can exit. Without signals the platform
+// default set is used: SIGINT and SIGTERM on Unix, os.Interrupt elsewhere. Calling it again replaces the set.
+//
+// The signals of all Debuggers are received by a single process-wide handler, which keeps every Debugger using
+// the signal handling alive until its Close is called; Close must be called once a Debugger is no longer used.
+func (d *Debugger) HandleSignals(signals ...os.Signal) *Debugger {
+	if len(signals) == 0 {
+		signals = DefaultShutdownSignals()
+	}
+
+	h := d.signalHandler()
+	processSignals.mu.Lock()
+	h.closeOnSignal = true
+	h.notify = append([]os.Signal(nil), signals...)
+	processSignals.update()
+	processSignals.mu.Unlock()
+	return d
+}
+
+// HandleVerbositySignals bumps the verbosity of the Debugger by one step on SIGUSR1 and lowers it by one step
+// on SIGUSR2, see BumpVerbosity, without closing the Debugger or forwarding the signals to the rules. It only
+// takes effect on Unix; elsewhere use BumpVerbosity, e.g. through the admin handler. Like HandleSignals, it
+// requires the Debugger to be closed with Close.
+func (d *Debugger) HandleVerbositySignals() *Debugger {
+	h := d.signalHandler()
+	processSignals.mu.Lock()
+	h.verbosity = true
+	processSignals.update()
+	processSignals.mu.Unlock()
+	return d
+}
+
+// Shutdown triggers the same sequence as a signal passed to HandleSignals, delivering ShutdownSignal. It does
+// nothing once the Debugger is closed.
+func (d *Debugger) Shutdown() {
+	h := d.signalHandler()
+	select {
+	case <-d.closedChannel():
+		return
+	default:
+	}
+	if !h.shutdown.CompareAndSwap(false, true) {
+		return // A shutdown is already pending.
+	}
+	go func() {
+		defer h.shutdown.Store(false)
+		d.dispatchSignal(ShutdownSignal)
+	}()
+}
+
+// DefaultShutdownSignals returns the signals handled by default on the current platform.
+func DefaultShutdownSignals() []os.Signal {
+	return append([]os.Signal(nil), defaultShutdownSignals...)
+}
+
+// signalHandler returns the signal handler of the Debugger, registering it with processSignals on first use
+// with os.Interrupt forwarded to the rules, as rules have always been notified about it.
+func (d *Debugger) signalHandler() *signalHandler {
+	d.signalOnce.Do(func() {
+		d.signals = &signalHandler{notify: []os.Signal{os.Interrupt}}
+		processSignals.register(d, d.signals)
+	})
+	return d.signals
+}
+
+// dispatchSignal bumps the verbosity on a verbosity signal. On other signals it closes the Debugger if
+// requested and forwards the signal to the rules.
+func (d *Debugger) dispatchSignal(sig os.Signal) {
+	processSignals.mu.Lock()
+	closeOnSignal, verbosity := d.signals.closeOnSignal, d.signals.verbosity
+	processSignals.mu.Unlock()
+
+	if delta, ok := verbositySignals[sig]; ok && verbosity {
+		d.BumpVerbosity(delta)
+		return
+	}
+	if closeOnSignal {
+		ctx, cancel := context.WithTimeout(context.Background(), MKLOG_CloseTimeoutDefault)
+		if _, err := d.Close(ctx); err != nil {
+			d.handleError(err)
+		}
+		cancel()
+	}
+	d.forwardSignal(sig)
+}
+
+// forwardSignal delivers the signal to the signal channels of the rules without blocking.
+func (d *Debugger) forwardSignal(sig os.Signal) {
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			if v.signalChannel == nil {
+				continue
+			}
+			select {
+			case v.signalChannel <- sig:
+			default:
+			}
+		}
+	}
+}
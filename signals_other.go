@@ -0,0 +1,12 @@
+//go:build !unix
+
+package mklog
+
+import "os"
+
+// defaultShutdownSignals is os.Interrupt, the only signal delivered on every platform; use Debugger.Shutdown
+// where the platform has its own stop semantics, such as Windows services.
+var defaultShutdownSignals = []os.Signal{os.Interrupt}
+
+// verbositySignals is empty, as SIGUSR1 and SIGUSR2 do not exist; use BumpVerbosity, e.g. through the admin handler.
+var verbositySignals map[os.Signal]int
@@ -0,0 +1,27 @@
+//go:build !unix
+
+package mklog
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestDefaultSignalsOther handles only os.Interrupt by default where Unix signals do not exist, e.g. on
+// Windows, and ignores the verbosity signals.
+func TestDefaultSignalsOther(t *testing.T) {
+	if signals := DefaultShutdownSignals(); len(signals) != 1 || signals[0] != os.Interrupt {
+		t.Errorf("DefaultShutdownSignals = %v, want os.Interrupt", signals)
+	}
+	if len(verbositySignals) != 0 {
+		t.Errorf("verbositySignals = %v, want none", verbositySignals)
+	}
+
+	d := (&Debugger{}).SetQuiet(true).HandleVerbositySignals()
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	defer d.Close(context.Background())
+	if d.Enabled(DebugLevel) {
+		t.Error("HandleVerbositySignals changed the verbosity")
+	}
+}
@@ -0,0 +1,78 @@
+package mklog
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// receiveSignal waits for a signal on the channel.
+func receiveSignal(t *testing.T, signals chan os.Signal) os.Signal {
+	t.Helper()
+	select {
+	case sig := <-signals:
+		return sig
+	case <-time.After(5 * time.Second):
+		t.Fatal("no signal forwarded to the rule")
+		return nil
+	}
+}
+
+// TestShutdown closes the Debugger on Shutdown, writing the buffered entries and closing the file writer, then
+// forwards ShutdownSignal to the rules.
+func TestShutdown(t *testing.T) {
+	w := &syncCloseWriter{}
+	d := (&Debugger{}).SetQuiet(true).HandleSignals()
+	d.NewLogRule("app", WithFileWriter(w), WithCloseFileWriter(true), WithAsyncLog(true, 16))
+	rule := d.rules()["app"][0]
+
+	d.Info("buffered")
+	d.Shutdown()
+	if sig := receiveSignal(t, rule.GetSignalChannel()); sig != ShutdownSignal {
+		t.Errorf("forwarded signal = %v, want ShutdownSignal", sig)
+	}
+	if got := waitCalls(w, "write,sync,close"); got != "write,sync,close" {
+		t.Errorf("writer calls = %s, want the entry written and the writer closed before the signal is forwarded", got)
+	}
+}
+
+// registeredSignals reports whether the Debugger is registered with the process-wide signal handling.
+func registeredSignals(d *Debugger) bool {
+	processSignals.mu.Lock()
+	defer processSignals.mu.Unlock()
+	_, ok := processSignals.debuggers[d]
+	return ok
+}
+
+// TestSignalRegistry registers the Debuggers using the signal handling with the process-wide handler and removes
+// them on Close, after which they are neither registered again nor forward signals to their rules.
+func TestSignalRegistry(t *testing.T) {
+	plain := (&Debugger{}).SetQuiet(true)
+	plain.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	handled := (&Debugger{}).SetQuiet(true).HandleSignals()
+	handled.NewLogRule("app", WithFileWriter(&syncBuffer{}))
+	if !registeredSignals(plain) || !registeredSignals(handled) {
+		t.Fatal("Debuggers not registered with the signal handling")
+	}
+
+	handled.Close(context.Background())
+	if registeredSignals(handled) || !registeredSignals(plain) {
+		t.Error("Close did not remove exactly the closed Debugger")
+	}
+	plain.Close(context.Background())
+	if registeredSignals(plain) {
+		t.Error("closed Debugger still registered")
+	}
+
+	rule := plain.rules()["app"][0]
+	plain.HandleSignals().Shutdown()
+	if registeredSignals(plain) {
+		t.Error("closed Debugger registered again by HandleSignals")
+	}
+	select {
+	case sig := <-rule.GetSignalChannel():
+		t.Errorf("closed Debugger forwarded %v", sig)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package mklog
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultShutdownSignals are SIGINT and SIGTERM, the latter sent by Kubernetes and systemd on stop.
+var defaultShutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// verbositySignals are the signals handled by HandleVerbositySignals with their verbosity bump.
+var verbositySignals = map[os.Signal]int{syscall.SIGUSR1: 1, syscall.SIGUSR2: -1}
@@ -0,0 +1,78 @@
+//go:build unix
+
+package mklog
+
+import (
+	"context"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestHandleSignalsSIGTERM closes the Debugger on SIGTERM, one of the default signals on Unix, and forwards the
+// signal to the rules.
+func TestHandleSignalsSIGTERM(t *testing.T) {
+	w := &syncCloseWriter{}
+	d := (&Debugger{}).SetQuiet(true).HandleSignals()
+	d.NewLogRule("app", WithFileWriter(w), WithCloseFileWriter(true), WithAsyncLog(true, 16))
+	rule := d.rules()["app"][0]
+
+	d.Info("buffered")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if sig := receiveSignal(t, rule.GetSignalChannel()); sig != syscall.SIGTERM {
+		t.Errorf("forwarded signal = %v, want SIGTERM", sig)
+	}
+	if got := waitCalls(w, "write,sync,close"); got != "write,sync,close" {
+		t.Errorf("writer calls = %s, want the entry written and the writer closed", got)
+	}
+}
+
+// TestVerbositySignals bumps the verbosity on SIGUSR1 and lowers it on SIGUSR2 without closing the Debugger.
+func TestVerbositySignals(t *testing.T) {
+	out := &syncBuffer{}
+	d := (&Debugger{}).SetQuiet(true).HandleVerbositySignals()
+	d.NewLogRule("app", WithFileWriter(out))
+	defer d.Close(context.Background())
+
+	raise := func(sig syscall.Signal, want bool) {
+		t.Helper()
+		if err := syscall.Kill(os.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for d.Enabled(DebugLevel) != want && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if d.Enabled(DebugLevel) != want {
+			t.Fatalf("Enabled(Debug) = %v after %v, want %v", !want, sig, want)
+		}
+	}
+	raise(syscall.SIGUSR1, true)
+	raise(syscall.SIGUSR2, false)
+	d.Info("still open")
+	if !strings.Contains(out.String(), "still open") {
+		t.Errorf("output = %q, want the Debugger to keep logging", out.String())
+	}
+}
+
+// TestHandleSignalsStoppedOnClose stops the delivery of the signals handled by a Debugger once it is closed, so
+// they get their default behaviour back.
+func TestHandleSignalsStoppedOnClose(t *testing.T) {
+	d := (&Debugger{}).SetQuiet(true).HandleSignals(syscall.SIGHUP)
+	notified := func() bool {
+		processSignals.mu.Lock()
+		defer processSignals.mu.Unlock()
+		return processSignals.notified[syscall.SIGHUP]
+	}
+	if !notified() {
+		t.Fatal("SIGHUP not registered by HandleSignals")
+	}
+	d.Close(context.Background())
+	if notified() {
+		t.Error("SIGHUP still registered after Close")
+	}
+}
@@ -0,0 +1,109 @@
+package mklog
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Sink is an additional output receiving the entries accepted by a rule,
+// such as a database table or a remote collector. Errors of a sink are reported as part of an OutputError
+// naming the sink by its Name method, if it has one, or by its type.
+// Write is called for one entry of the rule at a time, in the order the console and log file receive the
+// entries, see Entry.Seq; it must not log to the same rule synchronously.
+type Sink interface {
+	// Write stores the entry; formatted holds the entry rendered by the rule's formatter, without a trailing
+	// line break.
+	Write(entry Entry, formatted string) error
+	// Close flushes buffered entries and releases the resources of the sink.
+	Close() error
+}
+
+// SinkFactory creates a sink from the options of a config file sink block.
+type SinkFactory func(options map[string]interface{}) (Sink, error)
+
+// AddSink attaches the sink to the log rule.
+func (d *LogRule) AddSink(sink Sink) *LogRule {
+	if s, ok := sink.(ErrorHandlerSetter); ok {
+		s.SetErrorHandler(d.reportError)
+	}
+	d.Sinks = append(d.Sinks, sink)
+	return d
+}
+
+// writeSinks passes the entry to every sink of the rule, returning the errors of the failed sinks named
+// after them.
+func (lr *LogRule) writeSinks(entry Entry, formatted string) []error {
+	var errs []error
+	for i, sink := range lr.Sinks {
+		start := time.Now()
+		err := lr.writeSink(sink, entry, formatted)
+		lr.observeWrite(i, time.Since(start))
+		lr.countWrite(0, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", outputName(i, sink), err))
+		}
+	}
+	return errs
+}
+
+// CloseSinks closes every sink of the rule and returns the first error encountered.
+func (lr *LogRule) CloseSinks() error {
+	var firstErr error
+	for _, sink := range lr.Sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// OutputError reports the outputs of a rule that failed to write one entry, delivered to the error handler
+// once per entry instead of once per failed output.
+type OutputError struct {
+	Module string    // Module of the rule.
+	Level  LogLevel  // Level of the entry.
+	Time   time.Time // Time of the entry.
+	Err    error     // Errors of the failed outputs joined with errors.Join, each prefixed with the output name.
+}
+
+// Error returns the errors of the failed outputs, one per line.
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("[mklog] writing the %s entry of %s logged at %s failed:\n%v",
+		e.Level.GetLogLevelName(), e.Module, e.Time.Format(time.RFC3339Nano), e.Err)
+}
+
+// Unwrap returns the joined errors of the failed outputs.
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+// reportOutputErrors passes the errors of the outputs that failed to write the entry to the error handler
+// as one OutputError.
+func (lr *LogRule) reportOutputErrors(entry Entry, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	lr.reportError(lr.outputError(entry, errs))
+}
+
+// deferOutputErrors is reportOutputErrors for entries written while the rule is acquired, see deferError.
+func (lr *LogRule) deferOutputErrors(entry Entry, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	lr.deferError(lr.outputError(entry, errs))
+}
+
+// outputError joins the errors of the outputs that failed to write the entry into one OutputError.
+func (lr *LogRule) outputError(entry Entry, errs []error) error {
+	return &OutputError{Module: lr.ModuleName, Level: entry.Level, Time: entry.Time, Err: errors.Join(errs...)}
+}
+
+// outputName names a sink or hook by its Name method or by its type and position.
+func outputName(i int, output interface{}) string {
+	if n, ok := output.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%d (%T)", i, output)
+}
@@ -0,0 +1,126 @@
+package mklog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errTimeout = errors.New("timeout")
+
+// namedFailingSink fails every write with errTimeout and names itself.
+type namedFailingSink struct{ name string }
+
+func (s namedFailingSink) Name() string              { return s.name }
+func (s namedFailingSink) Write(Entry, string) error { return errTimeout }
+func (s namedFailingSink) Close() error              { return nil }
+
+// failingHook fails every entry it is fired for.
+type failingHook struct{}
+
+func (failingHook) Levels() []LogLevel { return []LogLevel{ErrorLevel} }
+func (failingHook) Fire(Entry) error   { return errors.New("hook unavailable") }
+
+// TestOutputErrorsAggregated fails the log file and two sinks of an entry at once and expects one call of the
+// error handler naming every failed output, while the console and the working sink still get the entry.
+func TestOutputErrorsAggregated(t *testing.T) {
+	var errs []error
+	at := time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC)
+	working := &fieldSink{}
+	console := captureStdout(t, func() {
+		d := (&Debugger{}).SetQuiet(true).SetClock(func() time.Time { return at })
+		d.SetErrorHandler(func(err error) { errs = append(errs, err) })
+		d.NewLogRule("app",
+			WithFileWriter(failingWriter{}),
+			WithConsoleOutput(true),
+			WithSink(namedFailingSink{name: "webhook"}),
+			WithSink(working),
+			WithSink(pickySink{}),
+		)
+		d.Info("fine")
+		d.Info("reject this")
+		if _, err := d.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if len(errs) != 2 {
+		t.Fatalf("error handler called %d times, want once per entry: %v", len(errs), errs)
+	}
+	var out *OutputError
+	if !errors.As(errs[1], &out) {
+		t.Fatalf("error %T, want *OutputError", errs[1])
+	}
+	if out.Module != "app" || out.Level != InfoLevel || !out.Time.Equal(at) {
+		t.Errorf("output error = %+v", out)
+	}
+	if !errors.Is(out, io.ErrClosedPipe) || !errors.Is(out, errTimeout) {
+		t.Errorf("output error %v does not wrap the errors of the outputs", out)
+	}
+	msg := out.Error()
+	for _, want := range []string{
+		"[mklog] writing the INFO entry of app logged at 2024-03-09T10:20:30Z failed:",
+		"file: io: read/write on closed pipe",
+		"sink webhook: timeout",
+		"sink 2 (mklog.pickySink): rejected",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error does not contain %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(errs[0].Error(), "pickySink") {
+		t.Errorf("first entry reported the sink that accepted it: %v", errs[0])
+	}
+	if len(working.fields) != 2 || strings.Count(console, "\n") != 2 {
+		t.Errorf("working outputs got %d sink and %d console entries, want 2", len(working.fields), strings.Count(console, "\n"))
+	}
+}
+
+// TestOutputErrorsHooks reports the failures of a sink and a hook of an entry as one error and nothing for an
+// entry all outputs write.
+func TestOutputErrorsHooks(t *testing.T) {
+	var errs []error
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(func(err error) { errs = append(errs, err) })
+	d.NewLogRule("app", WithFileWriter(&syncBuffer{}), WithSink(pickySink{}))
+	d.rules()["app"][0].AddHook(failingHook{})
+
+	d.Info("accepted")
+	d.Error("reject")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("error handler called %d times, want once: %v", len(errs), errs)
+	}
+	msg := errs[0].Error()
+	if !strings.Contains(msg, "sink 0 (mklog.pickySink): rejected") || !strings.Contains(msg, "hook 0 (mklog.failingHook): hook unavailable") {
+		t.Errorf("error = %s, want the sink and the hook", msg)
+	}
+}
+
+// TestOutputErrorsAsync reports a failed write of the async consumer as an OutputError of the file.
+func TestOutputErrorsAsync(t *testing.T) {
+	reported := make(chan error, 4)
+	d := (&Debugger{}).SetQuiet(true)
+	d.SetErrorHandler(func(err error) { reported <- err })
+	d.NewLogRule("app", WithFileWriter(failingWriter{}), WithAsyncLog(true, 4))
+
+	d.Warning("queued")
+	if _, err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reported:
+		var out *OutputError
+		if !errors.As(err, &out) || out.Level != WarningLevel || !errors.Is(err, io.ErrClosedPipe) || !strings.Contains(err.Error(), "file: ") {
+			t.Errorf("error = %v, want an OutputError of the file", err)
+		}
+	default:
+		t.Fatal("failed async write not reported")
+	}
+}
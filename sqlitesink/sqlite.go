@@ -0,0 +1,333 @@
+// Package sqlitesink provides an mklog sink storing entries in a local SQLite database,
+// so that tools shipping with mklog can query their logs instead of parsing text files.
+//
+// The package only depends on database/sql; the application imports the SQLite driver
+// of its choice, e.g. modernc.org/sqlite (driver "sqlite") or github.com/mattn/go-sqlite3 (driver "sqlite3").
+// While the database is unavailable the buffer is bounded: entries beyond its capacity are dropped and counted,
+// see Dropped.
+package sqlitesink
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+var (
+	// Default settings of the SQLite sink
+	DriverDefault        = "sqlite"    // Default database/sql driver name
+	TableDefault         = "logs"      // Default table name
+	FlushIntervalDefault = time.Second // Default interval between batched inserts
+	BatchSizeDefault     = 100         // Default number of entries triggering an early flush and inserted by one transaction
+	QueueSizeDefault     = 10000       // Default number of buffered entries before dropping
+)
+
+// timeLayout renders UTC timestamps so that they sort lexicographically.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// tableNamePattern restricts table names to plain identifiers.
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Options configures the SQLite sink.
+type Options struct {
+	Table         string             // Name of the log table.
+	FlushInterval time.Duration      // Interval between batched inserts.
+	BatchSize     int                // Number of buffered entries triggering an early flush and inserted by one transaction.
+	MaxRows       int64              // Number of newest rows kept after each flush, zero keeps everything.
+	QueueSize     int                // Maximum number of buffered entries, further entries are dropped.
+	ErrorHandler  mklog.ErrorHandler // Handler for failed flushes, see mklog.ErrorHandler.Report.
+}
+
+// Sink stores log entries in a SQLite table with batched inserts.
+type Sink struct {
+	db      *sql.DB
+	options Options
+	ownsDB  bool                        // Whether Close also closes the database
+	batch   *mklog.Batcher[mklog.Entry] // Buffer inserted in batches by a background goroutine
+}
+
+// schemaTable records the schema version of every log table, so that sinks writing to different tables of one
+// database, and applications using PRAGMA user_version themselves, do not skip each other's migrations.
+const schemaTable = `CREATE TABLE IF NOT EXISTS mklog_schema (
+	table_name TEXT PRIMARY KEY,
+	version INTEGER NOT NULL
+)`
+
+// migrations holds the schema versions of a log table applied in order, tracked in the mklog_schema table.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS %[1]s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ts TEXT NOT NULL,
+		level INTEGER NOT NULL,
+		module TEXT NOT NULL,
+		submodules TEXT,
+		message TEXT NOT NULL,
+		fields TEXT,
+		error TEXT,
+		stack TEXT
+	);
+	CREATE INDEX IF NOT EXISTS %[1]s_ts_idx ON %[1]s (ts);
+	CREATE INDEX IF NOT EXISTS %[1]s_level_idx ON %[1]s (level);`,
+}
+
+// Open opens the SQLite database file with the given driver and creates a sink writing to it.
+// The database is closed together with the sink.
+func Open(driverName, path string, options Options) (*Sink, error) {
+	if driverName == "" {
+		driverName = DriverDefault
+	}
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	s, err := New(db, options)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.ownsDB = true
+	return s, nil
+}
+
+// New creates a sink writing to an already opened SQLite database and migrates its schema.
+func New(db *sql.DB, options Options) (*Sink, error) {
+	if options.Table == "" {
+		options.Table = TableDefault
+	}
+	if !tableNamePattern.MatchString(options.Table) {
+		return nil, fmt.Errorf("invalid table name: %s", options.Table)
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = FlushIntervalDefault
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = BatchSizeDefault
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = QueueSizeDefault
+	}
+
+	s := &Sink{db: db, options: options}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+
+	s.batch = mklog.NewBatcher(mklog.BatcherOptions{
+		Name:          "sqlite sink",
+		FlushInterval: options.FlushInterval,
+		BatchSize:     options.BatchSize,
+		QueueSize:     options.QueueSize,
+		ErrorHandler:  options.ErrorHandler,
+	}, s.insert)
+	return s, nil
+}
+
+// Factory creates a sink from config options, to be registered with LogConfigManager.RegisterSink.
+// Supported options are path (required), driver, table, flush_interval, batch_size, max_rows and queue_size.
+func Factory(options map[string]interface{}) (mklog.Sink, error) {
+	path, _ := options["path"].(string)
+	if path == "" {
+		return nil, fmt.Errorf("sqlite sink requires a path")
+	}
+	driver, _ := options["driver"].(string)
+	table, _ := options["table"].(string)
+
+	flushInterval, err := durationOption(options, "flush_interval")
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := intOption(options, "batch_size")
+	if err != nil {
+		return nil, err
+	}
+	maxRows, err := intOption(options, "max_rows")
+	if err != nil {
+		return nil, err
+	}
+	queueSize, err := intOption(options, "queue_size")
+	if err != nil {
+		return nil, err
+	}
+
+	return Open(driver, path, Options{
+		Table:         table,
+		FlushInterval: flushInterval,
+		BatchSize:     int(batchSize),
+		MaxRows:       maxRows,
+		QueueSize:     int(queueSize),
+	})
+}
+
+// Write buffers the entry until the next flush, dropping it when the buffer is full.
+func (s *Sink) Write(entry mklog.Entry, formatted string) error {
+	s.batch.Add(entry)
+	return nil
+}
+
+// Dropped returns the number of entries dropped because the buffer was full.
+func (s *Sink) Dropped() uint64 {
+	return s.batch.Dropped()
+}
+
+// Flush inserts the buffered entries with one transaction per batch of at most BatchSize entries and prunes
+// old rows. Entries of a failed insert are kept for the next flush as far as the buffer has room.
+func (s *Sink) Flush() error {
+	return s.batch.Flush()
+}
+
+// Close flushes the remaining entries and stops the sink.
+func (s *Sink) Close() error {
+	err := s.batch.Close()
+	if s.ownsDB {
+		if closeErr := s.db.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// SetErrorHandler sets the handler receiving failed flushes.
+func (s *Sink) SetErrorHandler(handler mklog.ErrorHandler) {
+	s.batch.SetErrorHandler(handler)
+}
+
+// migrate brings the schema of the log table to the latest version.
+func (s *Sink) migrate() error {
+	if _, err := s.db.Exec(schemaTable); err != nil {
+		return fmt.Errorf("failed to create schema table: %w", err)
+	}
+	var version int
+	err := s.db.QueryRow("SELECT version FROM mklog_schema WHERE table_name = ?", s.options.Table).Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration: %w", err)
+		}
+		for _, stmt := range strings.Split(fmt.Sprintf(migrations[i], s.options.Table), ";") {
+			if strings.TrimSpace(stmt) == "" {
+				continue
+			}
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to migrate schema to version %d: %w", i+1, err)
+			}
+		}
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO mklog_schema (table_name, version) VALUES (?, ?)",
+			s.options.Table, i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration: %w", err)
+		}
+	}
+	return nil
+}
+
+// insert writes the entries and prunes the table in one transaction.
+func (s *Sink) insert(entries []mklog.Entry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf(
+		"INSERT INTO %s (ts, level, module, submodules, message, fields, error, stack) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		s.options.Table))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		submodules, fields, errText, stack := entryColumns(e)
+		if _, err := stmt.Exec(e.Time.UTC().Format(timeLayout), int(e.Level), e.Module, submodules, e.Message, fields, errText, stack); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert entry: %w", err)
+		}
+	}
+
+	if s.options.MaxRows > 0 {
+		if _, err := tx.Exec(fmt.Sprintf(
+			"DELETE FROM %[1]s WHERE id NOT IN (SELECT id FROM %[1]s ORDER BY id DESC LIMIT ?)",
+			s.options.Table), s.options.MaxRows); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to prune table: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit entries: %w", err)
+	}
+	return nil
+}
+
+// entryColumns converts the optional parts of the entry into nullable column values.
+func entryColumns(e mklog.Entry) (submodules, fields, errText, stack sql.NullString) {
+	if len(e.Submodules) > 0 {
+		data, _ := json.Marshal(e.Submodules)
+		submodules = sql.NullString{String: string(data), Valid: true}
+	}
+	if len(e.Fields) > 0 {
+		if data, err := json.Marshal(e.Fields); err == nil {
+			fields = sql.NullString{String: string(data), Valid: true}
+		}
+	}
+	if e.Err != nil {
+		errText = sql.NullString{String: e.Err.Error(), Valid: true}
+		if detailed, ok := e.Err.(mklog.DetailedError); ok {
+			stack = sql.NullString{String: detailed.StackInfo, Valid: true}
+		} else if origin := mklog.OriginStack(e.Err); origin != "" {
+			stack = sql.NullString{String: origin, Valid: true}
+		}
+	}
+	return submodules, fields, errText, stack
+}
+
+// durationOption reads a duration given as a string like "5s" or as a number of seconds.
+func durationOption(options map[string]interface{}, key string) (time.Duration, error) {
+	switch v := options[key].(type) {
+	case nil:
+		return 0, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return d, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	default:
+		return 0, fmt.Errorf("invalid %s: %v", key, v)
+	}
+}
+
+// intOption reads an integer option decoded from YAML or JSON.
+func intOption(options map[string]interface{}, key string) (int64, error) {
+	switch v := options[key].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("invalid %s: %v", key, v)
+	}
+}
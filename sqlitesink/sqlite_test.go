@@ -0,0 +1,177 @@
+//go:build cgo
+
+package sqlitesink
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestDB opens a SQLite database in a temporary directory and returns it with its path.
+func openTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "logs.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+// TestSinkInsertAndQuery stores entries on Close and reads back their columns.
+func TestSinkInsertAndQuery(t *testing.T) {
+	_, path := openTestDB(t)
+	s, err := Open("sqlite3", path, Options{FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 3, 9, 10, 20, 30, 5, time.FixedZone("CET", 3600))
+	s.Write(mklog.Entry{Time: at, Level: mklog.InfoLevel, Module: "app", Message: "started"}, "")
+	s.Write(mklog.Entry{
+		Time:       at.Add(time.Second),
+		Level:      mklog.ErrorLevel,
+		Module:     "db",
+		Submodules: []string{"pool"},
+		Message:    "query failed",
+		Fields:     map[string]interface{}{"table": "users"},
+		Err:        errors.New("timeout"),
+	}, "")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT ts, level, module, submodules, message, fields, error FROM logs ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		ts, module, message      string
+		level                    int
+		submodules, fields, errs sql.NullString
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.ts, &r.level, &r.module, &r.submodules, &r.message, &r.fields, &r.errs); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d rows, want 2", len(got))
+	}
+	if got[0].ts != "2024-03-09T09:20:30.000000005Z" || got[0].level != int(mklog.InfoLevel) || got[0].message != "started" {
+		t.Errorf("first row = %+v", got[0])
+	}
+	if got[0].submodules.Valid || got[0].fields.Valid || got[0].errs.Valid {
+		t.Errorf("first row has optional columns: %+v", got[0])
+	}
+	second := got[1]
+	if second.module != "db" || second.submodules.String != `["pool"]` || second.fields.String != `{"table":"users"}` ||
+		second.errs.String != "timeout" {
+		t.Errorf("second row = %+v", second)
+	}
+}
+
+// TestSinkSchemaVersion records the schema version of every table and reopens migrated tables without
+// migrating them again.
+func TestSinkSchemaVersion(t *testing.T) {
+	db, _ := openTestDB(t)
+	for _, table := range []string{"logs", "audit", "logs"} {
+		s, err := New(db, Options{Table: table, FlushInterval: time.Hour})
+		if err != nil {
+			t.Fatalf("table %s: %v", table, err)
+		}
+		s.Close()
+	}
+
+	rows, err := db.Query("SELECT table_name, version FROM mklog_schema ORDER BY table_name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	versions := map[string]int{}
+	for rows.Next() {
+		var table string
+		var version int
+		if err := rows.Scan(&table, &version); err != nil {
+			t.Fatal(err)
+		}
+		versions[table] = version
+	}
+	if len(versions) != 2 || versions["logs"] != len(migrations) || versions["audit"] != len(migrations) {
+		t.Errorf("schema versions = %v, want version %d of logs and audit", versions, len(migrations))
+	}
+
+	if _, err := New(db, Options{Table: "logs; DROP TABLE audit"}); err == nil {
+		t.Error("New accepted an invalid table name")
+	}
+}
+
+// TestSinkMaxRows keeps the newest rows after each flush.
+func TestSinkMaxRows(t *testing.T) {
+	db, _ := openTestDB(t)
+	s, err := New(db, Options{FlushInterval: time.Hour, MaxRows: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		s.Write(mklog.Entry{Time: time.Now(), Level: mklog.InfoLevel, Module: "app", Message: msg}, "")
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT message FROM logs ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg)
+	}
+	if len(got) != 3 || got[0] != "three" || got[2] != "five" {
+		t.Errorf("kept rows %v, want three, four and five", got)
+	}
+}
+
+// TestSinkQueueSize drops the entries beyond the buffer and counts them.
+func TestSinkQueueSize(t *testing.T) {
+	db, _ := openTestDB(t)
+	s, err := New(db, Options{FlushInterval: time.Hour, BatchSize: 100, QueueSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 5; i++ {
+		s.Write(mklog.Entry{Time: time.Now(), Module: "app", Message: "entry"}, "")
+	}
+	if got := s.Dropped(); got != 3 {
+		t.Errorf("dropped %d entries, want 3", got)
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/SHEP4RDO/mklog/sqlsink
+
+go 1.20
+
+require github.com/SHEP4RDO/mklog v0.1.0
+
+require github.com/mattn/go-sqlite3 v1.14.22
+
+require (
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// Builds inside the repository use the root module next to this one; dependents resolve the required release,
+// which must be tagged before this module is.
+replace github.com/SHEP4RDO/mklog => ../
@@ -0,0 +1,360 @@
+// Package sqlsink provides an mklog sink writing entries into a table of an existing *sql.DB,
+// such as a central Postgres or MySQL database.
+//
+// Entries are buffered and inserted in batches with multi-row INSERT statements. When the database
+// is unavailable the buffer is bounded: entries beyond its capacity are dropped and counted,
+// so logging never blocks the application.
+package sqlsink
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+var (
+	// Default settings of the SQL sink
+	FlushIntervalDefault = time.Second            // Default interval between batched inserts
+	BatchSizeDefault     = 100                    // Default number of rows in a single INSERT statement
+	QueueSizeDefault     = 10000                  // Default number of buffered entries before dropping
+	MaxRetriesDefault    = 3                      // Default number of retries of a failed batch
+	RetryBackoffDefault  = 200 * time.Millisecond // Default delay before the first retry, doubled on each retry
+)
+
+// identifierPattern restricts table and column names to plain identifiers.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Dialect describes the SQL differences between supported databases.
+type Dialect int
+
+const (
+	Postgres Dialect = iota // Postgres uses $N placeholders.
+	MySQL                   // MySQL uses ? placeholders.
+	SQLite                  // SQLite uses ? placeholders.
+)
+
+// Value selects the part of an entry stored in a column.
+type Value int
+
+const (
+	ValueTime       Value = iota // ValueTime stores the entry time.
+	ValueLevel                   // ValueLevel stores the numeric log level.
+	ValueLevelName               // ValueLevelName stores the display name of the log level.
+	ValueModule                  // ValueModule stores the module name.
+	ValueSubmodules              // ValueSubmodules stores the submodules as a JSON array.
+	ValueMessage                 // ValueMessage stores the log message.
+	ValueFormatted               // ValueFormatted stores the entry rendered by the rule's formatter.
+	ValueFields                  // ValueFields stores the structured fields as a JSON object.
+	ValueError                   // ValueError stores the error text.
+	ValueStack                   // ValueStack stores the stack trace of a DetailedError or the one carried by the error.
+)
+
+// Column maps a table column onto a part of the entry.
+type Column struct {
+	Name  string // Name of the column.
+	Value Value  // Part of the entry stored in the column.
+}
+
+// DefaultColumns is the column mapping used when Options.Columns is empty.
+var DefaultColumns = []Column{
+	{"ts", ValueTime},
+	{"level", ValueLevel},
+	{"module", ValueModule},
+	{"submodules", ValueSubmodules},
+	{"message", ValueMessage},
+	{"fields", ValueFields},
+	{"error", ValueError},
+	{"stack", ValueStack},
+}
+
+// Options configures the SQL sink.
+type Options struct {
+	Dialect       Dialect            // SQL dialect of the database.
+	Table         string             // Name of the log table.
+	Columns       []Column           // Mapping of table columns onto entry values.
+	BatchSize     int                // Maximum number of rows inserted by one statement.
+	FlushInterval time.Duration      // Interval between batched inserts.
+	QueueSize     int                // Maximum number of buffered entries, further entries are dropped.
+	MaxRetries    int                // Number of retries of a batch failing with a transient error.
+	RetryBackoff  time.Duration      // Delay before the first retry, doubled on each retry.
+	IsTransient   func(error) bool   // Classifies errors worth retrying, connection errors by default.
+	ErrorHandler  mklog.ErrorHandler // Handler for failed inserts, see mklog.ErrorHandler.Report.
+}
+
+// Sink writes log entries into a database table.
+type Sink struct {
+	db      *sql.DB
+	options Options
+	batch   *mklog.Batcher[bufferedEntry] // Buffer inserted in batches by a background goroutine
+}
+
+// bufferedEntry keeps the formatted text together with the entry.
+type bufferedEntry struct {
+	entry     mklog.Entry
+	formatted string
+}
+
+// New creates a sink writing to the table of the database.
+func New(db *sql.DB, options Options) (*Sink, error) {
+	if options.Table == "" {
+		return nil, fmt.Errorf("sql sink requires a table name")
+	}
+	if !identifierPattern.MatchString(options.Table) {
+		return nil, fmt.Errorf("invalid table name: %s", options.Table)
+	}
+	if len(options.Columns) == 0 {
+		options.Columns = DefaultColumns
+	}
+	for _, c := range options.Columns {
+		if !identifierPattern.MatchString(c.Name) {
+			return nil, fmt.Errorf("invalid column name: %s", c.Name)
+		}
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = BatchSizeDefault
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = FlushIntervalDefault
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = QueueSizeDefault
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	} else if options.MaxRetries == 0 {
+		options.MaxRetries = MaxRetriesDefault
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = RetryBackoffDefault
+	}
+	if options.IsTransient == nil {
+		options.IsTransient = IsTransient
+	}
+
+	s := &Sink{db: db, options: options}
+	s.batch = mklog.NewBatcher(mklog.BatcherOptions{
+		Name:          "sql sink",
+		FlushInterval: options.FlushInterval,
+		BatchSize:     options.BatchSize,
+		QueueSize:     options.QueueSize,
+		MaxRetries:    options.MaxRetries,
+		RetryBackoff:  options.RetryBackoff,
+		Retryable:     options.IsTransient,
+		ErrorHandler:  options.ErrorHandler,
+	}, s.insert)
+	return s, nil
+}
+
+// Write buffers the entry until the next flush, dropping it when the buffer is full.
+func (s *Sink) Write(entry mklog.Entry, formatted string) error {
+	s.batch.Add(bufferedEntry{entry, formatted})
+	return nil
+}
+
+// Dropped returns the number of entries dropped because the buffer was full.
+func (s *Sink) Dropped() uint64 {
+	return s.batch.Dropped()
+}
+
+// Flush inserts the buffered entries in batches of at most BatchSize rows, retrying transient failures.
+// Entries of a batch that could not be inserted stay buffered for the next flush.
+func (s *Sink) Flush() error {
+	return s.batch.Flush()
+}
+
+// Close flushes the remaining entries and stops the sink. The database itself is left open.
+func (s *Sink) Close() error {
+	return s.batch.Close()
+}
+
+// SetErrorHandler sets the handler receiving failed inserts.
+func (s *Sink) SetErrorHandler(handler mklog.ErrorHandler) {
+	s.batch.SetErrorHandler(handler)
+}
+
+// insert writes the batch with a single multi-row INSERT statement.
+func (s *Sink) insert(batch []bufferedEntry) error {
+	query := InsertSQL(s.options.Dialect, s.options.Table, s.options.Columns, len(batch))
+	args := make([]interface{}, 0, len(batch)*len(s.options.Columns))
+	for _, b := range batch {
+		for _, c := range s.options.Columns {
+			args = append(args, columnValue(c.Value, b.entry, b.formatted))
+		}
+	}
+
+	if _, err := s.db.ExecContext(context.Background(), query, args...); err != nil {
+		return fmt.Errorf("failed to insert %d entries: %w", len(batch), err)
+	}
+	return nil
+}
+
+// IsTransient reports whether the error is a connection failure worth retrying.
+func IsTransient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// InsertSQL builds a parameterized INSERT statement for the given number of rows.
+func InsertSQL(dialect Dialect, table string, columns []Column, rows int) string {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(table)
+	sb.WriteString(" (")
+	for i, c := range columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(c.Name)
+	}
+	sb.WriteString(") VALUES ")
+
+	n := 0
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for i := range columns {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			n++
+			if dialect == Postgres {
+				sb.WriteString("$" + strconv.Itoa(n))
+			} else {
+				sb.WriteString("?")
+			}
+		}
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
+
+// CreateTableSQL returns the DDL creating a log table with the given columns in the dialect.
+func CreateTableSQL(dialect Dialect, table string, columns []Column) string {
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	var sb strings.Builder
+	sb.WriteString("CREATE TABLE IF NOT EXISTS ")
+	sb.WriteString(table)
+	sb.WriteString(" (\n")
+	switch dialect {
+	case Postgres:
+		sb.WriteString("    id BIGSERIAL PRIMARY KEY")
+	case MySQL:
+		sb.WriteString("    id BIGINT AUTO_INCREMENT PRIMARY KEY")
+	default:
+		sb.WriteString("    id INTEGER PRIMARY KEY AUTOINCREMENT")
+	}
+	for _, c := range columns {
+		sb.WriteString(",\n    ")
+		sb.WriteString(c.Name)
+		sb.WriteString(" ")
+		sb.WriteString(columnType(dialect, c.Value))
+	}
+	sb.WriteString("\n)")
+	return sb.String()
+}
+
+// CreateIndexSQL returns the DDL creating an index on a column of the log table.
+func CreateIndexSQL(table, column string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_%s_idx ON %s (%s)", table, column, table, column)
+}
+
+// columnType returns the SQL type storing the entry value in the dialect.
+func columnType(dialect Dialect, value Value) string {
+	switch value {
+	case ValueTime:
+		switch dialect {
+		case Postgres:
+			return "TIMESTAMPTZ NOT NULL"
+		case MySQL:
+			return "DATETIME(6) NOT NULL"
+		default:
+			return "TIMESTAMP NOT NULL"
+		}
+	case ValueLevel:
+		return "INTEGER NOT NULL"
+	case ValueLevelName, ValueModule:
+		if dialect == MySQL {
+			return "VARCHAR(255) NOT NULL"
+		}
+		return "TEXT NOT NULL"
+	case ValueFields, ValueSubmodules:
+		switch dialect {
+		case Postgres:
+			return "JSONB"
+		case MySQL:
+			return "JSON"
+		default:
+			return "TEXT"
+		}
+	case ValueMessage, ValueFormatted:
+		return "TEXT NOT NULL"
+	default:
+		return "TEXT"
+	}
+}
+
+// columnValue extracts the value stored in a column from the entry.
+func columnValue(value Value, e mklog.Entry, formatted string) interface{} {
+	switch value {
+	case ValueTime:
+		return e.Time
+	case ValueLevel:
+		return int(e.Level)
+	case ValueLevelName:
+		return e.LevelName
+	case ValueModule:
+		return e.Module
+	case ValueSubmodules:
+		if len(e.Submodules) == 0 {
+			return nil
+		}
+		data, _ := json.Marshal(e.Submodules)
+		return string(data)
+	case ValueMessage:
+		return e.Message
+	case ValueFormatted:
+		return formatted
+	case ValueFields:
+		if len(e.Fields) == 0 {
+			return nil
+		}
+		data, err := json.Marshal(e.Fields)
+		if err != nil {
+			return nil
+		}
+		return string(data)
+	case ValueError:
+		if e.Err == nil {
+			return nil
+		}
+		return e.Err.Error()
+	case ValueStack:
+		if detailed, ok := e.Err.(mklog.DetailedError); ok {
+			return detailed.StackInfo
+		}
+		if stack := mklog.OriginStack(e.Err); stack != "" {
+			return stack
+		}
+		return nil
+	default:
+		return nil
+	}
+}
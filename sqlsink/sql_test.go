@@ -0,0 +1,201 @@
+//go:build cgo
+
+package sqlsink
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openMemoryDB opens an in-memory SQLite database holding a log table with the columns, indexed on the first.
+func openMemoryDB(t *testing.T, columns []Column) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // Every connection opens its own in-memory database.
+	t.Cleanup(func() { db.Close() })
+
+	indexed := DefaultColumns[0].Name
+	if len(columns) > 0 {
+		indexed = columns[0].Name
+	}
+	for _, stmt := range []string{CreateTableSQL(SQLite, "logs", columns), CreateIndexSQL("logs", indexed)} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+// queryStrings returns the first column of the rows of the query.
+func queryStrings(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var s sql.NullString
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s.String)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// TestSinkInsertAndQuery inserts the entries in batches of BatchSize rows and reads back the default columns.
+func TestSinkInsertAndQuery(t *testing.T) {
+	db := openMemoryDB(t, nil)
+	s, err := New(db, Options{Dialect: SQLite, Table: "logs", BatchSize: 2, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 3, 9, 10, 20, 30, 0, time.UTC)
+	for i, msg := range []string{"one", "two", "three", "four", "five"} {
+		s.Write(mklog.Entry{Time: at.Add(time.Duration(i) * time.Second), Level: mklog.InfoLevel, Module: "app", Message: msg}, "")
+	}
+	s.Write(mklog.Entry{
+		Time:       at,
+		Level:      mklog.ErrorLevel,
+		Module:     "db",
+		Submodules: []string{"pool"},
+		Message:    "query failed",
+		Fields:     map[string]interface{}{"table": "users"},
+		Err:        errors.New("timeout"),
+	}, "")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := queryStrings(t, db, "SELECT message FROM logs ORDER BY id")
+	if len(messages) != 6 || messages[0] != "one" || messages[4] != "five" {
+		t.Errorf("messages = %v, want the entries in order", messages)
+	}
+	var level int
+	var submodules, fields, errText string
+	if err := db.QueryRow("SELECT level, submodules, fields, error FROM logs WHERE module = 'db'").
+		Scan(&level, &submodules, &fields, &errText); err != nil {
+		t.Fatal(err)
+	}
+	if level != int(mklog.ErrorLevel) || submodules != `["pool"]` || fields != `{"table":"users"}` || errText != "timeout" {
+		t.Errorf("row = %d %s %s %s", level, submodules, fields, errText)
+	}
+	if nulls := queryStrings(t, db, "SELECT error FROM logs WHERE module = 'app' AND error IS NOT NULL"); len(nulls) != 0 {
+		t.Errorf("entries without error stored %v", nulls)
+	}
+}
+
+// TestSinkColumnMapping stores the parts of the entry selected by the columns.
+func TestSinkColumnMapping(t *testing.T) {
+	columns := []Column{{"at", ValueTime}, {"severity", ValueLevelName}, {"line", ValueFormatted}}
+	db := openMemoryDB(t, columns)
+	s, err := New(db, Options{Dialect: SQLite, Table: "logs", Columns: columns, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write(mklog.Entry{Time: time.Now(), LevelName: "WARNING", Message: "disk"}, "WARNING | disk")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := queryStrings(t, db, "SELECT severity || ' ' || line FROM logs"); len(got) != 1 || got[0] != "WARNING WARNING | disk" {
+		t.Errorf("rows = %v", got)
+	}
+	if _, err := New(db, Options{Table: "logs", Columns: []Column{{"line; DROP TABLE logs", ValueMessage}}}); err == nil {
+		t.Error("New accepted an invalid column name")
+	}
+}
+
+// TestSinkRetriesTransientErrors retries a failing batch MaxRetries times and keeps it for the next flush.
+func TestSinkRetriesTransientErrors(t *testing.T) {
+	db := openMemoryDB(t, nil)
+	attempts := 0
+	s, err := New(db, Options{
+		Dialect:       SQLite,
+		Table:         "missing",
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		IsTransient:   func(error) bool { attempts++; return true },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Write(mklog.Entry{Time: time.Now(), Module: "app", Message: "kept"}, "")
+	if err := s.Flush(); err == nil {
+		t.Fatal("Flush into a missing table succeeded")
+	}
+	if attempts != 3 {
+		t.Errorf("inserted %d times, want 3", attempts)
+	}
+
+	if _, err := db.Exec(CreateTableSQL(SQLite, "missing", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryStrings(t, db, "SELECT message FROM missing"); len(got) != 1 || got[0] != "kept" {
+		t.Errorf("rows after recovery = %v, want the kept entry", got)
+	}
+}
+
+// TestSinkDropsWhileDatabaseDown bounds the buffer while inserts fail, dropping and counting the oldest entries.
+func TestSinkDropsWhileDatabaseDown(t *testing.T) {
+	db := openMemoryDB(t, nil)
+	s, err := New(db, Options{Dialect: SQLite, Table: "logs", FlushInterval: time.Hour, BatchSize: 10, QueueSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	db.Close()
+
+	start := time.Now()
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		s.Write(mklog.Entry{Time: time.Now(), Module: "app", Message: msg}, "")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Write blocked for %v", elapsed)
+	}
+	if got := s.Dropped(); got != 2 {
+		t.Errorf("dropped %d entries, want 2", got)
+	}
+
+	if err := s.Flush(); err == nil {
+		t.Fatal("Flush into a closed database succeeded")
+	}
+	s.Write(mklog.Entry{Time: time.Now(), Module: "app", Message: "six"}, "")
+	if err := s.Flush(); err == nil {
+		t.Fatal("Flush into a closed database succeeded")
+	}
+	if buffered := s.batch.Len(); buffered != 3 || s.Dropped() != 3 {
+		t.Errorf("buffered %d and dropped %d entries, want 3 and 3", buffered, s.Dropped())
+	}
+}
+
+// TestInsertSQL numbers the placeholders of Postgres and uses ? for the other dialects.
+func TestInsertSQL(t *testing.T) {
+	columns := []Column{{"ts", ValueTime}, {"message", ValueMessage}}
+	if got, want := InsertSQL(Postgres, "logs", columns, 2), "INSERT INTO logs (ts, message) VALUES ($1, $2), ($3, $4)"; got != want {
+		t.Errorf("Postgres: %s, want %s", got, want)
+	}
+	if got, want := InsertSQL(MySQL, "logs", columns, 2), "INSERT INTO logs (ts, message) VALUES (?, ?), (?, ?)"; got != want {
+		t.Errorf("MySQL: %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,23 @@
+package mklog
+
+// Stats is a snapshot of the runtime state of a Debugger instance.
+type Stats struct {
+	Maintenance []MaintenanceTaskStats `json:"maintenance"` // State of the maintenance tasks, ordered by name
+	Async       []AsyncStats           `json:"async"`       // State of the async queues, ordered by module and index
+	Writes      []WriteStats           `json:"writes"`      // Write latency of the log files and sinks, ordered by module and index
+	Rules       []RuleStats            `json:"rules"`       // Traffic of the rules, ordered by module and index
+
+	SuppressedErrors uint64 `json:"suppressed_errors"` // Internal errors held back by coalescing, see SetErrorCoalescing
+}
+
+// Stats returns a snapshot of the runtime state of the Debugger instance.
+func (d *Debugger) Stats() Stats {
+	return Stats{
+		Maintenance: d.maintenanceStats(),
+		Async:       d.asyncStats(),
+		Writes:      d.writeStats(),
+		Rules:       d.ruleStats(),
+
+		SuppressedErrors: d.SuppressedErrors(),
+	}
+}
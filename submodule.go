@@ -0,0 +1,50 @@
+package mklog
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// Separator of the submodule chain in the text, logfmt, CSV and XML formatters, e.g. "db.pool.conn"
+	MKLOG_SubmoduleSeparatorDefault = "."
+)
+
+// submoduleSeparatorOption returns the submodule_separator option of a formatter block.
+func submoduleSeparatorOption(options map[string]interface{}) (string, error) {
+	v, ok := options["submodule_separator"]
+	if !ok || v == nil {
+		return "", nil
+	}
+	sep, ok := v.(string)
+	if !ok || sep == "" {
+		return "", fmt.Errorf("option submodule_separator must be a non-empty string, got %v", v)
+	}
+	return sep, nil
+}
+
+// joinSubmodules joins the submodule chain with the separator, MKLOG_SubmoduleSeparatorDefault when empty.
+func joinSubmodules(submodules []string, sep string) string {
+	if sep == "" {
+		sep = MKLOG_SubmoduleSeparatorDefault
+	}
+	return strings.Join(submodules, sep)
+}
+
+// submodulesValue returns the submodules of structured formatters: the chain as an array, or joined with the
+// separator when one is set.
+func submodulesValue(submodules []string, sep string) interface{} {
+	if sep == "" {
+		return submodules
+	}
+	return strings.Join(submodules, sep)
+}
+
+// splitSubmodules splits a submodule chain read from a log file on MKLOG_SubmoduleSeparatorDefault, or on the
+// legacy separator the formatter used before, so older files stay readable.
+func splitSubmodules(chain string, legacy string) []string {
+	if strings.Contains(chain, legacy) {
+		return strings.Split(chain, legacy)
+	}
+	return strings.Split(chain, MKLOG_SubmoduleSeparatorDefault)
+}
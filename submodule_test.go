@@ -0,0 +1,138 @@
+package mklog
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSubmoduleChains renders empty, single and deep submodule chains with the default and a custom separator
+// in each formatter.
+func TestSubmoduleChains(t *testing.T) {
+	chains := []struct {
+		submodules []string
+		joined     string
+	}{
+		{nil, ""},
+		{[]string{"pool"}, "pool"},
+		{[]string{"db", "pool", "conn", "tx"}, "db.pool.conn.tx"},
+	}
+	for _, c := range chains {
+		for _, sep := range []string{"", "/"} {
+			joined := c.joined
+			if sep != "" {
+				joined = strings.ReplaceAll(joined, ".", sep)
+			}
+			plain := PlainTextFormatter{SubmoduleSeparator: sep}.Format("msg", "INFO", "app", c.submodules, "ts")
+			fast := FastTextFormatter{SubmoduleSeparator: sep}.Format("msg", "INFO", "app", c.submodules, "ts")
+			logfmt := LogfmtFormatter{SubmoduleSeparator: sep}.Format("msg", "INFO", "app", c.submodules, "ts")
+			csv := CSVFormatter{SubmoduleSeparator: sep}.Format("msg", "INFO", "app", c.submodules, "ts")
+			if len(c.submodules) == 0 {
+				if plain != "ts | INFO | [app] : msg" || fast != plain || strings.Contains(logfmt, "submodules=") || csv != "ts,INFO,app,,msg," {
+					t.Errorf("empty chain: plain %q, fast %q, logfmt %q, csv %q", plain, fast, logfmt, csv)
+				}
+				continue
+			}
+			if want := "ts | INFO | [app] - [" + joined + "]: msg"; plain != want || fast != want {
+				t.Errorf("separator %q: plain %q, fast %q, want %q", sep, plain, fast, want)
+			}
+			if !strings.Contains(logfmt, " submodules="+joined+" ") {
+				t.Errorf("separator %q: logfmt %q, want submodules=%s", sep, logfmt, joined)
+			}
+			if !strings.HasPrefix(csv, "ts,INFO,app,"+joined+",msg,") {
+				t.Errorf("separator %q: csv %q, want the chain %s", sep, csv, joined)
+			}
+			if xml := (XMLFormatter{Compact: true, SubmoduleSeparator: sep}).Format("msg", "INFO", "app", c.submodules, "ts"); !strings.Contains(xml, ">"+joined+"<") {
+				t.Errorf("separator %q: xml %q, want the chain %s", sep, xml, joined)
+			}
+		}
+	}
+}
+
+// TestSubmoduleChainsJSON renders the chain as an array by default and as one string in joined mode, and reads
+// both back.
+func TestSubmoduleChainsJSON(t *testing.T) {
+	deep := []string{"db", "pool", "conn"}
+	tests := []struct {
+		sep, want string
+	}{
+		{"", `"submodules":["db","pool","conn"]`},
+		{".", `"submodules":"db.pool.conn"`},
+	}
+	for _, tt := range tests {
+		line := JSONFormatter{SubmoduleSeparator: tt.sep}.Format("msg", "INFO", "app", deep, "ts")
+		if !strings.Contains(line, tt.want) {
+			t.Errorf("separator %q: %s, want %s", tt.sep, line, tt.want)
+		}
+		e, err := parseJSONLine(line, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.Submodules, deep) {
+			t.Errorf("separator %q: read submodules %q, want %q", tt.sep, e.Submodules, deep)
+		}
+	}
+	if line := (JSONFormatter{}).Format("msg", "INFO", "app", nil, "ts"); strings.Contains(line, "submodules") {
+		t.Errorf("empty chain rendered: %s", line)
+	}
+	if _, err := parseJSONLine(`{"logLevel":"INFO","submodules":42}`, ""); err == nil {
+		t.Error("numeric submodules read without error")
+	}
+}
+
+// TestSubmoduleChainsRead splits the chains of plain text and logfmt lines on the default separator and on the
+// separators of older versions.
+func TestSubmoduleChainsRead(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"ts | INFO | [app] - [pool]: msg", []string{"pool"}},
+		{"ts | INFO | [app] - [db.pool.conn]: msg", []string{"db", "pool", "conn"}},
+		{"ts | INFO | [app] - [db pool conn]: msg", []string{"db", "pool", "conn"}},
+	}
+	for _, tt := range tests {
+		e, err := parsePlainTextLine(tt.line, "")
+		if err != nil || !reflect.DeepEqual(e.Submodules, tt.want) || e.Message != "msg" {
+			t.Errorf("%q: submodules %q, message %q, err %v, want %q", tt.line, e.Submodules, e.Message, err, tt.want)
+		}
+	}
+	for _, line := range []string{"level=INFO module=app submodules=db.pool msg=x", "level=INFO module=app submodules=db,pool msg=x"} {
+		e, err := parseLogfmtLine(line, "")
+		if err != nil || !reflect.DeepEqual(e.Submodules, []string{"db", "pool"}) {
+			t.Errorf("%q: submodules %q, err %v", line, e.Submodules, err)
+		}
+	}
+}
+
+// TestSubmoduleSeparatorConfig sets the separator from the formatter options of the configuration and rejects
+// empty and non-string separators.
+func TestSubmoduleSeparatorConfig(t *testing.T) {
+	config := func(formatter string) string {
+		return writeConfig(t, "config.yaml", `
+log_rules:
+  app:
+    - min_level: INFO
+      max_level: FATAL
+      log_formatter: `+formatter+`
+      file_log: {enable: true, file_path: `+filepath.ToSlash(t.TempDir())+`, file_name: app, file_type: .log}
+`)
+	}
+	d, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config(`{type: plain, submodule_separator: " > "}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close(context.Background())
+	if f, ok := d.rules()["app"][0].LogFormatter.(PlainTextFormatter); !ok || f.SubmoduleSeparator != " > " {
+		t.Errorf("formatter = %#v, want plain text with the separator", d.rules()["app"][0].LogFormatter)
+	}
+
+	for _, formatter := range []string{`{type: json, submodule_separator: ""}`, `{type: logfmt, submodule_separator: 3}`} {
+		if _, err := NewLogConfigManager().SetQuiet(true).LoadConfig(config(formatter)); err == nil ||
+			!strings.Contains(err.Error(), "option submodule_separator must be a non-empty string") {
+			t.Errorf("%s: LoadConfig error = %v, want the separator rejected", formatter, err)
+		}
+	}
+}
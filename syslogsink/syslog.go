@@ -0,0 +1,250 @@
+// Package syslogsink provides an mklog sink sending entries to a syslog daemon in the BSD syslog
+// format (RFC 3164), so rsyslog or syslog-ng can route them by facility and severity.
+//
+// Each sink has its own facility, so different rules of a multi-tenant host can log to local0–local7.
+// The severity of a message is derived from the entry level with LogLevel.SyslogSeverity.
+package syslogsink
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+var (
+	// Default settings of the syslog sink
+	NetworkDefault     = "udp"           // Default network of the syslog daemon
+	AddressDefault     = "127.0.0.1:514" // Default address of the syslog daemon
+	FacilityDefault    = User            // Default facility of the messages
+	DialTimeoutDefault = 5 * time.Second // Default timeout of connecting to the daemon
+	TimestampFormat    = time.Stamp      // Layout of the RFC 3164 timestamp
+)
+
+// Facility is a syslog facility as defined by RFC 3164.
+type Facility int
+
+const (
+	Kern     Facility = iota // Kernel messages
+	User                     // User-level messages
+	Mail                     // Mail system
+	Daemon                   // System daemons
+	Auth                     // Security/authorization messages
+	Syslog                   // Messages generated internally by syslogd
+	Lpr                      // Line printer subsystem
+	News                     // Network news subsystem
+	Uucp                     // UUCP subsystem
+	Cron                     // Clock daemon
+	Authpriv                 // Security/authorization messages (private)
+	Ftp                      // FTP daemon
+	_                        // NTP subsystem
+	_                        // Log audit
+	_                        // Log alert
+	_                        // Clock daemon
+	Local0                   // Local use 0
+	Local1                   // Local use 1
+	Local2                   // Local use 2
+	Local3                   // Local use 3
+	Local4                   // Local use 4
+	Local5                   // Local use 5
+	Local6                   // Local use 6
+	Local7                   // Local use 7
+)
+
+// facilityNames maps the names accepted by ParseFacility onto the facilities.
+var facilityNames = map[string]Facility{
+	"kern":     Kern,
+	"user":     User,
+	"mail":     Mail,
+	"daemon":   Daemon,
+	"auth":     Auth,
+	"syslog":   Syslog,
+	"lpr":      Lpr,
+	"news":     News,
+	"uucp":     Uucp,
+	"cron":     Cron,
+	"authpriv": Authpriv,
+	"ftp":      Ftp,
+	"local0":   Local0,
+	"local1":   Local1,
+	"local2":   Local2,
+	"local3":   Local3,
+	"local4":   Local4,
+	"local5":   Local5,
+	"local6":   Local6,
+	"local7":   Local7,
+}
+
+// ParseFacility returns the facility with the name, e.g. "local3", ignoring case.
+func ParseFacility(name string) (Facility, error) {
+	f, ok := facilityNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility: %s", name)
+	}
+	return f, nil
+}
+
+// String returns the name of the facility.
+func (f Facility) String() string {
+	for name, v := range facilityNames {
+		if v == f {
+			return name
+		}
+	}
+	return fmt.Sprintf("facility(%d)", int(f))
+}
+
+// Priority returns the PRI value of a message of the facility at the log level: facility * 8 + severity.
+func Priority(facility Facility, level mklog.LogLevel) int {
+	return int(facility)*8 + level.SyslogSeverity()
+}
+
+// Options configures the syslog sink.
+type Options struct {
+	Network      string             // Network of the daemon: "udp", "tcp", "unix" or "unixgram".
+	Address      string             // Address of the daemon, e.g. "127.0.0.1:514" or "/dev/log".
+	Facility     Facility           // Facility of the messages.
+	Tag          string             // Tag of the messages, the program name by default.
+	Hostname     string             // Host name in the messages, os.Hostname by default.
+	DialTimeout  time.Duration      // Timeout of connecting to the daemon.
+	ErrorHandler mklog.ErrorHandler // Handler for failed sends, see mklog.ErrorHandler.Report.
+}
+
+// Sink sends log entries to a syslog daemon.
+type Sink struct {
+	options Options
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// New creates a sink sending to the syslog daemon at the address of the options.
+// The connection is established lazily and re-established after a failed send.
+func New(options Options) (*Sink, error) {
+	if options.Network == "" {
+		options.Network = NetworkDefault
+	}
+	if options.Address == "" {
+		options.Address = AddressDefault
+	}
+	if options.Facility < Kern || options.Facility > Local7 {
+		return nil, fmt.Errorf("invalid syslog facility: %d", options.Facility)
+	}
+	if options.Tag == "" {
+		options.Tag = filepath.Base(os.Args[0])
+	}
+	if options.Hostname == "" {
+		options.Hostname, _ = os.Hostname()
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = DialTimeoutDefault
+	}
+	return &Sink{options: options}, nil
+}
+
+// Factory creates a sink from config options, to be registered with LogConfigManager.RegisterSink.
+// Supported options are network, address, facility (a name like "local3"), tag and hostname.
+func Factory(options map[string]interface{}) (mklog.Sink, error) {
+	network, _ := options["network"].(string)
+	address, _ := options["address"].(string)
+	tag, _ := options["tag"].(string)
+	hostname, _ := options["hostname"].(string)
+
+	facility := FacilityDefault
+	if name, ok := options["facility"].(string); ok && name != "" {
+		f, err := ParseFacility(name)
+		if err != nil {
+			return nil, err
+		}
+		facility = f
+	} else if v, ok := options["facility"]; ok && v != nil {
+		return nil, fmt.Errorf("invalid facility: %v", v)
+	}
+
+	return New(Options{
+		Network:  network,
+		Address:  address,
+		Facility: facility,
+		Tag:      tag,
+		Hostname: hostname,
+	})
+}
+
+// Write sends the message of the entry with the priority of the sink facility and the entry level.
+// Failed sends are passed to the error handler, so logging never fails because of the daemon.
+func (s *Sink) Write(entry mklog.Entry, formatted string) error {
+	if err := s.send(s.format(entry)); err != nil {
+		s.reportError(err)
+	}
+	return nil
+}
+
+// send writes the message to the daemon, retrying once on a fresh connection, e.g. after the daemon restarted.
+func (s *Sink) send(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = net.DialTimeout(s.options.Network, s.options.Address, s.options.DialTimeout); err != nil {
+				s.conn = nil
+				continue
+			}
+		}
+		if _, err = s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// format renders the entry as an RFC 3164 message; stream connections are newline framed.
+func (s *Sink) format(entry mklog.Entry) []byte {
+	t := entry.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	msg := fmt.Sprintf("<%d>%s %s %s: %s", Priority(s.options.Facility, entry.Level),
+		t.Format(TimestampFormat), s.options.Hostname, s.options.Tag, entry.Message)
+	if s.options.Network == "tcp" || s.options.Network == "unix" {
+		msg += "\n"
+	}
+	return []byte(msg)
+}
+
+// Close closes the connection to the daemon.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// SetErrorHandler sets the handler receiving failed sends.
+func (s *Sink) SetErrorHandler(handler mklog.ErrorHandler) {
+	s.mu.Lock()
+	s.options.ErrorHandler = handler
+	s.mu.Unlock()
+}
+
+// reportError passes the error to the error handler, see mklog.ErrorHandler.Report.
+func (s *Sink) reportError(err error) {
+	s.mu.Lock()
+	handler := s.options.ErrorHandler
+	s.mu.Unlock()
+
+	handler.Report(fmt.Errorf("[mklog] syslog sink: %w", err))
+}
@@ -0,0 +1,69 @@
+package syslogsink
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+// TestWriteUDPPriority sends an entry of each level to a UDP listener and checks the PRI of every datagram:
+// Local3 (19) * 8 plus the syslog severity of the level.
+func TestWriteUDPPriority(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := New(Options{Network: "udp", Address: conn.LocalAddr().String(), Facility: Local3,
+		Tag: "billing", Hostname: "host1", ErrorHandler: func(err error) { t.Error(err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	tests := []struct {
+		level mklog.LogLevel
+		pri   string
+	}{
+		{mklog.TraceLevel, "<159>"},
+		{mklog.DebugLevel, "<159>"},
+		{mklog.InfoLevel, "<158>"},
+		{mklog.WarningLevel, "<156>"},
+		{mklog.ErrorLevel, "<155>"},
+		{mklog.FatalLevel, "<154>"},
+		{mklog.LogLevel(99), "<157>"}, // Unknown levels are sent as Notice.
+	}
+	at := time.Date(2024, time.March, 5, 14, 7, 9, 0, time.Local)
+	buf := make([]byte, 1024)
+	for _, tt := range tests {
+		sink.Write(mklog.Entry{Time: at, Level: tt.level, Message: "payment failed"}, "")
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("level %v: %v", tt.level, err)
+		}
+		got := string(buf[:n])
+		want := tt.pri + "Mar  5 14:07:09 host1 billing: payment failed"
+		if got != want {
+			t.Errorf("level %v: datagram = %q, want %q", tt.level, got, want)
+		}
+		if strings.HasSuffix(got, "\n") {
+			t.Errorf("level %v: datagram ends with a newline, want no framing over UDP", tt.level)
+		}
+	}
+}
+
+// TestPriority computes facility * 8 plus the severity of the level.
+func TestPriority(t *testing.T) {
+	if got := Priority(Kern, mklog.FatalLevel); got != 2 {
+		t.Errorf("Priority(Kern, Fatal) = %d, want 2", got)
+	}
+	if got := Priority(Local7, mklog.DebugLevel); got != 191 {
+		t.Errorf("Priority(Local7, Debug) = %d, want 191", got)
+	}
+}
@@ -0,0 +1,75 @@
+// Package acmelog registers a formatter and a sink from its init function, like the packages of private
+// formatters and sinks applications import for their side effects, for the tests of the registries.
+package acmelog
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/SHEP4RDO/mklog"
+)
+
+func init() {
+	if err := mklog.RegisterFormatter("acme", newFormatter, "tenant"); err != nil {
+		panic(err)
+	}
+	if err := mklog.RegisterSink("acme-bus", newSink); err != nil {
+		panic(err)
+	}
+}
+
+// Formatter renders entries as "tenant/LEVEL/module: message".
+type Formatter struct {
+	Tenant string
+}
+
+// Format formats the log message with the tenant.
+func (f Formatter) Format(logMessage string, logLevel string, moduleName string, submodules []string, timestamp string) string {
+	return fmt.Sprintf("%s/%s/%s: %s", f.Tenant, logLevel, moduleName, logMessage)
+}
+
+// newFormatter creates the formatter from the tenant option.
+func newFormatter(_ string, options map[string]interface{}) (mklog.LogFormatter, error) {
+	tenant, _ := options["tenant"].(string)
+	if tenant == "" {
+		return nil, fmt.Errorf("option tenant is required")
+	}
+	return Formatter{Tenant: tenant}, nil
+}
+
+// Bus is a sink keeping the formatted entries published to a topic.
+type Bus struct {
+	Topic string
+
+	mu      sync.Mutex
+	entries []string
+}
+
+// Write keeps the formatted entry.
+func (b *Bus) Write(entry mklog.Entry, formatted string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, formatted)
+	return nil
+}
+
+// Close does nothing.
+func (b *Bus) Close() error { return nil }
+
+// Entries returns the entries written so far.
+func (b *Bus) Entries() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.entries...)
+}
+
+// Buses holds the sinks created from configuration files by topic.
+var Buses sync.Map
+
+// newSink creates a sink for the topic option and records it in Buses.
+func newSink(options map[string]interface{}) (mklog.Sink, error) {
+	topic, _ := options["topic"].(string)
+	b := &Bus{Topic: topic}
+	Buses.Store(topic, b)
+	return b, nil
+}
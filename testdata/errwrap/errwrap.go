@@ -0,0 +1,16 @@
+// Package errwrap is an error helper package wrapping mklog.NewDetailedError, like the error helpers of
+// applications, and creating errors carrying their stack like github.com/pkg/errors, for the tests of the
+// caller detection.
+package errwrap
+
+import "github.com/SHEP4RDO/mklog"
+
+// Wrap creates a DetailedError one layer below its caller.
+func Wrap(err error) mklog.DetailedError {
+	return mklog.NewDetailedError(err)
+}
+
+// WrapTwice creates a DetailedError two layers below its caller.
+func WrapTwice(err error) mklog.DetailedError {
+	return Wrap(err)
+}
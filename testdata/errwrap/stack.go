@@ -0,0 +1,54 @@
+package errwrap
+
+import "runtime"
+
+// Frame is a program counter of a stack frame, like errors.Frame of github.com/pkg/errors.
+type Frame uintptr
+
+// StackTrace is the stack of frames an error was created with, like errors.StackTrace of github.com/pkg/errors.
+type StackTrace []Frame
+
+// stackError carries a message, the error it wraps and, unless nil, the stack it was created with.
+type stackError struct {
+	msg   string
+	err   error
+	stack StackTrace
+}
+
+func (e *stackError) Error() string {
+	if e.err == nil {
+		return e.msg
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *stackError) Unwrap() error { return e.err }
+
+// StackTrace returns the stack the error was created with.
+func (e *stackError) StackTrace() StackTrace { return e.stack }
+
+// New returns an error carrying the stack of its caller, like errors.New of github.com/pkg/errors.
+func New(msg string) error {
+	return &stackError{msg: msg, stack: callers()}
+}
+
+// WithStack annotates err with a message and the stack of its caller, like errors.Wrap of github.com/pkg/errors.
+func WithStack(err error, msg string) error {
+	return &stackError{msg: msg, err: err, stack: callers()}
+}
+
+// WithMessage annotates err with a message only, like errors.WithMessage of github.com/pkg/errors.
+func WithMessage(err error, msg string) error {
+	return &stackError{msg: msg, err: err}
+}
+
+// callers returns the stack starting at the caller of the function calling it.
+func callers() StackTrace {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	stack := make(StackTrace, n)
+	for i, pc := range pcs[:n] {
+		stack[i] = Frame(pc)
+	}
+	return stack
+}
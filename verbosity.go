@@ -0,0 +1,124 @@
+package mklog
+
+import "sync/atomic"
+
+// debugModeVerbosity returns the Verbosity matching the deprecated debug mode fields of a rule.
+func debugModeVerbosity(debugMode bool, debugModeStatus LogLevel) LogLevel {
+	switch {
+	case !debugMode:
+		return InfoLevel
+	case debugModeStatus == TraceLevel:
+		return TraceLevel
+	default:
+		return DebugLevel
+	}
+}
+
+// WithVerbosity sets the lowest level the rule logs in addition to its MinLevel, e.g. TraceLevel to log
+// everything the MinLevel allows or InfoLevel to leave out Trace and Debug entries.
+//
+// The Verbosity replaces the debug mode of a rule: an entry is logged by the rule if its level is within the
+// MinLevel and MaxLevel of the rule and at or above its Verbosity, whichever method logged it. The deprecated
+// DebugMode and DebugModeStatus fields are mapped onto the Verbosity by WithDebugMode, SetDebugMode,
+// SetDebugLevel, AddRule and the is_debug_mod and debug_mode_status configuration keys:
+//
+//	DebugMode  DebugModeStatus  Verbosity   Trace  Debug  Info and above
+//	false      any              InfoLevel   no     no     yes
+//	true       TraceLevel       TraceLevel  yes    yes    yes
+//	true       other            DebugLevel  no     yes    yes
+//
+// This keeps the behavior of Trace, Debug and the level methods above them. The methods taking a level now
+// apply the same condition, so they no longer log entries below the Verbosity: Custom leaves out Trace and Debug
+// entries without debug mode and Trace entries unless DebugModeStatus is TraceLevel, and CustomDebug leaves out
+// Trace entries unless DebugModeStatus is TraceLevel. CustomDebug and CustomTrace in turn log the entries reaching
+// the Verbosity without debug mode, and CustomTrace also those of rules in debug mode whose DebugModeStatus is not
+// TraceLevel. TestVerbosityTruthTable checks every combination against the former behavior.
+func WithVerbosity(level LogLevel) Option {
+	return func(lr *LogRule) {
+		lr.SetVerbosity(level)
+	}
+}
+
+// SetVerbosity sets the lowest level the rule logs in addition to its MinLevel, see WithVerbosity.
+func (lr *LogRule) SetVerbosity(level LogLevel) *LogRule {
+	lr.Verbosity = level
+	lr.verbositySet = true
+	return lr
+}
+
+// syncVerbosity sets the Verbosity from the deprecated debug mode fields.
+func (lr *LogRule) syncVerbosity() {
+	lr.Verbosity = debugModeVerbosity(lr.DebugMode, lr.DebugModeStatus)
+	lr.verbositySet = true
+}
+
+// BumpVerbosity shifts the MinLevel and the Verbosity of all rules of the Debugger by delta steps at once,
+// a positive delta logging more, e.g. 1 to log Debug entries where Info entries were logged, and a negative
+// delta logging less. The shifted levels are clamped to TraceLevel..FatalLevel and the rules keep their
+// configured levels, so bumping back restores them. It logs an informational entry stating the lowest level
+// now logged and returns the total shift. SIGUSR1 and SIGUSR2 bump the verbosity by one step, see
+// HandleVerbositySignals; the admin handler does so on platforms without them.
+func (d *Debugger) BumpVerbosity(delta int) int {
+	span := int32(FatalLevel - TraceLevel)
+	var shift int32
+	for {
+		old := atomic.LoadInt32(&d.verbosityShift)
+		shift = old + int32(delta)
+		if shift > span {
+			shift = span
+		} else if shift < -span {
+			shift = -span
+		}
+		if atomic.CompareAndSwapInt32(&d.verbosityShift, old, shift) {
+			break
+		}
+	}
+
+	lowest := FatalLevel
+	for _, rules := range d.rules() {
+		for _, v := range rules {
+			if level := maxLevel(v.levelBounds()); level < lowest {
+				lowest = level
+			}
+		}
+	}
+	d.log(nil, InfoLevel, nil, "[mklog] verbosity shifted by %+d, logging from %s", shift, lowest.GetLogLevelName())
+	return int(shift)
+}
+
+// VerbosityShift returns the total shift of the levels set with BumpVerbosity.
+func (d *Debugger) VerbosityShift() int {
+	return int(atomic.LoadInt32(&d.verbosityShift))
+}
+
+// levelBounds returns the MinLevel and the Verbosity of the rule shifted by BumpVerbosity.
+func (lr *LogRule) levelBounds() (minLevel, verbosity LogLevel) {
+	if lr.debugger == nil {
+		return lr.MinLevel, lr.Verbosity
+	}
+	shift := atomic.LoadInt32(&lr.debugger.verbosityShift)
+	if shift == 0 {
+		return lr.MinLevel, lr.Verbosity
+	}
+	return shiftLevel(lr.MinLevel, shift), shiftLevel(lr.Verbosity, shift)
+}
+
+// shiftLevel lowers the level by shift steps, clamped to TraceLevel..FatalLevel.
+func shiftLevel(level LogLevel, shift int32) LogLevel {
+	shifted := int32(level) - shift
+	if shifted < int32(TraceLevel) {
+		return TraceLevel
+	}
+	if shifted > int32(FatalLevel) {
+		return FatalLevel
+	}
+	return LogLevel(shifted)
+}
+
+// maxLevel returns the higher of the levels.
+func maxLevel(a, b LogLevel) LogLevel {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,156 @@
+package mklog
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// levelHook records the levels of the entries accepted by a rule.
+type levelHook struct {
+	mu     sync.Mutex
+	levels []LogLevel
+}
+
+func (h *levelHook) Levels() []LogLevel           { return nil }
+func (h *levelHook) FiresFor(level LogLevel) bool { return true }
+func (h *levelHook) Fire(entry Entry) error {
+	h.mu.Lock()
+	h.levels = append(h.levels, entry.Level)
+	h.mu.Unlock()
+	return nil
+}
+
+// logged reports whether an entry was accepted since the last call and resets the hook.
+func (h *levelHook) logged() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	logged := len(h.levels) > 0
+	h.levels = nil
+	return logged
+}
+
+var allLevels = []LogLevel{TraceLevel, DebugLevel, InfoLevel, WarningLevel, ErrorLevel, FatalLevel}
+
+// logMethod is a log method of the Debugger with the condition it applied before the Verbosity replaced the
+// debug mode of the rules.
+type logMethod struct {
+	name   string
+	custom bool     // Whether the method takes the level
+	level  LogLevel // Level of the methods not taking one
+	log    func(d *Debugger, level LogLevel)
+	old    func(debugMode bool, debugModeStatus LogLevel) bool
+}
+
+var logMethods = []logMethod{
+	{"Trace", false, TraceLevel, func(d *Debugger, _ LogLevel) { d.Trace("m") }, tracing},
+	{"Debug", false, DebugLevel, func(d *Debugger, _ LogLevel) { d.Debug("m") }, debugging},
+	{"Info", false, InfoLevel, func(d *Debugger, _ LogLevel) { d.Info("m") }, always},
+	{"Warning", false, WarningLevel, func(d *Debugger, _ LogLevel) { d.Warning("m") }, always},
+	{"Error", false, ErrorLevel, func(d *Debugger, _ LogLevel) { d.Error("m") }, always},
+	{"Fatal", false, FatalLevel, func(d *Debugger, _ LogLevel) { d.Fatal("m") }, always},
+	{"Custom", true, 0, func(d *Debugger, level LogLevel) { d.Custom(level, "m") }, always},
+	{"CustomDebug", true, 0, func(d *Debugger, level LogLevel) { d.CustomDebug(level, "m") }, debugging},
+	{"CustomTrace", true, 0, func(d *Debugger, level LogLevel) { d.CustomTrace(level, "m") }, tracing},
+}
+
+func always(bool, LogLevel) bool                { return true }
+func debugging(debugMode bool, _ LogLevel) bool { return debugMode }
+func tracing(debugMode bool, debugModeStatus LogLevel) bool {
+	return debugMode && debugModeStatus == TraceLevel
+}
+
+// TestVerbosityTruthTable compares every log method on every combination of the levels and the deprecated debug
+// mode fields of a rule added with AddRule against the behavior before the Verbosity. The methods of one level
+// behave as before; the differences of the methods taking a level are the ones documented on WithVerbosity.
+func TestVerbosityTruthTable(t *testing.T) {
+	for _, debugMode := range []bool{false, true} {
+		for _, status := range allLevels {
+			for _, minLevel := range allLevels {
+				for _, maxLevel := range allLevels {
+					if maxLevel < minLevel {
+						continue
+					}
+					hook := &levelHook{}
+					d := (&Debugger{}).SetQuiet(true)
+					d.AddRule("app", LogRule{
+						MinLevel:        minLevel,
+						MaxLevel:        maxLevel,
+						DebugMode:       debugMode,
+						DebugModeStatus: status,
+						LogFormatter:    GetDefaults().Formatter,
+						FileLog:         FileLog{Enable: true, Writer: io.Discard},
+						Hooks:           []Hook{hook},
+					})
+
+					for _, m := range logMethods {
+						levels := allLevels
+						if !m.custom {
+							levels = []LogLevel{m.level}
+						}
+						for _, level := range levels {
+							m.log(d, level)
+							got := hook.logged()
+							inWindow := minLevel <= level && level <= maxLevel
+							old := inWindow && m.old(debugMode, status)
+							name := fmt.Sprintf("%s(%s) DebugMode=%v DebugModeStatus=%s Min=%s Max=%s", m.name,
+								level.GetLogLevelName(), debugMode, status.GetLogLevelName(),
+								minLevel.GetLogLevelName(), maxLevel.GetLogLevelName())
+
+							verbosity := debugModeVerbosity(debugMode, status)
+							switch {
+							case got == old:
+							case m.custom && old && !got && level < verbosity:
+								// The methods taking a level no longer log entries below the Verbosity.
+							case m.custom && m.name != "Custom" && !old && got && inWindow && level >= verbosity:
+								// CustomDebug and CustomTrace log the entries reaching the Verbosity without debug mode.
+							default:
+								t.Errorf("%s: got logged=%v, want %v", name, got, old)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestAddRuleKeepsExplicitVerbosity checks that AddRule keeps a TraceLevel Verbosity set explicitly instead of
+// deriving it from the deprecated debug mode fields.
+func TestAddRuleKeepsExplicitVerbosity(t *testing.T) {
+	rule := LogRule{MinLevel: TraceLevel, MaxLevel: FatalLevel, FileLog: FileLog{Enable: true, Writer: io.Discard}}
+	explicit := rule
+	explicit.SetVerbosity(TraceLevel)
+
+	d := (&Debugger{}).SetQuiet(true)
+	d.AddRule("zero", rule)
+	d.AddRule("set", explicit)
+	d.AddRule("option", rule, WithVerbosity(TraceLevel))
+
+	for module, want := range map[string]LogLevel{"zero": InfoLevel, "set": TraceLevel, "option": TraceLevel} {
+		if got := d.rules()[module][0].Verbosity; got != want {
+			t.Errorf("%s: got Verbosity %s, want %s", module, got.GetLogLevelName(), want.GetLogLevelName())
+		}
+	}
+}
+
+// TestBurstCaptureLowersVerbosity checks that an error burst logs the Debug entries the Verbosity leaves out.
+func TestBurstCaptureLowersVerbosity(t *testing.T) {
+	hook := &levelHook{}
+	d := (&Debugger{}).SetQuiet(true)
+	d.NewLogRule("app", WithMaxLevel(FatalLevel), WithFileWriter(io.Discard), WithHook(hook),
+		WithErrorBurstCapture(time.Minute, DebugLevel))
+
+	d.Debug("before the error")
+	if hook.logged() {
+		t.Fatal("Debug entry logged before the burst")
+	}
+	d.Error("failure")
+	hook.logged()
+	d.Debug("after the error")
+	if !hook.logged() {
+		t.Error("Debug entry not logged during the burst")
+	}
+}
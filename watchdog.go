@@ -0,0 +1,159 @@
+package mklog
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// Default settings of the async watchdog
+	MKLOG_AsyncHighWatermarkDefault = 0.9 // Fraction of the async buffer considered a stalled queue
+)
+
+// AsyncStats describes the async queue of a rule.
+type AsyncStats struct {
+	Module    string    `json:"module"`     // Module of the rule
+	Index     int       `json:"index"`      // Index of the rule within the module
+	QueueLen  int       `json:"queue_len"`  // Number of messages waiting in the queue
+	QueueCap  int       `json:"queue_cap"`  // Capacity of the queue
+	Stalls    uint64    `json:"stalls"`     // Number of stalls detected by the watchdog
+	Dropped   uint64    `json:"dropped"`    // Number of messages dropped by the overflow policy
+	Degraded  bool      `json:"degraded"`   // Whether the rule writes to the console only until the consumer recovers
+	LastWrite time.Time `json:"last_write"` // Time of the last message written by the consumer
+}
+
+// asyncWatchdog tracks the progress of the async consumer of a rule.
+type asyncWatchdog struct {
+	lastWrite int64  // Unix nanoseconds of the last message written by the consumer
+	writes    uint64 // Number of messages written by the consumer
+	stalls    uint64 // Number of detected stalls
+	dropped   uint64 // Number of messages dropped by the overflow policy
+	lastDrop  int64  // Unix nanoseconds of the last dropped message warning
+	degraded  int32  // Non-zero while producers bypass the queue
+
+	// Used by the maintenance task only
+	stallSince time.Time // Time the queue reached the high watermark
+	reported   bool      // Whether the current stall was reported
+	degradedAt uint64    // Number of written messages when the degraded mode was entered
+}
+
+// WithAsyncWatchdog watches the async queue of the rule: when it stays above the high watermark
+// for longer than stallTimeout, a diagnostic is passed to the error handler. With degrade set,
+// the rule then writes to the console only, bypassing the queue until the consumer writes again.
+func WithAsyncWatchdog(stallTimeout time.Duration, degrade bool) Option {
+	return func(lr *LogRule) {
+		lr.AsyncLog.StallTimeout = stallTimeout
+		lr.AsyncLog.DegradeOnStall = degrade
+	}
+}
+
+// scheduleWatchdog tracks the progress of the async consumer and registers the maintenance task checking
+// the async queue of the rule when a stall timeout is set.
+func (lr *LogRule) scheduleWatchdog(name string) {
+	if !lr.AsyncLog.Enable || lr.debugger == nil {
+		return
+	}
+
+	lr.watchdog = &asyncWatchdog{}
+	atomic.StoreInt64(&lr.watchdog.lastWrite, lr.debugger.now().UnixNano())
+	if lr.AsyncLog.StallTimeout <= 0 {
+		return
+	}
+
+	interval := lr.AsyncLog.StallTimeout / 4
+	if interval <= 0 {
+		interval = lr.AsyncLog.StallTimeout
+	}
+	lr.debugger.AddMaintenanceTask(name, interval, lr.checkStall)
+}
+
+// checkStall reports a queue stuck at the high watermark and switches the rule into or out of the degraded mode.
+func (lr *LogRule) checkStall(now time.Time) error {
+	w := lr.watchdog
+	lastWrite := time.Unix(0, atomic.LoadInt64(&w.lastWrite))
+
+	// Leave the degraded mode once the consumer has written again.
+	if atomic.LoadInt32(&w.degraded) != 0 && atomic.LoadUint64(&w.writes) > w.degradedAt {
+		atomic.StoreInt32(&w.degraded, 0)
+		lr.reportError(fmt.Errorf("[mklog] async consumer of %s recovered, leaving console-only mode", lr.ModuleName))
+	}
+
+	depth, capacity := len(lr.logChannel), cap(lr.logChannel)
+	if capacity == 0 || float64(depth) < float64(capacity)*MKLOG_AsyncHighWatermarkDefault {
+		w.stallSince = time.Time{}
+		w.reported = false
+		return nil
+	}
+	if w.stallSince.IsZero() {
+		w.stallSince = now
+		return nil
+	}
+	// Report each stall once; the queue has to drop below the watermark to start a new one.
+	if w.reported || now.Sub(w.stallSince) < lr.AsyncLog.StallTimeout {
+		return nil
+	}
+	w.reported = true
+	atomic.AddUint64(&w.stalls, 1)
+
+	diagnostic := fmt.Errorf("[mklog] async consumer of %s stalled: queue %d/%d, last write %s",
+		lr.ModuleName, depth, capacity, lastWrite.Format(time.RFC3339))
+	if lr.AsyncLog.DegradeOnStall {
+		w.degradedAt = atomic.LoadUint64(&w.writes)
+		atomic.StoreInt32(&w.degraded, 1)
+		diagnostic = fmt.Errorf("%w, switching to console-only mode", diagnostic)
+	}
+	lr.reportError(diagnostic)
+	return nil
+}
+
+// degraded reports whether the rule bypasses its async queue.
+func (lr *LogRule) degraded() bool {
+	return lr.watchdog != nil && atomic.LoadInt32(&lr.watchdog.degraded) != 0
+}
+
+// markWritten records a message written by the async consumer.
+func (lr *LogRule) markWritten() {
+	if lr.watchdog == nil {
+		return
+	}
+	atomic.AddUint64(&lr.watchdog.writes, 1)
+	if lr.debugger != nil {
+		atomic.StoreInt64(&lr.watchdog.lastWrite, lr.debugger.now().UnixNano())
+	} else {
+		atomic.StoreInt64(&lr.watchdog.lastWrite, time.Now().UnixNano())
+	}
+}
+
+// asyncStats returns the state of the async queues of all rules with async logging.
+func (d *Debugger) asyncStats() []AsyncStats {
+	var stats []AsyncStats
+	for module, rules := range d.rules() {
+		for i, v := range rules {
+			if !v.AsyncLog.Enable {
+				continue
+			}
+			s := AsyncStats{
+				Module:   module,
+				Index:    i,
+				QueueLen: len(v.logChannel),
+				QueueCap: cap(v.logChannel),
+			}
+			if w := v.watchdog; w != nil {
+				s.Stalls = atomic.LoadUint64(&w.stalls)
+				s.Dropped = atomic.LoadUint64(&w.dropped)
+				s.Degraded = atomic.LoadInt32(&w.degraded) != 0
+				s.LastWrite = time.Unix(0, atomic.LoadInt64(&w.lastWrite))
+			}
+			stats = append(stats, s)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Module != stats[j].Module {
+			return stats[i].Module < stats[j].Module
+		}
+		return stats[i].Index < stats[j].Index
+	})
+	return stats
+}
@@ -0,0 +1,77 @@
+package mklog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAsyncWatchdogStall reports a queue stuck at the high watermark once the stall timeout elapsed on the
+// Debugger clock, once per stall, switches the rule to the console while the sinks keep receiving its entries,
+// and reports the recovery once the consumer writes again.
+func TestAsyncWatchdogStall(t *testing.T) {
+	const capacity = 4
+	start := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	rec := &errorRecorder{}
+	w := &blockingWriter{started: make(chan struct{}, 2*capacity), release: make(chan struct{})}
+	sink := &entrySink{}
+	d := (&Debugger{}).SetQuiet(true).SetClock(clock.Now)
+	d.SetErrorHandler(rec.handle)
+	d.SetErrorCoalescing(0)
+	d.NewLogRule("billing", WithFileWriter(w), WithSink(sink), WithAsyncLog(true, capacity),
+		WithAsyncWatchdog(time.Minute, true))
+	defer d.Close(context.Background())
+
+	d.Info("stuck")
+	<-w.started // The consumer holds the first message, the queue fills up behind it.
+	for i := 0; i < capacity; i++ {
+		d.Info("queued %d", i)
+	}
+
+	d.RunMaintenance() // The queue reached the watermark, nothing is reported yet.
+	clock.Set(start.Add(30 * time.Second))
+	d.RunMaintenance()
+	if errs := rec.get(); len(errs) != 0 {
+		t.Fatalf("errors before the stall timeout = %v", errs)
+	}
+	clock.Set(start.Add(2 * time.Minute))
+	d.RunMaintenance()
+	clock.Set(start.Add(3 * time.Minute))
+	d.RunMaintenance() // The same stall is not reported again.
+
+	errs := rec.get()
+	if len(errs) != 1 {
+		t.Fatalf("errors = %v, want one stall report", errs)
+	}
+	want := "[mklog] async consumer of billing stalled: queue 4/4, last write " + start.Format(time.RFC3339) +
+		", switching to console-only mode"
+	if errs[0].Error() != want {
+		t.Errorf("stall report = %q, want %q", errs[0], want)
+	}
+	stats := d.asyncStats()
+	if len(stats) != 1 || stats[0].Stalls != 1 || !stats[0].Degraded {
+		t.Fatalf("async stats = %+v, want one stall and the degraded mode", stats)
+	}
+
+	d.Info("bypassed")
+	if got := sink.messages(); got[len(got)-1] != "bypassed" {
+		t.Errorf("sink messages = %q, want the entry logged in the degraded mode", got)
+	}
+
+	close(w.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(d.rules()["billing"][0].logChannel) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Set(start.Add(4 * time.Minute))
+	d.RunMaintenance()
+	errs = rec.get()
+	if len(errs) != 2 || !strings.Contains(errs[1].Error(), "async consumer of billing recovered") {
+		t.Fatalf("errors = %v, want the recovery reported", errs)
+	}
+	if stats := d.asyncStats(); stats[0].Degraded {
+		t.Errorf("async stats = %+v, want the degraded mode left", stats)
+	}
+}
@@ -0,0 +1,174 @@
+package mklog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+)
+
+var (
+	// Default settings for webhook notifications
+	MKLOG_WebhookTimeoutDefault   = 5 * time.Second // Default timeout of a single webhook request
+	MKLOG_WebhookQueueSizeDefault = 16              // Default number of entries waiting to be sent
+)
+
+// WebhookNotifier is a Hook posting log entries to Slack/Telegram-style webhooks.
+// Entries are rendered with a text/template into the JSON payload and sent from a background goroutine,
+// so the logging path is never blocked; entries arriving while the queue is full are dropped.
+type WebhookNotifier struct {
+	URL    string             // Address of the webhook.
+	Client *http.Client       // HTTP client used to send the payloads.
+	Header http.Header        // Additional headers sent with each request.
+	tmpl   *template.Template // Template rendering the payload.
+	levels []LogLevel         // Levels the notifier is fired for.
+
+	queue        chan Entry     // Queue of entries waiting to be sent
+	wg           sync.WaitGroup // Tracks the sending goroutine
+	queueMu      sync.RWMutex   // Orders sends to the queue with closing it
+	closed       bool           // Whether Close closed the queue, guarded by queueMu
+	mu           sync.RWMutex   // Guards the error handler
+	errorHandler ErrorHandler   // Handler for failed sends
+}
+
+// NewWebhookNotifier creates a notifier posting entries of the given levels to the webhook url.
+// The template receives the Entry and must render the JSON payload; the "json" function
+// quotes a value as JSON, e.g. {"text": {{json .Message}}}. Without levels only Fatal entries are sent.
+func NewWebhookNotifier(url string, tmpl string, levels ...LogLevel) (*WebhookNotifier, error) {
+	t, err := template.New("webhook").Funcs(template.FuncMap{"json": jsonTemplateFunc}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("[mklog] failed to parse webhook template: %w", err)
+	}
+	if len(levels) == 0 {
+		levels = []LogLevel{FatalLevel}
+	}
+
+	n := &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: MKLOG_WebhookTimeoutDefault},
+		Header: make(http.Header),
+		tmpl:   t,
+		levels: levels,
+		queue:  make(chan Entry, MKLOG_WebhookQueueSizeDefault),
+	}
+	n.Header.Set("Content-Type", "application/json")
+
+	n.wg.Add(1)
+	go n.run()
+	return n, nil
+}
+
+// Levels returns the log levels the notifier is fired for.
+func (n *WebhookNotifier) Levels() []LogLevel {
+	return n.levels
+}
+
+// Fire queues the entry for sending without blocking; it fails if the queue is full or the notifier is closed.
+func (n *WebhookNotifier) Fire(entry Entry) error {
+	n.queueMu.RLock()
+	defer n.queueMu.RUnlock()
+	if n.closed {
+		return fmt.Errorf("webhook notifier is closed, entry dropped")
+	}
+	select {
+	case n.queue <- entry:
+		return nil
+	default:
+		return fmt.Errorf("webhook queue is full, entry dropped")
+	}
+}
+
+// Close stops accepting entries and waits until the queued ones are sent. Entries still queued when the
+// process exits without Close are lost.
+func (n *WebhookNotifier) Close() error {
+	n.queueMu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.queueMu.Unlock()
+	n.wg.Wait()
+	return nil
+}
+
+// SetErrorHandler sets the handler receiving failed sends.
+func (n *WebhookNotifier) SetErrorHandler(handler ErrorHandler) {
+	n.mu.Lock()
+	n.errorHandler = handler
+	n.mu.Unlock()
+}
+
+// run sends queued entries until the queue is closed.
+func (n *WebhookNotifier) run() {
+	defer n.wg.Done()
+	for entry := range n.queue {
+		if err := n.send(entry); err != nil {
+			n.reportError(err)
+		}
+	}
+}
+
+// send renders the entry and posts it, retrying once on failure.
+func (n *WebhookNotifier) send(entry Entry) error {
+	var payload bytes.Buffer
+	if err := n.tmpl.Execute(&payload, entry); err != nil {
+		return fmt.Errorf("[mklog] failed to render webhook payload: %w", err)
+	}
+
+	err := n.post(payload.Bytes())
+	if err != nil {
+		err = n.post(payload.Bytes())
+	}
+	if err != nil {
+		return fmt.Errorf("[mklog] failed to send webhook notification: %w", err)
+	}
+	return nil
+}
+
+// post performs a single webhook request.
+func (n *WebhookNotifier) post(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for key, values := range n.Header {
+		req.Header[key] = values
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) // Reading the body lets the client reuse the connection.
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// reportError passes the error to the error handler, see ErrorHandler.Report.
+func (n *WebhookNotifier) reportError(err error) {
+	n.mu.RLock()
+	handler := n.errorHandler
+	n.mu.RUnlock()
+
+	handler.Report(err)
+}
+
+// jsonTemplateFunc quotes the value as JSON for use inside payload templates.
+func jsonTemplateFunc(v interface{}) (string, error) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
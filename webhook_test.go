@@ -0,0 +1,111 @@
+package mklog
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestWebhookNotifierPayload posts the rendered template with the configured headers over one kept-alive
+// connection.
+func TestWebhookNotifierPayload(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []map[string]interface{}
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if token := r.Header.Get("X-Token"); token != "secret" {
+			t.Errorf("X-Token = %q", token)
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+		io.WriteString(w, strings.Repeat("a body larger than the read buffer of the client ", 40000))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	n, err := NewWebhookNotifier(server.URL, `{"text": {{json .Message}}, "level": {{json .LevelName}}, "err": {{json .Err}}}`, ErrorLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Header.Set("X-Token", "secret")
+	for _, msg := range []string{`quote " and \ backslash`, "second", "third"} {
+		if err := n.Fire(Entry{Level: ErrorLevel, LevelName: "ERROR", Message: msg, Err: io.ErrUnexpectedEOF}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond) // Send the entries one after the other.
+	}
+	n.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 3 {
+		t.Fatalf("got %d payloads, want 3", len(payloads))
+	}
+	want := map[string]interface{}{"text": `quote " and \ backslash`, "level": "ERROR", "err": "unexpected EOF"}
+	for key, value := range want {
+		if payloads[0][key] != value {
+			t.Errorf("payload %s = %v, want %v", key, payloads[0][key], value)
+		}
+	}
+	if got := atomic.LoadInt32(&conns); got != 1 {
+		t.Errorf("opened %d connections, want 1 kept alive", got)
+	}
+}
+
+// TestWebhookNotifierDoesNotBlock keeps Fire from blocking on a stuck webhook, dropping the entries beyond the
+// queue.
+func TestWebhookNotifierDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		atomic.AddInt32(&received, 1)
+	}))
+	defer server.Close()
+
+	n, err := NewWebhookNotifier(server.URL, `{"text": {{json .Message}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const fired = 100
+	start := time.Now()
+	dropped := 0
+	for i := 0; i < fired; i++ {
+		if err := n.Fire(Entry{Level: FatalLevel, Message: "stuck"}); err != nil {
+			dropped++
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fire blocked for %v", elapsed)
+	}
+	if dropped == 0 || dropped == fired {
+		t.Errorf("dropped %d of %d entries, want the ones beyond the queue", dropped, fired)
+	}
+
+	close(release)
+	n.Close()
+	if got := int(atomic.LoadInt32(&received)); got != fired-dropped {
+		t.Errorf("webhook received %d entries, want the %d queued", got, fired-dropped)
+	}
+}